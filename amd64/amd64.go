@@ -22,6 +22,7 @@ import (
 	_ "unsafe"
 
 	"github.com/karlo195/tamago/amd64/lapic"
+	"github.com/karlo195/tamago/boottime"
 	"github.com/karlo195/tamago/internal/reg"
 )
 
@@ -35,14 +36,19 @@ const (
 
 // aliased constants
 const (
+	LAPIC_ID   = LAPIC_BASE + lapic.LAPIC_ID
 	LAPIC_EOI  = LAPIC_BASE + lapic.LAPIC_EOI
 	LAPIC_SVR  = LAPIC_BASE + lapic.LAPIC_SVR
 	LAPIC_ICRL = LAPIC_BASE + lapic.LAPIC_ICRL
 
+	LAPIC_LVT_PERF = LAPIC_BASE + lapic.LAPIC_LVT_PERF
+
 	SVR_ENABLE = lapic.SVR_ENABLE
+	LVT_MASK   = lapic.LVT_MASK
 
 	ICR_DST      = lapic.ICR_DST
 	ICR_DST_REST = lapic.ICR_DST_REST
+	ICR_DLV_NMI  = lapic.ICR_DLV_NMI
 )
 
 //go:linkname ramStackOffset runtime.ramStackOffset
@@ -50,7 +56,7 @@ var ramStackOffset uint64 = 0x100000 // 1 MB
 
 // CPU represents the Bootstrap Processor (BSP) instance.
 type CPU struct {
-	// Timer multiplier
+	// Timer multiplier, calibrated on Init()
 	TimerMultiplier float64
 	// Timer offset in nanoseconds
 	TimerOffset int64
@@ -58,12 +64,27 @@ type CPU struct {
 	// LAPIC represents the Local APIC instance
 	LAPIC *lapic.LAPIC
 
+	// APInit, when set before [CPU.InitSMP], is invoked once on each
+	// Application Processor right before its first task (see [CPU.Task])
+	// to perform per-core initialization (e.g. LAPIC timer or MSR
+	// programming).
+	//
+	// The function is executed with interrupts disabled on the AP system
+	// stack, therefore it must not allocate memory nor block.
+	APInit func(ap *CPU)
+
+	// MWaitHint represents the target C-state hint used by
+	// [CPU.MWaitIdleGovernor] (e.g. [MWAIT_C1]).
+	MWaitHint uint32
+
 	// aps represents the Application Processors on symmetric
 	// multiprocessing (SMP systems, it is populated by [CPU.InitSMP] with
 	// the available number of additional cores.
 	aps []*CPU
 	// init represents the last initialized CPU index
 	init int
+	// apInit tracks whether APInit has been executed on this AP
+	apInit bool
 
 	// features
 	features Features
@@ -99,7 +120,13 @@ func (cpu *CPU) Init() {
 	}
 
 	cpu.initFeatures()
+	cpu.initProtection()
 	cpu.initTimers()
+	cpu.initProcessorIndex()
+
+	if cpu.freq > 1 {
+		boottime.Frequency = uint64(cpu.freq)
+	}
 }
 
 // Name returns the CPU identifier.
@@ -13,6 +13,11 @@
 #define PDT   0xb000	// Page Directory Table           (2MB entries)
 #define PT    0xc000	// Page Table                     (4kB entries)
 
+// runtime g and m structure offsets (see runtime/runtime2.go)
+#define g_m		48
+#define g_sched_sp	56
+#define m_g0		0
+
 // These legacy prefixes are used in 16-bit Real Mode to ensure valid Go
 // assembly interpretation.
 
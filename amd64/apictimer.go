@@ -0,0 +1,85 @@
+// x86-64 processor support
+// https://github.com/karlo195/tamago
+//
+// Copyright (c) The TamaGo Authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+package amd64
+
+import (
+	"errors"
+	"math"
+
+	"github.com/karlo195/tamago/amd64/lapic"
+)
+
+// LAPIC Timer frequency in Hz (at divisor 1), calibrated on first use
+var apicTimerFreq uint64
+
+// calibrateAPICTimer measures the LAPIC Timer frequency against the TSC.
+func (cpu *CPU) calibrateAPICTimer() (freq uint64, err error) {
+	if apicTimerFreq != 0 {
+		return apicTimerFreq, nil
+	}
+
+	if cpu.freq <= 1 {
+		return 0, errors.New("core frequency is unavailable")
+	}
+
+	cpu.LAPIC.SetTimer(0, lapic.TIMER_MODE_ONE_SHOT)
+	cpu.LAPIC.MaskTimer(true)
+	cpu.LAPIC.SetTimerDivide(1)
+	cpu.LAPIC.SetTimerCount(math.MaxUint32)
+
+	ticks := uint64(cpu.freq) * calibrationTime / 1000
+	start := read_tsc()
+
+	for read_tsc()-start < ticks {
+	}
+
+	elapsed := uint64(math.MaxUint32 - cpu.LAPIC.TimerCount())
+	tsc := read_tsc() - start
+
+	cpu.LAPIC.SetTimerCount(0)
+
+	if elapsed == 0 {
+		return 0, errors.New("LAPIC timer is unavailable")
+	}
+
+	apicTimerFreq = elapsed * uint64(cpu.freq) / tsc
+
+	return apicTimerFreq, nil
+}
+
+// EnableTimer enables the LAPIC Timer callback API (see
+// [lapic.LAPIC.SetPeriodic] and [lapic.LAPIC.SetOneShot]), by calibrating the
+// LAPIC Timer frequency against the TSC and allocating its interrupt vector,
+// serviced through [CPU.ServiceInterrupts].
+//
+// The LAPIC Timer is specific to each core and shared with [CPU.SetAlarm],
+// therefore it must be programmed on the processor servicing interrupts and
+// [CPU.MWaitIdleGovernor] must not be used at the same time.
+func (cpu *CPU) EnableTimer() (err error) {
+	if cpu.LAPIC.TimerVector != 0 {
+		return
+	}
+
+	freq, err := cpu.calibrateAPICTimer()
+
+	if err != nil {
+		return
+	}
+
+	vector, err := cpu.AllocateInterrupt(cpu.LAPIC.HandleTimer)
+
+	if err != nil {
+		return
+	}
+
+	cpu.LAPIC.TimerFrequency = freq
+	cpu.LAPIC.TimerVector = vector
+
+	return
+}
@@ -0,0 +1,106 @@
+// x86-64 processor support
+// https://github.com/karlo195/tamago
+//
+// Copyright (c) The TamaGo Authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+package amd64
+
+import (
+	"bytes"
+	"encoding/binary"
+
+	"github.com/karlo195/tamago/internal/mem"
+)
+
+// Boot information offsets
+const (
+	// PVH start information (hvm_start_info)
+	pvhCmdline = 0x18
+	pvhRSDP    = 0x20
+
+	// Linux boot parameters (boot_params)
+	linuxRSDP       = 0x070
+	linuxExtCmdline = 0x0c8
+	linuxCmdline    = 0x228
+	linuxCmdlineMax = 0x238
+)
+
+// maximum command line length
+const cmdlineSize = 4096
+
+// defined in bootmem.s
+func bootmem()
+func ramEnd() uint64
+func bootInfo() (pvh uint64, linux uint64)
+func multiboot2() uint64
+
+// RAMEnd returns the end address of the usable RAM region holding the Go
+// runtime, as reported by the boot memory map (e820 entries in Linux boot
+// parameters, PVH start information or Multiboot2 information).
+//
+// The boot memory map is parsed before runtime initialization, when a zero
+// runtime.ramSize is linked by the board package it is sized to three quarters
+// of the memory available after runtime.ramStart, the remainder can then be
+// used as DMA region (see dma.Init()) without rebuilding on guest memory size
+// changes.
+func RAMEnd() (end uint64, ok bool) {
+	end = ramEnd()
+	return end, end != 0
+}
+
+func read(addr uint64, size int) (buf []byte) {
+	buf, _ = mem.Read(uint(addr), size)
+	return
+}
+
+// CommandLine returns the kernel command line passed through PVH start
+// information, Linux boot parameters or Multiboot2 information.
+func CommandLine() string {
+	var addr uint64
+	var size = cmdlineSize
+
+	if info, ok := Multiboot2(); ok {
+		return info.CommandLine
+	}
+
+	switch pvh, linux := bootInfo(); {
+	case pvh != 0:
+		addr = binary.LittleEndian.Uint64(read(pvh, pvhCmdline+8)[pvhCmdline:])
+	case linux != 0:
+		buf := read(linux, linuxCmdlineMax+4)
+		addr = uint64(binary.LittleEndian.Uint32(buf[linuxExtCmdline:]))<<32 |
+			uint64(binary.LittleEndian.Uint32(buf[linuxCmdline:]))
+
+		if n := int(binary.LittleEndian.Uint32(buf[linuxCmdlineMax:])); n > 0 && n < size {
+			size = n + 1
+		}
+	}
+
+	if addr == 0 {
+		return ""
+	}
+
+	buf := read(addr, size)
+
+	if i := bytes.IndexByte(buf, 0); i >= 0 {
+		buf = buf[:i]
+	}
+
+	return string(buf)
+}
+
+// RSDP returns the ACPI Root System Description Pointer address passed
+// through PVH start information or Linux boot parameters, when available.
+func RSDP() (addr uint, ok bool) {
+	switch pvh, linux := bootInfo(); {
+	case pvh != 0:
+		addr = uint(binary.LittleEndian.Uint64(read(pvh, pvhRSDP+8)[pvhRSDP:]))
+	case linux != 0:
+		addr = uint(binary.LittleEndian.Uint64(read(linux, linuxRSDP+8)[linuxRSDP:]))
+	}
+
+	return addr, addr != 0
+}
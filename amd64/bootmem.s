@@ -0,0 +1,230 @@
+// x86-64 processor support
+// https://github.com/usbarmory/tamago
+//
+// Copyright (c) The TamaGo Authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+#include "textflag.h"
+
+// Linux x86 boot protocol (Documentation/arch/x86/zero-page.rst)
+#define BOOT_PARAMS_E820_ENTRIES 0x1e8
+#define BOOT_PARAMS_HEADER       0x202
+#define BOOT_PARAMS_E820_TABLE   0x2d0
+#define BOOT_PARAMS_HDRS         0x53726448	// "HdrS"
+#define E820_ENTRY_SIZE          20
+#define E820_MAX_ENTRIES         128
+
+// Xen PVH boot protocol (xen/include/public/arch-x86/hvm/start_info.h)
+#define PVH_MAGIC                0x336ec578
+#define PVH_VERSION              0x04
+#define PVH_MEMMAP_PADDR         0x28
+#define PVH_MEMMAP_ENTRIES       0x30
+#define PVH_ENTRY_SIZE           24
+
+// Multiboot2 boot protocol (Multiboot2 Specification version 2.0)
+#define MB2_MAGIC                0x36d76289
+#define MB2_TAG_END              0
+#define MB2_TAG_MMAP             6
+#define MB2_TAG_HEADER_SIZE      8
+#define MB2_MMAP_HEADER_SIZE     16
+
+// usable RAM memory type
+#define MEM_RAM 1
+
+// boot information must lie within the identity mapped region preceding the
+// uncacheable 32-bit MMIO hole (see cpuinit)
+#define MEM_MIN   0x1000
+#define MEM_LIMIT 0xc0000000
+
+// default RAM size, when not sized from the boot memory map
+#define RAM_SIZE_DEFAULT 0x40000000
+
+// end of the usable RAM region holding the Go runtime, 0 when unknown
+DATA	·bootRAMEnd+0(SB)/8, $0
+GLOBL	·bootRAMEnd(SB),NOPTR,$8
+
+// boot protocol information addresses, 0 when unknown
+DATA	·bootPVH+0(SB)/8, $0
+GLOBL	·bootPVH(SB),NOPTR,$8
+DATA	·bootLinux+0(SB)/8, $0
+GLOBL	·bootLinux(SB),NOPTR,$8
+DATA	·bootMultiboot2+0(SB)/8, $0
+GLOBL	·bootMultiboot2(SB),NOPTR,$8
+
+// func bootmem()
+//
+// The boot memory map is read from the Linux boot parameters (RSI), the PVH
+// start information (RBX) or the Multiboot2 information structure (RBX, with
+// the boot magic in RBP), to find the usable RAM region holding ramStart.
+//
+// When a zero ramSize is linked, it is set to three quarters of the available
+// memory, leaving the remainder to DMA allocation (see RAMEnd()).
+TEXT ·bootmem(SB),NOSPLIT|NOFRAME,$0
+	MOVQ	$MEM_LIMIT, R11
+	MOVL	BX, BX
+
+	// Multiboot2 information structure, 32-bit entry
+	CMPL	BP, $MB2_MAGIC
+	JNE	pvh
+	CMPQ	BX, $MEM_MIN
+	JB	pvh
+	CMPQ	BX, R11
+	JAE	pvh
+
+	MOVQ	BX, ·bootMultiboot2(SB)
+
+	// tags follow the fixed part (total_size, reserved)
+	MOVL	(BX), DX
+	ADDQ	BX, DX
+	LEAQ	MB2_TAG_HEADER_SIZE(BX), DI
+
+next_tag:
+	LEAQ	MB2_TAG_HEADER_SIZE(DI), AX
+	CMPQ	AX, DX
+	JA	done
+
+	// tag layout: type (4), size (4)
+	MOVL	(DI), AX
+	CMPL	AX, $MB2_TAG_END
+	JE	done
+	CMPL	AX, $MB2_TAG_MMAP
+	JE	mb2_mmap
+
+	// tags are 8 bytes aligned
+	MOVL	4(DI), AX
+	ADDL	$7, AX
+	ANDL	$~7, AX
+	JZ	done
+	ADDQ	AX, DI
+	JMP	next_tag
+
+mb2_mmap:
+	// memory map layout: type (4), size (4), entry_size (4),
+	// entry_version (4), entries
+	MOVL	8(DI), R8
+	CMPL	R8, $E820_ENTRY_SIZE
+	JB	done
+
+	MOVL	4(DI), AX
+	SUBL	$MB2_MMAP_HEADER_SIZE, AX
+	JB	done
+	XORL	DX, DX
+	DIVL	R8
+	MOVL	AX, CX
+
+	ADDQ	$MB2_MMAP_HEADER_SIZE, DI
+	JMP	check_map
+
+pvh:
+	// PVH start information (hvm_start_info), 32-bit entry
+	CMPQ	BX, $MEM_MIN
+	JB	linux
+	CMPQ	BX, R11
+	JAE	linux
+	CMPL	(BX), $PVH_MAGIC
+	JNE	linux
+	MOVQ	BX, ·bootPVH(SB)
+
+	CMPL	PVH_VERSION(BX), $1	// memory map requires version >= 1
+	JB	done
+
+	MOVQ	PVH_MEMMAP_PADDR(BX), DI
+	MOVL	PVH_MEMMAP_ENTRIES(BX), CX
+	MOVQ	$PVH_ENTRY_SIZE, R8
+	JMP	check_map
+
+linux:
+	// Linux boot parameters (boot_params), 64-bit entry
+	CMPQ	SI, $MEM_MIN
+	JB	done
+	CMPQ	SI, R11
+	JAE	done
+	CMPL	BOOT_PARAMS_HEADER(SI), $BOOT_PARAMS_HDRS
+	JNE	done
+
+	MOVQ	SI, ·bootLinux(SB)
+
+	LEAQ	BOOT_PARAMS_E820_TABLE(SI), DI
+	MOVBLZX	BOOT_PARAMS_E820_ENTRIES(SI), CX
+	MOVQ	$E820_ENTRY_SIZE, R8
+
+check_map:
+	CMPQ	DI, $MEM_MIN
+	JB	done
+	CMPQ	DI, R11
+	JAE	done
+	CMPL	CX, $E820_MAX_ENTRIES
+	JA	done
+
+	MOVQ	runtime·ramStart(SB), R9
+
+next_entry:
+	TESTL	CX, CX
+	JZ	done
+
+	// entry layout: address (8), size (8), type (4)
+	CMPL	16(DI), $MEM_RAM
+	JNE	skip_entry
+
+	MOVQ	(DI), AX
+	CMPQ	R9, AX
+	JB	skip_entry
+
+	MOVQ	8(DI), DX
+	ADDQ	AX, DX
+	CMPQ	R9, DX
+	JAE	skip_entry
+
+	// clamp to identity mapped cacheable memory
+	CMPQ	DX, R11
+	JBE	found
+	MOVQ	R11, DX
+
+found:
+	MOVQ	DX, ·bootRAMEnd(SB)
+
+	CMPQ	runtime·ramSize(SB), $0
+	JNE	done
+
+	// ramSize = 3/4 of available memory, 2MB aligned
+	SUBQ	R9, DX
+	MOVQ	DX, AX
+	SHRQ	$2, AX
+	SUBQ	AX, DX
+	ANDQ	$~0x1fffff, DX
+	MOVQ	DX, runtime·ramSize(SB)
+	RET
+
+skip_entry:
+	ADDQ	R8, DI
+	DECL	CX
+	JMP	next_entry
+
+done:
+	CMPQ	runtime·ramSize(SB), $0
+	JNE	ret
+	MOVQ	$RAM_SIZE_DEFAULT, runtime·ramSize(SB)
+ret:
+	RET
+
+// func ramEnd() uint64
+TEXT ·ramEnd(SB),NOSPLIT,$0-8
+	MOVQ	·bootRAMEnd(SB), AX
+	MOVQ	AX, ret+0(FP)
+	RET
+
+// func multiboot2() uint64
+TEXT ·multiboot2(SB),NOSPLIT,$0-8
+	MOVQ	·bootMultiboot2(SB), AX
+	MOVQ	AX, ret+0(FP)
+	RET
+
+// func bootInfo() (pvh uint64, linux uint64)
+TEXT ·bootInfo(SB),NOSPLIT,$0-16
+	MOVQ	·bootPVH(SB), AX
+	MOVQ	AX, pvh+0(FP)
+	MOVQ	·bootLinux(SB), AX
+	MOVQ	AX, linux+8(FP)
+	RET
@@ -0,0 +1,116 @@
+// x86-64 processor support
+// https://github.com/karlo195/tamago
+//
+// Copyright (c) The TamaGo Authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+package amd64
+
+import (
+	"github.com/karlo195/tamago/internal/reg"
+)
+
+// High Precision Event Timer registers
+// (IA-PC HPET (High Precision Event Timers) Specification - 2.3).
+const (
+	HPET_BASE = 0xfed00000
+
+	HPET_PERIOD  = HPET_BASE + 0x04
+	HPET_CONFIG  = HPET_BASE + 0x10
+	HPET_ENABLE  = 0
+	HPET_COUNTER = HPET_BASE + 0xf0
+
+	// maximum valid counter period in femtoseconds
+	hpetMaxPeriod = 100000000
+)
+
+// 8254 Programmable Interval Timer registers
+const (
+	PIT_FREQ     = 1193182
+	PIT_CHANNEL2 = 0x42
+	PIT_MODE     = 0x43
+
+	// channel 2 gate and output control
+	PIT_CONTROL = 0x61
+	PIT_GATE    = 0
+	PIT_SPEAKER = 1
+	PIT_OUTPUT  = 5
+)
+
+const (
+	// calibration interval in milliseconds
+	calibrationTime = 10
+	// upper bound for calibration loops, in TSC cycles, for absent or
+	// stuck timers
+	calibrationTimeout = 1 << 30
+)
+
+// measureHPET returns the TSC frequency measured against the HPET main
+// counter.
+func measureHPET() (freq uint32) {
+	period := uint64(reg.Read(HPET_PERIOD))
+
+	// absent devices read as all ones
+	if period == 0 || period > hpetMaxPeriod {
+		return
+	}
+
+	config := reg.Read(HPET_CONFIG)
+	reg.Write(HPET_CONFIG, config|1<<HPET_ENABLE)
+	defer reg.Write(HPET_CONFIG, config)
+
+	ticks := uint32(calibrationTime * 1e12 / period)
+	start := reg.Read(HPET_COUNTER)
+	tsc := read_tsc()
+
+	var elapsed uint32
+
+	for elapsed < ticks {
+		if read_tsc()-tsc > calibrationTimeout {
+			return
+		}
+
+		elapsed = reg.Read(HPET_COUNTER) - start
+	}
+
+	delta := read_tsc() - tsc
+	ns := uint64(elapsed) * period / 1e6
+
+	if ns == 0 {
+		return
+	}
+
+	return uint32(delta * uint64(refFreq) / ns)
+}
+
+// measurePIT returns the TSC frequency measured against the PIT channel 2
+// one-shot countdown.
+func measurePIT() (freq uint32) {
+	count := uint16(PIT_FREQ * calibrationTime / 1000)
+	control := reg.In8(PIT_CONTROL)
+
+	// disable speaker and gate
+	reg.Out8(PIT_CONTROL, control&^(1<<PIT_SPEAKER|1<<PIT_GATE))
+	defer reg.Out8(PIT_CONTROL, control)
+
+	// channel 2, lobyte/hibyte access, mode 0 (interrupt on terminal count)
+	reg.Out8(PIT_MODE, 0b10110000)
+	reg.Out8(PIT_CHANNEL2, uint8(count))
+	reg.Out8(PIT_CHANNEL2, uint8(count>>8))
+
+	// start countdown
+	reg.Out8(PIT_CONTROL, control&^(1<<PIT_SPEAKER)|1<<PIT_GATE)
+	tsc := read_tsc()
+
+	for (reg.In8(PIT_CONTROL)>>PIT_OUTPUT)&1 == 0 {
+		if read_tsc()-tsc > calibrationTimeout {
+			return
+		}
+	}
+
+	delta := read_tsc() - tsc
+
+	return uint32(delta * PIT_FREQ / uint64(count))
+}
@@ -0,0 +1,179 @@
+// x86-64 processor support
+// https://github.com/karlo195/tamago
+//
+// Copyright (c) The TamaGo Authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+package amd64
+
+import (
+	"errors"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/karlo195/tamago/amd64/lapic"
+)
+
+// CallTimeout is the default timeout for cross-processor call completion.
+const CallTimeout = 1 * time.Second
+
+// Cross-processor call states
+const (
+	callIdle = iota
+	callPending
+	callRunning
+	callDone
+)
+
+// call represents a cross-processor call mailbox
+type call struct {
+	fn    func()
+	state atomic.Uint32
+}
+
+var (
+	calls     []call
+	callsOnce sync.Once
+	callsLock sync.Mutex
+)
+
+// runCall executes the pending cross-processor call of the current processor,
+// it is invoked in interrupt context by ·handleCall.
+//
+//go:nosplit
+func runCall() {
+	i := CurrentCPU()
+
+	if i >= len(calls) {
+		return
+	}
+
+	c := &calls[i]
+
+	// claim the call, unless cancelled by its timeout
+	if !c.state.CompareAndSwap(callPending, callRunning) {
+		return
+	}
+
+	c.fn()
+	c.state.Store(callDone)
+}
+
+// cancel withdraws all pending calls which have not yet been claimed by their
+// target processor.
+func cancel(targets []int, self int) {
+	for _, i := range targets {
+		if i != self {
+			calls[i].state.CompareAndSwap(callPending, callIdle)
+		}
+	}
+}
+
+func (cpu *CPU) call(targets []int, fn func()) (err error) {
+	if fn == nil {
+		return errors.New("invalid function")
+	}
+
+	callsOnce.Do(func() {
+		calls = make([]call, NumCPU())
+		setIDT(IRQ_CALL, IRQ_CALL)
+	})
+
+	for _, i := range targets {
+		if i < 0 || i >= cpu.NumCPU() || i >= len(calls) {
+			return errors.New("invalid processor index")
+		}
+	}
+
+	callsLock.Lock()
+	defer callsLock.Unlock()
+
+	// prevent migration of the calling goroutine
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	self := CurrentCPU()
+	local := false
+
+	for _, i := range targets {
+		if i == self {
+			continue
+		}
+
+		// a previously timed out call might still be running
+		switch calls[i].state.Load() {
+		case callPending, callRunning:
+			return errors.New("processor busy")
+		}
+	}
+
+	for _, i := range targets {
+		if i == self {
+			local = true
+			continue
+		}
+
+		// the mailbox is published only once the function is set
+		calls[i].fn = fn
+		calls[i].state.Store(callPending)
+
+		cpu.LAPIC.IPI(i, IRQ_CALL, lapic.ICR_DLV_IRQ)
+	}
+
+	if local {
+		fn()
+	}
+
+	start := time.Now()
+
+	for _, i := range targets {
+		if i == self {
+			continue
+		}
+
+		for !calls[i].state.CompareAndSwap(callDone, callIdle) {
+			if time.Since(start) >= CallTimeout {
+				cancel(targets, self)
+				return errors.New("cross-processor call timeout")
+			}
+		}
+	}
+
+	return
+}
+
+// CallOn executes the argument function on the indexed processor (see
+// [CurrentCPU]), returning only after its completion has been acknowledged.
+//
+// The function is executed in interrupt context, raised with an [IRQ_CALL]
+// Inter-Processor Interrupt, on the system stack of the target processor,
+// therefore it must be limited to short, non-blocking, operations (e.g. TLB or
+// cache maintenance) which do not allocate memory.
+//
+// On timeout a call which has not yet started is withdrawn, while a call
+// already running completes in the background and further calls to the same
+// processor fail until then.
+//
+// Application Processors can only execute calls after being assigned to the
+// Go scheduler (see [CPU.Task]) as they are otherwise halted with interrupts
+// disabled, such calls fail after [CallTimeout].
+func (cpu *CPU) CallOn(index int, fn func()) error {
+	return cpu.call([]int{index}, fn)
+}
+
+// Broadcast executes the argument function on all initialized processors,
+// including the calling one, returning only after all completions have been
+// acknowledged (see [CPU.CallOn]).
+func (cpu *CPU) Broadcast(fn func()) error {
+	targets := make([]int, cpu.NumCPU())
+
+	for i := range targets {
+		targets[i] = i
+	}
+
+	return cpu.call(targets, fn)
+}
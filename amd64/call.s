@@ -0,0 +1,125 @@
+// x86-64 processor support
+// https://github.com/usbarmory/tamago
+//
+// Copyright (c) The TamaGo Authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+#include "amd64.h"
+#include "go_asm.h"
+#include "textflag.h"
+
+TEXT ·handleCall(SB),NOSPLIT|NOFRAME,$0
+	// IRQ_CALL is generated by CPU.CallOn and CPU.Broadcast
+
+	// save caller registers, all of them can be clobbered by ·runCall
+	PUSHQ	AX
+	PUSHQ	BX
+	PUSHQ	CX
+	PUSHQ	DX
+	PUSHQ	SI
+	PUSHQ	DI
+	PUSHQ	BP
+	PUSHQ	R8
+	PUSHQ	R9
+	PUSHQ	R10
+	PUSHQ	R11
+	PUSHQ	R12
+	PUSHQ	R13
+	PUSHQ	R14
+	PUSHQ	R15
+
+	SUBQ	$256, SP
+	MOVUPS	X0, 0(SP)
+	MOVUPS	X1, 16(SP)
+	MOVUPS	X2, 32(SP)
+	MOVUPS	X3, 48(SP)
+	MOVUPS	X4, 64(SP)
+	MOVUPS	X5, 80(SP)
+	MOVUPS	X6, 96(SP)
+	MOVUPS	X7, 112(SP)
+	MOVUPS	X8, 128(SP)
+	MOVUPS	X9, 144(SP)
+	MOVUPS	X10, 160(SP)
+	MOVUPS	X11, 176(SP)
+	MOVUPS	X12, 192(SP)
+	MOVUPS	X13, 208(SP)
+	MOVUPS	X14, 224(SP)
+	MOVUPS	X15, 240(SP)
+
+	// The call is executed on the system stack of the interrupted M
+	// (g0), as the interrupted goroutine stack might not accommodate it.
+	MOVQ	SP, BX
+	MOVQ	TLS, CX
+	MOVQ	0(CX)(TLS*1), AX
+
+	CMPQ	AX, $0
+	JE	run
+	MOVQ	g_m(AX), DX
+	CMPQ	DX, $0
+	JE	run
+	MOVQ	m_g0(DX), DX
+	CMPQ	AX, DX
+	JE	run
+
+	// switch to g0
+	MOVQ	DX, 0(CX)(TLS*1)
+	MOVQ	g_sched_sp(DX), SP
+
+run:
+	// save interrupted stack pointer and goroutine
+	PUSHQ	BX
+	PUSHQ	AX
+
+	CALL	·runCall(SB)
+
+	// restore interrupted stack pointer and goroutine
+	POPQ	AX
+	POPQ	BX
+	MOVQ	TLS, CX
+	MOVQ	AX, 0(CX)(TLS*1)
+	MOVQ	BX, SP
+
+	// restore caller registers
+	MOVUPS	0(SP), X0
+	MOVUPS	16(SP), X1
+	MOVUPS	32(SP), X2
+	MOVUPS	48(SP), X3
+	MOVUPS	64(SP), X4
+	MOVUPS	80(SP), X5
+	MOVUPS	96(SP), X6
+	MOVUPS	112(SP), X7
+	MOVUPS	128(SP), X8
+	MOVUPS	144(SP), X9
+	MOVUPS	160(SP), X10
+	MOVUPS	176(SP), X11
+	MOVUPS	192(SP), X12
+	MOVUPS	208(SP), X13
+	MOVUPS	224(SP), X14
+	MOVUPS	240(SP), X15
+	ADDQ	$256, SP
+
+	// clear interrupt
+	MOVL	$(const_LAPIC_EOI), AX
+	MOVL	$0, (AX)
+
+	POPQ	R15
+	POPQ	R14
+	POPQ	R13
+	POPQ	R12
+	POPQ	R11
+	POPQ	R10
+	POPQ	R9
+	POPQ	R8
+	POPQ	BP
+	POPQ	DI
+	POPQ	SI
+	POPQ	DX
+	POPQ	CX
+	POPQ	BX
+	POPQ	AX
+
+	// return to caller
+	ADDQ	$8, SP
+	IRETQ
@@ -0,0 +1,273 @@
+// x86-64 processor support
+// https://github.com/karlo195/tamago
+//
+// Copyright (c) The TamaGo Authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+package amd64
+
+import (
+	"errors"
+)
+
+// Debug registers
+// (AMD64 Architecture Programmer’s Manual
+// Volume 2 - 13.1.1 Debug Registers).
+const (
+	DR6          = 6
+	DR6_B0       = 0
+	DR6_BD       = 13
+	DR6_BS       = 14
+	DR6_BT       = 15
+	DR6_RESERVED = 0xffff0ff0
+
+	DR7          = 7
+	DR7_L0       = 0
+	DR7_LE       = 8
+	DR7_RW0      = 16
+	DR7_LEN0     = 18
+	DR7_RESERVED = 1 << 10
+)
+
+// Breakpoint conditions (DR7 R/W fields)
+const (
+	BreakExecute   = 0b00
+	BreakWrite     = 0b01
+	BreakReadWrite = 0b11
+)
+
+// RFLAGS bits
+const (
+	RFLAGS_TF   = 8
+	RFLAGS_IF   = 9
+	RFLAGS_DF   = 10
+	RFLAGS_IOPL = 12
+	RFLAGS_RF   = 16
+	RFLAGS_AC   = 18
+)
+
+// NumBreakpoints is the number of hardware breakpoints (DR0-DR3).
+const NumBreakpoints = 4
+
+// DebugHandler represents a debug exception (#DB) handler, invoked with the
+// debug status (DR6) on breakpoint hit or single-step completion, it returns
+// whether execution must resume (see [ExceptionHandler]).
+type DebugHandler func(ctx *ExceptionContext, status uint64) (resume bool)
+
+var debugHandler DebugHandler
+
+// defined in debug.s
+func read_dr(n int) (val uint64)
+func write_dr(n int, val uint64)
+
+func breakpointLength(length int) (val uint64, err error) {
+	switch length {
+	case 1:
+		return 0b00, nil
+	case 2:
+		return 0b01, nil
+	case 4:
+		return 0b11, nil
+	case 8:
+		return 0b10, nil
+	}
+
+	return 0, errors.New("invalid breakpoint length")
+}
+
+// SetBreakpoint configures the indexed hardware breakpoint (0-3) on the
+// argument linear address, condition (e.g. [BreakExecute]) and length
+// (1, 2, 4 or 8 bytes, 1 for execution breakpoints).
+//
+// Debug registers are specific to each core, therefore the breakpoint only
+// applies to the processor executing this function (see [CPU.Broadcast]).
+func (cpu *CPU) SetBreakpoint(index int, addr uint64, cond int, length int) (err error) {
+	if index < 0 || index >= NumBreakpoints {
+		return errors.New("invalid breakpoint index")
+	}
+
+	if cond != BreakExecute && cond != BreakWrite && cond != BreakReadWrite {
+		return errors.New("invalid breakpoint condition")
+	}
+
+	if cond == BreakExecute {
+		length = 1
+	}
+
+	n, err := breakpointLength(length)
+
+	if err != nil {
+		return
+	}
+
+	if addr%uint64(length) != 0 {
+		return errors.New("unaligned breakpoint address")
+	}
+
+	dr7 := read_dr(DR7)
+	dr7 &^= 0b1111 << (DR7_RW0 + index*4)
+	dr7 |= (uint64(cond) | n<<2) << (DR7_RW0 + index*4)
+	dr7 |= 1<<(DR7_L0+index*2) | 1<<DR7_LE | DR7_RESERVED
+
+	write_dr(index, addr)
+	write_dr(DR7, dr7)
+
+	return
+}
+
+// ClearBreakpoint disables the indexed hardware breakpoint (0-3) on the
+// processor executing this function.
+func (cpu *CPU) ClearBreakpoint(index int) {
+	if index < 0 || index >= NumBreakpoints {
+		return
+	}
+
+	dr7 := read_dr(DR7)
+	dr7 &^= 1 << (DR7_L0 + index*2)
+
+	write_dr(DR7, dr7)
+}
+
+// Breakpoint returns the configuration of the indexed hardware breakpoint
+// (0-3) on the processor executing this function.
+func (cpu *CPU) Breakpoint(index int) (addr uint64, cond int, length int, enabled bool) {
+	if index < 0 || index >= NumBreakpoints {
+		return
+	}
+
+	dr7 := read_dr(DR7)
+	ctrl := dr7 >> (DR7_RW0 + index*4)
+
+	addr = read_dr(index)
+	cond = int(ctrl & 0b11)
+	enabled = dr7&(1<<(DR7_L0+index*2)) != 0
+
+	switch ctrl >> 2 & 0b11 {
+	case 0b00:
+		length = 1
+	case 0b01:
+		length = 2
+	case 0b11:
+		length = 4
+	case 0b10:
+		length = 8
+	}
+
+	return
+}
+
+// Watch configures free hardware breakpoints, on the processor executing this
+// function, to trap accesses matching the argument condition (BreakWrite or
+// BreakReadWrite) within the argument memory range, which is covered with
+// naturally aligned watchpoints of up to 8 bytes.
+//
+// The function returns the indices of the configured breakpoints, on error
+// (e.g. when the range requires more watchpoints than available) no
+// breakpoint is left configured.
+func (cpu *CPU) Watch(addr uint64, size int, cond int) (indices []int, err error) {
+	if cond != BreakWrite && cond != BreakReadWrite {
+		return nil, errors.New("invalid watchpoint condition")
+	}
+
+	if size <= 0 {
+		return nil, errors.New("invalid watchpoint size")
+	}
+
+	var free []int
+
+	for i := range NumBreakpoints {
+		if _, _, _, enabled := cpu.Breakpoint(i); !enabled {
+			free = append(free, i)
+		}
+	}
+
+	end := addr + uint64(size)
+
+	for addr < end {
+		length := 8
+
+		// largest naturally aligned length within range
+		for addr%uint64(length) != 0 || addr+uint64(length) > end {
+			length /= 2
+		}
+
+		if len(indices) == len(free) {
+			err = errors.New("insufficient hardware breakpoints")
+		} else {
+			err = cpu.SetBreakpoint(free[len(indices)], addr, cond, length)
+		}
+
+		if err != nil {
+			// release breakpoints configured so far
+			for _, i := range indices {
+				cpu.ClearBreakpoint(i)
+			}
+
+			return nil, err
+		}
+
+		indices = append(indices, free[len(indices)])
+		addr += uint64(length)
+	}
+
+	return
+}
+
+// BreakpointHit returns whether the argument debug status (DR6), as passed to
+// a [DebugHandler], reports a condition match for the indexed hardware
+// breakpoint (0-3).
+func BreakpointHit(status uint64, index int) bool {
+	if index < 0 || index >= NumBreakpoints {
+		return false
+	}
+
+	return status&(1<<(DR6_B0+index)) != 0
+}
+
+// SetDebugHandler registers a function to handle debug exceptions (#DB),
+// raised on hardware breakpoint hits and single-step completion (see
+// [ExceptionContext.SingleStep]), a nil function restores handling through
+// [DefaultExceptionHandler].
+//
+// The handler is invoked in exception context, with the same constraints of
+// [CPU.SetExceptionHandler]. On resume the Resume Flag (RF) is set to avoid
+// retriggering instruction breakpoints.
+func (cpu *CPU) SetDebugHandler(fn DebugHandler) (err error) {
+	debugHandler = fn
+
+	if fn == nil {
+		return cpu.SetExceptionHandler(Debug, nil)
+	}
+
+	return cpu.SetExceptionHandler(Debug, handleDebug)
+}
+
+func handleDebug(ctx *ExceptionContext) (resume bool) {
+	status := read_dr(DR6)
+
+	// debug status bits are sticky, clear them for the next exception
+	write_dr(DR6, DR6_RESERVED)
+
+	if debugHandler == nil || !debugHandler(ctx, status) {
+		return false
+	}
+
+	if status&(1<<NumBreakpoints-1) != 0 {
+		ctx.RFLAGS |= 1 << RFLAGS_RF
+	}
+
+	return true
+}
+
+// SingleStep enables, or disables, single-stepping through the Trap Flag
+// (TF) when resuming execution from the exception context, a debug exception
+// (with DR6.BS set) is raised after each instruction.
+func (ctx *ExceptionContext) SingleStep(on bool) {
+	if on {
+		ctx.RFLAGS |= 1 << RFLAGS_TF
+	} else {
+		ctx.RFLAGS &^= 1 << RFLAGS_TF
+	}
+}
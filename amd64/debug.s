@@ -0,0 +1,103 @@
+// x86-64 processor support
+// https://github.com/usbarmory/tamago
+//
+// Copyright (c) The TamaGo Authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+#include "textflag.h"
+
+// Only DR0, DR6 and DR7 moves are supported by the Go assembler, the
+// remaining ones are encoded explicitly.
+
+// func read_dr(n int) (val uint64)
+TEXT ·read_dr(SB),NOSPLIT,$0-16
+	MOVQ	n+0(FP), CX
+	XORQ	AX, AX
+
+	CMPQ	CX, $0
+	JNE	dr1
+	MOVQ	DR0, AX
+	JMP	done
+dr1:
+	CMPQ	CX, $1
+	JNE	dr2
+	// MOVQ DR1, AX
+	BYTE	$0x0f
+	BYTE	$0x21
+	BYTE	$0xc8
+	JMP	done
+dr2:
+	CMPQ	CX, $2
+	JNE	dr3
+	// MOVQ DR2, AX
+	BYTE	$0x0f
+	BYTE	$0x21
+	BYTE	$0xd0
+	JMP	done
+dr3:
+	CMPQ	CX, $3
+	JNE	dr6
+	// MOVQ DR3, AX
+	BYTE	$0x0f
+	BYTE	$0x21
+	BYTE	$0xd8
+	JMP	done
+dr6:
+	CMPQ	CX, $6
+	JNE	dr7
+	MOVQ	DR6, AX
+	JMP	done
+dr7:
+	CMPQ	CX, $7
+	JNE	done
+	MOVQ	DR7, AX
+done:
+	MOVQ	AX, val+8(FP)
+	RET
+
+// func write_dr(n int, val uint64)
+TEXT ·write_dr(SB),NOSPLIT,$0-16
+	MOVQ	n+0(FP), CX
+	MOVQ	val+8(FP), AX
+
+	CMPQ	CX, $0
+	JNE	dr1
+	MOVQ	AX, DR0
+	RET
+dr1:
+	CMPQ	CX, $1
+	JNE	dr2
+	// MOVQ AX, DR1
+	BYTE	$0x0f
+	BYTE	$0x23
+	BYTE	$0xc8
+	RET
+dr2:
+	CMPQ	CX, $2
+	JNE	dr3
+	// MOVQ AX, DR2
+	BYTE	$0x0f
+	BYTE	$0x23
+	BYTE	$0xd0
+	RET
+dr3:
+	CMPQ	CX, $3
+	JNE	dr6
+	// MOVQ AX, DR3
+	BYTE	$0x0f
+	BYTE	$0x23
+	BYTE	$0xd8
+	RET
+dr6:
+	CMPQ	CX, $6
+	JNE	dr7
+	MOVQ	AX, DR6
+	RET
+dr7:
+	CMPQ	CX, $7
+	JNE	done
+	MOVQ	AX, DR7
+done:
+	RET
@@ -0,0 +1,108 @@
+// x86-64 processor support
+// https://github.com/karlo195/tamago
+//
+// Copyright (c) The TamaGo Authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+package amd64
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+)
+
+// Implementation represents a function implementation variant.
+type Implementation[T any] struct {
+	// Name is the implementation name (e.g. "avx2", "generic").
+	Name string
+	// Supported returns whether the implementation can be used with the
+	// argument processor capabilities, nil indicates a portable
+	// implementation.
+	Supported func(f *Features) bool
+	// Fn is the implementation function.
+	Fn T
+}
+
+// Dispatcher represents a function with alternative implementations (e.g.
+// portable and assembly accelerated ones), selected on processor
+// capabilities.
+type Dispatcher[T any] struct {
+	sync.Mutex
+
+	impls    []Implementation[T]
+	selected atomic.Pointer[Implementation[T]]
+}
+
+// NewDispatcher returns a function dispatcher for the argument
+// implementations, listed in order of preference, selecting the first one
+// supported by the processor (see [CPU.Features]).
+//
+// The detection of processor capabilities does not depend on [CPU.Init],
+// therefore dispatchers can be declared as package variables. A portable
+// implementation should always be listed last, otherwise the function panics
+// when none of the implementations is supported.
+func NewDispatcher[T any](impls ...Implementation[T]) *Dispatcher[T] {
+	d := &Dispatcher[T]{
+		impls: impls,
+	}
+
+	d.Select("")
+
+	return d
+}
+
+// Fn returns the selected implementation function.
+func (d *Dispatcher[T]) Fn() T {
+	return d.selected.Load().Fn
+}
+
+// Name returns the selected implementation name.
+func (d *Dispatcher[T]) Name() string {
+	return d.selected.Load().Name
+}
+
+// Implementations returns the names of all implementations, supported or
+// not, in order of preference.
+func (d *Dispatcher[T]) Implementations() (names []string) {
+	for _, impl := range d.impls {
+		names = append(names, impl.Name)
+	}
+
+	return
+}
+
+// Select overrides automatic selection with the named implementation, to
+// allow testing of all supported variants, an empty name restores automatic
+// selection.
+func (d *Dispatcher[T]) Select(name string) (err error) {
+	d.Lock()
+	defer d.Unlock()
+
+	f := processorFeatures()
+
+	for i, impl := range d.impls {
+		if name != "" && impl.Name != name {
+			continue
+		}
+
+		if impl.Supported != nil && !impl.Supported(f) {
+			if name != "" {
+				return errors.New("implementation not supported")
+			}
+
+			continue
+		}
+
+		d.selected.Store(&d.impls[i])
+		return
+	}
+
+	if name != "" {
+		return errors.New("implementation not found")
+	}
+
+	panic("no supported implementation")
+}
@@ -8,11 +8,125 @@
 
 package amd64
 
+import (
+	"errors"
+	"runtime"
+	"strconv"
+	"unsafe"
+)
+
+// Exception vectors
+// (AMD64 Architecture Programmer’s Manual
+// Volume 2 - 8.2 Vectors).
+const (
+	DivideError         = 0
+	Debug               = 1
+	NMI                 = 2
+	Breakpoint          = 3
+	Overflow            = 4
+	BoundRange          = 5
+	InvalidOpcode       = 6
+	DeviceNotAvailable  = 7
+	DoubleFault         = 8
+	InvalidTSS          = 10
+	SegmentNotPresent   = 11
+	StackFault          = 12
+	GeneralProtection   = 13
+	PageFault           = 14
+	FloatingPoint       = 16
+	AlignmentCheck      = 17
+	MachineCheck        = 18
+	SIMDFloatingPoint   = 19
+	Virtualization      = 20
+	ControlProtection   = 21
+	HypervisorInjection = 28
+	VMMCommunication    = 29
+	Security            = 30
+)
+
+// exception vectors for which the processor pushes an error code
+const errorCodeVectors = 1<<DoubleFault | 1<<InvalidTSS | 1<<SegmentNotPresent |
+	1<<StackFault | 1<<GeneralProtection | 1<<PageFault | 1<<AlignmentCheck |
+	1<<ControlProtection | 1<<VMMCommunication | 1<<Security
+
+// maximum number of frames printed by DefaultExceptionHandler
+const maxStackFrames = 32
+
+// ExceptionFrame represents the processor state captured at exception time.
+type ExceptionFrame struct {
+	// General purpose registers
+	AX  uint64
+	BX  uint64
+	CX  uint64
+	DX  uint64
+	SI  uint64
+	DI  uint64
+	BP  uint64
+	R8  uint64
+	R9  uint64
+	R10 uint64
+	R11 uint64
+	R12 uint64
+	R13 uint64
+	R14 uint64
+	R15 uint64
+
+	// Interrupt stack frame
+	// (AMD64 Architecture Programmer’s Manual
+	// Volume 2 - 8.9.3 Interrupt Stack Frame).
+	ErrorCode uint64
+	RIP       uint64
+	CS        uint64
+	RFLAGS    uint64
+	RSP       uint64
+	SS        uint64
+
+	// Page fault linear address
+	CR2 uint64
+
+	// Vector number
+	Vector int
+}
+
+// ExceptionContext represents the processor state passed to exception
+// handlers (see [CPU.SetExceptionHandler]).
+type ExceptionContext struct {
+	// ExceptionFrame is the processor state captured at exception time,
+	// changes to its registers are applied when resuming execution.
+	*ExceptionFrame
+
+	// CPU is the index of the processor which raised the exception (see
+	// [CurrentCPU]).
+	CPU int
+}
+
+// ExceptionHandler represents an exception handler, it returns whether
+// execution must resume from the (optionally modified) exception context,
+// otherwise the exception is handled by [DefaultExceptionHandler].
+type ExceptionHandler func(ctx *ExceptionContext) (resume bool)
+
 var (
 	currentVector uintptr
 	isThrowing    bool
+
+	// set in exception.s
+	exceptionFrame ExceptionFrame
+	exceptionStack [6]uint64
+
+	// user exception handlers
+	exceptionHandlers [32]ExceptionHandler
+	exceptionContext  ExceptionContext
+
+	// read in exception.s
+	exceptionResume     bool
+	exceptionUserResume bool
+	exceptionSkip       uint64
+	udISR               uint64
 )
 
+// defined in exception.s
+func handleInvalidOpcodeAddr() uintptr
+
 func currentVectorNumber() (id int) {
 	id = int(currentVector - irqHandlerAddr)
 
@@ -23,23 +137,303 @@ func currentVectorNumber() (id int) {
 	return
 }
 
-// DefaultExceptionHandler handles an exception by printing its vector and
-// processor mode before panicking.
+// hasErrorCode returns whether the processor pushes an error code on the
+// stack for the argument exception vector.
+func hasErrorCode(vector int) bool {
+	return vector >= 0 && vector < 32 && errorCodeVectors&(1<<vector) != 0
+}
+
+// VectorName returns the exception vector mnemonic.
+func VectorName(vector int) string {
+	switch vector {
+	case DivideError:
+		return "#DE"
+	case Debug:
+		return "#DB"
+	case NMI:
+		return "NMI"
+	case Breakpoint:
+		return "#BP"
+	case Overflow:
+		return "#OF"
+	case BoundRange:
+		return "#BR"
+	case InvalidOpcode:
+		return "#UD"
+	case DeviceNotAvailable:
+		return "#NM"
+	case DoubleFault:
+		return "#DF"
+	case InvalidTSS:
+		return "#TS"
+	case SegmentNotPresent:
+		return "#NP"
+	case StackFault:
+		return "#SS"
+	case GeneralProtection:
+		return "#GP"
+	case PageFault:
+		return "#PF"
+	case FloatingPoint:
+		return "#MF"
+	case AlignmentCheck:
+		return "#AC"
+	case MachineCheck:
+		return "#MC"
+	case SIMDFloatingPoint:
+		return "#XF"
+	case Virtualization:
+		return "#VE"
+	case ControlProtection:
+		return "#CP"
+	case HypervisorInjection:
+		return "#HV"
+	case VMMCommunication:
+		return "#VC"
+	case Security:
+		return "#SX"
+	}
+
+	return "Unknown"
+}
+
+func hex(val uint64) string {
+	return "0x" + strconv.FormatUint(val, 16)
+}
+
+// decode fills the interrupt stack frame fields from the raw stack contents
+// saved at exception time.
+func (f *ExceptionFrame) decode(vector int, stack []uint64) {
+	f.Vector = vector
+
+	if hasErrorCode(vector) {
+		f.ErrorCode = stack[0]
+		stack = stack[1:]
+	} else {
+		f.ErrorCode = 0
+	}
+
+	f.RIP = stack[0]
+	f.CS = stack[1]
+	f.RFLAGS = stack[2]
+	f.RSP = stack[3]
+	f.SS = stack[4]
+}
+
+// encode updates the raw stack contents, restored on exception return, from
+// the interrupt stack frame fields.
+func (f *ExceptionFrame) encode(stack []uint64) {
+	if hasErrorCode(f.Vector) {
+		stack = stack[1:]
+	}
+
+	stack[0] = f.RIP
+	stack[1] = f.CS
+	stack[2] = f.RFLAGS
+	stack[3] = f.RSP
+	stack[4] = f.SS
+}
+
+// Print prints the exception vector, the processor registers and a
+// symbolized stack trace of the interrupted code.
+func (f *ExceptionFrame) Print() {
+	print("exception: vector ", f.Vector, " (", VectorName(f.Vector), ") error ", hex(f.ErrorCode), "\n")
+
+	if f.Vector == PageFault {
+		print("cr2    ", hex(f.CR2), "\n")
+	}
+
+	print("rip    ", hex(f.RIP), "\n")
+	print("rflags ", hex(f.RFLAGS), "\n")
+	print("cs     ", hex(f.CS), "\n")
+	print("ss     ", hex(f.SS), "\n")
+	print("rsp    ", hex(f.RSP), "\n")
+	print("rbp    ", hex(f.BP), "\n")
+	print("rax    ", hex(f.AX), "\n")
+	print("rbx    ", hex(f.BX), "\n")
+	print("rcx    ", hex(f.CX), "\n")
+	print("rdx    ", hex(f.DX), "\n")
+	print("rsi    ", hex(f.SI), "\n")
+	print("rdi    ", hex(f.DI), "\n")
+	print("r8     ", hex(f.R8), "\n")
+	print("r9     ", hex(f.R9), "\n")
+	print("r10    ", hex(f.R10), "\n")
+	print("r11    ", hex(f.R11), "\n")
+	print("r12    ", hex(f.R12), "\n")
+	print("r13    ", hex(f.R13), "\n")
+	print("r14    ", hex(f.R14), "\n")
+	print("r15    ", hex(f.R15), "\n")
+
+	print("\n")
+	f.printStack()
+}
+
+func printFrame(pc uint64) {
+	fn := runtime.FuncForPC(uintptr(pc))
+
+	if fn == nil {
+		print("?()\n\t? pc=", hex(pc), "\n")
+		return
+	}
+
+	file, line := fn.FileLine(uintptr(pc))
+	print(fn.Name(), "()\n\t", file, ":", line, " pc=", hex(pc), "\n")
+}
+
+// printStack walks the frame pointer chain of the interrupted code.
+func (f *ExceptionFrame) printStack() {
+	ramStart, ramEnd := runtime.MemRegion()
+
+	printFrame(f.RIP)
+
+	bp := f.BP
+
+	for i := 0; i < maxStackFrames; i++ {
+		if bp == 0 || bp%8 != 0 || bp < uint64(ramStart) || bp+16 > uint64(ramEnd) {
+			break
+		}
+
+		// the saved frame pointer is followed by the return address
+		next := *(*uint64)(unsafe.Pointer(uintptr(bp)))
+		pc := *(*uint64)(unsafe.Pointer(uintptr(bp + 8)))
+
+		if pc == 0 {
+			break
+		}
+
+		// return addresses point to the instruction after the call
+		printFrame(pc - 1)
+
+		if next <= bp {
+			break
+		}
+
+		bp = next
+	}
+}
+
+// dispatchException invokes the user exception handler, if any, for the
+// current vector, it is called by ·handleException which resumes execution
+// when exceptionResume is set.
+func dispatchException() {
+	exceptionResume = false
+	exceptionUserResume = false
+
+	vector := currentVectorNumber()
+
+	if isThrowing || vector < 0 || vector >= len(exceptionHandlers) {
+		return
+	}
+
+	exceptionFrame.decode(vector, exceptionStack[:])
+	user := exceptionFrame.CS&3 == 3
+
+	if userContext != nil && user {
+		// terminate user mode execution (see CPU.RunUser)
+		userException()
+	} else {
+		fn := exceptionHandlers[vector]
+
+		if fn == nil {
+			return
+		}
+
+		exceptionContext.ExceptionFrame = &exceptionFrame
+		exceptionContext.CPU = CurrentCPU()
+
+		if !fn(&exceptionContext) {
+			return
+		}
+	}
+
+	exceptionFrame.encode(exceptionStack[:])
+
+	// the user mode TLS base is restored only when returning to it
+	exceptionUserResume = user && exceptionFrame.CS&3 == 3
+
+	// discard ISR return address and error code on resume
+	exceptionSkip = 8
+
+	if hasErrorCode(vector) {
+		exceptionSkip += 8
+	}
+
+	exceptionResume = true
+}
+
+// DefaultExceptionHandler handles an exception by printing its vector,
+// processor registers and stack trace before panicking.
+//
+// As exceptions are handled on the system stack (g0), the panic is fatal.
 func DefaultExceptionHandler() {
 	if isThrowing {
 		exit(0)
 	}
 
-	// TODO: implement runtime.CallOnG0 for a cleaner approach
 	isThrowing = true
 
-	print("exception: vector ", currentVectorNumber(), " \n")
+	exceptionFrame.decode(currentVectorNumber(), exceptionStack[:])
+
+	if exceptionFrame.Vector == NMI && watchdogCounter != 0 {
+		print("NMI watchdog: scheduler hung\n")
+	}
+
+	exceptionFrame.Print()
+
+	if istVectors[exceptionFrame.Vector] != 0 {
+		// unwinding is not possible on an Interrupt Stack Table stack
+		print("unrecoverable exception\n")
+		exit(0)
+	}
+
 	panic("unhandled exception")
 }
 
 // EnableExceptions initializes handling of processor exceptions through
 // DefaultExceptionHandler().
+//
+// Exceptions are raised on dedicated Interrupt Stack Table stacks, and then
+// handled on the system stack (g0) of the interrupted goroutine M, to this
+// end the function must be invoked before [CPU.InitSMP].
 func (cpu *CPU) EnableExceptions() {
+	// dedicated exception stacks
+	cpu.initTSS()
+
 	// processor exceptions
 	setIDT(0, 31)
 }
+
+// SetExceptionHandler registers a function to handle a processor exception
+// vector (0-31, except NMI), a nil function restores handling through
+// [DefaultExceptionHandler].
+//
+// The handler is invoked in exception context, on the system stack (g0) of
+// the interrupted goroutine M, with interrupts disabled: it must not block,
+// allocate memory or rely on the Go scheduler.
+// Exception handling state is shared among all processors, therefore
+// exceptions must not be raised concurrently on multiple processors.
+//
+// The handler can resume execution, optionally modifying the context (e.g.
+// advancing RIP to emulate an unsupported instruction), panic or fall back to
+// [DefaultExceptionHandler].
+func (cpu *CPU) SetExceptionHandler(vector int, fn ExceptionHandler) (err error) {
+	if vector < 0 || vector >= len(exceptionHandlers) || vector == NMI {
+		return errors.New("invalid exception vector")
+	}
+
+	exceptionHandlers[vector] = fn
+
+	if vector == InvalidOpcode {
+		// #UD is handled as interrupt by default (see irqHandler)
+		if fn != nil {
+			udISR = uint64(irqHandlerAddr) + (InvalidOpcode+1)*callSize
+			isrOverride[InvalidOpcode] = handleInvalidOpcodeAddr()
+		} else {
+			isrOverride[InvalidOpcode] = 0
+		}
+	}
+
+	setIDT(vector, vector)
+
+	return
+}
@@ -6,20 +6,230 @@
 // Use of this source code is governed by the license
 // that can be found in the LICENSE file.
 
+#include "amd64.h"
 #include "go_asm.h"
 #include "textflag.h"
 
+// SSE registers saved at exception time
+GLOBL	·exceptionXMM<>(SB),NOPTR,$256
+
+// exception stack pointer and interrupted goroutine
+GLOBL	·exceptionSP<>(SB),NOPTR,$8
+GLOBL	·exceptionG<>(SB),NOPTR,$8
+
+// user mode TLS base at exception time
+GLOBL	·exceptionUserFS<>(SB),NOPTR,$8
+
 TEXT ·handleException(SB),NOSPLIT|NOFRAME,$0
 	CLI
 
+	// save general purpose registers
+	MOVQ	AX, ·exceptionFrame+ExceptionFrame_AX(SB)
+	MOVQ	BX, ·exceptionFrame+ExceptionFrame_BX(SB)
+	MOVQ	CX, ·exceptionFrame+ExceptionFrame_CX(SB)
+	MOVQ	DX, ·exceptionFrame+ExceptionFrame_DX(SB)
+	MOVQ	SI, ·exceptionFrame+ExceptionFrame_SI(SB)
+	MOVQ	DI, ·exceptionFrame+ExceptionFrame_DI(SB)
+	MOVQ	BP, ·exceptionFrame+ExceptionFrame_BP(SB)
+	MOVQ	R8, ·exceptionFrame+ExceptionFrame_R8(SB)
+	MOVQ	R9, ·exceptionFrame+ExceptionFrame_R9(SB)
+	MOVQ	R10, ·exceptionFrame+ExceptionFrame_R10(SB)
+	MOVQ	R11, ·exceptionFrame+ExceptionFrame_R11(SB)
+	MOVQ	R12, ·exceptionFrame+ExceptionFrame_R12(SB)
+	MOVQ	R13, ·exceptionFrame+ExceptionFrame_R13(SB)
+	MOVQ	R14, ·exceptionFrame+ExceptionFrame_R14(SB)
+	MOVQ	R15, ·exceptionFrame+ExceptionFrame_R15(SB)
+
+	// save page fault linear address
+	MOVQ	CR2, AX
+	MOVQ	AX, ·exceptionFrame+ExceptionFrame_CR2(SB)
+
+	// AMD64 Architecture Programmer’s Manual
+	// Volume 2 - 8.9.3 Interrupt Stack Frame
+
+	// save interrupt stack frame, with optional error code, which
+	// follows the ISR return address (see irqHandler)
+	MOVQ	8(SP), AX
+	MOVQ	AX, ·exceptionStack+0x00(SB)
+	MOVQ	16(SP), AX
+	MOVQ	AX, ·exceptionStack+0x08(SB)
+	MOVQ	24(SP), AX
+	MOVQ	AX, ·exceptionStack+0x10(SB)
+	MOVQ	32(SP), AX
+	MOVQ	AX, ·exceptionStack+0x18(SB)
+	MOVQ	40(SP), AX
+	MOVQ	AX, ·exceptionStack+0x20(SB)
+	MOVQ	48(SP), AX
+	MOVQ	AX, ·exceptionStack+0x28(SB)
+
 	// find ISR offset from stack linking information (see irqHandler)
 	MOVQ	isr-(0)(SP), AX
 	SUBQ	$(const_callSize), AX
 	MOVQ	AX, ·currentVector(SB)
 
-	// TODO: implement runtime.CallOnG0 for a cleaner approach
+	// save SSE registers, which might be clobbered by Go handlers
+	MOVUPS	X0, ·exceptionXMM<>+0x00(SB)
+	MOVUPS	X1, ·exceptionXMM<>+0x10(SB)
+	MOVUPS	X2, ·exceptionXMM<>+0x20(SB)
+	MOVUPS	X3, ·exceptionXMM<>+0x30(SB)
+	MOVUPS	X4, ·exceptionXMM<>+0x40(SB)
+	MOVUPS	X5, ·exceptionXMM<>+0x50(SB)
+	MOVUPS	X6, ·exceptionXMM<>+0x60(SB)
+	MOVUPS	X7, ·exceptionXMM<>+0x70(SB)
+	MOVUPS	X8, ·exceptionXMM<>+0x80(SB)
+	MOVUPS	X9, ·exceptionXMM<>+0x90(SB)
+	MOVUPS	X10, ·exceptionXMM<>+0xa0(SB)
+	MOVUPS	X11, ·exceptionXMM<>+0xb0(SB)
+	MOVUPS	X12, ·exceptionXMM<>+0xc0(SB)
+	MOVUPS	X13, ·exceptionXMM<>+0xd0(SB)
+	MOVUPS	X14, ·exceptionXMM<>+0xe0(SB)
+	MOVUPS	X15, ·exceptionXMM<>+0xf0(SB)
+
+	// interrupt stack frame offset, after the optional error code
+	MOVQ	·currentVector(SB), AX
+	SUBQ	·irqHandlerAddr(SB), AX
+	MOVQ	$(const_callSize), CX
+	XORQ	DX, DX
+	DIVQ	CX
+	MOVQ	$8, BX
+	MOVQ	$(const_errorCodeVectors), CX
+	BTQ	AX, CX
+	JCC	2(PC)
+	ADDQ	$8, BX
+
+	// restore Go TLS base on user mode exceptions (see ·run_user)
+	MOVQ	8(SP)(BX*1), AX
+	ANDQ	$3, AX
+	CMPQ	AX, $3
+	JNE	kernel
+
+	MOVL	$(const_MSR_FS_BASE), CX
+	RDMSR
+	MOVL	AX, ·exceptionUserFS<>+0(SB)
+	MOVL	DX, ·exceptionUserFS<>+4(SB)
+	MOVL	·userKernelFS+0(SB), AX
+	MOVL	·userKernelFS+4(SB), DX
+	WRMSR
+
+kernel:
+	// The exception is handled on a dedicated stack (see CPU.initTSS),
+	// Go handlers must run on the system stack of the interrupted M (g0),
+	// as the faulting goroutine stack cannot be trusted.
+	MOVQ	SP, ·exceptionSP<>(SB)
+	MOVQ	TLS, CX
+	MOVQ	0(CX)(TLS*1), AX
+	MOVQ	AX, ·exceptionG<>(SB)
+
+	CMPQ	AX, $0
+	JE	dispatch
+	MOVQ	g_m(AX), DX
+	CMPQ	DX, $0
+	JE	dispatch
+	MOVQ	m_g0(DX), DX
+	CMPQ	AX, DX
+	JE	on_g0
+
+	// switch to g0
+	MOVQ	DX, 0(CX)(TLS*1)
+	MOVQ	g_sched_sp(DX), SP
+	JMP	dispatch
+
+on_g0:
+	// continue below the interrupted g0 stack pointer
+	MOVQ	24(SP)(BX*1), SP
+	SUBQ	$128, SP
+	ANDQ	$~15, SP
+
+dispatch:
+	// user exception handlers (see CPU.SetExceptionHandler)
+	CALL	·dispatchException(SB)
+
+	CMPB	·exceptionResume(SB), $0
+	JNE	resume
+
 	CALL	·DefaultExceptionHandler(SB)
 
+resume:
+	// restore interrupted goroutine and exception stack
+	MOVQ	·exceptionG<>(SB), AX
+	MOVQ	TLS, CX
+	MOVQ	AX, 0(CX)(TLS*1)
+	MOVQ	·exceptionSP<>(SB), SP
+
+	// restore interrupt stack frame, with optional error code
+	MOVQ	·exceptionStack+0x00(SB), AX
+	MOVQ	AX, 8(SP)
+	MOVQ	·exceptionStack+0x08(SB), AX
+	MOVQ	AX, 16(SP)
+	MOVQ	·exceptionStack+0x10(SB), AX
+	MOVQ	AX, 24(SP)
+	MOVQ	·exceptionStack+0x18(SB), AX
+	MOVQ	AX, 32(SP)
+	MOVQ	·exceptionStack+0x20(SB), AX
+	MOVQ	AX, 40(SP)
+	MOVQ	·exceptionStack+0x28(SB), AX
+	MOVQ	AX, 48(SP)
+
+	// restore user mode TLS base when resuming user mode
+	CMPB	·exceptionUserResume(SB), $0
+	JE	restore
+
+	MOVL	$(const_MSR_FS_BASE), CX
+	MOVL	·exceptionUserFS<>+0(SB), AX
+	MOVL	·exceptionUserFS<>+4(SB), DX
+	WRMSR
+
+restore:
+	// restore SSE registers
+	MOVUPS	·exceptionXMM<>+0x00(SB), X0
+	MOVUPS	·exceptionXMM<>+0x10(SB), X1
+	MOVUPS	·exceptionXMM<>+0x20(SB), X2
+	MOVUPS	·exceptionXMM<>+0x30(SB), X3
+	MOVUPS	·exceptionXMM<>+0x40(SB), X4
+	MOVUPS	·exceptionXMM<>+0x50(SB), X5
+	MOVUPS	·exceptionXMM<>+0x60(SB), X6
+	MOVUPS	·exceptionXMM<>+0x70(SB), X7
+	MOVUPS	·exceptionXMM<>+0x80(SB), X8
+	MOVUPS	·exceptionXMM<>+0x90(SB), X9
+	MOVUPS	·exceptionXMM<>+0xa0(SB), X10
+	MOVUPS	·exceptionXMM<>+0xb0(SB), X11
+	MOVUPS	·exceptionXMM<>+0xc0(SB), X12
+	MOVUPS	·exceptionXMM<>+0xd0(SB), X13
+	MOVUPS	·exceptionXMM<>+0xe0(SB), X14
+	MOVUPS	·exceptionXMM<>+0xf0(SB), X15
+
+	// restore general purpose registers
+	MOVQ	·exceptionFrame+ExceptionFrame_AX(SB), AX
+	MOVQ	·exceptionFrame+ExceptionFrame_BX(SB), BX
+	MOVQ	·exceptionFrame+ExceptionFrame_CX(SB), CX
+	MOVQ	·exceptionFrame+ExceptionFrame_DX(SB), DX
+	MOVQ	·exceptionFrame+ExceptionFrame_SI(SB), SI
+	MOVQ	·exceptionFrame+ExceptionFrame_DI(SB), DI
+	MOVQ	·exceptionFrame+ExceptionFrame_BP(SB), BP
+	MOVQ	·exceptionFrame+ExceptionFrame_R8(SB), R8
+	MOVQ	·exceptionFrame+ExceptionFrame_R9(SB), R9
+	MOVQ	·exceptionFrame+ExceptionFrame_R10(SB), R10
+	MOVQ	·exceptionFrame+ExceptionFrame_R11(SB), R11
+	MOVQ	·exceptionFrame+ExceptionFrame_R12(SB), R12
+	MOVQ	·exceptionFrame+ExceptionFrame_R13(SB), R13
+	MOVQ	·exceptionFrame+ExceptionFrame_R14(SB), R14
+	MOVQ	·exceptionFrame+ExceptionFrame_R15(SB), R15
+
+	// discard ISR return address and optional error code
+	ADDQ	·exceptionSkip(SB), SP
+	IRETQ
+
+TEXT ·handleInvalidOpcode(SB),NOSPLIT|NOFRAME,$0
+	// handle as exception, with its irqHandler offset on the stack
+	PUSHQ	·udISR(SB)
+	JMP	·handleException(SB)
+
+// func handleInvalidOpcodeAddr() uintptr
+TEXT ·handleInvalidOpcodeAddr(SB),$0-8
+	MOVQ	$·handleInvalidOpcode(SB), AX
+	MOVQ	AX, ret+0(FP)
+	RET
+
 // To allow a single user-defined ISR for all vectors, a jump table of CALLs,
 // which save the vector PC on the stack, is built to use as IDT offsets.
 TEXT ·irqHandler(SB),NOSPLIT|NOFRAME,$0
@@ -32,13 +242,13 @@ TEXT ·irqHandler(SB),NOSPLIT|NOFRAME,$0
 	CALL	·handleException(SB) //  5 - Bound Range
 	CALL	·handleInterrupt(SB) //  6 - Invalid Opcode
 	CALL	·handleException(SB) //  7 - Device Not Available
-	CALL	$0 // triple fault   //  8 - Double Fault
+	CALL	·handleException(SB) //  8 - Double Fault
 	CALL	·handleException(SB) //  9 - Reserved
 	CALL	·handleException(SB) // 10 - Invalid TSS
 	CALL	·handleException(SB) // 11 - Segment Not Present
 	CALL	·handleException(SB) // 12 - Stack Fault
 	CALL	·handleException(SB) // 13 - General Protection
-	CALL	$0 // triple fault   // 14 - Page Fault
+	CALL	·handleException(SB) // 14 - Page Fault
 	CALL	·handleException(SB) // 15 - Reserved
 	CALL	·handleException(SB) // 16 - x87 Floating Point
 	CALL	·handleException(SB) // 17 - Alignment Check
@@ -280,5 +490,5 @@ TEXT ·irqHandler(SB),NOSPLIT|NOFRAME,$0
 	CALL	·handleInterrupt(SB) // ...
 	CALL	·handleInterrupt(SB) // ...
 	CALL	·handleInterrupt(SB) // ...
-	CALL	·handleInterrupt(SB) // ...
+	CALL	·handleCall(SB)      // 254 (IRQ_CALL)
 	CALL	·ignoreInterrupt(SB) // 255 (IRQ_WAKEUP)
@@ -10,6 +10,8 @@ package amd64
 
 import (
 	"runtime"
+	"strings"
+	"sync"
 
 	"github.com/karlo195/tamago/bits"
 )
@@ -24,8 +26,27 @@ const (
 	CPUID_VENDOR_ECX_INTEL = 0x6c65746e // GenuineI(ntel)
 	CPUID_VENDOR_ECX_AMD   = 0x444d4163 // Authenti(cAMD)
 
-	CPUID_INFO        = 0x01
+	CPUID_INFO = 0x01
+	// CPUID_INFO ECX bits
+	INFO_HYPERVISOR   = 31
+	INFO_RDRAND       = 30
+	INFO_AVX          = 28
+	INFO_AES          = 25
 	INFO_TSC_DEADLINE = 24
+	INFO_X2APIC       = 21
+	INFO_MONITOR      = 3
+	// CPUID_INFO EDX bits
+	INFO_PAT  = 16
+	INFO_MTRR = 12
+
+	CPUID_EXT_FEATURES = 0x07
+	// CPUID_EXT_FEATURES EBX bits
+	EXT_FEATURES_SMAP   = 20
+	EXT_FEATURES_RDSEED = 18
+	EXT_FEATURES_SMEP   = 7
+	EXT_FEATURES_AVX2   = 5
+	// CPUID_EXT_FEATURES ECX bits
+	EXT_FEATURES_UMIP = 2
 
 	CPUID_INTEL_CACHE = 0x04
 
@@ -35,6 +56,13 @@ const (
 	CPUID_TSC_CCC = 0x15
 	CPUID_CPU_FRQ = 0x16
 
+	CPUID_EXT_MAX = 0x80000000
+
+	CPUID_EXT_INFO   = 0x80000001
+	EXT_INFO_RDTSCP  = 27
+	EXT_INFO_PAGE1GB = 26
+	EXT_INFO_NX      = 20
+
 	CPUID_APM         = 0x80000007
 	APM_TSC_INVARIANT = 8
 )
@@ -76,6 +104,47 @@ const (
 // Features represents the processor capabilities detected through the CPUID
 // instruction.
 type Features struct {
+	// Vendor is the processor vendor identification string.
+	Vendor string
+	// Family is the processor family, including its extended value.
+	Family int
+	// Model is the processor model, including its extended value.
+	Model int
+	// Stepping is the processor stepping.
+	Stepping int
+
+	// AES indicates support for AES-NI instructions.
+	AES bool
+	// AVX indicates support for Advanced Vector Extensions.
+	AVX bool
+	// AVX2 indicates support for Advanced Vector Extensions 2.
+	AVX2 bool
+	// RDRAND indicates support for the RDRAND instruction.
+	RDRAND bool
+	// RDSEED indicates support for the RDSEED instruction.
+	RDSEED bool
+	// X2APIC indicates support for the x2APIC interrupt controller mode.
+	X2APIC bool
+	// MWAIT indicates support for the MONITOR/MWAIT instructions.
+	MWAIT bool
+
+	// NX indicates support for no-execute page protection.
+	NX bool
+	// Page1GB indicates support for 1GB pages.
+	Page1GB bool
+	// RDTSCP indicates support for the RDTSCP instruction.
+	RDTSCP bool
+	// PAT indicates support for the Page Attribute Table.
+	PAT bool
+	// MTRR indicates support for Memory Type Range Registers.
+	MTRR bool
+	// SMEP indicates support for Supervisor Mode Execution Prevention.
+	SMEP bool
+	// SMAP indicates support for Supervisor Mode Access Prevention.
+	SMAP bool
+	// UMIP indicates support for User Mode Instruction Prevention.
+	UMIP bool
+
 	// TSCInvariant indicates whether the Time Stamp Counter is guaranteed
 	// to be at constant rate.
 	TSCInvariant bool
@@ -83,12 +152,24 @@ type Features struct {
 	// available for the local-APIC timer to support [CPU.SetAlarm].
 	TSCDeadline bool
 
+	// Hypervisor indicates whether execution under a hypervisor is
+	// detected.
+	Hypervisor bool
+	// HypervisorVendor is the hypervisor identification string (e.g.
+	// "KVMKVMKVM").
+	HypervisorVendor string
+
 	// KVM indicates whether a Kernel-base Virtual Machine is detected.
 	KVM bool
 	// KVMClockMSR returns the kvmclock Model Specific Register.
 	KVMClockMSR uint32
 }
 
+var (
+	detectedFeatures Features
+	detectOnce       sync.Once
+)
+
 // defined in features.s
 func cpuid(eaxArg, ecxArg uint32) (eax, ebx, ecx, edx uint32)
 
@@ -97,29 +178,111 @@ func (cpu *CPU) CPUID(leaf, subleaf uint32) (eax, ebx, ecx, edx uint32) {
 	return cpuid(leaf, subleaf)
 }
 
-func (cpu *CPU) initFeatures() {
-	_, _, _, apmFeatures := cpuid(CPUID_APM, 0)
-	cpu.features.TSCInvariant = bits.IsSet(&apmFeatures, APM_TSC_INVARIANT)
+func vendor(r ...uint32) string {
+	buf := make([]byte, 0, 4*len(r))
+
+	for _, v := range r {
+		buf = append(buf, byte(v), byte(v>>8), byte(v>>16), byte(v>>24))
+	}
+
+	return strings.TrimRight(string(buf), "\x00")
+}
+
+// Intel® 64 and IA-32 Architectures Software Developer’s Manual
+// Volume 2A - CPUID—CPU Identification
+func (f *Features) initSignature(info uint32) {
+	stepping := int(bits.Get(&info, 0, 0xf))
+	model := int(bits.Get(&info, 4, 0xf))
+	family := int(bits.Get(&info, 8, 0xf))
+
+	if family == 0xf {
+		family += int(bits.Get(&info, 20, 0xff))
+	}
+
+	if family == 0x6 || family >= 0xf {
+		model += int(bits.Get(&info, 16, 0xf)) << 4
+	}
+
+	f.Family = family
+	f.Model = model
+	f.Stepping = stepping
+}
+
+func (f *Features) detect() {
+	maxLeaf, ebx, ecx, edx := cpuid(CPUID_VENDOR, 0)
+	f.Vendor = vendor(ebx, edx, ecx)
+
+	info, _, cpuFeatures, cpuFeaturesEDX := cpuid(CPUID_INFO, 0)
+	f.initSignature(info)
+
+	f.AES = bits.IsSet(&cpuFeatures, INFO_AES)
+	f.AVX = bits.IsSet(&cpuFeatures, INFO_AVX)
+	f.RDRAND = bits.IsSet(&cpuFeatures, INFO_RDRAND)
+	f.X2APIC = bits.IsSet(&cpuFeatures, INFO_X2APIC)
+	f.MWAIT = bits.IsSet(&cpuFeatures, INFO_MONITOR)
+	f.TSCDeadline = bits.IsSet(&cpuFeatures, INFO_TSC_DEADLINE)
+	f.Hypervisor = bits.IsSet(&cpuFeatures, INFO_HYPERVISOR)
+	f.PAT = bits.IsSet(&cpuFeaturesEDX, INFO_PAT)
+	f.MTRR = bits.IsSet(&cpuFeaturesEDX, INFO_MTRR)
+
+	if maxLeaf >= CPUID_EXT_FEATURES {
+		_, extFeatures, extFeaturesECX, _ := cpuid(CPUID_EXT_FEATURES, 0)
+
+		f.AVX2 = bits.IsSet(&extFeatures, EXT_FEATURES_AVX2)
+		f.RDSEED = bits.IsSet(&extFeatures, EXT_FEATURES_RDSEED)
+		f.SMEP = bits.IsSet(&extFeatures, EXT_FEATURES_SMEP)
+		f.SMAP = bits.IsSet(&extFeatures, EXT_FEATURES_SMAP)
+		f.UMIP = bits.IsSet(&extFeaturesECX, EXT_FEATURES_UMIP)
+	}
+
+	maxExtLeaf, _, _, _ := cpuid(CPUID_EXT_MAX, 0)
+
+	if maxExtLeaf >= CPUID_EXT_INFO {
+		_, _, _, extInfo := cpuid(CPUID_EXT_INFO, 0)
+
+		f.NX = bits.IsSet(&extInfo, EXT_INFO_NX)
+		f.Page1GB = bits.IsSet(&extInfo, EXT_INFO_PAGE1GB)
+		f.RDTSCP = bits.IsSet(&extInfo, EXT_INFO_RDTSCP)
+	}
+
+	if maxExtLeaf >= CPUID_APM {
+		_, _, _, apmFeatures := cpuid(CPUID_APM, 0)
+		f.TSCInvariant = bits.IsSet(&apmFeatures, APM_TSC_INVARIANT)
+	}
 
-	_, _, cpuFeatures, _ := cpuid(CPUID_INFO, 0)
-	cpu.features.TSCDeadline = bits.IsSet(&cpuFeatures, INFO_TSC_DEADLINE)
+	_, ebx, ecx, edx = cpuid(KVM_CPUID_SIGNATURE, 0)
+
+	if f.Hypervisor {
+		f.HypervisorVendor = vendor(ebx, ecx, edx)
+	}
 
-	if _, kvmk, _, _ := cpuid(KVM_CPUID_SIGNATURE, 0); kvmk != KVM_SIGNATURE {
+	if ebx != KVM_SIGNATURE {
 		return
 	}
 
-	cpu.features.KVM = true
+	f.KVM = true
 	kvmFeatures, _, _, _ := cpuid(KVM_CPUID_FEATURES, 0)
 
 	if bits.IsSet(&kvmFeatures, FEATURES_CLOCKSOURCE) {
-		cpu.features.KVMClockMSR = 0x12
+		f.KVMClockMSR = 0x12
 	}
 
 	if bits.IsSet(&kvmFeatures, FEATURES_CLOCKSOURCE2) {
-		cpu.features.KVMClockMSR = 0x4b564d01
+		f.KVMClockMSR = 0x4b564d01
 	}
 }
 
+// processorFeatures returns the processor capabilities, detected on first use
+// to allow their evaluation before [CPU.Init] (e.g. by package initializers).
+func processorFeatures() *Features {
+	detectOnce.Do(detectedFeatures.detect)
+	return &detectedFeatures
+}
+
+func (cpu *CPU) initFeatures() {
+	cpu.features = *processorFeatures()
+}
+
 // Features returns the processor capabilities.
 func (cpu *CPU) Features() Features {
 	return cpu.features
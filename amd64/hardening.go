@@ -0,0 +1,75 @@
+// x86-64 processor support
+// https://github.com/karlo195/tamago
+//
+// Copyright (c) The TamaGo Authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+package amd64
+
+// CR4 protection bits
+// (AMD64 Architecture Programmer’s Manual
+// Volume 2 - 3.1.3 CR4 Register).
+const (
+	CR4_UMIP = 11
+	CR4_SMEP = 20
+	CR4_SMAP = 21
+)
+
+// cr4Protection holds the CR4 bits applied on all processors, it is read by
+// ·apstart to apply them on APs.
+var cr4Protection uint32
+
+// defined in hardening.s
+func set_cr4(bits uint32)
+func stac()
+func clac()
+
+// initProtection enables, when supported, Supervisor Mode Execution
+// Prevention (SMEP), Supervisor Mode Access Prevention (SMAP) and User Mode
+// Instruction Prevention (UMIP).
+//
+// SMEP and SMAP prevent supervisor execution and access of pages with the
+// PTE_US attribute (see [CPU.RunUser]), UMIP prevents user mode execution of
+// descriptor table and machine status queries (SGDT, SIDT, SLDT, SMSW, STR).
+func (cpu *CPU) initProtection() {
+	var bits uint32
+
+	if cpu.features.SMEP {
+		bits |= 1 << CR4_SMEP
+	}
+
+	if cpu.features.SMAP {
+		bits |= 1 << CR4_SMAP
+	}
+
+	if cpu.features.UMIP {
+		bits |= 1 << CR4_UMIP
+	}
+
+	if bits == 0 {
+		return
+	}
+
+	set_cr4(bits)
+	cr4Protection = bits
+}
+
+// UserAccess invokes the argument function with supervisor access to user
+// mode pages temporarily allowed, as required to access user mode memory
+// (e.g. system call arguments) when SMAP is enabled.
+//
+// The function must not block or yield to the Go scheduler, as the access
+// permission (RFLAGS.AC) is not preserved across goroutine switches.
+func UserAccess(fn func()) {
+	if cr4Protection&(1<<CR4_SMAP) == 0 {
+		fn()
+		return
+	}
+
+	stac()
+	defer clac()
+
+	fn()
+}
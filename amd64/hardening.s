@@ -0,0 +1,27 @@
+// x86-64 processor support
+// https://github.com/usbarmory/tamago
+//
+// Copyright (c) The TamaGo Authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+#include "textflag.h"
+
+// func set_cr4(bits uint32)
+TEXT ·set_cr4(SB),NOSPLIT,$0-4
+	MOVL	bits+0(FP), BX
+	MOVQ	CR4, AX
+	ORQ	BX, AX
+	MOVQ	AX, CR4
+	RET
+
+// func stac()
+TEXT ·stac(SB),NOSPLIT,$0
+	STAC
+	RET
+
+// func clac()
+TEXT ·clac(SB),NOSPLIT,$0
+	CLAC
+	RET
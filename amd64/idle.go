@@ -0,0 +1,59 @@
+// x86-64 processor support
+// https://github.com/karlo195/tamago
+//
+// Copyright (c) The TamaGo Authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+package amd64
+
+import (
+	"math"
+)
+
+// MWAIT hints, EAX[7:4] selects the target C-state and EAX[3:0] its
+// sub-state (Intel® 64 and IA-32 Architectures Software Developer’s Manual
+// Volume 2B - MWAIT—Monitor Wait).
+const (
+	MWAIT_C1 = 0x00
+	MWAIT_C2 = 0x10
+	MWAIT_C3 = 0x20
+	MWAIT_C4 = 0x30
+	MWAIT_C5 = 0x40
+	MWAIT_C6 = 0x50
+)
+
+// monitored address range, interrupts are the only expected wake up event
+var idleMonitor [64]byte
+
+// defined in idle.s
+func mwait(addr *byte, hint uint32)
+
+// MWaitIdleGovernor is an alternative CPU idle time management function,
+// it can replace [CPU.DefaultIdleGovernor] as runtime.Idle.
+//
+// The processor is suspended with MONITOR/MWAIT, using the C-state hint set
+// in [CPU.MWaitHint], or HLT when MWAIT is not supported. Unlike the default
+// governor, finite pollUntil values are also honored by programming a wake up
+// timer (see [CPU.SetAlarm]) when TSC-Deadline mode is available.
+func (cpu *CPU) MWaitIdleGovernor(pollUntil int64) {
+	if pollUntil != math.MaxInt64 {
+		if !cpu.features.TSCDeadline || cpu.TimerMultiplier == 0 {
+			return
+		}
+
+		if pollUntil <= cpu.GetTime() {
+			return
+		}
+
+		cpu.SetAlarm(pollUntil)
+	}
+
+	if !cpu.features.MWAIT {
+		cpu.WaitInterrupt()
+		return
+	}
+
+	mwait(&idleMonitor[0], cpu.MWaitHint)
+}
@@ -0,0 +1,33 @@
+// x86-64 processor support
+// https://github.com/usbarmory/tamago
+//
+// Copyright (c) The TamaGo Authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+#include "textflag.h"
+
+// func mwait(addr *byte, hint uint32)
+TEXT ·mwait(SB),$0-12
+	// disable interrupts to avoid races while checking state
+	CLI
+
+	MOVB	·irqLock(SB), AX
+	CMPB	AX, $1
+	JE	done
+
+	// arm address monitoring
+	MOVQ	addr+0(FP), AX
+	MOVL	$0, CX
+	MOVL	$0, DX
+	MONITOR
+
+	// wait for interrupt, STI shadow covers MWAIT
+	MOVL	hint+8(FP), AX
+	MOVL	$0, CX
+	STI
+	MWAIT
+done:
+	STI
+	RET
@@ -10,10 +10,16 @@ package amd64
 
 import (
 	_ "unsafe"
+
+	"github.com/karlo195/tamago/boottime"
 )
 
 // Init takes care of the lower level initialization triggered before runtime
 // setup (pre World start).
 //
 //go:linkname Init runtime.hwinit0
-func Init() {}
+func Init() {
+	// boot time profiling, before TSC calibration
+	boottime.Counter = read_tsc
+	boottime.Mark("hwinit0")
+}
@@ -26,6 +26,9 @@ TEXT cpuinit(SB),NOSPLIT|NOFRAME,$0
 	// disable interrupts
 	CLI
 
+	// preserve Multiboot2 boot magic (see ·bootmem)
+	MOVL	AX, DX
+
 	// we might not have a valid stack pointer for CALLs
 	MOVL	$PML4T, SP
 
@@ -44,13 +47,13 @@ TEXT cpuinit(SB),NOSPLIT|NOFRAME,$0
 	MOVL	DI, CR3
 	REP;	STOSB
 
-	// PML4T[0] = PDPT
+	// PML4T[0] = PDPT, access restrictions are only applied on leaf entries
 	MOVL	$PML4T, DI
-	MOVL	$(PDPT | 1<<1 | 1<<0), (DI)			// set R/W, P
+	MOVL	$(PDPT | 1<<2 | 1<<1 | 1<<0), (DI)		// set U/S, R/W, P
 
 	// PDPT[0] = PDT
 	MOVL	$PDPT, DI
-	MOVL	$(PDT | 1<<1 | 1<<0), (DI)			// set R/W, P
+	MOVL	$(PDT | 1<<2 | 1<<1 | 1<<0), (DI)		// set U/S, R/W, P
 
 	// PDPT[1]: 0x40000000 - 0x7fffffff (1GB) cacheable physical page (1GB PDPE)
 	ADDL	$8, DI
@@ -79,6 +82,8 @@ add_pdt_entries:
 	JMP	add_pdt_entries
 
 check_long_mode:
+	MOVL	DX, BP
+
 	MOVL	CR4, AX
 	ANDL	$(1<<7 | 1<<5), AX	// get CR4.(PGE|PAE)
 	JBE	enable_long_mode
@@ -98,18 +103,18 @@ enable_long_mode:
 	ORL	$(1<<31 | 1<<0), AX	// set CR0.(PG|PE)
 	MOVL	AX, CR0
 
-	// set Global Descriptor Table
+	// set Global Descriptor Table (preserving BX and BP, see ·bootmem)
 	CALL	·getPC<>(SB)
-	MOVL	$·gdtptr(SB), BX	// 32-bit mode: only PC offset is copied
+	MOVL	$·gdtptr(SB), DX	// 32-bit mode: only PC offset is copied
 	ADDL	$6, AX
-	ADDL	BX, AX
+	ADDL	DX, AX
 	LGDT	(AX)
 
 	// set far return target
 	CALL	·getPC<>(SB)
-	MOVL	$·start<>(SB), BX	// 32-bit mode: only PC offset is copied
+	MOVL	$·start<>(SB), DX	// 32-bit mode: only PC offset is copied
 	ADDL	$6, AX
-	ADDL	BX, AX
+	ADDL	DX, AX
 
 	// jump to target in long mode
 	PUSHQ	$0x08
@@ -127,6 +132,9 @@ TEXT ·reload_gdt<>(SB),NOSPLIT|NOFRAME,$0
 	RETFQ
 
 TEXT ·start<>(SB),NOSPLIT|NOFRAME,$0
+	// size RAM from the boot memory map
+	CALL	·bootmem(SB)
+
 	// enable SSE
 	CALL	sse_enable(SB)
 
@@ -0,0 +1,386 @@
+// x86-64 instruction emulation
+// https://github.com/karlo195/tamago
+//
+// Copyright (c) The TamaGo Authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+// Package insn implements a minimal x86-64 instruction decoder, limited to
+// common memory load and store forms, to allow emulation of trapped MMIO
+// accesses (e.g. TDX #VE or SEV-ES #VC handling) adopting the following
+// reference specifications:
+//   - AMD64 Architecture Programmer’s Manual - Volume 3 - Chapter 1 Instruction Encoding
+//
+// This package is only meant to be used with `GOOS=tamago GOARCH=amd64` as
+// supported by the TamaGo framework for bare metal Go, see
+// https://github.com/karlo195/tamago.
+package insn
+
+import (
+	"errors"
+	"unsafe"
+
+	"github.com/karlo195/tamago/amd64"
+)
+
+// MaxLength is the maximum x86-64 instruction length.
+const MaxLength = 15
+
+// Register indices, as encoded in ModRM/REX fields
+const (
+	RAX = iota
+	RCX
+	RDX
+	RBX
+	RSP
+	RBP
+	RSI
+	RDI
+	R8
+	R9
+	R10
+	R11
+	R12
+	R13
+	R14
+	R15
+)
+
+// errors are pre-allocated as decoding occurs in exception context
+var (
+	errTruncated   = errors.New("truncated instruction")
+	errUnsupported = errors.New("unsupported instruction")
+	errRegister    = errors.New("invalid register operand")
+)
+
+// Instruction represents a decoded memory load or store instruction.
+type Instruction struct {
+	// Length is the instruction length in bytes.
+	Length int
+	// Size is the memory access size in bytes.
+	Size int
+	// Write indicates a memory store, rather than a load.
+	Write bool
+
+	// Register is the register operand index, for loads it is the
+	// destination while for stores it is the source (unless Immediate is
+	// used).
+	Register int
+	// HighByte indicates legacy high byte register access (AH, CH, DH,
+	// BH).
+	HighByte bool
+	// DestSize is the destination register size for zero or sign
+	// extending loads.
+	DestSize int
+	// SignExtend indicates a sign extending load.
+	SignExtend bool
+
+	// HasImmediate indicates that the stored value is an immediate.
+	HasImmediate bool
+	// Immediate is the (sign extended) immediate value.
+	Immediate uint64
+}
+
+// Fetch returns the instruction bytes at the argument address (e.g. the
+// faulting RIP).
+func Fetch(rip uint64) []byte {
+	return unsafe.Slice((*byte)(unsafe.Pointer(uintptr(rip))), MaxLength)
+}
+
+// modrm skips the ModRM, optional SIB and displacement bytes, returning the
+// ModRM reg field and the number of bytes consumed.
+func modrm(code []byte) (reg int, n int, err error) {
+	if len(code) < 1 {
+		return 0, 0, errTruncated
+	}
+
+	m := code[0]
+	mod := m >> 6
+	rm := m & 0b111
+	reg = int(m>>3) & 0b111
+	n = 1
+
+	if mod == 0b11 {
+		// register operand, not a memory access
+		return 0, 0, errUnsupported
+	}
+
+	if rm == 0b100 {
+		if len(code) < 2 {
+			return 0, 0, errTruncated
+		}
+
+		// SIB
+		n++
+
+		if mod == 0b00 && code[1]&0b111 == 0b101 {
+			n += 4
+		}
+	}
+
+	switch {
+	case mod == 0b01:
+		n += 1
+	case mod == 0b10:
+		n += 4
+	case mod == 0b00 && rm == 0b101:
+		// RIP-relative
+		n += 4
+	}
+
+	if n > len(code) {
+		return 0, 0, errTruncated
+	}
+
+	return
+}
+
+// Decode decodes a memory load or store instruction, supported forms are MOV
+// (88, 89, 8A, 8B, C6, C7) and MOVZX/MOVSX (0F B6, 0F B7, 0F BE, 0F BF).
+func Decode(code []byte) (inst Instruction, err error) {
+	var rex byte
+	var opsize16 bool
+
+	i := 0
+
+	// legacy prefixes
+prefixes:
+	for ; i < len(code) && i < MaxLength; i++ {
+		switch code[i] {
+		case 0x66:
+			opsize16 = true
+		case 0x67:
+			// address size override, no effect on encoding length
+		case 0x26, 0x2e, 0x36, 0x3e, 0x64, 0x65:
+			// segment overrides
+		default:
+			break prefixes
+		}
+	}
+
+	// REX prefix
+	if i < len(code) && code[i]&0xf0 == 0x40 {
+		rex = code[i]
+		i++
+	}
+
+	if i >= len(code) {
+		return inst, errTruncated
+	}
+
+	rexW := rex&0b1000 != 0
+	rexR := rex&0b0100 != 0
+
+	size := 4
+
+	switch {
+	case rexW:
+		size = 8
+	case opsize16:
+		size = 2
+	}
+
+	op := code[i]
+	i++
+
+	twoByte := op == 0x0f
+
+	if twoByte {
+		if i >= len(code) {
+			return inst, errTruncated
+		}
+
+		op = code[i]
+		i++
+	}
+
+	reg, n, err := modrm(code[i:])
+
+	if err != nil {
+		return
+	}
+
+	i += n
+
+	if rexR {
+		reg += 8
+	}
+
+	inst.Register = reg
+
+	switch {
+	case !twoByte && op == 0x88:
+		inst.Write = true
+		inst.Size = 1
+	case !twoByte && op == 0x89:
+		inst.Write = true
+		inst.Size = size
+	case !twoByte && op == 0x8a:
+		inst.Size = 1
+	case !twoByte && op == 0x8b:
+		inst.Size = size
+	case !twoByte && (op == 0xc6 || op == 0xc7):
+		if reg&0b111 != 0 {
+			return inst, errUnsupported
+		}
+
+		inst.Write = true
+		inst.HasImmediate = true
+		inst.Register = 0
+
+		immSize := 1
+
+		if op == 0xc7 {
+			inst.Size = size
+			immSize = min(size, 4)
+		} else {
+			inst.Size = 1
+		}
+
+		if i+immSize > len(code) {
+			return inst, errTruncated
+		}
+
+		inst.Immediate = signExtend(readLE(code[i:], immSize), immSize)
+		i += immSize
+	case twoByte && (op == 0xb6 || op == 0xb7 || op == 0xbe || op == 0xbf):
+		inst.Size = 1
+
+		if op&1 != 0 {
+			inst.Size = 2
+		}
+
+		inst.DestSize = size
+		inst.SignExtend = op >= 0xbe
+	default:
+		return inst, errUnsupported
+	}
+
+	// legacy high byte registers are only addressable without REX
+	if inst.Size == 1 && !inst.HasImmediate && inst.DestSize == 0 && rex == 0 && reg >= 4 {
+		inst.HighByte = true
+		inst.Register = reg - 4
+	}
+
+	if i > MaxLength {
+		return inst, errTruncated
+	}
+
+	inst.Length = i
+
+	return
+}
+
+func readLE(buf []byte, size int) (val uint64) {
+	for i := size - 1; i >= 0; i-- {
+		val = val<<8 | uint64(buf[i])
+	}
+
+	return
+}
+
+func signExtend(val uint64, size int) uint64 {
+	shift := 64 - size*8
+	return uint64(int64(val<<shift) >> shift)
+}
+
+func mask(size int) uint64 {
+	if size >= 8 {
+		return ^uint64(0)
+	}
+
+	return 1<<(size*8) - 1
+}
+
+// Register returns a pointer to the indexed general purpose register within
+// an exception frame.
+func Register(f *amd64.ExceptionFrame, n int) *uint64 {
+	switch n {
+	case RAX:
+		return &f.AX
+	case RCX:
+		return &f.CX
+	case RDX:
+		return &f.DX
+	case RBX:
+		return &f.BX
+	case RSP:
+		return &f.RSP
+	case RBP:
+		return &f.BP
+	case RSI:
+		return &f.SI
+	case RDI:
+		return &f.DI
+	case R8:
+		return &f.R8
+	case R9:
+		return &f.R9
+	case R10:
+		return &f.R10
+	case R11:
+		return &f.R11
+	case R12:
+		return &f.R12
+	case R13:
+		return &f.R13
+	case R14:
+		return &f.R14
+	case R15:
+		return &f.R15
+	}
+
+	return nil
+}
+
+// Emulate performs the decoded memory access through the argument functions,
+// which implement the actual (e.g. hypervisor assisted) device access,
+// updating the exception frame registers and advancing its instruction
+// pointer.
+func (inst *Instruction) Emulate(f *amd64.ExceptionFrame, read func(size int) uint64, write func(size int, val uint64)) (err error) {
+	r := Register(f, inst.Register)
+
+	if r == nil {
+		return errRegister
+	}
+
+	shift := 0
+
+	if inst.HighByte {
+		shift = 8
+	}
+
+	switch {
+	case inst.Write && inst.HasImmediate:
+		write(inst.Size, inst.Immediate&mask(inst.Size))
+	case inst.Write:
+		write(inst.Size, *r>>shift&mask(inst.Size))
+	default:
+		val := read(inst.Size) & mask(inst.Size)
+		size := inst.Size
+
+		if inst.DestSize != 0 {
+			if inst.SignExtend {
+				val = signExtend(val, inst.Size) & mask(inst.DestSize)
+			}
+
+			size = inst.DestSize
+		}
+
+		switch size {
+		case 4:
+			// 32-bit destinations are zero extended
+			*r = val
+		case 8:
+			*r = val
+		default:
+			m := mask(size) << shift
+			*r = *r&^m | val<<shift&m
+		}
+	}
+
+	f.RIP += uint64(inst.Length)
+
+	return
+}
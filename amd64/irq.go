@@ -13,10 +13,12 @@ import (
 	"encoding/binary"
 	"math"
 	"runtime"
+	"sync"
 	"time"
 
 	"github.com/karlo195/tamago/amd64/lapic"
 	"github.com/karlo195/tamago/dma"
+	"github.com/karlo195/tamago/replay"
 )
 
 // Interrupt Gate Descriptor Attributes
@@ -36,6 +38,11 @@ const (
 	// it cannot be serviced by [CPU.ServiceInterrupt] as the IRQ is
 	// handled internally to resume halted processors.
 	IRQ_WAKEUP = 255
+
+	// IRQ_CALL represents the interrupt vector raised by [CPU.CallOn] and
+	// [CPU.Broadcast], it cannot be serviced by [CPU.ServiceInterrupts] as
+	// the IRQ is handled internally to execute cross-processor calls.
+	IRQ_CALL = 254
 )
 
 var (
@@ -47,8 +54,24 @@ var (
 	irqHandlerG uint
 	irqHandling bool
 	irqLock     bool
+
+	// registered interrupt handlers
+	handlers     [vectors]*handler
+	handlersLock sync.Mutex
+
+	// serviced interrupts tracer
+	irqTracer replay.Tracer
+
+	// ISR overrides to the irqHandler jump table
+	isrOverride [vectors]uintptr
 )
 
+// handler represents a registered interrupt handler.
+type handler struct {
+	fn      func()
+	enabled bool
+}
+
 // defined in irq.s
 func load_idt() (idt uintptr, irqHandler uintptr)
 func irq_enable()
@@ -114,7 +137,19 @@ func setIDT(start int, end int) {
 
 		// set ISR to irqHandler.abi0 + vector offset
 		off := irqHandlerAddr + uintptr(i*callSize)
+
+		if isrOverride[i] != 0 {
+			off = isrOverride[i]
+		}
+
 		desc.SetOffset(off)
+		desc.IST = istVectors[i]
+
+		if i < 32 && desc.IST == 0 && gdt != nil {
+			// never raise exceptions on a faulting stack
+			desc.IST = IST_EXCEPTION
+		}
+
 		copy(idt[i*gateSize:], desc.Bytes())
 	}
 }
@@ -147,14 +182,92 @@ func (cpu *CPU) WaitInterrupt() {
 	wfi()
 }
 
+// SetInterruptHandler registers a function to service a user defined
+// interrupt vector (32-253), a nil function removes any previously registered
+// handler.
+//
+// Registered handlers are invoked by [CPU.ServiceInterrupts], when no argument
+// function is set, only after the vector is enabled with
+// [CPU.EnableInterrupt]. The end of interrupt is automatically signaled to the
+// LAPIC after handler execution.
+func (cpu *CPU) SetInterruptHandler(vector int, fn func()) {
+	if vector < 32 || vector >= IRQ_CALL {
+		return
+	}
+
+	handlersLock.Lock()
+	defer handlersLock.Unlock()
+
+	if fn == nil {
+		handlers[vector] = nil
+		return
+	}
+
+	handlers[vector] = &handler{
+		fn: fn,
+	}
+
+	setIDT(vector, vector)
+}
+
+// EnableInterrupt enables dispatching of the argument interrupt vector to its
+// registered handler (see [CPU.SetInterruptHandler]).
+func (cpu *CPU) EnableInterrupt(vector int) {
+	cpu.setInterrupt(vector, true)
+}
+
+// DisableInterrupt disables dispatching of the argument interrupt vector to
+// its registered handler (see [CPU.SetInterruptHandler]), interrupts received
+// while disabled are acknowledged and discarded.
+func (cpu *CPU) DisableInterrupt(vector int) {
+	cpu.setInterrupt(vector, false)
+}
+
+func (cpu *CPU) setInterrupt(vector int, enabled bool) {
+	if vector < 0 || vector >= vectors {
+		return
+	}
+
+	handlersLock.Lock()
+	defer handlersLock.Unlock()
+
+	if h := handlers[vector]; h != nil {
+		h.enabled = enabled
+	}
+}
+
+// handleInterrupt dispatches an interrupt vector to its registered handler.
+func (cpu *CPU) handleInterrupt(vector int) {
+	if vector < 0 || vector >= vectors {
+		return
+	}
+
+	var fn func()
+
+	handlersLock.Lock()
+
+	if h := handlers[vector]; h != nil && h.enabled {
+		fn = h.fn
+	}
+
+	handlersLock.Unlock()
+
+	if fn != nil {
+		fn()
+	}
+}
+
 // ServiceInterrupts puts the calling goroutine in wait state, its execution is
 // resumed when a user defined interrupt is received, an argument function can
 // be set for servicing.
+//
+// When the argument function is nil, interrupts are dispatched to handlers
+// registered with [CPU.SetInterruptHandler].
 func (cpu *CPU) ServiceInterrupts(isr func(int)) {
 	irqHandlerG, _ = runtime.GetG()
 
 	if isr == nil {
-		isr = func(_ int) { return }
+		isr = cpu.handleInterrupt
 	}
 
 	// user defined interrupts
@@ -169,6 +282,19 @@ func (cpu *CPU) ServiceInterrupts(isr func(int)) {
 		// (see ·handleInterrupt in irq.s).
 		time.Sleep(math.MaxInt64)
 
-		isr(currentVectorNumber())
+		vector := currentVectorNumber()
+
+		if irqTracer != nil {
+			irqTracer.Interrupt("amd64", vector)
+		}
+
+		isr(vector)
 	}
 }
+
+// SetInterruptTracer sets a tracer (e.g. replay.Recorder) for the order of
+// interrupts serviced by [CPU.ServiceInterrupts], a nil argument disables
+// tracing. It must be invoked before interrupt servicing starts.
+func (cpu *CPU) SetInterruptTracer(t replay.Tracer) {
+	irqTracer = t
+}
@@ -111,17 +111,89 @@ TEXT ·handleNMI(SB),NOSPLIT|NOFRAME,$0
 	// NMIs are generated by:
 	//  * CPU.Task to wake up the next AP (see ·apstart)
 	//  * CPU.EnableInterrupt to unmask IRQs on the BSP
+	//  * performance counter overflow (see CPU.EnableWatchdog)
 
 	// save caller registers
 	PUSHQ	AX
+	PUSHQ	CX
+	PUSHQ	DX
+
+	CMPL	·watchdogCounter(SB), $0
+	JE	clear
+
+	// ignore NMIs received by other cores than the monitored one
+	MOVL	$(const_LAPIC_ID), AX
+	MOVL	(AX), AX
+	CMPL	AX, ·watchdogAPIC(SB)
+	JNE	clear
+
+	// ignore NMIs not originated by the LVT performance counter entry,
+	// which is masked on delivery
+	MOVL	$(const_LAPIC_LVT_PERF), AX
+	MOVL	(AX), AX
+	BTL	$(const_LVT_MASK), AX
+	JCC	clear
+
+	// counter overflow clears the MSB set by its negative reload value
+	MOVL	·watchdogCounter(SB), CX
+	RDMSR
+	SHLQ	$32, DX
+	ORQ	DX, AX
+	MOVQ	·watchdogMSB(SB), DX
+	TESTQ	DX, AX
+	JNE	clear
+
+	// reload counter
+	MOVQ	·watchdogReload(SB), AX
+	MOVQ	AX, DX
+	SHRQ	$32, DX
+	WRMSR
+
+	// clear overflow status, when required
+	MOVQ	·watchdogOverflow(SB), AX
+	CMPQ	AX, $0
+	JE	unmask
+	MOVQ	AX, DX
+	SHRQ	$32, DX
+	MOVL	$(const_MSR_PERF_GLOBAL_OVF_CTRL), CX
+	WRMSR
+unmask:
+	// the LVT performance counter entry is masked on delivery
+	MOVL	$(const_LAPIC_LVT_PERF), AX
+	MOVL	$(const_ICR_DLV_NMI), (AX)
+
+	// check scheduler heartbeat
+	MOVQ	·watchdogHeartbeat(SB), AX
+	CMPQ	AX, ·watchdogLast(SB)
+	JE	stall
+
+	MOVQ	AX, ·watchdogLast(SB)
+	MOVL	$0, ·watchdogStalls(SB)
+	JMP	done
+stall:
+	INCL	·watchdogStalls(SB)
+	MOVL	·watchdogStalls(SB), AX
+	CMPL	AX, ·watchdogThreshold(SB)
+	JB	done
 
+	// restore caller registers
+	POPQ	DX
+	POPQ	CX
+	POPQ	AX
+
+	// handle as NMI exception, with its irqHandler offset on the stack
+	PUSHQ	·watchdogISR(SB)
+	JMP	·handleException(SB)
+clear:
 	// clear interrupt
 	MOVL	$(const_LAPIC_EOI), AX
 	MOVL	$0, (AX)
-
+	MOVB	$0, ·irqLock(SB)
+done:
 	// restore caller registers
+	POPQ	DX
+	POPQ	CX
 	POPQ	AX
 
 	// return to caller
-	MOVB	$0, ·irqLock(SB)
 	IRETQ
@@ -17,6 +17,8 @@
 package lapic
 
 import (
+	"errors"
+
 	"github.com/karlo195/tamago/bits"
 	"github.com/karlo195/tamago/internal/reg"
 )
@@ -26,13 +28,15 @@ const (
 	LAPIC_ID = 0x20
 	ID       = 24
 
-	LAPIC_VER   = 0x30
-	VER_ENTRIES = 16
+	LAPIC_VER        = 0x30
+	VER_DIRECTED_EOI = 24
+	VER_ENTRIES      = 16
 
 	LAPIC_EOI = 0xb0
 
-	LAPIC_SVR  = 0xf0
-	SVR_ENABLE = 8
+	LAPIC_SVR              = 0xf0
+	SVR_SUPPRESS_EOI_BCAST = 12
+	SVR_ENABLE             = 8
 
 	LAPIC_ICRL = 0x300
 	LAPIC_ICRH = 0x310
@@ -60,12 +64,44 @@ const (
 	TIMER_MODE_ONE_SHOT     = 0b00
 	TIMER_MODE_PERIODIC     = 0b01
 	TIMER_MODE_TSC_DEADLINE = 0b10
+
+	LAPIC_TIMER_ICR = 0x380
+	LAPIC_TIMER_CCR = 0x390
+	LAPIC_TIMER_DCR = 0x3e0
+
+	LAPIC_LVT_PERF = 0x340
+	LVT_MASK       = 16
+)
+
+// Message Signalled Interrupts (MSI) address and data fields
+// (Intel® 64 and IA-32 Architectures Software Developer’s Manual
+// Volume 3A - 10.11 Message Signalled Interrupts).
+const (
+	MSI_ADDR_BASE = 0xfee00000
+	MSI_ADDR_DEST = 12
+	MSI_ADDR_RH   = 3
+	MSI_ADDR_DM   = 2
+
+	MSI_DATA_TRIGGER = 15
+	MSI_DATA_LEVEL   = 14
+	MSI_DATA_DLV     = 8
+	MSI_DATA_VECTOR  = 0
 )
 
 // LAPIC represents a Local APIC instance.
 type LAPIC struct {
 	// Base register
 	Base uint32
+
+	// TimerFrequency is the LAPIC Timer frequency in Hz, at divisor 1,
+	// required by SetPeriodic and SetOneShot.
+	TimerFrequency uint64
+	// TimerVector is the LAPIC Timer interrupt vector, required by
+	// SetPeriodic and SetOneShot, its handler must invoke HandleTimer.
+	TimerVector int
+
+	// timer callback state
+	timer timer
 }
 
 // ID returns the LAPIC identification register.
@@ -99,6 +135,22 @@ func (io *LAPIC) ClearInterrupt() {
 	reg.Write(io.Base+LAPIC_EOI, 0)
 }
 
+// SuppressEOIBroadcast controls whether end of interrupt signals, for
+// level-triggered interrupts, are broadcast to all I/O APICs. When
+// suppressed, level-triggered interrupts must be explicitly acknowledged on
+// their originating I/O APIC (directed EOI, see ioapic.IOAPIC.EOI)
+// (Intel® 64 and IA-32 Architectures Software Developer’s Manual
+// Volume 3A - 10.8.5 Signaling Interrupt Servicing Completion).
+func (io *LAPIC) SuppressEOIBroadcast(suppress bool) (err error) {
+	if suppress && reg.Get(io.Base+LAPIC_VER, VER_DIRECTED_EOI, 1) == 0 {
+		return errors.New("EOI broadcast suppression not supported")
+	}
+
+	reg.SetTo(io.Base+LAPIC_SVR, SVR_SUPPRESS_EOI_BCAST, suppress)
+
+	return
+}
+
 // IPI sends an Inter-Processor Interrupt (IPI).
 func (io *LAPIC) IPI(apicid int, id int, flags int) {
 	reg.SetN(io.Base+LAPIC_ICRH, ID, 0xff, uint32(apicid))
@@ -115,3 +167,69 @@ func (io *LAPIC) SetTimer(id int, mode int) {
 
 	reg.Write(io.Base+LAPIC_LVT_TIMER, val)
 }
+
+// SetTimerDivide configures the LAPIC Timer divide configuration register
+// with the argument divisor (1, 2, 4, 8, 16, 32, 64 or 128)
+// (Intel® 64 and IA-32 Architectures Software Developer’s Manual
+// Volume 3A - 10.5.4 APIC Timer).
+func (io *LAPIC) SetTimerDivide(div int) (err error) {
+	var val uint32
+
+	switch div {
+	case 1:
+		val = 0b1011
+	case 2:
+		val = 0b0000
+	case 4:
+		val = 0b0001
+	case 8:
+		val = 0b0010
+	case 16:
+		val = 0b0011
+	case 32:
+		val = 0b1000
+	case 64:
+		val = 0b1001
+	case 128:
+		val = 0b1010
+	default:
+		return errors.New("invalid divisor")
+	}
+
+	reg.Write(io.Base+LAPIC_TIMER_DCR, val)
+
+	return
+}
+
+// SetTimerCount sets the LAPIC Timer initial count register, starting the
+// countdown in one-shot and periodic modes, a zero value stops the timer.
+func (io *LAPIC) SetTimerCount(count uint32) {
+	reg.Write(io.Base+LAPIC_TIMER_ICR, count)
+}
+
+// TimerCount returns the LAPIC Timer current count register.
+func (io *LAPIC) TimerCount() uint32 {
+	return reg.Read(io.Base + LAPIC_TIMER_CCR)
+}
+
+// MaskTimer masks, or unmasks, the LAPIC LVT Timer interrupt.
+func (io *LAPIC) MaskTimer(mask bool) {
+	if mask {
+		reg.Set(io.Base+LAPIC_LVT_TIMER, LVT_MASK)
+	} else {
+		reg.Clear(io.Base+LAPIC_LVT_TIMER, LVT_MASK)
+	}
+}
+
+// MSIMessage returns the Message Signalled Interrupt address and data values,
+// for edge triggered physical destination mode delivery, of the argument
+// vector to the LAPIC matching the argument APIC ID.
+//
+// The delivery mode must be one of the ICR_DLV_* constants, which share their
+// encoding with the MSI data register.
+func MSIMessage(apicid int, id int, mode int) (addr uint64, data uint32) {
+	addr = MSI_ADDR_BASE | uint64(apicid&0xff)<<MSI_ADDR_DEST
+	data = uint32(mode&(0b111<<MSI_DATA_DLV)) | uint32(id&0xff)<<MSI_DATA_VECTOR
+
+	return
+}
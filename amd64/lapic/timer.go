@@ -0,0 +1,132 @@
+// Intel Advanced Programmable Interrupt Controller (APIC) driver
+// https://github.com/karlo195/tamago
+//
+// Copyright (c) The TamaGo Authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+package lapic
+
+import (
+	"errors"
+	"math"
+	"sync"
+	"time"
+)
+
+// maximum LAPIC Timer divisor
+const maxTimerDivide = 128
+
+// timer represents the LAPIC Timer callback state.
+type timer struct {
+	sync.Mutex
+
+	// user callback
+	fn func()
+	// periodic mode
+	periodic bool
+}
+
+// timerCount returns the smallest divisor, and its initial count, which
+// allow to represent the argument duration.
+func (io *LAPIC) timerCount(d time.Duration) (div int, count uint32, err error) {
+	if io.TimerFrequency == 0 {
+		return 0, 0, errors.New("timer frequency is unavailable")
+	}
+
+	for div = 1; div <= maxTimerDivide; div *= 2 {
+		n := float64(d) * float64(io.TimerFrequency) / float64(div) / float64(time.Second)
+
+		if n <= math.MaxUint32 {
+			return div, uint32(max(n, 1)), nil
+		}
+	}
+
+	return 0, 0, errors.New("invalid interval")
+}
+
+func (io *LAPIC) startTimer(d time.Duration, fn func(), periodic bool) (err error) {
+	if fn == nil {
+		return errors.New("invalid handler")
+	}
+
+	if io.TimerVector == 0 {
+		return errors.New("invalid timer vector")
+	}
+
+	io.timer.Lock()
+	defer io.timer.Unlock()
+
+	div, count, err := io.timerCount(d)
+
+	if err != nil {
+		return
+	}
+
+	mode := TIMER_MODE_ONE_SHOT
+
+	if periodic {
+		mode = TIMER_MODE_PERIODIC
+	}
+
+	io.timer.fn = fn
+	io.timer.periodic = periodic
+
+	io.SetTimerCount(0)
+	io.SetTimerDivide(div)
+	io.SetTimer(io.TimerVector, mode)
+	io.SetTimerCount(count)
+
+	return
+}
+
+// SetPeriodic programs the LAPIC Timer, in periodic mode, to invoke the
+// argument function at every interval.
+//
+// The function is invoked from the interrupt path, through HandleTimer, on
+// the core owning the LAPIC instance.
+func (io *LAPIC) SetPeriodic(interval time.Duration, fn func()) (err error) {
+	if interval <= 0 {
+		return errors.New("invalid interval")
+	}
+
+	return io.startTimer(interval, fn, true)
+}
+
+// SetOneShot programs the LAPIC Timer, in one-shot mode, to invoke the
+// argument function once at the argument deadline, past deadlines expire
+// immediately.
+//
+// The same constraints of SetPeriodic apply.
+func (io *LAPIC) SetOneShot(deadline time.Time, fn func()) (err error) {
+	return io.startTimer(max(time.Until(deadline), 0), fn, false)
+}
+
+// StopTimer stops the LAPIC Timer, cancelling any pending callback set with
+// SetPeriodic or SetOneShot.
+func (io *LAPIC) StopTimer() {
+	io.timer.Lock()
+	defer io.timer.Unlock()
+
+	io.SetTimerCount(0)
+	io.MaskTimer(true)
+
+	io.timer.fn = nil
+}
+
+// HandleTimer invokes the callback set with SetPeriodic or SetOneShot, it
+// must be invoked by the TimerVector interrupt handler.
+func (io *LAPIC) HandleTimer() {
+	io.timer.Lock()
+	fn := io.timer.fn
+
+	if !io.timer.periodic {
+		io.timer.fn = nil
+	}
+	io.timer.Unlock()
+
+	if fn != nil {
+		fn()
+	}
+}
@@ -0,0 +1,391 @@
+// x86-64 processor support
+// https://github.com/karlo195/tamago
+//
+// Copyright (c) The TamaGo Authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+package amd64
+
+import (
+	"errors"
+	"runtime"
+	"sync"
+	"unsafe"
+
+	"github.com/karlo195/tamago/internal/reg"
+)
+
+// Page table entry attributes
+//
+// (AMD64 Architecture Programmer’s Manual
+// Volume 2 - 5.4 Page-Translation-Table Entry Fields).
+const (
+	PTE_P   uint64 = (1 << 0)  // present
+	PTE_RW  uint64 = (1 << 1)  // read/write
+	PTE_US  uint64 = (1 << 2)  // user/supervisor
+	PTE_PWT uint64 = (1 << 3)  // page-level writethrough
+	PTE_PCD uint64 = (1 << 4)  // page-level cache disable
+	PTE_A   uint64 = (1 << 5)  // accessed
+	PTE_D   uint64 = (1 << 6)  // dirty
+	PTE_PS  uint64 = (1 << 7)  // page size
+	PTE_G   uint64 = (1 << 8)  // global page
+	PTE_NX  uint64 = (1 << 63) // no execute
+
+	// page attribute table index bit, for 4KB pages (PTE_PS otherwise)
+	PTE_PAT uint64 = (1 << 7)
+	// page attribute table index bit, for 2MB and 1GB pages
+	PTE_PAT_LARGE uint64 = (1 << 12)
+)
+
+const (
+	MemoryRegion = PTE_RW | PTE_P
+	DeviceRegion = PTE_NX | PTE_PCD | PTE_PWT | PTE_RW | PTE_P
+)
+
+// Page table levels
+const (
+	PageLevel4KB = 0
+	PageLevel2MB = 1
+	PageLevel1GB = 2
+)
+
+const (
+	// Page Map Level 4 Table (see amd64.h)
+	pml4t = 0x9000
+
+	pageSize         = 4096
+	pageTableEntries = 512
+	pageTableLevels  = 4
+
+	pteAddressMask   uint64 = 0x000ffffffffff000
+	pteAttributeMask        = PTE_NX | PTE_G | PTE_PCD | PTE_PWT | PTE_US | PTE_RW | PTE_P
+)
+
+var (
+	mmuLock sync.Mutex
+
+	// page tables allocated at runtime, referenced to prevent garbage
+	// collection
+	pageTables [][]byte
+
+	// set when no-execute and write protection are enabled, to apply
+	// them on Application Processors (see ·apstart)
+	pageProtection uint32
+)
+
+// defined in mmu.s
+func flush_tlb()
+func enable_page_protection()
+
+func levelSize(level int) uint64 {
+	return 1 << (12 + 9*level)
+}
+
+func patBit(level int) uint64 {
+	if level == PageLevel4KB {
+		return PTE_PAT
+	}
+
+	return PTE_PAT_LARGE
+}
+
+func newPageTable() uint64 {
+	// allocate twice the required size to guarantee alignment
+	buf := make([]byte, 2*pageSize)
+	pageTables = append(pageTables, buf)
+
+	addr := uint64(uintptr(unsafe.Pointer(&buf[0])))
+
+	return (addr + pageSize - 1) &^ (pageSize - 1)
+}
+
+// walk returns the address of the page table entry translating the argument
+// virtual address, stopping at the requested level or at the first not
+// present or large page entry, whichever comes first.
+func walk(va uint64, level int) (pte uint64, l int) {
+	table := uint64(pml4t)
+
+	for l = pageTableLevels - 1; ; l-- {
+		pte = table + 8*((va>>(12+9*l))&(pageTableEntries-1))
+
+		if l == level || l == 0 {
+			return
+		}
+
+		e := reg.Read64(pte)
+
+		if e&PTE_P == 0 || e&PTE_PS != 0 {
+			return
+		}
+
+		table = e & pteAddressMask
+	}
+}
+
+// entry returns the address of the page table entry translating the argument
+// virtual address at the requested level, allocating missing tables or
+// splitting larger pages as required.
+func entry(va uint64, level int) (pte uint64) {
+	for {
+		pte, l := walk(va, level)
+
+		if l == level {
+			return pte
+		}
+
+		e := reg.Read64(pte)
+		table := newPageTable()
+
+		if e&PTE_P != 0 {
+			// split large page preserving its attributes
+			size := levelSize(l - 1)
+			base := e & pteAddressMask &^ (levelSize(l) - 1)
+			attr := e & pteAttributeMask
+
+			if e&PTE_PAT_LARGE != 0 {
+				attr |= patBit(l - 1)
+			}
+
+			if l-1 > 0 {
+				attr |= PTE_PS
+			}
+
+			for i := uint64(0); i < pageTableEntries; i++ {
+				reg.Write64(table+8*i, (base+i*size)|attr)
+			}
+		}
+
+		// access restrictions are only applied on leaf entries
+		reg.Write64(pte, table|PTE_US|PTE_RW|PTE_P)
+	}
+}
+
+func checkRange(start uint64, end uint64) error {
+	if start%pageSize != 0 || end%pageSize != 0 || end <= start {
+		return errors.New("invalid page range")
+	}
+
+	return nil
+}
+
+func (cpu *CPU) initPageProtection(flags uint64) error {
+	if flags&PTE_NX == 0 || pageProtection != 0 {
+		return nil
+	}
+
+	if !cpu.features.NX {
+		return errors.New("no-execute pages not supported")
+	}
+
+	enable_page_protection()
+	pageProtection = 1
+
+	return nil
+}
+
+// FlushTLBs flushes the current CPU Translation Lookaside Buffers.
+//
+// Changes to the page tables performed on the current CPU are not
+// automatically propagated to other CPUs, which must flush their own buffers.
+func (cpu *CPU) FlushTLBs() {
+	flush_tlb()
+}
+
+// Translate returns the physical address and page attributes for the
+// argument virtual address, ok is false when the address is not mapped.
+func (cpu *CPU) Translate(va uint64) (pa uint64, flags uint64, level int, ok bool) {
+	mmuLock.Lock()
+	defer mmuLock.Unlock()
+
+	pte, level := walk(va, 0)
+	e := reg.Read64(pte)
+
+	if e&PTE_P == 0 {
+		return
+	}
+
+	size := levelSize(level)
+	pa = e&pteAddressMask&^(size-1) + va%size
+
+	return pa, e & pteAttributeMask, level, true
+}
+
+// Map (re)configures the page tables to translate the argument virtual
+// memory range to the physical memory range starting at the pa argument, with
+// the argument attribute flags (e.g. MemoryRegion, DeviceRegion).
+//
+// The largest page size allowed by the range alignment is used for each
+// translation, existing larger pages are split as required.
+func (cpu *CPU) Map(start, end, pa, flags uint64) (err error) {
+	mmuLock.Lock()
+	defer mmuLock.Unlock()
+
+	if err = checkRange(start, end); err != nil {
+		return
+	}
+
+	if pa%pageSize != 0 {
+		return errors.New("invalid physical address")
+	}
+
+	if err = cpu.initPageProtection(flags); err != nil {
+		return
+	}
+
+	flags = (flags & pteAttributeMask) | PTE_P
+
+	for va := start; va < end; {
+		level := PageLevel4KB
+
+		for l := PageLevel1GB; l > PageLevel4KB; l-- {
+			size := levelSize(l)
+
+			if va%size == 0 && pa%size == 0 && end-va >= size {
+				level = l
+				break
+			}
+		}
+
+		e := pa | flags
+
+		if level > PageLevel4KB {
+			e |= PTE_PS
+		}
+
+		reg.Write64(entry(va, level), e)
+
+		va += levelSize(level)
+		pa += levelSize(level)
+	}
+
+	cpu.FlushTLBs()
+
+	return
+}
+
+// MapMMIO maps, with a flat 1:1 mapping, the argument memory range as an
+// uncacheable and non-executable window for memory mapped I/O (e.g. PCI BARs).
+func (cpu *CPU) MapMMIO(start, end uint64) (err error) {
+	return cpu.Map(start, end, start, DeviceRegion)
+}
+
+func (cpu *CPU) updatePages(start, end uint64, mapped bool, fn func(e uint64, level int) uint64) (err error) {
+	if err = checkRange(start, end); err != nil {
+		return
+	}
+
+	for va := start; va < end; {
+		pte, level := walk(va, PageLevel4KB)
+		size := levelSize(level)
+		e := reg.Read64(pte)
+
+		switch {
+		case e&PTE_P == 0 && mapped:
+			return errors.New("page not mapped")
+		case e&PTE_P == 0:
+			va = (va + size) &^ (size - 1)
+		case va%size != 0 || end-va < size:
+			entry(va, level-1)
+		default:
+			reg.Write64(pte, fn(e, level))
+			va += size
+		}
+	}
+
+	cpu.FlushTLBs()
+
+	return
+}
+
+// Unmap invalidates the page table entries for the argument virtual memory
+// range.
+func (cpu *CPU) Unmap(start, end uint64) (err error) {
+	mmuLock.Lock()
+	defer mmuLock.Unlock()
+
+	return cpu.updatePages(start, end, false, func(e uint64, _ int) uint64 {
+		return e &^ PTE_P
+	})
+}
+
+// SetAttributes (re)configures the page tables for the argument virtual
+// memory range, which must be already mapped, with the argument attribute
+// flags.
+func (cpu *CPU) SetAttributes(start, end, flags uint64) (err error) {
+	mmuLock.Lock()
+	defer mmuLock.Unlock()
+
+	if err = cpu.initPageProtection(flags); err != nil {
+		return
+	}
+
+	flags &= pteAttributeMask
+
+	return cpu.updatePages(start, end, true, func(e uint64, _ int) uint64 {
+		return e&^pteAttributeMask | flags | PTE_P
+	})
+}
+
+// SetAttribute (re)configures a single attribute flag on the page tables for
+// the argument virtual memory range, which must be already mapped.
+func (cpu *CPU) SetAttribute(start, end, flag uint64, val bool) (err error) {
+	mmuLock.Lock()
+	defer mmuLock.Unlock()
+
+	if val {
+		if err = cpu.initPageProtection(flag); err != nil {
+			return
+		}
+	}
+
+	flag &= pteAttributeMask &^ PTE_P
+
+	return cpu.updatePages(start, end, true, func(e uint64, _ int) uint64 {
+		if val {
+			return e | flag
+		}
+
+		return e &^ flag
+	})
+}
+
+// EnforceWX enforces a Write XOR Execute policy on the runtime memory: the
+// range returned by runtime.TextRegion() is marked as read-only, all
+// remaining memory returned by runtime.MemRegion() is marked as
+// non-executable.
+//
+// Pages shared between code and data, at the text region boundaries, are left
+// executable and writable.
+func (cpu *CPU) EnforceWX() (err error) {
+	ramStart, ramEnd := runtime.MemRegion()
+	textStart, textEnd := runtime.TextRegion()
+
+	ramStart = (ramStart + pageSize - 1) &^ (pageSize - 1)
+	ramEnd = ramEnd &^ (pageSize - 1)
+
+	roStart := (textStart + pageSize - 1) &^ (pageSize - 1)
+	roEnd := textEnd &^ (pageSize - 1)
+
+	xStart := textStart &^ (pageSize - 1)
+	xEnd := (textEnd + pageSize - 1) &^ (pageSize - 1)
+
+	if roEnd > roStart {
+		if err = cpu.SetAttribute(roStart, roEnd, PTE_RW, false); err != nil {
+			return
+		}
+	}
+
+	if xStart > ramStart {
+		if err = cpu.SetAttribute(ramStart, xStart, PTE_NX, true); err != nil {
+			return
+		}
+	}
+
+	if ramEnd > xEnd {
+		err = cpu.SetAttribute(xEnd, ramEnd, PTE_NX, true)
+	}
+
+	return
+}
@@ -0,0 +1,29 @@
+// x86-64 processor support
+// https://github.com/usbarmory/tamago
+//
+// Copyright (c) The TamaGo Authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+#include "amd64.h"
+#include "textflag.h"
+
+// func flush_tlb()
+TEXT ·flush_tlb(SB),$0
+	MOVQ	CR3, AX
+	MOVQ	AX, CR3
+	RET
+
+// func enable_page_protection()
+TEXT ·enable_page_protection(SB),NOSPLIT,$0
+	MOVL	$MSR_EFER, CX
+	RDMSR
+	ORL	$(1<<11), AX		// set MSR_EFER.NXE
+	WRMSR
+
+	MOVQ	CR0, AX
+	ORL	$(1<<16), AX		// set CR0.WP
+	MOVQ	AX, CR0
+
+	RET
@@ -0,0 +1,67 @@
+// x86-64 processor support
+// https://github.com/karlo195/tamago
+//
+// Copyright (c) The TamaGo Authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+package amd64
+
+import (
+	"errors"
+
+	"github.com/karlo195/tamago/amd64/lapic"
+)
+
+// AllocateInterrupt allocates a free user defined interrupt vector, registers
+// the argument function as its handler (see [CPU.SetInterruptHandler]) and
+// enables it.
+//
+// Vectors are allocated from the top of the user defined range to minimize
+// conflicts with statically assigned ones (e.g. I/O APIC redirection
+// entries).
+func (cpu *CPU) AllocateInterrupt(fn func()) (vector int, err error) {
+	if fn == nil {
+		return 0, errors.New("invalid handler")
+	}
+
+	handlersLock.Lock()
+
+	for vector = IRQ_CALL - 1; vector >= 32; vector-- {
+		if handlers[vector] == nil {
+			break
+		}
+	}
+
+	if vector < 32 {
+		handlersLock.Unlock()
+		return 0, errors.New("no free interrupt vectors")
+	}
+
+	// reserve the vector before releasing the lock
+	handlers[vector] = &handler{}
+	handlersLock.Unlock()
+
+	cpu.SetInterruptHandler(vector, fn)
+	cpu.EnableInterrupt(vector)
+
+	return
+}
+
+// FreeInterrupt disables a user defined interrupt vector and releases it for
+// subsequent allocation (see [CPU.AllocateInterrupt]).
+func (cpu *CPU) FreeInterrupt(vector int) {
+	cpu.SetInterruptHandler(vector, nil)
+}
+
+// MSI returns the Message Signalled Interrupt address and data values for
+// fixed delivery of the argument vector to the processor LAPIC.
+//
+// The returned values are meant to be used for MSI or MSI-X interrupt
+// configuration (e.g. [pci.CapabilityMSIX.EnableInterrupt]).
+//
+// [pci.CapabilityMSIX.EnableInterrupt]: https://pkg.go.dev/github.com/karlo195/tamago/soc/intel/pci#CapabilityMSIX.EnableInterrupt
+func (cpu *CPU) MSI(vector int) (addr uint64, data uint32) {
+	return lapic.MSIMessage(int(cpu.ID()), vector, lapic.ICR_DLV_IRQ)
+}
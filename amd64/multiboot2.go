@@ -0,0 +1,195 @@
+// x86-64 processor support
+// https://github.com/karlo195/tamago
+//
+// Copyright (c) The TamaGo Authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+package amd64
+
+import (
+	"bytes"
+	"encoding/binary"
+)
+
+// Multiboot2 information tag types
+const (
+	MB2_TAG_END         = 0
+	MB2_TAG_CMDLINE     = 1
+	MB2_TAG_BOOTLOADER  = 2
+	MB2_TAG_MODULE      = 3
+	MB2_TAG_MMAP        = 6
+	MB2_TAG_FRAMEBUFFER = 8
+)
+
+// Multiboot2 memory map entry types
+const (
+	MB2_MEMORY_AVAILABLE        = 1
+	MB2_MEMORY_RESERVED         = 2
+	MB2_MEMORY_ACPI_RECLAIMABLE = 3
+	MB2_MEMORY_NVS              = 4
+	MB2_MEMORY_BADRAM           = 5
+)
+
+// maximum Multiboot2 information structure size
+const mb2InfoSize = 64 * 1024
+
+// MemoryMapEntry represents a Multiboot2 memory map entry.
+type MemoryMapEntry struct {
+	// Address is the region start address
+	Address uint64
+	// Size is the region length in bytes
+	Size uint64
+	// Type is the region type (e.g. MB2_MEMORY_AVAILABLE)
+	Type uint32
+}
+
+// Module represents a boot module loaded by the boot loader.
+type Module struct {
+	// Start is the module start address
+	Start uint32
+	// End is the module end address (exclusive)
+	End uint32
+	// Name is the module string (e.g. its command line)
+	Name string
+}
+
+// Framebuffer represents the framebuffer information set up by the boot
+// loader.
+type Framebuffer struct {
+	// Address is the framebuffer physical address
+	Address uint64
+	// Pitch is the number of bytes per line
+	Pitch uint32
+	// Width is the number of pixels (or characters in EGA text mode) per line
+	Width uint32
+	// Height is the number of lines
+	Height uint32
+	// BPP is the number of bits per pixel
+	BPP uint8
+	// Type is the framebuffer type (0: indexed, 1: RGB, 2: EGA text)
+	Type uint8
+}
+
+// Multiboot2Info represents the Multiboot2 information structure passed by
+// the boot loader.
+type Multiboot2Info struct {
+	// CommandLine is the kernel command line
+	CommandLine string
+	// BootLoader is the boot loader name
+	BootLoader string
+	// Modules is the list of boot modules
+	Modules []Module
+	// MemoryMap is the boot memory map
+	MemoryMap []MemoryMapEntry
+	// Framebuffer is the framebuffer information, nil when not available
+	Framebuffer *Framebuffer
+}
+
+func cstring(buf []byte) string {
+	if i := bytes.IndexByte(buf, 0); i >= 0 {
+		buf = buf[:i]
+	}
+
+	return string(buf)
+}
+
+func (info *Multiboot2Info) parseTag(t uint32, tag []byte) {
+	switch t {
+	case MB2_TAG_CMDLINE:
+		info.CommandLine = cstring(tag[8:])
+	case MB2_TAG_BOOTLOADER:
+		info.BootLoader = cstring(tag[8:])
+	case MB2_TAG_MODULE:
+		if len(tag) < 16 {
+			return
+		}
+
+		info.Modules = append(info.Modules, Module{
+			Start: binary.LittleEndian.Uint32(tag[8:]),
+			End:   binary.LittleEndian.Uint32(tag[12:]),
+			Name:  cstring(tag[16:]),
+		})
+	case MB2_TAG_MMAP:
+		if len(tag) < 16 {
+			return
+		}
+
+		n := int(binary.LittleEndian.Uint32(tag[8:]))
+
+		if n < 20 {
+			return
+		}
+
+		for off := 16; off+n <= len(tag); off += n {
+			info.MemoryMap = append(info.MemoryMap, MemoryMapEntry{
+				Address: binary.LittleEndian.Uint64(tag[off:]),
+				Size:    binary.LittleEndian.Uint64(tag[off+8:]),
+				Type:    binary.LittleEndian.Uint32(tag[off+16:]),
+			})
+		}
+	case MB2_TAG_FRAMEBUFFER:
+		if len(tag) < 30 {
+			return
+		}
+
+		info.Framebuffer = &Framebuffer{
+			Address: binary.LittleEndian.Uint64(tag[8:]),
+			Pitch:   binary.LittleEndian.Uint32(tag[16:]),
+			Width:   binary.LittleEndian.Uint32(tag[20:]),
+			Height:  binary.LittleEndian.Uint32(tag[24:]),
+			BPP:     tag[28],
+			Type:    tag[29],
+		}
+	}
+}
+
+// Multiboot2 returns the Multiboot2 information structure passed by the boot
+// loader, when booted through the Multiboot2 protocol (e.g. by GRUB).
+//
+// The memory map is also used, before runtime initialization, to size the
+// available RAM (see RAMEnd()).
+func Multiboot2() (info *Multiboot2Info, ok bool) {
+	addr := multiboot2()
+
+	if addr == 0 {
+		return
+	}
+
+	hdr := read(addr, 8)
+
+	if len(hdr) != 8 {
+		return
+	}
+
+	size := int(binary.LittleEndian.Uint32(hdr))
+
+	if size < 8 || size > mb2InfoSize {
+		return
+	}
+
+	buf := read(addr, size)
+
+	if len(buf) != size {
+		return
+	}
+
+	info = &Multiboot2Info{}
+
+	// tags follow the fixed part (total_size, reserved) and are 8 bytes
+	// aligned
+	for off := 8; off+8 <= size; {
+		t := binary.LittleEndian.Uint32(buf[off:])
+		n := int(binary.LittleEndian.Uint32(buf[off+4:]))
+
+		if t == MB2_TAG_END || n < 8 || off+n > size {
+			break
+		}
+
+		info.parseTag(t, buf[off:off+n])
+		off += (n + 7) &^ 7
+	}
+
+	return info, true
+}
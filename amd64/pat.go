@@ -0,0 +1,263 @@
+// x86-64 processor support
+// https://github.com/karlo195/tamago
+//
+// Copyright (c) The TamaGo Authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+package amd64
+
+import (
+	"errors"
+
+	"github.com/karlo195/tamago/internal/reg"
+)
+
+// Memory types
+//
+// (Intel® 64 and IA-32 Architectures Software Developer’s Manual
+// Volume 3A - 12.3 Methods of Caching Available).
+const (
+	MEM_UC       = 0x00 // Uncacheable
+	MEM_WC       = 0x01 // Write Combining
+	MEM_WT       = 0x04 // Write-through
+	MEM_WP       = 0x05 // Write-protected
+	MEM_WB       = 0x06 // Write-back
+	MEM_UC_MINUS = 0x07 // Uncached (PAT only)
+)
+
+// Memory type MSRs
+//
+// (Intel® 64 and IA-32 Architectures Software Developer’s Manual
+// Volume 3A - 12.11 Memory Type Range Registers (MTRRs)
+// Volume 3A - 12.12 Page Attribute Table (PAT)).
+const (
+	MSR_MTRRCAP  = 0xfe
+	MTRRCAP_WC   = 10
+	MTRRCAP_VCNT = 0
+
+	MSR_MTRR_PHYSBASE0 = 0x200
+	MSR_MTRR_PHYSMASK0 = 0x201
+	MTRR_PHYSMASK_V    = 11
+
+	MSR_PAT = 0x277
+
+	MSR_MTRR_DEF_TYPE = 0x2ff
+	MTRR_DEF_TYPE_E   = 11
+	MTRR_DEF_TYPE_FE  = 10
+)
+
+// maximum number of tracked variable range MTRRs
+const maxMTRR = 32
+
+// patTable represents the Page Attribute Table configuration, the first four
+// entries match power-up defaults to preserve the memory type of existing
+// PWT/PCD page table entries.
+var patTable = [8]uint8{MEM_WB, MEM_WT, MEM_UC_MINUS, MEM_UC, MEM_WC, MEM_WP, MEM_UC_MINUS, MEM_UC}
+
+// memory type configuration, applied on Application Processors (see
+// ·apply_memory_types)
+var (
+	pat         uint64
+	mtrrs       [maxMTRR * 2]uint64
+	mtrrCount   uint32
+	mtrrDefType uint64
+)
+
+// defined in pat.s
+func cache_disable() (flags uint64)
+func cache_enable(flags uint64)
+func apply_memory_types()
+
+func (cpu *CPU) initPAT() error {
+	if pat != 0 {
+		return nil
+	}
+
+	if !cpu.features.PAT {
+		return errors.New("page attribute table not supported")
+	}
+
+	var val uint64
+
+	for i, t := range patTable {
+		val |= uint64(t) << (8 * i)
+	}
+
+	flags := cache_disable()
+	reg.WriteMsr(MSR_PAT, val)
+	cache_enable(flags)
+
+	pat = val
+
+	return nil
+}
+
+// SetMemoryType (re)configures the page tables for the argument virtual
+// memory range, which must be already mapped, with the argument memory type
+// (see MEM_* constants) through the Page Attribute Table.
+//
+// The effective memory type also depends on the Memory Type Range Registers
+// configuration for the underlying physical memory (see SetMTRR).
+func (cpu *CPU) SetMemoryType(start, end uint64, memType int) (err error) {
+	mmuLock.Lock()
+	defer mmuLock.Unlock()
+
+	index := -1
+
+	for i, t := range patTable {
+		if int(t) == memType {
+			index = i
+			break
+		}
+	}
+
+	if index < 0 {
+		return errors.New("invalid memory type")
+	}
+
+	if err = cpu.initPAT(); err != nil {
+		return
+	}
+
+	return cpu.updatePages(start, end, true, func(e uint64, level int) uint64 {
+		e &^= PTE_PCD | PTE_PWT | patBit(level)
+
+		if index&0b001 != 0 {
+			e |= PTE_PWT
+		}
+
+		if index&0b010 != 0 {
+			e |= PTE_PCD
+		}
+
+		if index&0b100 != 0 {
+			e |= patBit(level)
+		}
+
+		return e
+	})
+}
+
+func physAddrMask() uint64 {
+	eax, _, _, _ := cpuid(CPUID_AMD_PROC, 0)
+	width := eax & 0xff
+
+	if width == 0 {
+		width = 36
+	}
+
+	return 1<<width - 1
+}
+
+func (cpu *CPU) updateMTRR(start uint64, size uint64, memType int, clear bool) (err error) {
+	if !cpu.features.MTRR {
+		return errors.New("memory type range registers not supported")
+	}
+
+	if size < pageSize || size&(size-1) != 0 || start&(size-1) != 0 {
+		return errors.New("invalid memory range")
+	}
+
+	mtrrCap := reg.Msr64(MSR_MTRRCAP)
+	vcnt := min(int(mtrrCap>>MTRRCAP_VCNT)&0xff, maxMTRR)
+
+	switch memType {
+	case MEM_WC:
+		if mtrrCap&(1<<MTRRCAP_WC) == 0 {
+			return errors.New("write combining not supported")
+		}
+	case MEM_UC, MEM_WT, MEM_WP, MEM_WB:
+	default:
+		return errors.New("invalid memory type")
+	}
+
+	mask := physAddrMask()
+	slot := -1
+
+	for i := 0; i < vcnt; i++ {
+		base := reg.Msr64(MSR_MTRR_PHYSBASE0 + uint32(2*i))
+		m := reg.Msr64(MSR_MTRR_PHYSMASK0 + uint32(2*i))
+
+		if m&(1<<MTRR_PHYSMASK_V) == 0 {
+			if slot < 0 && !clear {
+				slot = i
+			}
+			continue
+		}
+
+		if base&mask&^(pageSize-1) == start {
+			slot = i
+			break
+		}
+	}
+
+	if slot < 0 {
+		if clear {
+			return
+		}
+
+		return errors.New("no free memory type range register")
+	}
+
+	base := start | uint64(memType)
+	m := mask&^(size-1) | 1<<MTRR_PHYSMASK_V
+
+	if clear {
+		base = 0
+		m = 0
+	}
+
+	// Intel® 64 and IA-32 Architectures Software Developer’s Manual
+	// Volume 3A - 12.11.7.2 MemTypeSet() Function
+	flags := cache_disable()
+
+	defType := reg.Msr64(MSR_MTRR_DEF_TYPE)
+	reg.WriteMsr(MSR_MTRR_DEF_TYPE, defType&^(1<<MTRR_DEF_TYPE_E))
+
+	reg.WriteMsr(MSR_MTRR_PHYSBASE0+uint32(2*slot), base)
+	reg.WriteMsr(MSR_MTRR_PHYSMASK0+uint32(2*slot), m)
+
+	if defType&(1<<MTRR_DEF_TYPE_E) == 0 {
+		// MTRRs were disabled, all memory was therefore uncacheable
+		defType = MEM_WB
+	}
+
+	reg.WriteMsr(MSR_MTRR_DEF_TYPE, defType|1<<MTRR_DEF_TYPE_E)
+
+	cache_enable(flags)
+
+	for i := 0; i < 2*vcnt; i++ {
+		mtrrs[i] = reg.Msr64(MSR_MTRR_PHYSBASE0 + uint32(i))
+	}
+
+	mtrrCount = uint32(2 * vcnt)
+	mtrrDefType = defType | 1<<MTRR_DEF_TYPE_E
+
+	return
+}
+
+// SetMTRR configures a variable range Memory Type Range Register for the
+// argument physical memory range with the argument memory type (see MEM_*
+// constants), the size must be a power of 2 (minimum 4KB) and the start address
+// aligned to it.
+//
+// If Memory Type Range Registers are disabled, they are enabled with a
+// write-back default memory type. The configuration is also applied to
+// Application Processors started after this call (see InitSMP).
+func (cpu *CPU) SetMTRR(start uint64, size uint64, memType int) (err error) {
+	mmuLock.Lock()
+	defer mmuLock.Unlock()
+
+	return cpu.updateMTRR(start, size, memType, false)
+}
+
+// ClearMTRR disables the variable range Memory Type Range Register previously
+// configured, with SetMTRR, for the argument physical address.
+func (cpu *CPU) ClearMTRR(start uint64) (err error) {
+	mmuLock.Lock()
+	defer mmuLock.Unlock()
+
+	return cpu.updateMTRR(start, pageSize, MEM_UC, true)
+}
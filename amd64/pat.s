@@ -0,0 +1,95 @@
+// x86-64 processor support
+// https://github.com/usbarmory/tamago
+//
+// Copyright (c) The TamaGo Authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+#include "go_asm.h"
+#include "textflag.h"
+
+// func cache_disable() (flags uint64)
+TEXT ·cache_disable(SB),NOSPLIT,$0-8
+	PUSHFQ
+	POPQ	AX
+	MOVQ	AX, flags+0(FP)
+	CLI
+
+	MOVQ	CR0, AX
+	ORL	$(1<<30), AX		// set CR0.CD
+	ANDL	$~(1<<29), AX		// clear CR0.NW
+	MOVQ	AX, CR0
+
+	WBINVD
+
+	// flush TLBs
+	MOVQ	CR3, AX
+	MOVQ	AX, CR3
+
+	RET
+
+// func cache_enable(flags uint64)
+TEXT ·cache_enable(SB),NOSPLIT,$0-8
+	WBINVD
+
+	// flush TLBs
+	MOVQ	CR3, AX
+	MOVQ	AX, CR3
+
+	MOVQ	CR0, AX
+	ANDL	$~(1<<30), AX		// clear CR0.CD
+	MOVQ	AX, CR0
+
+	MOVQ	flags+0(FP), AX
+	PUSHQ	AX
+	POPFQ
+
+	RET
+
+// func apply_memory_types()
+TEXT ·apply_memory_types(SB),NOSPLIT,$0
+	// apply Page Attribute Table, when configured (see CPU.SetMemoryType)
+	MOVQ	·pat(SB), AX
+	CMPQ	AX, $0
+	JE	mtrr
+
+	MOVQ	AX, DX
+	SHRQ	$32, DX
+	MOVL	$(const_MSR_PAT), CX
+	WRMSR
+mtrr:
+	// apply Memory Type Range Registers, when configured (see CPU.SetMTRR)
+	MOVQ	·mtrrDefType(SB), AX
+	CMPQ	AX, $0
+	JE	done
+
+	// disable MTRRs
+	XORL	AX, AX
+	XORL	DX, DX
+	MOVL	$(const_MSR_MTRR_DEF_TYPE), CX
+	WRMSR
+
+	MOVQ	$·mtrrs(SB), SI
+	XORL	BX, BX
+next:
+	CMPL	BX, ·mtrrCount(SB)
+	JAE	enable
+
+	MOVQ	(SI)(BX*8), AX
+	MOVQ	AX, DX
+	SHRQ	$32, DX
+	MOVL	BX, CX
+	ADDL	$(const_MSR_MTRR_PHYSBASE0), CX
+	WRMSR
+
+	INCL	BX
+	JMP	next
+enable:
+	MOVQ	·mtrrDefType(SB), AX
+	MOVQ	AX, DX
+	SHRQ	$32, DX
+	MOVL	$(const_MSR_MTRR_DEF_TYPE), CX
+	WRMSR
+done:
+	RET
@@ -0,0 +1,103 @@
+// x86-64 processor support
+// https://github.com/karlo195/tamago
+//
+// Copyright (c) The TamaGo Authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+package amd64
+
+import (
+	"github.com/karlo195/tamago/amd64/lapic"
+	"github.com/karlo195/tamago/internal/reg"
+)
+
+// Time Stamp Counter auxiliary MSR, holding the processor index returned by
+// RDTSCP.
+const MSR_TSC_AUX = 0xc0000103
+
+// cache line size, used to avoid false sharing among per-CPU values
+const cacheLineSize = 64
+
+// maximum processor index, matching the LAPIC ID width read by CurrentCPU
+const maxProcessorIndex = 0xf
+
+// tscAux is read by ·apstart to set MSR_TSC_AUX on each AP
+var tscAux uint32
+
+// defined in percpu.s
+func rdtscp_aux() uint32
+
+func (cpu *CPU) initProcessorIndex() {
+	if !cpu.features.RDTSCP {
+		return
+	}
+
+	reg.WriteMsr(MSR_TSC_AUX, uint64(cpu.LAPIC.ID()))
+	tscAux = 1
+}
+
+// CurrentCPU returns the index, matching its LAPIC ID, of the processor
+// executing the calling goroutine.
+//
+// The index is read from MSR_TSC_AUX with RDTSCP, when available, to avoid
+// (potentially trapped) LAPIC register access.
+//
+// The Go scheduler can move a goroutine to a different processor at any
+// time, [runtime.LockOSThread] pins it to the processor on which the OS
+// thread (M) runs, as M's are never dropped on `GOOS=tamago`.
+func CurrentCPU() int {
+	if tscAux != 0 {
+		return int(rdtscp_aux())
+	}
+
+	return int(reg.Get(LAPIC_ID, lapic.ID, maxProcessorIndex))
+}
+
+type perCPUValue[T any] struct {
+	val T
+	_   [cacheLineSize]byte
+}
+
+// PerCPU represents a variable with a separate instance for each processor,
+// allowing SMP aware drivers and schedulers to keep per-core state without
+// global locking.
+//
+// Instances are indexed by processor (see [CurrentCPU]) and padded to avoid
+// false sharing. As LAPIC IDs are not necessarily contiguous (e.g. with SMT
+// siblings or hypervisor assigned gaps) an instance is allocated for every
+// possible index.
+type PerCPU[T any] struct {
+	vals []perCPUValue[T]
+}
+
+// NewPerCPU returns a per-CPU variable with instances for all possible
+// processor indices.
+func NewPerCPU[T any]() *PerCPU[T] {
+	return &PerCPU[T]{
+		vals: make([]perCPUValue[T], max(NumCPU(), maxProcessorIndex+1)),
+	}
+}
+
+// Get returns the instance of the processor executing the calling goroutine.
+func (p *PerCPU[T]) Get() *T {
+	return p.At(CurrentCPU())
+}
+
+// At returns the instance of the indexed processor.
+func (p *PerCPU[T]) At(cpu int) *T {
+	if cpu < 0 || cpu >= len(p.vals) {
+		panic("invalid processor index")
+	}
+
+	return &p.vals[cpu].val
+}
+
+// Each invokes the argument function on each processor instance, including
+// the ones of indices not matching any processor.
+func (p *PerCPU[T]) Each(fn func(cpu int, val *T)) {
+	for i := range p.vals {
+		fn(i, &p.vals[i].val)
+	}
+}
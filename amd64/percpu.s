@@ -0,0 +1,15 @@
+// x86-64 processor support
+// https://github.com/usbarmory/tamago
+//
+// Copyright (c) The TamaGo Authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+#include "textflag.h"
+
+// func rdtscp_aux() uint32
+TEXT ·rdtscp_aux(SB),NOSPLIT,$0-4
+	RDTSCP
+	MOVL	CX, ret+0(FP)
+	RET
@@ -0,0 +1,368 @@
+// x86-64 processor support
+// https://github.com/karlo195/tamago
+//
+// Copyright (c) The TamaGo Authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+package amd64
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/karlo195/tamago/bits"
+	"github.com/karlo195/tamago/internal/reg"
+)
+
+// Architectural performance monitoring
+//
+// (Intel® 64 and IA-32 Architectures Software Developer’s Manual
+// Volume 3B - 21.2 Architectural Performance Monitoring).
+const (
+	CPUID_PERFMON   = 0x0a
+	PERFMON_VERSION = 0
+	PERFMON_GP_NUM  = 8
+	PERFMON_GP_BITS = 16
+	PERFMON_FX_NUM  = 0
+	PERFMON_FX_BITS = 5
+
+	MSR_PMC0 = 0xc1
+
+	MSR_PERFEVTSEL0     = 0x186
+	PERFEVTSEL_EN       = 22
+	PERFEVTSEL_INT      = 20
+	PERFEVTSEL_OS       = 17
+	PERFEVTSEL_USR      = 16
+	PERFEVTSEL_UMASK    = 8
+	PERFEVTSEL_EVENTSEL = 0
+
+	MSR_FIXED_CTR0     = 0x309
+	MSR_FIXED_CTR_CTRL = 0x38d
+	FIXED_CTR_CTRL_OS  = 0
+	FIXED_CTR_CTRL_PMI = 3
+
+	MSR_PERF_GLOBAL_CTRL = 0x38f
+	PERF_GLOBAL_CTRL_FX  = 32
+
+	MSR_PERF_GLOBAL_OVF_CTRL = 0x390
+)
+
+// AMD performance monitoring
+//
+// (AMD64 Architecture Programmer’s Manual
+// Volume 2 - 13.2 Hardware Performance Monitoring and Control).
+const (
+	MSR_AMD_PERF_CTL0 = 0xc0010000
+	MSR_AMD_PERF_CTR0 = 0xc0010004
+
+	AMD_PERF_NUM  = 4
+	AMD_PERF_BITS = 48
+)
+
+// Performance monitoring events
+const (
+	// Unhalted core cycles
+	EventCycles = iota
+	// Instructions retired
+	EventInstructions
+	// Unhalted reference cycles
+	EventReferenceCycles
+	// Last level cache references
+	EventCacheReferences
+	// Last level cache misses
+	EventCacheMisses
+	// Branch instructions retired
+	EventBranches
+	// Branch mispredictions retired
+	EventBranchMisses
+)
+
+// Intel architectural event select and unit mask values, indexed by event
+// (Table 21-1. UMask and Event Select Encodings for Pre-Defined
+// Architectural Performance Events).
+var intelEvents = [][2]uint32{
+	EventCycles:          {0x3c, 0x00},
+	EventInstructions:    {0xc0, 0x00},
+	EventReferenceCycles: {0x3c, 0x01},
+	EventCacheReferences: {0x2e, 0x4f},
+	EventCacheMisses:     {0x2e, 0x41},
+	EventBranches:        {0xc4, 0x00},
+	EventBranchMisses:    {0xc5, 0x00},
+}
+
+// AMD event select and unit mask values, indexed by event, a zero event select
+// indicates an unsupported event.
+var amdEvents = [][2]uint32{
+	EventCycles:       {0x76, 0x00},
+	EventInstructions: {0xc0, 0x00},
+	EventCacheMisses:  {0x41, 0x00},
+	EventBranches:     {0xc2, 0x00},
+	EventBranchMisses: {0xc3, 0x00},
+}
+
+// Intel fixed-function counters, indexed by event
+var intelFixed = map[int]int{
+	EventInstructions:    0,
+	EventCycles:          1,
+	EventReferenceCycles: 2,
+}
+
+var pmc struct {
+	sync.Mutex
+
+	init    bool
+	amd     bool
+	version int
+	gp      int
+	fixed   int
+	mask    uint64
+	// unavailable architectural events
+	unavailable uint32
+
+	gpUsed    uint32
+	fixedUsed uint32
+}
+
+// Counter represents a performance monitoring counter.
+type Counter struct {
+	// Event is the counted event (see Event* constants).
+	Event int
+
+	fixed bool
+	index int
+}
+
+func initPMC() {
+	if pmc.init {
+		return
+	}
+
+	pmc.init = true
+
+	_, _, ecx, _ := cpuid(CPUID_VENDOR, 0)
+
+	if ecx == CPUID_VENDOR_ECX_AMD {
+		pmc.amd = true
+		pmc.gp = AMD_PERF_NUM
+		pmc.mask = 1<<AMD_PERF_BITS - 1
+		return
+	}
+
+	eax, ebx, _, edx := cpuid(CPUID_PERFMON, 0)
+
+	if pmc.version = int(bits.Get(&eax, PERFMON_VERSION, 0xff)); pmc.version == 0 {
+		return
+	}
+
+	pmc.gp = int(bits.Get(&eax, PERFMON_GP_NUM, 0xff))
+	pmc.mask = 1<<bits.Get(&eax, PERFMON_GP_BITS, 0xff) - 1
+	pmc.unavailable = ebx
+
+	if pmc.version > 1 {
+		pmc.fixed = int(bits.Get(&edx, PERFMON_FX_NUM, 0b11111))
+	}
+}
+
+// NumCounters returns the number of available general-purpose and
+// fixed-function performance monitoring counters.
+func (cpu *CPU) NumCounters() (gp int, fixed int) {
+	pmc.Lock()
+	defer pmc.Unlock()
+
+	initPMC()
+
+	return pmc.gp, pmc.fixed
+}
+
+// NewCounter allocates and configures a performance monitoring counter for
+// the argument event (see Event* constants), counting only at supervisor
+// privilege level. Fixed-function counters are preferred, when available, for
+// supported events.
+//
+// Performance monitoring counters are specific to each core, therefore
+// counting only takes place on the CPU executing this function and
+// Counter.Start, goroutines being measured should use runtime.LockOSThread()
+// on SMP systems.
+func (cpu *CPU) NewCounter(event int) (c *Counter, err error) {
+	pmc.Lock()
+	defer pmc.Unlock()
+
+	initPMC()
+
+	if pmc.gp == 0 {
+		return nil, errors.New("performance monitoring not supported")
+	}
+
+	c = &Counter{
+		Event: event,
+	}
+
+	var evtsel [2]uint32
+
+	if pmc.amd {
+		if event < 0 || event >= len(amdEvents) || amdEvents[event][0] == 0 {
+			return nil, errors.New("unsupported event")
+		}
+
+		evtsel = amdEvents[event]
+	} else {
+		if event < 0 || event >= len(intelEvents) || bits.IsSet(&pmc.unavailable, event) {
+			return nil, errors.New("unsupported event")
+		}
+
+		evtsel = intelEvents[event]
+
+		if i, ok := intelFixed[event]; ok && i < pmc.fixed && !bits.IsSet(&pmc.fixedUsed, i) {
+			bits.Set(&pmc.fixedUsed, i)
+
+			c.fixed = true
+			c.index = i
+
+			// enable counting at privilege level 0
+			ctrl := reg.Msr64(MSR_FIXED_CTR_CTRL)
+			ctrl &^= 0b1111 << (4 * i)
+			ctrl |= 1 << (4*i + FIXED_CTR_CTRL_OS)
+			reg.WriteMsr(MSR_FIXED_CTR_CTRL, ctrl)
+
+			c.Reset()
+
+			return
+		}
+	}
+
+	c.index = -1
+
+	for i := 0; i < pmc.gp; i++ {
+		if !bits.IsSet(&pmc.gpUsed, i) {
+			c.index = i
+			break
+		}
+	}
+
+	if c.index < 0 {
+		return nil, errors.New("no counter available")
+	}
+
+	bits.Set(&pmc.gpUsed, c.index)
+
+	val := uint64(evtsel[0])<<PERFEVTSEL_EVENTSEL |
+		uint64(evtsel[1])<<PERFEVTSEL_UMASK |
+		1<<PERFEVTSEL_OS
+
+	reg.WriteMsr(c.selectRegister(), val)
+	c.Reset()
+
+	return
+}
+
+func (c *Counter) selectRegister() uint32 {
+	if pmc.amd {
+		return MSR_AMD_PERF_CTL0 + uint32(c.index)
+	}
+
+	return MSR_PERFEVTSEL0 + uint32(c.index)
+}
+
+func (c *Counter) counterRegister() uint32 {
+	switch {
+	case c.fixed:
+		return MSR_FIXED_CTR0 + uint32(c.index)
+	case pmc.amd:
+		return MSR_AMD_PERF_CTR0 + uint32(c.index)
+	default:
+		return MSR_PMC0 + uint32(c.index)
+	}
+}
+
+func (c *Counter) enable(on bool) {
+	pmc.Lock()
+	defer pmc.Unlock()
+
+	if !c.fixed {
+		sel := reg.Msr64(c.selectRegister())
+		bits.SetTo64(&sel, PERFEVTSEL_EN, on)
+		reg.WriteMsr(c.selectRegister(), sel)
+	}
+
+	if pmc.amd {
+		return
+	}
+
+	pos := c.index
+
+	if c.fixed {
+		pos += PERF_GLOBAL_CTRL_FX
+	}
+
+	ctrl := reg.Msr64(MSR_PERF_GLOBAL_CTRL)
+	bits.SetTo64(&ctrl, pos, on)
+	reg.WriteMsr(MSR_PERF_GLOBAL_CTRL, ctrl)
+}
+
+// setInterrupt configures the generation of a performance monitoring
+// interrupt on counter overflow, delivered through the LAPIC LVT performance
+// counter entry.
+func (c *Counter) setInterrupt(on bool) {
+	pmc.Lock()
+	defer pmc.Unlock()
+
+	if c.fixed {
+		ctrl := reg.Msr64(MSR_FIXED_CTR_CTRL)
+		bits.SetTo64(&ctrl, 4*c.index+FIXED_CTR_CTRL_PMI, on)
+		reg.WriteMsr(MSR_FIXED_CTR_CTRL, ctrl)
+		return
+	}
+
+	sel := reg.Msr64(c.selectRegister())
+	bits.SetTo64(&sel, PERFEVTSEL_INT, on)
+	reg.WriteMsr(c.selectRegister(), sel)
+}
+
+// overflowStatus returns the MSR_PERF_GLOBAL_OVF_CTRL value which clears the
+// counter overflow status, zero is returned when not required.
+func (c *Counter) overflowStatus() uint64 {
+	switch {
+	case pmc.amd || pmc.version < 2:
+		return 0
+	case c.fixed:
+		return 1 << (PERF_GLOBAL_CTRL_FX + c.index)
+	default:
+		return 1 << c.index
+	}
+}
+
+// Start enables counting.
+func (c *Counter) Start() {
+	c.enable(true)
+}
+
+// Stop disables counting.
+func (c *Counter) Stop() {
+	c.enable(false)
+}
+
+// Read returns the current counter value.
+func (c *Counter) Read() uint64 {
+	return reg.Msr64(c.counterRegister()) & pmc.mask
+}
+
+// Reset clears the counter value.
+func (c *Counter) Reset() {
+	reg.WriteMsr(c.counterRegister(), 0)
+}
+
+// Release stops and frees the counter for re-allocation.
+func (c *Counter) Release() {
+	c.Stop()
+
+	pmc.Lock()
+	defer pmc.Unlock()
+
+	if c.fixed {
+		bits.Clear(&pmc.fixedUsed, c.index)
+	} else {
+		bits.Clear(&pmc.gpUsed, c.index)
+	}
+}
@@ -9,25 +9,165 @@
 package amd64
 
 import (
+	"crypto/sha256"
+	"sync"
 	_ "unsafe"
 
 	"github.com/karlo195/tamago/internal/rng"
 )
 
+// Entropy sources
+const (
+	EntropyRDRAND = "RDRAND"
+	EntropyRDSEED = "RDSEED"
+)
+
+const (
+	// Intel® Digital Random Number Generator (DRNG) Software
+	// Implementation Guide - 5.2.1 Retry Recommendations
+	rdrandRetries = 10
+	rdseedRetries = 100
+
+	// number of bytes generated between DRBG reseeds
+	reseedInterval = 1 << 16
+)
+
+var entropy struct {
+	sync.Mutex
+
+	// DRBG seeded with RDSEED, when available
+	drbg *rng.DRBG
+	// bytes generated since last reseed
+	count int
+	// active source
+	source string
+}
+
 // defined in rng.s
-func rdrand() uint32
+func rdrand() (val uint32, ok bool)
+func rdseed() (val uint32, ok bool)
+
+func getRDRAND() uint32 {
+	for i := 0; i < rdrandRetries; i++ {
+		if val, ok := rdrand(); ok {
+			return val
+		}
+	}
+
+	panic("RDRAND failure")
+}
+
+// seed fills b with RDSEED output, falling back to RDRAND on entropy
+// underflow, and returns the weakest source used.
+func seed(b []byte) (source string) {
+	source = EntropyRDSEED
+
+	for read := 0; read < len(b); {
+		var val uint32
+		var ok bool
+
+		for i := 0; i < rdseedRetries && !ok; i++ {
+			val, ok = rdseed()
+		}
+
+		if !ok {
+			val = getRDRAND()
+			source = EntropyRDRAND
+		}
+
+		read = rng.Fill(b, read, val)
+	}
+
+	return
+}
+
+func reseed(ext ...byte) {
+	var s [32]byte
+
+	entropy.source = seed(s[:])
+
+	if len(ext) > 0 {
+		h := sha256.Sum256(ext)
+
+		for i := range s {
+			s[i] ^= h[i]
+		}
+	}
 
-// GetRandomData returns len(b) random bytes gathered from the RDRAND instruction.
+	entropy.drbg.Lock()
+	defer entropy.drbg.Unlock()
+
+	for i := range s {
+		entropy.drbg.Seed[i] ^= s[i]
+	}
+
+	entropy.count = 0
+}
+
+// AddEntropy forces a DRBG reseed from the processor entropy source, mixed
+// with the argument external entropy (e.g. from a VirtIO RNG device).
+//
+// It should be invoked periodically with hypervisor provided entropy, and
+// whenever a snapshot restore is detected, to prevent reuse of the DRBG
+// stream across restored instances. Without RDSEED support data is gathered
+// directly from RDRAND and the function has no effect.
+func AddEntropy(b []byte) {
+	entropy.Lock()
+	defer entropy.Unlock()
+
+	if entropy.drbg == nil {
+		return
+	}
+
+	reseed(b...)
+}
+
+// EntropySource returns the entropy source currently in use by GetRandomData,
+// RDSEED is only reported when all data since the last reseed has been
+// derived from it.
+func EntropySource() string {
+	entropy.Lock()
+	defer entropy.Unlock()
+
+	return entropy.source
+}
+
+// GetRandomData returns len(b) random bytes.
+//
+// When the RDSEED instruction is supported, data is generated by an AES-CTR
+// DRBG periodically reseeded from RDSEED, falling back to RDRAND on entropy
+// underflow, otherwise data is gathered from the RDRAND instruction.
 func GetRandomData(b []byte) {
-	read := 0
-	need := len(b)
+	entropy.Lock()
+
+	if entropy.drbg == nil {
+		entropy.Unlock()
+
+		for read := 0; read < len(b); {
+			read = rng.Fill(b, read, getRDRAND())
+		}
+
+		return
+	}
 
-	for read < need {
-		read = rng.Fill(b, read, rdrand())
+	if entropy.count >= reseedInterval {
+		reseed()
 	}
+
+	entropy.count += len(b)
+	entropy.Unlock()
+
+	entropy.drbg.GetRandomData(b)
 }
 
 //go:linkname initRNG runtime.initRNG
 func initRNG() {
+	entropy.source = EntropyRDRAND
+
+	if processorFeatures().RDSEED {
+		entropy.drbg = &rng.DRBG{}
+		reseed()
+	}
+
 	rng.GetRandomDataFn = GetRandomData
 }
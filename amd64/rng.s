@@ -6,11 +6,22 @@
 // Use of this source code is governed by the license
 // that can be found in the LICENSE file.
 
-// func rdrand() uint32
-TEXT ·rdrand(SB),$0-4
+// func rdrand() (val uint32, ok bool)
+TEXT ·rdrand(SB),$0-5
 	// rdrand eax
 	BYTE	$0x0f
 	BYTE	$0xc7
 	BYTE	$0xf0
-	MOVL	AX, ret+0(FP)
+	SETCS	ok+4(FP)
+	MOVL	AX, val+0(FP)
+	RET
+
+// func rdseed() (val uint32, ok bool)
+TEXT ·rdseed(SB),$0-5
+	// rdseed eax
+	BYTE	$0x0f
+	BYTE	$0xc7
+	BYTE	$0xf8
+	SETCS	ok+4(FP)
+	MOVL	AX, val+0(FP)
 	RET
@@ -35,9 +35,34 @@ const (
 	taskAddress = 0x6020
 )
 
+// bsp represents the processor which initialized the APs, used by ·apstart
+// to invoke its APInit function.
+var bsp *CPU
+
 // defined in smp.s
 func apinit_reloc(init uintptr, start uintptr)
 
+// runAPInit invokes the APInit function, if any, for the current AP, it is
+// called by ·apstart before executing each task and runs APInit only once.
+//
+//go:nosplit
+func runAPInit() {
+	if bsp == nil || bsp.APInit == nil {
+		return
+	}
+
+	i := CurrentCPU()
+
+	if i < 1 || i > len(bsp.aps) {
+		return
+	}
+
+	if ap := bsp.aps[i-1]; !ap.apInit {
+		ap.apInit = true
+		bsp.APInit(ap)
+	}
+}
+
 // task represents a CPU task
 type task struct {
 	sp uint64 // stack pointer
@@ -129,6 +154,8 @@ func (cpu *CPU) InitSMP(n int) (aps []*CPU) {
 		return
 	}
 
+	bsp = cpu
+
 	// copy ·apinit to a 16-bit address reachable in real mode
 	// copy ·apstart pointer to avoid RIP/EIP-relative addressing
 	apinit_reloc(apinitAddress, apstartAddress)
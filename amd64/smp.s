@@ -139,6 +139,56 @@ TEXT ·apstart<>(SB),NOSPLIT|NOFRAME,$0
 	MOVQ	$·idtptr(SB), AX
 	LIDT	(AX)
 
+	// apply BSP memory types, when configured
+	CALL	·apply_memory_types(SB)
+
+	// apply processor index to TSC_AUX, when enabled (see CurrentCPU)
+	CMPL	·tscAux(SB), $0
+	JE	apply_page_protection
+
+	MOVL	$(const_LAPIC_ID), AX
+	MOVL	(AX), AX
+	SHRL	$24, AX
+	ANDL	$0xf, AX
+	MOVL	$0, DX
+	MOVL	$(const_MSR_TSC_AUX), CX
+	WRMSR
+
+apply_page_protection:
+	// apply BSP page protection, when enabled (see CPU.Map)
+	CMPL	·pageProtection(SB), $0
+	JE	apply_cr4_protection
+	CALL	·enable_page_protection(SB)
+
+apply_cr4_protection:
+	// apply BSP SMEP/SMAP/UMIP, when enabled (see CPU.initProtection)
+	MOVL	·cr4Protection(SB), BX
+	CMPL	BX, $0
+	JE	apply_tss
+	MOVQ	CR4, AX
+	ORQ	BX, AX
+	MOVQ	AX, CR4
+
+apply_tss:
+	// apply extended GDT and TSS, when present (see CPU.initTSS)
+	MOVQ	$·gdtr(SB), AX
+	CMPW	(AX), $0
+	JE	restore_gdt
+
+	// select TSS descriptor by LAPIC ID
+	MOVL	$(const_LAPIC_ID), BX
+	MOVL	(BX), BX
+	SHRL	$24, BX
+	ANDL	$0xf, BX
+	CMPL	BX, ·tssCount(SB)
+	JAE	restore_gdt
+
+	LGDT	(AX)
+	SHLL	$4, BX
+	ADDL	$(const_tssSelector), BX
+	LTR	BX
+
+restore_gdt:
 	// restore GDT limits for next ·apinit
 	MOVQ	$(const_gdtAddress), AX
 	ADDQ	$0x08, AX
@@ -179,6 +229,15 @@ wait:
 	MOVL	$(const_LAPIC_SVR), AX
 	MOVL	$(1<<const_SVR_ENABLE), (AX)	// set SVR_ENABLE
 
+	// run per-AP initialization, once when set (see CPU.APInit)
+	PUSHQ	R12
+	PUSHQ	R13
+	PUSHQ	g
+	CALL	·runAPInit(SB)
+	POPQ	g
+	POPQ	R13
+	POPQ	R12
+
 	// call task target
 	STI
 	CALL	R12
@@ -0,0 +1,282 @@
+// x86-64 processor support
+// https://github.com/karlo195/tamago
+//
+// Copyright (c) The TamaGo Authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+package amd64
+
+import (
+	"errors"
+
+	"github.com/karlo195/tamago/bits"
+	"github.com/karlo195/tamago/internal/reg"
+)
+
+// Thermal and power management
+//
+// (Intel® 64 and IA-32 Architectures Software Developer’s Manual
+// Volume 3B - 16.8 Thermal Monitoring and Protection).
+const (
+	CPUID_THERMAL = 0x06
+	// CPUID_THERMAL EAX bits
+	THERMAL_DTS = 0
+	THERMAL_PTM = 6
+	// CPUID_THERMAL ECX bits
+	THERMAL_HCF = 0
+
+	MSR_MPERF = 0xe7
+	MSR_APERF = 0xe8
+
+	MSR_THERM_STATUS         = 0x19c
+	MSR_PACKAGE_THERM_STATUS = 0x1b1
+	THERM_STATUS_VALID       = 31
+	THERM_STATUS_READOUT     = 16
+
+	MSR_TEMPERATURE_TARGET = 0x1a2
+	TEMPERATURE_TARGET     = 16
+)
+
+// Running Average Power Limit (RAPL) energy counters
+//
+// (Intel® 64 and IA-32 Architectures Software Developer’s Manual
+// Volume 3B - 16.10 Platform Specific Power Management Support).
+const (
+	MSR_RAPL_POWER_UNIT    = 0x606
+	RAPL_POWER_UNIT_ENERGY = 8
+
+	MSR_PKG_ENERGY_STATUS = 0x611
+	MSR_PP0_ENERGY_STATUS = 0x639
+)
+
+// AMD Running Average Power Limit (RAPL) energy counters
+//
+// (Open-Source Register Reference For AMD Family 17h Processors
+// Models 00h-2Fh - Rev 3.03 - 2.1.14.1 Running Average Power Limit).
+const (
+	APM_RAPL = 14
+
+	MSR_AMD_RAPL_POWER_UNIT   = 0xc0010299
+	MSR_AMD_CORE_ENERGY_STAT  = 0xc001029a
+	MSR_AMD_PKG_ENERGY_STATUS = 0xc001029b
+)
+
+// Energy domains
+const (
+	// EnergyPackage represents the whole processor package.
+	EnergyPackage = iota
+	// EnergyCore represents the processor cores (Intel power plane 0 or
+	// AMD current core).
+	EnergyCore
+)
+
+const (
+	// default junction temperature limit, in degrees Celsius
+	defaultTjMax = 100
+
+	// first Intel family 6 models supporting MSR_TEMPERATURE_TARGET
+	// (Nehalem) and RAPL (Sandy Bridge)
+	modelNehalem     = 0x1a
+	modelSandyBridge = 0x2a
+)
+
+// Activity represents a snapshot of processor activity counters, the
+// difference between two snapshots allows to derive utilization and effective
+// frequency over the sampled interval (see [Activity.Utilization]).
+type Activity struct {
+	// TSC is the Time Stamp Counter value.
+	TSC uint64
+	// MPERF is the maximum frequency clock count, incremented at TSC
+	// rate only when the processor is active (C0 state).
+	MPERF uint64
+	// APERF is the actual frequency clock count, incremented at the
+	// actual processor frequency only when the processor is active.
+	APERF uint64
+}
+
+// Utilization returns the fraction (0.0 to 1.0) of time spent by the
+// processor in active state between the argument previous snapshot and the
+// current one.
+func (a Activity) Utilization(prev Activity) float64 {
+	tsc := a.TSC - prev.TSC
+
+	if tsc == 0 {
+		return 0
+	}
+
+	return min(float64(a.MPERF-prev.MPERF)/float64(tsc), 1)
+}
+
+// Frequency returns the effective processor frequency, while active, between
+// the argument previous snapshot and the current one, relative to the
+// argument nominal frequency (e.g. [CPU.Freq]).
+func (a Activity) Frequency(prev Activity, nominal uint32) (hz uint64) {
+	mperf := a.MPERF - prev.MPERF
+
+	if mperf == 0 {
+		return
+	}
+
+	return uint64(float64(nominal) * float64(a.APERF-prev.APERF) / float64(mperf))
+}
+
+// ReadMSR returns the value of a Model Specific Register.
+//
+// Access to an unimplemented register raises a general protection exception
+// (#GP), therefore it should be preceded by feature detection (see
+// [CPU.CPUID]).
+func (cpu *CPU) ReadMSR(addr uint32) (val uint64) {
+	return reg.Msr64(addr)
+}
+
+// WriteMSR sets the value of a Model Specific Register.
+//
+// Access to an unimplemented register, or setting of reserved bits, raises a
+// general protection exception (#GP), therefore it should be preceded by
+// feature detection (see [CPU.CPUID]).
+func (cpu *CPU) WriteMSR(addr uint32, val uint64) {
+	reg.WriteMsr(addr, val)
+}
+
+func thermalFeatures() (eax uint32, ecx uint32) {
+	if maxLeaf, _, _, _ := cpuid(CPUID_VENDOR, 0); maxLeaf < CPUID_THERMAL {
+		return
+	}
+
+	eax, _, ecx, _ = cpuid(CPUID_THERMAL, 0)
+
+	return
+}
+
+func isIntel(minModel int) bool {
+	f := processorFeatures()
+	return f.Vendor == "GenuineIntel" && f.Family == 6 && f.Model >= minModel
+}
+
+// tjMax returns the junction temperature limit used as reference for digital
+// thermal sensor readouts.
+func (cpu *CPU) tjMax() int {
+	if !isIntel(modelNehalem) {
+		return defaultTjMax
+	}
+
+	val := reg.Msr64(MSR_TEMPERATURE_TARGET)
+
+	if t := int(bits.Get64(&val, TEMPERATURE_TARGET, 0xff)); t != 0 {
+		return t
+	}
+
+	return defaultTjMax
+}
+
+func readTemperature(msr uint32, tjMax int) (celsius int, err error) {
+	val := reg.Msr64(msr)
+
+	if !bits.IsSet64(&val, THERM_STATUS_VALID) {
+		return 0, errors.New("invalid temperature reading")
+	}
+
+	return tjMax - int(bits.Get64(&val, THERM_STATUS_READOUT, 0x7f)), nil
+}
+
+// Temperature returns the current core temperature, and when available the
+// package one, in degrees Celsius as reported by Intel digital thermal sensors.
+//
+// Thermal sensors are specific to each core, therefore the core temperature
+// is reported for the CPU executing this function. Digital thermal sensors
+// are typically not exposed to virtual machines.
+func (cpu *CPU) Temperature() (core int, pkg int, err error) {
+	eax, _ := thermalFeatures()
+
+	if !isIntel(0) || !bits.IsSet(&eax, THERMAL_DTS) {
+		return 0, 0, errors.New("digital thermal sensor unavailable")
+	}
+
+	tjMax := cpu.tjMax()
+
+	if core, err = readTemperature(MSR_THERM_STATUS, tjMax); err != nil {
+		return
+	}
+
+	if !bits.IsSet(&eax, THERMAL_PTM) {
+		return core, core, nil
+	}
+
+	pkg, err = readTemperature(MSR_PACKAGE_THERM_STATUS, tjMax)
+
+	return
+}
+
+// Energy returns the cumulative energy consumption, in Joules, for the
+// argument domain (EnergyPackage or EnergyCore) as reported by Running Average
+// Power Limit (RAPL) counters.
+//
+// Energy counters are 32-bit wide and wrap around, depending on consumption,
+// in the order of minutes. Average power can be derived by dividing the
+// difference of two readings by the elapsed time.
+//
+// On Intel processors RAPL support is assumed from Sandy Bridge onwards, as it
+// is not enumerated by CPUID, and only on bare metal as RAPL counters are
+// typically not exposed to virtual machines.
+func (cpu *CPU) Energy(domain int) (joules float64, err error) {
+	var unitMSR, counterMSR uint32
+	var apm uint32
+
+	if maxExtLeaf, _, _, _ := cpuid(CPUID_EXT_MAX, 0); maxExtLeaf >= CPUID_APM {
+		_, _, _, apm = cpuid(CPUID_APM, 0)
+	}
+
+	f := processorFeatures()
+
+	switch {
+	case f.Vendor == "AuthenticAMD" && bits.IsSet(&apm, APM_RAPL):
+		unitMSR = MSR_AMD_RAPL_POWER_UNIT
+
+		switch domain {
+		case EnergyPackage:
+			counterMSR = MSR_AMD_PKG_ENERGY_STATUS
+		case EnergyCore:
+			counterMSR = MSR_AMD_CORE_ENERGY_STAT
+		}
+	case isIntel(modelSandyBridge) && !f.Hypervisor:
+		unitMSR = MSR_RAPL_POWER_UNIT
+
+		switch domain {
+		case EnergyPackage:
+			counterMSR = MSR_PKG_ENERGY_STATUS
+		case EnergyCore:
+			counterMSR = MSR_PP0_ENERGY_STATUS
+		}
+	default:
+		return 0, errors.New("energy counters unavailable")
+	}
+
+	if counterMSR == 0 {
+		return 0, errors.New("invalid energy domain")
+	}
+
+	unit := reg.Msr64(unitMSR)
+	esu := bits.Get64(&unit, RAPL_POWER_UNIT_ENERGY, 0x1f)
+	count := reg.Msr64(counterMSR) & 0xffffffff
+
+	return float64(count) / float64(uint64(1)<<esu), nil
+}
+
+// Activity returns a snapshot of processor activity counters, used to derive
+// utilization and effective frequency (see [Activity.Utilization]).
+//
+// Activity counters are specific to each core, therefore the snapshot is
+// taken for the CPU executing this function.
+func (cpu *CPU) Activity() (a Activity, err error) {
+	if _, ecx := thermalFeatures(); !bits.IsSet(&ecx, THERMAL_HCF) {
+		return a, errors.New("activity counters unavailable")
+	}
+
+	a.TSC = read_tsc()
+	a.MPERF = reg.Msr64(MSR_MPERF)
+	a.APERF = reg.Msr64(MSR_APERF)
+
+	return
+}
@@ -22,31 +22,51 @@ const refFreq uint32 = 1e9
 func read_tsc() uint64
 func write_tsc_deadline(cnt uint64)
 
+// detectCoreFrequency discovers the TSC frequency, in order of preference,
+// from hypervisor timing information, CPUID enumeration and, as a last resort,
+// measurement against the HPET or PIT.
 func (cpu *CPU) detectCoreFrequency() (freq uint32) {
-	if den, num, nominalFreq, _ := cpuid(CPUID_TSC_CCC, 0); den != 0 {
-		if nominalFreq == 0 {
-			baseFreq, _, _, _ := cpuid(CPUID_CPU_FRQ, 0)
-			nominalFreq = uint32(uint64(baseFreq) * 1e6 * uint64(den) / uint64(num))
+	maxLeaf, _, _, _ := cpuid(CPUID_VENDOR, 0)
+
+	if maxLeaf >= CPUID_TSC_CCC {
+		if den, num, nominalFreq, _ := cpuid(CPUID_TSC_CCC, 0); den != 0 && num != 0 {
+			if nominalFreq == 0 && maxLeaf >= CPUID_CPU_FRQ {
+				baseFreq, _, _, _ := cpuid(CPUID_CPU_FRQ, 0)
+				nominalFreq = uint32(uint64(baseFreq) * 1e6 * uint64(den) / uint64(num))
+			}
+
+			cpu.freq = uint32((uint64(num) * uint64(nominalFreq)) / uint64(den))
 		}
+	}
 
-		cpu.freq = uint32((uint64(num) * uint64(nominalFreq)) / uint64(den))
+	if cpu.freq == 0 && maxLeaf >= CPUID_CPU_FRQ {
+		// processor base frequency, matching the TSC nominal one
+		if baseFreq, _, _, _ := cpuid(CPUID_CPU_FRQ, 0); baseFreq != 0 {
+			cpu.freq = (baseFreq & 0xffff) * 1e6
+		}
 	}
 
-	if cpu.features.KVM {
-		if khz, _, _, _ := cpuid(KVM_CPUID_TSC_KHZ, 0); khz != 0 {
-			cpu.freq = khz * 1000
-		} else {
-			_, nsecA, tscA := kvmclock.Pairing()
-			_, nsecB, tscB := kvmclock.Pairing()
-
-			if den := uint64(nsecB - nsecA); den != 0 {
-				cpu.freq = uint32(((tscB - tscA) * uint64(refFreq)) / den)
+	if cpu.features.Hypervisor {
+		// generic hypervisor timing leaf (e.g. KVM, VMware)
+		if maxHVLeaf, _, _, _ := cpuid(KVM_CPUID_SIGNATURE, 0); maxHVLeaf >= KVM_CPUID_TSC_KHZ {
+			if khz, _, _, _ := cpuid(KVM_CPUID_TSC_KHZ, 0); khz != 0 {
+				cpu.freq = khz * 1000
+				return cpu.freq
 			}
 		}
 	}
 
+	if cpu.features.KVM {
+		_, nsecA, tscA := kvmclock.Pairing()
+		_, nsecB, tscB := kvmclock.Pairing()
+
+		if den := uint64(nsecB - nsecA); den != 0 {
+			cpu.freq = uint32(((tscB - tscA) * uint64(refFreq)) / den)
+		}
+	}
+
 	if cpu.freq != 0 {
-		return
+		return cpu.freq
 	}
 
 	if _, _, ecx, _ := cpuid(CPUID_VENDOR, 0); ecx == CPUID_VENDOR_ECX_AMD {
@@ -63,12 +83,20 @@ func (cpu *CPU) detectCoreFrequency() (freq uint32) {
 		}
 	}
 
+	if cpu.freq == 0 {
+		cpu.freq = measureHPET()
+	}
+
+	if cpu.freq == 0 {
+		cpu.freq = measurePIT()
+	}
+
 	if cpu.freq == 0 {
 		print("WARNING: TSC frequency is unavailable\n")
 		return 1
 	}
 
-	return
+	return cpu.freq
 }
 
 func (cpu *CPU) initTimers() {
@@ -87,7 +115,14 @@ func (cpu *CPU) Counter() uint64 {
 }
 
 // GetTime returns the system time in nanoseconds.
+//
+// Before [CPU.Init] calibration, with an unset TimerMultiplier, the system
+// time is the raw TSC value.
 func (cpu *CPU) GetTime() int64 {
+	if cpu.TimerMultiplier == 0 {
+		return int64(cpu.Counter()) + cpu.TimerOffset
+	}
+
 	return int64(float64(cpu.Counter())*cpu.TimerMultiplier) + cpu.TimerOffset
 }
 
@@ -0,0 +1,162 @@
+// x86-64 processor support
+// https://github.com/karlo195/tamago
+//
+// Copyright (c) The TamaGo Authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+package amd64
+
+import (
+	"bytes"
+	"encoding/binary"
+	"unsafe"
+)
+
+// Interrupt Stack Table (IST) indices
+const (
+	IST_DOUBLE_FAULT  = 1
+	IST_NMI           = 2
+	IST_MACHINE_CHECK = 3
+	IST_EXCEPTION     = 4
+)
+
+const (
+	// GDT code and data descriptors (see init.s)
+	gdtCode = 0x00209a0000000000
+	gdtData = 0x0000920000000000
+	// GDT user (DPL 3) data and 64-bit code descriptors
+	gdtUserData = 0x0000f20000000000
+	gdtUserCode = 0x0020fa0000000000
+
+	// TSS size
+	tssSize = 0x68
+	// TSS descriptor selector for the first processor
+	tssSelector = 0x30
+	// TSS descriptor size
+	tssDescriptorSize = 16
+	// Interrupt Stack Table stack size
+	istStackSize = 0x4000 // 16 kB
+)
+
+var (
+	// extended GDT pseudo-descriptor, applied to APs in ·apstart
+	gdtr [10]byte
+	// number of TSS descriptors in the extended GDT
+	tssCount uint32
+
+	// IST index for each exception vector
+	istVectors = map[int]uint8{}
+
+	// extended GDT, TSS and IST stacks
+	gdt       []byte
+	tssBuf    [][]byte
+	istStacks [][]byte
+)
+
+// defined in tss.s
+func load_gdt(gdtr uintptr)
+func load_tr(sel uint16)
+
+// TaskStateSegment represents a 64-bit Task State Segment (TSS)
+// (AMD64 Architecture Programmer’s Manual
+// Volume 2 - 12.2.5 64-Bit Task State Segment).
+type TaskStateSegment struct {
+	_    uint32
+	RSP  [3]uint64
+	_    uint64
+	IST  [7]uint64
+	_    uint64
+	_    uint16
+	IOPB uint16
+}
+
+// Bytes converts the TSS structure to byte array format.
+func (tss *TaskStateSegment) Bytes() []byte {
+	buf := new(bytes.Buffer)
+	binary.Write(buf, binary.LittleEndian, tss)
+	return buf.Bytes()
+}
+
+func address(buf []byte) uint64 {
+	return uint64(uintptr(unsafe.Pointer(&buf[0])))
+}
+
+// tssDescriptor returns a 64-bit available TSS system segment descriptor
+// (AMD64 Architecture Programmer’s Manual
+// Volume 2 - 4.8.3 System Descriptors).
+func tssDescriptor(base uint64, limit uint32) (desc []byte) {
+	desc = make([]byte, tssDescriptorSize)
+
+	binary.LittleEndian.PutUint16(desc[0:], uint16(limit))
+	binary.LittleEndian.PutUint16(desc[2:], uint16(base))
+	desc[4] = byte(base >> 16)
+	desc[5] = 0b10001001 // P, 64-bit TSS (available)
+	desc[6] = byte(limit>>16) & 0xf
+	desc[7] = byte(base >> 24)
+	binary.LittleEndian.PutUint32(desc[8:], uint32(base>>32))
+
+	return
+}
+
+// initTSS creates a Task State Segment for each processor, with dedicated
+// Interrupt Stack Table entries for double fault, NMI, machine check and all
+// remaining exceptions, and loads it on the BSP.
+//
+// The extended GDT also holds user mode segments, laid out as required by
+// SYSRET (see [CPU.RunUser]), and each TSS a privilege level 0 stack for
+// exceptions raised in user mode.
+//
+// The IST allows such exceptions to be handled on a known good stack,
+// therefore stack overflows and nested faults produce a diagnostic rather
+// than a triple fault.
+func (cpu *CPU) initTSS() {
+	if gdt != nil || len(cpu.aps) > 0 {
+		// APs have been already started without a TSS
+		return
+	}
+
+	n := NumCPU()
+
+	gdt = make([]byte, tssSelector+n*tssDescriptorSize)
+	binary.LittleEndian.PutUint64(gdt[0x08:], gdtCode)
+	binary.LittleEndian.PutUint64(gdt[0x10:], gdtData)
+	binary.LittleEndian.PutUint64(gdt[USER_SS&^3:], gdtUserData)
+	binary.LittleEndian.PutUint64(gdt[USER_CS&^3:], gdtUserCode)
+
+	for i := 0; i < n; i++ {
+		tss := &TaskStateSegment{
+			IOPB: tssSize,
+		}
+
+		for _, index := range []int{IST_DOUBLE_FAULT, IST_NMI, IST_MACHINE_CHECK, IST_EXCEPTION} {
+			stack := make([]byte, istStackSize)
+			istStacks = append(istStacks, stack)
+			// stacks grow downwards, keep 16 byte alignment
+			tss.IST[index-1] = (address(stack) + istStackSize) &^ 0xf
+		}
+
+		// privilege level 0 stack for user mode exceptions
+		stack := make([]byte, istStackSize)
+		istStacks = append(istStacks, stack)
+		tss.RSP[0] = (address(stack) + istStackSize) &^ 0xf
+
+		buf := tss.Bytes()
+		tssBuf = append(tssBuf, buf)
+
+		desc := tssDescriptor(address(buf), tssSize-1)
+		copy(gdt[tssSelector+i*tssDescriptorSize:], desc)
+	}
+
+	binary.LittleEndian.PutUint16(gdtr[0:], uint16(len(gdt)-1))
+	binary.LittleEndian.PutUint64(gdtr[2:], address(gdt))
+	tssCount = uint32(n)
+
+	load_gdt(uintptr(unsafe.Pointer(&gdtr[0])))
+	load_tr(uint16(tssSelector + cpu.ID()*tssDescriptorSize))
+
+	istVectors[DoubleFault] = IST_DOUBLE_FAULT
+	istVectors[NMI] = IST_NMI
+	istVectors[MachineCheck] = IST_MACHINE_CHECK
+}
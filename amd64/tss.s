@@ -0,0 +1,19 @@
+// x86-64 processor support
+// https://github.com/usbarmory/tamago
+//
+// Copyright (c) The TamaGo Authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+// func load_gdt(gdtr uintptr)
+TEXT ·load_gdt(SB),$0-8
+	MOVQ	gdtr+0(FP), AX
+	LGDT	(AX)
+	RET
+
+// func load_tr(sel uint16)
+TEXT ·load_tr(SB),$0-2
+	MOVW	sel+0(FP), AX
+	LTR	AX
+	RET
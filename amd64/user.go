@@ -0,0 +1,253 @@
+// x86-64 processor support
+// https://github.com/karlo195/tamago
+//
+// Copyright (c) The TamaGo Authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+package amd64
+
+import (
+	"errors"
+	"runtime"
+	"sync"
+
+	"github.com/karlo195/tamago/internal/reg"
+)
+
+// Segment selectors
+// (see CPU.initTSS).
+const (
+	KERNEL_CS = 0x08
+	KERNEL_SS = 0x10
+	USER_SS   = 0x20 | 3
+	USER_CS   = 0x28 | 3
+)
+
+// System call MSRs
+// (AMD64 Architecture Programmer’s Manual
+// Volume 2 - 6.1.1 SYSCALL and SYSRET).
+const (
+	MSR_EFER    = 0xc0000080
+	MSR_STAR    = 0xc0000081
+	MSR_LSTAR   = 0xc0000082
+	MSR_FMASK   = 0xc0000084
+	MSR_FS_BASE = 0xc0000100
+
+	STAR_SYSCALL_CS = 32
+	STAR_SYSRET_CS  = 48
+
+	EFER_SCE = 0
+)
+
+// RFLAGS reserved bit, always set
+const rflagsFixed = 1 << 1
+
+// user mode exit reasons (see ·userExit)
+const (
+	userExitSyscall   = 0
+	userExitException = 1
+)
+
+// UserContext represents the processor state of code executing in user mode
+// (ring 3), the system call number is held in AX and its arguments in DI, SI,
+// DX, R10, R8 and R9.
+type UserContext struct {
+	// General purpose registers
+	AX  uint64
+	BX  uint64
+	CX  uint64
+	DX  uint64
+	SI  uint64
+	DI  uint64
+	BP  uint64
+	R8  uint64
+	R9  uint64
+	R10 uint64
+	R11 uint64
+	R12 uint64
+	R13 uint64
+	R14 uint64
+	R15 uint64
+
+	// Instruction and stack pointers
+	RIP uint64
+	RSP uint64
+	// Flags register
+	RFLAGS uint64
+}
+
+// SyscallHandler represents a user mode system call handler, it returns
+// whether user mode execution must resume, with the (optionally modified)
+// context, or terminate.
+//
+// The handler runs in the goroutine invoking [CPU.RunUser], therefore,
+// unlike exception handlers, it is not restricted in its use of the Go
+// runtime.
+type SyscallHandler func(ctx *UserContext) (resume bool)
+
+// User represents a user mode (ring 3) execution environment.
+type User struct {
+	// Entry is the user mode code entry point.
+	Entry uint64
+	// Stack is the user mode stack pointer (top of stack).
+	Stack uint64
+
+	// Syscall is the system call handler, when nil any system call
+	// terminates user mode execution.
+	Syscall SyscallHandler
+
+	// Context is the user mode processor state, updated on each system
+	// call or exception.
+	Context UserContext
+
+	// Exception is set when user mode execution is terminated by a
+	// processor exception.
+	Exception *ExceptionFrame
+}
+
+var (
+	userLock sync.Mutex
+
+	// read and written in user.s
+	userContext     *UserContext
+	userKernelSP    uint64
+	userKernelFlags uint64
+	userKernelFS    uint64
+	userRSP         uint64
+
+	// exception state of the last user mode termination
+	userFault ExceptionFrame
+)
+
+// defined in user.s
+func run_user(ctx *UserContext) (reason uint64)
+func syscallEntryAddr() uintptr
+func userExitAddr() uintptr
+
+// initSyscall enables SYSCALL/SYSRET on the current processor.
+func initSyscall() {
+	reg.WriteMsr(MSR_EFER, reg.Msr64(MSR_EFER)|1<<EFER_SCE)
+
+	// SYSRET loads CS from STAR[63:48]+16 and SS from STAR[63:48]+8
+	star := uint64(KERNEL_CS)<<STAR_SYSCALL_CS | uint64(USER_SS-8)<<STAR_SYSRET_CS
+	reg.WriteMsr(MSR_STAR, star)
+
+	reg.WriteMsr(MSR_LSTAR, uint64(syscallEntryAddr()))
+	reg.WriteMsr(MSR_FMASK, 1<<RFLAGS_IF|1<<RFLAGS_TF|1<<RFLAGS_DF|1<<RFLAGS_AC)
+}
+
+// userException terminates user mode execution on an exception raised in
+// ring 3, it is called by dispatchException which resumes execution at
+// ·userExit.
+func userException() {
+	ctx := userContext
+
+	ctx.AX = exceptionFrame.AX
+	ctx.BX = exceptionFrame.BX
+	ctx.CX = exceptionFrame.CX
+	ctx.DX = exceptionFrame.DX
+	ctx.SI = exceptionFrame.SI
+	ctx.DI = exceptionFrame.DI
+	ctx.BP = exceptionFrame.BP
+	ctx.R8 = exceptionFrame.R8
+	ctx.R9 = exceptionFrame.R9
+	ctx.R10 = exceptionFrame.R10
+	ctx.R11 = exceptionFrame.R11
+	ctx.R12 = exceptionFrame.R12
+	ctx.R13 = exceptionFrame.R13
+	ctx.R14 = exceptionFrame.R14
+	ctx.R15 = exceptionFrame.R15
+	ctx.RIP = exceptionFrame.RIP
+	ctx.RSP = exceptionFrame.RSP
+	ctx.RFLAGS = exceptionFrame.RFLAGS
+
+	userFault = exceptionFrame
+
+	// return to the kernel, with the exit reason in AX
+	exceptionFrame.AX = userExitException
+	exceptionFrame.RIP = uint64(userExitAddr())
+	exceptionFrame.CS = KERNEL_CS
+	exceptionFrame.SS = KERNEL_SS
+	exceptionFrame.RSP = userKernelSP
+	exceptionFrame.RFLAGS = rflagsFixed
+}
+
+// RunUser executes code in user mode (ring 3), on the current processor,
+// until its termination, which happens when the system call handler returns
+// false or on any processor exception.
+//
+// Exceptions must be enabled (see [CPU.EnableExceptions]) as user mode
+// exceptions are raised on a dedicated privilege level 0 stack, the Go TLS
+// base is restored before handling them on the system stack (g0).
+//
+// User mode code can only access pages with the PTE_US attribute, which must
+// be set by the caller on its code, data and stack (see [CPU.SetAttribute]),
+// system calls (SYSCALL instruction) are its only interface with the kernel.
+// When SMAP is enabled such pages must not be part of the Go runtime memory
+// and can only be accessed by the kernel through [UserAccess].
+//
+// User mode code runs with interrupts disabled, therefore it cannot be
+// preempted and it must yield to the kernel with system calls. Only one user
+// mode context can execute at any given time.
+func (cpu *CPU) RunUser(u *User) (err error) {
+	if gdt == nil {
+		return errors.New("exceptions are not enabled")
+	}
+
+	if u.Entry == 0 || u.Stack == 0 {
+		return errors.New("invalid user mode entry point or stack")
+	}
+
+	userLock.Lock()
+	defer userLock.Unlock()
+
+	// MSRs are specific to each core
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	initSyscall()
+
+	// handle #UD as exception (see CPU.SetExceptionHandler)
+	if isrOverride[InvalidOpcode] == 0 {
+		udISR = uint64(irqHandlerAddr) + (InvalidOpcode+1)*callSize
+		isrOverride[InvalidOpcode] = handleInvalidOpcodeAddr()
+		setIDT(InvalidOpcode, InvalidOpcode)
+
+		defer func() {
+			if exceptionHandlers[InvalidOpcode] == nil {
+				isrOverride[InvalidOpcode] = 0
+				setIDT(InvalidOpcode, InvalidOpcode)
+			}
+		}()
+	}
+
+	u.Exception = nil
+	u.Context = UserContext{
+		RIP: u.Entry,
+		RSP: u.Stack,
+	}
+
+	userContext = &u.Context
+	defer func() { userContext = nil }()
+
+	for {
+		// interrupts are never enabled in user mode
+		u.Context.RFLAGS = u.Context.RFLAGS&^(1<<RFLAGS_IF|0b11<<RFLAGS_IOPL) | rflagsFixed
+
+		switch run_user(&u.Context) {
+		case userExitSyscall:
+			if u.Syscall != nil && u.Syscall(&u.Context) {
+				continue
+			}
+
+			return
+		default:
+			frame := userFault
+			u.Exception = &frame
+
+			return errors.New("user mode exception " + VectorName(frame.Vector))
+		}
+	}
+}
@@ -0,0 +1,120 @@
+// x86-64 processor support
+// https://github.com/usbarmory/tamago
+//
+// Copyright (c) The TamaGo Authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+#include "go_asm.h"
+#include "textflag.h"
+
+// func run_user(ctx *UserContext) (reason uint64)
+TEXT ·run_user(SB),NOSPLIT,$0-16
+	// save kernel state, restored by ·userExit
+	PUSHFQ
+	POPQ	AX
+	MOVQ	AX, ·userKernelFlags(SB)
+	MOVQ	SP, ·userKernelSP(SB)
+	PUSHQ	BP
+
+	// save Go TLS base, which user mode might alter
+	MOVL	$(const_MSR_FS_BASE), CX
+	RDMSR
+	MOVL	AX, ·userKernelFS+0(SB)
+	MOVL	DX, ·userKernelFS+4(SB)
+
+	MOVQ	ctx+0(FP), AX
+
+	// prevent interrupts while on the user stack
+	CLI
+
+	// restore user mode registers
+	MOVQ	UserContext_BX(AX), BX
+	MOVQ	UserContext_DX(AX), DX
+	MOVQ	UserContext_SI(AX), SI
+	MOVQ	UserContext_DI(AX), DI
+	MOVQ	UserContext_BP(AX), BP
+	MOVQ	UserContext_R8(AX), R8
+	MOVQ	UserContext_R9(AX), R9
+	MOVQ	UserContext_R10(AX), R10
+	MOVQ	UserContext_R12(AX), R12
+	MOVQ	UserContext_R13(AX), R13
+	MOVQ	UserContext_R14(AX), R14
+	MOVQ	UserContext_R15(AX), R15
+
+	// SYSRET loads RIP from RCX and RFLAGS from R11
+	MOVQ	UserContext_RIP(AX), CX
+	MOVQ	UserContext_RFLAGS(AX), R11
+	MOVQ	UserContext_RSP(AX), SP
+	MOVQ	UserContext_AX(AX), AX
+
+	// SYSRETQ (64-bit operand size)
+	BYTE	$0x48
+	BYTE	$0x0f
+	BYTE	$0x07
+
+// System call entry point (MSR_LSTAR), invoked with interrupts disabled
+// (MSR_FMASK) and the user mode stack.
+TEXT ·syscallEntry(SB),NOSPLIT|NOFRAME,$0
+	MOVQ	SP, ·userRSP(SB)
+	MOVQ	·userContext(SB), SP
+
+	// save user mode registers
+	MOVQ	AX, UserContext_AX(SP)
+	MOVQ	BX, UserContext_BX(SP)
+	MOVQ	DX, UserContext_DX(SP)
+	MOVQ	SI, UserContext_SI(SP)
+	MOVQ	DI, UserContext_DI(SP)
+	MOVQ	BP, UserContext_BP(SP)
+	MOVQ	R8, UserContext_R8(SP)
+	MOVQ	R9, UserContext_R9(SP)
+	MOVQ	R10, UserContext_R10(SP)
+	MOVQ	R12, UserContext_R12(SP)
+	MOVQ	R13, UserContext_R13(SP)
+	MOVQ	R14, UserContext_R14(SP)
+	MOVQ	R15, UserContext_R15(SP)
+
+	// SYSCALL saves RIP in RCX and RFLAGS in R11
+	MOVQ	CX, UserContext_RIP(SP)
+	MOVQ	R11, UserContext_RFLAGS(SP)
+	MOVQ	·userRSP(SB), AX
+	MOVQ	AX, UserContext_RSP(SP)
+
+	MOVQ	$(const_userExitSyscall), AX
+	JMP	·userExit(SB)
+
+// User mode exit, with the exit reason in AX, reached from ·syscallEntry or
+// on exceptions (see userException).
+TEXT ·userExit(SB),NOSPLIT|NOFRAME,$0
+	// restore kernel stack and frame pointer, saved below it by ·run_user
+	MOVQ	·userKernelSP(SB), SP
+	ADJSP	$8
+	POPQ	BP
+
+	// set ·run_user return value
+	MOVQ	AX, 16(SP)
+
+	// restore Go TLS base
+	MOVL	$(const_MSR_FS_BASE), CX
+	MOVL	·userKernelFS+0(SB), AX
+	MOVL	·userKernelFS+4(SB), DX
+	WRMSR
+
+	MOVQ	·userKernelFlags(SB), AX
+	PUSHQ	AX
+	POPFQ
+
+	RET
+
+// func syscallEntryAddr() uintptr
+TEXT ·syscallEntryAddr(SB),$0-8
+	MOVQ	$·syscallEntry(SB), AX
+	MOVQ	AX, ret+0(FP)
+	RET
+
+// func userExitAddr() uintptr
+TEXT ·userExitAddr(SB),$0-8
+	MOVQ	$·userExit(SB), AX
+	MOVQ	AX, ret+0(FP)
+	RET
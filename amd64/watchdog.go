@@ -0,0 +1,131 @@
+// x86-64 processor support
+// https://github.com/karlo195/tamago
+//
+// Copyright (c) The TamaGo Authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+package amd64
+
+import (
+	"errors"
+	"math"
+	"sync/atomic"
+	"time"
+
+	"github.com/karlo195/tamago/amd64/lapic"
+	"github.com/karlo195/tamago/internal/reg"
+)
+
+// WatchdogPeriod represents the NMI watchdog sampling period, in unhalted
+// core cycles time.
+const WatchdogPeriod = 100 * time.Millisecond
+
+var (
+	// NMI watchdog state, read by ·handleNMI
+	watchdogCounter   uint32 // counter MSR, 0 when disabled
+	watchdogAPIC      uint32 // LAPIC ID register of the monitored core
+	watchdogReload    uint64
+	watchdogMSB       uint64
+	watchdogOverflow  uint64
+	watchdogThreshold uint32
+	watchdogStalls    uint32
+	watchdogHeartbeat uint64
+	watchdogLast      uint64
+	watchdogISR       uint64
+
+	watchdog     *Counter
+	watchdogStop chan struct{}
+)
+
+// EnableWatchdog enables the NMI watchdog, which panics with a register dump
+// (see [DefaultExceptionHandler]) when the Go scheduler appears hung for the
+// argument timeout.
+//
+// A performance monitoring counter is programmed to deliver periodic NMIs,
+// every [WatchdogPeriod] of unhalted core cycles, which verify that a
+// heartbeat goroutine is still being scheduled. As NMIs cannot be masked,
+// lockups within interrupt-disabled code are also detected.
+//
+// Performance monitoring counters are specific to each core, therefore only
+// the CPU executing this function is monitored.
+func (cpu *CPU) EnableWatchdog(timeout time.Duration) (err error) {
+	if watchdogCounter != 0 {
+		return errors.New("watchdog already enabled")
+	}
+
+	if cpu.freq <= 1 {
+		return errors.New("core frequency is unavailable")
+	}
+
+	if timeout < WatchdogPeriod {
+		return errors.New("invalid timeout")
+	}
+
+	c, err := cpu.NewCounter(EventCycles)
+
+	if err != nil {
+		return
+	}
+
+	// Intel general-purpose counters writes are sign extended from bit 31
+	period := min(uint64(cpu.freq)*uint64(WatchdogPeriod)/uint64(time.Second), math.MaxInt32)
+
+	watchdogReload = -period & pmc.mask
+	watchdogMSB = (pmc.mask + 1) >> 1
+	watchdogOverflow = c.overflowStatus()
+	watchdogThreshold = uint32(timeout / WatchdogPeriod)
+	watchdogStalls = 0
+
+	// NMI exception handling on scheduler hang (see irqHandler)
+	setIDT(NMI, NMI)
+	watchdogISR = uint64(irqHandlerAddr) + (NMI+1)*callSize
+
+	reg.WriteMsr(c.counterRegister(), watchdogReload)
+	c.setInterrupt(true)
+
+	reg.Write(LAPIC_LVT_PERF, ICR_DLV_NMI)
+
+	watchdog = c
+	watchdogStop = make(chan struct{})
+	watchdogLast = atomic.LoadUint64(&watchdogHeartbeat)
+	watchdogAPIC = reg.Read(LAPIC_ID)
+	watchdogCounter = c.counterRegister()
+
+	go heartbeat(watchdogStop)
+
+	c.Start()
+
+	return
+}
+
+// DisableWatchdog disables the NMI watchdog.
+func (cpu *CPU) DisableWatchdog() {
+	if watchdogCounter == 0 {
+		return
+	}
+
+	reg.Set(LAPIC_LVT_PERF, lapic.LVT_MASK)
+	watchdog.setInterrupt(false)
+	watchdog.Release()
+
+	watchdogCounter = 0
+	watchdog = nil
+
+	close(watchdogStop)
+}
+
+func heartbeat(stop chan struct{}) {
+	t := time.NewTicker(WatchdogPeriod / 2)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-t.C:
+			atomic.AddUint64(&watchdogHeartbeat, 1)
+		}
+	}
+}
@@ -0,0 +1,158 @@
+// ARM PrimeCell UART (PL011) driver
+// https://github.com/karlo195/tamago
+//
+// IP: ARM PrimeCell UART (PL011) r1p5
+//
+// Copyright (c) The TamaGo Authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+// Package pl011 implements a driver for the ARM PrimeCell UART (PL011).
+//
+// The driver is based on the following reference specifications:
+//   - ARM DDI 0183G - PrimeCell UART (PL011) Technical Reference Manual r1p5
+//
+// The PL011 is found at fixed addresses on most virtual machines (e.g.
+// 0x09000000 on QEMU `virt`), which makes it suitable as an early debug
+// console before full driver initialization.
+//
+// This package is only meant to be used with `GOOS=tamago` as supported by the
+// TamaGo framework for bare metal Go, see https://github.com/karlo195/tamago.
+package pl011
+
+import (
+	"github.com/karlo195/tamago/internal/reg"
+)
+
+// PL011 registers
+// (Table 3-1 UART register summary, DDI 0183G).
+const (
+	DEFAULT_BAUDRATE = 115200
+
+	UARTDR = 0x00
+
+	UARTFR      = 0x18
+	UARTFR_TXFE = 7
+	UARTFR_TXFF = 5
+	UARTFR_RXFE = 4
+	UARTFR_BUSY = 3
+
+	UARTIBRD = 0x24
+	UARTFBRD = 0x28
+
+	UARTLCR_H      = 0x2c
+	UARTLCR_H_WLEN = 5
+	UARTLCR_H_FEN  = 4
+
+	UARTCR        = 0x30
+	UARTCR_RXE    = 9
+	UARTCR_TXE    = 8
+	UARTCR_UARTEN = 0
+
+	UARTIMSC = 0x38
+	UARTICR  = 0x44
+)
+
+// PL011 represents a serial port instance.
+type PL011 struct {
+	// Controller index
+	Index int
+	// Base register
+	Base uint32
+	// Clock is the UART reference clock frequency in Hz, when zero the
+	// baud rate divisors are not modified (e.g. early console setup
+	// relying on firmware or hypervisor configuration).
+	Clock uint32
+	// Baudrate, DEFAULT_BAUDRATE is used when zero
+	Baudrate uint32
+
+	// control registers
+	dr uint32
+	fr uint32
+}
+
+// Init initializes and enables the UART for 8N1 operation with FIFOs enabled
+// and interrupts masked.
+func (hw *PL011) Init() {
+	if hw.Base == 0 {
+		panic("invalid PL011 controller instance")
+	}
+
+	if hw.Baudrate == 0 {
+		hw.Baudrate = DEFAULT_BAUDRATE
+	}
+
+	hw.dr = hw.Base + UARTDR
+	hw.fr = hw.Base + UARTFR
+
+	// disable UART
+	reg.Write(hw.Base+UARTCR, 0)
+
+	// mask and clear all interrupts
+	reg.Write(hw.Base+UARTIMSC, 0)
+	reg.Write(hw.Base+UARTICR, 0x7ff)
+
+	if hw.Clock != 0 {
+		// divisor = UARTCLK / (16 * baud rate), in 1/64 units
+		// (3.3.6 Fractional Baud Rate Register, DDI 0183G)
+		div := (hw.Clock*4 + hw.Baudrate/2) / hw.Baudrate
+
+		reg.Write(hw.Base+UARTIBRD, div>>6)
+		reg.Write(hw.Base+UARTFBRD, div&0x3f)
+	}
+
+	// 8 bits word length, FIFOs enabled
+	reg.Write(hw.Base+UARTLCR_H, 0b11<<UARTLCR_H_WLEN|1<<UARTLCR_H_FEN)
+
+	// enable UART, transmit and receive
+	reg.Write(hw.Base+UARTCR, 1<<UARTCR_RXE|1<<UARTCR_TXE|1<<UARTCR_UARTEN)
+}
+
+// Tx transmits a single character to the serial port.
+func (hw *PL011) Tx(c byte) {
+	if hw.dr == 0 {
+		// allow use as early console before Init()
+		hw.dr = hw.Base + UARTDR
+		hw.fr = hw.Base + UARTFR
+	}
+
+	for reg.IsSet(hw.fr, UARTFR_TXFF) {
+		// wait for TX FIFO to have room for a character
+	}
+
+	reg.Write(hw.dr, uint32(c))
+}
+
+// Rx receives a single character from the serial port.
+func (hw *PL011) Rx() (c byte, valid bool) {
+	if hw.fr == 0 || reg.IsSet(hw.fr, UARTFR_RXFE) {
+		return
+	}
+
+	return byte(reg.Read(hw.dr)), true
+}
+
+// Write data from buffer to serial port.
+func (hw *PL011) Write(buf []byte) (n int, _ error) {
+	for n = 0; n < len(buf); n++ {
+		hw.Tx(buf[n])
+	}
+
+	return
+}
+
+// Read available data to buffer from serial port.
+func (hw *PL011) Read(buf []byte) (n int, _ error) {
+	var valid bool
+
+	for n = 0; n < len(buf); n++ {
+		buf[n], valid = hw.Rx()
+
+		if !valid {
+			break
+		}
+	}
+
+	return
+}
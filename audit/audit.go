@@ -0,0 +1,496 @@
+// Persistent audit log
+// https://github.com/karlo195/tamago
+//
+// Copyright (c) The TamaGo Authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+// Package audit implements an append-only audit log of typed security events,
+// persisted on a block device partition or eMMC RPMB partition, for
+// regulated appliance deployments.
+//
+// Records are hash chained, each one includes the hash of its predecessor,
+// and optionally authenticated with an HMAC-SHA256 key (e.g. derived from a
+// hardware unique key) so that any alteration, reordering or removal of
+// existing records is detected:
+//
+//	log := &audit.Log{
+//		Storage: &audit.RPMBPartition{RPMB: p, Address: 0, Count: 64},
+//		Key:     key,
+//		Signer:  signer,
+//	}
+//
+//	if err := log.Init(); err != nil {
+//		return err
+//	}
+//
+//	log.Append(audit.EVENT_AUTH_FAILURE, []byte("admin"))
+//
+// Checkpoints, signed by [Log.Signer], attest the log head so that exported
+// records can be verified off device (see [Verify]) and truncation of the
+// log, undetectable from the chain alone, identified against previously
+// exported checkpoints.
+//
+// Each record is stored in a single storage block, therefore the event data
+// size is limited to the block size minus the record overhead (see
+// [Log.MaxDataSize]).
+//
+// This package is only meant to be used with `GOOS=tamago` as supported by the
+// TamaGo framework for bare metal Go, see https://github.com/karlo195/tamago.
+package audit
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Event types, values from EVENT_USER onwards are application defined.
+const (
+	EVENT_BOOT          = 0x0001
+	EVENT_SHUTDOWN      = 0x0002
+	EVENT_AUTH_SUCCESS  = 0x0003
+	EVENT_AUTH_FAILURE  = 0x0004
+	EVENT_KEY_USE       = 0x0005
+	EVENT_UPDATE        = 0x0006
+	EVENT_CONFIG_CHANGE = 0x0007
+	EVENT_TAMPER        = 0x0008
+	EVENT_LOG_EXPORT    = 0x0009
+
+	EVENT_USER = 0x8000
+)
+
+const (
+	// record magic
+	magic = 0x474f4c41 // "ALOG"
+
+	// HashSize is the record hash size.
+	HashSize = sha256.Size
+	// HeaderSize is the record header size.
+	HeaderSize = 24 + HashSize
+	// Overhead is the record size excluding event data.
+	Overhead = HeaderSize + HashSize
+
+	// checkpoint signature domain separation label
+	checkpointLabel = "tamago audit checkpoint"
+)
+
+// ErrFull is returned by [Log.Append] when the storage is full.
+var ErrFull = errors.New("audit log full")
+
+// ErrIntegrity is returned when a record fails hash chain or authentication
+// verification.
+var ErrIntegrity = errors.New("audit log integrity failure")
+
+// Record represents an audit log record.
+type Record struct {
+	// Sequence is the record sequence number.
+	Sequence uint64
+	// Time is the record timestamp.
+	Time time.Time
+	// Type is the event type.
+	Type uint16
+	// Data is the event data.
+	Data []byte
+	// Previous is the hash of the previous record, zero for the first one.
+	Previous [HashSize]byte
+	// Hash is the record hash, HMAC-SHA256 when the log is keyed and
+	// SHA-256 otherwise, computed over all preceding fields.
+	Hash [HashSize]byte
+}
+
+func (r *Record) header() []byte {
+	buf := make([]byte, HeaderSize, HeaderSize+len(r.Data))
+
+	binary.LittleEndian.PutUint32(buf[0:], magic)
+	binary.LittleEndian.PutUint16(buf[4:], r.Type)
+	binary.LittleEndian.PutUint16(buf[6:], uint16(len(r.Data)))
+	binary.LittleEndian.PutUint64(buf[8:], r.Sequence)
+	binary.LittleEndian.PutUint64(buf[16:], uint64(r.Time.UnixNano()))
+	copy(buf[24:], r.Previous[:])
+
+	return append(buf, r.Data...)
+}
+
+func (r *Record) digest(key []byte) (sum [HashSize]byte) {
+	h := sha256.New()
+
+	if len(key) > 0 {
+		h = hmac.New(sha256.New, key)
+	}
+
+	h.Write(r.header())
+	copy(sum[:], h.Sum(nil))
+
+	return
+}
+
+// Marshal encodes the record.
+func (r *Record) Marshal() []byte {
+	return append(r.header(), r.Hash[:]...)
+}
+
+// Unmarshal decodes a record, trailing data is ignored.
+func (r *Record) Unmarshal(buf []byte) (err error) {
+	if len(buf) < Overhead || binary.LittleEndian.Uint32(buf[0:]) != magic {
+		return errors.New("invalid record")
+	}
+
+	n := int(binary.LittleEndian.Uint16(buf[6:]))
+
+	if len(buf) < Overhead+n {
+		return errors.New("invalid record length")
+	}
+
+	r.Type = binary.LittleEndian.Uint16(buf[4:])
+	r.Sequence = binary.LittleEndian.Uint64(buf[8:])
+	r.Time = time.Unix(0, int64(binary.LittleEndian.Uint64(buf[16:])))
+	copy(r.Previous[:], buf[24:HeaderSize])
+	r.Data = append([]byte{}, buf[HeaderSize:HeaderSize+n]...)
+	copy(r.Hash[:], buf[HeaderSize+n:])
+
+	return
+}
+
+// Checkpoint represents a signed attestation of the audit log head.
+type Checkpoint struct {
+	// Sequence is the number of records in the log.
+	Sequence uint64
+	// Hash is the hash of the last record, zero for an empty log.
+	Hash [HashSize]byte
+	// Signature is the checkpoint signature.
+	Signature []byte
+}
+
+// Digest returns the checkpoint signed message digest.
+func (cp *Checkpoint) Digest() []byte {
+	h := sha256.New()
+	h.Write([]byte(checkpointLabel))
+	binary.Write(h, binary.BigEndian, cp.Sequence)
+	h.Write(cp.Hash[:])
+
+	return h.Sum(nil)
+}
+
+// Log represents an audit log instance.
+type Log struct {
+	sync.Mutex
+
+	// Storage is the underlying record storage.
+	Storage Storage
+	// Key is the optional HMAC-SHA256 record authentication key, when nil
+	// records are only hash chained.
+	Key []byte
+	// Signer is the optional checkpoint signing key (ECDSA, RSA or
+	// Ed25519).
+	Signer crypto.Signer
+	// Now returns the record timestamps (default time.Now).
+	Now func() time.Time
+
+	// next record sequence number
+	next uint64
+	// last record hash
+	head [HashSize]byte
+}
+
+// MaxDataSize returns the maximum event data size.
+func (l *Log) MaxDataSize() int {
+	return min(l.Storage.BlockSize()-Overhead, 0xffff)
+}
+
+func (l *Log) read(n int) (r *Record, err error) {
+	buf := make([]byte, l.Storage.BlockSize())
+
+	if err = l.Storage.ReadBlock(n, buf); err != nil {
+		return
+	}
+
+	r = &Record{}
+
+	if err = r.Unmarshal(buf); err != nil {
+		return nil, err
+	}
+
+	return
+}
+
+func (l *Log) verify(r *Record, seq uint64, prev [HashSize]byte) error {
+	sum := r.digest(l.Key)
+
+	if r.Sequence != seq || r.Previous != prev || !hmac.Equal(sum[:], r.Hash[:]) {
+		return fmt.Errorf("%w, record %d", ErrIntegrity, seq)
+	}
+
+	return nil
+}
+
+// Init verifies the stored records, locating the log head.
+//
+// The log ends at the first block not holding a record, either blank (erased)
+// or malformed, [ErrIntegrity] is returned when any record follows it as
+// appending would overwrite evidence of tampering.
+func (l *Log) Init() (err error) {
+	l.Lock()
+	defer l.Unlock()
+
+	if l.Storage == nil || l.MaxDataSize() < 0 {
+		return errors.New("invalid audit log instance")
+	}
+
+	if l.Now == nil {
+		l.Now = time.Now
+	}
+
+	l.next = 0
+	l.head = [HashSize]byte{}
+
+	buf := make([]byte, l.Storage.BlockSize())
+	end := false
+
+	for n := 0; n < l.Storage.Blocks(); n++ {
+		if err = l.Storage.ReadBlock(n, buf); err != nil {
+			return
+		}
+
+		r := &Record{}
+		valid := r.Unmarshal(buf) == nil
+
+		switch {
+		case end && valid:
+			// records following the log end indicate that an
+			// existing one has been corrupted or erased
+			return fmt.Errorf("%w, record %d", ErrIntegrity, l.next)
+		case end:
+			continue
+		case !valid:
+			// the log ends at the first blank or malformed block,
+			// the following ones are still checked
+			end = true
+			continue
+		}
+
+		if err = l.verify(r, l.next, l.head); err != nil {
+			return err
+		}
+
+		l.next++
+		l.head = r.Hash
+	}
+
+	return
+}
+
+// Append appends an event to the log, returning its record sequence number.
+func (l *Log) Append(typ uint16, data []byte) (seq uint64, err error) {
+	l.Lock()
+	defer l.Unlock()
+
+	if l.Now == nil {
+		return 0, errors.New("audit log not initialized")
+	}
+
+	if len(data) > l.MaxDataSize() {
+		return 0, errors.New("invalid event data size")
+	}
+
+	if l.next >= uint64(l.Storage.Blocks()) {
+		return 0, ErrFull
+	}
+
+	r := &Record{
+		Sequence: l.next,
+		Time:     l.Now(),
+		Type:     typ,
+		Data:     data,
+		Previous: l.head,
+	}
+
+	r.Hash = r.digest(l.Key)
+
+	buf := make([]byte, l.Storage.BlockSize())
+	copy(buf, r.Marshal())
+
+	if err = l.Storage.WriteBlock(int(r.Sequence), buf); err != nil {
+		return
+	}
+
+	l.next++
+	l.head = r.Hash
+
+	return r.Sequence, nil
+}
+
+// Len returns the number of records in the log.
+func (l *Log) Len() uint64 {
+	l.Lock()
+	defer l.Unlock()
+
+	return l.next
+}
+
+// Read returns the verified record at the argument sequence number.
+func (l *Log) Read(seq uint64) (r *Record, err error) {
+	l.Lock()
+	defer l.Unlock()
+
+	if seq >= l.next {
+		return nil, errors.New("invalid sequence number")
+	}
+
+	var prev [HashSize]byte
+
+	if seq > 0 {
+		p, err := l.read(int(seq - 1))
+
+		if err != nil {
+			return nil, err
+		}
+
+		prev = p.Hash
+	}
+
+	if r, err = l.read(int(seq)); err != nil {
+		return
+	}
+
+	return r, l.verify(r, seq, prev)
+}
+
+// Checkpoint returns a signed attestation of the current log head.
+func (l *Log) Checkpoint() (cp *Checkpoint, err error) {
+	l.Lock()
+	defer l.Unlock()
+
+	return l.checkpoint()
+}
+
+func (l *Log) checkpoint() (cp *Checkpoint, err error) {
+	if l.Signer == nil {
+		return nil, errors.New("missing checkpoint signer")
+	}
+
+	cp = &Checkpoint{
+		Sequence: l.next,
+		Hash:     l.head,
+	}
+
+	digest := cp.Digest()
+
+	switch l.Signer.Public().(type) {
+	case ed25519.PublicKey:
+		cp.Signature, err = l.Signer.Sign(rand.Reader, digest, crypto.Hash(0))
+	default:
+		cp.Signature, err = l.Signer.Sign(rand.Reader, digest, crypto.SHA256)
+	}
+
+	return
+}
+
+// Export returns, from the argument sequence number onwards, all verified
+// records along with a checkpoint attesting the last one.
+func (l *Log) Export(from uint64) (records []*Record, cp *Checkpoint, err error) {
+	l.Lock()
+	defer l.Unlock()
+
+	if from > l.next {
+		return nil, nil, errors.New("invalid sequence number")
+	}
+
+	var prev [HashSize]byte
+
+	if from > 0 {
+		p, err := l.read(int(from - 1))
+
+		if err != nil {
+			return nil, nil, err
+		}
+
+		prev = p.Hash
+	}
+
+	for seq := from; seq < l.next; seq++ {
+		r, err := l.read(int(seq))
+
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if err = l.verify(r, seq, prev); err != nil {
+			return nil, nil, err
+		}
+
+		records = append(records, r)
+		prev = r.Hash
+	}
+
+	cp, err = l.checkpoint()
+
+	return
+}
+
+// Verify verifies, off device, exported records against their checkpoint
+// and its signing public key.
+//
+// Records must be consecutive and end at the checkpoint head, their hashes
+// are verified with the argument log authentication key (nil for logs which
+// are not keyed). The first record predecessor is not verified, exports
+// should therefore either start from the first record or be matched against a
+// previously verified checkpoint.
+func Verify(records []*Record, cp *Checkpoint, pub crypto.PublicKey, key []byte) (err error) {
+	if cp == nil {
+		return errors.New("missing checkpoint")
+	}
+
+	digest := cp.Digest()
+
+	switch k := pub.(type) {
+	case *ecdsa.PublicKey:
+		if !ecdsa.VerifyASN1(k, digest, cp.Signature) {
+			err = errors.New("invalid checkpoint signature")
+		}
+	case *rsa.PublicKey:
+		err = rsa.VerifyPKCS1v15(k, crypto.SHA256, digest, cp.Signature)
+	case ed25519.PublicKey:
+		if !ed25519.Verify(k, digest, cp.Signature) {
+			err = errors.New("invalid checkpoint signature")
+		}
+	default:
+		err = errors.New("unsupported public key")
+	}
+
+	if err != nil {
+		return
+	}
+
+	for i, r := range records {
+		if i > 0 && (r.Sequence != records[i-1].Sequence+1 || r.Previous != records[i-1].Hash) {
+			return fmt.Errorf("%w, record %d", ErrIntegrity, r.Sequence)
+		}
+
+		if sum := r.digest(key); !hmac.Equal(sum[:], r.Hash[:]) {
+			return fmt.Errorf("%w, record %d", ErrIntegrity, r.Sequence)
+		}
+	}
+
+	if len(records) == 0 {
+		return
+	}
+
+	last := records[len(records)-1]
+
+	if last.Sequence+1 != cp.Sequence || last.Hash != cp.Hash {
+		return errors.New("records do not match checkpoint")
+	}
+
+	return
+}
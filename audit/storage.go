@@ -0,0 +1,132 @@
+// Persistent audit log
+// https://github.com/karlo195/tamago
+//
+// Copyright (c) The TamaGo Authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+package audit
+
+import (
+	"errors"
+
+	"github.com/karlo195/tamago/rpmb"
+)
+
+// Storage represents the audit log block storage, each record is stored in a
+// single block.
+type Storage interface {
+	// BlockSize returns the storage block size.
+	BlockSize() int
+	// Blocks returns the number of storage blocks.
+	Blocks() int
+	// ReadBlock reads a single block.
+	ReadBlock(n int, buf []byte) error
+	// WriteBlock writes a single block.
+	WriteBlock(n int, buf []byte) error
+}
+
+// BlockDevice represents a block device, matching the NXP uSDHC driver (see
+// soc/nxp/usdhc) API.
+type BlockDevice interface {
+	// ReadBlocks reads consecutive blocks starting at the argument LBA.
+	ReadBlocks(lba int, buf []byte) (err error)
+	// WriteBlocks writes consecutive blocks starting at the argument LBA.
+	WriteBlocks(lba int, buf []byte) (err error)
+}
+
+// Partition represents an audit log storage on a block device region.
+type Partition struct {
+	// Device is the underlying block device.
+	Device BlockDevice
+	// Start is the region first LBA.
+	Start int
+	// Count is the region size in blocks.
+	Count int
+	// Size is the block size (default 512).
+	Size int
+}
+
+// BlockSize returns the storage block size.
+func (p *Partition) BlockSize() int {
+	if p.Size == 0 {
+		return 512
+	}
+
+	return p.Size
+}
+
+// Blocks returns the number of storage blocks.
+func (p *Partition) Blocks() int {
+	return p.Count
+}
+
+// ReadBlock reads a single block.
+func (p *Partition) ReadBlock(n int, buf []byte) error {
+	if n < 0 || n >= p.Count || len(buf) != p.BlockSize() {
+		return errors.New("invalid block")
+	}
+
+	return p.Device.ReadBlocks(p.Start+n, buf)
+}
+
+// WriteBlock writes a single block.
+func (p *Partition) WriteBlock(n int, buf []byte) error {
+	if n < 0 || n >= p.Count || len(buf) != p.BlockSize() {
+		return errors.New("invalid block")
+	}
+
+	return p.Device.WriteBlocks(p.Start+n, buf)
+}
+
+// RPMB represents an authenticated eMMC RPMB partition, matching the rpmb
+// package (see rpmb.RPMB) API.
+type RPMB interface {
+	// Read performs authenticated reads of consecutive half sectors.
+	Read(addr uint16, buf []byte) (err error)
+	// Write performs authenticated writes of consecutive half sectors.
+	Write(addr uint16, buf []byte) (err error)
+}
+
+// RPMBPartition represents an audit log storage on an eMMC RPMB partition
+// region, each block being a 256 bytes half sector.
+//
+// Unlike a block device partition, records cannot be altered without the
+// RPMB authentication key, giving tamper resistance to the log.
+type RPMBPartition struct {
+	// RPMB is the underlying RPMB partition.
+	RPMB RPMB
+	// Address is the region first half sector address.
+	Address uint16
+	// Count is the region size in half sectors.
+	Count int
+}
+
+// BlockSize returns the storage block size.
+func (p *RPMBPartition) BlockSize() int {
+	return rpmb.DataSize
+}
+
+// Blocks returns the number of storage blocks.
+func (p *RPMBPartition) Blocks() int {
+	return p.Count
+}
+
+// ReadBlock reads a single block.
+func (p *RPMBPartition) ReadBlock(n int, buf []byte) error {
+	if n < 0 || n >= p.Count || len(buf) != rpmb.DataSize {
+		return errors.New("invalid block")
+	}
+
+	return p.RPMB.Read(p.Address+uint16(n), buf)
+}
+
+// WriteBlock writes a single block.
+func (p *RPMBPartition) WriteBlock(n int, buf []byte) error {
+	if n < 0 || n >= p.Count || len(buf) != rpmb.DataSize {
+		return errors.New("invalid block")
+	}
+
+	return p.RPMB.Write(p.Address+uint16(n), buf)
+}
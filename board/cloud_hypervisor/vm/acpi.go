@@ -0,0 +1,49 @@
+// Cloud Hypervisor support for tamago/amd64
+// https://github.com/karlo195/tamago
+//
+// Copyright (c) The TamaGo Authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+package vm
+
+import (
+	"github.com/karlo195/tamago/amd64"
+	"github.com/karlo195/tamago/soc/intel/acpi"
+	"github.com/karlo195/tamago/soc/intel/pci"
+)
+
+// configureECAM enables access to the PCI Express extended configuration
+// space through the ECAM regions described by the ACPI MCFG, when available.
+func configureECAM() {
+	var ecam []pci.ECAM
+
+	rsdp, _ := amd64.RSDP()
+	a, err := acpi.Load(rsdp)
+
+	if err != nil {
+		return
+	}
+
+	regions, err := a.MCFG()
+
+	if err != nil {
+		return
+	}
+
+	for _, r := range regions {
+		// only PCI segment group 0 is supported
+		if r.Segment != 0 {
+			continue
+		}
+
+		ecam = append(ecam, pci.ECAM{
+			Base:     r.Base,
+			StartBus: uint32(r.StartBus),
+			EndBus:   uint32(r.EndBus),
+		})
+	}
+
+	pci.SetECAM(ecam...)
+}
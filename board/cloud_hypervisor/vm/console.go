@@ -11,14 +11,53 @@
 package vm
 
 import (
+	"errors"
 	_ "unsafe"
+
+	"github.com/karlo195/tamago/console"
+	"github.com/karlo195/tamago/kvm/virtio"
 )
 
+// Console is the console output manager, its default sink is UART0, or the
+// debug console with the `debugcon` build tag (see console.Console).
+var Console = &console.Console{
+	Default: defaultTx,
+}
+
+// EnableVirtIOConsole initializes the VirtIO console (`--console`), when
+// attached, and adds it as Console sink. The returned instance can also be
+// used to read console input.
+func EnableVirtIOConsole() (c *virtio.Console, err error) {
+	dev := VirtIO(VIRTIO_CONSOLE_PCI_DEVICE)
+
+	if dev == nil {
+		return nil, errors.New("no console device attached")
+	}
+
+	c = &virtio.Console{
+		Device: &virtio.PCI{Device: dev},
+	}
+
+	if err = c.Init(); err != nil {
+		return nil, err
+	}
+
+	if _, err = Console.Add(c); err != nil {
+		return nil, err
+	}
+
+	return
+}
+
+func uartTx(c byte) {
+	UART0.Tx(c)
+}
+
 //go:linkname printk runtime.printk
 func printk(c byte) {
-	UART0.Tx(c)
+	Console.Tx(c)
 
 	if c == 0x0a { // LF
-		UART0.Tx(0x0d) // CR
+		Console.Tx(0x0d) // CR
 	}
 }
@@ -0,0 +1,55 @@
+// Cloud Hypervisor support for tamago/amd64
+// https://github.com/karlo195/tamago
+//
+// Copyright (c) The TamaGo Authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+package vm
+
+import (
+	"github.com/karlo195/tamago/internal/reg"
+	"github.com/karlo195/tamago/kvm/virtio"
+	"github.com/karlo195/tamago/power"
+)
+
+// ACPI shutdown device, referenced by the FADT sleep control and reset
+// registers.
+const (
+	SHUTDOWN_PIO_ADDRESS = 0x600
+
+	// SLP_EN | SLP_TYP(S5)
+	SHUTDOWN_S5    = 0x34
+	SHUTDOWN_RESET = 0x01
+)
+
+type platform struct{}
+
+func init() {
+	power.Register(&platform{})
+}
+
+// Reset requests a virtual machine reset through the ACPI reset register.
+func (p *platform) Reset() {
+	reg.Out8(SHUTDOWN_PIO_ADDRESS, SHUTDOWN_RESET)
+}
+
+// Shutdown requests a virtual machine shutdown through the ACPI sleep
+// control register.
+func (p *platform) Shutdown() {
+	// clean VirtIO devices shutdown
+	virtio.ResetAll()
+
+	reg.Out8(SHUTDOWN_PIO_ADDRESS, SHUTDOWN_S5)
+}
+
+// Reboot requests a virtual machine reset, after clean devices shutdown.
+func (p *platform) Reboot(reason string) {
+	print("reboot: ", reason, "\n")
+
+	// clean VirtIO devices shutdown
+	virtio.ResetAll()
+
+	p.Reset()
+}
@@ -19,14 +19,16 @@ import (
 	_ "unsafe"
 
 	"github.com/karlo195/tamago/amd64"
+	"github.com/karlo195/tamago/boottime"
 	"github.com/karlo195/tamago/dma"
-	"github.com/karlo195/tamago/internal/reg"
 	"github.com/karlo195/tamago/kvm/pvclock"
+	"github.com/karlo195/tamago/soc/intel/acpi"
 	"github.com/karlo195/tamago/soc/intel/ioapic"
 	"github.com/karlo195/tamago/soc/intel/pci"
 	"github.com/karlo195/tamago/soc/intel/uart"
 )
 
+// DMA region, used in absence of a boot memory map
 const (
 	dmaStart = 0x50000000
 	dmaSize  = 0x10000000 // 256MB
@@ -44,23 +46,29 @@ const (
 	VIRTIO_MMIO_BASE = 0xe8000000
 
 	// VirtIO Networking
-	VIRTIO_NET_PCI_VENDOR = 0x1af4 // Red Hat, Inc.
+	VIRTIO_NET_PCI_VENDOR = pci.VirtIOVendorID
 	VIRTIO_NET_PCI_DEVICE = 0x1041 // Virtio 1.0 network device
+
+	// VirtIO PCI devices (--disk, --console, --rng, --vsock)
+	VIRTIO_BLOCK_PCI_DEVICE   = 0x1042 // Virtio 1.0 block device
+	VIRTIO_CONSOLE_PCI_DEVICE = 0x1043 // Virtio 1.0 console
+	VIRTIO_RNG_PCI_DEVICE     = 0x1044 // Virtio 1.0 RNG
+	VIRTIO_VSOCK_PCI_DEVICE   = 0x1053 // Virtio 1.0 socket
 )
 
 // Peripheral instances
 var (
 	// CPU instance(s)
-	AMD64 = &amd64.CPU{
-		// required before Init()
-		TimerMultiplier: 1,
-	}
+	AMD64 = &amd64.CPU{}
 
 	// I/O APIC - GSI 0-23
 	IOAPIC0 = &ioapic.IOAPIC{
 		Base: IOAPIC0_BASE,
 	}
 
+	// I/O APICs, keyed by GSI base
+	IOAPICs = &ioapic.Registry{}
+
 	// Serial port
 	UART0 = &uart.UART{
 		Index: 1,
@@ -68,6 +76,28 @@ var (
 	}
 )
 
+// dmaRegion returns the global DMA region, which follows the Go runtime memory
+// up to the end of RAM when reported by the boot memory map.
+func dmaRegion() (start uint, size int) {
+	_, ramEnd := runtime.MemRegion()
+
+	if end, ok := amd64.RAMEnd(); ok && end > uint64(ramEnd) {
+		return uint(ramEnd), int(end - uint64(ramEnd))
+	}
+
+	return dmaStart, dmaSize
+}
+
+// VirtIO returns the first VirtIO PCI device matching the argument device ID
+// (e.g. VIRTIO_BLOCK_PCI_DEVICE), nil is returned when the device is not
+// attached.
+//
+// Device Base Address registers are left as assigned by Cloud Hypervisor, the
+// returned device can be passed to virtio.PCI.
+func VirtIO(device uint16) *pci.Device {
+	return pci.Probe(0, pci.VirtIOVendorID, device)
+}
+
 //go:linkname nanotime1 runtime.nanotime1
 func nanotime1() int64 {
 	return AMD64.GetTime()
@@ -79,20 +109,31 @@ func nanotime1() int64 {
 //go:linkname Init runtime.hwinit1
 func Init() {
 	// initialize CPU
+	boottime.Mark("cpu")
 	AMD64.Init()
 
-	// initialize I/O APIC
+	// initialize I/O APIC, as described by ACPI when available
+	boottime.Mark("ioapic")
+	rsdp, _ := amd64.RSDP()
+	acpi.ConfigureIOAPICs(rsdp, IOAPIC0)
 	IOAPIC0.Init()
+	IOAPICs.Add(IOAPIC0)
+
 	// initialize serial console
+	boottime.Mark("uart")
 	UART0.Init()
 
 	runtime.Exit = func(_ int32) {
-		// shutdown_pio_address
-		reg.Out32(0x600, 0x34)
+		(&platform{}).Shutdown()
 	}
+
+	// remaining runtime initialization
+	boottime.Mark("runtime")
 }
 
 func init() {
+	boottime.Mark("board")
+
 	// trap CPU exceptions
 	AMD64.EnableExceptions()
 
@@ -101,16 +142,25 @@ func init() {
 	// AMD64.InitSMP(-1)
 
 	// allocate global DMA region
-	dma.Init(dmaStart, dmaSize)
+	boottime.Mark("dma")
+	dma.Init(dmaRegion())
 
 	// initialize KVM pvclock as needed
+	boottime.Mark("pvclock")
 	pvclock.Init(AMD64)
 
-	if dev := pci.Probe(0, VIRTIO_NET_PCI_VENDOR, VIRTIO_NET_PCI_DEVICE); dev != nil {
+	// enable PCI Express extended configuration space access
+	boottime.Mark("ecam")
+	configureECAM()
+
+	if dev := VirtIO(VIRTIO_NET_PCI_DEVICE); dev != nil {
 		// set Memory Space Enable (MSE)
 		dev.Write(0, pci.Command, 1<<1)
 		// reconfigure BAR to mapped memory region
 		dev.Write(0, pci.Bar0, 0x40000000)
 		dev.Write(0, pci.Bar0+4, 0x1)
 	}
+
+	// remaining package initialization
+	boottime.Mark("init")
 }
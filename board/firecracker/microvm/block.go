@@ -0,0 +1,49 @@
+// Firecracker microvm support for tamago/amd64
+// https://github.com/karlo195/tamago
+//
+// Copyright (c) The TamaGo Authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+package microvm
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/karlo195/tamago/amd64"
+	"github.com/karlo195/tamago/kvm/virtio"
+)
+
+// BLK0 is the VirtIO block device of the first attached drive (e.g. the
+// `--root-drive` of firectl or the first entry of `drives` in the
+// configuration file), see Drive().
+var BLK0 = &virtio.Block{}
+
+var drive struct {
+	sync.Once
+	err error
+}
+
+// Drive initializes, on first invocation, and returns the block device of
+// the first attached drive, suitable for block device consumers (e.g.
+// filesystem drivers) through its ReadBlocks and WriteBlocks methods.
+//
+// The device is located on the kernel command line (see virtio.Find()), an
+// error is returned when no drive is attached.
+func Drive() (*virtio.Block, error) {
+	drive.Do(func() {
+		dev := virtio.Find(amd64.CommandLine(), virtio.BlockDeviceID)
+
+		if dev == nil {
+			drive.err = errors.New("no drive attached")
+			return
+		}
+
+		BLK0.Device = dev
+		drive.err = BLK0.Init()
+	})
+
+	return BLK0, drive.err
+}
@@ -12,9 +12,21 @@ package microvm
 
 import (
 	_ "unsafe"
+
+	"github.com/karlo195/tamago/console"
 )
 
+// Console is the console output manager, its default sink is UART0 (see
+// console.Console).
+var Console = &console.Console{
+	Default: uartTx,
+}
+
+func uartTx(c byte) {
+	UART0.Tx(c)
+}
+
 //go:linkname printk runtime.printk
 func printk(c byte) {
-	UART0.Tx(c)
+	Console.Tx(c)
 }
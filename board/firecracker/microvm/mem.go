@@ -18,6 +18,9 @@ import (
 //
 // This is useful when large DMA descriptors are required to re-initialize
 // tamago `dma` package in external RAM.
+//
+// A zero ramSize is sized at boot from the memory map passed by Firecracker
+// (see amd64.RAMEnd()), defaulting to 1GB in its absence.
 
 //go:linkname ramSize runtime.ramSize
-var ramSize uint64 = 0x40000000 // 1GB
+var ramSize uint64 = 0
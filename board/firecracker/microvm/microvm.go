@@ -19,12 +19,15 @@ import (
 	_ "unsafe"
 
 	"github.com/karlo195/tamago/amd64"
+	"github.com/karlo195/tamago/boottime"
 	"github.com/karlo195/tamago/dma"
 	"github.com/karlo195/tamago/kvm/pvclock"
+	"github.com/karlo195/tamago/soc/intel/acpi"
 	"github.com/karlo195/tamago/soc/intel/ioapic"
 	"github.com/karlo195/tamago/soc/intel/uart"
 )
 
+// DMA region, used in absence of a boot memory map
 const (
 	dmaStart = 0x50000000
 	dmaSize  = 0x10000000 // 256MB
@@ -37,28 +40,21 @@ const (
 
 	// Intel I/O Programmable Interrupt Controller
 	IOAPIC0_BASE = 0xfec00000
-
-	// VirtIO Memory-mapped I/O
-	VIRTIO_MMIO_BASE = 0xc0000000
-
-	// VirtIO Networking
-	VIRTIO_NET0_BASE = VIRTIO_MMIO_BASE + 0x2000
-	VIRTIO_NET0_IRQ  = 6
 )
 
 // Peripheral instances
 var (
 	// CPU instance(s)
-	AMD64 = &amd64.CPU{
-		// required before Init()
-		TimerMultiplier: 1,
-	}
+	AMD64 = &amd64.CPU{}
 
 	// I/O APIC - GSI 0-23
 	IOAPIC0 = &ioapic.IOAPIC{
 		Base: IOAPIC0_BASE,
 	}
 
+	// I/O APICs, keyed by GSI base
+	IOAPICs = &ioapic.Registry{}
+
 	// Serial port
 	UART0 = &uart.UART{
 		Index: 1,
@@ -66,6 +62,18 @@ var (
 	}
 )
 
+// dmaRegion returns the global DMA region, which follows the Go runtime memory
+// up to the end of RAM when reported by the boot memory map.
+func dmaRegion() (start uint, size int) {
+	_, ramEnd := runtime.MemRegion()
+
+	if end, ok := amd64.RAMEnd(); ok && end > uint64(ramEnd) {
+		return uint(ramEnd), int(end - uint64(ramEnd))
+	}
+
+	return dmaStart, dmaSize
+}
+
 //go:linkname nanotime1 runtime.nanotime1
 func nanotime1() int64 {
 	return AMD64.GetTime()
@@ -77,28 +85,46 @@ func nanotime1() int64 {
 //go:linkname Init runtime.hwinit1
 func Init() {
 	// initialize CPU
+	boottime.Mark("cpu")
 	AMD64.Init()
 
-	// initialize I/O APIC
+	// initialize I/O APIC, as described by ACPI when available
+	boottime.Mark("ioapic")
+	rsdp, _ := amd64.RSDP()
+	acpi.ConfigureIOAPICs(rsdp, IOAPIC0)
 	IOAPIC0.Init()
+	IOAPICs.Add(IOAPIC0)
+
 	// initialize serial console
+	boottime.Mark("uart")
 	UART0.Init()
 
 	runtime.Exit = func(_ int32) {
-		AMD64.Reset()
+		(&platform{}).Shutdown()
 	}
+
+	// remaining runtime initialization
+	boottime.Mark("runtime")
 }
 
 func init() {
+	boottime.Mark("board")
+
 	// trap CPU exceptions
 	AMD64.EnableExceptions()
 
 	// initialize APs
+	boottime.Mark("smp")
 	AMD64.InitSMP(-1)
 
 	// allocate global DMA region
-	dma.Init(dmaStart, dmaSize)
+	boottime.Mark("dma")
+	dma.Init(dmaRegion())
 
 	// initialize KVM pvclock as needed
+	boottime.Mark("pvclock")
 	pvclock.Init(AMD64)
+
+	// remaining package initialization
+	boottime.Mark("init")
 }
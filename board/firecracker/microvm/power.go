@@ -0,0 +1,41 @@
+// Firecracker microvm support for tamago/amd64
+// https://github.com/karlo195/tamago
+//
+// Copyright (c) The TamaGo Authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+package microvm
+
+import (
+	"github.com/karlo195/tamago/kvm/virtio"
+	"github.com/karlo195/tamago/power"
+)
+
+type platform struct{}
+
+func init() {
+	power.Register(&platform{})
+}
+
+// Reset asserts the CPU reset line through the emulated 8042 keyboard
+// controller, which Firecracker handles by terminating the virtual machine.
+func (p *platform) Reset() {
+	AMD64.Reset()
+}
+
+// Shutdown terminates the virtual machine.
+func (p *platform) Shutdown() {
+	// clean VirtIO devices shutdown
+	virtio.ResetAll()
+
+	AMD64.Reset()
+}
+
+// Reboot terminates the virtual machine, as Firecracker does not support
+// guest restarts its relaunch is left to the VMM orchestration.
+func (p *platform) Reboot(reason string) {
+	print("reboot: ", reason, "\n")
+	p.Shutdown()
+}
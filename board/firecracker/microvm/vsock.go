@@ -0,0 +1,82 @@
+// Firecracker microvm support for tamago/amd64
+// https://github.com/karlo195/tamago
+//
+// Copyright (c) The TamaGo Authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+package microvm
+
+import (
+	"errors"
+	"net"
+	"sync"
+
+	"github.com/karlo195/tamago/amd64"
+	"github.com/karlo195/tamago/kvm/virtio"
+)
+
+// VSOCK0 is the VirtIO socket device, configured with the `vsock` entry of
+// the Firecracker configuration file, see Dial() and Listen().
+var VSOCK0 = &virtio.Vsock{}
+
+var vsock struct {
+	sync.Once
+	err error
+}
+
+func initVsock() error {
+	vsock.Do(func() {
+		dev := virtio.Find(amd64.CommandLine(), virtio.VsockDeviceID)
+
+		if dev == nil {
+			vsock.err = errors.New("no socket device attached")
+			return
+		}
+
+		VSOCK0.Device = dev
+		vsock.err = VSOCK0.Init()
+	})
+
+	return vsock.err
+}
+
+// Dial connects to the argument port on the host, initializing VSOCK0 on
+// first use.
+//
+// Firecracker forwards guest initiated connections to the Unix domain socket
+// configured as `uds_path`, suffixed with the port number (e.g.
+// `/tmp/v.sock_1024`).
+func Dial(port uint32) (net.Conn, error) {
+	if err := initVsock(); err != nil {
+		return nil, err
+	}
+
+	c, err := VSOCK0.Dial(virtio.VsockHostCID, port)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// Listen announces on the argument guest port, initializing VSOCK0 on first
+// use.
+//
+// Firecracker forwards host initiated connections, issued with `CONNECT
+// <port>` on the `uds_path` Unix domain socket, to the guest port.
+func Listen(port uint32) (net.Listener, error) {
+	if err := initVsock(); err != nil {
+		return nil, err
+	}
+
+	l, err := VSOCK0.Listen(port)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return l, nil
+}
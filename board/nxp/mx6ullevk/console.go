@@ -13,13 +13,24 @@ package mx6ullevk
 import (
 	_ "unsafe"
 
+	"github.com/karlo195/tamago/console"
 	"github.com/karlo195/tamago/soc/nxp/imx6ul"
 )
 
 // On the MCIMX6ULL-EVK the serial console is UART1, therefore standard
 // output is redirected there.
 
+// Console is the console output manager, its default sink is imx6ul.UART1 (see
+// console.Console).
+var Console = &console.Console{
+	Default: uartTx,
+}
+
+func uartTx(c byte) {
+	imx6ul.UART1.Tx(c)
+}
+
 //go:linkname printk runtime.printk
 func printk(c byte) {
-	imx6ul.UART1.Tx(c)
+	Console.Tx(c)
 }
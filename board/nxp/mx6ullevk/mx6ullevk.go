@@ -9,43 +9,12 @@
 // Package mx6ullevk provides hardware initialization, automatically on import,
 // for the NXP MCIMX6ULL-EVK evaluation board.
 //
+// Peripheral instances, memory map and runtime initialization are generated
+// from board.toml (see cmd/boardgen).
+//
 // This package is only meant to be used with `GOOS=tamago GOARCH=arm` as
 // supported by the TamaGo framework for bare metal Go, see
 // https://github.com/karlo195/tamago.
 package mx6ullevk
 
-import (
-	_ "unsafe"
-
-	"github.com/karlo195/tamago/soc/nxp/imx6ul"
-)
-
-// Peripheral instances
-var (
-	ENET1 = imx6ul.ENET1
-	ENET2 = imx6ul.ENET2
-
-	I2C1 = imx6ul.I2C1
-	I2C2 = imx6ul.I2C2
-
-	UART1 = imx6ul.UART1
-	UART2 = imx6ul.UART2
-
-	USB1 = imx6ul.USB1
-	USB2 = imx6ul.USB2
-
-	// SD1 is the base board full size SD instance
-	USDHC1 = imx6ul.USDHC1
-	USDHC2 = imx6ul.USDHC2
-)
-
-// Init takes care of the lower level initialization triggered early in runtime
-// setup (post World start).
-//
-//go:linkname Init runtime.hwinit1
-func Init() {
-	imx6ul.Init()
-
-	// initialize console
-	imx6ul.UART1.Init()
-}
+//go:generate go run github.com/karlo195/tamago/cmd/boardgen board.toml
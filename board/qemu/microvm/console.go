@@ -12,9 +12,21 @@ package microvm
 
 import (
 	_ "unsafe"
+
+	"github.com/karlo195/tamago/console"
 )
 
+// Console is the console output manager, its default sink is UART0, or the
+// debug console with the `debugcon` build tag (see console.Console).
+var Console = &console.Console{
+	Default: defaultTx,
+}
+
+func uartTx(c byte) {
+	UART0.Tx(c)
+}
+
 //go:linkname printk runtime.printk
 func printk(c byte) {
-	UART0.Tx(c)
+	Console.Tx(c)
 }
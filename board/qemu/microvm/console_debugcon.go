@@ -0,0 +1,21 @@
+// QEMU microvm support for tamago/amd64
+// https://github.com/karlo195/tamago
+//
+// Copyright (c) The TamaGo Authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+//go:build !linkprintk && debugcon
+
+package microvm
+
+import (
+	"github.com/karlo195/tamago/kvm/debugcon"
+)
+
+// the debug console is used as default sink, rather than UART0, with the
+// debugcon build tag
+func defaultTx(c byte) {
+	debugcon.Tx(c)
+}
@@ -0,0 +1,15 @@
+// QEMU microvm support for tamago/amd64
+// https://github.com/karlo195/tamago
+//
+// Copyright (c) The TamaGo Authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+//go:build !linkprintk && !debugcon
+
+package microvm
+
+func defaultTx(c byte) {
+	uartTx(c)
+}
@@ -19,8 +19,10 @@ import (
 	_ "unsafe"
 
 	"github.com/karlo195/tamago/amd64"
+	"github.com/karlo195/tamago/boottime"
 	"github.com/karlo195/tamago/dma"
 	"github.com/karlo195/tamago/kvm/pvclock"
+	"github.com/karlo195/tamago/soc/intel/acpi"
 	"github.com/karlo195/tamago/soc/intel/ioapic"
 	"github.com/karlo195/tamago/soc/intel/rtc"
 	"github.com/karlo195/tamago/soc/intel/uart"
@@ -39,22 +41,12 @@ const (
 	// Intel I/O Programmable Interrupt Controllers
 	IOAPIC0_BASE = 0xfec00000
 	IOAPIC1_BASE = 0xfec10000
-
-	// VirtIO Memory-mapped I/O
-	VIRTIO_MMIO_BASE = 0xfeb00000
-
-	// VirtIO Networking
-	VIRTIO_NET0_BASE = VIRTIO_MMIO_BASE + 0x2e00
-	VIRTIO_NET0_IRQ  = 47
 )
 
 // Peripheral instances
 var (
 	// CPU instance(s)
-	AMD64 = &amd64.CPU{
-		// required before Init()
-		TimerMultiplier: 1,
-	}
+	AMD64 = &amd64.CPU{}
 
 	// I/O APIC - GSI 0-23
 	IOAPIC0 = &ioapic.IOAPIC{
@@ -73,6 +65,9 @@ var (
 	// Real-Time Clock
 	RTC = &rtc.RTC{}
 
+	// I/O APICs, keyed by GSI base
+	IOAPICs = &ioapic.Registry{}
+
 	// Serial port
 	UART0 = &uart.UART{
 		Index: 1,
@@ -91,32 +86,52 @@ func nanotime1() int64 {
 //go:linkname Init runtime.hwinit1
 func Init() {
 	// initialize BSP
+	boottime.Mark("cpu")
 	AMD64.Init()
 
-	// initialize I/O APICs
+	// initialize I/O APICs, as described by ACPI when available
+	boottime.Mark("ioapic")
+	rsdp, _ := amd64.RSDP()
+	acpi.ConfigureIOAPICs(rsdp, IOAPIC0, IOAPIC1)
 	IOAPIC0.Init()
 	IOAPIC1.Init()
+	IOAPICs.Add(IOAPIC0)
+	IOAPICs.Add(IOAPIC1)
 
 	// initialize serial console
+	boottime.Mark("uart")
 	UART0.Init()
 
 	runtime.Exit = func(_ int32) {
-		// On microvm the recommended way to trigger a guest-initiated
-		// shut down is by generating a triple-fault.
-		amd64.Fault()
+		(&platform{}).Shutdown()
 	}
+
+	// remaining runtime initialization
+	boottime.Mark("runtime")
 }
 
 func init() {
+	boottime.Mark("board")
+
 	// trap CPU exceptions
 	AMD64.EnableExceptions()
 
 	// initialize APs
+	boottime.Mark("smp")
 	AMD64.InitSMP(-1)
 
 	// allocate global DMA region
+	boottime.Mark("dma")
 	dma.Init(dmaStart, dmaSize)
 
 	// initialize KVM pvclock as needed
+	boottime.Mark("pvclock")
 	pvclock.Init(AMD64)
+
+	// set wall clock time
+	boottime.Mark("rtc")
+	RTC.Sync(AMD64)
+
+	// remaining package initialization
+	boottime.Mark("init")
 }
@@ -0,0 +1,49 @@
+// QEMU microvm support for tamago/amd64
+// https://github.com/karlo195/tamago
+//
+// Copyright (c) The TamaGo Authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+package microvm
+
+import (
+	"github.com/karlo195/tamago/amd64"
+	"github.com/karlo195/tamago/kvm/virtio"
+	"github.com/karlo195/tamago/power"
+)
+
+type platform struct{}
+
+func init() {
+	power.Register(&platform{})
+}
+
+// Reset generates a triple fault, which QEMU handles as a system reset (or
+// as termination with `-no-reboot`).
+func (p *platform) Reset() {
+	amd64.Fault()
+}
+
+// Shutdown terminates the virtual machine, which requires QEMU to be
+// started with `-no-reboot`.
+func (p *platform) Shutdown() {
+	// clean VirtIO devices shutdown
+	virtio.ResetAll()
+
+	// On microvm the recommended way to trigger a guest-initiated
+	// shut down is by generating a triple-fault.
+	amd64.Fault()
+}
+
+// Reboot restarts the virtual machine, which requires QEMU to be started
+// without `-no-reboot`.
+func (p *platform) Reboot(reason string) {
+	print("reboot: ", reason, "\n")
+
+	// clean VirtIO devices shutdown
+	virtio.ResetAll()
+
+	amd64.Fault()
+}
@@ -0,0 +1,21 @@
+// QEMU microvm support for tamago/amd64
+// https://github.com/karlo195/tamago
+//
+// Copyright (c) The TamaGo Authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+package microvm
+
+import (
+	"github.com/karlo195/tamago/amd64"
+	"github.com/karlo195/tamago/kvm/virtio"
+)
+
+// NET0 is the first VirtIO networking device declared on the kernel command
+// line (see virtio.Find()), nil when not attached.
+//
+// Its IRQ is the Global System Interrupt to be routed (see IOAPICs.RouteGSI())
+// for interrupt driven operation.
+var NET0 = virtio.Find(amd64.CommandLine(), virtio.NetDeviceID)
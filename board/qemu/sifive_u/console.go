@@ -13,10 +13,21 @@ package sifive_u
 import (
 	_ "unsafe"
 
+	"github.com/karlo195/tamago/console"
 	"github.com/karlo195/tamago/soc/sifive/fu540"
 )
 
+// Console is the console output manager, its default sink is fu540.UART0 (see
+// console.Console).
+var Console = &console.Console{
+	Default: uartTx,
+}
+
+func uartTx(c byte) {
+	fu540.UART0.Tx(c)
+}
+
 //go:linkname printk runtime.printk
 func printk(c byte) {
-	fu540.UART0.Tx(c)
+	Console.Tx(c)
 }
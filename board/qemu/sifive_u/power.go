@@ -0,0 +1,45 @@
+// QEMU virt support for tamago/riscv64
+// https://github.com/karlo195/tamago
+//
+// Copyright (c) The TamaGo Authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+package sifive_u
+
+import (
+	"github.com/karlo195/tamago/internal/reg"
+	"github.com/karlo195/tamago/power"
+)
+
+// QEMU SiFive test device, used for guest initiated shutdown and reset.
+const (
+	TEST_BASE = 0x100000
+
+	TEST_PASS  = 0x5555
+	TEST_RESET = 0x7777
+)
+
+type platform struct{}
+
+func init() {
+	power.Register(&platform{})
+}
+
+// Reset requests a machine reset through the QEMU SiFive test device.
+func (p *platform) Reset() {
+	reg.Write(TEST_BASE, TEST_RESET)
+}
+
+// Shutdown terminates QEMU, with a successful exit status, through the QEMU
+// SiFive test device.
+func (p *platform) Shutdown() {
+	reg.Write(TEST_BASE, TEST_PASS)
+}
+
+// Reboot requests a machine reset through the QEMU SiFive test device.
+func (p *platform) Reboot(reason string) {
+	print("reboot: ", reason, "\n")
+	p.Reset()
+}
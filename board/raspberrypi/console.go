@@ -13,10 +13,21 @@ package pi
 import (
 	_ "unsafe"
 
+	"github.com/karlo195/tamago/console"
 	"github.com/karlo195/tamago/soc/bcm2835"
 )
 
+// Console is the console output manager, its default sink is bcm2835.MiniUART (see
+// console.Console).
+var Console = &console.Console{
+	Default: uartTx,
+}
+
+func uartTx(c byte) {
+	bcm2835.MiniUART.Tx(c)
+}
+
 //go:linkname printk runtime.printk
 func printk(c byte) {
-	bcm2835.MiniUART.Tx(c)
+	Console.Tx(c)
 }
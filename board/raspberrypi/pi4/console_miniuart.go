@@ -0,0 +1,14 @@
+// Raspberry Pi 4 support for tamago/arm
+// https://github.com/karlo195/tamago
+//
+// Copyright (c) the pi4 package authors
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+//go:build linkprintk || !pl011
+
+package pi4
+
+// the Mini UART, initialized by bcm2835.Init(), is used as default sink
+func initConsole() {}
@@ -0,0 +1,37 @@
+// Raspberry Pi 4 support for tamago/arm
+// https://github.com/karlo195/tamago
+//
+// Copyright (c) the pi4 package authors
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+//go:build !linkprintk && pl011
+
+package pi4
+
+import (
+	"github.com/karlo195/tamago/board/raspberrypi"
+	"github.com/karlo195/tamago/internal/reg"
+	"github.com/karlo195/tamago/soc/bcm2835"
+)
+
+// the PL011 UART is used as default sink, rather than the Mini UART, with the
+// pl011 build tag
+func initConsole() {
+	// Not using GPIO abstraction here, as for the Mini UART, because
+	// calling Lock on sync.Mutex fails at this stage.
+	ra := reg.Read(bcm2835.PeripheralAddress(bcm2835.GPFSEL1))
+	ra &= ^(uint32(7) << 12) // gpio14
+	ra |= 4 << 12            // alt0
+	ra &= ^(uint32(7) << 15) // gpio15
+	ra |= 4 << 15            // alt0
+	reg.Write(bcm2835.PeripheralAddress(bcm2835.GPFSEL1), ra)
+
+	UART0.Init()
+	pi.Console.Default = uart0Tx
+}
+
+func uart0Tx(c byte) {
+	UART0.Tx(c)
+}
@@ -0,0 +1,56 @@
+// Raspberry Pi 4 LED support
+// https://github.com/karlo195/tamago
+//
+// Copyright (c) the pi4 package authors
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+package pi4
+
+import (
+	"errors"
+
+	"github.com/karlo195/tamago/soc/bcm2835"
+)
+
+// LED GPIO lines
+const (
+	// Activity LED, the power LED is only accessible through the
+	// VideoCore GPIO expander.
+	ACTIVITY = 0x2a
+)
+
+var activity *bcm2835.GPIO
+
+func init() {
+	var err error
+
+	activity, err = bcm2835.NewGPIO(ACTIVITY)
+
+	if err != nil {
+		panic(err)
+	}
+
+	activity.Out()
+}
+
+// LED turns on/off an LED by name.
+func (b *board) LED(name string, on bool) (err error) {
+	var led *bcm2835.GPIO
+
+	switch name {
+	case "activity", "Activity", "ACTIVITY":
+		led = activity
+	default:
+		return errors.New("invalid LED")
+	}
+
+	if on {
+		led.High()
+	} else {
+		led.Low()
+	}
+
+	return
+}
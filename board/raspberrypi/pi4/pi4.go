@@ -0,0 +1,85 @@
+// Raspberry Pi 4 support for tamago/arm
+// https://github.com/karlo195/tamago
+//
+// Copyright (c) the pi4 package authors
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+// Package pi4 provides hardware initialization, automatically on import, for
+// the Raspberry Pi 4 Model B single board computer in AArch32 state.
+//
+// The BCM2711 Cortex-A72 cores are executed in AArch32 state, the firmware
+// must therefore be configured with `arm_64bit=0`, only the first GB of RAM is
+// used.
+//
+// AArch64 execution (`GOARCH=arm64`), which requires runtime glue not yet
+// implemented, and the GENET Gigabit Ethernet controller are not supported.
+//
+// This package is only meant to be used with `GOOS=tamago GOARCH=arm` as
+// supported by the TamaGo framework for bare metal Go, see
+// https://github.com/karlo195/tamago.
+package pi4
+
+import (
+	_ "unsafe"
+
+	"github.com/karlo195/tamago/arm/gic"
+	"github.com/karlo195/tamago/arm/pl011"
+	"github.com/karlo195/tamago/board/raspberrypi"
+	"github.com/karlo195/tamago/soc/bcm2835"
+)
+
+// On the BCM2711, in low peripheral mode, peripheral addresses are remapped
+// from their hardware 'bus' address to the 0xfe000000 'physical' address.
+const peripheralBase = 0xfe000000
+
+// Peripheral registers
+const (
+	// Generic Interrupt Controller (GIC-400)
+	GIC_BASE = 0xff840000
+
+	// PL011 UART
+	UART0_BASE = peripheralBase + 0x201000
+	// PL011 UART reference clock (firmware default)
+	UART0_CLOCK = 48000000
+)
+
+// Peripheral instances
+var (
+	// Generic Interrupt Controller, initialized for Non-Secure operation
+	// at board initialization.
+	GIC = &gic.GIC{
+		Base: GIC_BASE,
+	}
+
+	// PL011 UART, initialized on GPIO 14/15 as early console with the
+	// pl011 build tag, the Mini UART (see bcm2835.MiniUART) is used
+	// otherwise.
+	UART0 = &pl011.PL011{
+		Index: 0,
+		Base:  UART0_BASE,
+		Clock: UART0_CLOCK,
+	}
+)
+
+type board struct{}
+
+// Board provides access to the capabilities of the Pi 4.
+var Board pi.Board = &board{}
+
+// Init takes care of the lower level initialization triggered early in runtime
+// setup (post World start).
+//
+//go:linkname Init runtime.hwinit1
+func Init() {
+	// Defer to generic BCM2835 initialization, with Pi 4
+	// peripheral base address.
+	bcm2835.Init(peripheralBase)
+
+	// select the early console UART
+	initConsole()
+
+	// the firmware ARM stub leaves the cores in Non-Secure state
+	GIC.Init(false, false)
+}
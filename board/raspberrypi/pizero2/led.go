@@ -0,0 +1,55 @@
+// Raspberry Pi Zero 2 W LED support
+// https://github.com/karlo195/tamago
+//
+// Copyright (c) the pizero2 package authors
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+package pizero2
+
+import (
+	"errors"
+
+	"github.com/karlo195/tamago/soc/bcm2835"
+)
+
+// LED GPIO lines
+const (
+	// Activity LED (active low)
+	ACTIVITY = 0x1d
+)
+
+var activity *bcm2835.GPIO
+
+func init() {
+	var err error
+
+	activity, err = bcm2835.NewGPIO(ACTIVITY)
+
+	if err != nil {
+		panic(err)
+	}
+
+	activity.Out()
+}
+
+// LED turns on/off an LED by name.
+func (b *board) LED(name string, on bool) (err error) {
+	var led *bcm2835.GPIO
+
+	switch name {
+	case "activity", "Activity", "ACTIVITY":
+		led = activity
+	default:
+		return errors.New("invalid LED")
+	}
+
+	if on {
+		led.Low()
+	} else {
+		led.High()
+	}
+
+	return
+}
@@ -0,0 +1,18 @@
+// Raspberry Pi Zero 2 W support for tamago/arm
+// https://github.com/karlo195/tamago
+//
+// Copyright (c) the pizero2 package authors
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+//go:build !linkramsize
+
+package pizero2
+
+import (
+	_ "unsafe"
+)
+
+//go:linkname ramSize runtime.ramSize
+var ramSize uint32 = 0x20000000 - 0x04000000 // 512MB - 64MB GPU (VideoCore)
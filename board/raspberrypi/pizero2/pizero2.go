@@ -0,0 +1,46 @@
+// Raspberry Pi Zero 2 W support for tamago/arm
+// https://github.com/karlo195/tamago
+//
+// Copyright (c) the pizero2 package authors
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+// Package pizero2 provides hardware initialization, automatically on import,
+// for the Raspberry Pi Zero 2 W single board computer in AArch32 state.
+//
+// The BCM2710 Cortex-A53 cores are executed in AArch32 state, as
+// AArch64 execution (`GOARCH=arm64`) requires runtime glue not yet
+// implemented, the firmware must therefore be configured with `arm_64bit=0`
+// to load the 32-bit kernel image.
+//
+// This package is only meant to be used with `GOOS=tamago GOARCH=arm` as
+// supported by the TamaGo framework for bare metal Go, see
+// https://github.com/karlo195/tamago.
+package pizero2
+
+import (
+	_ "unsafe"
+
+	"github.com/karlo195/tamago/board/raspberrypi"
+	"github.com/karlo195/tamago/soc/bcm2835"
+)
+
+// On the BCM2710 peripheral addresses are remapped, as on the BCM2836, from
+// their hardware 'bus' address to the 0x3f000000 'physical' address.
+const peripheralBase = 0x3f000000
+
+type board struct{}
+
+// Board provides access to the capabilities of the Pi Zero 2 W.
+var Board pi.Board = &board{}
+
+// Init takes care of the lower level initialization triggered early in runtime
+// setup (post World start).
+//
+//go:linkname Init runtime.hwinit1
+func Init() {
+	// Defer to generic BCM2835 initialization, with Pi Zero 2 W
+	// peripheral base address.
+	bcm2835.Init(peripheralBase)
+}
@@ -0,0 +1,68 @@
+// Raspberry Pi support for tamago/arm
+// https://github.com/karlo195/tamago
+//
+// Copyright (c) the pi package authors
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+package pi
+
+import (
+	"github.com/karlo195/tamago/internal/reg"
+	"github.com/karlo195/tamago/power"
+	"github.com/karlo195/tamago/soc/bcm2835"
+)
+
+// Reset status register
+const (
+	PM_RSTS = PM_BASE + 0x20
+
+	// boot partition 63 is interpreted by the firmware as a halt request
+	PM_RSTS_PARTITION_CLR = 0xfffffaaa
+	PM_RSTS_HALT          = 0x00000555
+
+	// watchdog ticks before a requested reset
+	resetTicks = 10
+)
+
+type platform struct{}
+
+func init() {
+	power.Register(&platform{})
+}
+
+// restart triggers a full reset through the watchdog timer.
+func restart() {
+	pm_rstc := reg.Read(bcm2835.PeripheralAddress(PM_RSTC))
+	pm_rstc = PM_PASSWORD | (pm_rstc & PM_RSTC_WRCFG_CLR) | PM_RSTC_WRCFG_FULL_RESET
+
+	reg.Write(bcm2835.PeripheralAddress(PM_WDOG), PM_PASSWORD|resetTicks)
+	reg.Write(bcm2835.PeripheralAddress(PM_RSTC), pm_rstc)
+
+	// wait for the watchdog to expire
+	for {
+	}
+}
+
+// Reset triggers a full board reset through the watchdog timer.
+func (p *platform) Reset() {
+	restart()
+}
+
+// Shutdown halts the board by selecting the firmware halt boot partition
+// before resetting, the board remains halted until power cycled.
+func (p *platform) Shutdown() {
+	pm_rsts := reg.Read(bcm2835.PeripheralAddress(PM_RSTS))
+	pm_rsts = PM_PASSWORD | (pm_rsts & PM_RSTS_PARTITION_CLR) | PM_RSTS_HALT
+
+	reg.Write(bcm2835.PeripheralAddress(PM_RSTS), pm_rsts)
+
+	restart()
+}
+
+// Reboot triggers a full board reset through the watchdog timer.
+func (p *platform) Reboot(reason string) {
+	print("reboot: ", reason, "\n")
+	restart()
+}
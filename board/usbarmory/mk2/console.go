@@ -13,6 +13,7 @@ package mk2
 import (
 	_ "unsafe"
 
+	"github.com/karlo195/tamago/console"
 	"github.com/karlo195/tamago/soc/nxp/imx6ul"
 )
 
@@ -25,7 +26,17 @@ import (
 //
 // On model UA-MKII-LAN the console is exposed through test pads.
 
+// Console is the console output manager, its default sink is imx6ul.UART2 (see
+// console.Console).
+var Console = &console.Console{
+	Default: uartTx,
+}
+
+func uartTx(c byte) {
+	imx6ul.UART2.Tx(c)
+}
+
 //go:linkname printk runtime.printk
 func printk(c byte) {
-	imx6ul.UART2.Tx(c)
+	Console.Tx(c)
 }
@@ -0,0 +1,34 @@
+// USB armory Mk II support for tamago/arm
+// https://github.com/karlo195/tamago
+//
+// Copyright (c) The TamaGo Authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+package mk2
+
+import (
+	"github.com/karlo195/tamago/power"
+)
+
+type platform struct{}
+
+func init() {
+	power.Register(&platform{})
+}
+
+// Reset causes the board to power cycle (see Reset()).
+func (p *platform) Reset() {
+	Reset()
+}
+
+// Shutdown is not supported as the board cannot be powered off by software,
+// the function returns immediately.
+func (p *platform) Shutdown() {}
+
+// Reboot causes the board to power cycle (see Reset()).
+func (p *platform) Reboot(reason string) {
+	print("reboot: ", reason, "\n")
+	Reset()
+}
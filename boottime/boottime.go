@@ -0,0 +1,174 @@
+// Boot time profiling
+// https://github.com/karlo195/tamago
+//
+// Copyright (c) The TamaGo Authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+// Package boottime implements boot time profiling through timestamped phase
+// markers, recorded by architecture and board packages during runtime
+// initialization (runtime.hwinit0, runtime.hwinit1 and board init()), to
+// identify where time is spent before application code is reached (e.g. to
+// optimize microVM cold-start latency).
+//
+// Applications can add their own markers and print the resulting report:
+//
+//	func main() {
+//		boottime.Mark("main")
+//		boottime.Report(os.Stdout)
+//		...
+//	}
+//
+// Markers are timestamped with the architecture cycle counter, which is reset
+// on processor (or VM) start, therefore the first marker reports the time
+// elapsed before Go runtime initialization (e.g. firmware or VMM boot).
+//
+// This package is only meant to be used with `GOOS=tamago` as supported by the
+// TamaGo framework for bare metal Go, see https://github.com/karlo195/tamago.
+package boottime
+
+import (
+	"fmt"
+	"io"
+	"sync/atomic"
+	"text/tabwriter"
+	"time"
+)
+
+// MaxMarkers is the maximum number of recorded markers, further markers are
+// discarded.
+const MaxMarkers = 64
+
+// Counter is the monotonic cycle counter used to timestamp markers, it is set
+// by architecture packages on early initialization. Markers are discarded
+// while unset.
+var Counter func() uint64
+
+// Frequency is the Counter frequency in Hz, used to convert marker timestamps,
+// it is set by architecture packages once calibrated.
+var Frequency uint64
+
+// Marker represents a boot phase marker.
+type Marker struct {
+	// Name is the phase name.
+	Name string
+	// Ticks is the Counter value at marker time.
+	Ticks uint64
+}
+
+// Phase represents a boot phase, spanning from its marker to the following
+// one.
+type Phase struct {
+	// Name is the phase name.
+	Name string
+	// Start is the phase start time, relative to Counter reset.
+	Start time.Duration
+	// Duration is the time elapsed until the following marker, or until
+	// the report for the last one.
+	Duration time.Duration
+}
+
+var (
+	markers [MaxMarkers]Marker
+	count   uint32
+)
+
+// Mark records a timestamped marker, with the argument name, for the boot
+// phase beginning at the time of invocation.
+//
+// The function does not allocate and can therefore be invoked before runtime
+// initialization (e.g. runtime.hwinit0), the argument should be a constant
+// string.
+//
+//go:nosplit
+func Mark(name string) {
+	if Counter == nil {
+		return
+	}
+
+	ticks := Counter()
+
+	if n := atomic.AddUint32(&count, 1); n <= MaxMarkers {
+		markers[n-1] = Marker{
+			Name:  name,
+			Ticks: ticks,
+		}
+	}
+}
+
+// Markers returns all recorded markers.
+func Markers() []Marker {
+	n := min(int(atomic.LoadUint32(&count)), MaxMarkers)
+	m := make([]Marker, n)
+	copy(m, markers[:n])
+
+	return m
+}
+
+func duration(ticks uint64) time.Duration {
+	if Frequency == 0 {
+		return 0
+	}
+
+	sec := ticks / Frequency
+	rem := ticks % Frequency
+
+	return time.Duration(sec)*time.Second + time.Duration(rem*uint64(time.Second)/Frequency)
+}
+
+// Phases returns the boot phases delimited by recorded markers, durations are
+// zero when the Counter frequency is unknown.
+func Phases() (phases []Phase) {
+	m := Markers()
+
+	if len(m) == 0 {
+		return
+	}
+
+	now := m[len(m)-1].Ticks
+
+	if Counter != nil {
+		now = Counter()
+	}
+
+	for i, marker := range m {
+		end := now
+
+		if i+1 < len(m) {
+			end = m[i+1].Ticks
+		}
+
+		phases = append(phases, Phase{
+			Name:     marker.Name,
+			Start:    duration(marker.Ticks),
+			Duration: duration(end - marker.Ticks),
+		})
+	}
+
+	return
+}
+
+// Report writes a human readable boot report, listing all phases with their
+// start time and duration, to the argument writer.
+func Report(w io.Writer) (err error) {
+	phases := Phases()
+
+	if len(phases) == 0 {
+		_, err = fmt.Fprintln(w, "no boot markers")
+		return
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintf(tw, "phase\tstart\tduration\t\n")
+
+	for _, p := range phases {
+		fmt.Fprintf(tw, "%s\t%v\t%v\t\n", p.Name, p.Start, p.Duration)
+	}
+
+	if n := atomic.LoadUint32(&count); n > MaxMarkers {
+		fmt.Fprintf(tw, "(%d markers discarded)\t\t\t\n", n-MaxMarkers)
+	}
+
+	return tw.Flush()
+}
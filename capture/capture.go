@@ -0,0 +1,141 @@
+// Input capture and quadrature encoder support
+// https://github.com/karlo195/tamago
+//
+// Copyright (c) The TamaGo Authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+// Package capture provides a common interface for timer input capture
+// channels, timestamping signal edges, along with helpers to measure pulse
+// period and width and to decode quadrature encoder signals.
+//
+// Input capture channels are implemented by SoC drivers (e.g. gpt.GPT on NXP
+// i.MX6UL, bcm2835.GPIOCapture on Raspberry Pi):
+//
+//	var p capture.Pulse
+//
+//	for {
+//		if s, ok := ch.Capture(); ok {
+//			p.Update(s)
+//		}
+//
+//		rpm := 60 / p.Period(ch.Frequency()).Seconds()
+//		...
+//	}
+//
+// This package is only meant to be used with `GOOS=tamago` as supported by the
+// TamaGo framework for bare metal Go, see https://github.com/karlo195/tamago.
+package capture
+
+import (
+	"time"
+)
+
+// Edge represents the signal transitions captured by an input channel.
+type Edge int
+
+// Capture edges
+const (
+	// Rising captures low to high transitions.
+	Rising Edge = 1 << iota
+	// Falling captures high to low transitions.
+	Falling
+	// Both captures all transitions.
+	Both = Rising | Falling
+)
+
+// Sample represents a captured signal edge.
+type Sample struct {
+	// Ticks is the capture timestamp, in timer ticks, as a monotonic
+	// counter extended to 64 bits.
+	Ticks uint64
+	// Rising indicates a low to high transition.
+	Rising bool
+}
+
+// Channel represents an input capture channel.
+type Channel interface {
+	// Capture returns the oldest pending sample, if any.
+	Capture() (s Sample, ok bool)
+	// Frequency returns the timestamp tick frequency in Hz.
+	Frequency() uint32
+}
+
+// Duration converts the argument number of ticks, at the argument frequency,
+// to a time duration.
+func Duration(ticks uint64, freq uint32) time.Duration {
+	if freq == 0 {
+		return 0
+	}
+
+	sec := ticks / uint64(freq)
+	rem := ticks % uint64(freq)
+
+	return time.Duration(sec)*time.Second + time.Duration(rem*uint64(time.Second)/uint64(freq))
+}
+
+// Pulse measures the period and width of a periodic signal from consecutive
+// capture samples.
+type Pulse struct {
+	rise    uint64
+	fall    uint64
+	rising  bool
+	falling bool
+
+	period uint64
+	width  uint64
+}
+
+// Update processes a capture sample, the period is measured between
+// consecutive rising edges (or falling edges when only those are captured),
+// the width between a rising edge and the following falling one.
+func (p *Pulse) Update(s Sample) {
+	if s.Rising {
+		if p.rising {
+			p.period = s.Ticks - p.rise
+		}
+
+		p.rise = s.Ticks
+		p.rising = true
+
+		return
+	}
+
+	if p.falling && !p.rising {
+		p.period = s.Ticks - p.fall
+	}
+
+	if p.rising && s.Ticks > p.rise {
+		p.width = s.Ticks - p.rise
+	}
+
+	p.fall = s.Ticks
+	p.falling = true
+}
+
+// Period returns the last measured signal period at the argument timestamp
+// frequency.
+func (p *Pulse) Period(freq uint32) time.Duration {
+	return Duration(p.period, freq)
+}
+
+// Width returns the last measured pulse (high level) width at the argument
+// timestamp frequency.
+func (p *Pulse) Width(freq uint32) time.Duration {
+	return Duration(p.width, freq)
+}
+
+// Duty returns the last measured duty cycle (0.0 to 1.0).
+func (p *Pulse) Duty() float64 {
+	if p.period == 0 {
+		return 0
+	}
+
+	return min(float64(p.width)/float64(p.period), 1)
+}
+
+// Reset clears all measurements.
+func (p *Pulse) Reset() {
+	*p = Pulse{}
+}
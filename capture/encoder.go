@@ -0,0 +1,105 @@
+// Input capture and quadrature encoder support
+// https://github.com/karlo195/tamago
+//
+// Copyright (c) The TamaGo Authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+package capture
+
+import (
+	"sync"
+)
+
+// quadrature transitions indexed by previous and current AB state, valid
+// transitions count ±1 while invalid ones (both signals changed) count 0 and
+// are reported as errors
+var transitions = [16]int8{
+	0, -1, +1, 0,
+	+1, 0, 0, -1,
+	-1, 0, 0, +1,
+	0, +1, -1, 0,
+}
+
+// Encoder represents a quadrature encoder decoder, counting all edges of its
+// A and B signals (x4 decoding).
+//
+// Signal levels must be sampled on every edge (e.g. from a GPIO edge capture
+// channel or interrupt), transitions missed due to insufficient sampling rate
+// are counted as errors.
+type Encoder struct {
+	sync.Mutex
+
+	// Invert reverses the counting direction.
+	Invert bool
+
+	state    uint8
+	init     bool
+	position int64
+	errors   uint64
+}
+
+// Update processes the current A and B signal levels.
+func (e *Encoder) Update(a bool, b bool) {
+	var state uint8
+
+	if a {
+		state |= 0b10
+	}
+
+	if b {
+		state |= 0b01
+	}
+
+	e.Lock()
+	defer e.Unlock()
+
+	if !e.init {
+		e.state = state
+		e.init = true
+		return
+	}
+
+	if state == e.state {
+		return
+	}
+
+	delta := transitions[e.state<<2|state]
+
+	if delta == 0 {
+		e.errors++
+	} else if e.Invert {
+		e.position -= int64(delta)
+	} else {
+		e.position += int64(delta)
+	}
+
+	e.state = state
+}
+
+// Position returns the encoder position in counts (four per encoder cycle).
+func (e *Encoder) Position() int64 {
+	e.Lock()
+	defer e.Unlock()
+
+	return e.position
+}
+
+// Errors returns the number of invalid transitions detected.
+func (e *Encoder) Errors() uint64 {
+	e.Lock()
+	defer e.Unlock()
+
+	return e.errors
+}
+
+// Reset sets the encoder position to the argument value and clears the error
+// count.
+func (e *Encoder) Reset(position int64) {
+	e.Lock()
+	defer e.Unlock()
+
+	e.position = position
+	e.errors = 0
+}
@@ -0,0 +1,89 @@
+// Checksum primitives
+// https://github.com/karlo195/tamago
+//
+// Copyright (c) The TamaGo Authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+// Package checksum implements optimized checksum primitives for network and
+// storage drivers, adopting the following reference specifications:
+//   - RFC1071 - Computing the Internet Checksum
+//   - RFC3720 - Internet Small Computer Systems Interface (iSCSI) - B.4. CRC Calculations
+//
+// The Internet checksum is computed with architecture specific assembly
+// (SSE2 on amd64, unrolled add with carry on arm), CRC32C relies on the
+// hash/crc32 Castagnoli implementation which uses the SSE4.2 CRC32
+// instruction on amd64, when available.
+//
+// This package is only meant to be used with `GOOS=tamago` as supported by the
+// TamaGo framework for bare metal Go, see https://github.com/karlo195/tamago.
+package checksum
+
+import (
+	"encoding/binary"
+	"hash/crc32"
+)
+
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+// fold reduces a 64-bit one's complement sum to 16 bits.
+func fold(sum uint64) uint16 {
+	sum = sum&0xffffffff + sum>>32
+	sum = sum&0xffffffff + sum>>32
+	sum = sum&0xffff + sum>>16
+	sum = sum&0xffff + sum>>16
+
+	return uint16(sum)
+}
+
+// sumGeneric returns the 64-bit one's complement sum of the argument
+// buffer, taken as a sequence of little-endian 32-bit words followed by an
+// optional 16-bit one, the length must be even.
+func sumGeneric(b []byte) (sum uint64) {
+	for ; len(b) >= 4; b = b[4:] {
+		sum += uint64(binary.LittleEndian.Uint32(b))
+	}
+
+	if len(b) >= 2 {
+		sum += uint64(binary.LittleEndian.Uint16(b))
+	}
+
+	return
+}
+
+// Sum returns the 16-bit one's complement sum of the argument buffer, in
+// network byte order, added to the argument partial sum (e.g. of a
+// pseudo-header).
+//
+// The sum can be computed incrementally over multiple buffers, all of which,
+// except the last one, must have an even length.
+func Sum(b []byte, initial uint16) uint16 {
+	n := len(b) &^ 1
+	sum := sum(b[:n])
+
+	if n < len(b) {
+		// pad odd length with a zero byte
+		sum += uint64(b[n])
+	}
+
+	// the sum is byte order independent (RFC1071 2.B)
+	s := fold(sum)
+	s = s>>8 | s<<8
+
+	return fold(uint64(s) + uint64(initial))
+}
+
+// Internet returns the Internet checksum (one's complement of the one's
+// complement sum) of the argument buffer, as used in IPv4, ICMP, TCP and UDP
+// headers.
+func Internet(b []byte) uint16 {
+	return ^Sum(b, 0)
+}
+
+// CRC32C returns the CRC-32 checksum, with the Castagnoli polynomial, of the
+// argument buffer updated from the argument initial value, as used in iSCSI,
+// SCTP, ext4 and VirtIO block integrity metadata.
+func CRC32C(crc uint32, b []byte) uint32 {
+	return crc32.Update(crc, castagnoli, b)
+}
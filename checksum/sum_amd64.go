@@ -0,0 +1,45 @@
+// Checksum primitives
+// https://github.com/karlo195/tamago
+//
+// Copyright (c) The TamaGo Authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+package checksum
+
+import (
+	"github.com/karlo195/tamago/amd64"
+)
+
+// SSE2 is part of the amd64 baseline, the portable implementation is
+// retained for testing (see Implementation).
+var sumImpl = amd64.NewDispatcher(
+	amd64.Implementation[func([]byte) uint64]{
+		Name: "sse2",
+		Fn:   sumSSE2,
+	},
+	amd64.Implementation[func([]byte) uint64]{
+		Name: "generic",
+		Fn:   sumGeneric,
+	},
+)
+
+// defined in sum_amd64.s
+func sum_sse2(b []byte) (sum uint64, n int)
+
+func sumSSE2(b []byte) uint64 {
+	sum, n := sum_sse2(b)
+	return sum + sumGeneric(b[n:])
+}
+
+func sum(b []byte) uint64 {
+	return sumImpl.Fn()(b)
+}
+
+// Implementation selects the Internet checksum implementation, to allow
+// testing of all variants ("sse2", "generic"), an empty name restores
+// automatic selection.
+func Implementation(name string) error {
+	return sumImpl.Select(name)
+}
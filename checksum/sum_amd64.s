@@ -0,0 +1,55 @@
+// Checksum primitives
+// https://github.com/usbarmory/tamago
+//
+// Copyright (c) The TamaGo Authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+#include "textflag.h"
+
+// func sum_sse2(b []byte) (sum uint64, n int)
+//
+// Each 16 byte block is split in 32-bit words, zero extended to 64-bit lanes
+// to accumulate without carries, only full 32 byte blocks are processed.
+TEXT ·sum_sse2(SB),NOSPLIT,$0-40
+	MOVQ	b_base+0(FP), SI
+	MOVQ	b_len+8(FP), CX
+	ANDQ	$~31, CX
+	MOVQ	CX, n+32(FP)
+
+	PXOR	X0, X0		// accumulator
+	PXOR	X1, X1		// accumulator
+	PXOR	X7, X7		// zero
+
+	TESTQ	CX, CX
+	JZ	reduce
+
+loop:
+	MOVOU	0(SI), X2
+	MOVOU	16(SI), X4
+	MOVO	X2, X3
+	MOVO	X4, X5
+
+	PUNPCKLLQ	X7, X2
+	PUNPCKHLQ	X7, X3
+	PUNPCKLLQ	X7, X4
+	PUNPCKHLQ	X7, X5
+
+	PADDQ	X2, X0
+	PADDQ	X3, X1
+	PADDQ	X4, X0
+	PADDQ	X5, X1
+
+	ADDQ	$32, SI
+	SUBQ	$32, CX
+	JNZ	loop
+
+reduce:
+	PADDQ	X1, X0
+	MOVQ	X0, AX
+	PSRLDQ	$8, X0
+	MOVQ	X0, BX
+	ADDQ	BX, AX
+	MOVQ	AX, sum+24(FP)
+	RET
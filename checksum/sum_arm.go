@@ -0,0 +1,49 @@
+// Checksum primitives
+// https://github.com/karlo195/tamago
+//
+// Copyright (c) The TamaGo Authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+package checksum
+
+import (
+	"encoding/binary"
+	"errors"
+	"unsafe"
+)
+
+// defined in sum_arm.s
+func sum_arm(b []byte) (sum uint64, n int)
+
+func sum(b []byte) (s uint64) {
+	if len(b) == 0 {
+		return
+	}
+
+	// the sum is invariant to 16-bit aligned splits, therefore a 2 byte
+	// head allows word aligned loads
+	switch uintptr(unsafe.Pointer(&b[0])) & 3 {
+	case 0:
+	case 2:
+		s = uint64(binary.LittleEndian.Uint16(b))
+		b = b[2:]
+	default:
+		return sumGeneric(b)
+	}
+
+	s2, n := sum_arm(b)
+
+	return s + s2 + sumGeneric(b[n:])
+}
+
+// Implementation selects the Internet checksum implementation, only the
+// default one ("") is available on this architecture.
+func Implementation(name string) error {
+	if name != "" {
+		return errors.New("implementation not found")
+	}
+
+	return nil
+}
@@ -0,0 +1,44 @@
+// Checksum primitives
+// https://github.com/usbarmory/tamago
+//
+// Copyright (c) The TamaGo Authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+#include "textflag.h"
+
+// func sum_arm(b []byte) (sum uint64, n int)
+//
+// The buffer must be word aligned, only full 16 byte blocks are processed.
+TEXT ·sum_arm(SB),NOSPLIT,$0-24
+	MOVW	b_base+0(FP), R1
+	MOVW	b_len+4(FP), R2
+	BIC	$15, R2
+	MOVW	R2, n+20(FP)
+
+	MOVW	$0, R0		// sum (low)
+	MOVW	$0, R3		// sum (high)
+
+	CMP	$0, R2
+	BEQ	done
+
+loop:
+	MOVM.IA.W	(R1), [R4-R7]
+
+	ADD.S	R4, R0
+	ADC	$0, R3
+	ADD.S	R5, R0
+	ADC	$0, R3
+	ADD.S	R6, R0
+	ADC	$0, R3
+	ADD.S	R7, R0
+	ADC	$0, R3
+
+	SUB.S	$16, R2
+	BNE	loop
+
+done:
+	MOVW	R0, sum_lo+12(FP)
+	MOVW	R3, sum_hi+16(FP)
+	RET
@@ -0,0 +1,29 @@
+// Checksum primitives
+// https://github.com/karlo195/tamago
+//
+// Copyright (c) The TamaGo Authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+//go:build !amd64 && !arm
+
+package checksum
+
+import (
+	"errors"
+)
+
+func sum(b []byte) uint64 {
+	return sumGeneric(b)
+}
+
+// Implementation selects the Internet checksum implementation, only the
+// default one ("") is available on this architecture.
+func Implementation(name string) error {
+	if name != "" {
+		return errors.New("implementation not found")
+	}
+
+	return nil
+}
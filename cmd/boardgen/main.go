@@ -0,0 +1,335 @@
+// Board package generator
+// https://github.com/karlo195/tamago
+//
+// Copyright (c) The TamaGo Authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+// Command boardgen generates the boilerplate of board support packages
+// (memory map, peripheral instances, IRQ wiring and runtime initialization)
+// from a declarative description, to be invoked through go:generate:
+//
+//	//go:generate go run github.com/karlo195/tamago/cmd/boardgen board.toml
+//
+// Board specific logic (e.g. pad configuration, console, power management) is
+// kept in hand-written files within the same package.
+//
+// The description uses a TOML subset (tables, arrays of tables and string,
+// integer or boolean values):
+//
+//	[board]
+//	package = "mx6ullevk"                 # Go package name
+//	title = "MCIMX6ULL-EVK support"       # source header title
+//	arch = "arm"                          # GOARCH
+//
+//	[soc]
+//	import = "github.com/karlo195/tamago/soc/nxp/imx6ul"
+//	init = true                           # invoke SoC Init() on runtime hwinit1
+//
+//	[memory]
+//	size = 0x20000000                     # runtime.ramSize
+//	comment = "A single 512MB DDR3 RAM module."
+//
+//	[[device]]
+//	name = "UART1"                        # board variable name
+//	instance = "UART1"                    # SoC instance (default name)
+//	comment = "Debug console"             # optional
+//	init = true                           # invoke Init() after SoC Init()
+//	irq = 58                              # optional, defines UART1_IRQ
+//
+// The generated files, `board_generated.go` and `mem_generated.go` (which can
+// be excluded with the `linkramsize` build tag), are written in the output
+// directory.
+//
+// Usage:
+//
+//	boardgen [-o <output directory>] <description file>
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"go/format"
+	"go/token"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"text/template"
+)
+
+const (
+	boardFile = "board_generated.go"
+	memFile   = "mem_generated.go"
+)
+
+type device struct {
+	Name     string
+	Instance string
+	Comment  string
+	Init     bool
+	IRQ      int64
+	HasIRQ   bool
+}
+
+type board struct {
+	Source  string
+	Package string
+	Title   string
+	Arch    string
+
+	SoC     string
+	SoCName string
+	SoCInit bool
+
+	RAMSize    int64
+	RAMType    string
+	RAMComment string
+
+	Devices []*device
+}
+
+const header = `// Code generated by boardgen from {{.Source}}; DO NOT EDIT.
+
+// {{.Title}}
+// https://github.com/karlo195/tamago
+//
+// Copyright (c) The TamaGo Authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+`
+
+var boardTemplate = template.Must(template.New("board").Parse(header + `
+package {{.Package}}
+
+import (
+	_ "unsafe"
+
+	"{{.SoC}}"
+)
+{{if .Devices}}
+// Peripheral instances
+var (
+{{- range .Devices}}
+{{- if .Comment}}
+	// {{.Comment}}
+{{- end}}
+	{{.Name}} = {{$.SoCName}}.{{.Instance}}
+{{- end}}
+)
+{{end}}
+{{- $irq := false}}{{range .Devices}}{{if .HasIRQ}}{{$irq = true}}{{end}}{{end}}
+{{- if $irq}}
+// Peripheral interrupts
+const (
+{{- range .Devices}}{{if .HasIRQ}}
+	{{.Name}}_IRQ = {{.IRQ}}
+{{- end}}{{end}}
+)
+{{end}}
+// Init takes care of the lower level initialization triggered early in runtime
+// setup (post World start).
+//
+//go:linkname Init runtime.hwinit1
+func Init() {
+{{- if .SoCInit}}
+	{{.SoCName}}.Init()
+{{end}}
+{{- range .Devices}}{{if .Init}}
+	{{$.SoCName}}.{{.Instance}}.Init()
+{{- end}}{{end}}
+}
+`))
+
+var memTemplate = template.Must(template.New("mem").Parse(header + `
+//go:build !linkramsize
+
+package {{.Package}}
+
+import (
+	_ "unsafe"
+)
+
+// Applications can override ramSize with the ` + "`linkramsize`" + ` build tag.
+//
+// This is useful when large DMA descriptors are required to re-initialize
+// tamago ` + "`dma`" + ` package in external RAM.
+{{if .RAMComment}}
+// {{.RAMComment}}
+{{end}}
+//go:linkname ramSize runtime.ramSize
+var ramSize {{.RAMType}} = {{printf "%#x" .RAMSize}}
+`))
+
+func load(name string) (b *board, err error) {
+	f, err := os.Open(name)
+
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	doc, err := parse(f)
+
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", name, err)
+	}
+
+	b = &board{Source: filepath.Base(name)}
+
+	t := doc.tables["board"]
+
+	if b.Package, err = t.String("package"); err != nil {
+		return
+	}
+
+	if b.Title, err = t.String("title"); err != nil {
+		return
+	}
+
+	if b.Arch, err = t.String("arch"); err != nil {
+		return
+	}
+
+	if !token.IsIdentifier(b.Package) {
+		return nil, errors.New("invalid board package")
+	}
+
+	switch b.Arch {
+	case "arm":
+		b.RAMType = "uint32"
+	case "amd64", "arm64", "riscv64":
+		b.RAMType = "uint64"
+	default:
+		return nil, errors.New("invalid board arch")
+	}
+
+	if b.Title == "" {
+		b.Title = fmt.Sprintf("%s support", b.Package)
+	}
+
+	b.Title += " for tamago/" + b.Arch
+
+	t = doc.tables["soc"]
+
+	if b.SoC, err = t.String("import"); err != nil {
+		return
+	}
+
+	if b.SoCInit, err = t.Bool("init"); err != nil {
+		return
+	}
+
+	if b.SoC == "" {
+		return nil, errors.New("missing SoC import path")
+	}
+
+	b.SoCName = path.Base(b.SoC)
+
+	t = doc.tables["memory"]
+
+	size, ok, err := t.Int("size")
+
+	if err != nil {
+		return
+	}
+
+	if !ok || size <= 0 {
+		return nil, errors.New("missing memory size")
+	}
+
+	b.RAMSize = size
+
+	if b.RAMComment, err = t.String("comment"); err != nil {
+		return
+	}
+
+	names := make(map[string]bool)
+
+	for _, t := range doc.arrays["device"] {
+		d := &device{}
+
+		if d.Name, err = t.String("name"); err != nil {
+			return
+		}
+
+		if d.Instance, err = t.String("instance"); err != nil {
+			return
+		}
+
+		if d.Comment, err = t.String("comment"); err != nil {
+			return
+		}
+
+		if d.Init, err = t.Bool("init"); err != nil {
+			return
+		}
+
+		if d.IRQ, d.HasIRQ, err = t.Int("irq"); err != nil {
+			return
+		}
+
+		if d.Instance == "" {
+			d.Instance = d.Name
+		}
+
+		if !token.IsIdentifier(d.Name) || !token.IsIdentifier(d.Instance) {
+			return nil, fmt.Errorf("invalid device name %q", d.Name)
+		}
+
+		if names[d.Name] {
+			return nil, fmt.Errorf("duplicate device %s", d.Name)
+		}
+
+		names[d.Name] = true
+		b.Devices = append(b.Devices, d)
+	}
+
+	return
+}
+
+func generate(dir string, name string, tmpl *template.Template, b *board) (err error) {
+	buf := new(bytes.Buffer)
+
+	if err = tmpl.Execute(buf, b); err != nil {
+		return
+	}
+
+	src, err := format.Source(buf.Bytes())
+
+	if err != nil {
+		return fmt.Errorf("%s: %v", name, err)
+	}
+
+	return os.WriteFile(filepath.Join(dir, name), src, 0644)
+}
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("boardgen: ")
+
+	dir := flag.String("o", ".", "output directory")
+	flag.Parse()
+
+	if flag.NArg() != 1 {
+		log.Fatal("usage: boardgen [-o <output directory>] <description file>")
+	}
+
+	b, err := load(flag.Arg(0))
+
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if err = generate(*dir, boardFile, boardTemplate, b); err != nil {
+		log.Fatal(err)
+	}
+
+	if err = generate(*dir, memFile, memTemplate, b); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,148 @@
+// Board package generator
+// https://github.com/karlo195/tamago
+//
+// Copyright (c) The TamaGo Authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// table represents a TOML table as key/value pairs, values are either
+// string, int64 or bool.
+type table map[string]any
+
+// document represents a parsed TOML document, limited to the subset used by
+// board descriptions: tables, arrays of tables and string, integer or
+// boolean values.
+type document struct {
+	tables map[string]table
+	arrays map[string][]table
+}
+
+func parseValue(s string) (any, error) {
+	switch {
+	case strings.HasPrefix(s, `"`):
+		return strconv.Unquote(s)
+	case s == "true":
+		return true, nil
+	case s == "false":
+		return false, nil
+	}
+
+	return strconv.ParseInt(strings.ReplaceAll(s, "_", ""), 0, 64)
+}
+
+// stripComment removes a trailing comment, outside of strings, from a line.
+func stripComment(line string) string {
+	quoted := false
+
+	for i := 0; i < len(line); i++ {
+		switch line[i] {
+		case '\\':
+			if quoted {
+				i++
+			}
+		case '"':
+			quoted = !quoted
+		case '#':
+			if !quoted {
+				return line[:i]
+			}
+		}
+	}
+
+	return line
+}
+
+func parse(r io.Reader) (doc *document, err error) {
+	doc = &document{
+		tables: make(map[string]table),
+		arrays: make(map[string][]table),
+	}
+
+	var cur table
+
+	s := bufio.NewScanner(r)
+
+	for n := 1; s.Scan(); n++ {
+		line := strings.TrimSpace(stripComment(s.Text()))
+
+		switch {
+		case line == "":
+			continue
+		case strings.HasPrefix(line, "[[") && strings.HasSuffix(line, "]]"):
+			name := strings.TrimSpace(line[2 : len(line)-2])
+			cur = make(table)
+			doc.arrays[name] = append(doc.arrays[name], cur)
+		case strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]"):
+			name := strings.TrimSpace(line[1 : len(line)-1])
+
+			if _, ok := doc.tables[name]; ok {
+				return nil, fmt.Errorf("line %d, duplicate table %s", n, name)
+			}
+
+			cur = make(table)
+			doc.tables[name] = cur
+		default:
+			key, val, ok := strings.Cut(line, "=")
+
+			if !ok || cur == nil {
+				return nil, fmt.Errorf("line %d, invalid syntax", n)
+			}
+
+			key = strings.TrimSpace(key)
+
+			if _, ok := cur[key]; ok {
+				return nil, fmt.Errorf("line %d, duplicate key %s", n, key)
+			}
+
+			if cur[key], err = parseValue(strings.TrimSpace(val)); err != nil {
+				return nil, fmt.Errorf("line %d, invalid value for %s", n, key)
+			}
+		}
+	}
+
+	return doc, s.Err()
+}
+
+func (t table) String(key string) (string, error) {
+	switch v := t[key].(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	}
+
+	return "", fmt.Errorf("%s must be a string", key)
+}
+
+func (t table) Int(key string) (int64, bool, error) {
+	switch v := t[key].(type) {
+	case nil:
+		return 0, false, nil
+	case int64:
+		return v, true, nil
+	}
+
+	return 0, false, fmt.Errorf("%s must be an integer", key)
+}
+
+func (t table) Bool(key string) (bool, error) {
+	switch v := t[key].(type) {
+	case nil:
+		return false, nil
+	case bool:
+		return v, nil
+	}
+
+	return false, fmt.Errorf("%s must be a boolean", key)
+}
@@ -0,0 +1,176 @@
+// Multiboot2 header tool
+// https://github.com/karlo195/tamago
+//
+// Copyright (c) The TamaGo Authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+// Command multiboot2 adds a Multiboot2 header to TamaGo amd64 ELF executables,
+// allowing direct kernel boot by GRUB (or any other Multiboot2 compliant boot
+// loader) without the Linux boot protocol.
+//
+// As the Go linker does not allow custom sections within the first 32KB of the
+// image, the header is written within the unused space following the ELF
+// program headers, with an entry address tag pointing to the `cpuinit` 32-bit
+// entry point.
+//
+// Usage:
+//
+//	multiboot2 <ELF file>
+package main
+
+import (
+	"debug/elf"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+)
+
+// Multiboot2 header (Multiboot2 Specification version 2.0 - 3.1.1)
+const (
+	headerMagic     = 0xe85250d6
+	headerArchI386  = 0
+	headerAlign     = 8
+	headerSearchLen = 32768
+
+	tagEnd          = 0
+	tagEntryAddress = 3
+)
+
+// 32-bit entry point symbol (see amd64/init.s)
+const entrySymbol = "cpuinit"
+
+func entryPoint(f *elf.File) (addr uint64, err error) {
+	syms, err := f.Symbols()
+
+	if err != nil {
+		return
+	}
+
+	for _, sym := range syms {
+		if strings.TrimSuffix(sym.Name, ".abi0") != entrySymbol {
+			continue
+		}
+
+		for _, p := range f.Progs {
+			if p.Type == elf.PT_LOAD && sym.Value >= p.Vaddr && sym.Value < p.Vaddr+p.Memsz {
+				return sym.Value - p.Vaddr + p.Paddr, nil
+			}
+		}
+	}
+
+	return 0, fmt.Errorf("%s symbol not found", entrySymbol)
+}
+
+func tag(typ uint16, data []byte) (buf []byte) {
+	buf = binary.LittleEndian.AppendUint16(buf, typ)
+	buf = binary.LittleEndian.AppendUint16(buf, 0)
+	buf = binary.LittleEndian.AppendUint32(buf, uint32(8+len(data)))
+	buf = append(buf, data...)
+
+	// tags are 8 bytes aligned
+	for len(buf)%headerAlign != 0 {
+		buf = append(buf, 0)
+	}
+
+	return
+}
+
+func header(entry uint32) (buf []byte) {
+	var tags []byte
+
+	tags = append(tags, tag(tagEntryAddress, binary.LittleEndian.AppendUint32(nil, entry))...)
+	tags = append(tags, tag(tagEnd, nil)...)
+
+	length := uint32(16 + len(tags))
+
+	buf = binary.LittleEndian.AppendUint32(buf, headerMagic)
+	buf = binary.LittleEndian.AppendUint32(buf, headerArchI386)
+	buf = binary.LittleEndian.AppendUint32(buf, length)
+	buf = binary.LittleEndian.AppendUint32(buf, -(headerMagic + headerArchI386 + length))
+
+	return append(buf, tags...)
+}
+
+func addHeader(path string) (err error) {
+	f, err := elf.Open(path)
+
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	if f.Class != elf.ELFCLASS64 || f.Machine != elf.EM_X86_64 {
+		return errors.New("unsupported ELF file")
+	}
+
+	entry, err := entryPoint(f)
+
+	if err != nil {
+		return
+	}
+
+	if entry > 0xffffffff {
+		return fmt.Errorf("entry point %#x is not 32-bit addressable", entry)
+	}
+
+	buf := header(uint32(entry))
+
+	// ELF64 header: e_phoff (0x20), e_phentsize (0x36), e_phnum (0x38)
+	ehdr := make([]byte, 64)
+
+	out, err := os.OpenFile(path, os.O_RDWR, 0)
+
+	if err != nil {
+		return
+	}
+	defer out.Close()
+
+	if _, err = io.ReadFull(out, ehdr); err != nil {
+		return
+	}
+
+	phoff := binary.LittleEndian.Uint64(ehdr[0x20:])
+	phsize := uint64(binary.LittleEndian.Uint16(ehdr[0x36:])) * uint64(binary.LittleEndian.Uint16(ehdr[0x38:]))
+
+	off := (phoff + phsize + headerAlign - 1) &^ (headerAlign - 1)
+	end := off + uint64(len(buf))
+
+	if end > headerSearchLen {
+		return fmt.Errorf("no room for header within the first %d bytes", headerSearchLen)
+	}
+
+	// the header must not overlap any section or segment contents
+	for _, p := range f.Progs {
+		if p.Filesz > 0 && p.Off > 0 && p.Off < end && p.Off+p.Filesz > off {
+			return fmt.Errorf("header overlaps segment at %#x", p.Off)
+		}
+	}
+
+	for _, s := range f.Sections {
+		if s.Type != elf.SHT_NOBITS && s.Size > 0 && s.Offset < end && s.Offset+s.Size > off {
+			return fmt.Errorf("header overlaps section %s", s.Name)
+		}
+	}
+
+	_, err = out.WriteAt(buf, int64(off))
+
+	return
+}
+
+func main() {
+	log.SetFlags(0)
+
+	if len(os.Args) != 2 {
+		log.Fatalf("usage: %s <ELF file>", os.Args[0])
+	}
+
+	if err := addHeader(os.Args[1]); err != nil {
+		log.Fatalf("error, %v", err)
+	}
+}
@@ -0,0 +1,143 @@
+// PVH ELF note tool
+// https://github.com/karlo195/tamago
+//
+// Copyright (c) The TamaGo Authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+// Command pvh adds a Xen PVH direct boot entry point (XEN_ELFNOTE_PHYS32_ENTRY)
+// to TamaGo amd64 ELF executables, allowing direct kernel boot on QEMU and
+// Cloud Hypervisor without the Linux boot protocol.
+//
+// As the Go linker does not allow custom ELF notes, the existing Go build ID
+// note segment is overwritten with the PVH one, pointing to the `cpuinit`
+// 32-bit entry point.
+//
+// Usage:
+//
+//	pvh <ELF file>
+package main
+
+import (
+	"debug/elf"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+)
+
+// Xen ELF notes (xen/include/public/elfnote.h)
+const (
+	xenNoteName        = "Xen\x00"
+	xenNoteInfo        = 0
+	xenNotePhys32Entry = 18
+)
+
+// note header length
+const noteHeaderLength = 12
+
+// 32-bit entry point symbol (see amd64/init.s)
+const entrySymbol = "cpuinit"
+
+func entryPoint(f *elf.File) (addr uint64, err error) {
+	syms, err := f.Symbols()
+
+	if err != nil {
+		return
+	}
+
+	for _, sym := range syms {
+		if strings.TrimSuffix(sym.Name, ".abi0") != entrySymbol {
+			continue
+		}
+
+		for _, p := range f.Progs {
+			if p.Type == elf.PT_LOAD && sym.Value >= p.Vaddr && sym.Value < p.Vaddr+p.Memsz {
+				return sym.Value - p.Vaddr + p.Paddr, nil
+			}
+		}
+	}
+
+	return 0, fmt.Errorf("%s symbol not found", entrySymbol)
+}
+
+func note(typ uint32, desc []byte) (buf []byte) {
+	buf = binary.LittleEndian.AppendUint32(buf, uint32(len(xenNoteName)))
+	buf = binary.LittleEndian.AppendUint32(buf, uint32(len(desc)))
+	buf = binary.LittleEndian.AppendUint32(buf, typ)
+	buf = append(buf, xenNoteName...)
+
+	return append(buf, desc...)
+}
+
+func addNote(path string) (err error) {
+	f, err := elf.Open(path)
+
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	if f.Class != elf.ELFCLASS64 || f.Machine != elf.EM_X86_64 {
+		return errors.New("unsupported ELF file")
+	}
+
+	entry, err := entryPoint(f)
+
+	if err != nil {
+		return
+	}
+
+	if entry > 0xffffffff {
+		return fmt.Errorf("entry point %#x is not 32-bit addressable", entry)
+	}
+
+	var seg *elf.Prog
+
+	for _, p := range f.Progs {
+		if p.Type == elf.PT_NOTE {
+			seg = p
+			break
+		}
+	}
+
+	if seg == nil {
+		return errors.New("note segment not found")
+	}
+
+	buf := note(xenNotePhys32Entry, binary.LittleEndian.AppendUint32(nil, uint32(entry)))
+	pad := int(seg.Filesz) - len(buf) - noteHeaderLength - len(xenNoteName)
+
+	if pad < 0 || pad%4 != 0 {
+		return fmt.Errorf("invalid note segment size (%d)", seg.Filesz)
+	}
+
+	// fill the remaining segment with an ignored note
+	buf = append(buf, note(xenNoteInfo, make([]byte, pad))...)
+
+	out, err := os.OpenFile(path, os.O_WRONLY, 0)
+
+	if err != nil {
+		return
+	}
+	defer out.Close()
+
+	_, err = out.WriteAt(buf, int64(seg.Off))
+
+	return
+}
+
+func main() {
+	log.SetFlags(0)
+
+	if len(os.Args) != 2 {
+		log.Fatalf("usage: %s <ELF file>", os.Args[0])
+	}
+
+	if err := addNote(os.Args[1]); err != nil {
+		log.Fatalf("error, %v", err)
+	}
+}
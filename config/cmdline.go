@@ -0,0 +1,68 @@
+// Runtime configuration support
+// https://github.com/karlo195/tamago
+//
+// Copyright (c) The TamaGo Authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+package config
+
+import (
+	"errors"
+	"strings"
+)
+
+// CommandLine represents a kernel command line configuration source (e.g.
+// amd64.CommandLine()), with space separated `key=value` entries.
+//
+// Values can be enclosed in double quotes to include spaces, entries without
+// value (flags) are set with an empty one and parsing terminates at `--`.
+type CommandLine string
+
+// Name returns the configuration source name.
+func (s CommandLine) Name() string {
+	return "cmdline"
+}
+
+// Load returns the command line key/value entries.
+func (s CommandLine) Load() (entries map[string]string, err error) {
+	var quoted bool
+	var fields []string
+	var field strings.Builder
+
+	entries = make(map[string]string)
+
+	for _, c := range string(s) {
+		switch {
+		case c == '"':
+			quoted = !quoted
+		case !quoted && (c == ' ' || c == '\t' || c == '\n'):
+			if field.Len() > 0 {
+				fields = append(fields, field.String())
+				field.Reset()
+			}
+		default:
+			field.WriteRune(c)
+		}
+	}
+
+	if quoted {
+		return nil, errors.New("unterminated quote")
+	}
+
+	if field.Len() > 0 {
+		fields = append(fields, field.String())
+	}
+
+	for _, f := range fields {
+		if f == "--" {
+			break
+		}
+
+		key, val, _ := strings.Cut(f, "=")
+		entries[key] = val
+	}
+
+	return
+}
@@ -0,0 +1,194 @@
+// Runtime configuration support
+// https://github.com/karlo195/tamago
+//
+// Copyright (c) The TamaGo Authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+// Package config implements runtime configuration merging key/value entries
+// from the host channels available on each platform (e.g. kernel command
+// line, QEMU fw_cfg files, Firecracker MMDS, device tree /chosen node) into a
+// single typed accessor:
+//
+//	fw := &fwcfg.FwCfg{}
+//	fw.Init()
+//
+//	cfg := &config.Config{
+//		Sources: []config.Source{
+//			config.CommandLine(amd64.CommandLine()),
+//			fw,
+//		},
+//	}
+//
+//	if err := cfg.Load(); err != nil {
+//		log.Printf("configuration, %v", err)
+//	}
+//
+//	hostname := cfg.String("hostname", "tamago")
+//	port, err := cfg.Int("port", 8080)
+//
+// Sources are listed in decreasing precedence order, a key found in more than
+// one source takes the value of the first one listing it.
+//
+// This package is only meant to be used with `GOOS=tamago` as supported by the
+// TamaGo framework for bare metal Go, see https://github.com/karlo195/tamago.
+package config
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Source represents a configuration channel.
+type Source interface {
+	// Name returns the source name.
+	Name() string
+	// Load returns the source key/value entries.
+	Load() (map[string]string, error)
+}
+
+// Entry represents a merged configuration entry.
+type Entry struct {
+	// Value is the entry value.
+	Value string
+	// Source is the name of the source providing the value.
+	Source string
+}
+
+// Config represents a merged runtime configuration.
+type Config struct {
+	sync.RWMutex
+
+	// Sources is the list of configuration sources, in decreasing
+	// precedence order.
+	Sources []Source
+
+	entries map[string]Entry
+}
+
+// Load (re)loads all configuration sources, an unavailable source does not
+// prevent merging of the remaining ones and its error is returned jointly
+// with any other.
+func (c *Config) Load() error {
+	var errs []error
+
+	entries := make(map[string]Entry)
+
+	// walk in increasing precedence order, overriding earlier entries
+	for _, src := range slices.Backward(c.Sources) {
+		kv, err := src.Load()
+
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s, %v", src.Name(), err))
+		}
+
+		for k, v := range kv {
+			entries[k] = Entry{Value: v, Source: src.Name()}
+		}
+	}
+
+	c.Lock()
+	c.entries = entries
+	c.Unlock()
+
+	return errors.Join(errs...)
+}
+
+// Lookup returns the configuration entry for the argument key.
+func (c *Config) Lookup(key string) (e Entry, ok bool) {
+	c.RLock()
+	defer c.RUnlock()
+
+	e, ok = c.entries[key]
+
+	return
+}
+
+// Keys returns all configuration keys, in sorted order.
+func (c *Config) Keys() (keys []string) {
+	c.RLock()
+	defer c.RUnlock()
+
+	for k := range c.entries {
+		keys = append(keys, k)
+	}
+
+	slices.Sort(keys)
+
+	return
+}
+
+// String returns the value for the argument key, or the default one when
+// not found.
+func (c *Config) String(key string, def string) string {
+	if e, ok := c.Lookup(key); ok {
+		return e.Value
+	}
+
+	return def
+}
+
+// Int returns the integer value, in Go syntax (e.g. 0x prefix for
+// hexadecimal values), for the argument key, or the default one when not
+// found or invalid.
+func (c *Config) Int(key string, def int) (int, error) {
+	e, ok := c.Lookup(key)
+
+	if !ok {
+		return def, nil
+	}
+
+	val, err := strconv.ParseInt(e.Value, 0, 0)
+
+	if err != nil {
+		return def, fmt.Errorf("invalid %s value (%s), %v", key, e.Source, err)
+	}
+
+	return int(val), nil
+}
+
+// Bool returns the boolean value for the argument key, or the default one
+// when not found or invalid. An empty value (e.g. a command line flag
+// without value) is true.
+func (c *Config) Bool(key string, def bool) (bool, error) {
+	e, ok := c.Lookup(key)
+
+	if !ok {
+		return def, nil
+	}
+
+	if e.Value == "" {
+		return true, nil
+	}
+
+	val, err := strconv.ParseBool(e.Value)
+
+	if err != nil {
+		return def, fmt.Errorf("invalid %s value (%s), %v", key, e.Source, err)
+	}
+
+	return val, nil
+}
+
+// Duration returns the duration value (e.g. "1m30s") for the argument key,
+// or the default one when not found or invalid.
+func (c *Config) Duration(key string, def time.Duration) (time.Duration, error) {
+	e, ok := c.Lookup(key)
+
+	if !ok {
+		return def, nil
+	}
+
+	val, err := time.ParseDuration(e.Value)
+
+	if err != nil {
+		return def, fmt.Errorf("invalid %s value (%s), %v", key, e.Source, err)
+	}
+
+	return val, nil
+}
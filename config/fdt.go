@@ -0,0 +1,135 @@
+// Runtime configuration support
+// https://github.com/karlo195/tamago
+//
+// Copyright (c) The TamaGo Authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+package config
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+)
+
+// Flattened device tree structure tokens
+// (Devicetree Specification Release v0.4 - 5.4 Structure Block).
+const (
+	FDT_MAGIC      = 0xd00dfeed
+	FDT_BEGIN_NODE = 0x1
+	FDT_END_NODE   = 0x2
+	FDT_PROP       = 0x3
+	FDT_NOP        = 0x4
+	FDT_END        = 0x9
+)
+
+const fdtHeaderLength = 40
+
+// DeviceTree represents a flattened device tree blob configuration source,
+// the string properties of its /chosen node (e.g. `bootargs`) are returned
+// as entries.
+type DeviceTree []byte
+
+// Name returns the configuration source name.
+func (s DeviceTree) Name() string {
+	return "devicetree"
+}
+
+// Load returns the device tree /chosen node string properties.
+func (s DeviceTree) Load() (entries map[string]string, err error) {
+	fdt := []byte(s)
+
+	if len(fdt) < fdtHeaderLength || binary.BigEndian.Uint32(fdt[0:]) != FDT_MAGIC {
+		return nil, errors.New("invalid device tree")
+	}
+
+	structOff := binary.BigEndian.Uint32(fdt[8:])
+	stringsOff := binary.BigEndian.Uint32(fdt[12:])
+	structSize := binary.BigEndian.Uint32(fdt[36:])
+
+	if uint64(structOff)+uint64(structSize) > uint64(len(fdt)) || uint64(stringsOff) > uint64(len(fdt)) {
+		return nil, errors.New("invalid device tree layout")
+	}
+
+	buf := fdt[structOff : structOff+structSize]
+	names := fdt[stringsOff:]
+
+	entries = make(map[string]string)
+
+	var depth int
+	var chosen bool
+
+	for off := 0; off+4 <= len(buf); {
+		token := binary.BigEndian.Uint32(buf[off:])
+		off += 4
+
+		switch token {
+		case FDT_BEGIN_NODE:
+			name, _, _ := bytes.Cut(buf[off:], []byte{0})
+			off += align4(len(name) + 1)
+
+			depth++
+			chosen = depth == 2 && string(name) == "chosen"
+		case FDT_END_NODE:
+			if depth--; depth < 2 {
+				chosen = false
+			}
+		case FDT_PROP:
+			if off+8 > len(buf) {
+				return nil, errors.New("invalid property")
+			}
+
+			size := binary.BigEndian.Uint32(buf[off:])
+			nameOff := binary.BigEndian.Uint32(buf[off+4:])
+			off += 8
+
+			if uint64(size) > uint64(len(buf)-off) || uint64(nameOff) >= uint64(len(names)) {
+				return nil, errors.New("invalid property")
+			}
+
+			val := buf[off : off+int(size)]
+			off += align4(int(size))
+
+			if !chosen || depth != 2 {
+				continue
+			}
+
+			name, _, _ := bytes.Cut(names[nameOff:], []byte{0})
+
+			if str, ok := propertyString(val); ok {
+				entries[string(name)] = str
+			}
+		case FDT_NOP:
+		case FDT_END:
+			return
+		default:
+			return nil, errors.New("invalid structure token")
+		}
+	}
+
+	return
+}
+
+func align4(n int) int {
+	return (n + 3) &^ 3
+}
+
+// propertyString returns the value of NUL terminated printable string
+// properties.
+func propertyString(val []byte) (string, bool) {
+	if len(val) == 0 || val[len(val)-1] != 0 {
+		return "", false
+	}
+
+	val = val[:len(val)-1]
+
+	for _, c := range val {
+		if c < 0x20 || c > 0x7e {
+			return "", false
+		}
+	}
+
+	return string(val), true
+}
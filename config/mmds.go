@@ -0,0 +1,88 @@
+// Runtime configuration support
+// https://github.com/karlo195/tamago
+//
+// Copyright (c) The TamaGo Authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strconv"
+)
+
+// MMDS represents a Firecracker MicroVM Metadata Service configuration
+// source, its JSON metadata objects are flattened into keys with `.`
+// separated components (e.g. `{"app":{"port":8080}}` into `app.port`).
+//
+// Metadata retrieval requires a network stack and is therefore delegated to
+// the Fetch function, which is expected to issue an HTTP GET request to the
+// MMDS address (e.g. http://169.254.169.254/) with `Accept:
+// application/json` and, for MMDS version 2, a session token.
+type MMDS struct {
+	// Fetch returns the MMDS JSON metadata.
+	Fetch func() ([]byte, error)
+}
+
+// Name returns the configuration source name.
+func (s *MMDS) Name() string {
+	return "mmds"
+}
+
+// Load returns the flattened MMDS metadata entries.
+func (s *MMDS) Load() (entries map[string]string, err error) {
+	var metadata any
+
+	if s.Fetch == nil {
+		return nil, errors.New("missing fetch function")
+	}
+
+	buf, err := s.Fetch()
+
+	if err != nil {
+		return
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(buf))
+	dec.UseNumber()
+
+	if err = dec.Decode(&metadata); err != nil {
+		return
+	}
+
+	entries = make(map[string]string)
+	flatten("", metadata, entries)
+
+	return
+}
+
+func flatten(prefix string, val any, entries map[string]string) {
+	key := func(k string) string {
+		if prefix == "" {
+			return k
+		}
+
+		return prefix + "." + k
+	}
+
+	switch v := val.(type) {
+	case map[string]any:
+		for k, e := range v {
+			flatten(key(k), e, entries)
+		}
+	case []any:
+		for i, e := range v {
+			flatten(key(strconv.Itoa(i)), e, entries)
+		}
+	case string:
+		entries[prefix] = v
+	case json.Number:
+		entries[prefix] = v.String()
+	case bool:
+		entries[prefix] = strconv.FormatBool(v)
+	}
+}
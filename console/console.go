@@ -0,0 +1,243 @@
+// Console output management
+// https://github.com/karlo195/tamago
+//
+// Copyright (c) The TamaGo Authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+// Package console provides a console output manager, fanning out characters
+// printed by the runtime (see runtime.printk) to multiple sinks (e.g. UART,
+// VirtIO console, ring buffer, framebuffer) which can be enabled or disabled
+// at runtime.
+//
+// Sinks are registered with a priority, characters are transmitted to higher
+// priority sinks first so that slower sinks (e.g. framebuffer) do not delay
+// the most relevant ones, which matters on fatal errors.
+//
+// This package is only meant to be used with `GOOS=tamago` as supported by the
+// TamaGo framework for bare metal Go, see https://github.com/karlo195/tamago.
+package console
+
+import (
+	"cmp"
+	"errors"
+	"slices"
+	"sync"
+	"sync/atomic"
+)
+
+// MaxSinks is the maximum number of sinks, in addition to the default one.
+const MaxSinks = 8
+
+// DefaultSink is the identifier of the default sink.
+const DefaultSink = 0
+
+// Sink represents a console output sink.
+type Sink interface {
+	// Tx transmits a single character, it must not allocate memory nor
+	// block as it can be invoked before World start or within exception
+	// handling.
+	Tx(c byte)
+}
+
+type sink struct {
+	out      Sink
+	enabled  atomic.Bool
+	priority int
+}
+
+// Console represents a console output manager.
+//
+// The Default sink is a function, rather than a [Sink], so that it can be
+// statically assigned to allow output since early boot (before World start).
+type Console struct {
+	// Default is the default sink (e.g. UART transmission), enabled unless
+	// disabled with Enable(DefaultSink, false).
+	Default func(c byte)
+	// Priority is the default sink priority (see [Console.AddPriority]),
+	// it must be set before any sink is added.
+	Priority int
+
+	sync.Mutex
+
+	disabled atomic.Bool
+	sinks    [MaxSinks]atomic.Pointer[sink]
+	// transmission order, nil until a sink is added
+	order atomic.Pointer[[]int]
+}
+
+// sort updates the transmission order, by descending priority and then by
+// sink identifier, it must be invoked with the lock held.
+func (c *Console) sort() {
+	var order []int
+
+	priority := func(id int) int {
+		if id == DefaultSink {
+			return c.Priority
+		}
+
+		return c.sinks[id-1].Load().priority
+	}
+
+	order = append(order, DefaultSink)
+
+	for i := range c.sinks {
+		if c.sinks[i].Load() != nil {
+			order = append(order, i+1)
+		}
+	}
+
+	slices.SortStableFunc(order, func(a, b int) int {
+		return cmp.Compare(priority(b), priority(a))
+	})
+
+	c.order.Store(&order)
+}
+
+// Add registers an enabled sink with the same priority as the default one,
+// returning its identifier.
+func (c *Console) Add(out Sink) (id int, err error) {
+	return c.AddPriority(out, c.Priority)
+}
+
+// AddPriority registers an enabled sink with the argument priority, returning
+// its identifier. Characters are transmitted to sinks in descending priority
+// order, sinks with equal priority are served in registration order.
+func (c *Console) AddPriority(out Sink, priority int) (id int, err error) {
+	c.Lock()
+	defer c.Unlock()
+
+	if out == nil {
+		return -1, errors.New("invalid sink")
+	}
+
+	for i := range c.sinks {
+		if c.sinks[i].Load() != nil {
+			continue
+		}
+
+		s := &sink{
+			out:      out,
+			priority: priority,
+		}
+		s.enabled.Store(true)
+
+		c.sinks[i].Store(s)
+		c.sort()
+
+		return i + 1, nil
+	}
+
+	return -1, errors.New("too many sinks")
+}
+
+// SetPriority changes the priority of a registered sink, the default sink
+// priority cannot be changed once sinks are added.
+func (c *Console) SetPriority(id int, priority int) (err error) {
+	c.Lock()
+	defer c.Unlock()
+
+	if id <= DefaultSink || id > MaxSinks {
+		return errors.New("invalid sink")
+	}
+
+	s := c.sinks[id-1].Load()
+
+	if s == nil {
+		return errors.New("invalid sink")
+	}
+
+	// replace rather than update, as the sink might be in use
+	r := &sink{
+		out:      s.out,
+		priority: priority,
+	}
+	r.enabled.Store(s.enabled.Load())
+
+	c.sinks[id-1].Store(r)
+	c.sort()
+
+	return
+}
+
+// Remove unregisters a sink, the default one cannot be removed but only
+// disabled.
+func (c *Console) Remove(id int) (err error) {
+	c.Lock()
+	defer c.Unlock()
+
+	if id <= DefaultSink || id > MaxSinks || c.sinks[id-1].Load() == nil {
+		return errors.New("invalid sink")
+	}
+
+	c.sinks[id-1].Store(nil)
+	c.sort()
+
+	return
+}
+
+// Enable enables or disables a sink.
+func (c *Console) Enable(id int, on bool) (err error) {
+	if id == DefaultSink {
+		c.disabled.Store(!on)
+		return
+	}
+
+	if id < DefaultSink || id > MaxSinks {
+		return errors.New("invalid sink")
+	}
+
+	s := c.sinks[id-1].Load()
+
+	if s == nil {
+		return errors.New("invalid sink")
+	}
+
+	s.enabled.Store(on)
+
+	return
+}
+
+// Enabled returns whether a sink is registered and enabled.
+func (c *Console) Enabled(id int) bool {
+	if id == DefaultSink {
+		return c.Default != nil && !c.disabled.Load()
+	}
+
+	if id < DefaultSink || id > MaxSinks {
+		return false
+	}
+
+	s := c.sinks[id-1].Load()
+
+	return s != nil && s.enabled.Load()
+}
+
+// Tx transmits a single character to all enabled sinks, in priority order, it
+// is meant to be invoked by runtime.printk.
+func (c *Console) Tx(ch byte) {
+	order := c.order.Load()
+
+	if order == nil {
+		if c.Default != nil && !c.disabled.Load() {
+			c.Default(ch)
+		}
+
+		return
+	}
+
+	for _, id := range *order {
+		if id == DefaultSink {
+			if c.Default != nil && !c.disabled.Load() {
+				c.Default(ch)
+			}
+
+			continue
+		}
+
+		if s := c.sinks[id-1].Load(); s != nil && s.enabled.Load() {
+			s.out.Tx(ch)
+		}
+	}
+}
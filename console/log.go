@@ -0,0 +1,221 @@
+// Console output management
+// https://github.com/karlo195/tamago
+//
+// Copyright (c) The TamaGo Authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+package console
+
+import (
+	"fmt"
+	"io"
+	"sync/atomic"
+	"time"
+	_ "unsafe"
+)
+
+// record timestamp format
+const timeFormat = "2006-01-02T15:04:05.000000Z07:00"
+
+// DefaultLineLength is the default maximum length of a log record, longer
+// lines are split across multiple records.
+const DefaultLineLength = 128
+
+//go:linkname nanotime runtime.nanotime
+func nanotime() int64
+
+// Record represents a log record, holding a single console output line.
+type Record struct {
+	// Seq is the record sequence number.
+	Seq uint64
+	// Time is the record timestamp, taken from the runtime system time,
+	// which counts from boot until the wall clock is set (e.g.
+	// rtc.RTC.Sync) and from the Unix epoch afterwards.
+	Time time.Time
+	// Text is the line contents, without its terminating newline.
+	Text string
+}
+
+// String returns the record in dmesg format, with ISO 8601 timestamps (as
+// `dmesg --time-format iso`) as the system time is not necessarily relative to
+// boot.
+func (r Record) String() string {
+	return fmt.Sprintf("[%s] %s", r.Time.UTC().Format(timeFormat), r.Text)
+}
+
+type record struct {
+	seq  uint64
+	time int64
+	n    int
+}
+
+// Log represents a kernel log sink, recording console output lines with
+// timestamps and sequence numbers in a ring buffer (e.g. for dmesg style
+// retrieval over vsock or network).
+type Log struct {
+	// spinlock, as the sink cannot block on runtime.printk
+	lock atomic.Bool
+
+	records []record
+	buf     []byte
+	length  int
+
+	// sequence number of the next record
+	next uint64
+	// sequence number of the first non-cleared record
+	first uint64
+	// the last record is incomplete
+	open bool
+}
+
+// NewLog returns a kernel log sink retaining the argument number of records,
+// each up to the argument line length (or DefaultLineLength when zero).
+func NewLog(records int, lineLength int) *Log {
+	if lineLength <= 0 {
+		lineLength = DefaultLineLength
+	}
+
+	return &Log{
+		records: make([]record, records),
+		buf:     make([]byte, records*lineLength),
+		length:  lineLength,
+	}
+}
+
+func (l *Log) acquire() {
+	for !l.lock.CompareAndSwap(false, true) {
+	}
+}
+
+func (l *Log) release() {
+	l.lock.Store(false)
+}
+
+// add opens a new record, it must be invoked with the lock held.
+func (l *Log) add() {
+	l.records[l.next%uint64(len(l.records))] = record{
+		seq:  l.next,
+		time: nanotime(),
+	}
+
+	l.next++
+	l.open = true
+}
+
+// Tx records a single character, opening a new timestamped record at the
+// beginning of each line.
+func (l *Log) Tx(c byte) {
+	if len(l.records) == 0 {
+		return
+	}
+
+	l.acquire()
+	defer l.release()
+
+	switch {
+	case c == '\n':
+		if !l.open {
+			l.add()
+		}
+
+		l.open = false
+		return
+	case c == '\r':
+		return
+	case !l.open:
+		l.add()
+	}
+
+	i := (l.next - 1) % uint64(len(l.records))
+	r := &l.records[i]
+
+	if r.n == l.length {
+		l.add()
+		i = (l.next - 1) % uint64(len(l.records))
+		r = &l.records[i]
+	}
+
+	l.buf[int(i)*l.length+r.n] = c
+	r.n++
+}
+
+// Records returns a copy of the retained records with sequence number equal
+// or greater than the argument one, the last record might be incomplete.
+func (l *Log) Records(seq uint64) (records []Record) {
+	// Allocation must not happen with the lock held, as a goroutine parked
+	// while allocating would leave runtime.printk spinning.
+	snap := make([]record, len(l.records))
+	text := make([]byte, len(l.buf))
+
+	l.acquire()
+
+	n := uint64(len(l.records))
+
+	if l.next > n {
+		seq = max(seq, l.next-n)
+	}
+
+	seq = max(seq, l.first)
+	count := 0
+
+	for ; seq < l.next; seq++ {
+		i := seq % n
+		r := l.records[i]
+		off := int(i) * l.length
+
+		snap[count] = r
+		copy(text[count*l.length:], l.buf[off:off+r.n])
+		count++
+	}
+
+	l.release()
+
+	records = make([]Record, count)
+
+	for i, r := range snap[:count] {
+		off := i * l.length
+
+		records[i] = Record{
+			Seq:  r.seq,
+			Time: time.Unix(0, r.time),
+			Text: string(text[off : off+r.n]),
+		}
+	}
+
+	return
+}
+
+// Next returns the sequence number of the next record, to be passed to
+// [Log.Records] for incremental retrieval.
+func (l *Log) Next() uint64 {
+	l.acquire()
+	defer l.release()
+
+	return l.next
+}
+
+// Clear discards the retained records, sequence numbers are preserved.
+func (l *Log) Clear() {
+	l.acquire()
+	defer l.release()
+
+	l.first = l.next
+	l.open = false
+}
+
+// WriteTo writes the retained records, in dmesg format, to the argument
+// writer.
+func (l *Log) WriteTo(w io.Writer) (n int64, err error) {
+	for _, r := range l.Records(0) {
+		c, err := fmt.Fprintln(w, r)
+		n += int64(c)
+
+		if err != nil {
+			return n, err
+		}
+	}
+
+	return
+}
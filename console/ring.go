@@ -0,0 +1,105 @@
+// Console output management
+// https://github.com/karlo195/tamago
+//
+// Copyright (c) The TamaGo Authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+package console
+
+import (
+	"sync/atomic"
+)
+
+// Ring represents a ring buffer sink, retaining the most recent console output
+// (e.g. for later retrieval over network or persistent storage).
+type Ring struct {
+	buf []byte
+	pos atomic.Uint32
+}
+
+// NewRing returns a ring buffer sink of the argument size.
+func NewRing(size int) *Ring {
+	return &Ring{
+		buf: make([]byte, size),
+	}
+}
+
+// Tx stores a single character in the ring buffer, overwriting the oldest one
+// when full.
+func (r *Ring) Tx(c byte) {
+	if len(r.buf) == 0 {
+		return
+	}
+
+	i := r.pos.Add(1) - 1
+	r.buf[int(i%uint32(len(r.buf)))] = c
+}
+
+// Bytes returns a copy of the ring buffer contents, from the oldest to the
+// most recent character.
+func (r *Ring) Bytes() (buf []byte) {
+	n := uint32(len(r.buf))
+
+	if n == 0 {
+		return
+	}
+
+	pos := r.pos.Load()
+
+	if pos < n {
+		return append(buf, r.buf[:pos]...)
+	}
+
+	i := pos % n
+
+	buf = append(buf, r.buf[i:]...)
+	buf = append(buf, r.buf[:i]...)
+
+	return
+}
+
+// Copy copies the ring buffer contents, from the oldest to the most recent
+// character, to the argument buffer without allocating memory and returns the
+// number of copied bytes. Only the most recent characters are copied when the
+// argument buffer is smaller than the ring buffer contents.
+//
+//go:nosplit
+func (r *Ring) Copy(buf []byte) int {
+	size := uint32(len(r.buf))
+
+	if size == 0 {
+		return 0
+	}
+
+	pos := r.pos.Load()
+	start := uint32(0)
+	n := pos
+
+	if pos >= size {
+		start = pos - size
+		n = size
+	}
+
+	if m := uint32(len(buf)); n > m {
+		start += n - m
+		n = m
+	}
+
+	for i := uint32(0); i < n; i++ {
+		buf[i] = r.buf[(start+i)%size]
+	}
+
+	return int(n)
+}
+
+// Size returns the ring buffer capacity.
+func (r *Ring) Size() int {
+	return len(r.buf)
+}
+
+// Reset discards the ring buffer contents.
+func (r *Ring) Reset() {
+	r.pos.Store(0)
+}
@@ -0,0 +1,210 @@
+// Remote log shipper
+// https://github.com/karlo195/tamago
+//
+// Copyright (c) The TamaGo Authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+package syslog
+
+import (
+	"encoding/json"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SDID is the structured data element identifier conveying record attributes
+// in syslog messages (RFC5424 - 6.3.2. SD-ID).
+const SDID = "attrs@32473"
+
+const (
+	nilValue = "-"
+	// RFC5424 - 6.2.3. TIMESTAMP
+	timestampFormat = "2006-01-02T15:04:05.000000Z07:00"
+)
+
+// severity returns the syslog severity matching a structured logging level
+// (RFC5424 - 6.2.1. PRI).
+func severity(level slog.Level) int {
+	switch {
+	case level >= slog.LevelError:
+		return 3
+	case level >= slog.LevelWarn:
+		return 4
+	case level >= slog.LevelInfo:
+		return 6
+	default:
+		return 7
+	}
+}
+
+// header returns a syslog header field value, replacing characters outside
+// the allowed printable US-ASCII set (RFC5424 - 6.2. HEADER).
+func header(s string, max int) string {
+	if s == "" {
+		return nilValue
+	}
+
+	if len(s) > max {
+		s = s[:max]
+	}
+
+	return strings.Map(func(r rune) rune {
+		if r < 33 || r > 126 {
+			return '_'
+		}
+		return r
+	}, s)
+}
+
+// flatten appends resolved attributes, with group members keys prefixed by
+// the group name.
+func flatten(attrs []slog.Attr, prefix string, a slog.Attr) []slog.Attr {
+	a.Value = a.Value.Resolve()
+
+	if a.Equal(slog.Attr{}) {
+		return attrs
+	}
+
+	if a.Value.Kind() != slog.KindGroup {
+		return append(attrs, slog.Attr{Key: prefix + a.Key, Value: a.Value})
+	}
+
+	if a.Key != "" {
+		prefix += a.Key + "."
+	}
+
+	for _, m := range a.Value.Group() {
+		attrs = flatten(attrs, prefix, m)
+	}
+
+	return attrs
+}
+
+func (s *Shipper) encode(r *slog.Record, with []slog.Attr, prefix string) []byte {
+	attrs := make([]slog.Attr, 0, len(with)+r.NumAttrs())
+
+	for _, a := range with {
+		attrs = flatten(attrs, "", a)
+	}
+
+	r.Attrs(func(a slog.Attr) bool {
+		attrs = flatten(attrs, prefix, a)
+		return true
+	})
+
+	if s.Format == JSON {
+		return s.encodeJSON(r, attrs)
+	}
+
+	return s.encodeSyslog(r, attrs)
+}
+
+// encodeSyslog encodes a record as RFC5424 message, attributes are conveyed
+// as structured data parameters.
+func (s *Shipper) encodeSyslog(r *slog.Record, attrs []slog.Attr) []byte {
+	var b strings.Builder
+
+	b.WriteByte('<')
+	b.WriteString(strconv.Itoa(s.Facility*8 + severity(r.Level)))
+	b.WriteString(">1 ")
+
+	if r.Time.IsZero() {
+		b.WriteString(nilValue)
+	} else {
+		b.WriteString(r.Time.UTC().Format(timestampFormat))
+	}
+
+	b.WriteByte(' ')
+	b.WriteString(header(s.Hostname, 255))
+	b.WriteByte(' ')
+	b.WriteString(header(s.AppName, 48))
+	// PROCID and MSGID
+	b.WriteString(" - - ")
+
+	if len(attrs) == 0 {
+		b.WriteString(nilValue)
+	} else {
+		b.WriteString("[" + SDID)
+
+		for _, a := range attrs {
+			b.WriteByte(' ')
+			// RFC5424 - 6.3.3. SD-NAME
+			b.WriteString(header(strings.NewReplacer("=", "_", "]", "_", `"`, "_").Replace(a.Key), 32))
+			b.WriteString(`="`)
+			// RFC5424 - 6.3.3. PARAM-VALUE
+			b.WriteString(strings.NewReplacer(`\`, `\\`, `"`, `\"`, "]", `\]`).Replace(a.Value.String()))
+			b.WriteByte('"')
+		}
+
+		b.WriteByte(']')
+	}
+
+	if r.Message != "" {
+		b.WriteByte(' ')
+		b.WriteString(r.Message)
+	}
+
+	return []byte(b.String())
+}
+
+func appendJSON(b []byte, v any) []byte {
+	buf, err := json.Marshal(v)
+
+	if err != nil {
+		buf, _ = json.Marshal(err.Error())
+	}
+
+	return append(b, buf...)
+}
+
+// encodeJSON encodes a record as JSON object, attributes are conveyed as
+// top-level members.
+func (s *Shipper) encodeJSON(r *slog.Record, attrs []slog.Attr) (b []byte) {
+	b = append(b, `{"time":`...)
+	b = appendJSON(b, r.Time.UTC().Format(time.RFC3339Nano))
+	b = append(b, `,"level":`...)
+	b = appendJSON(b, r.Level.String())
+
+	if s.Hostname != "" {
+		b = append(b, `,"host":`...)
+		b = appendJSON(b, s.Hostname)
+	}
+
+	if s.AppName != "" {
+		b = append(b, `,"app":`...)
+		b = appendJSON(b, s.AppName)
+	}
+
+	b = append(b, `,"msg":`...)
+	b = appendJSON(b, r.Message)
+
+	for _, a := range attrs {
+		b = append(b, ',')
+		b = appendJSON(b, a.Key)
+		b = append(b, ':')
+
+		switch v := a.Value; v.Kind() {
+		case slog.KindString:
+			b = appendJSON(b, v.String())
+		case slog.KindTime:
+			b = appendJSON(b, v.Time().UTC().Format(time.RFC3339Nano))
+		case slog.KindDuration:
+			b = appendJSON(b, v.Duration().String())
+		case slog.KindAny:
+			if err, ok := v.Any().(error); ok {
+				b = appendJSON(b, err.Error())
+			} else {
+				b = appendJSON(b, v.Any())
+			}
+		default:
+			// bool, numeric types
+			b = appendJSON(b, v.Any())
+		}
+	}
+
+	return append(b, '}')
+}
@@ -0,0 +1,324 @@
+// Remote log shipper
+// https://github.com/karlo195/tamago
+//
+// Copyright (c) The TamaGo Authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+// Package syslog implements a remote log shipper, for structured (see
+// log/slog) and unstructured (see log) logging, which transmits log records in
+// batches over UDP or stream transports (e.g. vsock connections) adopting the
+// following reference specifications:
+//   - RFC5424 - The Syslog Protocol
+//   - RFC5426 - Transmission of Syslog Messages over UDP
+//   - RFC6587 - Transmission of Syslog Messages over TCP
+//
+// Records are encoded either as syslog messages, with attributes conveyed as
+// structured data, or as JSON objects (one per line on stream transports).
+//
+// This package is only meant to be used with `GOOS=tamago` as supported by the
+// TamaGo framework for bare metal Go, see https://github.com/karlo195/tamago.
+package syslog
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Record encoding formats
+const (
+	// Syslog encodes records as RFC5424 messages.
+	Syslog = iota
+	// JSON encodes records as JSON objects.
+	JSON
+)
+
+// Facility codes (RFC5424 - 6.2.1. PRI).
+const (
+	FacilityUser   = 1
+	FacilityDaemon = 3
+	FacilityLocal0 = 16
+)
+
+// Defaults
+const (
+	DefaultBatchSize  = 32
+	DefaultQueueSize  = 1024
+	DefaultInterval   = 1 * time.Second
+	DefaultMaxBackoff = 30 * time.Second
+)
+
+// Transport represents a log record transport.
+type Transport interface {
+	// Send transmits a batch of encoded records.
+	Send(records [][]byte) error
+}
+
+// Shipper represents a remote log shipper instance.
+type Shipper struct {
+	// Transport is the log record transport.
+	Transport Transport
+	// Format is the record encoding format (Syslog or JSON).
+	Format int
+
+	// Hostname is the originator host name.
+	Hostname string
+	// AppName is the originator application name.
+	AppName string
+	// Facility is the syslog facility code (default FacilityUser).
+	Facility int
+
+	// BatchSize is the maximum number of records per batch.
+	BatchSize int
+	// QueueSize is the maximum number of pending records, the oldest ones
+	// are dropped when exceeded.
+	QueueSize int
+	// Interval is the batch transmission interval.
+	Interval time.Duration
+	// MaxBackoff is the maximum retransmission delay on transport errors,
+	// which doubles on each failure starting from Interval.
+	MaxBackoff time.Duration
+
+	mu    sync.Mutex
+	queue [][]byte
+	// number of records removed from the queue
+	head uint64
+
+	flush   chan struct{}
+	done    chan struct{}
+	dropped atomic.Uint64
+	running bool
+}
+
+// Start initializes the shipper and starts asynchronous batch transmission.
+func (s *Shipper) Start() (err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.Transport == nil || s.Format != Syslog && s.Format != JSON {
+		return errors.New("invalid shipper instance")
+	}
+
+	if s.running {
+		return errors.New("shipper already started")
+	}
+
+	if s.Facility == 0 {
+		s.Facility = FacilityUser
+	}
+
+	if s.BatchSize <= 0 {
+		s.BatchSize = DefaultBatchSize
+	}
+
+	if s.QueueSize <= 0 {
+		s.QueueSize = DefaultQueueSize
+	}
+
+	if s.Interval <= 0 {
+		s.Interval = DefaultInterval
+	}
+
+	if s.MaxBackoff < s.Interval {
+		s.MaxBackoff = max(DefaultMaxBackoff, s.Interval)
+	}
+
+	s.flush = make(chan struct{}, 1)
+	s.done = make(chan struct{})
+	s.running = true
+
+	go s.run(s.flush, s.done)
+
+	return
+}
+
+// Stop stops asynchronous batch transmission, pending records are
+// transmitted once more before returning.
+func (s *Shipper) Stop() {
+	s.mu.Lock()
+
+	if !s.running {
+		s.mu.Unlock()
+		return
+	}
+
+	s.running = false
+	close(s.flush)
+	done := s.done
+
+	s.mu.Unlock()
+
+	<-done
+}
+
+// Flush requests immediate transmission of pending records.
+func (s *Shipper) Flush() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.running {
+		return
+	}
+
+	select {
+	case s.flush <- struct{}{}:
+	default:
+	}
+}
+
+// Dropped returns the number of records discarded on queue overflow.
+func (s *Shipper) Dropped() uint64 {
+	return s.dropped.Load()
+}
+
+func (s *Shipper) enqueue(rec []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if n := s.QueueSize; n > 0 && len(s.queue) >= n {
+		s.queue = s.queue[1:]
+		s.head++
+		s.dropped.Add(1)
+	}
+
+	s.queue = append(s.queue, rec)
+
+	if s.running && len(s.queue) >= s.BatchSize {
+		select {
+		case s.flush <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// send transmits all pending records in batches, records are dequeued only
+// on successful transmission.
+func (s *Shipper) send() (err error) {
+	for {
+		s.mu.Lock()
+		n := min(len(s.queue), s.BatchSize)
+		batch := s.queue[:n:n]
+		head := s.head
+		s.mu.Unlock()
+
+		if n == 0 {
+			return
+		}
+
+		if err = s.Transport.Send(batch); err != nil {
+			return
+		}
+
+		s.mu.Lock()
+		// records might have been dropped during transmission
+		if sent := n - int(s.head-head); sent > 0 {
+			s.queue = s.queue[sent:]
+			s.head += uint64(sent)
+		}
+		s.mu.Unlock()
+	}
+}
+
+func (s *Shipper) run(flush chan struct{}, done chan struct{}) {
+	defer close(done)
+
+	backoff := s.Interval
+	t := time.NewTimer(s.Interval)
+	defer t.Stop()
+
+	for {
+		var stop bool
+
+		select {
+		case _, ok := <-flush:
+			stop = !ok
+		case <-t.C:
+		}
+
+		if err := s.send(); err != nil {
+			backoff = min(backoff*2, s.MaxBackoff)
+		} else {
+			backoff = s.Interval
+		}
+
+		if stop {
+			return
+		}
+
+		t.Reset(backoff)
+	}
+}
+
+// Write implements io.Writer, for use as unstructured logger output (see
+// log.SetOutput), each write is shipped as a single informational record.
+func (s *Shipper) Write(p []byte) (n int, err error) {
+	r := slog.NewRecord(time.Now(), slog.LevelInfo, trimNewline(string(p)), 0)
+	s.enqueue(s.encode(&r, nil, ""))
+
+	return len(p), nil
+}
+
+// Handler returns a structured logging handler, shipping records at or above
+// the argument minimum level (slog.LevelInfo when nil).
+func (s *Shipper) Handler(level slog.Leveler) slog.Handler {
+	if level == nil {
+		level = slog.LevelInfo
+	}
+
+	return &handler{
+		shipper: s,
+		level:   level,
+	}
+}
+
+type handler struct {
+	shipper *Shipper
+	level   slog.Leveler
+	attrs   []slog.Attr
+	prefix  string
+}
+
+func (h *handler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.level.Level()
+}
+
+func (h *handler) Handle(_ context.Context, r slog.Record) error {
+	h.shipper.enqueue(h.shipper.encode(&r, h.attrs, h.prefix))
+	return nil
+}
+
+func (h *handler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	c := *h
+	c.attrs = make([]slog.Attr, 0, len(h.attrs)+len(attrs))
+	c.attrs = append(c.attrs, h.attrs...)
+
+	for _, a := range attrs {
+		c.attrs = append(c.attrs, slog.Attr{Key: h.prefix + a.Key, Value: a.Value})
+	}
+
+	return &c
+}
+
+func (h *handler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+
+	c := *h
+	c.prefix = h.prefix + name + "."
+
+	return &c
+}
+
+func trimNewline(s string) string {
+	for len(s) > 0 && (s[len(s)-1] == '\n' || s[len(s)-1] == '\r') {
+		s = s[:len(s)-1]
+	}
+
+	return s
+}
@@ -0,0 +1,121 @@
+// Remote log shipper
+// https://github.com/karlo195/tamago
+//
+// Copyright (c) The TamaGo Authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+package syslog
+
+import (
+	"errors"
+	"io"
+	"strconv"
+
+	"github.com/karlo195/tamago/nic"
+)
+
+// DefaultPort is the syslog UDP port (RFC5426 - 3.3.).
+const DefaultPort = 514
+
+// maximum UDP payload within a standard Ethernet MTU
+const maxDatagram = 1500 - nic.IPv4HeaderLength - nic.UDPHeaderLength
+
+// UDP represents a transport sending each record as a single UDP datagram
+// (RFC5426 - 3.1.) over a network interface controller, records exceeding the
+// Ethernet MTU are truncated (RFC5426 - 3.2.).
+type UDP struct {
+	// NIC is the network interface controller.
+	NIC nic.NIC
+
+	// Ethernet addresses (i.e. the collector or gateway hardware address)
+	SrcMAC [6]byte
+	DstMAC [6]byte
+
+	// IPv4 addresses
+	SrcIP [4]byte
+	DstIP [4]byte
+
+	// UDP ports, DstPort defaults to DefaultPort
+	SrcPort uint16
+	DstPort uint16
+
+	// frame buffer, reused across datagrams
+	buf [nic.UDPOverhead + maxDatagram]byte
+}
+
+// Send transmits each record of a batch as a UDP datagram.
+func (t *UDP) Send(records [][]byte) (err error) {
+	if t.NIC == nil {
+		return errors.New("invalid transport")
+	}
+
+	udp := &nic.UDP{
+		SrcMAC:  t.SrcMAC,
+		DstMAC:  t.DstMAC,
+		SrcIP:   t.SrcIP,
+		DstIP:   t.DstIP,
+		SrcPort: t.SrcPort,
+		DstPort: t.DstPort,
+	}
+
+	if udp.SrcPort == 0 {
+		udp.SrcPort = DefaultPort
+	}
+
+	if udp.DstPort == 0 {
+		udp.DstPort = DefaultPort
+	}
+
+	for _, rec := range records {
+		udp.Payload = rec[:min(len(rec), maxDatagram)]
+
+		frame, err := udp.Marshal(t.buf[:])
+
+		if err != nil {
+			return err
+		}
+
+		t.NIC.Tx(frame)
+	}
+
+	return
+}
+
+// Stream represents a transport over a reliable byte stream (e.g. a vsock
+// connection), records are framed with octet counting (RFC6587 - 3.4.1.) when
+// Syslog encoded or newline delimited when JSON encoded.
+type Stream struct {
+	// Conn is the underlying stream.
+	Conn io.Writer
+	// Format is the record encoding format, which must match the shipper
+	// one.
+	Format int
+
+	buf []byte
+}
+
+// Send transmits a batch of records with a single stream write.
+func (t *Stream) Send(records [][]byte) (err error) {
+	if t.Conn == nil {
+		return errors.New("invalid transport")
+	}
+
+	t.buf = t.buf[:0]
+
+	for _, rec := range records {
+		if t.Format == JSON {
+			t.buf = append(t.buf, rec...)
+			t.buf = append(t.buf, '\n')
+		} else {
+			t.buf = strconv.AppendInt(t.buf, int64(len(rec)), 10)
+			t.buf = append(t.buf, ' ')
+			t.buf = append(t.buf, rec...)
+		}
+	}
+
+	_, err = t.Conn.Write(t.buf)
+
+	return
+}
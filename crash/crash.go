@@ -0,0 +1,163 @@
+// Runtime panic policy
+// https://github.com/karlo195/tamago
+//
+// Copyright (c) The TamaGo Authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+// Package crash implements a configurable policy for abnormal runtime
+// termination (e.g. unrecovered panics), allowing deployments to choose among
+// immediate reset, halt for debugging, crash log persistence and host
+// notification rather than the board default termination.
+//
+// The policy is enforced by overriding runtime.Exit, after board
+// initialization, for all non-zero exit codes (a panic terminates the runtime
+// with exit code 2). As the policy actions execute within runtime.Exit, with
+// the runtime in a possibly inconsistent state, they must not allocate memory,
+// block or grow the stack (i.e. //go:nosplit functions performing register or
+// memory writes):
+//
+//	// memory region preserved across resets
+//	var retained []byte
+//
+//	ring := console.NewRing(16384)
+//	microvm.Console.Add(ring)
+//
+//	host := &pvpanic.PVPanic{}
+//	host.Init()
+//
+//	p := &crash.Policy{
+//		Action: crash.Reset,
+//		Log:    ring,
+//		Dump:   func(log []byte) { copy(retained, log) },
+//		Notify: host.Panicked,
+//		Reset:  power.Registered().Reset,
+//	}
+//
+//	p.Install()
+//
+// This package is only meant to be used with `GOOS=tamago` as supported by the
+// TamaGo framework for bare metal Go, see https://github.com/karlo195/tamago.
+package crash
+
+import (
+	"errors"
+	"runtime"
+	"sync/atomic"
+
+	"github.com/karlo195/tamago/console"
+)
+
+// Termination actions
+const (
+	// Exit invokes the runtime.Exit function set before installation
+	// (i.e. the board default termination).
+	Exit = iota
+	// Halt suspends execution indefinitely, to allow debugger attachment
+	// or inspection of console output.
+	Halt
+	// Reset invokes the Reset function.
+	Reset
+)
+
+// Policy represents a runtime termination policy.
+type Policy struct {
+	// Action is the termination action (Exit, Halt or Reset).
+	Action int
+
+	// Log is the console output captured for crash dumps, including the
+	// panic message and goroutine traces.
+	Log *console.Ring
+	// Dump is an optional function invoked with the captured console
+	// output for persistence (e.g. copy to retained memory), it must not
+	// allocate memory nor block.
+	Dump func(log []byte)
+
+	// Notify is an optional function invoked to notify the termination to
+	// the host (e.g. pvpanic.PVPanic.Panicked), it must not allocate memory
+	// nor block.
+	Notify func()
+
+	// Reset is the system reset function, required by the Reset action
+	// (e.g. power.Platform.Reset), it must not allocate memory.
+	Reset func()
+	// Wait is an optional function invoked repeatedly on Halt (e.g.
+	// amd64.CPU.WaitInterrupt), the processor spins when not set. It must
+	// not allocate memory.
+	Wait func()
+
+	exit  func(int32)
+	fired atomic.Bool
+
+	// preallocated crash dump buffer
+	log []byte
+}
+
+// Install sets the policy as runtime termination handler, non-zero exit codes
+// trigger the policy while zero ones are handled by the previous runtime.Exit
+// function.
+func (p *Policy) Install() (err error) {
+	switch p.Action {
+	case Exit, Halt:
+	case Reset:
+		if p.Reset == nil {
+			return errors.New("missing reset function")
+		}
+	default:
+		return errors.New("invalid action")
+	}
+
+	if p.Dump != nil && p.Log != nil {
+		p.log = make([]byte, p.Log.Size())
+	}
+
+	p.exit = runtime.Exit
+	runtime.Exit = p.handle
+
+	return
+}
+
+//go:nosplit
+func (p *Policy) handle(code int32) {
+	// failures within policy execution fall back to the previous exit
+	if code == 0 || !p.fired.CompareAndSwap(false, true) {
+		p.terminate(code)
+		return
+	}
+
+	if p.Dump != nil && p.log != nil {
+		n := p.Log.Copy(p.log)
+		p.Dump(p.log[:n])
+	}
+
+	if p.Notify != nil {
+		p.Notify()
+	}
+
+	switch p.Action {
+	case Halt:
+		print("halted\n")
+
+		for {
+			if p.Wait != nil {
+				p.Wait()
+			}
+		}
+	case Reset:
+		p.Reset()
+	}
+
+	p.terminate(code)
+}
+
+//go:nosplit
+func (p *Policy) terminate(code int32) {
+	if p.exit != nil {
+		p.exit(code)
+	}
+
+	// runtime.Exit must not return
+	for {
+	}
+}
@@ -0,0 +1,283 @@
+// Device initialization management
+// https://github.com/karlo195/tamago
+//
+// Copyright (c) The TamaGo Authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+// Package devinit provides deferred device initialization, allowing board
+// packages to declare device initialization functions, with their
+// dependencies, which run in parallel goroutines after scheduler start or
+// lazily on first use, rather than sequentially before main().
+//
+// This reduces time-to-main for applications (e.g. microVMs) which do not use,
+// or do not immediately need, every available device:
+//
+//	var Devices = &devinit.Graph{}
+//
+//	func init() {
+//		Devices.Add(&devinit.Device{
+//			Name: "net",
+//			Init: initNetwork,
+//		})
+//
+//		Devices.Add(&devinit.Device{
+//			Name: "storage",
+//			Deps: []string{"net"},
+//			Init: initStorage,
+//			Lazy: true,
+//		})
+//
+//		Devices.Start()
+//	}
+//
+// Applications must then wait for any device before its use:
+//
+//	if err := board.Devices.Require("storage"); err != nil {
+//		log.Fatal(err)
+//	}
+//
+// This package is only meant to be used with `GOOS=tamago` as supported by the
+// TamaGo framework for bare metal Go, see https://github.com/karlo195/tamago.
+package devinit
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Device represents a device initialization function and its dependencies.
+type Device struct {
+	// Name is the device unique name.
+	Name string
+	// Deps is the list of device names which must be initialized before
+	// this one.
+	Deps []string
+	// Init is the device initialization function.
+	Init func() error
+	// Lazy defers initialization to the first [Graph.Require] invocation
+	// for this device, or one which depends on it, rather than on
+	// [Graph.Start].
+	Lazy bool
+
+	once    sync.Once
+	done    chan struct{}
+	err     error
+	elapsed time.Duration
+}
+
+// Status represents a device initialization status.
+type Status struct {
+	// Name is the device name.
+	Name string
+	// Done indicates whether initialization has completed.
+	Done bool
+	// Err is the initialization error, if any.
+	Err error
+	// Elapsed is the initialization duration, excluding dependencies.
+	Elapsed time.Duration
+}
+
+// Graph represents a set of devices with initialization dependencies.
+type Graph struct {
+	sync.Mutex
+
+	devices map[string]*Device
+	order   []string
+	started bool
+}
+
+// Add registers a device, its dependencies can be registered afterwards but
+// must be present by the time of its initialization.
+func (g *Graph) Add(d *Device) (err error) {
+	g.Lock()
+	defer g.Unlock()
+
+	if d == nil || d.Name == "" || d.Init == nil {
+		return errors.New("invalid device")
+	}
+
+	if g.devices == nil {
+		g.devices = make(map[string]*Device)
+	}
+
+	if _, ok := g.devices[d.Name]; ok {
+		return fmt.Errorf("duplicate device %s", d.Name)
+	}
+
+	d.done = make(chan struct{})
+
+	g.devices[d.Name] = d
+	g.order = append(g.order, d.Name)
+
+	if g.started && !d.Lazy {
+		go g.Require(d.Name)
+	}
+
+	return
+}
+
+// check verifies that all dependencies of the named device are registered and
+// free of cycles.
+func (g *Graph) check(name string, visiting map[string]bool, checked map[string]bool) error {
+	if checked[name] {
+		return nil
+	}
+
+	if visiting[name] {
+		return fmt.Errorf("dependency cycle on %s", name)
+	}
+
+	d, ok := g.devices[name]
+
+	if !ok {
+		return fmt.Errorf("missing device %s", name)
+	}
+
+	visiting[name] = true
+
+	for _, dep := range d.Deps {
+		if err := g.check(dep, visiting, checked); err != nil {
+			return err
+		}
+	}
+
+	visiting[name] = false
+	checked[name] = true
+
+	return nil
+}
+
+// Start verifies the dependency graph and launches, in parallel goroutines,
+// the initialization of all devices not marked as lazy. Devices registered
+// after Start() are initialized on registration, unless lazy.
+//
+// As initialization happens in goroutines, it progresses only after scheduler
+// start and concurrently with package initialization and main().
+func (g *Graph) Start() (err error) {
+	g.Lock()
+	defer g.Unlock()
+
+	if g.started {
+		return errors.New("already started")
+	}
+
+	visiting := make(map[string]bool)
+	checked := make(map[string]bool)
+
+	for _, name := range g.order {
+		if err = g.check(name, visiting, checked); err != nil {
+			return
+		}
+	}
+
+	g.started = true
+
+	for _, name := range g.order {
+		if !g.devices[name].Lazy {
+			go g.Require(name)
+		}
+	}
+
+	return
+}
+
+func (g *Graph) device(name string) (d *Device, err error) {
+	g.Lock()
+	defer g.Unlock()
+
+	if d = g.devices[name]; d == nil {
+		return nil, fmt.Errorf("missing device %s", name)
+	}
+
+	if err = g.check(name, make(map[string]bool), make(map[string]bool)); err != nil {
+		return nil, err
+	}
+
+	return
+}
+
+// Require initializes, if not already done, the named device and all its
+// dependencies, it blocks until initialization is complete and returns its
+// result.
+func (g *Graph) Require(name string) (err error) {
+	d, err := g.device(name)
+
+	if err != nil {
+		return
+	}
+
+	d.once.Do(func() {
+		defer close(d.done)
+
+		for _, dep := range d.Deps {
+			if err := g.Require(dep); err != nil {
+				d.err = fmt.Errorf("%s dependency %s failed, %v", d.Name, dep, err)
+				return
+			}
+		}
+
+		start := time.Now()
+		d.err = d.Init()
+		d.elapsed = time.Since(start)
+	})
+
+	<-d.done
+
+	return d.err
+}
+
+// Wait blocks until all devices not marked as lazy, or already required, are
+// initialized and returns the first initialization error, if any.
+func (g *Graph) Wait() (err error) {
+	g.Lock()
+	var devices []*Device
+
+	for _, name := range g.order {
+		devices = append(devices, g.devices[name])
+	}
+	g.Unlock()
+
+	for _, d := range devices {
+		if d.Lazy {
+			select {
+			case <-d.done:
+			default:
+				continue
+			}
+		}
+
+		if e := g.Require(d.Name); e != nil && err == nil {
+			err = e
+		}
+	}
+
+	return
+}
+
+// Status returns the initialization status of all registered devices, in
+// registration order.
+func (g *Graph) Status() (status []Status) {
+	g.Lock()
+	defer g.Unlock()
+
+	for _, name := range g.order {
+		d := g.devices[name]
+		s := Status{Name: name}
+
+		select {
+		case <-d.done:
+			s.Done = true
+			s.Err = d.err
+			s.Elapsed = d.elapsed
+		default:
+		}
+
+		status = append(status, s)
+	}
+
+	return
+}
@@ -0,0 +1,342 @@
+// Display framebuffer support
+// https://github.com/karlo195/tamago
+//
+// Copyright (c) The TamaGo Authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+// Package display implements a common framebuffer and drawing API for
+// displays, either memory mapped (e.g. BCM2835 VideoCore framebuffer) or
+// updated through a [Panel] driver (e.g. SPI TFT controllers, see
+// display/tft).
+//
+// The framebuffer implements the draw.Image interface, so that the standard
+// library image packages can be used for drawing, and tracks modified areas
+// so that only those are transferred to the panel on [Framebuffer.Flush]:
+//
+//	lcd := &tft.TFT{Bus: spi, DC: dc, Model: tft.ST7789, Width: 240, Height: 240}
+//
+//	if err := lcd.Init(); err != nil {
+//		return err
+//	}
+//
+//	fb := &display.Framebuffer{Width: 240, Height: 240, Format: display.RGB565, Panel: lcd}
+//	fb.Init()
+//
+//	fb.Fill(fb.Bounds(), color.Black)
+//	fb.Line(0, 0, 239, 239, color.White)
+//	fb.Flush()
+//
+// This package is only meant to be used with `GOOS=tamago` as supported by the
+// TamaGo framework for bare metal Go, see https://github.com/karlo195/tamago.
+package display
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"sync"
+)
+
+// Pixel formats
+const (
+	// RGB565 is the 16 bits per pixel format, stored big-endian as
+	// expected by MIPI DCS display controllers.
+	RGB565 Format = iota
+	// RGB565LE is the 16 bits per pixel format, stored little-endian.
+	RGB565LE
+	// XRGB8888 is the 32 bits per pixel format, stored little-endian
+	// (blue first).
+	XRGB8888
+)
+
+// Format represents a framebuffer pixel format.
+type Format int
+
+// Bpp returns the format number of bytes per pixel.
+func (f Format) Bpp() int {
+	switch f {
+	case RGB565, RGB565LE:
+		return 2
+	case XRGB8888:
+		return 4
+	default:
+		return 0
+	}
+}
+
+// Panel represents a display controller updated through explicit pixel
+// transfers.
+type Panel interface {
+	// Update transfers the argument area pixels, packed row by row in
+	// the framebuffer pixel format.
+	Update(r image.Rectangle, pix []byte) error
+}
+
+// Framebuffer represents a display framebuffer instance.
+type Framebuffer struct {
+	sync.Mutex
+
+	// Width is the framebuffer width in pixels.
+	Width int
+	// Height is the framebuffer height in pixels.
+	Height int
+	// Format is the pixel format.
+	Format Format
+	// Stride is the number of bytes per row (default Width*Format.Bpp()).
+	Stride int
+	// Pix is the framebuffer memory, allocated on Init when nil (e.g.
+	// unless memory mapped).
+	Pix []byte
+	// Panel is the optional display controller updated on Flush.
+	Panel Panel
+
+	// modified area since last flush
+	dirty image.Rectangle
+}
+
+// Init initializes the framebuffer.
+func (fb *Framebuffer) Init() (err error) {
+	fb.Lock()
+	defer fb.Unlock()
+
+	bpp := fb.Format.Bpp()
+
+	if fb.Width <= 0 || fb.Height <= 0 || bpp == 0 {
+		return errors.New("invalid framebuffer instance")
+	}
+
+	if fb.Stride == 0 {
+		fb.Stride = fb.Width * bpp
+	}
+
+	if fb.Stride < fb.Width*bpp {
+		return errors.New("invalid framebuffer stride")
+	}
+
+	size := fb.Stride * fb.Height
+
+	if fb.Pix == nil {
+		fb.Pix = make([]byte, size)
+	}
+
+	if len(fb.Pix) < size {
+		return errors.New("invalid framebuffer size")
+	}
+
+	fb.dirty = fb.Bounds()
+
+	return
+}
+
+// ColorModel returns the framebuffer color model, it implements the
+// image.Image interface.
+func (fb *Framebuffer) ColorModel() color.Model {
+	return color.RGBAModel
+}
+
+// Bounds returns the framebuffer area, it implements the image.Image
+// interface.
+func (fb *Framebuffer) Bounds() image.Rectangle {
+	return image.Rect(0, 0, fb.Width, fb.Height)
+}
+
+func (fb *Framebuffer) offset(x int, y int) int {
+	return y*fb.Stride + x*fb.Format.Bpp()
+}
+
+// pixel converts a color in the framebuffer pixel format.
+func (fb *Framebuffer) pixel(c color.Color) (pix []byte) {
+	r, g, b, _ := c.RGBA()
+
+	switch fb.Format {
+	case RGB565, RGB565LE:
+		v := uint16(r>>11)<<11 | uint16(g>>10)<<5 | uint16(b>>11)
+
+		if fb.Format == RGB565 {
+			return []byte{uint8(v >> 8), uint8(v)}
+		}
+
+		return []byte{uint8(v), uint8(v >> 8)}
+	case XRGB8888:
+		return []byte{uint8(b >> 8), uint8(g >> 8), uint8(r >> 8), 0xff}
+	}
+
+	return
+}
+
+// At returns the color of the pixel at the argument coordinates, it
+// implements the image.Image interface.
+func (fb *Framebuffer) At(x int, y int) color.Color {
+	if !(image.Point{x, y}.In(fb.Bounds())) || fb.Pix == nil {
+		return color.RGBA{}
+	}
+
+	off := fb.offset(x, y)
+
+	switch fb.Format {
+	case RGB565, RGB565LE:
+		var v uint16
+
+		if fb.Format == RGB565 {
+			v = uint16(fb.Pix[off])<<8 | uint16(fb.Pix[off+1])
+		} else {
+			v = uint16(fb.Pix[off+1])<<8 | uint16(fb.Pix[off])
+		}
+
+		r := uint8(v>>11) << 3
+		g := uint8(v>>5) << 2
+		b := uint8(v) << 3
+
+		return color.RGBA{r | r>>5, g | g>>6, b | b>>5, 0xff}
+	case XRGB8888:
+		return color.RGBA{fb.Pix[off+2], fb.Pix[off+1], fb.Pix[off], 0xff}
+	}
+
+	return color.RGBA{}
+}
+
+func (fb *Framebuffer) mark(r image.Rectangle) {
+	fb.dirty = fb.dirty.Union(r)
+}
+
+// Set sets the color of the pixel at the argument coordinates, it implements
+// the draw.Image interface.
+func (fb *Framebuffer) Set(x int, y int, c color.Color) {
+	if !(image.Point{x, y}.In(fb.Bounds())) || fb.Pix == nil {
+		return
+	}
+
+	fb.Lock()
+	defer fb.Unlock()
+
+	copy(fb.Pix[fb.offset(x, y):], fb.pixel(c))
+	fb.mark(image.Rect(x, y, x+1, y+1))
+}
+
+// Fill fills the argument area with a solid color.
+func (fb *Framebuffer) Fill(r image.Rectangle, c color.Color) {
+	r = r.Intersect(fb.Bounds())
+
+	if r.Empty() || fb.Pix == nil {
+		return
+	}
+
+	fb.Lock()
+	defer fb.Unlock()
+
+	pix := fb.pixel(c)
+	bpp := len(pix)
+
+	// fill the first row and replicate it on the following ones
+	start := fb.offset(r.Min.X, r.Min.Y)
+	row := fb.Pix[start : start+r.Dx()*bpp]
+
+	for i := 0; i < len(row); i += bpp {
+		copy(row[i:], pix)
+	}
+
+	for y := r.Min.Y + 1; y < r.Max.Y; y++ {
+		copy(fb.Pix[fb.offset(r.Min.X, y):], row)
+	}
+
+	fb.mark(r)
+}
+
+// Clear fills the entire framebuffer with a solid color.
+func (fb *Framebuffer) Clear(c color.Color) {
+	fb.Fill(fb.Bounds(), c)
+}
+
+// Rectangle draws the outline of the argument area.
+func (fb *Framebuffer) Rectangle(r image.Rectangle, c color.Color) {
+	r = r.Canon()
+
+	if r.Empty() {
+		return
+	}
+
+	fb.Fill(image.Rect(r.Min.X, r.Min.Y, r.Max.X, r.Min.Y+1), c)
+	fb.Fill(image.Rect(r.Min.X, r.Max.Y-1, r.Max.X, r.Max.Y), c)
+	fb.Fill(image.Rect(r.Min.X, r.Min.Y, r.Min.X+1, r.Max.Y), c)
+	fb.Fill(image.Rect(r.Max.X-1, r.Min.Y, r.Max.X, r.Max.Y), c)
+}
+
+// Line draws a line between the argument points (inclusive), using
+// Bresenham's algorithm.
+func (fb *Framebuffer) Line(x0 int, y0 int, x1 int, y1 int, c color.Color) {
+	dx := x1 - x0
+	dy := y1 - y0
+	sx := 1
+	sy := 1
+
+	if dx < 0 {
+		dx = -dx
+		sx = -1
+	}
+
+	if dy < 0 {
+		dy = -dy
+		sy = -1
+	}
+
+	e := dx - dy
+
+	for {
+		fb.Set(x0, y0, c)
+
+		if x0 == x1 && y0 == y1 {
+			return
+		}
+
+		e2 := 2 * e
+
+		if e2 >= -dy {
+			e -= dy
+			x0 += sx
+		}
+
+		if e2 <= dx {
+			e += dx
+			y0 += sy
+		}
+	}
+}
+
+// Flush transfers the area modified since the last flush to the panel, if
+// any.
+func (fb *Framebuffer) Flush() (err error) {
+	fb.Lock()
+	defer fb.Unlock()
+
+	r := fb.dirty.Intersect(fb.Bounds())
+	fb.dirty = image.Rectangle{}
+
+	if fb.Panel == nil || r.Empty() {
+		return
+	}
+
+	bpp := fb.Format.Bpp()
+	n := r.Dx() * bpp
+
+	var pix []byte
+
+	if r.Dx() == fb.Width && fb.Stride == n {
+		pix = fb.Pix[fb.offset(0, r.Min.Y):fb.offset(0, r.Max.Y)]
+	} else {
+		pix = make([]byte, 0, n*r.Dy())
+
+		for y := r.Min.Y; y < r.Max.Y; y++ {
+			off := fb.offset(r.Min.X, y)
+			pix = append(pix, fb.Pix[off:off+n]...)
+		}
+	}
+
+	if err = fb.Panel.Update(r, pix); err != nil {
+		// retry on next flush
+		fb.mark(r)
+	}
+
+	return
+}
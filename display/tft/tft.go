@@ -0,0 +1,278 @@
+// SPI TFT display controller driver
+// https://github.com/karlo195/tamago
+//
+// Copyright (c) The TamaGo Authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+// Package tft implements a driver for Sitronix ST7789 and Ilitek ILI9341 SPI
+// TFT display controllers, implementing the display.Panel interface for use
+// with a display.Framebuffer in RGB565 format, adopting the following
+// reference specifications:
+//   - MIPI Alliance Specification for Display Command Set (DCS) - v1.02.00
+//   - ST7789VW Datasheet - Version 1.0
+//   - ILI9341 Datasheet - V1.11
+//
+// Controllers are operated through the 4-line serial interface (SPI plus
+// data/command line), vendor specific tuning (e.g. gamma, power control) is
+// left at its reset defaults.
+//
+// This package is only meant to be used with `GOOS=tamago` as supported by the
+// TamaGo framework for bare metal Go, see https://github.com/karlo195/tamago.
+package tft
+
+import (
+	"errors"
+	"image"
+	"sync"
+	"time"
+
+	"github.com/karlo195/tamago/spi"
+)
+
+// Display Command Set (MIPI DCS)
+const (
+	SWRESET = 0x01
+	SLPOUT  = 0x11
+	NORON   = 0x13
+	INVOFF  = 0x20
+	INVON   = 0x21
+	DISPOFF = 0x28
+	DISPON  = 0x29
+	CASET   = 0x2a
+	RASET   = 0x2b
+	RAMWR   = 0x2c
+
+	MADCTL     = 0x36
+	MADCTL_MY  = 7
+	MADCTL_MX  = 6
+	MADCTL_MV  = 5
+	MADCTL_BGR = 3
+
+	COLMOD        = 0x3a
+	COLMOD_RGB565 = 0x55
+)
+
+// Supported controllers
+const (
+	ST7789 = iota
+	ILI9341
+)
+
+const (
+	// resetDelay is the time to wait after a hardware or software reset.
+	resetDelay = 150 * time.Millisecond
+	// sleepOutDelay is the time to wait after exiting sleep mode.
+	sleepOutDelay = 120 * time.Millisecond
+	// maxTransfer is the default maximum SPI transfer size.
+	maxTransfer = 4096
+)
+
+// Line represents a GPIO output line (e.g. powerseq.Line).
+type Line interface {
+	// Out configures the line as output.
+	Out()
+	// High drives the line high.
+	High()
+	// Low drives the line low.
+	Low()
+}
+
+// TFT represents an SPI TFT display controller instance.
+type TFT struct {
+	sync.Mutex
+
+	// Bus is the SPI controller to which the display is connected.
+	Bus spi.Bus
+	// DC is the data (high) or command (low) selection line.
+	DC Line
+	// Reset is the optional active low hardware reset line.
+	Reset Line
+
+	// Model is the display controller model (ST7789, ILI9341).
+	Model int
+	// Width is the display width in pixels, after rotation.
+	Width int
+	// Height is the display height in pixels, after rotation.
+	Height int
+	// Rotation is the display rotation in degrees (0, 90, 180, 270).
+	Rotation int
+	// XOffset is the column offset of the visible area within the
+	// controller memory (e.g. 240x240 panels on ST7789).
+	XOffset int
+	// YOffset is the row offset of the visible area within the controller
+	// memory.
+	YOffset int
+	// Invert enables display inversion, required by most IPS panels.
+	Invert bool
+	// BGR selects blue-green-red subpixel order, required by most ILI9341
+	// panels.
+	BGR bool
+	// MaxTransfer is the maximum SPI transfer size (default 4096).
+	MaxTransfer int
+}
+
+func (hw *TFT) command(cmd uint8, data ...uint8) (err error) {
+	hw.DC.Low()
+
+	if err = hw.Bus.Transfer([]byte{cmd}, nil); err != nil {
+		return
+	}
+
+	if len(data) == 0 {
+		return
+	}
+
+	return hw.data(data)
+}
+
+func (hw *TFT) data(buf []byte) (err error) {
+	hw.DC.High()
+
+	for len(buf) > 0 {
+		n := min(len(buf), hw.MaxTransfer)
+
+		if err = hw.Bus.Transfer(buf[:n], nil); err != nil {
+			return
+		}
+
+		buf = buf[n:]
+	}
+
+	return
+}
+
+// Init initializes the display controller.
+func (hw *TFT) Init() (err error) {
+	hw.Lock()
+	defer hw.Unlock()
+
+	if hw.Bus == nil || hw.DC == nil || hw.Width <= 0 || hw.Height <= 0 {
+		return errors.New("invalid TFT instance")
+	}
+
+	if hw.MaxTransfer <= 0 {
+		hw.MaxTransfer = maxTransfer
+	}
+
+	var madctl uint8
+
+	switch hw.Rotation {
+	case 0:
+	case 90:
+		madctl = 1<<MADCTL_MX | 1<<MADCTL_MV
+	case 180:
+		madctl = 1<<MADCTL_MX | 1<<MADCTL_MY
+	case 270:
+		madctl = 1<<MADCTL_MY | 1<<MADCTL_MV
+	default:
+		return errors.New("invalid rotation")
+	}
+
+	switch hw.Model {
+	case ST7789, ILI9341:
+	default:
+		return errors.New("unsupported controller model")
+	}
+
+	if hw.BGR {
+		madctl |= 1 << MADCTL_BGR
+	}
+
+	hw.DC.Out()
+
+	if hw.Reset != nil {
+		hw.Reset.Out()
+		hw.Reset.Low()
+		time.Sleep(10 * time.Millisecond)
+		hw.Reset.High()
+		time.Sleep(resetDelay)
+	}
+
+	if err = hw.command(SWRESET); err != nil {
+		return
+	}
+
+	time.Sleep(resetDelay)
+
+	if err = hw.command(SLPOUT); err != nil {
+		return
+	}
+
+	time.Sleep(sleepOutDelay)
+
+	if err = hw.command(COLMOD, COLMOD_RGB565); err != nil {
+		return
+	}
+
+	if err = hw.command(MADCTL, madctl); err != nil {
+		return
+	}
+
+	inv := uint8(INVOFF)
+
+	if hw.Invert {
+		inv = INVON
+	}
+
+	if err = hw.command(inv); err != nil {
+		return
+	}
+
+	if err = hw.command(NORON); err != nil {
+		return
+	}
+
+	return hw.command(DISPON)
+}
+
+// Enable turns the display on or off, the display memory is preserved.
+func (hw *TFT) Enable(on bool) error {
+	hw.Lock()
+	defer hw.Unlock()
+
+	if on {
+		return hw.command(DISPON)
+	}
+
+	return hw.command(DISPOFF)
+}
+
+func (hw *TFT) window(r image.Rectangle) (err error) {
+	x0 := uint16(r.Min.X + hw.XOffset)
+	x1 := uint16(r.Max.X - 1 + hw.XOffset)
+	y0 := uint16(r.Min.Y + hw.YOffset)
+	y1 := uint16(r.Max.Y - 1 + hw.YOffset)
+
+	if err = hw.command(CASET, uint8(x0>>8), uint8(x0), uint8(x1>>8), uint8(x1)); err != nil {
+		return
+	}
+
+	return hw.command(RASET, uint8(y0>>8), uint8(y0), uint8(y1>>8), uint8(y1))
+}
+
+// Update transfers the argument area pixels, packed row by row in RGB565
+// (big-endian) format, it implements the display.Panel interface.
+func (hw *TFT) Update(r image.Rectangle, pix []byte) (err error) {
+	hw.Lock()
+	defer hw.Unlock()
+
+	if r.Empty() || !r.In(image.Rect(0, 0, hw.Width, hw.Height)) {
+		return errors.New("invalid area")
+	}
+
+	if len(pix) != r.Dx()*r.Dy()*2 {
+		return errors.New("invalid pixel buffer size")
+	}
+
+	if err = hw.window(r); err != nil {
+		return
+	}
+
+	if err = hw.command(RAMWR); err != nil {
+		return
+	}
+
+	return hw.data(pix)
+}
@@ -0,0 +1,34 @@
+// First-fit memory allocator for DMA buffers
+// https://github.com/karlo195/tamago
+//
+// Copyright (c) The TamaGo Authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+package dma
+
+// Mapper represents an I/O Memory Management Unit (IOMMU) translation domain,
+// restricting device DMA to explicitly mapped memory.
+//
+// Mappings are identity ones, device (I/O virtual) addresses match the
+// physical addresses returned by Region allocations.
+type Mapper interface {
+	// Map allows device access to the argument physical memory range,
+	// read-only access is granted when write is false.
+	Map(addr uint, size int, write bool) error
+	// Unmap revokes device access to the argument physical memory range.
+	Unmap(addr uint, size int) error
+}
+
+// Map allows device access to the whole region through the argument IOMMU
+// translation domain.
+func (r *Region) Map(m Mapper) error {
+	return m.Map(r.Start(), int(r.Size()), true)
+}
+
+// Unmap revokes device access to the whole region through the argument IOMMU
+// translation domain.
+func (r *Region) Unmap(m Mapper) error {
+	return m.Unmap(r.Start(), int(r.Size()))
+}
@@ -0,0 +1,45 @@
+// https://github.com/karlo195/tamago
+//
+// Copyright (c) The TamaGo Authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+// Package mem provides read-only access to firmware and boot loader data
+// structures (e.g. ACPI tables, SMBIOS or boot parameters) at arbitrary
+// physical addresses.
+//
+// This package is only meant to be used with `GOOS=tamago` as supported by the
+// TamaGo framework for bare metal Go, see https://github.com/karlo195/tamago.
+package mem
+
+import (
+	"github.com/karlo195/tamago/dma"
+)
+
+// Read returns a slice of the argument memory range, which is allowed to
+// overlap Go runtime memory as the slice must only be read and is never
+// allocated or freed.
+func Read(addr uint, size int) (buf []byte, err error) {
+	r, err := dma.NewRegion(addr, size, true)
+
+	if err != nil {
+		return
+	}
+
+	// the range is returned as is, regardless of its alignment
+	_, buf = r.Reserve(size, 1)
+
+	return
+}
+
+// Checksum returns whether all bytes of the argument buffer sum to zero.
+func Checksum(buf []byte) bool {
+	var sum uint8
+
+	for _, b := range buf {
+		sum += b
+	}
+
+	return sum == 0
+}
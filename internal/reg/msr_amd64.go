@@ -9,3 +9,5 @@ package reg
 
 // defined in msr_amd64.s
 func Msr(addr uint32) (val uint32)
+func Msr64(addr uint32) (val uint64)
+func WriteMsr(addr uint32, val uint64)
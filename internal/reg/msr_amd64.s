@@ -11,3 +11,21 @@ TEXT ·Msr(SB),$0-12
 	RDMSR
 	MOVL	AX, val+8(FP)
 	RET
+
+// func Msr64(addr uint32) (val uint64)
+TEXT ·Msr64(SB),$0-16
+	MOVL	addr+0(FP), CX
+	RDMSR
+	SHLQ	$32, DX
+	ORQ	DX, AX
+	MOVQ	AX, val+8(FP)
+	RET
+
+// func WriteMsr(addr uint32, val uint64)
+TEXT ·WriteMsr(SB),$0-16
+	MOVL	addr+0(FP), CX
+	MOVQ	val+8(FP), AX
+	MOVQ	AX, DX
+	SHRQ	$32, DX
+	WRMSR
+	RET
@@ -21,6 +21,15 @@ TEXT ·Out8(SB),$0-3
 	BYTE	$0xee
 	RET
 
+// func Out16(port uint16, val uint16)
+TEXT ·Out16(SB),$0-4
+	MOVW	port+0(FP), DX
+	MOVW	val+2(FP), AX
+	// out dx, ax
+	BYTE	$0x66
+	BYTE	$0xef
+	RET
+
 // func In32(port uint32) (val uint32)
 TEXT ·In32(SB),$0-12
 	MOVL	port+0(FP), DX
@@ -0,0 +1,77 @@
+// QEMU/Bochs debug console driver
+// https://github.com/karlo195/tamago
+//
+// Copyright (c) The TamaGo Authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+// Package debugcon implements a driver for the QEMU and Bochs debug console
+// (debugcon), also supported by Cloud Hypervisor (--debug-console), which
+// outputs characters written to a single I/O port without any initialization.
+//
+// The debug console provides an early output channel, available before UART
+// initialization and on machines without a serial port model, and can be
+// used as console sink (see console.Console):
+//
+//	microvm.Console.Add(&debugcon.DebugCon{})
+//
+// On QEMU the debug console is enabled as follows:
+//
+//	qemu-system-x86_64 ... -debugcon stdio
+//
+// This package is only meant to be used with `GOOS=tamago GOARCH=amd64` as
+// supported by the TamaGo framework for bare metal Go, see
+// https://github.com/karlo195/tamago.
+package debugcon
+
+import (
+	"github.com/karlo195/tamago/internal/reg"
+)
+
+// PORT is the default debug console I/O port.
+const PORT = 0xe9
+
+// DebugCon represents a debug console instance.
+type DebugCon struct {
+	// Port is the I/O port, PORT is used when unset.
+	Port uint16
+}
+
+func (hw *DebugCon) port() uint16 {
+	if hw.Port == 0 {
+		return PORT
+	}
+
+	return hw.Port
+}
+
+// Present returns whether the debug console is available, as QEMU and Bochs
+// implementations return the port number on reads.
+func (hw *DebugCon) Present() bool {
+	port := hw.port()
+	return reg.In8(port) == uint8(port)
+}
+
+// Tx transmits a single character.
+func (hw *DebugCon) Tx(c byte) {
+	reg.Out8(hw.port(), c)
+}
+
+// Write transmits the argument buffer, it implements io.Writer.
+func (hw *DebugCon) Write(buf []byte) (n int, _ error) {
+	port := hw.port()
+
+	for _, c := range buf {
+		reg.Out8(port, c)
+	}
+
+	return len(buf), nil
+}
+
+// Tx transmits a single character on the default debug console port, it can
+// be statically assigned as console default sink (see console.Console) to
+// allow output since early boot.
+func Tx(c byte) {
+	reg.Out8(PORT, c)
+}
@@ -0,0 +1,131 @@
+// Hypervisor entropy reseeding policy
+// https://github.com/karlo195/tamago
+//
+// Copyright (c) The TamaGo Authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+// Package entropy implements a reseeding policy for the runtime random number
+// generator from hypervisor provided entropy sources (e.g. VirtIO RNG), to
+// prevent random stream reuse across restored virtual machine snapshots.
+//
+// This package is only meant to be used with `GOOS=tamago` as
+// supported by the TamaGo framework for bare metal Go, see
+// https://github.com/karlo195/tamago.
+package entropy
+
+import (
+	"errors"
+	"io"
+	"sync"
+	"time"
+)
+
+// DefaultInterval is the default periodic reseed interval.
+const DefaultInterval = 10 * time.Minute
+
+// seedSize is the amount of entropy gathered on each reseed.
+const seedSize = 32
+
+// Policy represents a runtime random number generator reseeding policy.
+type Policy struct {
+	sync.Mutex
+
+	// Source represents the hypervisor entropy source (e.g. virtio.RNG).
+	Source io.Reader
+	// Reseed mixes entropy into the runtime random number generator
+	// (e.g. amd64.AddEntropy).
+	Reseed func([]byte)
+	// Interval is the periodic reseed interval (default: DefaultInterval).
+	Interval time.Duration
+
+	// Last is the time of the last successful reseed.
+	Last time.Time
+
+	stop chan struct{}
+}
+
+func (p *Policy) reseed() (err error) {
+	buf := make([]byte, seedSize)
+
+	if _, err = io.ReadFull(p.Source, buf); err != nil {
+		return
+	}
+
+	p.Reseed(buf)
+	p.Last = time.Now()
+
+	return
+}
+
+// Start performs an initial reseed and starts periodic reseeding.
+func (p *Policy) Start() (err error) {
+	p.Lock()
+	defer p.Unlock()
+
+	if p.Source == nil || p.Reseed == nil {
+		return errors.New("invalid policy")
+	}
+
+	if p.stop != nil {
+		return errors.New("policy already started")
+	}
+
+	if p.Interval == 0 {
+		p.Interval = DefaultInterval
+	}
+
+	if err = p.reseed(); err != nil {
+		return
+	}
+
+	p.stop = make(chan struct{})
+
+	go p.run(p.stop)
+
+	return
+}
+
+// Stop stops periodic reseeding.
+func (p *Policy) Stop() {
+	p.Lock()
+	defer p.Unlock()
+
+	if p.stop == nil {
+		return
+	}
+
+	close(p.stop)
+	p.stop = nil
+}
+
+// Resume performs an immediate reseed, it must be invoked when a snapshot
+// restore (e.g. VM Generation ID change) is detected.
+func (p *Policy) Resume() (err error) {
+	p.Lock()
+	defer p.Unlock()
+
+	if p.Source == nil || p.Reseed == nil {
+		return errors.New("invalid policy")
+	}
+
+	return p.reseed()
+}
+
+func (p *Policy) run(stop chan struct{}) {
+	t := time.NewTicker(p.Interval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-t.C:
+			p.Lock()
+			// failures are retried at the next interval
+			p.reseed()
+			p.Unlock()
+		}
+	}
+}
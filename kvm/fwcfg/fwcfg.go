@@ -0,0 +1,173 @@
+// QEMU Firmware Configuration (fw_cfg) driver
+// https://github.com/karlo195/tamago
+//
+// Copyright (c) The TamaGo Authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+// Package fwcfg implements a driver for the QEMU Firmware Configuration
+// (fw_cfg) device, through its I/O port interface, following reference
+// specifications:
+//   - https://www.qemu.org/docs/master/specs/fw_cfg.html
+//
+// Configuration files passed on the QEMU command line are exposed as a
+// key/value source (see config.Source):
+//
+//	qemu-system-x86_64 ... -fw_cfg name=opt/tamago/hostname,string=vm0
+//
+// This package is only meant to be used with `GOOS=tamago GOARCH=amd64` as
+// supported by the TamaGo framework for bare metal Go, see
+// https://github.com/karlo195/tamago.
+package fwcfg
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"strings"
+	"sync"
+
+	"github.com/karlo195/tamago/internal/reg"
+)
+
+// I/O ports
+const (
+	SELECTOR = 0x510
+	DATA     = 0x511
+)
+
+// Selector keys
+const (
+	FW_CFG_SIGNATURE = 0x0000
+	FW_CFG_ID        = 0x0001
+	FW_CFG_FILE_DIR  = 0x0019
+)
+
+// DefaultPrefix is the default file name prefix of configuration entries.
+const DefaultPrefix = "opt/tamago/"
+
+const (
+	signature  = "QEMU"
+	fileLength = 64
+	nameLength = 56
+)
+
+// File represents a fw_cfg file directory entry.
+type File struct {
+	// Name is the file name.
+	Name string
+	// Size is the file size.
+	Size uint32
+	// Select is the file selector key.
+	Select uint16
+}
+
+// FwCfg represents a fw_cfg device instance.
+type FwCfg struct {
+	sync.Mutex
+
+	// Prefix is the file name prefix, stripped from returned keys, of
+	// configuration entries (default DefaultPrefix).
+	Prefix string
+}
+
+func (fw *FwCfg) read(key uint16, size int) []byte {
+	reg.Out16(SELECTOR, key)
+	return fw.next(size)
+}
+
+func (fw *FwCfg) next(size int) (buf []byte) {
+	buf = make([]byte, size)
+
+	for i := range buf {
+		buf[i] = reg.In8(DATA)
+	}
+
+	return
+}
+
+// Init initializes the fw_cfg device instance.
+func (fw *FwCfg) Init() (err error) {
+	fw.Lock()
+	defer fw.Unlock()
+
+	if fw.Prefix == "" {
+		fw.Prefix = DefaultPrefix
+	}
+
+	if string(fw.read(FW_CFG_SIGNATURE, len(signature))) != signature {
+		return errors.New("fw_cfg device not found")
+	}
+
+	return
+}
+
+// Files returns the fw_cfg file directory.
+func (fw *FwCfg) Files() (files []File) {
+	fw.Lock()
+	defer fw.Unlock()
+
+	// the directory count and entries are read in sequence from the same
+	// selector
+	reg.Out16(SELECTOR, FW_CFG_FILE_DIR)
+
+	n := binary.BigEndian.Uint32(fw.next(4))
+
+	for i := uint32(0); i < n; i++ {
+		buf := fw.next(fileLength)
+
+		name, _, _ := bytes.Cut(buf[8:8+nameLength], []byte{0})
+
+		files = append(files, File{
+			Name:   string(name),
+			Size:   binary.BigEndian.Uint32(buf[0:]),
+			Select: binary.BigEndian.Uint16(buf[4:]),
+		})
+	}
+
+	return
+}
+
+// ReadFile returns the contents of the named fw_cfg file.
+func (fw *FwCfg) ReadFile(name string) (buf []byte, err error) {
+	for _, f := range fw.Files() {
+		if f.Name != name {
+			continue
+		}
+
+		fw.Lock()
+		defer fw.Unlock()
+
+		return fw.read(f.Select, int(f.Size)), nil
+	}
+
+	return nil, errors.New("file not found")
+}
+
+// Name returns the configuration source name.
+func (fw *FwCfg) Name() string {
+	return "fw_cfg"
+}
+
+// Load returns the contents of all fw_cfg files matching Prefix, keyed by
+// their name without Prefix.
+func (fw *FwCfg) Load() (entries map[string]string, err error) {
+	entries = make(map[string]string)
+
+	for _, f := range fw.Files() {
+		key, ok := strings.CutPrefix(f.Name, fw.Prefix)
+
+		if !ok || key == "" {
+			continue
+		}
+
+		fw.Lock()
+		val := fw.read(f.Select, int(f.Size))
+		fw.Unlock()
+
+		entries[key] = string(bytes.TrimRight(val, "\x00\n"))
+	}
+
+	return
+}
@@ -0,0 +1,142 @@
+// Inter-VM Shared Memory driver
+// https://github.com/karlo195/tamago
+//
+// Copyright (c) The TamaGo Authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+// Package ivshmem implements a driver for QEMU Inter-VM Shared Memory
+// (ivshmem) PCI devices following reference specifications:
+//   - https://www.qemu.org/docs/master/specs/ivshmem-spec.html
+//
+// This package is only meant to be used with `GOOS=tamago` as
+// supported by the TamaGo framework for bare metal Go, see
+// https://github.com/karlo195/tamago.
+package ivshmem
+
+import (
+	"errors"
+
+	"github.com/karlo195/tamago/amd64/lapic"
+	"github.com/karlo195/tamago/dma"
+	"github.com/karlo195/tamago/internal/reg"
+	"github.com/karlo195/tamago/soc/intel/pci"
+)
+
+// PCI identifiers
+const (
+	VendorID = 0x1af4
+	DeviceID = 0x1110
+)
+
+// ivshmem registers (BAR0)
+const (
+	INTRMASK   = 0x00
+	INTRSTATUS = 0x04
+	IVPOSITION = 0x08
+
+	DOORBELL      = 0x0c
+	DOORBELL_PEER = 16
+)
+
+// Base Address Registers
+const (
+	registersBAR    = 0
+	sharedMemoryBAR = 2
+)
+
+// IVSHMEM represents an Inter-VM Shared Memory device instance.
+type IVSHMEM struct {
+	// Device represents the probed PCI device.
+	Device *pci.Device
+
+	// registers base address
+	base uint32
+	// shared memory physical address and size
+	addr uint64
+	size uint64
+
+	msix *pci.CapabilityMSIX
+}
+
+// Init initializes an Inter-VM Shared Memory device instance.
+func (hw *IVSHMEM) Init() (err error) {
+	if hw.Device == nil || hw.Device.Vendor != VendorID || hw.Device.Device != DeviceID {
+		return errors.New("invalid ivshmem instance")
+	}
+
+	hw.base = uint32(hw.Device.BaseAddress(registersBAR)) &^ 0xf
+	hw.addr = uint64(hw.Device.BaseAddress(sharedMemoryBAR)) &^ 0xf
+	hw.size = hw.Device.BaseAddressSize(sharedMemoryBAR)
+
+	if hw.base == 0 || hw.addr == 0 || hw.size == 0 {
+		return errors.New("invalid ivshmem BARs")
+	}
+
+	if msix, ok := hw.Device.Capability(pci.MSIX).(*pci.CapabilityMSIX); ok {
+		hw.msix = msix
+	}
+
+	return
+}
+
+// SharedMemory returns the physical address and size of the shared memory
+// region.
+func (hw *IVSHMEM) SharedMemory() (addr uint64, size uint64) {
+	return hw.addr, hw.size
+}
+
+// Bytes returns the shared memory region as a byte slice, allowing zero-copy
+// access to host-shared mappings.
+//
+// On amd64 the region might require mapping before use (e.g.
+// amd64.CPU.MapMMIO()).
+func (hw *IVSHMEM) Bytes() (buf []byte, err error) {
+	r, err := dma.NewRegion(uint(hw.addr), int(hw.size), false)
+
+	if err != nil {
+		return
+	}
+
+	_, buf = r.Reserve(int(hw.size), 0)
+
+	return
+}
+
+// Position returns the device peer ID, a negative value is returned when the
+// device is not connected to an ivshmem server (i.e. without doorbell
+// support).
+func (hw *IVSHMEM) Position() int {
+	return int(int32(reg.Read(hw.base + IVPOSITION)))
+}
+
+// Ring signals the interrupt vector of a peer through the doorbell register.
+func (hw *IVSHMEM) Ring(peer int, vector int) {
+	reg.Write(hw.base+DOORBELL, uint32(peer)<<DOORBELL_PEER|uint32(vector)&0xffff)
+}
+
+// EnableInterrupt enables MSI-X interrupt vector routing to the bootstrap
+// processor LAPIC for the indexed doorbell vector.
+//
+// The interrupt vector can be obtained with [amd64.CPU.AllocateInterrupt].
+func (hw *IVSHMEM) EnableInterrupt(id int, vector int) (err error) {
+	if hw.msix == nil {
+		return errors.New("missing MSI-X capability")
+	}
+
+	addr, data := lapic.MSIMessage(0, id, lapic.ICR_DLV_IRQ)
+
+	return hw.msix.EnableInterrupt(vector, addr, data)
+}
+
+// SetInterruptMask sets the legacy (INTx) interrupt mask, used in absence of
+// MSI-X.
+func (hw *IVSHMEM) SetInterruptMask(mask uint32) {
+	reg.Write(hw.base+INTRMASK, mask)
+}
+
+// InterruptStatus returns, and clears, the legacy (INTx) interrupt status.
+func (hw *IVSHMEM) InterruptStatus() uint32 {
+	return reg.Read(hw.base + INTRSTATUS)
+}
@@ -0,0 +1,152 @@
+// KVM paravirtualization interface
+// https://github.com/karlo195/tamago
+//
+// Copyright (c) The TamaGo Authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+// Package pv implements detection of KVM paravirtualized features, hypercalls
+// and steal time accounting following reference specifications:
+//   - https://docs.kernel.org/virt/kvm/x86/cpuid.html
+//   - https://docs.kernel.org/virt/kvm/x86/hypercalls.html
+//   - https://docs.kernel.org/virt/kvm/x86/msr.html
+//
+// This package is only meant to be used with `GOOS=tamago GOARCH=amd64` as
+// supported by the TamaGo framework for bare metal Go, see
+// https://github.com/karlo195/tamago.
+package pv
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/karlo195/tamago/amd64"
+)
+
+// KVM paravirtualized features (KVM_CPUID_FEATURES EAX)
+const (
+	FEATURE_CLOCKSOURCE        = 0
+	FEATURE_NOP_IO_DELAY       = 1
+	FEATURE_MMU_OP             = 2
+	FEATURE_CLOCKSOURCE2       = 3
+	FEATURE_ASYNC_PF           = 4
+	FEATURE_STEAL_TIME         = 5
+	FEATURE_PV_EOI             = 6
+	FEATURE_PV_UNHALT          = 7
+	FEATURE_PV_TLB_FLUSH       = 9
+	FEATURE_ASYNC_PF_VMEXIT    = 10
+	FEATURE_PV_SEND_IPI        = 11
+	FEATURE_POLL_CONTROL       = 12
+	FEATURE_PV_SCHED_YIELD     = 13
+	FEATURE_ASYNC_PF_INT       = 14
+	FEATURE_MSI_EXT_DEST_ID    = 15
+	FEATURE_HC_MAP_GPA_RANGE   = 16
+	FEATURE_MIGRATION_CONTROL  = 17
+	FEATURE_CLOCKSOURCE_STABLE = 24
+)
+
+// KVM paravirtualized hints (KVM_CPUID_FEATURES EDX)
+const (
+	HINT_REALTIME = 0
+)
+
+// KVM hypercall numbers
+const (
+	KVM_HC_VAPIC_POLL_IRQ = 1
+	KVM_HC_KICK_CPU       = 5
+	KVM_HC_CLOCK_PAIRING  = 9
+	KVM_HC_SEND_IPI       = 10
+	KVM_HC_SCHED_YIELD    = 11
+	KVM_HC_MAP_GPA_RANGE  = 12
+)
+
+// KVM hypercall error codes
+const (
+	KVM_ENOSYS     = 1000
+	KVM_EFAULT     = 14
+	KVM_EINVAL     = 22
+	KVM_E2BIG      = 7
+	KVM_EPERM      = 1
+	KVM_EOPNOTSUPP = 95
+)
+
+// defined in pv.s
+func vmcall(nr, a0, a1, a2, a3 uint64) (ret int64)
+func vmmcall(nr, a0, a1, a2, a3 uint64) (ret int64)
+
+// KVM represents the KVM paravirtualization interface of a processor
+// instance.
+type KVM struct {
+	// CPU is the processor instance
+	CPU *amd64.CPU
+
+	// Features is the paravirtualized features bitmap
+	Features uint32
+	// Hints is the paravirtualized hints bitmap
+	Hints uint32
+
+	amd bool
+}
+
+// Init detects the KVM paravirtualized features.
+func (hw *KVM) Init() (err error) {
+	if hw.CPU == nil {
+		return errors.New("invalid CPU instance")
+	}
+
+	features := hw.CPU.Features()
+
+	if !features.KVM {
+		return errors.New("KVM not detected")
+	}
+
+	hw.Features, _, _, hw.Hints = hw.CPU.CPUID(amd64.KVM_CPUID_FEATURES, 0)
+
+	// AMD processors use VMMCALL rather than VMCALL
+	hw.amd = strings.HasPrefix(features.Vendor, "AuthenticAMD") ||
+		strings.HasPrefix(features.Vendor, "HygonGenuine")
+
+	return
+}
+
+// Has returns whether a paravirtualized feature is available.
+func (hw *KVM) Has(feature int) bool {
+	return hw.Features&(1<<feature) != 0
+}
+
+// Hypercall issues a KVM hypercall, up to four arguments are supported.
+func (hw *KVM) Hypercall(nr uint64, args ...uint64) (ret int64, err error) {
+	var a [4]uint64
+
+	if len(args) > len(a) {
+		return 0, errors.New("too many arguments")
+	}
+
+	copy(a[:], args)
+
+	if hw.amd {
+		ret = vmmcall(nr, a[0], a[1], a[2], a[3])
+	} else {
+		ret = vmcall(nr, a[0], a[1], a[2], a[3])
+	}
+
+	if ret < 0 {
+		err = fmt.Errorf("hypercall error %d", -ret)
+	}
+
+	return
+}
+
+// Yield yields the processor to the target vCPU, identified by its APIC ID,
+// which is preempted while holding a resource needed by the caller.
+func (hw *KVM) Yield(apicID int) (err error) {
+	if !hw.Has(FEATURE_PV_SCHED_YIELD) {
+		return errors.New("unsupported feature")
+	}
+
+	_, err = hw.Hypercall(KVM_HC_SCHED_YIELD, uint64(apicID))
+
+	return
+}
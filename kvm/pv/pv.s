@@ -0,0 +1,41 @@
+// KVM paravirtualization interface
+// https://github.com/usbarmory/tamago
+//
+// Copyright (c) The TamaGo Authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+#include "textflag.h"
+
+// func vmcall(nr, a0, a1, a2, a3 uint64) (ret int64)
+TEXT ·vmcall(SB),$0-48
+	MOVQ	nr+0(FP), AX
+	MOVQ	a0+8(FP), BX
+	MOVQ	a1+16(FP), CX
+	MOVQ	a2+24(FP), DX
+	MOVQ	a3+32(FP), SI
+
+	// VMCALL
+	BYTE	$0x0f
+	BYTE	$0x01
+	BYTE	$0xc1
+
+	MOVQ	AX, ret+40(FP)
+	RET
+
+// func vmmcall(nr, a0, a1, a2, a3 uint64) (ret int64)
+TEXT ·vmmcall(SB),$0-48
+	MOVQ	nr+0(FP), AX
+	MOVQ	a0+8(FP), BX
+	MOVQ	a1+16(FP), CX
+	MOVQ	a2+24(FP), DX
+	MOVQ	a3+32(FP), SI
+
+	// VMMCALL
+	BYTE	$0x0f
+	BYTE	$0x01
+	BYTE	$0xd9
+
+	MOVQ	AX, ret+40(FP)
+	RET
@@ -0,0 +1,102 @@
+// KVM paravirtualization interface
+// https://github.com/karlo195/tamago
+//
+// Copyright (c) The TamaGo Authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+package pv
+
+import (
+	"errors"
+	"sync/atomic"
+	"time"
+	"unsafe"
+
+	"github.com/karlo195/tamago/amd64"
+	"github.com/karlo195/tamago/dma"
+	"github.com/karlo195/tamago/internal/reg"
+)
+
+// Steal time MSR
+const (
+	MSR_KVM_STEAL_TIME = 0x4b564d03
+	KVM_MSR_ENABLED    = 0
+)
+
+// struct kvm_steal_time layout
+const (
+	stealTimeSize      = 64
+	stealTimeSteal     = 0x00
+	stealTimeVersion   = 0x08
+	stealTimePreempted = 0x10
+)
+
+// StealTime represents the per-vCPU steal time areas, updated by the host
+// to account for time during which vCPUs were not running.
+type StealTime struct {
+	addr uint
+	buf  []byte
+}
+
+// EnableStealTime enables steal time accounting on all initialized
+// processors (see [amd64.CPU.Broadcast]).
+func (hw *KVM) EnableStealTime() (st *StealTime, err error) {
+	if !hw.Has(FEATURE_STEAL_TIME) {
+		return nil, errors.New("unsupported feature")
+	}
+
+	// processor indices match LAPIC IDs (see amd64.CurrentCPU)
+	n := amd64.NumCPU()
+	st = &StealTime{}
+
+	st.addr, st.buf = dma.Reserve(n*stealTimeSize, stealTimeSize)
+	clear(st.buf)
+
+	err = hw.CPU.Broadcast(func() {
+		if i := amd64.CurrentCPU(); i < n {
+			reg.WriteMsr(MSR_KVM_STEAL_TIME, uint64(st.addr+uint(i*stealTimeSize))|1<<KVM_MSR_ENABLED)
+		}
+	})
+
+	return
+}
+
+func (st *StealTime) area(cpu int) []byte {
+	if cpu < 0 || (cpu+1)*stealTimeSize > len(st.buf) {
+		panic("invalid processor index")
+	}
+
+	return st.buf[cpu*stealTimeSize : (cpu+1)*stealTimeSize]
+}
+
+// Steal returns the cumulative time during which the indexed processor was
+// runnable but not running on the host.
+func (st *StealTime) Steal(cpu int) time.Duration {
+	a := st.area(cpu)
+
+	version := (*uint32)(unsafe.Pointer(&a[stealTimeVersion]))
+	steal := (*uint64)(unsafe.Pointer(&a[stealTimeSteal]))
+
+	for {
+		v := atomic.LoadUint32(version)
+
+		// odd versions signal updates in progress
+		if v%2 != 0 {
+			continue
+		}
+
+		val := atomic.LoadUint64(steal)
+
+		if atomic.LoadUint32(version) == v {
+			return time.Duration(val)
+		}
+	}
+}
+
+// Preempted returns whether the indexed processor is currently preempted by
+// the host.
+func (st *StealTime) Preempted(cpu int) bool {
+	return st.area(cpu)[stealTimePreempted]&1 != 0
+}
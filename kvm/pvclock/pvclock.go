@@ -85,7 +85,11 @@ func pvClock(cpu *amd64.CPU, timeInfo *pvClockTimeInfo) int64 {
 	return int64(r.Uint64() + timeInfo.SystemTime)
 }
 
-func pvClockSync(cpu *amd64.CPU) {
+// pvClockSync periodically adjusts the system time with kvmclock, the argument
+// timer offset is the one in use when the adjustment is started.
+func pvClockSync(cpu *amd64.CPU, offset int64) {
+	var epoch int64
+
 	version := uint32(0)
 	timeInfo := &pvClockTimeInfo{}
 
@@ -98,8 +102,13 @@ func pvClockSync(cpu *amd64.CPU) {
 			continue
 		}
 
+		// preserve system time changes (e.g. wall clock setting)
+		// performed since the last adjustment
+		epoch += cpu.TimerOffset - offset
+
 		version = timeInfo.Version
-		cpu.SetTime(pvClock(cpu, timeInfo))
+		cpu.SetTime(pvClock(cpu, timeInfo) + epoch)
+		offset = cpu.TimerOffset
 	}
 }
 
@@ -126,7 +135,7 @@ func Init(cpu *amd64.CPU) {
 		//
 		// If ever required pvClockSync() can be moved to Go assembly.
 		initTimeInfo(features.KVMClockMSR)
-		go pvClockSync(cpu)
+		go pvClockSync(cpu, cpu.TimerOffset)
 	default:
 		panic("could not set system timer")
 	}
@@ -0,0 +1,110 @@
+// QEMU pvpanic driver
+// https://github.com/karlo195/tamago
+//
+// Copyright (c) The TamaGo Authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+// Package pvpanic implements a driver for QEMU pvpanic devices, to notify
+// guest panics to the host, following reference specifications:
+//   - https://www.qemu.org/docs/master/specs/pvpanic.html
+//
+// This package is only meant to be used with `GOOS=tamago GOARCH=amd64` as
+// supported by the TamaGo framework for bare metal Go, see
+// https://github.com/karlo195/tamago.
+package pvpanic
+
+import (
+	"errors"
+
+	"github.com/karlo195/tamago/internal/reg"
+	"github.com/karlo195/tamago/soc/intel/pci"
+)
+
+// ISA device default I/O port
+const PORT = 0x505
+
+// PCI identifiers
+const (
+	VendorID = 0x1b36
+	DeviceID = 0x0011
+)
+
+// Events
+const (
+	PANICKED     = 0
+	CRASH_LOADED = 1
+	SHUTDOWN     = 2
+)
+
+// PVPanic represents a pvpanic device instance.
+type PVPanic struct {
+	// Port is the ISA device I/O port (default PORT), used when Device is
+	// not set.
+	Port uint16
+	// Device represents the probed PCI device.
+	Device *pci.Device
+
+	// PCI register address
+	base uint32
+	// supported events
+	events uint8
+}
+
+func (hw *PVPanic) read() uint8 {
+	if hw.base != 0 {
+		return uint8(reg.Read16(hw.base))
+	}
+
+	return reg.In8(hw.Port)
+}
+
+// Init initializes a pvpanic device instance.
+func (hw *PVPanic) Init() (err error) {
+	if d := hw.Device; d != nil {
+		if d.Vendor != VendorID || d.Device != DeviceID {
+			return errors.New("invalid pvpanic instance")
+		}
+
+		if hw.base = uint32(d.BaseAddress(0)) &^ 0xf; hw.base == 0 {
+			return errors.New("invalid pvpanic BAR")
+		}
+	} else if hw.Port == 0 {
+		hw.Port = PORT
+	}
+
+	// absent ISA ports read as all ones
+	if hw.events = hw.read(); hw.events == 0 || hw.events == 0xff {
+		return errors.New("pvpanic device not found")
+	}
+
+	return
+}
+
+// Supported returns whether the argument event is supported by the device.
+func (hw *PVPanic) Supported(event int) bool {
+	return (hw.events>>event)&1 == 1
+}
+
+// Notify notifies the argument event to the host.
+func (hw *PVPanic) Notify(event int) {
+	if !hw.Supported(event) {
+		return
+	}
+
+	val := uint8(1 << event)
+
+	if hw.base != 0 {
+		reg.Write16(hw.base, uint16(val))
+		return
+	}
+
+	reg.Out8(hw.Port, val)
+}
+
+// Panicked notifies a guest panic, the host handles it according to its
+// configuration (e.g. pausing the guest).
+func (hw *PVPanic) Panicked() {
+	hw.Notify(PANICKED)
+}
@@ -0,0 +1,269 @@
+// VirtIO driver
+// https://github.com/karlo195/tamago
+//
+// Copyright (c) The TamaGo Authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+package virtio
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/karlo195/tamago/bits"
+)
+
+// Block device identifier
+const BlockDeviceID = 2
+
+// Block device feature bits
+// (5.2.3 Feature bits - Virtual I/O Device (VIRTIO) - Version 1.2).
+const (
+	BlockRO      = 5
+	BlockBlkSize = 6
+	BlockFlush   = 9
+)
+
+// Block device request types
+// (5.2.6 Device Operation - Virtual I/O Device (VIRTIO) - Version 1.2).
+const (
+	BlockRequestIn    = 0
+	BlockRequestOut   = 1
+	BlockRequestFlush = 4
+	BlockRequestGetID = 8
+)
+
+// Block device request status
+const (
+	BlockStatusOK     = 0
+	BlockStatusIOErr  = 1
+	BlockStatusUnsupp = 2
+)
+
+// SectorSize is the block device addressing unit, independent from the
+// device block size.
+const SectorSize = 512
+
+const (
+	blockConfigSize = 24
+	blockHeaderSize = 16
+	blockIDSize     = 20
+
+	// maximum sectors per request
+	blockMaxSectors = 128
+)
+
+// BlockRequestTimeout is the default timeout for block device requests.
+const BlockRequestTimeout = 5 * time.Second
+
+// Block represents a VirtIO block device instance
+// (5.2 Block Device - Virtual I/O Device (VIRTIO) - Version 1.2).
+//
+// The device is addressed in 512 bytes sectors (see [SectorSize]),
+// implementing the block device API of the NXP uSDHC driver (see
+// soc/nxp/usdhc).
+type Block struct {
+	sync.Mutex
+
+	// Device represents the VirtIO transport instance
+	Device VirtIO
+	// Timeout for device requests (default: [BlockRequestTimeout])
+	Timeout time.Duration
+
+	queue *VirtualQueue
+}
+
+// Init initializes a VirtIO block device instance.
+func (hw *Block) Init() (err error) {
+	if hw.Device == nil || hw.Device.DeviceID() != BlockDeviceID {
+		return errors.New("invalid VirtIO block device")
+	}
+
+	if err = hw.Device.Init(1<<Version1 | 1<<BlockRO | 1<<BlockBlkSize | 1<<BlockFlush); err != nil {
+		return
+	}
+
+	if hw.Timeout == 0 {
+		hw.Timeout = BlockRequestTimeout
+	}
+
+	// header, data and status descriptors
+	hw.queue = &VirtualQueue{}
+	hw.queue.InitChain(blockHeaderSize, blockMaxSectors*SectorSize, 1)
+
+	hw.Device.SetQueueSize(0, len(hw.queue.Descriptors))
+	hw.Device.SetQueue(0, hw.queue)
+	hw.Device.SetReady()
+
+	return
+}
+
+// Capacity returns the device size in 512 bytes sectors.
+func (hw *Block) Capacity() uint64 {
+	return binary.LittleEndian.Uint64(hw.Device.Config(blockConfigSize))
+}
+
+// BlockSize returns the device optimal block size, which defaults to
+// [SectorSize] when not advertised.
+func (hw *Block) BlockSize() int {
+	features := hw.Device.NegotiatedFeatures()
+
+	if !bits.IsSet64(&features, BlockBlkSize) {
+		return SectorSize
+	}
+
+	return int(binary.LittleEndian.Uint32(hw.Device.Config(blockConfigSize)[20:]))
+}
+
+// ReadOnly returns whether the device is write protected.
+func (hw *Block) ReadOnly() bool {
+	features := hw.Device.NegotiatedFeatures()
+	return bits.IsSet64(&features, BlockRO)
+}
+
+// request performs a single device request, returning its device-writable
+// data.
+//
+// Each request is a chain of separate header, data and status descriptors, as
+// required by some device implementations (e.g. Firecracker), the data
+// descriptor is omitted when no data is transferred.
+func (hw *Block) request(t uint32, sector uint64, data []byte, n int) (res []byte, err error) {
+	if hw.queue == nil {
+		return nil, errors.New("device not initialized")
+	}
+
+	hdr := make([]byte, blockHeaderSize)
+	status := []byte{0xff}
+
+	binary.LittleEndian.PutUint32(hdr[0:], t)
+	binary.LittleEndian.PutUint64(hdr[8:], sector)
+
+	segs := []Segment{{Buf: hdr}}
+
+	switch {
+	case len(data) > 0:
+		segs = append(segs, Segment{Buf: data})
+	case n > 0:
+		res = make([]byte, n)
+		segs = append(segs, Segment{Buf: res, Write: true})
+	}
+
+	segs = append(segs, Segment{Buf: status, Write: true})
+
+	hw.queue.RequestChain(segs)
+	hw.Device.QueueNotify(0)
+
+	start := time.Now()
+
+	for !hw.queue.ResponseChain(segs) {
+		if time.Since(start) >= hw.Timeout {
+			return nil, errors.New("request timeout")
+		}
+
+		runtime.Gosched()
+	}
+
+	switch status[0] {
+	case BlockStatusOK:
+		return res, nil
+	case BlockStatusUnsupp:
+		return nil, errors.New("unsupported request")
+	default:
+		return nil, fmt.Errorf("I/O error (sector %d)", sector)
+	}
+}
+
+func (hw *Block) transfer(lba int, buf []byte, out bool) (err error) {
+	hw.Lock()
+	defer hw.Unlock()
+
+	if lba < 0 || len(buf)%SectorSize != 0 {
+		return errors.New("invalid transfer")
+	}
+
+	if uint64(lba)+uint64(len(buf)/SectorSize) > hw.Capacity() {
+		return errors.New("transfer exceeds capacity")
+	}
+
+	for off := 0; off < len(buf); {
+		n := min(len(buf)-off, blockMaxSectors*SectorSize)
+		sector := uint64(lba + off/SectorSize)
+
+		if out {
+			_, err = hw.request(BlockRequestOut, sector, buf[off:off+n], 0)
+		} else {
+			var res []byte
+
+			if res, err = hw.request(BlockRequestIn, sector, nil, n); err == nil {
+				copy(buf[off:], res)
+			}
+		}
+
+		if err != nil {
+			return
+		}
+
+		off += n
+	}
+
+	return
+}
+
+// ReadBlocks reads consecutive 512 bytes sectors, starting at the argument
+// LBA, to the argument buffer.
+func (hw *Block) ReadBlocks(lba int, buf []byte) (err error) {
+	return hw.transfer(lba, buf, false)
+}
+
+// WriteBlocks writes the argument buffer to consecutive 512 bytes sectors,
+// starting at the argument LBA.
+func (hw *Block) WriteBlocks(lba int, buf []byte) (err error) {
+	if hw.ReadOnly() {
+		return errors.New("read-only device")
+	}
+
+	return hw.transfer(lba, buf, true)
+}
+
+// Flush commits written data to persistent storage, it is a no-op on devices
+// without write cache flush support.
+func (hw *Block) Flush() (err error) {
+	hw.Lock()
+	defer hw.Unlock()
+
+	features := hw.Device.NegotiatedFeatures()
+
+	if !bits.IsSet64(&features, BlockFlush) {
+		return
+	}
+
+	_, err = hw.request(BlockRequestFlush, 0, nil, 0)
+
+	return
+}
+
+// ID returns the device serial number.
+func (hw *Block) ID() (id string, err error) {
+	hw.Lock()
+	defer hw.Unlock()
+
+	res, err := hw.request(BlockRequestGetID, 0, nil, blockIDSize)
+
+	if err != nil {
+		return
+	}
+
+	for i, c := range res {
+		if c == 0 {
+			return string(res[:i]), nil
+		}
+	}
+
+	return string(res), nil
+}
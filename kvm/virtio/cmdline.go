@@ -0,0 +1,136 @@
+// VirtIO over MMIO driver
+// https://github.com/karlo195/tamago
+//
+// Copyright (c) The TamaGo Authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+package virtio
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// MMIOParameter is the kernel command line parameter declaring VirtIO over
+// MMIO devices, as passed by Firecracker and QEMU microvm.
+const MMIOParameter = "virtio_mmio.device"
+
+// MMIODevice represents a VirtIO over MMIO device declared on the kernel
+// command line.
+type MMIODevice struct {
+	MMIO
+
+	// Size is the register window size.
+	Size uint64
+	// IRQ is the device interrupt line (e.g. I/O APIC Global System
+	// Interrupt).
+	IRQ int
+	// ID is the optional platform device identifier.
+	ID int
+}
+
+// parseSize parses a memory size with optional K, M or G suffix.
+func parseSize(s string) (size uint64, err error) {
+	shift := 0
+
+	if n := len(s); n > 0 {
+		switch s[n-1] {
+		case 'k', 'K':
+			shift = 10
+		case 'm', 'M':
+			shift = 20
+		case 'g', 'G':
+			shift = 30
+		}
+
+		if shift != 0 {
+			s = s[:n-1]
+		}
+	}
+
+	if size, err = strconv.ParseUint(s, 0, 64); err != nil {
+		return
+	}
+
+	return size << shift, nil
+}
+
+// ParseCommandLine returns the VirtIO over MMIO devices declared on the
+// argument kernel command line (e.g. amd64.CommandLine()) with parameters in
+// `virtio_mmio.device=<size>@<base>:<irq>[:<id>]` format.
+//
+// The returned devices are VirtIO transports ready to be passed to device
+// drivers, their DeviceID() identifies the device type.
+func ParseCommandLine(cmdline string) (devices []*MMIODevice, err error) {
+	for _, arg := range strings.Fields(cmdline) {
+		val, ok := strings.CutPrefix(arg, MMIOParameter+"=")
+
+		if !ok {
+			continue
+		}
+
+		var base uint64
+		dev := &MMIODevice{}
+
+		size, rest, ok := strings.Cut(val, "@")
+
+		if !ok {
+			return nil, fmt.Errorf("invalid %s (%s)", MMIOParameter, val)
+		}
+
+		if dev.Size, err = parseSize(size); err != nil {
+			return nil, fmt.Errorf("invalid %s size (%s)", MMIOParameter, val)
+		}
+
+		fields := strings.Split(rest, ":")
+
+		if len(fields) < 2 || len(fields) > 3 {
+			return nil, fmt.Errorf("invalid %s (%s)", MMIOParameter, val)
+		}
+
+		if base, err = strconv.ParseUint(fields[0], 0, 32); err != nil {
+			return nil, fmt.Errorf("invalid %s address (%s)", MMIOParameter, val)
+		}
+
+		dev.Base = uint32(base)
+
+		if dev.IRQ, err = strconv.Atoi(fields[1]); err != nil {
+			return nil, fmt.Errorf("invalid %s IRQ (%s)", MMIOParameter, val)
+		}
+
+		if len(fields) == 3 {
+			if dev.ID, err = strconv.Atoi(fields[2]); err != nil {
+				return nil, fmt.Errorf("invalid %s ID (%s)", MMIOParameter, val)
+			}
+		}
+
+		devices = append(devices, dev)
+	}
+
+	return
+}
+
+// Find returns the first VirtIO over MMIO device, declared on the argument
+// kernel command line (see ParseCommandLine()), matching the argument device
+// ID (e.g. NetDeviceID), nil is returned when not found.
+//
+// Its base address and IRQ should be preferred over fixed definitions as the
+// device layout depends on the VMM version and configuration.
+func Find(cmdline string, id uint32) *MMIODevice {
+	devices, err := ParseCommandLine(cmdline)
+
+	if err != nil {
+		return nil
+	}
+
+	for _, dev := range devices {
+		if dev.DeviceID() == id {
+			return dev
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,166 @@
+// VirtIO driver
+// https://github.com/karlo195/tamago
+//
+// Copyright (c) The TamaGo Authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+package virtio
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+)
+
+// Console device identifier
+const ConsoleDeviceID = 3
+
+const (
+	consoleRxQueue = 0
+	consoleTxQueue = 1
+
+	consoleQueueSize  = 16
+	consoleBufferSize = 128
+)
+
+// Console represents a VirtIO console device instance, only its first port is
+// supported as multiport operation is not negotiated
+// (5.3 Console Device - Virtual I/O Device (VIRTIO) - Version 1.2).
+type Console struct {
+	sync.Mutex
+
+	// Device represents the VirtIO transport instance
+	Device VirtIO
+
+	rx *VirtualQueue
+	tx *VirtualQueue
+
+	// received data not yet read
+	pending []byte
+
+	// spinlock for transmission, as the console.Sink path cannot block on
+	// runtime.printk
+	txLock atomic.Bool
+	// characters buffered by Tx until end of line
+	line [consoleBufferSize]byte
+	n    int
+}
+
+// Init initializes a VirtIO console device instance.
+func (hw *Console) Init() (err error) {
+	if hw.Device == nil || hw.Device.DeviceID() != ConsoleDeviceID {
+		return errors.New("invalid VirtIO console device")
+	}
+
+	if err = hw.Device.Init(1 << Version1); err != nil {
+		return
+	}
+
+	hw.Lock()
+	defer hw.Unlock()
+
+	hw.rx = &VirtualQueue{}
+	hw.rx.Init(consoleQueueSize, consoleBufferSize, Write)
+
+	hw.tx = &VirtualQueue{}
+	hw.tx.Init(consoleQueueSize, consoleBufferSize, 0)
+
+	hw.Device.SetQueueSize(consoleRxQueue, consoleQueueSize)
+	hw.Device.SetQueue(consoleRxQueue, hw.rx)
+
+	hw.Device.SetQueueSize(consoleTxQueue, consoleQueueSize)
+	hw.Device.SetQueue(consoleTxQueue, hw.tx)
+
+	hw.Device.SetReady()
+	hw.Device.QueueNotify(consoleRxQueue)
+
+	return
+}
+
+// flush transmits characters buffered by Tx, it must be invoked with the
+// transmission lock held.
+func (hw *Console) flush() {
+	if hw.n == 0 {
+		return
+	}
+
+	hw.tx.Push(hw.line[:hw.n])
+	hw.Device.QueueNotify(consoleTxQueue)
+
+	hw.n = 0
+}
+
+// Tx buffers a single character, transmitted at end of line or when the
+// buffer is full, allowing the console to be used as console.Sink.
+//
+// The function never blocks, the character is discarded when transmission
+// is in progress on another path (e.g. [Console.Write]).
+func (hw *Console) Tx(c byte) {
+	if hw.tx == nil || !hw.txLock.CompareAndSwap(false, true) {
+		return
+	}
+
+	defer hw.txLock.Store(false)
+
+	hw.line[hw.n] = c
+	hw.n++
+
+	if c == '\n' || hw.n == len(hw.line) {
+		hw.flush()
+	}
+}
+
+// Write transmits data to the console port, after any character buffered by
+// Tx.
+func (hw *Console) Write(b []byte) (n int, err error) {
+	if hw.tx == nil {
+		return 0, errors.New("device not initialized")
+	}
+
+	for !hw.txLock.CompareAndSwap(false, true) {
+	}
+
+	defer hw.txLock.Store(false)
+
+	hw.flush()
+
+	for n < len(b) {
+		buf := b[n:min(n+consoleBufferSize, len(b))]
+
+		hw.tx.Push(buf)
+		hw.Device.QueueNotify(consoleTxQueue)
+
+		n += len(buf)
+	}
+
+	return
+}
+
+// Read reads available data received from the console port, the function
+// does not block.
+func (hw *Console) Read(b []byte) (n int, err error) {
+	hw.Lock()
+	defer hw.Unlock()
+
+	if hw.rx == nil {
+		return 0, errors.New("device not initialized")
+	}
+
+	for n < len(b) {
+		if len(hw.pending) == 0 {
+			if hw.pending = hw.rx.Pop(); len(hw.pending) == 0 {
+				break
+			}
+
+			hw.Device.QueueNotify(consoleRxQueue)
+		}
+
+		i := copy(b[n:], hw.pending)
+		hw.pending = hw.pending[i:]
+		n += i
+	}
+
+	return
+}
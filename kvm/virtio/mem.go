@@ -0,0 +1,287 @@
+// VirtIO driver
+// https://github.com/karlo195/tamago
+//
+// Copyright (c) The TamaGo Authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+package virtio
+
+import (
+	"encoding/binary"
+	"errors"
+	"sync"
+	"time"
+)
+
+// Memory device identifier
+const MemDeviceID = 24
+
+// Memory device feature bits
+// (5.15.3 Feature bits - Virtual I/O Device (VIRTIO) - Version 1.2).
+const (
+	MemACPIPXM               = 0
+	MemUnpluggedInaccessible = 1
+)
+
+// Memory device request types
+// (5.15.6.1 Driver Requests - Virtual I/O Device (VIRTIO) - Version 1.2).
+const (
+	MemRequestPlug      = 0
+	MemRequestUnplug    = 1
+	MemRequestUnplugAll = 2
+	MemRequestState     = 3
+)
+
+// Memory device response types
+const (
+	MemResponseACK   = 0
+	MemResponseNACK  = 1
+	MemResponseBusy  = 2
+	MemResponseError = 3
+)
+
+// Memory block states
+const (
+	MemPlugged   = 0
+	MemUnplugged = 1
+	MemMixed     = 2
+)
+
+const (
+	memConfigSize   = 56
+	memRequestSize  = 24
+	memResponseSize = 10
+
+	// nb_blocks is a 16-bit field
+	memMaxBlocks = 0xffff
+)
+
+// MemRequestTimeout is the default timeout for memory device requests.
+const MemRequestTimeout = 1 * time.Second
+
+// MemConfig represents a VirtIO memory device configuration layout
+// (5.15.4 Device configuration layout - Virtual I/O Device (VIRTIO) - Version 1.2).
+type MemConfig struct {
+	BlockSize        uint64
+	NodeID           uint16
+	_                [6]byte
+	Address          uint64
+	RegionSize       uint64
+	UsableRegionSize uint64
+	PluggedSize      uint64
+	RequestedSize    uint64
+}
+
+// Mem represents a VirtIO memory device instance, used by the host to add or
+// remove guest memory at runtime.
+//
+// Memory blocks are plugged contiguously from the start of the device memory
+// region and unplugged from its end, the plugged range can therefore be used
+// to back dedicated DMA regions (see dma.NewRegion()).
+type Mem struct {
+	sync.Mutex
+
+	// Device represents the VirtIO transport instance
+	Device VirtIO
+	// Timeout for device requests (default: [MemRequestTimeout])
+	Timeout time.Duration
+
+	// PlugHandler is invoked after memory blocks are plugged, the physical
+	// range might require mapping before use (e.g. amd64.CPU.Map()).
+	PlugHandler func(addr uint64, size uint64)
+	// UnplugHandler is invoked before memory blocks are unplugged, the
+	// physical range must no longer be in use when it returns, an error
+	// prevents the unplug request.
+	UnplugHandler func(addr uint64, size uint64) error
+
+	queue   *VirtualQueue
+	plugged uint64
+
+	// serializes device requests
+	req sync.Mutex
+}
+
+// Init initializes a VirtIO memory device instance, any memory left plugged
+// by a previous driver instance is unplugged.
+func (hw *Mem) Init() (err error) {
+	if hw.Device == nil || hw.Device.DeviceID() != MemDeviceID {
+		return errors.New("invalid VirtIO memory device")
+	}
+
+	if err = hw.Device.Init(1 << Version1); err != nil {
+		return
+	}
+
+	if hw.Timeout == 0 {
+		hw.Timeout = MemRequestTimeout
+	}
+
+	hw.queue = &VirtualQueue{}
+	hw.queue.InitRequest(memRequestSize)
+
+	hw.Device.SetQueueSize(0, 2)
+	hw.Device.SetQueue(0, hw.queue)
+	hw.Device.SetReady()
+
+	if hw.Config().PluggedSize == 0 {
+		return
+	}
+
+	return hw.UnplugAll()
+}
+
+// Config returns the device configuration layout.
+func (hw *Mem) Config() (config MemConfig) {
+	buf := hw.Device.Config(memConfigSize)
+	binary.Decode(buf, binary.LittleEndian, &config)
+	return
+}
+
+// Plugged returns the physical address and size of the memory currently
+// plugged by the driver.
+func (hw *Mem) Plugged() (addr uint64, size uint64) {
+	hw.Lock()
+	defer hw.Unlock()
+
+	return hw.Config().Address, hw.plugged
+}
+
+func (hw *Mem) request(t uint16, addr uint64, blocks uint16) (res []byte, err error) {
+	hw.req.Lock()
+	defer hw.req.Unlock()
+
+	req := make([]byte, memRequestSize)
+
+	binary.LittleEndian.PutUint16(req[0:], t)
+	binary.LittleEndian.PutUint64(req[8:], addr)
+	binary.LittleEndian.PutUint16(req[16:], blocks)
+
+	hw.queue.Request(req)
+	hw.Device.QueueNotify(0)
+
+	start := time.Now()
+
+	for {
+		var ok bool
+
+		if res, ok = hw.queue.Response(); ok {
+			break
+		}
+
+		if time.Since(start) >= hw.Timeout {
+			return nil, errors.New("request timeout")
+		}
+	}
+
+	if len(res) < memResponseSize {
+		return nil, errors.New("invalid response")
+	}
+
+	switch binary.LittleEndian.Uint16(res[0:]) {
+	case MemResponseACK:
+		return
+	case MemResponseNACK:
+		err = errors.New("request rejected")
+	case MemResponseBusy:
+		err = errors.New("device busy")
+	default:
+		err = errors.New("request error")
+	}
+
+	return nil, err
+}
+
+// Plug requests the device to plug the given number of memory blocks at the
+// given physical address.
+func (hw *Mem) Plug(addr uint64, blocks uint16) (err error) {
+	_, err = hw.request(MemRequestPlug, addr, blocks)
+	return
+}
+
+// Unplug requests the device to unplug the given number of memory blocks at
+// the given physical address.
+func (hw *Mem) Unplug(addr uint64, blocks uint16) (err error) {
+	_, err = hw.request(MemRequestUnplug, addr, blocks)
+	return
+}
+
+// UnplugAll requests the device to unplug all memory blocks.
+func (hw *Mem) UnplugAll() (err error) {
+	hw.Lock()
+	defer hw.Unlock()
+
+	if _, err = hw.request(MemRequestUnplugAll, 0, 0); err == nil {
+		hw.plugged = 0
+	}
+
+	return
+}
+
+// State returns the state (MemPlugged, MemUnplugged or MemMixed) of the given
+// number of memory blocks at the given physical address.
+func (hw *Mem) State(addr uint64, blocks uint16) (state int, err error) {
+	res, err := hw.request(MemRequestState, addr, blocks)
+
+	if err != nil {
+		return
+	}
+
+	return int(binary.LittleEndian.Uint16(res[8:])), nil
+}
+
+// Resize plugs or unplugs memory blocks to match the size requested by the
+// device, it should be invoked on device configuration change notifications.
+//
+// Plugged memory is reported through PlugHandler, memory to be unplugged is
+// reported through UnplugHandler.
+func (hw *Mem) Resize() (err error) {
+	hw.Lock()
+	defer hw.Unlock()
+
+	config := hw.Config()
+
+	if config.BlockSize == 0 {
+		return errors.New("invalid block size")
+	}
+
+	requested := min(config.RequestedSize, config.UsableRegionSize)
+	requested -= requested % config.BlockSize
+
+	for hw.plugged < requested {
+		blocks := min((requested-hw.plugged)/config.BlockSize, memMaxBlocks)
+		addr := config.Address + hw.plugged
+		size := blocks * config.BlockSize
+
+		if err = hw.Plug(addr, uint16(blocks)); err != nil {
+			return
+		}
+
+		hw.plugged += size
+
+		if hw.PlugHandler != nil {
+			hw.PlugHandler(addr, size)
+		}
+	}
+
+	for hw.plugged > requested {
+		blocks := min((hw.plugged-requested)/config.BlockSize, memMaxBlocks)
+		size := blocks * config.BlockSize
+		addr := config.Address + hw.plugged - size
+
+		if hw.UnplugHandler != nil {
+			if err = hw.UnplugHandler(addr, size); err != nil {
+				return
+			}
+		}
+
+		if err = hw.Unplug(addr, uint16(blocks)); err != nil {
+			return
+		}
+
+		hw.plugged -= size
+	}
+
+	return
+}
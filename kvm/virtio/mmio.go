@@ -10,10 +10,12 @@ package virtio
 
 import (
 	"errors"
+	"slices"
 
 	"github.com/karlo195/tamago/bits"
 	"github.com/karlo195/tamago/dma"
 	"github.com/karlo195/tamago/internal/reg"
+	"github.com/karlo195/tamago/replay"
 )
 
 // VirtIO MMIO Device Registers
@@ -37,6 +39,11 @@ const (
 	QueueDesc         = 0x080
 	QueueDriver       = 0x090
 	QueueDevice       = 0x0a0
+	SHMSel            = 0x0ac
+	SHMLenLow         = 0x0b0
+	SHMLenHigh        = 0x0b4
+	SHMBaseLow        = 0x0b8
+	SHMBaseHigh       = 0x0bc
 	ConfigGeneration  = 0x0fc
 	Config            = 0x100
 )
@@ -46,8 +53,15 @@ type MMIO struct {
 	// Base address
 	Base uint32
 
+	// Tracer is an optional tracer for interrupt status reads (see
+	// package replay).
+	Tracer replay.Tracer
+
 	features uint64
 
+	// registered queue indices
+	queues []int
+
 	// DMA buffer
 	config []byte
 }
@@ -77,12 +91,19 @@ func (io *MMIO) Init(features uint64) (err error) {
 
 	// reset
 	reg.Write(io.Base+Status, 0x0)
+	io.queues = nil
 
 	// initialize driver
 	reg.Set(io.Base+Status, Acknowledge)
 	reg.Set(io.Base+Status, Driver)
 
-	return io.negotiate(features)
+	if err = io.negotiate(features); err != nil {
+		return
+	}
+
+	register(io)
+
+	return
 }
 
 // Config returns the device configuration layout.
@@ -166,6 +187,10 @@ func (io *MMIO) SetQueueSize(index int, n int) {
 func (io *MMIO) InterruptStatus() (buffer bool, config bool) {
 	s := reg.Read(io.Base + InterruptStatus)
 
+	if io.Tracer != nil {
+		s = uint32(io.Tracer.Read("virtio-mmio", uint64(io.Base+InterruptStatus), uint64(s)))
+	}
+
 	buffer = bits.IsSet(&s, 0)
 	config = bits.IsSet(&s, 1)
 
@@ -186,6 +211,10 @@ func (io *MMIO) SetQueue(index int, queue *VirtualQueue) {
 	reg.Write(io.Base+QueueDriver, uint32(driver))
 	reg.Write(io.Base+QueueDevice, uint32(device))
 	reg.Write(io.Base+QueueReady, 1)
+
+	if !slices.Contains(io.queues, index) {
+		io.queues = append(io.queues, index)
+	}
 }
 
 // SetReady indicates that the driver is set up and ready to drive the device.
@@ -202,3 +231,39 @@ func (io *MMIO) QueueNotify(index int) {
 func (io *MMIO) ConfigVersion() uint32 {
 	return reg.Read(io.Base + ConfigGeneration)
 }
+
+// SharedMemory returns the physical address and size of the indexed shared
+// memory region.
+func (io *MMIO) SharedMemory(id int) (addr uint64, size uint64, err error) {
+	reg.Write(io.Base+SHMSel, uint32(id))
+
+	size = uint64(reg.Read(io.Base+SHMLenHigh))<<32 | uint64(reg.Read(io.Base+SHMLenLow))
+
+	// a length of all ones indicates a non-existent region
+	if size == 0xffffffffffffffff || size == 0 {
+		return 0, 0, errors.New("invalid shared memory region")
+	}
+
+	addr = uint64(reg.Read(io.Base+SHMBaseHigh))<<32 | uint64(reg.Read(io.Base+SHMBaseLow))
+
+	return
+}
+
+// Reset disables all queues and resets the device
+// (4.2.2.2 Driver Requirements: MMIO Device Register Layout).
+func (io *MMIO) Reset() {
+	for _, index := range io.queues {
+		reg.Write(io.Base+QueueSel, uint32(index))
+		reg.Write(io.Base+QueueReady, 0)
+
+		for i := 0; i < resetTimeout && reg.Read(io.Base+QueueReady) != 0; i++ {
+		}
+	}
+
+	io.queues = nil
+
+	reg.Write(io.Base+Status, 0x0)
+
+	for i := 0; i < resetTimeout && reg.Read(io.Base+Status) != 0; i++ {
+	}
+}
@@ -0,0 +1,41 @@
+// VirtIO driver
+// https://github.com/karlo195/tamago
+//
+// Copyright (c) The TamaGo Authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+package virtio
+
+import (
+	"errors"
+	"net"
+
+	"github.com/karlo195/tamago/bits"
+)
+
+// Network device feature bits
+// (5.1.3 Feature bits - Virtual I/O Device (VIRTIO) - Version 1.2).
+const (
+	NetMAC = 5
+)
+
+// Network device identifier
+const NetDeviceID = 1
+
+// MAC returns the Ethernet MAC address advertised in the configuration layout
+// of an initialized VirtIO network device.
+func MAC(dev VirtIO) (mac net.HardwareAddr, err error) {
+	features := dev.DeviceFeatures()
+
+	if dev.DeviceID() != NetDeviceID {
+		return nil, errors.New("invalid VirtIO network device")
+	}
+
+	if !bits.IsSet64(&features, NetMAC) {
+		return nil, errors.New("MAC address not available")
+	}
+
+	return net.HardwareAddr(dev.Config(6)), nil
+}
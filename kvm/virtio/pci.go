@@ -12,8 +12,7 @@ import (
 	"encoding/binary"
 	"errors"
 
-	"github.com/karlo195/tamago/amd64"
-	"github.com/karlo195/tamago/dma"
+	"github.com/karlo195/tamago/amd64/lapic"
 	"github.com/karlo195/tamago/internal/reg"
 	"github.com/karlo195/tamago/soc/intel/pci"
 )
@@ -38,61 +37,6 @@ const (
 	queueDevice      = 0x30
 )
 
-const capabilityLength = 16
-
-// VirtIO PCI Capabilities Configuration Types
-const (
-	capCommon = 1
-	capNotify = 2
-	capISR    = 3
-	capDevice = 4
-	capPCI    = 5
-	capShmem  = 8
-	capVendor = 9
-)
-
-// VirtIO PCI Capability
-type capability struct {
-	pci.CapabilityHeader
-
-	CapLength uint8
-	CfgType   uint8
-	Bar       uint8
-	ID        uint8
-	_         uint16
-	Offset    uint32
-	Length    uint32
-}
-
-func (c *capability) Unmarshal(d *pci.Device, off uint32) (desc []byte, addr uint, err error) {
-	buf := make([]byte, capabilityLength)
-
-	for i := uint32(0); i < capabilityLength; i += 4 {
-		binary.LittleEndian.PutUint32(buf[i:i+4], d.Read(0, off+i))
-	}
-
-	if _, err = binary.Decode(buf, binary.LittleEndian, c); err != nil {
-		return nil, 0, errors.New("invalid capability format")
-	}
-
-	addr = d.BaseAddress(int(c.Bar)) + uint(c.Offset)
-	size := int(c.Length)
-
-	if addr == 0 || size == 0 {
-		return
-	}
-
-	r, err := dma.NewRegion(addr, size, false)
-
-	if err != nil {
-		return nil, 0, errors.New("invalid capability region")
-	}
-
-	_, desc = r.Reserve(size, 0)
-
-	return
-}
-
 // PCI represents a VirtIO over PCI device.
 type PCI struct {
 	// Device represents the probed PCI device.
@@ -109,37 +53,52 @@ type PCI struct {
 	common []byte
 	config []byte
 
+	// shared memory regions
+	shm map[int]*shmRegion
+
 	msix *pci.CapabilityMSIX
 }
 
-func (io *PCI) addCapability(off uint32, hdr *pci.CapabilityHeader) error {
-	switch hdr.Vendor {
-	case pci.VendorSpecific:
-		c := &capability{}
+type shmRegion struct {
+	addr uint64
+	size uint64
+}
 
-		buf, addr, err := c.Unmarshal(io.Device, off)
+func (io *PCI) addCapability(entry pci.Capability) error {
+	switch entry := entry.(type) {
+	case *pci.CapabilityVirtIO:
+		switch entry.Type {
+		case pci.VIRTIO_PCI_CAP_COMMON_CFG:
+			_, buf, err := entry.Map()
 
-		if err != nil {
-			return err
-		}
+			if err != nil {
+				return err
+			}
 
-		switch c.CfgType {
-		case capCommon:
 			io.common = buf
-		case capNotify:
-			io.notifyAddress = uint64(addr)
-			io.notifyMultiplier = io.Device.Read(0, off+capabilityLength)
-		case capDevice:
-			io.config = buf
-		}
-	case pci.MSIX:
-		c := &pci.CapabilityMSIX{}
+		case pci.VIRTIO_PCI_CAP_NOTIFY_CFG:
+			io.notifyAddress = entry.Address()
+			io.notifyMultiplier = entry.NotifyOffMultiplier
+		case pci.VIRTIO_PCI_CAP_DEVICE_CFG:
+			_, buf, err := entry.Map()
 
-		if err := c.Unmarshal(io.Device, off); err != nil {
-			return err
-		}
+			if err != nil {
+				return err
+			}
 
-		io.msix = c
+			io.config = buf
+		case pci.VIRTIO_PCI_CAP_SHARED_MEMORY_CFG:
+			if io.shm == nil {
+				io.shm = make(map[int]*shmRegion)
+			}
+
+			io.shm[int(entry.ID)] = &shmRegion{
+				addr: entry.Address(),
+				size: entry.Size,
+			}
+		}
+	case *pci.CapabilityMSIX:
+		io.msix = entry
 	}
 
 	return nil
@@ -168,8 +127,8 @@ func (io *PCI) Init(features uint64) (err error) {
 		return errors.New("transitional devices are not supported")
 	}
 
-	for off, hdr := range io.Device.Capabilities() {
-		if err = io.addCapability(off, hdr); err != nil {
+	for _, entry := range io.Device.Capabilities() {
+		if err = io.addCapability(entry); err != nil {
 			return
 		}
 	}
@@ -185,7 +144,13 @@ func (io *PCI) Init(features uint64) (err error) {
 	io.common[deviceStatus] |= (1 << Acknowledge)
 	io.common[deviceStatus] |= (1 << Driver)
 
-	return io.negotiate(features)
+	if err = io.negotiate(features); err != nil {
+		return
+	}
+
+	register(io)
+
+	return
 }
 
 // Config returns the device configuration layout.
@@ -255,16 +220,17 @@ func (io *PCI) SetQueueSize(index int, n int) {
 	binary.LittleEndian.PutUint16(io.common[queueSize:], uint16(n))
 }
 
-// EnableInterrupt enables MSI-X interrupt vector routing to a LAPIC instance
-// for the indexed virtual queue.
+// EnableInterrupt enables MSI-X interrupt vector routing to the bootstrap
+// processor LAPIC for the indexed virtual queue.
+//
+// The interrupt vector can be obtained with [amd64.CPU.AllocateInterrupt].
 func (io *PCI) EnableInterrupt(id int, index int) (err error) {
 	if io.msix == nil {
 		return errors.New("missing required capabilities")
 	}
 
 	entry := 0
-	addr := uint64(amd64.LAPIC_BASE)
-	data := uint32(id)
+	addr, data := lapic.MSIMessage(0, id, lapic.ICR_DLV_IRQ)
 
 	if err = io.msix.EnableInterrupt(entry, addr, data); err != nil {
 		return
@@ -310,3 +276,29 @@ func (io *PCI) QueueNotify(index int) {
 func (io *PCI) ConfigVersion() uint32 {
 	return uint32(io.common[configGeneration])
 }
+
+// SharedMemory returns the physical address and size of the indexed shared
+// memory region.
+func (io *PCI) SharedMemory(id int) (addr uint64, size uint64, err error) {
+	shm, ok := io.shm[id]
+
+	if !ok || shm.size == 0 {
+		return 0, 0, errors.New("invalid shared memory region")
+	}
+
+	return shm.addr, shm.size, nil
+}
+
+// Reset resets the device, which also disables all its queues as drivers are
+// not allowed to disable them individually without queue reset negotiation
+// (4.1.4.3.2 Driver Requirements: Common configuration structure layout).
+func (io *PCI) Reset() {
+	if io.common == nil {
+		return
+	}
+
+	io.common[deviceStatus] = 0
+
+	for i := 0; i < resetTimeout && io.common[deviceStatus] != 0; i++ {
+	}
+}
@@ -14,6 +14,7 @@ import (
 	"sync"
 
 	"github.com/karlo195/tamago/dma"
+	"github.com/karlo195/tamago/replay"
 )
 
 // Descriptor Flags
@@ -200,6 +201,10 @@ func (d *Used) Ring(n uint16) Ring {
 type VirtualQueue struct {
 	sync.Mutex
 
+	// Tracer is an optional tracer for used ring index reads (see package
+	// replay).
+	Tracer replay.Tracer
+
 	Descriptors []*Descriptor
 	Available   Available
 	Used        Used
@@ -246,7 +251,7 @@ func (d *VirtualQueue) Init(size int, length int, flags uint16) {
 	_, buf := dma.Reserve(size*length, 0)
 
 	for i := 0; i < size; i++ {
-		off := size * i
+		off := length * i
 
 		desc := &Descriptor{}
 		desc.Init(buf[off:off+length], flags)
@@ -263,7 +268,11 @@ func (d *VirtualQueue) Init(size int, length int, flags uint16) {
 		d.Available.index = uint16(size)
 	}
 
-	// allocate DMA buffer
+	d.alloc(size)
+}
+
+// alloc allocates the virtual queue DMA buffer.
+func (d *VirtualQueue) alloc(size int) {
 	buf, driver, device := d.Bytes()
 	d.desc, d.buf = dma.Reserve(len(buf), 16)
 	copy(d.buf, buf)
@@ -278,6 +287,142 @@ func (d *VirtualQueue) Init(size int, length int, flags uint16) {
 	d.Used.buf = d.buf[device:]
 }
 
+// InitRequest initializes a split virtual queue holding a single descriptor
+// chain, made of a device-readable request buffer followed by a
+// device-writable response buffer, for devices processing one driver request
+// at a time (see [VirtualQueue.Request]).
+func (d *VirtualQueue) InitRequest(length int) {
+	d.Init(2, length, 0)
+
+	d.Lock()
+	defer d.Unlock()
+
+	d.setDescriptor(0, Next, 1)
+	d.setDescriptor(1, Write, 0)
+}
+
+// InitChain initializes a split virtual queue holding a single descriptor
+// chain, made of buffers of the argument lengths, for devices processing one
+// driver request at a time (see [VirtualQueue.RequestChain]).
+//
+// The queue size is the number of buffers rounded up to a power of 2.
+func (d *VirtualQueue) InitChain(lengths ...int) {
+	d.Lock()
+	defer d.Unlock()
+
+	size := 1
+
+	for size < len(lengths) {
+		size <<= 1
+	}
+
+	total := 0
+
+	for _, n := range lengths {
+		total += n
+	}
+
+	// To avoid excessive DMA region fragmentation a single allocation
+	// reserves all descriptor buffers.
+	_, buf := dma.Reserve(total, 0)
+
+	for i := 0; i < size; i++ {
+		desc := &Descriptor{}
+
+		if i < len(lengths) {
+			n := lengths[i]
+			desc.Init(buf[:n:n], 0)
+			buf = buf[n:]
+		}
+
+		d.Descriptors = append(d.Descriptors, desc)
+		d.Available.ring = append(d.Available.ring, uint16(i))
+		d.Used.ring = append(d.Used.ring, &Ring{})
+	}
+
+	d.alloc(size)
+}
+
+// Segment represents a descriptor chain buffer (see
+// [VirtualQueue.RequestChain]).
+type Segment struct {
+	// Buf holds device-readable contents, or receives device-writable
+	// ones up to its length.
+	Buf []byte
+	// Write marks the buffer as device-writable.
+	Write bool
+}
+
+// RequestChain supplies a request, made of the argument buffers, to a virtual
+// queue previously initialized with [VirtualQueue.InitChain], the device
+// response can be received with [VirtualQueue.ResponseChain] once the device
+// has been notified.
+func (d *VirtualQueue) RequestChain(segs []Segment) {
+	d.Lock()
+	defer d.Unlock()
+
+	for i, seg := range segs {
+		desc := d.Descriptors[i]
+		n := min(len(seg.Buf), len(desc.buf))
+
+		var flags uint16
+		var next uint16
+
+		if seg.Write {
+			flags |= Write
+			desc.length = uint32(n)
+		} else {
+			desc.Write(seg.Buf[:n])
+		}
+
+		if i < len(segs)-1 {
+			flags |= Next
+			next = uint16(i + 1)
+		}
+
+		binary.LittleEndian.PutUint32(d.buf[8+i*16:], desc.length)
+		d.setDescriptor(i, flags, next)
+	}
+
+	d.Available.SetRingIndex(d.Available.index%d.size, 0)
+	d.Available.SetIndex(d.Available.index + 1)
+}
+
+// ResponseChain receives the device response to the last request supplied
+// with [VirtualQueue.RequestChain], copying device-writable buffers to the
+// argument ones, the returned boolean is false if the request has not yet
+// been processed.
+func (d *VirtualQueue) ResponseChain(segs []Segment) (ok bool) {
+	d.Lock()
+	defer d.Unlock()
+
+	if d.usedIndex() == d.Used.last {
+		return
+	}
+
+	d.Used.Ring(d.Used.last % d.size)
+
+	for i, seg := range segs {
+		if seg.Write {
+			d.Descriptors[i].Read(seg.Buf)
+		}
+	}
+
+	d.Used.last += 1
+
+	return true
+}
+
+func (d *VirtualQueue) setDescriptor(index int, flags uint16, next uint16) {
+	off := 12 + index*16
+
+	binary.LittleEndian.PutUint16(d.buf[off:], flags)
+	binary.LittleEndian.PutUint16(d.buf[off+2:], next)
+
+	d.Descriptors[index].Flags = flags
+	d.Descriptors[index].Next = next
+}
+
 // Destroy removes a split virtual queue from physical memory.
 func (d *VirtualQueue) Destroy() {
 	for _, d := range d.Descriptors {
@@ -295,12 +440,24 @@ func (d *VirtualQueue) Address() (desc uint, driver uint, device uint) {
 	return d.desc, d.driver, d.device
 }
 
+// usedIndex returns the used ring index, passed through the queue tracer
+// when set.
+func (d *VirtualQueue) usedIndex() uint16 {
+	index := d.Used.Index()
+
+	if d.Tracer != nil {
+		index = d.Tracer.Queue("virtio-queue", uint64(d.desc), index)
+	}
+
+	return index
+}
+
 // Pop receives a single used buffer from the virtual queue,
 func (d *VirtualQueue) Pop() (buf []byte) {
 	d.Lock()
 	defer d.Unlock()
 
-	if d.Used.Index() == d.Used.last {
+	if d.usedIndex() == d.Used.last {
 		return
 	}
 
@@ -324,7 +481,7 @@ func (d *VirtualQueue) Push(buf []byte) {
 	defer d.Unlock()
 
 	index := d.Available.Ring(d.Available.index % d.size)
-	used := d.Used.Index() - d.Used.last
+	used := d.usedIndex() - d.Used.last
 
 	off := 8 + index*16
 	binary.LittleEndian.PutUint32(d.buf[off:], uint32(len(buf)))
@@ -341,3 +498,39 @@ func (d *VirtualQueue) Push(buf []byte) {
 
 	d.Used.last += used
 }
+
+// Request supplies a request buffer to a virtual queue previously initialized
+// with [VirtualQueue.InitRequest], the device response can be received with
+// [VirtualQueue.Response] once the device has been notified.
+func (d *VirtualQueue) Request(req []byte) {
+	d.Lock()
+	defer d.Unlock()
+
+	off := 8
+	binary.LittleEndian.PutUint32(d.buf[off:], uint32(len(req)))
+
+	d.Descriptors[0].Write(req)
+
+	d.Available.SetRingIndex(d.Available.index%d.size, 0)
+	d.Available.SetIndex(d.Available.index + 1)
+}
+
+// Response receives the device response to the last request supplied with
+// [VirtualQueue.Request], the returned boolean is false if the request has
+// not yet been processed.
+func (d *VirtualQueue) Response() (res []byte, ok bool) {
+	d.Lock()
+	defer d.Unlock()
+
+	if d.usedIndex() == d.Used.last {
+		return
+	}
+
+	used := d.Used.Ring(d.Used.last % d.size)
+	res = make([]byte, used.Length)
+
+	d.Descriptors[1].Read(res)
+	d.Used.last += 1
+
+	return res, true
+}
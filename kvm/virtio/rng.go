@@ -0,0 +1,90 @@
+// VirtIO driver
+// https://github.com/karlo195/tamago
+//
+// Copyright (c) The TamaGo Authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+package virtio
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// Entropy device identifier
+const RNGDeviceID = 4
+
+const (
+	rngQueueSize  = 4
+	rngBufferSize = 64
+)
+
+// RNGTimeout is the default timeout for entropy device requests.
+const RNGTimeout = 1 * time.Second
+
+// RNG represents a VirtIO entropy device instance
+// (5.4 Entropy Device - Virtual I/O Device (VIRTIO) - Version 1.2).
+type RNG struct {
+	sync.Mutex
+
+	// Device represents the VirtIO transport instance
+	Device VirtIO
+	// Timeout for device requests (default: [RNGTimeout])
+	Timeout time.Duration
+
+	queue *VirtualQueue
+}
+
+// Init initializes a VirtIO entropy device instance.
+func (hw *RNG) Init() (err error) {
+	if hw.Device == nil || hw.Device.DeviceID() != RNGDeviceID {
+		return errors.New("invalid VirtIO entropy device")
+	}
+
+	if err = hw.Device.Init(1 << Version1); err != nil {
+		return
+	}
+
+	if hw.Timeout == 0 {
+		hw.Timeout = RNGTimeout
+	}
+
+	hw.queue = &VirtualQueue{}
+	hw.queue.Init(rngQueueSize, rngBufferSize, Write)
+
+	hw.Device.SetQueueSize(0, rngQueueSize)
+	hw.Device.SetQueue(0, hw.queue)
+	hw.Device.SetReady()
+	hw.Device.QueueNotify(0)
+
+	return
+}
+
+// Read fills b with entropy gathered from the device.
+func (hw *RNG) Read(b []byte) (n int, err error) {
+	hw.Lock()
+	defer hw.Unlock()
+
+	if hw.queue == nil {
+		return 0, errors.New("device not initialized")
+	}
+
+	start := time.Now()
+
+	for n < len(b) {
+		if buf := hw.queue.Pop(); len(buf) > 0 {
+			n += copy(b[n:], buf)
+			hw.Device.QueueNotify(0)
+			continue
+		}
+
+		if time.Since(start) >= hw.Timeout {
+			return n, errors.New("request timeout")
+		}
+	}
+
+	return
+}
@@ -0,0 +1,37 @@
+// VirtIO driver
+// https://github.com/karlo195/tamago
+//
+// Copyright (c) The TamaGo Authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+package virtio
+
+import (
+	"github.com/karlo195/tamago/dma"
+)
+
+// MapSharedMemory returns the indexed shared memory region of a VirtIO device
+// (2.10 Shared Memory Regions - Virtual I/O Device (VIRTIO) - Version 1.2) as
+// a byte slice, allowing zero-copy access to host-shared mappings.
+//
+// On amd64 the region might require mapping before use (e.g.
+// amd64.CPU.MapMMIO()).
+func MapSharedMemory(dev VirtIO, id int) (buf []byte, err error) {
+	addr, size, err := dev.SharedMemory(id)
+
+	if err != nil {
+		return
+	}
+
+	r, err := dma.NewRegion(uint(addr), int(size), false)
+
+	if err != nil {
+		return
+	}
+
+	_, buf = r.Reserve(int(size), 0)
+
+	return
+}
@@ -16,11 +16,15 @@
 package virtio
 
 import (
+	"slices"
+	"sync"
+
 	"github.com/karlo195/tamago/bits"
 )
 
 // Reserved Feature bits
 const (
+	Version1         = 32
 	Packed           = 34
 	NotificationData = 38
 )
@@ -77,6 +81,42 @@ type VirtIO interface {
 	QueueNotify(index int)
 	// ConfigVersion returns the device configuration (see Config field) version.
 	ConfigVersion() uint32
+	// SharedMemory returns the physical address and size of the indexed
+	// shared memory region.
+	SharedMemory(id int) (addr uint64, size uint64, err error)
+	// Reset disables all queues and resets the device.
+	Reset()
+}
+
+// resetTimeout is the number of device status reads waiting for reset
+// completion.
+const resetTimeout = 1 << 20
+
+// initialized devices, reset on ResetAll()
+var devices struct {
+	sync.Mutex
+	list []VirtIO
+}
+
+func register(dev VirtIO) {
+	devices.Lock()
+	defer devices.Unlock()
+
+	if !slices.Contains(devices.list, dev) {
+		devices.list = append(devices.list, dev)
+	}
+}
+
+// ResetAll resets all initialized VirtIO devices, disabling their queues, it
+// is meant to be invoked on the runtime exit path (e.g. runtime.Exit) so that
+// the host sees a clean device shutdown and snapshots are consistent.
+func ResetAll() {
+	devices.Lock()
+	defer devices.Unlock()
+
+	for _, dev := range devices.list {
+		dev.Reset()
+	}
 }
 
 func negotiate(deviceFeatures, driverFeatures uint64) (features uint64) {
@@ -0,0 +1,659 @@
+// VirtIO driver
+// https://github.com/karlo195/tamago
+//
+// Copyright (c) The TamaGo Authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+package virtio
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"runtime"
+	"sync"
+	"time"
+)
+
+// Socket device identifier
+const VsockDeviceID = 19
+
+// Well-known context identifiers
+const (
+	VsockHostCID = 2
+)
+
+// Socket device packet operations
+// (5.10.6 Device Operation - Virtual I/O Device (VIRTIO) - Version 1.2).
+const (
+	VsockOpRequest       = 1
+	VsockOpResponse      = 2
+	VsockOpRST           = 3
+	VsockOpShutdown      = 4
+	VsockOpRW            = 5
+	VsockOpCreditUpdate  = 6
+	VsockOpCreditRequest = 7
+)
+
+// Socket device shutdown flags
+const (
+	VsockShutdownRx = 0
+	VsockShutdownTx = 1
+)
+
+// Socket device event identifiers
+const VsockEventTransportReset = 0
+
+const (
+	vsockConfigSize = 8
+	vsockHeaderSize = 44
+	vsockStream     = 1
+
+	vsockQueueSize  = 64
+	vsockPacketSize = vsockHeaderSize + 4096
+	vsockEventSize  = 4
+
+	// rx, tx, event
+	vsockRxQueue    = 0
+	vsockTxQueue    = 1
+	vsockEventQueue = 2
+
+	// first ephemeral port
+	vsockPortStart = 49152
+)
+
+// VsockBufferSize is the receive buffer size of each connection, advertised
+// to the peer for flow control.
+const VsockBufferSize = 256 * 1024
+
+// VsockTimeout is the default timeout for connection establishment.
+const VsockTimeout = 5 * time.Second
+
+// VsockAddr represents a VirtIO socket address.
+type VsockAddr struct {
+	// CID is the context identifier.
+	CID uint64
+	// Port is the port number.
+	Port uint32
+}
+
+// Network returns the address network name.
+func (a *VsockAddr) Network() string {
+	return "vsock"
+}
+
+// String returns the address string representation.
+func (a *VsockAddr) String() string {
+	return fmt.Sprintf("%d:%d", a.CID, a.Port)
+}
+
+type vsockHeader struct {
+	SrcCID   uint64
+	DstCID   uint64
+	SrcPort  uint32
+	DstPort  uint32
+	Len      uint32
+	Type     uint16
+	Op       uint16
+	Flags    uint32
+	BufAlloc uint32
+	FwdCnt   uint32
+}
+
+type vsockKey struct {
+	local  uint32
+	remote VsockAddr
+}
+
+// Vsock represents a VirtIO socket device instance
+// (5.10 Socket Device - Virtual I/O Device (VIRTIO) - Version 1.2),
+// supporting stream connections.
+//
+// The device is polled by connection and listener operations, no interrupt
+// handling is required.
+type Vsock struct {
+	sync.Mutex
+
+	// Device represents the VirtIO transport instance
+	Device VirtIO
+	// Timeout for connection establishment (default: [VsockTimeout])
+	Timeout time.Duration
+
+	cid   uint64
+	rx    *VirtualQueue
+	tx    *VirtualQueue
+	event *VirtualQueue
+
+	conns     map[vsockKey]*VsockConn
+	listeners map[uint32]*VsockListener
+	port      uint32
+}
+
+// Init initializes a VirtIO socket device instance.
+func (hw *Vsock) Init() (err error) {
+	if hw.Device == nil || hw.Device.DeviceID() != VsockDeviceID {
+		return errors.New("invalid VirtIO socket device")
+	}
+
+	if err = hw.Device.Init(1 << Version1); err != nil {
+		return
+	}
+
+	if hw.Timeout == 0 {
+		hw.Timeout = VsockTimeout
+	}
+
+	hw.Lock()
+	defer hw.Unlock()
+
+	hw.cid = binary.LittleEndian.Uint64(hw.Device.Config(vsockConfigSize))
+	hw.conns = make(map[vsockKey]*VsockConn)
+	hw.listeners = make(map[uint32]*VsockListener)
+	hw.port = vsockPortStart
+
+	hw.rx = &VirtualQueue{}
+	hw.rx.Init(vsockQueueSize, vsockPacketSize, Write)
+
+	hw.tx = &VirtualQueue{}
+	hw.tx.Init(vsockQueueSize, vsockPacketSize, 0)
+
+	hw.event = &VirtualQueue{}
+	hw.event.Init(vsockQueueSize, vsockEventSize, Write)
+
+	hw.Device.SetQueueSize(vsockRxQueue, vsockQueueSize)
+	hw.Device.SetQueue(vsockRxQueue, hw.rx)
+
+	hw.Device.SetQueueSize(vsockTxQueue, vsockQueueSize)
+	hw.Device.SetQueue(vsockTxQueue, hw.tx)
+
+	hw.Device.SetQueueSize(vsockEventQueue, vsockQueueSize)
+	hw.Device.SetQueue(vsockEventQueue, hw.event)
+
+	hw.Device.SetReady()
+
+	hw.Device.QueueNotify(vsockRxQueue)
+	hw.Device.QueueNotify(vsockEventQueue)
+
+	return
+}
+
+// CID returns the guest context identifier.
+func (hw *Vsock) CID() uint64 {
+	hw.Lock()
+	defer hw.Unlock()
+
+	return hw.cid
+}
+
+// send transmits a packet, it must be invoked with the lock held.
+func (hw *Vsock) send(c *VsockConn, op uint16, flags uint32, data []byte) {
+	hdr := vsockHeader{
+		SrcCID:   hw.cid,
+		DstCID:   c.remote.CID,
+		SrcPort:  c.local.Port,
+		DstPort:  c.remote.Port,
+		Len:      uint32(len(data)),
+		Type:     vsockStream,
+		Op:       op,
+		Flags:    flags,
+		BufAlloc: VsockBufferSize,
+		FwdCnt:   c.fwdCnt,
+	}
+
+	buf := make([]byte, vsockHeaderSize, vsockHeaderSize+len(data))
+	binary.Encode(buf, binary.LittleEndian, &hdr)
+	buf = append(buf, data...)
+
+	c.lastFwdCnt = c.fwdCnt
+
+	hw.tx.Push(buf)
+	hw.Device.QueueNotify(vsockTxQueue)
+}
+
+// reset transmits a reset packet in reply to the argument one, it must be
+// invoked with the lock held.
+func (hw *Vsock) reset(hdr *vsockHeader) {
+	if hdr.Op == VsockOpRST {
+		return
+	}
+
+	c := &VsockConn{
+		local:  VsockAddr{CID: hw.cid, Port: hdr.DstPort},
+		remote: VsockAddr{CID: hdr.SrcCID, Port: hdr.SrcPort},
+	}
+
+	hw.send(c, VsockOpRST, 0, nil)
+}
+
+// poll processes received packets and events, it must be invoked with the
+// lock held.
+func (hw *Vsock) poll() {
+	received := false
+
+	for buf := hw.event.Pop(); len(buf) > 0; buf = hw.event.Pop() {
+		if binary.LittleEndian.Uint32(buf) == VsockEventTransportReset {
+			hw.cid = binary.LittleEndian.Uint64(hw.Device.Config(vsockConfigSize))
+
+			for k, c := range hw.conns {
+				c.closed = true
+				c.reset = true
+				delete(hw.conns, k)
+			}
+		}
+
+		hw.Device.QueueNotify(vsockEventQueue)
+	}
+
+	for buf := hw.rx.Pop(); len(buf) > 0; buf = hw.rx.Pop() {
+		received = true
+		hw.receive(buf)
+	}
+
+	if received {
+		hw.Device.QueueNotify(vsockRxQueue)
+	}
+}
+
+// receive processes a single received packet, it must be invoked with the lock
+// held.
+func (hw *Vsock) receive(buf []byte) {
+	var hdr vsockHeader
+
+	if _, err := binary.Decode(buf, binary.LittleEndian, &hdr); err != nil {
+		return
+	}
+
+	if hdr.Type != vsockStream || hdr.DstCID != hw.cid {
+		hw.reset(&hdr)
+		return
+	}
+
+	data := buf[vsockHeaderSize:]
+
+	if int(hdr.Len) < len(data) {
+		data = data[:hdr.Len]
+	}
+
+	key := vsockKey{
+		local:  hdr.DstPort,
+		remote: VsockAddr{CID: hdr.SrcCID, Port: hdr.SrcPort},
+	}
+
+	c, ok := hw.conns[key]
+
+	if !ok {
+		l, ok := hw.listeners[hdr.DstPort]
+
+		if !ok || hdr.Op != VsockOpRequest || l.closed {
+			hw.reset(&hdr)
+			return
+		}
+
+		c = &VsockConn{
+			hw:        hw,
+			local:     VsockAddr{CID: hw.cid, Port: hdr.DstPort},
+			remote:    key.remote,
+			connected: true,
+		}
+
+		c.credit(&hdr)
+		hw.conns[key] = c
+		hw.send(c, VsockOpResponse, 0, nil)
+
+		l.pending = append(l.pending, c)
+
+		return
+	}
+
+	c.credit(&hdr)
+
+	switch hdr.Op {
+	case VsockOpResponse:
+		c.connected = true
+	case VsockOpRW:
+		if len(c.buf)+len(data) > VsockBufferSize {
+			// the peer exceeded its credit
+			hw.send(c, VsockOpRST, 0, nil)
+			c.close(true)
+			return
+		}
+
+		c.buf = append(c.buf, data...)
+	case VsockOpCreditRequest:
+		hw.send(c, VsockOpCreditUpdate, 0, nil)
+	case VsockOpShutdown:
+		if hdr.Flags&(1<<VsockShutdownTx) != 0 {
+			c.eof = true
+		}
+
+		if hdr.Flags&(1<<VsockShutdownRx) != 0 {
+			c.peerClosed = true
+		}
+
+		if c.eof && c.peerClosed {
+			hw.send(c, VsockOpRST, 0, nil)
+			c.close(false)
+		}
+	case VsockOpRST:
+		c.close(!c.connected)
+	}
+}
+
+// wait polls the device until the argument condition is met or the deadline
+// expires.
+func (hw *Vsock) wait(deadline time.Time, cond func() bool) (err error) {
+	for {
+		hw.Lock()
+		hw.poll()
+		done := cond()
+		hw.Unlock()
+
+		if done {
+			return
+		}
+
+		if !deadline.IsZero() && !time.Now().Before(deadline) {
+			return os.ErrDeadlineExceeded
+		}
+
+		runtime.Gosched()
+	}
+}
+
+// Dial connects to the argument context identifier (e.g. [VsockHostCID]) and
+// port.
+func (hw *Vsock) Dial(cid uint64, port uint32) (c *VsockConn, err error) {
+	hw.Lock()
+
+	if hw.conns == nil {
+		hw.Unlock()
+		return nil, errors.New("device not initialized")
+	}
+
+	c = &VsockConn{
+		hw:     hw,
+		remote: VsockAddr{CID: cid, Port: port},
+	}
+
+	for {
+		c.local = VsockAddr{CID: hw.cid, Port: hw.port}
+
+		if hw.port++; hw.port == 0 {
+			hw.port = vsockPortStart
+		}
+
+		if _, ok := hw.listeners[c.local.Port]; ok {
+			continue
+		}
+
+		if _, ok := hw.conns[vsockKey{c.local.Port, c.remote}]; !ok {
+			break
+		}
+	}
+
+	hw.conns[vsockKey{c.local.Port, c.remote}] = c
+	hw.send(c, VsockOpRequest, 0, nil)
+	hw.Unlock()
+
+	err = hw.wait(time.Now().Add(hw.Timeout), func() bool {
+		return c.connected || c.closed
+	})
+
+	hw.Lock()
+	defer hw.Unlock()
+
+	switch {
+	case err != nil:
+		hw.send(c, VsockOpRST, 0, nil)
+		c.close(true)
+		return nil, errors.New("connection timeout")
+	case !c.connected:
+		return nil, errors.New("connection refused")
+	}
+
+	return
+}
+
+// Listen announces on the argument local port.
+func (hw *Vsock) Listen(port uint32) (l *VsockListener, err error) {
+	hw.Lock()
+	defer hw.Unlock()
+
+	if hw.listeners == nil {
+		return nil, errors.New("device not initialized")
+	}
+
+	if _, ok := hw.listeners[port]; ok {
+		return nil, errors.New("address already in use")
+	}
+
+	l = &VsockListener{
+		hw:   hw,
+		addr: VsockAddr{CID: hw.cid, Port: port},
+	}
+
+	hw.listeners[port] = l
+
+	return
+}
+
+// VsockListener represents a VirtIO socket listener, it implements
+// net.Listener.
+type VsockListener struct {
+	hw      *Vsock
+	addr    VsockAddr
+	pending []*VsockConn
+	closed  bool
+}
+
+// Accept waits for and returns the next connection.
+func (l *VsockListener) Accept() (net.Conn, error) {
+	var c *VsockConn
+
+	l.hw.wait(time.Time{}, func() bool {
+		if len(l.pending) > 0 {
+			c = l.pending[0]
+			l.pending = l.pending[1:]
+		}
+
+		return c != nil || l.closed
+	})
+
+	if c == nil {
+		return nil, net.ErrClosed
+	}
+
+	return c, nil
+}
+
+// Close stops listening, pending connections are reset.
+func (l *VsockListener) Close() error {
+	l.hw.Lock()
+	defer l.hw.Unlock()
+
+	for _, c := range l.pending {
+		l.hw.send(c, VsockOpRST, 0, nil)
+		c.close(true)
+	}
+
+	l.pending = nil
+	l.closed = true
+
+	delete(l.hw.listeners, l.addr.Port)
+
+	return nil
+}
+
+// Addr returns the listener local address.
+func (l *VsockListener) Addr() net.Addr {
+	return &l.addr
+}
+
+// VsockConn represents a VirtIO socket stream connection, it implements
+// net.Conn.
+type VsockConn struct {
+	hw     *Vsock
+	local  VsockAddr
+	remote VsockAddr
+
+	connected bool
+	// local close or reset
+	closed bool
+	reset  bool
+	// peer will not send nor receive any further data
+	eof        bool
+	peerClosed bool
+
+	// receive buffer and consumed bytes count
+	buf        []byte
+	fwdCnt     uint32
+	lastFwdCnt uint32
+
+	// peer buffer and consumed bytes count
+	peerBufAlloc uint32
+	peerFwdCnt   uint32
+	txCnt        uint32
+
+	readDeadline  time.Time
+	writeDeadline time.Time
+}
+
+// credit updates the peer credit information, it must be invoked with the
+// lock held.
+func (c *VsockConn) credit(hdr *vsockHeader) {
+	c.peerBufAlloc = hdr.BufAlloc
+	c.peerFwdCnt = hdr.FwdCnt
+}
+
+// close marks the connection as closed, it must be invoked with the lock held.
+func (c *VsockConn) close(reset bool) {
+	c.closed = true
+	c.reset = c.reset || reset
+
+	delete(c.hw.conns, vsockKey{c.local.Port, c.remote})
+}
+
+// Read reads data received from the connection.
+func (c *VsockConn) Read(b []byte) (n int, err error) {
+	hw := c.hw
+
+	err = hw.wait(c.readDeadline, func() bool {
+		return len(c.buf) > 0 || c.closed || c.eof
+	})
+
+	if err != nil {
+		return
+	}
+
+	hw.Lock()
+	defer hw.Unlock()
+
+	if len(c.buf) == 0 {
+		if c.reset {
+			return 0, errors.New("connection reset")
+		}
+
+		return 0, io.EOF
+	}
+
+	n = copy(b, c.buf)
+	c.buf = c.buf[n:]
+	c.fwdCnt += uint32(n)
+
+	if !c.closed && c.fwdCnt-c.lastFwdCnt >= VsockBufferSize/2 {
+		hw.send(c, VsockOpCreditUpdate, 0, nil)
+	}
+
+	return
+}
+
+// Write transmits data over the connection, within the credit granted by the
+// peer.
+func (c *VsockConn) Write(b []byte) (n int, err error) {
+	hw := c.hw
+
+	for n < len(b) {
+		var free uint32
+
+		err = hw.wait(c.writeDeadline, func() bool {
+			if inflight := c.txCnt - c.peerFwdCnt; inflight < c.peerBufAlloc {
+				free = c.peerBufAlloc - inflight
+			}
+
+			return free > 0 || c.closed || c.peerClosed
+		})
+
+		if err != nil {
+			return
+		}
+
+		hw.Lock()
+
+		if c.closed || c.peerClosed {
+			hw.Unlock()
+			return n, net.ErrClosed
+		}
+
+		size := min(len(b)-n, int(free), vsockPacketSize-vsockHeaderSize)
+
+		hw.send(c, VsockOpRW, 0, b[n:n+size])
+		c.txCnt += uint32(size)
+		n += size
+
+		hw.Unlock()
+	}
+
+	return
+}
+
+// Close closes the connection.
+func (c *VsockConn) Close() error {
+	hw := c.hw
+
+	hw.Lock()
+	defer hw.Unlock()
+
+	if c.closed {
+		return nil
+	}
+
+	hw.send(c, VsockOpShutdown, 1<<VsockShutdownRx|1<<VsockShutdownTx, nil)
+	c.close(false)
+
+	return nil
+}
+
+// LocalAddr returns the local network address.
+func (c *VsockConn) LocalAddr() net.Addr {
+	return &c.local
+}
+
+// RemoteAddr returns the remote network address.
+func (c *VsockConn) RemoteAddr() net.Addr {
+	return &c.remote
+}
+
+// SetDeadline sets the read and write deadlines.
+func (c *VsockConn) SetDeadline(t time.Time) error {
+	c.readDeadline = t
+	c.writeDeadline = t
+
+	return nil
+}
+
+// SetReadDeadline sets the read deadline.
+func (c *VsockConn) SetReadDeadline(t time.Time) error {
+	c.readDeadline = t
+	return nil
+}
+
+// SetWriteDeadline sets the write deadline.
+func (c *VsockConn) SetWriteDeadline(t time.Time) error {
+	c.writeDeadline = t
+	return nil
+}
@@ -0,0 +1,277 @@
+// Virtual Machine Generation ID driver
+// https://github.com/karlo195/tamago
+//
+// Copyright (c) The TamaGo Authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+// Package vmgenid implements a driver for the Virtual Machine Generation ID
+// device, which allows detection of virtual machine snapshot restore and clone
+// events, following reference specifications:
+//   - Virtual Machine Generation ID - Microsoft - August 2012
+//
+// The generation ID location is discovered through ACPI tables, as exposed by
+// Firecracker, when the ADDR object is declared as a static package. QEMU
+// evaluates ADDR through an AML method, in which case the address must be
+// passed explicitly.
+//
+// This package is only meant to be used with `GOOS=tamago GOARCH=amd64` as
+// supported by the TamaGo framework for bare metal Go, see
+// https://github.com/karlo195/tamago.
+package vmgenid
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/karlo195/tamago/internal/mem"
+	"github.com/karlo195/tamago/soc/intel/acpi"
+)
+
+// Device compatible identifier
+const CompatibleID = "VM_Gen_Counter"
+
+// DefaultPollInterval is the default generation ID polling interval.
+const DefaultPollInterval = 100 * time.Millisecond
+
+// AML encoding (20.2 AML Grammar Definition)
+const (
+	amlZero        = 0x00
+	amlOne         = 0x01
+	amlNameOp      = 0x08
+	amlBytePrefix  = 0x0a
+	amlWordPrefix  = 0x0b
+	amlDWordPrefix = 0x0c
+	amlQWordPrefix = 0x0e
+	amlPackageOp   = 0x12
+)
+
+// Generation ID length
+const idLength = 16
+
+// VMGenID represents a Virtual Machine Generation ID device instance.
+type VMGenID struct {
+	sync.Mutex
+
+	// Address is the generation ID physical address, when not set it is
+	// located through the ACPI tables passed to Init().
+	Address uint
+	// PollInterval is the generation ID polling interval (default:
+	// DefaultPollInterval).
+	PollInterval time.Duration
+	// Handler is invoked when a generation ID change is detected, it
+	// should be used to reseed random number generators (e.g.
+	// entropy.Policy.Resume()), rotate nonces and network identifiers.
+	Handler func(id [16]byte)
+
+	buf  []byte
+	last [16]byte
+	stop chan struct{}
+}
+
+func parseInteger(buf []byte) (val uint64, n int, err error) {
+	if len(buf) < 1 {
+		return 0, 0, errors.New("invalid integer")
+	}
+
+	switch buf[0] {
+	case amlZero:
+		return 0, 1, nil
+	case amlOne:
+		return 1, 1, nil
+	case amlBytePrefix:
+		n = 2
+	case amlWordPrefix:
+		n = 3
+	case amlDWordPrefix:
+		n = 5
+	case amlQWordPrefix:
+		n = 9
+	default:
+		return 0, 0, errors.New("unsupported integer encoding")
+	}
+
+	if len(buf) < n {
+		return 0, 0, errors.New("invalid integer")
+	}
+
+	b := make([]byte, 8)
+	copy(b, buf[1:n])
+
+	return binary.LittleEndian.Uint64(b), n, nil
+}
+
+// parseAddress parses a `Name (ADDR, Package () { low, high })` AML object.
+func parseAddress(aml []byte) (addr uint, err error) {
+	// NameOp NameString PackageOp PkgLength NumElements
+	i := bytes.Index(aml, []byte{amlNameOp, 'A', 'D', 'D', 'R', amlPackageOp})
+
+	if i < 0 {
+		return 0, errors.New("ADDR package not found")
+	}
+
+	buf := aml[i+6:]
+
+	// skip PkgLength (20.2.4 Package Length Encoding)
+	if len(buf) < 1 || len(buf) < 1+int(buf[0]>>6) {
+		return 0, errors.New("invalid ADDR package")
+	}
+
+	buf = buf[1+int(buf[0]>>6):]
+
+	if len(buf) < 1 || buf[0] != 2 {
+		return 0, errors.New("invalid ADDR package")
+	}
+
+	low, n, err := parseInteger(buf[1:])
+
+	if err != nil {
+		return
+	}
+
+	if len(buf) < 1+n {
+		return 0, errors.New("invalid ADDR package")
+	}
+
+	high, _, err := parseInteger(buf[1+n:])
+
+	if err != nil {
+		return
+	}
+
+	return uint(high<<32 | low&0xffffffff), nil
+}
+
+// Find locates the generation ID physical address by searching the
+// Differentiated and Secondary System Description Tables for the device
+// declaration.
+func Find(a *acpi.ACPI) (addr uint, err error) {
+	for _, t := range a.Tables {
+		switch string(t.Signature[:]) {
+		case acpi.DSDT_SIGNATURE, acpi.SSDT_SIGNATURE:
+		default:
+			continue
+		}
+
+		i := bytes.Index(t.Data, []byte(CompatibleID))
+
+		if i < 0 {
+			continue
+		}
+
+		if addr, err = parseAddress(t.Data[i:]); err == nil {
+			return
+		}
+	}
+
+	return 0, errors.New("VM generation ID not found")
+}
+
+// Init initializes the Virtual Machine Generation ID device instance, the
+// argument ACPI tables are only required when Address is not set.
+func (hw *VMGenID) Init(a *acpi.ACPI) (err error) {
+	hw.Lock()
+	defer hw.Unlock()
+
+	if hw.Address == 0 {
+		if a == nil {
+			return errors.New("missing ACPI tables")
+		}
+
+		if hw.Address, err = Find(a); err != nil {
+			return
+		}
+	}
+
+	if hw.buf, err = mem.Read(hw.Address, idLength); err != nil {
+		return
+	}
+
+	copy(hw.last[:], hw.buf)
+
+	if hw.PollInterval == 0 {
+		hw.PollInterval = DefaultPollInterval
+	}
+
+	return
+}
+
+// ID returns the current generation ID.
+func (hw *VMGenID) ID() (id [16]byte) {
+	hw.Lock()
+	defer hw.Unlock()
+
+	copy(id[:], hw.buf)
+
+	return
+}
+
+// Changed returns whether the generation ID changed since the last call (or
+// initialization), along with its current value.
+func (hw *VMGenID) Changed() (changed bool, id [16]byte) {
+	hw.Lock()
+	defer hw.Unlock()
+
+	copy(id[:], hw.buf)
+	changed = id != hw.last
+	hw.last = id
+
+	return
+}
+
+// Start starts polling the generation ID, invoking Handler on each change.
+func (hw *VMGenID) Start() (err error) {
+	hw.Lock()
+	defer hw.Unlock()
+
+	if hw.buf == nil {
+		return errors.New("device not initialized")
+	}
+
+	if hw.Handler == nil {
+		return errors.New("missing handler")
+	}
+
+	if hw.stop != nil {
+		return errors.New("polling already started")
+	}
+
+	hw.stop = make(chan struct{})
+
+	go hw.poll(hw.stop)
+
+	return
+}
+
+// Stop stops generation ID polling.
+func (hw *VMGenID) Stop() {
+	hw.Lock()
+	defer hw.Unlock()
+
+	if hw.stop == nil {
+		return
+	}
+
+	close(hw.stop)
+	hw.stop = nil
+}
+
+func (hw *VMGenID) poll(stop chan struct{}) {
+	t := time.NewTicker(hw.PollInterval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-t.C:
+			if changed, id := hw.Changed(); changed {
+				hw.Handler(id)
+			}
+		}
+	}
+}
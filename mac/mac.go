@@ -0,0 +1,84 @@
+// Ethernet MAC address provisioning
+// https://github.com/karlo195/tamago
+//
+// Copyright (c) The TamaGo Authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+// Package mac provides provisioning of Ethernet MAC addresses from platform
+// specific sources (e.g. SoC fuses, firmware mailboxes, VirtIO device
+// configuration) with a deterministic fallback.
+//
+// This package is only meant to be used with `GOOS=tamago` as supported by the
+// TamaGo framework for bare metal Go, see https://github.com/karlo195/tamago.
+package mac
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"net"
+)
+
+// Source represents a MAC address provider, such as:
+//   - i.MX6UL/i.MX6ULL fuses (see imx6ul.MAC)
+//   - Raspberry Pi VideoCore mailbox (see bcm2835.MACAddress)
+//   - VirtIO network device configuration (see virtio.MAC)
+type Source func() (net.HardwareAddr, error)
+
+// Valid returns whether the argument is a valid unicast MAC address.
+func Valid(mac net.HardwareAddr) bool {
+	if len(mac) != 6 {
+		return false
+	}
+
+	// multicast (including broadcast)
+	if mac[0]&0x01 != 0 {
+		return false
+	}
+
+	for _, b := range mac {
+		if b != 0 {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Fallback returns a deterministic, locally administered, unicast MAC address
+// derived from the argument seed (e.g. a SoC unique ID) and interface index.
+func Fallback(seed []byte, index int) net.HardwareAddr {
+	buf := make([]byte, 4)
+	binary.LittleEndian.PutUint32(buf, uint32(index))
+
+	h := sha256.New()
+	h.Write(seed)
+	h.Write(buf)
+
+	mac := net.HardwareAddr(h.Sum(nil)[0:6])
+
+	// flag address as unicast and locally administered
+	mac[0] &= 0xfe
+	mac[0] |= 0x02
+
+	return mac
+}
+
+// Get returns the first valid MAC address retrieved from the argument
+// sources, evaluated in order. When no source returns a valid address a
+// fallback one is derived from the argument seed and interface index (see
+// [Fallback]).
+func Get(seed []byte, index int, sources ...Source) net.HardwareAddr {
+	for _, source := range sources {
+		if source == nil {
+			continue
+		}
+
+		if mac, err := source(); err == nil && Valid(mac) {
+			return mac
+		}
+	}
+
+	return Fallback(seed, index)
+}
@@ -0,0 +1,88 @@
+// Persistent monotonic counter support
+// https://github.com/karlo195/tamago
+//
+// Copyright (c) The TamaGo Authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+package monotonic
+
+import (
+	"errors"
+)
+
+// DefaultMaxIncrement is the default limit for a single hardware counter
+// advance.
+const DefaultMaxIncrement = 1024
+
+// HardwareCounter represents a hardware monotonic counter which can only be
+// incremented by one, matching the NXP SNVS driver (see soc/nxp/snvs) API.
+type HardwareCounter interface {
+	MonotonicCounter() (cnt uint64, err error)
+	IncrementMonotonicCounter() (err error)
+}
+
+// Hardware represents a counter store backed by a hardware monotonic counter.
+type Hardware struct {
+	// Counter is the hardware monotonic counter.
+	Counter HardwareCounter
+
+	// Offset is subtracted from the hardware counter value, to account
+	// for increments performed before the counter was assigned to this
+	// store.
+	Offset uint64
+
+	// MaxIncrement limits the increments performed on a single advance,
+	// protecting the counter from exhaustion by excessive versions, when
+	// zero DefaultMaxIncrement is used.
+	MaxIncrement uint64
+}
+
+// Read returns the counter value.
+func (hw *Hardware) Read() (val uint64, err error) {
+	if hw.Counter == nil {
+		return 0, errors.New("invalid hardware counter")
+	}
+
+	if val, err = hw.Counter.MonotonicCounter(); err != nil {
+		return
+	}
+
+	if val < hw.Offset {
+		return 0, errors.New("invalid hardware counter offset")
+	}
+
+	return val - hw.Offset, nil
+}
+
+// Advance increments the hardware counter up to the argument value.
+func (hw *Hardware) Advance(val uint64) (err error) {
+	cur, err := hw.Read()
+
+	if err != nil {
+		return
+	}
+
+	if val < cur {
+		return ErrRollback
+	}
+
+	limit := hw.MaxIncrement
+
+	if limit == 0 {
+		limit = DefaultMaxIncrement
+	}
+
+	if val-cur > limit {
+		return errors.New("increment exceeds limit")
+	}
+
+	for ; cur < val; cur++ {
+		if err = hw.Counter.IncrementMonotonicCounter(); err != nil {
+			return
+		}
+	}
+
+	return
+}
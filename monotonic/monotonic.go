@@ -0,0 +1,142 @@
+// Persistent monotonic counter support
+// https://github.com/karlo195/tamago
+//
+// Copyright (c) The TamaGo Authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+// Package monotonic implements persistent monotonic counters, for
+// anti-rollback protection of firmware updates or other versioned data, on
+// non-volatile storage which prevents them from being decreased.
+//
+// The following counter stores are supported:
+//   - hardware monotonic counters (e.g. NXP SNVS, see [Hardware])
+//   - eMMC Replay Protected Memory Block (see [RPMB] and the rpmb package)
+//
+// Other stores (e.g. TPM NV counter indices) can be used by implementing the
+// [Store] interface:
+//
+//	c := &monotonic.Counter{
+//		Store: &monotonic.Hardware{
+//			Counter: imx6ul.SNVS,
+//		},
+//	}
+//
+//	// refuse updates older than the current version
+//	if err := c.Check(update.Version); err != nil {
+//		return err
+//	}
+//
+//	// install update, then prevent rollback to previous versions
+//	if err := c.Commit(update.Version); err != nil {
+//		return err
+//	}
+//
+// This package is only meant to be used with `GOOS=tamago` as supported by the
+// TamaGo framework for bare metal Go, see https://github.com/karlo195/tamago.
+package monotonic
+
+import (
+	"errors"
+	"math"
+	"sync"
+)
+
+// ErrRollback is returned when a value lower than the counter is presented.
+var ErrRollback = errors.New("rollback detected")
+
+// Store represents a non-volatile counter which cannot be decreased.
+type Store interface {
+	// Read returns the counter value.
+	Read() (val uint64, err error)
+	// Advance increases the counter to the argument value, which must not
+	// be lower than the current one.
+	Advance(val uint64) (err error)
+}
+
+// Counter represents a persistent monotonic counter.
+type Counter struct {
+	sync.Mutex
+
+	// Store is the counter non-volatile storage.
+	Store Store
+}
+
+// Value returns the counter value.
+func (c *Counter) Value() (val uint64, err error) {
+	c.Lock()
+	defer c.Unlock()
+
+	if c.Store == nil {
+		return 0, errors.New("invalid counter store")
+	}
+
+	return c.Store.Read()
+}
+
+// Increment increases the counter by one and returns its new value.
+func (c *Counter) Increment() (val uint64, err error) {
+	c.Lock()
+	defer c.Unlock()
+
+	if c.Store == nil {
+		return 0, errors.New("invalid counter store")
+	}
+
+	if val, err = c.Store.Read(); err != nil {
+		return
+	}
+
+	if val == math.MaxUint64 {
+		return 0, errors.New("counter exhausted")
+	}
+
+	val++
+
+	return val, c.Store.Advance(val)
+}
+
+// Check returns [ErrRollback] if the argument version is lower than the
+// counter value.
+func (c *Counter) Check(version uint64) (err error) {
+	val, err := c.Value()
+
+	if err != nil {
+		return
+	}
+
+	if version < val {
+		return ErrRollback
+	}
+
+	return
+}
+
+// Commit advances the counter to the argument version, preventing any later
+// [Counter.Check] on lower versions to succeed. It returns [ErrRollback] if
+// the argument version is lower than the counter value and has no effect if
+// equal.
+func (c *Counter) Commit(version uint64) (err error) {
+	c.Lock()
+	defer c.Unlock()
+
+	if c.Store == nil {
+		return errors.New("invalid counter store")
+	}
+
+	val, err := c.Store.Read()
+
+	if err != nil {
+		return
+	}
+
+	switch {
+	case version < val:
+		return ErrRollback
+	case version == val:
+		return
+	}
+
+	return c.Store.Advance(version)
+}
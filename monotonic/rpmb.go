@@ -0,0 +1,63 @@
+// Persistent monotonic counter support
+// https://github.com/karlo195/tamago
+//
+// Copyright (c) The TamaGo Authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+package monotonic
+
+import (
+	"encoding/binary"
+	"errors"
+
+	"github.com/karlo195/tamago/rpmb"
+)
+
+// RPMB represents a counter store backed by an eMMC Replay Protected Memory
+// Block (RPMB) half sector.
+//
+// The RPMB prevents replay of older authenticated writes, therefore the
+// counter cannot be decreased without knowledge of the authentication key.
+type RPMB struct {
+	// Partition is the eMMC card RPMB partition, with its authentication
+	// key.
+	Partition *rpmb.RPMB
+	// Address is the half sector (256 bytes) address holding the counter.
+	Address uint16
+}
+
+// Read returns the counter value, authenticated with a random nonce to
+// prevent replay of earlier responses.
+func (r *RPMB) Read() (val uint64, err error) {
+	if r.Partition == nil {
+		return 0, errors.New("invalid RPMB partition")
+	}
+
+	buf := make([]byte, rpmb.DataSize)
+
+	if err = r.Partition.Read(r.Address, buf); err != nil {
+		return
+	}
+
+	return binary.BigEndian.Uint64(buf), nil
+}
+
+// Advance performs an authenticated write of the argument counter value.
+func (r *RPMB) Advance(val uint64) (err error) {
+	cur, err := r.Read()
+
+	if err != nil {
+		return
+	}
+
+	if val < cur {
+		return ErrRollback
+	}
+
+	buf := make([]byte, rpmb.DataSize)
+	binary.BigEndian.PutUint64(buf, val)
+
+	return r.Partition.Write(r.Address, buf)
+}
@@ -0,0 +1,429 @@
+// DHCP client
+// https://github.com/karlo195/tamago
+//
+// Copyright (c) The TamaGo Authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+// Package dhcp implements a minimal Dynamic Host Configuration Protocol
+// (DHCP) client, over a generic network interface controller (see nic.NIC),
+// adopting the following reference specifications:
+//   - RFC2131 - Dynamic Host Configuration Protocol
+//   - RFC2132 - DHCP Options and BOOTP Vendor Extensions
+//
+// This package is only meant to be used with `GOOS=tamago` as supported by the
+// TamaGo framework for bare metal Go, see https://github.com/karlo195/tamago.
+package dhcp
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"net"
+	"time"
+
+	"github.com/karlo195/tamago/nic"
+)
+
+// UDP ports
+const (
+	ServerPort = 67
+	ClientPort = 68
+)
+
+// Message types
+// (RFC2132 - 9.6. DHCP Message Type).
+const (
+	DHCPDISCOVER = 1
+	DHCPOFFER    = 2
+	DHCPREQUEST  = 3
+	DHCPACK      = 5
+	DHCPNAK      = 6
+	DHCPRELEASE  = 7
+)
+
+// Options
+// (RFC2132).
+const (
+	OptionPad           = 0
+	OptionSubnetMask    = 1
+	OptionRouter        = 3
+	OptionDNS           = 6
+	OptionHostname      = 12
+	OptionRequestedIP   = 50
+	OptionLeaseTime     = 51
+	OptionMessageType   = 53
+	OptionServerID      = 54
+	OptionParameterList = 55
+	OptionEnd           = 255
+)
+
+const (
+	// BOOTP fixed fields length, excluding options
+	headerLength  = 236
+	magicCookie   = 0x63825363
+	flagBroadcast = 0x8000
+
+	// maximum message length without negotiation (RFC2131 - 2.)
+	maxMessageLength = 576 - 28
+
+	defaultTimeout = 2 * time.Second
+	pollInterval   = 1 * time.Millisecond
+)
+
+// Lease represents a DHCP address lease.
+type Lease struct {
+	// IP is the leased address.
+	IP net.IP
+	// Mask is the subnet mask.
+	Mask net.IPMask
+	// Router is the default gateway address.
+	Router net.IP
+	// DNS is the list of name server addresses.
+	DNS []net.IP
+	// Server is the DHCP server identifier.
+	Server net.IP
+
+	// Duration is the lease duration.
+	Duration time.Duration
+	// Obtained is the lease acknowledgment time.
+	Obtained time.Time
+}
+
+// Expired returns whether the lease has expired.
+func (l *Lease) Expired() bool {
+	return time.Since(l.Obtained) >= l.Duration
+}
+
+// RenewAt returns the time at which the lease should be renewed (RFC2131 -
+// 4.4.5 T1).
+func (l *Lease) RenewAt() time.Time {
+	return l.Obtained.Add(l.Duration / 2)
+}
+
+// Client represents a DHCP client instance.
+//
+// The client receives frames directly from the network interface, which must
+// not be concurrently used by other receivers while a request is pending.
+type Client struct {
+	// NIC is the network interface controller.
+	NIC nic.NIC
+	// MAC is the interface hardware address.
+	MAC net.HardwareAddr
+	// Hostname is the optional client host name.
+	Hostname string
+
+	// Timeout is the reply timeout for each request attempt.
+	Timeout time.Duration
+	// Retries is the number of request retransmissions.
+	Retries int
+
+	xid uint32
+	// frame and message buffers, reused across requests
+	tx  [nic.UDPOverhead + maxMessageLength]byte
+	msg message
+}
+
+// message represents the parsed fields of a server reply.
+type message struct {
+	typ      uint8
+	yiaddr   [4]byte
+	server   [4]byte
+	mask     [4]byte
+	router   [4]byte
+	dns      [4][4]byte
+	numDNS   int
+	lease    uint32
+	hasMask  bool
+	hasRoute bool
+}
+
+func (c *Client) send(typ uint8, ciaddr [4]byte, requested [4]byte, server [4]byte) (err error) {
+	buf := c.tx[nic.UDPOverhead:]
+
+	for i := range buf[:headerLength] {
+		buf[i] = 0
+	}
+
+	buf[0] = 1 // BOOTREQUEST
+	buf[1] = 1 // Ethernet
+	buf[2] = 6 // hardware address length
+	binary.BigEndian.PutUint32(buf[4:], c.xid)
+	binary.BigEndian.PutUint16(buf[10:], flagBroadcast)
+	copy(buf[12:], ciaddr[:])
+	copy(buf[28:], c.MAC)
+	binary.BigEndian.PutUint32(buf[headerLength:], magicCookie)
+
+	opts := buf[headerLength+4 : headerLength+4]
+	opts = append(opts, OptionMessageType, 1, typ)
+
+	if requested != [4]byte{} {
+		opts = append(opts, OptionRequestedIP, 4)
+		opts = append(opts, requested[:]...)
+	}
+
+	if server != [4]byte{} {
+		opts = append(opts, OptionServerID, 4)
+		opts = append(opts, server[:]...)
+	}
+
+	if n := len(c.Hostname); n > 0 && n < 64 {
+		opts = append(opts, OptionHostname, byte(n))
+		opts = append(opts, c.Hostname...)
+	}
+
+	if typ != DHCPRELEASE {
+		opts = append(opts, OptionParameterList, 4, OptionSubnetMask, OptionRouter, OptionDNS, OptionLeaseTime)
+	}
+
+	opts = append(opts, OptionEnd)
+
+	udp := &nic.UDP{
+		DstMAC:  nic.Broadcast,
+		DstIP:   [4]byte{255, 255, 255, 255},
+		SrcPort: ClientPort,
+		DstPort: ServerPort,
+		Payload: buf[:headerLength+4+len(opts)],
+	}
+
+	copy(udp.SrcMAC[:], c.MAC)
+
+	if typ == DHCPRELEASE {
+		udp.SrcIP = ciaddr
+		udp.DstIP = server
+	}
+
+	frame, err := udp.Marshal(c.tx[:])
+
+	if err != nil {
+		return
+	}
+
+	c.NIC.Tx(frame)
+
+	return
+}
+
+// parse parses a server reply matching the current transaction.
+func (c *Client) parse(frame []byte) bool {
+	var udp nic.UDP
+
+	if udp.Unmarshal(frame) != nil || udp.SrcPort != ServerPort || udp.DstPort != ClientPort {
+		return false
+	}
+
+	buf := udp.Payload
+
+	if len(buf) < headerLength+4 || buf[0] != 2 ||
+		binary.BigEndian.Uint32(buf[4:]) != c.xid ||
+		binary.BigEndian.Uint32(buf[headerLength:]) != magicCookie {
+		return false
+	}
+
+	for i := range c.MAC {
+		if buf[28+i] != c.MAC[i] {
+			return false
+		}
+	}
+
+	m := &c.msg
+	*m = message{}
+
+	copy(m.yiaddr[:], buf[16:20])
+
+	for opts := buf[headerLength+4:]; len(opts) > 0; {
+		code := opts[0]
+
+		if code == OptionEnd {
+			break
+		}
+
+		if code == OptionPad {
+			opts = opts[1:]
+			continue
+		}
+
+		if len(opts) < 2 || len(opts) < 2+int(opts[1]) {
+			return false
+		}
+
+		val := opts[2 : 2+int(opts[1])]
+		opts = opts[2+len(val):]
+
+		switch {
+		case code == OptionMessageType && len(val) == 1:
+			m.typ = val[0]
+		case code == OptionServerID && len(val) == 4:
+			copy(m.server[:], val)
+		case code == OptionSubnetMask && len(val) == 4:
+			copy(m.mask[:], val)
+			m.hasMask = true
+		case code == OptionRouter && len(val) >= 4:
+			copy(m.router[:], val)
+			m.hasRoute = true
+		case code == OptionDNS && len(val) >= 4:
+			for ; len(val) >= 4 && m.numDNS < len(m.dns); val = val[4:] {
+				copy(m.dns[m.numDNS][:], val)
+				m.numDNS++
+			}
+		case code == OptionLeaseTime && len(val) == 4:
+			m.lease = binary.BigEndian.Uint32(val)
+		}
+	}
+
+	return m.typ != 0
+}
+
+// receive waits for a server reply of one of the argument types.
+func (c *Client) receive(types ...uint8) (err error) {
+	timeout := c.Timeout
+
+	if timeout == 0 {
+		timeout = defaultTimeout
+	}
+
+	deadline := time.Now().Add(timeout)
+
+	for time.Now().Before(deadline) {
+		frame := c.NIC.Rx()
+
+		if frame == nil {
+			time.Sleep(pollInterval)
+			continue
+		}
+
+		if !c.parse(frame) {
+			continue
+		}
+
+		for _, typ := range types {
+			if c.msg.typ == typ {
+				return
+			}
+		}
+	}
+
+	return errors.New("timeout")
+}
+
+func (c *Client) transaction() (err error) {
+	if c.NIC == nil || len(c.MAC) != 6 {
+		return errors.New("invalid DHCP client instance")
+	}
+
+	var xid [4]byte
+
+	if _, err = rand.Read(xid[:]); err != nil {
+		return
+	}
+
+	c.xid = binary.BigEndian.Uint32(xid[:])
+
+	return
+}
+
+func (c *Client) lease() (l *Lease, err error) {
+	m := &c.msg
+
+	if m.typ == DHCPNAK {
+		return nil, errors.New("request not acknowledged")
+	}
+
+	l = &Lease{
+		IP:       net.IPv4(m.yiaddr[0], m.yiaddr[1], m.yiaddr[2], m.yiaddr[3]).To4(),
+		Server:   net.IPv4(m.server[0], m.server[1], m.server[2], m.server[3]).To4(),
+		Duration: time.Duration(m.lease) * time.Second,
+		Obtained: time.Now(),
+	}
+
+	if m.hasMask {
+		l.Mask = net.IPv4Mask(m.mask[0], m.mask[1], m.mask[2], m.mask[3])
+	}
+
+	if m.hasRoute {
+		l.Router = net.IPv4(m.router[0], m.router[1], m.router[2], m.router[3]).To4()
+	}
+
+	for _, ip := range m.dns[:m.numDNS] {
+		l.DNS = append(l.DNS, net.IPv4(ip[0], ip[1], ip[2], ip[3]).To4())
+	}
+
+	return
+}
+
+// Request obtains an address lease through the DHCPDISCOVER, DHCPOFFER,
+// DHCPREQUEST and DHCPACK exchange (RFC2131 - 3.1).
+func (c *Client) Request() (l *Lease, err error) {
+	if err = c.transaction(); err != nil {
+		return
+	}
+
+	for i := 0; i <= c.Retries; i++ {
+		if err = c.send(DHCPDISCOVER, [4]byte{}, [4]byte{}, [4]byte{}); err != nil {
+			return
+		}
+
+		if err = c.receive(DHCPOFFER); err != nil {
+			continue
+		}
+
+		offered := c.msg.yiaddr
+		server := c.msg.server
+
+		if err = c.send(DHCPREQUEST, [4]byte{}, offered, server); err != nil {
+			return
+		}
+
+		if err = c.receive(DHCPACK, DHCPNAK); err != nil {
+			continue
+		}
+
+		return c.lease()
+	}
+
+	return
+}
+
+// Renew extends an address lease, the request is broadcast to allow any
+// server to extend it (RFC2131 - 4.4.5 REBINDING state).
+func (c *Client) Renew(l *Lease) (renewed *Lease, err error) {
+	var ciaddr [4]byte
+
+	if l == nil || copy(ciaddr[:], l.IP.To4()) != 4 {
+		return nil, errors.New("invalid lease")
+	}
+
+	if err = c.transaction(); err != nil {
+		return
+	}
+
+	for i := 0; i <= c.Retries; i++ {
+		if err = c.send(DHCPREQUEST, ciaddr, [4]byte{}, [4]byte{}); err != nil {
+			return
+		}
+
+		if err = c.receive(DHCPACK, DHCPNAK); err != nil {
+			continue
+		}
+
+		return c.lease()
+	}
+
+	return
+}
+
+// Release relinquishes an address lease (RFC2131 - 4.4.6).
+func (c *Client) Release(l *Lease) (err error) {
+	var ciaddr, server [4]byte
+
+	if l == nil || copy(ciaddr[:], l.IP.To4()) != 4 || copy(server[:], l.Server.To4()) != 4 {
+		return errors.New("invalid lease")
+	}
+
+	if err = c.transaction(); err != nil {
+		return
+	}
+
+	return c.send(DHCPRELEASE, ciaddr, [4]byte{}, server)
+}
@@ -0,0 +1,517 @@
+// Microchip ENC28J60 SPI Ethernet controller driver
+// https://github.com/karlo195/tamago
+//
+// Copyright (c) The TamaGo Authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+// Package enc28j60 implements a driver for Microchip ENC28J60 SPI Ethernet
+// controllers, exposing a generic network interface controller (see nic.NIC)
+// to give network connectivity to boards without native Ethernet support,
+// adopting the following reference specifications:
+//   - DS39662E - ENC28J60 Data Sheet
+//   - DS80349C - ENC28J60 Silicon Errata and Data Sheet Clarification
+//
+// The controller operates in half-duplex mode, with 10BASE-T link speed.
+//
+// This package is only meant to be used with `GOOS=tamago` as supported by the
+// TamaGo framework for bare metal Go, see https://github.com/karlo195/tamago.
+package enc28j60
+
+import (
+	"encoding/binary"
+	"errors"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/karlo195/tamago/spi"
+)
+
+// SPI instruction set (4.2 SPI Instruction Set, DS39662E)
+const (
+	RCR = 0x00
+	RBM = 0x3a
+	WCR = 0x40
+	WBM = 0x7a
+	BFS = 0x80
+	BFC = 0xa0
+	SRC = 0xff
+)
+
+// Control registers (3.1 Control Registers, DS39662E), encoded with their bank
+// number (bits 6:5) and whether a dummy byte precedes their value on reads
+// (bit 7, MAC and MII registers).
+const (
+	EIE   = 0x1b
+	EIR   = 0x1c
+	ESTAT = 0x1d
+	ECON2 = 0x1e
+	ECON1 = 0x1f
+
+	ERDPTL   = 0x00
+	ERDPTH   = 0x01
+	EWRPTL   = 0x02
+	EWRPTH   = 0x03
+	ETXSTL   = 0x04
+	ETXSTH   = 0x05
+	ETXNDL   = 0x06
+	ETXNDH   = 0x07
+	ERXSTL   = 0x08
+	ERXSTH   = 0x09
+	ERXNDL   = 0x0a
+	ERXNDH   = 0x0b
+	ERXRDPTL = 0x0c
+	ERXRDPTH = 0x0d
+
+	ERXFCON = 1<<5 | 0x18
+	EPKTCNT = 1<<5 | 0x19
+
+	MACON1   = 1<<7 | 2<<5 | 0x00
+	MACON3   = 1<<7 | 2<<5 | 0x02
+	MACON4   = 1<<7 | 2<<5 | 0x03
+	MABBIPG  = 1<<7 | 2<<5 | 0x04
+	MAIPGL   = 1<<7 | 2<<5 | 0x06
+	MAIPGH   = 1<<7 | 2<<5 | 0x07
+	MAMXFLL  = 1<<7 | 2<<5 | 0x0a
+	MAMXFLH  = 1<<7 | 2<<5 | 0x0b
+	MIREGADR = 1<<7 | 2<<5 | 0x14
+	MIWRL    = 1<<7 | 2<<5 | 0x16
+	MIWRH    = 1<<7 | 2<<5 | 0x17
+	MIRDL    = 1<<7 | 2<<5 | 0x18
+	MIRDH    = 1<<7 | 2<<5 | 0x19
+	MICMD    = 1<<7 | 2<<5 | 0x12
+
+	MAADR5 = 1<<7 | 3<<5 | 0x00
+	MAADR6 = 1<<7 | 3<<5 | 0x01
+	MAADR3 = 1<<7 | 3<<5 | 0x02
+	MAADR4 = 1<<7 | 3<<5 | 0x03
+	MAADR1 = 1<<7 | 3<<5 | 0x04
+	MAADR2 = 1<<7 | 3<<5 | 0x05
+	MISTAT = 1<<7 | 3<<5 | 0x0a
+	EREVID = 3<<5 | 0x12
+)
+
+// Register bits
+const (
+	EIR_TXERIF = 1
+	EIR_TXIF   = 3
+
+	ESTAT_CLKRDY = 0
+
+	ECON2_PKTDEC  = 6
+	ECON2_AUTOINC = 7
+
+	ECON1_BSEL  = 0
+	ECON1_RXEN  = 2
+	ECON1_TXRTS = 3
+	ECON1_RXRST = 6
+	ECON1_TXRST = 7
+
+	ERXFCON_UCEN  = 7
+	ERXFCON_CRCEN = 5
+	ERXFCON_BCEN  = 0
+
+	MACON1_MARXEN = 0
+
+	MACON3_PADCFG  = 5
+	MACON3_TXCRCEN = 4
+	MACON3_FRMLNEN = 1
+
+	MACON4_DEFER = 6
+
+	MICMD_MIIRD = 0
+
+	MISTAT_BUSY = 0
+)
+
+// PHY registers (3.3 PHY Registers, DS39662E)
+const (
+	PHCON1  = 0x00
+	PHSTAT2 = 0x11
+	PHCON2  = 0x10
+
+	PHSTAT2_LSTAT = 10
+	PHCON2_HDLDIS = 8
+)
+
+// Buffer memory layout, the receive buffer starts at address 0 (DS80349C,
+// issue 5) and its end must be odd to keep ERXRDPT odd (DS80349C, issue 14).
+const (
+	rxStart = 0x0000
+	rxEnd   = 0x19ff
+	txStart = 0x1a00
+
+	maxFrameSize = 1518
+	statusSize   = 6
+)
+
+// ENC28J60 represents an ENC28J60 Ethernet controller instance.
+type ENC28J60 struct {
+	sync.Mutex
+
+	// Bus is the SPI controller to which the ENC28J60 is connected.
+	Bus spi.Bus
+	// MAC is the interface hardware address.
+	MAC net.HardwareAddr
+
+	bank uint8
+	next uint16
+}
+
+func (hw *ENC28J60) op(op uint8, addr uint8, val uint8) (err error) {
+	return hw.Bus.Transfer([]byte{op | addr&0x1f, val}, nil)
+}
+
+func (hw *ENC28J60) selectBank(addr uint8) (err error) {
+	bank := (addr >> 5) & 0b11
+
+	// common registers are mapped in all banks
+	if addr&0x1f >= EIE || bank == hw.bank {
+		return
+	}
+
+	if err = hw.op(BFC, ECON1, 0b11<<ECON1_BSEL); err != nil {
+		return
+	}
+
+	if err = hw.op(BFS, ECON1, bank<<ECON1_BSEL); err != nil {
+		return
+	}
+
+	hw.bank = bank
+
+	return
+}
+
+func (hw *ENC28J60) read(addr uint8) (val uint8, err error) {
+	if err = hw.selectBank(addr); err != nil {
+		return
+	}
+
+	tx := []byte{RCR | addr&0x1f, 0}
+
+	// MAC and MII registers are preceded by a dummy byte
+	if addr&0x80 != 0 {
+		tx = append(tx, 0)
+	}
+
+	rx := make([]byte, len(tx))
+
+	if err = hw.Bus.Transfer(tx, rx); err != nil {
+		return
+	}
+
+	return rx[len(rx)-1], nil
+}
+
+func (hw *ENC28J60) write(addr uint8, val uint8) (err error) {
+	if err = hw.selectBank(addr); err != nil {
+		return
+	}
+
+	return hw.op(WCR, addr, val)
+}
+
+func (hw *ENC28J60) write16(addr uint8, val uint16) (err error) {
+	if err = hw.write(addr, uint8(val)); err != nil {
+		return
+	}
+
+	return hw.write(addr+1, uint8(val>>8))
+}
+
+func (hw *ENC28J60) set(addr uint8, mask uint8) (err error) {
+	if err = hw.selectBank(addr); err != nil {
+		return
+	}
+
+	return hw.op(BFS, addr, mask)
+}
+
+func (hw *ENC28J60) clear(addr uint8, mask uint8) (err error) {
+	if err = hw.selectBank(addr); err != nil {
+		return
+	}
+
+	return hw.op(BFC, addr, mask)
+}
+
+func (hw *ENC28J60) readBuffer(buf []byte) (err error) {
+	tx := make([]byte, 1+len(buf))
+	rx := make([]byte, len(tx))
+	tx[0] = RBM
+
+	if err = hw.Bus.Transfer(tx, rx); err != nil {
+		return
+	}
+
+	copy(buf, rx[1:])
+
+	return
+}
+
+func (hw *ENC28J60) writeBuffer(buf []byte) (err error) {
+	return hw.Bus.Transfer(append([]byte{WBM}, buf...), nil)
+}
+
+func (hw *ENC28J60) wait(addr uint8, pos int, val bool, timeout time.Duration) (err error) {
+	start := time.Now()
+
+	for {
+		reg, err := hw.read(addr)
+
+		if err != nil {
+			return err
+		}
+
+		if (reg&(1<<pos) != 0) == val {
+			return nil
+		}
+
+		if time.Since(start) > timeout {
+			return errors.New("timeout")
+		}
+	}
+}
+
+func (hw *ENC28J60) writePHY(addr uint8, val uint16) (err error) {
+	if err = hw.write(MIREGADR, addr); err != nil {
+		return
+	}
+
+	if err = hw.write(MIWRL, uint8(val)); err != nil {
+		return
+	}
+
+	if err = hw.write(MIWRH, uint8(val>>8)); err != nil {
+		return
+	}
+
+	return hw.wait(MISTAT, MISTAT_BUSY, false, time.Millisecond)
+}
+
+func (hw *ENC28J60) readPHY(addr uint8) (val uint16, err error) {
+	if err = hw.write(MIREGADR, addr); err != nil {
+		return
+	}
+
+	if err = hw.write(MICMD, 1<<MICMD_MIIRD); err != nil {
+		return
+	}
+
+	if err = hw.wait(MISTAT, MISTAT_BUSY, false, time.Millisecond); err != nil {
+		return
+	}
+
+	if err = hw.write(MICMD, 0); err != nil {
+		return
+	}
+
+	lo, err := hw.read(MIRDL)
+
+	if err != nil {
+		return
+	}
+
+	hi, err := hw.read(MIRDH)
+
+	return uint16(hi)<<8 | uint16(lo), err
+}
+
+// Init initializes the ENC28J60 controller
+// (6.0 Initialization, DS39662E).
+func (hw *ENC28J60) Init() (err error) {
+	hw.Lock()
+	defer hw.Unlock()
+
+	if hw.Bus == nil || len(hw.MAC) != 6 {
+		return errors.New("invalid ENC28J60 instance")
+	}
+
+	if err = hw.Bus.Transfer([]byte{SRC}, nil); err != nil {
+		return
+	}
+
+	// the oscillator start-up timer is not reliable after a system reset
+	// command (DS80349C, issue 2)
+	time.Sleep(1 * time.Millisecond)
+
+	hw.bank = 0
+
+	if err = hw.wait(ESTAT, ESTAT_CLKRDY, true, 100*time.Millisecond); err != nil {
+		return errors.New("oscillator start-up timeout")
+	}
+
+	if rev, err := hw.read(EREVID); err != nil {
+		return err
+	} else if rev == 0x00 || rev == 0xff {
+		return errors.New("no device detected")
+	}
+
+	// receive buffer
+	hw.next = rxStart
+
+	if err = hw.write16(ERXSTL, rxStart); err != nil {
+		return
+	}
+
+	if err = hw.write16(ERXNDL, rxEnd); err != nil {
+		return
+	}
+
+	if err = hw.write16(ERXRDPTL, rxEnd); err != nil {
+		return
+	}
+
+	// receive filters: unicast, broadcast and valid CRC
+	if err = hw.write(ERXFCON, 1<<ERXFCON_UCEN|1<<ERXFCON_CRCEN|1<<ERXFCON_BCEN); err != nil {
+		return
+	}
+
+	// MAC initialization (6.5 MAC Initialization Settings, DS39662E)
+	if err = hw.write(MACON1, 1<<MACON1_MARXEN); err != nil {
+		return
+	}
+
+	// pad to 60 bytes, append CRC, check frame length
+	if err = hw.write(MACON3, 0b001<<MACON3_PADCFG|1<<MACON3_TXCRCEN|1<<MACON3_FRMLNEN); err != nil {
+		return
+	}
+
+	if err = hw.write(MACON4, 1<<MACON4_DEFER); err != nil {
+		return
+	}
+
+	if err = hw.write16(MAMXFLL, maxFrameSize); err != nil {
+		return
+	}
+
+	// half-duplex inter-packet gaps
+	if err = hw.write(MABBIPG, 0x12); err != nil {
+		return
+	}
+
+	if err = hw.write16(MAIPGL, 0x0c12); err != nil {
+		return
+	}
+
+	for i, addr := range []uint8{MAADR1, MAADR2, MAADR3, MAADR4, MAADR5, MAADR6} {
+		if err = hw.write(addr, hw.MAC[i]); err != nil {
+			return
+		}
+	}
+
+	// disable half-duplex loopback
+	if err = hw.writePHY(PHCON2, 1<<PHCON2_HDLDIS); err != nil {
+		return
+	}
+
+	if err = hw.set(ECON2, 1<<ECON2_AUTOINC); err != nil {
+		return
+	}
+
+	return hw.set(ECON1, 1<<ECON1_RXEN)
+}
+
+// Link returns the PHY link status.
+func (hw *ENC28J60) Link() bool {
+	hw.Lock()
+	defer hw.Unlock()
+
+	val, err := hw.readPHY(PHSTAT2)
+
+	return err == nil && val&(1<<PHSTAT2_LSTAT) != 0
+}
+
+// Rx receives a single Ethernet frame, excluding the checksum, nil is returned
+// when no frame is available.
+func (hw *ENC28J60) Rx() []byte {
+	hw.Lock()
+	defer hw.Unlock()
+
+	if n, err := hw.read(EPKTCNT); err != nil || n == 0 {
+		return nil
+	}
+
+	if err := hw.write16(ERDPTL, hw.next); err != nil {
+		return nil
+	}
+
+	// next packet pointer and receive status vector
+	// (7.2 Receiving Packets, DS39662E)
+	status := make([]byte, statusSize)
+
+	if err := hw.readBuffer(status); err != nil {
+		return nil
+	}
+
+	next := binary.LittleEndian.Uint16(status[0:])
+	size := int(binary.LittleEndian.Uint16(status[2:]))
+	// Received Ok status bit (bit 23)
+	ok := status[4]&(1<<7) != 0
+
+	var buf []byte
+
+	if ok && size > 4 && size <= maxFrameSize {
+		buf = make([]byte, size-4)
+
+		if err := hw.readBuffer(buf); err != nil {
+			buf = nil
+		}
+	}
+
+	hw.next = next
+
+	// ERXRDPT must be odd (DS80349C, issue 14)
+	rdpt := uint16(rxEnd)
+
+	if next != rxStart {
+		rdpt = next - 1
+	}
+
+	hw.write16(ERXRDPTL, rdpt)
+	hw.set(ECON2, 1<<ECON2_PKTDEC)
+
+	return buf
+}
+
+// Tx transmits a single Ethernet frame, the checksum is appended by the
+// controller.
+func (hw *ENC28J60) Tx(buf []byte) {
+	hw.Lock()
+	defer hw.Unlock()
+
+	if len(buf) == 0 || len(buf) > maxFrameSize-4 {
+		return
+	}
+
+	if err := hw.wait(ECON1, ECON1_TXRTS, false, 100*time.Millisecond); err != nil {
+		return
+	}
+
+	// reset transmit logic (DS80349C, issue 12)
+	hw.set(ECON1, 1<<ECON1_TXRST)
+	hw.clear(ECON1, 1<<ECON1_TXRST)
+	hw.clear(EIR, 1<<EIR_TXERIF|1<<EIR_TXIF)
+
+	if err := hw.write16(EWRPTL, txStart); err != nil {
+		return
+	}
+
+	// per-packet control byte, use MACON3 settings
+	if err := hw.writeBuffer(append([]byte{0x00}, buf...)); err != nil {
+		return
+	}
+
+	if err := hw.write16(ETXSTL, txStart); err != nil {
+		return
+	}
+
+	if err := hw.write16(ETXNDL, txStart+uint16(len(buf))); err != nil {
+		return
+	}
+
+	hw.set(ECON1, 1<<ECON1_TXRTS)
+}
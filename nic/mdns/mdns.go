@@ -0,0 +1,307 @@
+// mDNS responder
+// https://github.com/karlo195/tamago
+//
+// Copyright (c) The TamaGo Authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+// Package mdns implements a minimal Multicast DNS (mDNS) responder, answering
+// host name address queries over a generic network interface controller (see
+// nic.NIC), adopting the following reference specifications:
+//   - RFC6762 - Multicast DNS
+//   - RFC1035 - Domain Names - Implementation and Specification
+//
+// This package is only meant to be used with `GOOS=tamago` as supported by the
+// TamaGo framework for bare metal Go, see https://github.com/karlo195/tamago.
+package mdns
+
+import (
+	"encoding/binary"
+	"errors"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/karlo195/tamago/nic"
+)
+
+// Port is the mDNS UDP port.
+const Port = 5353
+
+// Resource record types and classes
+const (
+	TypeA   = 1
+	TypeANY = 255
+
+	ClassIN = 1
+
+	// RFC6762 - 10.2. Announcements to Flush Outdated Cache Entries
+	classCacheFlush = 0x8000
+	// RFC6762 - 5.4. Questions Requesting Unicast Responses
+	classUnicast = 0x8000
+)
+
+const (
+	headerLength = 12
+	// authoritative response (RFC6762 - 18.2, 18.4)
+	flagsResponse = 0x8400
+
+	defaultTTL = 120
+
+	// maximum encoded host name length (RFC1035 - 2.3.4. Size limits)
+	maxNameLength = 255
+)
+
+// Multicast addresses (RFC6762 - 3.)
+var (
+	MulticastIP  = [4]byte{224, 0, 0, 251}
+	MulticastMAC = [6]byte{0x01, 0x00, 0x5e, 0x00, 0x00, 0xfb}
+)
+
+// Responder represents an mDNS responder instance.
+type Responder struct {
+	// NIC is the network interface controller.
+	NIC nic.NIC
+	// MAC is the interface hardware address.
+	MAC net.HardwareAddr
+	// IP is the interface IPv4 address.
+	IP net.IP
+
+	// Hostname is the host name, without the ".local" suffix.
+	Hostname string
+	// TTL is the answer record time-to-live (default 120 seconds).
+	TTL time.Duration
+
+	// encoded host name
+	name []byte
+	// frame buffer, reused across responses
+	tx [nic.UDPOverhead + 2*maxNameLength + headerLength + 18]byte
+}
+
+// Init initializes an mDNS responder instance.
+func (r *Responder) Init() (err error) {
+	if r.NIC == nil || len(r.MAC) != 6 || r.IP.To4() == nil {
+		return errors.New("invalid mDNS responder instance")
+	}
+
+	if r.TTL == 0 {
+		r.TTL = defaultTTL * time.Second
+	}
+
+	r.name = r.name[:0]
+
+	for _, label := range strings.Split(r.Hostname+".local", ".") {
+		if len(label) == 0 || len(label) > 63 {
+			return errors.New("invalid host name")
+		}
+
+		r.name = append(r.name, byte(len(label)))
+		r.name = append(r.name, label...)
+	}
+
+	r.name = append(r.name, 0)
+
+	if len(r.name) > maxNameLength {
+		return errors.New("invalid host name")
+	}
+
+	return
+}
+
+// skipName returns the offset following the name at the argument offset.
+func skipName(msg []byte, off int) int {
+	for off < len(msg) {
+		switch n := int(msg[off]); {
+		case n == 0:
+			return off + 1
+		case n&0xc0 == 0xc0:
+			// compression pointer terminates the name
+			return off + 2
+		default:
+			off += 1 + n
+		}
+	}
+
+	return -1
+}
+
+// matchName returns whether the name at the argument offset, with
+// compression pointers followed, matches the encoded argument name ignoring
+// ASCII case (RFC6762 - 16.).
+func matchName(msg []byte, off int, name []byte) bool {
+	// bound pointer loops
+	for hops := 0; hops < len(msg) && off < len(msg); {
+		n := int(msg[off])
+
+		if n&0xc0 == 0xc0 {
+			if off+1 >= len(msg) {
+				return false
+			}
+
+			off = int(binary.BigEndian.Uint16(msg[off:]) & 0x3fff)
+			hops++
+
+			continue
+		}
+
+		if len(name) == 0 || int(name[0]) != n || off+1+n > len(msg) {
+			return false
+		}
+
+		if n == 0 {
+			return true
+		}
+
+		for i := 1; i <= n; i++ {
+			if lower(msg[off+i]) != lower(name[i]) {
+				return false
+			}
+		}
+
+		off += 1 + n
+		name = name[1+n:]
+	}
+
+	return false
+}
+
+func lower(c byte) byte {
+	if c >= 'A' && c <= 'Z' {
+		return c + 'a' - 'A'
+	}
+
+	return c
+}
+
+// answer encodes the host address response, with the question echoed when
+// set (legacy unicast, see RFC6762 - 6.7.).
+func (r *Responder) answer(buf []byte, id uint16, question bool, class uint16) []byte {
+	msg := buf[:headerLength]
+
+	binary.BigEndian.PutUint16(msg[0:], id)
+	binary.BigEndian.PutUint16(msg[2:], flagsResponse)
+	binary.BigEndian.PutUint16(msg[4:], 0)
+	binary.BigEndian.PutUint16(msg[6:], 1)
+	binary.BigEndian.PutUint16(msg[8:], 0)
+	binary.BigEndian.PutUint16(msg[10:], 0)
+
+	if question {
+		binary.BigEndian.PutUint16(msg[4:], 1)
+		msg = append(msg, r.name...)
+		msg = binary.BigEndian.AppendUint16(msg, TypeA)
+		msg = binary.BigEndian.AppendUint16(msg, ClassIN)
+	}
+
+	msg = append(msg, r.name...)
+	msg = binary.BigEndian.AppendUint16(msg, TypeA)
+	msg = binary.BigEndian.AppendUint16(msg, class)
+	msg = binary.BigEndian.AppendUint32(msg, uint32(r.TTL/time.Second))
+	msg = binary.BigEndian.AppendUint16(msg, 4)
+	msg = append(msg, r.IP.To4()...)
+
+	return msg
+}
+
+func (r *Responder) send(udp *nic.UDP) {
+	copy(udp.SrcMAC[:], r.MAC)
+	copy(udp.SrcIP[:], r.IP.To4())
+	udp.SrcPort = Port
+
+	if frame, err := udp.Marshal(r.tx[:]); err == nil {
+		r.NIC.Tx(frame)
+	}
+}
+
+// Handle processes a received Ethernet frame, answering mDNS queries for the
+// host name address, it returns whether the frame was an mDNS query for it.
+//
+// The function is meant to be invoked on all frames received by the
+// application, it does not allocate memory.
+func (r *Responder) Handle(frame []byte) bool {
+	var udp nic.UDP
+
+	if len(r.name) == 0 || udp.Unmarshal(frame) != nil || udp.DstPort != Port {
+		return false
+	}
+
+	msg := udp.Payload
+
+	// only standard queries are answered (RFC6762 - 18.)
+	if len(msg) < headerLength || binary.BigEndian.Uint16(msg[2:])&0xf800 != 0 {
+		return false
+	}
+
+	id := binary.BigEndian.Uint16(msg[0:])
+	qd := int(binary.BigEndian.Uint16(msg[4:]))
+	off := headerLength
+
+	for i := 0; i < qd; i++ {
+		next := skipName(msg, off)
+
+		if next < 0 || next+4 > len(msg) {
+			return false
+		}
+
+		typ := binary.BigEndian.Uint16(msg[next:])
+		class := binary.BigEndian.Uint16(msg[next+2:])
+		match := (typ == TypeA || typ == TypeANY) && class&^classUnicast == ClassIN && matchName(msg, off, r.name)
+
+		off = next + 4
+
+		if !match {
+			continue
+		}
+
+		reply := &nic.UDP{
+			DstMAC:  MulticastMAC,
+			DstIP:   MulticastIP,
+			DstPort: Port,
+		}
+
+		if udp.SrcPort != Port {
+			// legacy unicast response (RFC6762 - 6.7.)
+			reply.DstMAC = udp.SrcMAC
+			reply.DstIP = udp.SrcIP
+			reply.DstPort = udp.SrcPort
+			reply.Payload = r.answer(r.tx[nic.UDPOverhead:], id, true, ClassIN)
+		} else {
+			if class&classUnicast != 0 {
+				// unicast response (RFC6762 - 5.4.)
+				reply.DstMAC = udp.SrcMAC
+				reply.DstIP = udp.SrcIP
+			}
+
+			reply.Payload = r.answer(r.tx[nic.UDPOverhead:], 0, false, ClassIN|classCacheFlush)
+		}
+
+		r.send(reply)
+
+		return true
+	}
+
+	return false
+}
+
+// Announce sends two unsolicited responses, one second apart, advertising
+// the host name address (RFC6762 - 8.3. Announcing).
+func (r *Responder) Announce() (err error) {
+	if len(r.name) == 0 {
+		return errors.New("mDNS responder not initialized")
+	}
+
+	for i := 0; i < 2; i++ {
+		if i > 0 {
+			time.Sleep(1 * time.Second)
+		}
+
+		r.send(&nic.UDP{
+			DstMAC:  MulticastMAC,
+			DstIP:   MulticastIP,
+			DstPort: Port,
+			Payload: r.answer(r.tx[nic.UDPOverhead:], 0, false, ClassIN|classCacheFlush),
+		})
+	}
+
+	return
+}
@@ -0,0 +1,190 @@
+// Network interface controller support
+// https://github.com/karlo195/tamago
+//
+// Copyright (c) The TamaGo Authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+// Package nic defines a generic network interface controller (NIC) interface,
+// for raw Ethernet frame transmission and reception, and the UDP over IPv4
+// encapsulation required by minimal protocol implementations over it (see
+// packages dhcp and mdns), without a full network stack dependency.
+//
+// This package is only meant to be used with `GOOS=tamago` as supported by the
+// TamaGo framework for bare metal Go, see https://github.com/karlo195/tamago.
+package nic
+
+import (
+	"encoding/binary"
+	"errors"
+
+	"github.com/karlo195/tamago/checksum"
+)
+
+// NIC represents a network interface controller (e.g. enet.ENET).
+type NIC interface {
+	// Rx receives a single Ethernet frame, excluding the checksum, nil is
+	// returned when no frame is available.
+	Rx() []byte
+	// Tx transmits a single Ethernet frame, the checksum is appended by
+	// the controller.
+	Tx(buf []byte)
+}
+
+// Header lengths
+const (
+	EthernetHeaderLength = 14
+	IPv4HeaderLength     = 20
+	UDPHeaderLength      = 8
+
+	// UDPOverhead is the length of all headers preceding an encapsulated
+	// UDP payload.
+	UDPOverhead = EthernetHeaderLength + IPv4HeaderLength + UDPHeaderLength
+)
+
+// Protocol numbers
+const (
+	EtherTypeIPv4 = 0x0800
+	ProtocolUDP   = 17
+)
+
+const defaultTTL = 64
+
+// errors are pre-allocated as parsing occurs for every received frame
+var (
+	errLength   = errors.New("invalid length")
+	errProtocol = errors.New("not an IPv4 UDP datagram")
+	errFragment = errors.New("fragmented datagram")
+)
+
+// Broadcast is the Ethernet broadcast address.
+var Broadcast = [6]byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff}
+
+// UDP represents a UDP datagram encapsulated in an IPv4 Ethernet frame.
+type UDP struct {
+	// Ethernet addresses
+	SrcMAC [6]byte
+	DstMAC [6]byte
+
+	// IPv4 addresses
+	SrcIP [4]byte
+	DstIP [4]byte
+
+	// UDP ports
+	SrcPort uint16
+	DstPort uint16
+
+	// Payload is the datagram payload, on Unmarshal() it references the
+	// parsed frame.
+	Payload []byte
+}
+
+// Unmarshal parses an Ethernet frame holding a non-fragmented IPv4 UDP
+// datagram, without allocating memory.
+func (u *UDP) Unmarshal(frame []byte) (err error) {
+	if len(frame) < UDPOverhead {
+		return errLength
+	}
+
+	if binary.BigEndian.Uint16(frame[12:]) != EtherTypeIPv4 {
+		return errProtocol
+	}
+
+	ip := frame[EthernetHeaderLength:]
+	ihl := int(ip[0]&0xf) * 4
+
+	if ip[0]>>4 != 4 || ip[9] != ProtocolUDP {
+		return errProtocol
+	}
+
+	// MF flag or fragment offset
+	if binary.BigEndian.Uint16(ip[6:])&0x3fff != 0 {
+		return errFragment
+	}
+
+	total := int(binary.BigEndian.Uint16(ip[2:]))
+
+	if ihl < IPv4HeaderLength || total > len(ip) || total < ihl+UDPHeaderLength {
+		return errLength
+	}
+
+	udp := ip[ihl:total]
+	n := int(binary.BigEndian.Uint16(udp[4:]))
+
+	if n < UDPHeaderLength || n > len(udp) {
+		return errLength
+	}
+
+	copy(u.DstMAC[:], frame[0:6])
+	copy(u.SrcMAC[:], frame[6:12])
+	copy(u.SrcIP[:], ip[12:16])
+	copy(u.DstIP[:], ip[16:20])
+
+	u.SrcPort = binary.BigEndian.Uint16(udp[0:])
+	u.DstPort = binary.BigEndian.Uint16(udp[2:])
+	u.Payload = udp[UDPHeaderLength:n]
+
+	return
+}
+
+// Marshal encodes the datagram as an Ethernet frame in the argument buffer,
+// which must be at least UDPOverhead+len(u.Payload) bytes long, and returns
+// the frame slice.
+//
+// To avoid copies the payload can be prepared in place, at the UDPOverhead
+// offset of the argument buffer.
+func (u *UDP) Marshal(buf []byte) (frame []byte, err error) {
+	n := UDPOverhead + len(u.Payload)
+
+	if len(buf) < n || n-EthernetHeaderLength > 0xffff {
+		return nil, errLength
+	}
+
+	frame = buf[:n]
+
+	copy(frame[0:], u.DstMAC[:])
+	copy(frame[6:], u.SrcMAC[:])
+	binary.BigEndian.PutUint16(frame[12:], EtherTypeIPv4)
+
+	ip := frame[EthernetHeaderLength:]
+
+	ip[0] = 4<<4 | IPv4HeaderLength/4
+	ip[1] = 0
+	binary.BigEndian.PutUint16(ip[2:], uint16(n-EthernetHeaderLength))
+	binary.BigEndian.PutUint16(ip[4:], 0)
+	binary.BigEndian.PutUint16(ip[6:], 0)
+	ip[8] = defaultTTL
+	ip[9] = ProtocolUDP
+	binary.BigEndian.PutUint16(ip[10:], 0)
+	copy(ip[12:], u.SrcIP[:])
+	copy(ip[16:], u.DstIP[:])
+	binary.BigEndian.PutUint16(ip[10:], checksum.Internet(ip[:IPv4HeaderLength]))
+
+	udp := ip[IPv4HeaderLength:]
+	length := uint16(UDPHeaderLength + len(u.Payload))
+
+	binary.BigEndian.PutUint16(udp[0:], u.SrcPort)
+	binary.BigEndian.PutUint16(udp[2:], u.DstPort)
+	binary.BigEndian.PutUint16(udp[4:], length)
+	binary.BigEndian.PutUint16(udp[6:], 0)
+	copy(udp[UDPHeaderLength:], u.Payload)
+
+	// RFC768 pseudo-header
+	var pseudo [12]byte
+
+	copy(pseudo[0:], u.SrcIP[:])
+	copy(pseudo[4:], u.DstIP[:])
+	pseudo[9] = ProtocolUDP
+	binary.BigEndian.PutUint16(pseudo[10:], length)
+
+	sum := ^checksum.Sum(udp[:length], checksum.Sum(pseudo[:], 0))
+
+	if sum == 0 {
+		sum = 0xffff
+	}
+
+	binary.BigEndian.PutUint16(udp[6:], sum)
+
+	return
+}
@@ -0,0 +1,272 @@
+// Serial Line Internet Protocol (SLIP) driver
+// https://github.com/karlo195/tamago
+//
+// Copyright (c) The TamaGo Authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+// Package slip implements a Serial Line Internet Protocol (SLIP) driver, over
+// any serial port (e.g. uart.UART), exposing a generic network interface
+// controller (see nic.NIC) to allow boards with only a serial link to join an
+// IP network (e.g. for management), adopting the following reference
+// specifications:
+//   - RFC1055 - A Nonstandard for Transmission of IP Datagrams over Serial Lines: SLIP
+//
+// As SLIP carries bare IPv4 datagrams, Ethernet framing is emulated towards
+// the network stack: received datagrams are encapsulated in frames addressed
+// from a virtual peer, transmitted frames are stripped of their Ethernet
+// header and ARP requests are answered locally, resolving every address to
+// the virtual peer (i.e. the serial link acts as default route).
+//
+// The Point-to-Point Protocol (PPP) is not supported, on the host side a SLIP
+// interface can be configured with slattach(8):
+//
+//	slattach -p slip -s 115200 /dev/ttyUSB0 &
+//	ip addr add 10.0.0.1 peer 10.0.0.2 dev sl0
+//	ip link set sl0 up
+//
+// This package is only meant to be used with `GOOS=tamago` as supported by the
+// TamaGo framework for bare metal Go, see https://github.com/karlo195/tamago.
+package slip
+
+import (
+	"encoding/binary"
+	"errors"
+	"net"
+	"sync"
+
+	"github.com/karlo195/tamago/nic"
+)
+
+// SLIP special characters (RFC1055).
+const (
+	END     = 0xc0
+	ESC     = 0xdb
+	ESC_END = 0xdc
+	ESC_ESC = 0xdd
+)
+
+// MTU is the default maximum datagram size (RFC1055).
+const MTU = 1006
+
+const (
+	etherTypeARP = 0x0806
+
+	arpLength     = 28
+	arpRequest    = 1
+	arpReply      = 2
+	maxPendingARP = 4
+)
+
+// DefaultPeerMAC is the default hardware address of the emulated peer.
+var DefaultPeerMAC = net.HardwareAddr{0x02, 0x00, 0x00, 0x00, 0x00, 0x01}
+
+// Port represents a serial port.
+type Port interface {
+	// Tx transmits a single character.
+	Tx(c byte)
+	// Rx receives a single character, if available.
+	Rx() (c byte, valid bool)
+}
+
+// SLIP represents a SLIP interface instance.
+type SLIP struct {
+	sync.Mutex
+
+	// Port is the serial port.
+	Port Port
+	// MAC is the local hardware address of the emulated Ethernet interface.
+	MAC net.HardwareAddr
+	// PeerMAC is the hardware address of the emulated peer, the
+	// DefaultPeerMAC is used when unset.
+	PeerMAC net.HardwareAddr
+	// MTU is the maximum datagram size, MTU is used when unset.
+	MTU int
+
+	// receive state
+	buf     []byte
+	escaped bool
+	dropped bool
+
+	// locally generated ARP replies
+	pending [][]byte
+
+	// serializes transmitted datagrams
+	tx sync.Mutex
+}
+
+// Init initializes the SLIP interface.
+func (hw *SLIP) Init() (err error) {
+	if hw.Port == nil || len(hw.MAC) != 6 {
+		return errors.New("invalid SLIP instance")
+	}
+
+	if len(hw.PeerMAC) == 0 {
+		hw.PeerMAC = DefaultPeerMAC
+	}
+
+	if len(hw.PeerMAC) != 6 {
+		return errors.New("invalid peer MAC")
+	}
+
+	if hw.MTU == 0 {
+		hw.MTU = MTU
+	}
+
+	hw.buf = hw.frame(nic.EtherTypeIPv4)
+
+	// flush any line noise on the peer side
+	hw.Port.Tx(END)
+
+	return
+}
+
+func (hw *SLIP) frame(etherType uint16) []byte {
+	buf := make([]byte, nic.EthernetHeaderLength, nic.EthernetHeaderLength+hw.MTU)
+
+	copy(buf[0:6], hw.MAC)
+	copy(buf[6:12], hw.PeerMAC)
+	binary.BigEndian.PutUint16(buf[12:], etherType)
+
+	return buf
+}
+
+// Rx receives a single Ethernet frame, encapsulating an IPv4 datagram
+// received over the serial port or a locally generated ARP reply, nil is
+// returned when no complete frame is available.
+//
+// The function does not block, partially received datagrams are retained
+// across invocations.
+func (hw *SLIP) Rx() []byte {
+	hw.Lock()
+	defer hw.Unlock()
+
+	if len(hw.pending) > 0 {
+		buf := hw.pending[0]
+		hw.pending = hw.pending[1:]
+		return buf
+	}
+
+	for {
+		c, valid := hw.Port.Rx()
+
+		if !valid {
+			return nil
+		}
+
+		switch {
+		case c == END:
+			buf := hw.buf
+			dropped := hw.dropped
+
+			hw.buf = hw.frame(nic.EtherTypeIPv4)
+			hw.escaped = false
+			hw.dropped = false
+
+			// discard empty and oversized datagrams
+			if len(buf) > nic.EthernetHeaderLength && !dropped {
+				return buf
+			}
+
+			continue
+		case c == ESC:
+			hw.escaped = true
+			continue
+		case hw.escaped && c == ESC_END:
+			c = END
+		case hw.escaped && c == ESC_ESC:
+			c = ESC
+		}
+
+		hw.escaped = false
+
+		if len(hw.buf)-nic.EthernetHeaderLength >= hw.MTU {
+			hw.dropped = true
+			continue
+		}
+
+		hw.buf = append(hw.buf, c)
+	}
+}
+
+// Tx transmits a single Ethernet frame, its IPv4 payload is sent over the
+// serial port while ARP requests are answered locally, any other protocol is
+// discarded.
+//
+// Concurrent invocations are serialized, to prevent interleaving of datagrams
+// on the serial port, independently from Rx.
+func (hw *SLIP) Tx(buf []byte) {
+	if len(buf) < nic.EthernetHeaderLength {
+		return
+	}
+
+	switch binary.BigEndian.Uint16(buf[12:]) {
+	case nic.EtherTypeIPv4:
+		hw.send(buf[nic.EthernetHeaderLength:])
+	case etherTypeARP:
+		hw.resolve(buf[nic.EthernetHeaderLength:])
+	}
+}
+
+func (hw *SLIP) send(ip []byte) {
+	if len(ip) > hw.MTU {
+		return
+	}
+
+	hw.tx.Lock()
+	defer hw.tx.Unlock()
+
+	hw.Port.Tx(END)
+
+	for _, c := range ip {
+		switch c {
+		case END:
+			hw.Port.Tx(ESC)
+			hw.Port.Tx(ESC_END)
+		case ESC:
+			hw.Port.Tx(ESC)
+			hw.Port.Tx(ESC_ESC)
+		default:
+			hw.Port.Tx(c)
+		}
+	}
+
+	hw.Port.Tx(END)
+}
+
+// resolve answers an IPv4 over Ethernet ARP request with the peer hardware
+// address (RFC826).
+func (hw *SLIP) resolve(arp []byte) {
+	if len(arp) < arpLength ||
+		binary.BigEndian.Uint16(arp[0:]) != 1 ||
+		binary.BigEndian.Uint16(arp[2:]) != nic.EtherTypeIPv4 ||
+		binary.BigEndian.Uint16(arp[6:]) != arpRequest {
+		return
+	}
+
+	// ignore address probes and announcements (RFC5227)
+	if binary.BigEndian.Uint32(arp[14:]) == 0 || binary.BigEndian.Uint32(arp[14:]) == binary.BigEndian.Uint32(arp[24:]) {
+		return
+	}
+
+	buf := hw.frame(etherTypeARP)
+	reply := make([]byte, arpLength)
+
+	copy(reply, arp[:8])
+	binary.BigEndian.PutUint16(reply[6:], arpReply)
+
+	// sender: peer hardware address, requested protocol address
+	copy(reply[8:14], hw.PeerMAC)
+	copy(reply[14:18], arp[24:28])
+	// target: requester addresses
+	copy(reply[18:24], arp[8:14])
+	copy(reply[24:28], arp[14:18])
+
+	hw.Lock()
+	defer hw.Unlock()
+
+	if len(hw.pending) < maxPendingARP {
+		hw.pending = append(hw.pending, append(buf, reply...))
+	}
+}
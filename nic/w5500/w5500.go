@@ -0,0 +1,372 @@
+// WIZnet W5500 SPI Ethernet controller driver
+// https://github.com/karlo195/tamago
+//
+// Copyright (c) The TamaGo Authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+// Package w5500 implements a driver for WIZnet W5500 SPI Ethernet
+// controllers, exposing a generic network interface controller (see nic.NIC)
+// to give network connectivity to boards without native Ethernet support,
+// adopting the following reference specifications:
+//   - W5500 Datasheet - Version 1.0.9
+//
+// The hardwired TCP/IP offload engine is not used, socket 0 is operated in
+// MACRAW mode with all internal buffer memory assigned to it so that raw
+// Ethernet frames are exchanged with the network stack.
+//
+// This package is only meant to be used with `GOOS=tamago` as supported by the
+// TamaGo framework for bare metal Go, see https://github.com/karlo195/tamago.
+package w5500
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/karlo195/tamago/spi"
+)
+
+// Common registers (4.1 Common Registers, W5500 Datasheet)
+const (
+	MR     = 0x0000
+	MR_RST = 7
+
+	SHAR = 0x0009
+
+	PHYCFGR     = 0x002e
+	PHYCFGR_LNK = 0
+
+	VERSIONR   = 0x0039
+	VERSION_ID = 0x04
+)
+
+// Socket registers (4.2 Socket Registers, W5500 Datasheet)
+const (
+	Sn_MR         = 0x0000
+	Sn_MR_MACRAW  = 0x04
+	Sn_MR_MFEN    = 7
+	Sn_CR         = 0x0001
+	Sn_CR_OPEN    = 0x01
+	Sn_CR_CLOSE   = 0x10
+	Sn_CR_SEND    = 0x20
+	Sn_CR_RECV    = 0x40
+	Sn_IR         = 0x0002
+	Sn_IR_SENDOK  = 4
+	Sn_SR         = 0x0003
+	SOCK_MACRAW   = 0x42
+	Sn_RXBUF_SIZE = 0x001e
+	Sn_TXBUF_SIZE = 0x001f
+	Sn_TX_FSR     = 0x0020
+	Sn_TX_WR      = 0x0024
+	Sn_RX_RSR     = 0x0026
+	Sn_RX_RD      = 0x0028
+)
+
+// SPI frame control phase (2.2.2 Control Phase, W5500 Datasheet)
+const (
+	bsbCommon = 0b00000
+	bsbSocket = 0b00001
+	bsbTx     = 0b00010
+	bsbRx     = 0b00011
+
+	ctrlBSB   = 3
+	ctrlWrite = 1 << 2
+)
+
+const (
+	// sockets is the number of hardware sockets.
+	sockets = 8
+	// bufferSize is the total buffer memory size in KB, for each direction.
+	bufferSize = 16
+	// maxFrameSize is the maximum Ethernet frame size, excluding the
+	// checksum.
+	maxFrameSize = 1514
+	// resetTimeout is the software reset timeout.
+	resetTimeout = 100 * time.Millisecond
+	// busyTimeout is the socket command and transmit buffer timeout.
+	busyTimeout = 10 * time.Millisecond
+)
+
+// W5500 represents a W5500 Ethernet controller instance.
+type W5500 struct {
+	sync.Mutex
+
+	// Bus is the SPI controller to which the W5500 is connected.
+	Bus spi.Bus
+	// MAC is the interface hardware address.
+	MAC net.HardwareAddr
+	// Promiscuous disables MAC address filtering on reception.
+	Promiscuous bool
+}
+
+func (hw *W5500) read(bsb int, addr uint16, buf []byte) (err error) {
+	tx := make([]byte, 3+len(buf))
+	rx := make([]byte, len(tx))
+
+	binary.BigEndian.PutUint16(tx, addr)
+	tx[2] = uint8(bsb << ctrlBSB)
+
+	if err = hw.Bus.Transfer(tx, rx); err != nil {
+		return
+	}
+
+	copy(buf, rx[3:])
+
+	return
+}
+
+func (hw *W5500) write(bsb int, addr uint16, buf []byte) (err error) {
+	tx := make([]byte, 3, 3+len(buf))
+
+	binary.BigEndian.PutUint16(tx, addr)
+	tx[2] = uint8(bsb<<ctrlBSB) | ctrlWrite
+	tx = append(tx, buf...)
+
+	return hw.Bus.Transfer(tx, nil)
+}
+
+func (hw *W5500) read8(bsb int, addr uint16) (val uint8, err error) {
+	buf := make([]byte, 1)
+	err = hw.read(bsb, addr, buf)
+	return buf[0], err
+}
+
+func (hw *W5500) write8(bsb int, addr uint16, val uint8) error {
+	return hw.write(bsb, addr, []byte{val})
+}
+
+// read16 reads a 16-bit socket register, which requires to be read until
+// stable as it might be updated by the controller during the transfer
+// (4.2 Socket Registers - Sn_RX_RSR, W5500 Datasheet).
+func (hw *W5500) read16(bsb int, addr uint16) (val uint16, err error) {
+	buf := make([]byte, 2)
+
+	for {
+		if err = hw.read(bsb, addr, buf); err != nil {
+			return
+		}
+
+		prev := val
+		val = binary.BigEndian.Uint16(buf)
+
+		if val == prev {
+			return
+		}
+	}
+}
+
+func (hw *W5500) write16(bsb int, addr uint16, val uint16) error {
+	return hw.write(bsb, addr, binary.BigEndian.AppendUint16(nil, val))
+}
+
+func (hw *W5500) command(cmd uint8) (err error) {
+	if err = hw.write8(bsbSocket, Sn_CR, cmd); err != nil {
+		return
+	}
+
+	start := time.Now()
+
+	// the register is cleared once the command is accepted
+	for {
+		val, err := hw.read8(bsbSocket, Sn_CR)
+
+		if err != nil || val == 0 {
+			return err
+		}
+
+		if time.Since(start) > busyTimeout {
+			return errors.New("command timeout")
+		}
+	}
+}
+
+// Init initializes the W5500 controller.
+func (hw *W5500) Init() (err error) {
+	hw.Lock()
+	defer hw.Unlock()
+
+	if hw.Bus == nil || len(hw.MAC) != 6 {
+		return errors.New("invalid W5500 instance")
+	}
+
+	if err = hw.write8(bsbCommon, MR, 1<<MR_RST); err != nil {
+		return
+	}
+
+	start := time.Now()
+
+	for {
+		val, err := hw.read8(bsbCommon, MR)
+
+		if err != nil {
+			return err
+		}
+
+		if val&(1<<MR_RST) == 0 {
+			break
+		}
+
+		if time.Since(start) > resetTimeout {
+			return errors.New("reset timeout")
+		}
+	}
+
+	if id, err := hw.read8(bsbCommon, VERSIONR); err != nil {
+		return err
+	} else if id != VERSION_ID {
+		return fmt.Errorf("unexpected version %#x", id)
+	}
+
+	if err = hw.write(bsbCommon, SHAR, hw.MAC); err != nil {
+		return
+	}
+
+	// assign all buffer memory to socket 0
+	for n := 0; n < sockets; n++ {
+		size := uint8(0)
+
+		if n == 0 {
+			size = bufferSize
+		}
+
+		bsb := n<<2 | bsbSocket
+
+		if err = hw.write8(bsb, Sn_RXBUF_SIZE, size); err != nil {
+			return
+		}
+
+		if err = hw.write8(bsb, Sn_TXBUF_SIZE, size); err != nil {
+			return
+		}
+	}
+
+	mode := uint8(Sn_MR_MACRAW)
+
+	if !hw.Promiscuous {
+		mode |= 1 << Sn_MR_MFEN
+	}
+
+	if err = hw.write8(bsbSocket, Sn_MR, mode); err != nil {
+		return
+	}
+
+	if err = hw.command(Sn_CR_OPEN); err != nil {
+		return
+	}
+
+	if sr, err := hw.read8(bsbSocket, Sn_SR); err != nil {
+		return err
+	} else if sr != SOCK_MACRAW {
+		return fmt.Errorf("unexpected socket status %#x", sr)
+	}
+
+	return
+}
+
+// Link returns the PHY link status.
+func (hw *W5500) Link() bool {
+	hw.Lock()
+	defer hw.Unlock()
+
+	val, err := hw.read8(bsbCommon, PHYCFGR)
+
+	return err == nil && val&(1<<PHYCFGR_LNK) != 0
+}
+
+// Rx receives a single Ethernet frame, excluding the checksum, nil is returned
+// when no frame is available.
+func (hw *W5500) Rx() []byte {
+	hw.Lock()
+	defer hw.Unlock()
+
+	size, err := hw.read16(bsbSocket, Sn_RX_RSR)
+
+	if err != nil || size < 2 {
+		return nil
+	}
+
+	ptr, err := hw.read16(bsbSocket, Sn_RX_RD)
+
+	if err != nil {
+		return nil
+	}
+
+	// each frame is preceded by its length, including the length field
+	// itself.
+	hdr := make([]byte, 2)
+
+	if err = hw.read(bsbRx, ptr, hdr); err != nil {
+		return nil
+	}
+
+	n := binary.BigEndian.Uint16(hdr)
+
+	if n < 2 || n > size {
+		// desynchronized, discard all received data
+		hw.write16(bsbSocket, Sn_RX_RD, ptr+size)
+		hw.command(Sn_CR_RECV)
+		return nil
+	}
+
+	buf := make([]byte, n-2)
+
+	if err = hw.read(bsbRx, ptr+2, buf); err != nil {
+		return nil
+	}
+
+	hw.write16(bsbSocket, Sn_RX_RD, ptr+n)
+	hw.command(Sn_CR_RECV)
+
+	if len(buf) > maxFrameSize {
+		return nil
+	}
+
+	return buf
+}
+
+// Tx transmits a single Ethernet frame, the checksum is appended by the
+// controller.
+func (hw *W5500) Tx(buf []byte) {
+	hw.Lock()
+	defer hw.Unlock()
+
+	if len(buf) == 0 || len(buf) > maxFrameSize {
+		return
+	}
+
+	start := time.Now()
+
+	// the frame is dropped if the transmit buffer does not drain in time
+	for {
+		free, err := hw.read16(bsbSocket, Sn_TX_FSR)
+
+		if err != nil || time.Since(start) > busyTimeout {
+			return
+		}
+
+		if int(free) >= len(buf) {
+			break
+		}
+	}
+
+	ptr, err := hw.read16(bsbSocket, Sn_TX_WR)
+
+	if err != nil {
+		return
+	}
+
+	if err = hw.write(bsbTx, ptr, buf); err != nil {
+		return
+	}
+
+	if err = hw.write16(bsbSocket, Sn_TX_WR, ptr+uint16(len(buf))); err != nil {
+		return
+	}
+
+	hw.command(Sn_CR_SEND)
+}
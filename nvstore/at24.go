@@ -0,0 +1,142 @@
+// Non-volatile key-value configuration store
+// https://github.com/karlo195/tamago
+//
+// Copyright (c) The TamaGo Authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+package nvstore
+
+import (
+	"errors"
+	"time"
+)
+
+// AT24 write cycle time (tWR)
+const AT24WriteCycle = 5 * time.Millisecond
+
+// I2CBus represents an I2C controller, matching the NXP I2C driver
+// (see soc/nxp/i2c) API.
+type I2CBus interface {
+	Read(target uint8, addr uint32, alen int, size int) (buf []byte, err error)
+	Write(buf []byte, target uint8, addr uint32, alen int) (err error)
+}
+
+// AT24 represents an AT24 compatible I2C serial EEPROM.
+type AT24 struct {
+	// Bus is the I2C controller to which the EEPROM is connected.
+	Bus I2CBus
+	// Target is the I2C target address (typically 0x50).
+	Target uint8
+	// AddressLength is the memory address length in bytes, devices up to
+	// 2 kB (e.g. AT24C02) use 1 byte and the upper address bits are
+	// encoded in the target address.
+	AddressLength int
+	// PageSize is the device write page size.
+	PageSize int
+	// Size is the device total size.
+	Size int
+	// WriteCycle is the time required to complete a page write, when zero
+	// AT24WriteCycle is used.
+	WriteCycle time.Duration
+}
+
+func (d *AT24) address(off int) (target uint8, addr uint32) {
+	target = d.Target
+	addr = uint32(off)
+
+	if d.AddressLength == 1 {
+		target |= uint8(off>>8) & 0b111
+		addr &= 0xff
+	}
+
+	return
+}
+
+func (d *AT24) check(size int, off int64) error {
+	if d.Bus == nil || d.PageSize == 0 || d.AddressLength == 0 {
+		return errors.New("invalid AT24 instance")
+	}
+
+	if off < 0 || int(off)+size > d.Size {
+		return errors.New("invalid offset")
+	}
+
+	return nil
+}
+
+// ReadAt reads len(p) bytes at the argument offset.
+func (d *AT24) ReadAt(p []byte, off int64) (n int, err error) {
+	if err = d.check(len(p), off); err != nil {
+		return
+	}
+
+	for n < len(p) {
+		pos := int(off) + n
+		size := len(p) - n
+
+		if d.AddressLength == 1 {
+			// stay within the 256 bytes block selected by the target
+			// address
+			size = min(size, 0x100-pos&0xff)
+		}
+
+		target, addr := d.address(pos)
+
+		buf, err := d.Bus.Read(target, addr, d.AddressLength, size)
+
+		if err != nil {
+			return n, err
+		}
+
+		n += copy(p[n:], buf)
+	}
+
+	return
+}
+
+// WriteAt writes len(p) bytes at the argument offset, splitting the operation
+// on page boundaries.
+func (d *AT24) WriteAt(p []byte, off int64) (n int, err error) {
+	if err = d.check(len(p), off); err != nil {
+		return
+	}
+
+	wr := d.WriteCycle
+
+	if wr == 0 {
+		wr = AT24WriteCycle
+	}
+
+	for n < len(p) {
+		pos := int(off) + n
+		size := min(len(p)-n, d.PageSize-pos%d.PageSize)
+
+		target, addr := d.address(pos)
+
+		if err = d.Bus.Write(p[n:n+size], target, addr, d.AddressLength); err != nil {
+			return
+		}
+
+		time.Sleep(wr)
+
+		n += size
+	}
+
+	return
+}
+
+// Erase sets to 0xff the argument region, EEPROMs do not require erasing
+// before writing and therefore this is only a convenience function.
+func (d *AT24) Erase(off int64, size int) (err error) {
+	buf := make([]byte, size)
+
+	for i := range buf {
+		buf[i] = erased
+	}
+
+	_, err = d.WriteAt(buf, off)
+
+	return
+}
@@ -0,0 +1,182 @@
+// Non-volatile key-value configuration store
+// https://github.com/karlo195/tamago
+//
+// Copyright (c) The TamaGo Authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+package nvstore
+
+import (
+	"errors"
+	"time"
+
+	"github.com/karlo195/tamago/spi"
+)
+
+// SPI NOR flash commands (JEDEC JESD216)
+const (
+	CMD_READ         = 0x03
+	CMD_PAGE_PROGRAM = 0x02
+	CMD_WRITE_ENABLE = 0x06
+	CMD_READ_STATUS  = 0x05
+	CMD_SECTOR_ERASE = 0x20
+
+	STATUS_WIP = 0
+)
+
+// Configuration constants
+const (
+	// SPINORPageSize is the default SPI NOR flash program page size.
+	SPINORPageSize = 256
+	// SPINORSectorSize is the default SPI NOR flash sector erase size.
+	SPINORSectorSize = 4096
+	// SPINORTimeout is the default timeout for program and erase
+	// operations.
+	SPINORTimeout = 1 * time.Second
+)
+
+// SPINOR represents a JEDEC compatible SPI NOR flash with 3 bytes addressing.
+type SPINOR struct {
+	// Bus is the SPI controller to which the flash is connected.
+	Bus spi.Bus
+	// Size is the device total size.
+	Size int
+	// PageSize is the program page size, when zero SPINORPageSize is used.
+	PageSize int
+	// SectorSize is the erase sector size, when zero SPINORSectorSize is
+	// used.
+	SectorSize int
+	// Timeout for program and erase operations, when zero SPINORTimeout
+	// is used.
+	Timeout time.Duration
+}
+
+func (d *SPINOR) init() error {
+	if d.Bus == nil || d.Size == 0 || d.Size > 1<<24 {
+		return errors.New("invalid SPI NOR instance")
+	}
+
+	if d.PageSize == 0 {
+		d.PageSize = SPINORPageSize
+	}
+
+	if d.SectorSize == 0 {
+		d.SectorSize = SPINORSectorSize
+	}
+
+	if d.Timeout == 0 {
+		d.Timeout = SPINORTimeout
+	}
+
+	return nil
+}
+
+func command(cmd byte, addr int, size int) []byte {
+	buf := make([]byte, 4+size)
+
+	buf[0] = cmd
+	buf[1] = byte(addr >> 16)
+	buf[2] = byte(addr >> 8)
+	buf[3] = byte(addr)
+
+	return buf
+}
+
+func (d *SPINOR) wait() (err error) {
+	rx := make([]byte, 2)
+	start := time.Now()
+
+	for time.Since(start) < d.Timeout {
+		if err = d.Bus.Transfer([]byte{CMD_READ_STATUS, 0}, rx); err != nil {
+			return
+		}
+
+		if rx[1]&(1<<STATUS_WIP) == 0 {
+			return
+		}
+	}
+
+	return errors.New("timeout waiting for write completion")
+}
+
+func (d *SPINOR) write(cmd byte, addr int, p []byte) (err error) {
+	if err = d.Bus.Transfer([]byte{CMD_WRITE_ENABLE}, nil); err != nil {
+		return
+	}
+
+	tx := command(cmd, addr, len(p))
+	copy(tx[4:], p)
+
+	if err = d.Bus.Transfer(tx, nil); err != nil {
+		return
+	}
+
+	return d.wait()
+}
+
+// ReadAt reads len(p) bytes at the argument offset.
+func (d *SPINOR) ReadAt(p []byte, off int64) (n int, err error) {
+	if err = d.init(); err != nil {
+		return
+	}
+
+	if off < 0 || int(off)+len(p) > d.Size {
+		return 0, errors.New("invalid offset")
+	}
+
+	tx := command(CMD_READ, int(off), len(p))
+	rx := make([]byte, len(tx))
+
+	if err = d.Bus.Transfer(tx, rx); err != nil {
+		return
+	}
+
+	return copy(p, rx[4:]), nil
+}
+
+// WriteAt programs len(p) bytes at the argument offset, splitting the
+// operation on page boundaries, the target region must have been previously
+// erased.
+func (d *SPINOR) WriteAt(p []byte, off int64) (n int, err error) {
+	if err = d.init(); err != nil {
+		return
+	}
+
+	if off < 0 || int(off)+len(p) > d.Size {
+		return 0, errors.New("invalid offset")
+	}
+
+	for n < len(p) {
+		pos := int(off) + n
+		size := min(len(p)-n, d.PageSize-pos%d.PageSize)
+
+		if err = d.write(CMD_PAGE_PROGRAM, pos, p[n:n+size]); err != nil {
+			return
+		}
+
+		n += size
+	}
+
+	return
+}
+
+// Erase erases the argument region, which must be aligned to the sector size.
+func (d *SPINOR) Erase(off int64, size int) (err error) {
+	if err = d.init(); err != nil {
+		return
+	}
+
+	if off < 0 || int(off)+size > d.Size || int(off)%d.SectorSize != 0 || size%d.SectorSize != 0 {
+		return errors.New("invalid erase region")
+	}
+
+	for pos := int(off); pos < int(off)+size; pos += d.SectorSize {
+		if err = d.write(CMD_SECTOR_ERASE, pos, nil); err != nil {
+			return
+		}
+	}
+
+	return
+}
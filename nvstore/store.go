@@ -0,0 +1,405 @@
+// Non-volatile key-value configuration store
+// https://github.com/karlo195/tamago
+//
+// Copyright (c) The TamaGo Authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+// Package nvstore implements a small key-value configuration store, for
+// persisting device settings (e.g. MAC addresses, keys, calibration data) on
+// non-volatile memories such as I2C EEPROMs or SPI NOR flash, on boards
+// without a filesystem.
+//
+// The store is log-structured to minimize wear: updates are appended to the
+// active area and, only once it is full, live entries are compacted to a
+// second area whose header is written last, to survive power loss at any
+// time.
+//
+// This package is only meant to be used with `GOOS=tamago` as supported by the
+// TamaGo framework for bare metal Go, see https://github.com/karlo195/tamago.
+package nvstore
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"sort"
+	"sync"
+)
+
+const (
+	// area header magic
+	magic = 0x5356564e // "NVVS"
+
+	areaHeaderSize   = 12
+	recordHeaderSize = 8
+
+	// erased memory value, used to detect the end of records
+	erased = 0xff
+
+	// record flags
+	flagDelete = 0x01
+
+	// MaxKeyLength is the maximum key length.
+	MaxKeyLength = 0xfe
+	// MaxValueLength is the maximum value length.
+	MaxValueLength = 0xfffe
+)
+
+// Device represents a non-volatile memory device.
+type Device interface {
+	// ReadAt reads len(p) bytes at the argument offset.
+	ReadAt(p []byte, off int64) (n int, err error)
+	// WriteAt writes len(p) bytes at the argument offset, on memories
+	// requiring it the target region must have been previously erased.
+	WriteAt(p []byte, off int64) (n int, err error)
+	// Erase sets to the erased value (0xff) the argument region, which must
+	// be aligned to the device erase size.
+	Erase(off int64, size int) error
+}
+
+// Store represents a key-value configuration store instance.
+type Store struct {
+	sync.Mutex
+
+	// Device represents the underlying non-volatile memory.
+	Device Device
+	// Offset is the start of the store within the device.
+	Offset int64
+	// Size is the total store size, split in two equally sized areas
+	// which must be a multiple of the device erase size.
+	Size int
+
+	// active area index
+	area int
+	// active area generation
+	generation uint32
+	// append offset within the active area
+	off int
+	// whether trailing data prevents further appends
+	dirty bool
+
+	entries map[string][]byte
+}
+
+type areaHeader struct {
+	Magic      uint32
+	Generation uint32
+	CRC        uint32
+}
+
+type recordHeader struct {
+	KeyLength   uint8
+	Flags       uint8
+	ValueLength uint16
+	CRC         uint32
+}
+
+func (s *Store) areaSize() int {
+	return s.Size / 2
+}
+
+func (s *Store) areaOffset(area int) int64 {
+	return s.Offset + int64(area*s.areaSize())
+}
+
+func (s *Store) readHeader(area int) (hdr *areaHeader, err error) {
+	buf := make([]byte, areaHeaderSize)
+
+	if _, err = s.Device.ReadAt(buf, s.areaOffset(area)); err != nil {
+		return
+	}
+
+	hdr = &areaHeader{}
+
+	if _, err = binary.Decode(buf, binary.LittleEndian, hdr); err != nil {
+		return nil, err
+	}
+
+	if hdr.Magic != magic || hdr.CRC != crc32.ChecksumIEEE(buf[0:8]) {
+		return nil, errors.New("invalid area header")
+	}
+
+	return
+}
+
+func (s *Store) writeHeader(area int, generation uint32) (err error) {
+	buf := make([]byte, areaHeaderSize)
+
+	binary.LittleEndian.PutUint32(buf[0:], magic)
+	binary.LittleEndian.PutUint32(buf[4:], generation)
+	binary.LittleEndian.PutUint32(buf[8:], crc32.ChecksumIEEE(buf[0:8]))
+
+	_, err = s.Device.WriteAt(buf, s.areaOffset(area))
+
+	return
+}
+
+func record(key string, val []byte, flags uint8) []byte {
+	buf := new(bytes.Buffer)
+
+	hdr := &recordHeader{
+		KeyLength:   uint8(len(key)),
+		Flags:       flags,
+		ValueLength: uint16(len(val)),
+	}
+
+	h := crc32.NewIEEE()
+	h.Write([]byte{hdr.KeyLength, hdr.Flags, byte(hdr.ValueLength), byte(hdr.ValueLength >> 8)})
+	h.Write([]byte(key))
+	h.Write(val)
+	hdr.CRC = h.Sum32()
+
+	binary.Write(buf, binary.LittleEndian, hdr)
+	buf.WriteString(key)
+	buf.Write(val)
+
+	return buf.Bytes()
+}
+
+// load replays the records of the argument area.
+func (s *Store) load(area int) (err error) {
+	s.entries = make(map[string][]byte)
+	s.off = areaHeaderSize
+	s.dirty = false
+
+	base := s.areaOffset(area)
+	hbuf := make([]byte, recordHeaderSize)
+
+	for s.off+recordHeaderSize <= s.areaSize() {
+		if _, err = s.Device.ReadAt(hbuf, base+int64(s.off)); err != nil {
+			return
+		}
+
+		hdr := &recordHeader{}
+		binary.Decode(hbuf, binary.LittleEndian, hdr)
+
+		if hdr.KeyLength == erased {
+			// end of records
+			return
+		}
+
+		size := recordHeaderSize + int(hdr.KeyLength) + int(hdr.ValueLength)
+
+		if s.off+size > s.areaSize() {
+			s.dirty = true
+			return
+		}
+
+		data := make([]byte, int(hdr.KeyLength)+int(hdr.ValueLength))
+
+		if _, err = s.Device.ReadAt(data, base+int64(s.off+recordHeaderSize)); err != nil {
+			return
+		}
+
+		key := string(data[0:hdr.KeyLength])
+		val := data[hdr.KeyLength:]
+
+		if !bytes.Equal(record(key, val, hdr.Flags), append(hbuf, data...)) {
+			// interrupted write, further appends require compaction
+			s.dirty = true
+			return
+		}
+
+		if hdr.Flags&flagDelete != 0 {
+			delete(s.entries, key)
+		} else {
+			s.entries[key] = val
+		}
+
+		s.off += size
+	}
+
+	return
+}
+
+// Init initializes the store, loading the most recent valid area or
+// formatting the store if none is found.
+func (s *Store) Init() (err error) {
+	s.Lock()
+	defer s.Unlock()
+
+	if s.Device == nil || s.Size < 2*(areaHeaderSize+recordHeaderSize) {
+		return errors.New("invalid store instance")
+	}
+
+	s.area = -1
+
+	for area := 0; area < 2; area++ {
+		hdr, err := s.readHeader(area)
+
+		if err != nil {
+			continue
+		}
+
+		if s.area < 0 || hdr.Generation > s.generation {
+			s.area = area
+			s.generation = hdr.Generation
+		}
+	}
+
+	if s.area < 0 {
+		s.entries = make(map[string][]byte)
+		s.generation = 0
+		s.area = 1
+		return s.compact()
+	}
+
+	return s.load(s.area)
+}
+
+// compact writes all live entries to the inactive area, which then becomes
+// the active one. The area header is written last to commit the operation.
+func (s *Store) compact() (err error) {
+	area := (s.area + 1) % 2
+	base := s.areaOffset(area)
+
+	if err = s.Device.Erase(base, s.areaSize()); err != nil {
+		return
+	}
+
+	keys := make([]string, 0, len(s.entries))
+
+	for key := range s.entries {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	off := areaHeaderSize
+
+	for _, key := range keys {
+		buf := record(key, s.entries[key], 0)
+
+		if off+len(buf) > s.areaSize() {
+			return errors.New("store is full")
+		}
+
+		if _, err = s.Device.WriteAt(buf, base+int64(off)); err != nil {
+			return
+		}
+
+		off += len(buf)
+	}
+
+	if err = s.writeHeader(area, s.generation+1); err != nil {
+		return
+	}
+
+	s.area = area
+	s.generation += 1
+	s.off = off
+	s.dirty = false
+
+	return
+}
+
+func (s *Store) append(key string, val []byte, flags uint8) (err error) {
+	if len(key) == 0 || len(key) > MaxKeyLength {
+		return errors.New("invalid key length")
+	}
+
+	if len(val) > MaxValueLength {
+		return errors.New("invalid value length")
+	}
+
+	buf := record(key, val, flags)
+
+	if s.dirty || s.off+len(buf) > s.areaSize() {
+		return s.compact()
+	}
+
+	if _, err = s.Device.WriteAt(buf, s.areaOffset(s.area)+int64(s.off)); err != nil {
+		s.dirty = true
+		return
+	}
+
+	s.off += len(buf)
+
+	return
+}
+
+// Get returns the value associated to the argument key and whether it was
+// found.
+func (s *Store) Get(key string) (val []byte, ok bool) {
+	s.Lock()
+	defer s.Unlock()
+
+	v, ok := s.entries[key]
+
+	if !ok {
+		return
+	}
+
+	val = make([]byte, len(v))
+	copy(val, v)
+
+	return
+}
+
+// Set associates the argument value to the argument key, writes are skipped
+// when the value is unchanged.
+func (s *Store) Set(key string, val []byte) (err error) {
+	s.Lock()
+	defer s.Unlock()
+
+	if s.entries == nil {
+		return errors.New("store not initialized")
+	}
+
+	if v, ok := s.entries[key]; ok && bytes.Equal(v, val) {
+		return
+	}
+
+	prev, found := s.entries[key]
+
+	v := make([]byte, len(val))
+	copy(v, val)
+	s.entries[key] = v
+
+	if err = s.append(key, v, 0); err != nil {
+		if found {
+			s.entries[key] = prev
+		} else {
+			delete(s.entries, key)
+		}
+	}
+
+	return
+}
+
+// Delete removes the argument key from the store.
+func (s *Store) Delete(key string) (err error) {
+	s.Lock()
+	defer s.Unlock()
+
+	prev, ok := s.entries[key]
+
+	if !ok {
+		return
+	}
+
+	delete(s.entries, key)
+
+	if err = s.append(key, nil, flagDelete); err != nil {
+		s.entries[key] = prev
+	}
+
+	return
+}
+
+// Keys returns the sorted list of stored keys.
+func (s *Store) Keys() (keys []string) {
+	s.Lock()
+	defer s.Unlock()
+
+	for key := range s.entries {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	return
+}
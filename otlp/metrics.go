@@ -0,0 +1,229 @@
+// OpenTelemetry Protocol (OTLP) exporter
+// https://github.com/karlo195/tamago
+//
+// Copyright (c) The TamaGo Authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+package otlp
+
+import (
+	"math"
+	"runtime/metrics"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Aggregation temporality
+const aggregationCumulative = 2
+
+type exportMetricsServiceRequest struct {
+	ResourceMetrics []resourceMetrics `json:"resourceMetrics"`
+}
+
+type resourceMetrics struct {
+	Resource     resource       `json:"resource"`
+	ScopeMetrics []scopeMetrics `json:"scopeMetrics"`
+}
+
+type scopeMetrics struct {
+	Scope   scope    `json:"scope"`
+	Metrics []metric `json:"metrics"`
+}
+
+type metric struct {
+	Name        string     `json:"name"`
+	Description string     `json:"description,omitempty"`
+	Unit        string     `json:"unit,omitempty"`
+	Gauge       *gauge     `json:"gauge,omitempty"`
+	Sum         *sum       `json:"sum,omitempty"`
+	Histogram   *histogram `json:"histogram,omitempty"`
+}
+
+type gauge struct {
+	DataPoints []numberDataPoint `json:"dataPoints"`
+}
+
+type sum struct {
+	DataPoints             []numberDataPoint `json:"dataPoints"`
+	AggregationTemporality int               `json:"aggregationTemporality"`
+	IsMonotonic            bool              `json:"isMonotonic"`
+}
+
+type histogram struct {
+	DataPoints             []histogramDataPoint `json:"dataPoints"`
+	AggregationTemporality int                  `json:"aggregationTemporality"`
+}
+
+type numberDataPoint struct {
+	StartTimeUnixNano uint64   `json:"startTimeUnixNano,omitempty,string"`
+	TimeUnixNano      uint64   `json:"timeUnixNano,string"`
+	AsInt             *int64   `json:"asInt,omitempty,string"`
+	AsDouble          *float64 `json:"asDouble,omitempty"`
+}
+
+type histogramDataPoint struct {
+	StartTimeUnixNano uint64    `json:"startTimeUnixNano,string"`
+	TimeUnixNano      uint64    `json:"timeUnixNano,string"`
+	Count             uint64    `json:"count,string"`
+	BucketCounts      uint64s   `json:"bucketCounts"`
+	ExplicitBounds    []float64 `json:"explicitBounds"`
+}
+
+// uint64s represents a repeated 64-bit integer field, encoded as decimal
+// strings in OTLP/JSON.
+type uint64s []uint64
+
+func (u uint64s) MarshalJSON() ([]byte, error) {
+	buf := []byte{'['}
+
+	for i, v := range u {
+		if i > 0 {
+			buf = append(buf, ',')
+		}
+
+		buf = append(buf, '"')
+		buf = strconv.AppendUint(buf, v, 10)
+		buf = append(buf, '"')
+	}
+
+	return append(buf, ']'), nil
+}
+
+// metricName converts a runtime/metrics name (e.g. /gc/heap/allocs:bytes)
+// to an OpenTelemetry metric name and unit (e.g. go.gc.heap.allocs, By).
+func metricName(name string) (otel string, unit string) {
+	path, u, _ := strings.Cut(name, ":")
+	otel = "go" + strings.NewReplacer("/", ".", "-", "_").Replace(path)
+
+	switch u {
+	case "bytes":
+		unit = "By"
+	case "seconds":
+		unit = "s"
+	case "percent":
+		unit = "%"
+	default:
+		unit = "{" + u + "}"
+	}
+
+	return
+}
+
+func (e *Exporter) descriptions() (desc map[string]metrics.Description, samples []metrics.Sample) {
+	desc = make(map[string]metrics.Description)
+
+	for _, d := range metrics.All() {
+		desc[d.Name] = d
+	}
+
+	names := e.Metrics
+
+	if len(names) == 0 {
+		for _, d := range metrics.All() {
+			names = append(names, d.Name)
+		}
+	}
+
+	for _, name := range names {
+		if _, ok := desc[name]; ok {
+			samples = append(samples, metrics.Sample{Name: name})
+		}
+	}
+
+	return
+}
+
+func (e *Exporter) metrics() (req *exportMetricsServiceRequest) {
+	desc, samples := e.descriptions()
+	metrics.Read(samples)
+
+	now := uint64(time.Now().UnixNano())
+	start := uint64(e.start.UnixNano())
+
+	sm := scopeMetrics{
+		Scope: scope{Name: ScopeName},
+	}
+
+	for _, s := range samples {
+		d := desc[s.Name]
+		m := metric{Description: d.Description}
+		m.Name, m.Unit = metricName(s.Name)
+
+		dp := numberDataPoint{TimeUnixNano: now}
+
+		switch s.Value.Kind() {
+		case metrics.KindUint64:
+			v := int64(min(s.Value.Uint64(), math.MaxInt64))
+			dp.AsInt = &v
+		case metrics.KindFloat64:
+			v := s.Value.Float64()
+			dp.AsDouble = &v
+		case metrics.KindFloat64Histogram:
+			m.Histogram = &histogram{
+				DataPoints:             []histogramDataPoint{histogramPoint(s.Value.Float64Histogram(), start, now)},
+				AggregationTemporality: aggregationCumulative,
+			}
+		default:
+			continue
+		}
+
+		switch {
+		case m.Histogram != nil:
+		case d.Cumulative:
+			dp.StartTimeUnixNano = start
+			m.Sum = &sum{
+				DataPoints:             []numberDataPoint{dp},
+				AggregationTemporality: aggregationCumulative,
+				IsMonotonic:            true,
+			}
+		default:
+			m.Gauge = &gauge{
+				DataPoints: []numberDataPoint{dp},
+			}
+		}
+
+		sm.Metrics = append(sm.Metrics, m)
+	}
+
+	return &exportMetricsServiceRequest{
+		ResourceMetrics: []resourceMetrics{
+			{
+				Resource:     e.resource(),
+				ScopeMetrics: []scopeMetrics{sm},
+			},
+		},
+	}
+}
+
+// histogramPoint converts a runtime histogram, where infinite bucket
+// boundaries are implicit in OTLP and therefore omitted.
+func histogramPoint(h *metrics.Float64Histogram, start uint64, now uint64) (dp histogramDataPoint) {
+	dp.StartTimeUnixNano = start
+	dp.TimeUnixNano = now
+	dp.ExplicitBounds = []float64{}
+
+	// runtime buckets boundaries are one more than their counts, the
+	// inner ones match OTLP explicit bounds.
+	for i, c := range h.Counts {
+		dp.Count += c
+
+		if i > 0 {
+			bound := h.Buckets[i]
+
+			if math.IsInf(bound, 0) {
+				// merge into the adjacent bucket
+				dp.BucketCounts[len(dp.BucketCounts)-1] += c
+				continue
+			}
+
+			dp.ExplicitBounds = append(dp.ExplicitBounds, bound)
+		}
+
+		dp.BucketCounts = append(dp.BucketCounts, c)
+	}
+
+	return
+}
@@ -0,0 +1,251 @@
+// OpenTelemetry Protocol (OTLP) exporter
+// https://github.com/karlo195/tamago
+//
+// Copyright (c) The TamaGo Authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+// Package otlp implements an OpenTelemetry Protocol (OTLP) exporter for Go
+// runtime metrics (see runtime/metrics) and trace spans, encoded in the
+// OTLP/JSON format and transmitted over HTTP or stream transports (e.g. vsock
+// connections), adopting the following reference specifications:
+//   - OpenTelemetry Protocol Specification - 1.3.2
+//   - OpenTelemetry Protocol File Exporter - JSON lines format
+//
+// This package is only meant to be used with `GOOS=tamago` as supported by the
+// TamaGo framework for bare metal Go, see https://github.com/karlo195/tamago.
+package otlp
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Signal types
+const (
+	Metrics = "metrics"
+	Traces  = "traces"
+)
+
+// ScopeName is the instrumentation scope name of exported telemetry.
+const ScopeName = "github.com/karlo195/tamago/otlp"
+
+// DefaultInterval is the default export interval.
+const DefaultInterval = 10 * time.Second
+
+// Transport represents an OTLP/JSON transport.
+type Transport interface {
+	// Export transmits an encoded export request for the argument signal
+	// type (Metrics or Traces).
+	Export(signal string, req []byte) error
+}
+
+// HTTP represents an OTLP/HTTP transport, the request for each signal is sent
+// to its default path (e.g. /v1/metrics) under the endpoint URL.
+type HTTP struct {
+	// Client is the HTTP client (default http.DefaultClient).
+	Client *http.Client
+	// Endpoint is the collector base URL (e.g. http://10.0.0.1:4318).
+	Endpoint string
+}
+
+// Export transmits an export request with an HTTP POST.
+func (t *HTTP) Export(signal string, req []byte) (err error) {
+	client := t.Client
+
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	res, err := client.Post(t.Endpoint+"/v1/"+signal, "application/json", bytes.NewReader(req))
+
+	if err != nil {
+		return
+	}
+	defer res.Body.Close()
+
+	io.Copy(io.Discard, res.Body)
+
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("export failed, %s", res.Status)
+	}
+
+	return
+}
+
+// Stream represents a transport over a byte stream (e.g. a vsock connection),
+// export requests are newline delimited as in the OTLP file exporter format,
+// for ingestion with a collector file or stream receiver.
+type Stream struct {
+	// Conn is the underlying stream.
+	Conn io.Writer
+
+	sync.Mutex
+}
+
+// Export transmits an export request followed by a newline.
+func (t *Stream) Export(_ string, req []byte) (err error) {
+	if t.Conn == nil {
+		return errors.New("invalid transport")
+	}
+
+	t.Lock()
+	defer t.Unlock()
+
+	_, err = t.Conn.Write(append(req, '\n'))
+
+	return
+}
+
+// Exporter represents an OTLP exporter instance.
+type Exporter struct {
+	// Transport is the OTLP/JSON transport.
+	Transport Transport
+
+	// ServiceName is the service.name resource attribute.
+	ServiceName string
+	// Attributes are additional resource attributes.
+	Attributes map[string]string
+
+	// Interval is the periodic export interval (default DefaultInterval).
+	Interval time.Duration
+	// Metrics is the list of exported runtime/metrics names, all
+	// supported metrics are exported when empty.
+	Metrics []string
+
+	// MaxSpans is the maximum number of pending spans, the oldest ones are
+	// dropped when exceeded (default 1024).
+	MaxSpans int
+
+	mu      sync.Mutex
+	spans   []*Span
+	start   time.Time
+	stop    chan struct{}
+	done    chan struct{}
+	dropped uint64
+}
+
+type keyValue struct {
+	Key   string   `json:"key"`
+	Value anyValue `json:"value"`
+}
+
+type anyValue struct {
+	String *string  `json:"stringValue,omitempty"`
+	Bool   *bool    `json:"boolValue,omitempty"`
+	Int    *int64   `json:"intValue,omitempty,string"`
+	Double *float64 `json:"doubleValue,omitempty"`
+}
+
+type resource struct {
+	Attributes []keyValue `json:"attributes"`
+}
+
+type scope struct {
+	Name string `json:"name"`
+}
+
+func stringValue(s string) anyValue {
+	return anyValue{String: &s}
+}
+
+func (e *Exporter) resource() (r resource) {
+	name := e.ServiceName
+
+	if name == "" {
+		name = "tamago"
+	}
+
+	r.Attributes = append(r.Attributes, keyValue{Key: "service.name", Value: stringValue(name)})
+
+	for k, v := range e.Attributes {
+		r.Attributes = append(r.Attributes, keyValue{Key: k, Value: stringValue(v)})
+	}
+
+	return
+}
+
+// Start starts periodic export of runtime metrics and pending spans.
+func (e *Exporter) Start() (err error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.Transport == nil {
+		return errors.New("invalid exporter instance")
+	}
+
+	if e.stop != nil {
+		return errors.New("exporter already started")
+	}
+
+	if e.Interval <= 0 {
+		e.Interval = DefaultInterval
+	}
+
+	e.start = time.Now()
+	e.stop = make(chan struct{})
+	e.done = make(chan struct{})
+
+	go e.run(e.stop, e.done)
+
+	return
+}
+
+// Stop stops periodic export, a final export is performed before returning.
+func (e *Exporter) Stop() {
+	e.mu.Lock()
+	stop, done := e.stop, e.done
+	e.stop = nil
+	e.mu.Unlock()
+
+	if stop == nil {
+		return
+	}
+
+	close(stop)
+	<-done
+}
+
+func (e *Exporter) run(stop chan struct{}, done chan struct{}) {
+	defer close(done)
+
+	t := time.NewTicker(e.Interval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-stop:
+			e.Export()
+			return
+		case <-t.C:
+			e.Export()
+		}
+	}
+}
+
+// Export immediately exports runtime metrics and pending spans, spans are
+// retained for later export on transport errors.
+func (e *Exporter) Export() (err error) {
+	if e.Transport == nil {
+		return errors.New("invalid exporter instance")
+	}
+
+	req, err := json.Marshal(e.metrics())
+
+	if err != nil {
+		return
+	}
+
+	if err = e.Transport.Export(Metrics, req); err != nil {
+		return
+	}
+
+	return e.exportSpans()
+}
@@ -0,0 +1,198 @@
+// OpenTelemetry Protocol (OTLP) exporter
+// https://github.com/karlo195/tamago
+//
+// Copyright (c) The TamaGo Authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+package otlp
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+)
+
+const defaultMaxSpans = 1024
+
+// Span status codes
+const (
+	StatusUnset = 0
+	StatusOK    = 1
+	StatusError = 2
+)
+
+type exportTraceServiceRequest struct {
+	ResourceSpans []resourceSpans `json:"resourceSpans"`
+}
+
+type resourceSpans struct {
+	Resource   resource     `json:"resource"`
+	ScopeSpans []scopeSpans `json:"scopeSpans"`
+}
+
+type scopeSpans struct {
+	Scope scope   `json:"scope"`
+	Spans []*Span `json:"spans"`
+}
+
+type status struct {
+	Message string `json:"message,omitempty"`
+	Code    int    `json:"code"`
+}
+
+// Span represents a trace span, started with [Exporter.StartSpan] and queued for
+// export on [Span.End].
+type Span struct {
+	TraceID      string     `json:"traceId"`
+	SpanID       string     `json:"spanId"`
+	ParentSpanID string     `json:"parentSpanId,omitempty"`
+	Name         string     `json:"name"`
+	Kind         int        `json:"kind"`
+	StartTime    uint64     `json:"startTimeUnixNano,string"`
+	EndTime      uint64     `json:"endTimeUnixNano,string"`
+	Attributes   []keyValue `json:"attributes,omitempty"`
+	Status       status     `json:"status"`
+
+	exporter *Exporter
+}
+
+func randomID(n int) string {
+	buf := make([]byte, n)
+	rand.Read(buf)
+
+	return hex.EncodeToString(buf)
+}
+
+// StartSpan starts a trace span, within the trace of the argument parent span
+// or a new one when nil.
+func (e *Exporter) StartSpan(name string, parent *Span) (s *Span) {
+	s = &Span{
+		SpanID: randomID(8),
+		Name:   name,
+		// SPAN_KIND_INTERNAL
+		Kind:      1,
+		StartTime: uint64(time.Now().UnixNano()),
+		exporter:  e,
+	}
+
+	if parent != nil {
+		s.TraceID = parent.TraceID
+		s.ParentSpanID = parent.SpanID
+	} else {
+		s.TraceID = randomID(16)
+	}
+
+	return
+}
+
+// SetAttribute sets a span attribute, values other than strings, booleans,
+// integers and floats are converted to strings.
+func (s *Span) SetAttribute(key string, val any) {
+	var v anyValue
+
+	switch t := val.(type) {
+	case string:
+		v.String = &t
+	case bool:
+		v.Bool = &t
+	case int:
+		i := int64(t)
+		v.Int = &i
+	case int64:
+		v.Int = &t
+	case float64:
+		v.Double = &t
+	default:
+		buf, _ := json.Marshal(t)
+		v = stringValue(string(buf))
+	}
+
+	s.Attributes = append(s.Attributes, keyValue{Key: key, Value: v})
+}
+
+// SetError sets the span status as failed with the argument error.
+func (s *Span) SetError(err error) {
+	s.Status = status{Code: StatusError, Message: err.Error()}
+}
+
+// End ends a span and queues it for export, the span must not be modified
+// afterwards.
+func (s *Span) End() {
+	e := s.exporter
+
+	if e == nil || s.EndTime != 0 {
+		return
+	}
+
+	s.EndTime = uint64(time.Now().UnixNano())
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.spans = append(e.spans, s)
+	e.trim()
+}
+
+// trim drops the oldest spans exceeding the maximum number of pending ones.
+func (e *Exporter) trim() {
+	max := e.MaxSpans
+
+	if max <= 0 {
+		max = defaultMaxSpans
+	}
+
+	if n := len(e.spans) - max; n > 0 {
+		e.spans = e.spans[n:]
+		e.dropped += uint64(n)
+	}
+}
+
+// Dropped returns the number of spans discarded on queue overflow.
+func (e *Exporter) Dropped() uint64 {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	return e.dropped
+}
+
+func (e *Exporter) exportSpans() (err error) {
+	e.mu.Lock()
+	spans := e.spans
+	e.spans = nil
+	e.mu.Unlock()
+
+	if len(spans) == 0 {
+		return
+	}
+
+	req, err := json.Marshal(&exportTraceServiceRequest{
+		ResourceSpans: []resourceSpans{
+			{
+				Resource: e.resource(),
+				ScopeSpans: []scopeSpans{
+					{
+						Scope: scope{Name: ScopeName},
+						Spans: spans,
+					},
+				},
+			},
+		},
+	})
+
+	if err == nil {
+		err = e.Transport.Export(Traces, req)
+	}
+
+	if err != nil {
+		// requeue for later export
+		e.mu.Lock()
+		e.spans = append(spans, e.spans...)
+		e.trim()
+		e.mu.Unlock()
+	}
+
+	return
+}
@@ -0,0 +1,187 @@
+// Warm reboot persistent memory support
+// https://github.com/karlo195/tamago
+//
+// Copyright (c) The TamaGo Authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+// Package persist implements a memory region preserved across warm reboots,
+// allowing fast restart-in-place recovery which retains state (e.g. crash
+// logs, counters, DMA descriptor rings) through runtime re-initialization:
+//
+//	r := &persist.Region{
+//		Start: 0x4f000000,
+//		Size:  0x100000,
+//	}
+//
+//	if err := r.Init(); err != nil {
+//		return err
+//	}
+//
+//	if r.Warm() {
+//		// resume from r.Data() without full device renegotiation
+//	}
+//
+//	// ...
+//	r.Reboot(nil)
+//
+// The region must not overlap with Go runtime memory nor with the global DMA
+// region (see dma.Init()), as both are re-initialized on boot.
+//
+// Preservation requires the platform reset not to clear or retrain RAM, which
+// is the case for QEMU system resets and for i.MX6 warm resets (see
+// imx6ul.SetWarmReset()) but not for Firecracker, which terminates the
+// virtual machine on reset.
+//
+// This package is only meant to be used with `GOOS=tamago` as supported by the
+// TamaGo framework for bare metal Go, see https://github.com/karlo195/tamago.
+package persist
+
+import (
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"sync"
+
+	"github.com/karlo195/tamago/dma"
+	"github.com/karlo195/tamago/power"
+)
+
+// Region header
+const (
+	MAGIC   = 0x52574754 // "TGWR"
+	VERSION = 1
+
+	// HeaderSize is the region header size.
+	HeaderSize = 32
+
+	// header flags
+	FLAG_WARM = 0
+)
+
+// Region represents a memory region preserved across warm reboots.
+type Region struct {
+	sync.Mutex
+
+	// Start is the region physical start address.
+	Start uint
+	// Size is the region size, including its header.
+	Size int
+
+	hdr  []byte
+	data []byte
+
+	warm  bool
+	boots uint32
+}
+
+func (r *Region) checksum() uint32 {
+	return crc32.ChecksumIEEE(r.data)
+}
+
+// Init initializes the region, its contents are preserved when a valid warm
+// reboot record is found and zeroed otherwise.
+func (r *Region) Init() (err error) {
+	r.Lock()
+	defer r.Unlock()
+
+	if r.Size <= HeaderSize {
+		return errors.New("invalid region size")
+	}
+
+	mem, err := dma.NewRegion(r.Start, r.Size, false)
+
+	if err != nil {
+		return
+	}
+
+	_, buf := mem.Reserve(r.Size, 0)
+
+	r.hdr = buf[:HeaderSize]
+	r.data = buf[HeaderSize:]
+
+	r.warm = binary.LittleEndian.Uint32(r.hdr[0:]) == MAGIC &&
+		binary.LittleEndian.Uint32(r.hdr[4:]) == VERSION &&
+		binary.LittleEndian.Uint32(r.hdr[8:]) == uint32(len(r.data)) &&
+		binary.LittleEndian.Uint32(r.hdr[16:])&(1<<FLAG_WARM) != 0 &&
+		binary.LittleEndian.Uint32(r.hdr[20:]) == r.checksum()
+
+	if r.warm {
+		r.boots = binary.LittleEndian.Uint32(r.hdr[12:]) + 1
+	} else {
+		r.boots = 0
+		clear(r.data)
+	}
+
+	binary.LittleEndian.PutUint32(r.hdr[0:], MAGIC)
+	binary.LittleEndian.PutUint32(r.hdr[4:], VERSION)
+	binary.LittleEndian.PutUint32(r.hdr[8:], uint32(len(r.data)))
+	binary.LittleEndian.PutUint32(r.hdr[12:], r.boots)
+
+	// an unplanned reset must not be mistaken for a warm reboot
+	binary.LittleEndian.PutUint32(r.hdr[16:], 0)
+
+	return
+}
+
+// Warm returns whether the region contents have been preserved from a warm
+// reboot.
+func (r *Region) Warm() bool {
+	r.Lock()
+	defer r.Unlock()
+
+	return r.warm
+}
+
+// Boots returns the number of consecutive warm reboots.
+func (r *Region) Boots() uint32 {
+	r.Lock()
+	defer r.Unlock()
+
+	return r.boots
+}
+
+// Data returns the preserved region contents, following its header.
+func (r *Region) Data() []byte {
+	r.Lock()
+	defer r.Unlock()
+
+	return r.data
+}
+
+// Seal records the current region contents as valid for the next boot, it
+// must be invoked after the last update before a warm reboot.
+func (r *Region) Seal() (err error) {
+	r.Lock()
+	defer r.Unlock()
+
+	if r.hdr == nil {
+		return errors.New("region not initialized")
+	}
+
+	binary.LittleEndian.PutUint32(r.hdr[20:], r.checksum())
+	binary.LittleEndian.PutUint32(r.hdr[16:], 1<<FLAG_WARM)
+
+	return
+}
+
+// Reboot seals the region and invokes the argument reset function, or
+// power.Reset() when nil, which must retain RAM contents. An error is
+// returned if the reset function returns.
+//
+// On processors with write-back data caches, the region must be cleaned to
+// memory before reset (e.g. arm.CPU.FlushDataCache()).
+func (r *Region) Reboot(reset func()) (err error) {
+	if err = r.Seal(); err != nil {
+		return
+	}
+
+	if reset == nil {
+		return power.Reset()
+	}
+
+	reset()
+
+	return errors.New("reset failed")
+}
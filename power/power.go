@@ -0,0 +1,122 @@
+// System reset and power control
+// https://github.com/karlo195/tamago
+//
+// Copyright (c) The TamaGo Authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+// Package power provides a board independent interface for system reset,
+// shutdown and reboot, allowing applications to avoid architecture or board
+// specific methods (e.g. amd64.CPU.Reset, imx6ul.Reset):
+//
+//	import (
+//		"github.com/karlo195/tamago/power"
+//		_ "github.com/karlo195/tamago/board/qemu/microvm"
+//	)
+//
+//	func update() {
+//		// ...
+//		power.Reboot("firmware update")
+//	}
+//
+// Each board package registers its own [Platform] implementation on import,
+// using the mechanism available on the board hardware or hypervisor (e.g.
+// watchdog, ACPI, triple fault, hypervisor exit).
+//
+// This package is only meant to be used with `GOOS=tamago` as supported by the
+// TamaGo framework for bare metal Go, see https://github.com/karlo195/tamago.
+package power
+
+import (
+	"errors"
+	"sync"
+)
+
+// Platform represents the board specific system reset and power control.
+//
+// The methods are not expected to return on success, an implementation
+// returns when the operation is not supported by the board.
+type Platform interface {
+	// Reset performs an immediate system reset, without any clean up.
+	Reset()
+	// Shutdown performs an orderly shutdown, powering off the board or
+	// terminating the virtual machine.
+	Shutdown()
+	// Reboot performs an orderly restart, the argument reason is reported
+	// when supported by the board (e.g. console output).
+	Reboot(reason string)
+}
+
+var (
+	mu       sync.Mutex
+	platform Platform
+)
+
+// Register sets the board Platform implementation, it is meant to be invoked
+// by board packages on initialization.
+func Register(p Platform) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	platform = p
+}
+
+// Registered returns the board Platform implementation, nil is returned if
+// no board registered one.
+func Registered() Platform {
+	mu.Lock()
+	defer mu.Unlock()
+
+	return platform
+}
+
+func get() (Platform, error) {
+	if p := Registered(); p != nil {
+		return p, nil
+	}
+
+	return nil, errors.New("no platform registered")
+}
+
+// Reset performs an immediate system reset, an error is returned if no
+// platform is registered or if the board does not support it.
+func Reset() error {
+	p, err := get()
+
+	if err != nil {
+		return err
+	}
+
+	p.Reset()
+
+	return errors.New("reset not supported")
+}
+
+// Shutdown performs an orderly shutdown, an error is returned if no platform
+// is registered or if the board does not support it.
+func Shutdown() error {
+	p, err := get()
+
+	if err != nil {
+		return err
+	}
+
+	p.Shutdown()
+
+	return errors.New("shutdown not supported")
+}
+
+// Reboot performs an orderly restart for the argument reason, an error is
+// returned if no platform is registered or if the board does not support it.
+func Reboot(reason string) error {
+	p, err := get()
+
+	if err != nil {
+		return err
+	}
+
+	p.Reboot(reason)
+
+	return errors.New("reboot not supported")
+}
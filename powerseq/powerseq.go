@@ -0,0 +1,177 @@
+// Power and reset sequencing support
+// https://github.com/karlo195/tamago
+//
+// Copyright (c) The TamaGo Authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+// Package powerseq implements declarative power-enable and reset GPIO
+// sequences for external peripherals (e.g. Ethernet PHYs, radios, displays),
+// replacing ad-hoc sequencing code in board packages:
+//
+//	phy := &powerseq.Device{
+//		Name: "phy",
+//		On: powerseq.Sequence{
+//			{Line: pwr, Value: true, Delay: 10 * time.Millisecond},
+//			{Line: rst, Value: false, Delay: 10 * time.Millisecond},
+//			{Line: rst, Value: true, Delay: 50 * time.Millisecond},
+//		},
+//		Off: powerseq.Sequence{
+//			{Line: rst, Value: false},
+//			{Line: pwr, Value: false},
+//		},
+//	}
+//
+//	if err := phy.PowerOn(); err != nil {
+//		return err
+//	}
+//
+// A [Device] initialization function can also be registered for deferred
+// execution (see the devinit package):
+//
+//	board.Devices.Add(&devinit.Device{
+//		Name: phy.Name,
+//		Init: phy.PowerOn,
+//	})
+//
+// This package is only meant to be used with `GOOS=tamago` as supported by the
+// TamaGo framework for bare metal Go, see https://github.com/karlo195/tamago.
+package powerseq
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Line represents a GPIO output line, matching the NXP GPIO (see
+// soc/nxp/gpio) and BCM2835 GPIO (see soc/bcm2835) APIs.
+type Line interface {
+	// Out configures the line as output.
+	Out()
+	// High drives the line high.
+	High()
+	// Low drives the line low.
+	Low()
+}
+
+// Step represents a single sequence step.
+type Step struct {
+	// Line is the GPIO line driven by this step.
+	Line Line
+	// Value is the line logic level (true for high, false for low).
+	Value bool
+	// Delay is the time to wait after driving the line.
+	Delay time.Duration
+}
+
+// Sequence represents an ordered list of steps.
+type Sequence []Step
+
+// Run executes all sequence steps in order.
+func (seq Sequence) Run() (err error) {
+	for i, s := range seq {
+		if s.Line == nil {
+			return fmt.Errorf("invalid line at step %d", i)
+		}
+
+		s.Line.Out()
+
+		if s.Value {
+			s.Line.High()
+		} else {
+			s.Line.Low()
+		}
+
+		if s.Delay > 0 {
+			time.Sleep(s.Delay)
+		}
+	}
+
+	return
+}
+
+// Pulse returns a sequence which asserts a reset line for the argument
+// duration, then deasserts it and waits for the argument settle time.
+func Pulse(line Line, activeLow bool, width time.Duration, settle time.Duration) Sequence {
+	return Sequence{
+		{Line: line, Value: !activeLow, Delay: width},
+		{Line: line, Value: activeLow, Delay: settle},
+	}
+}
+
+// Device represents an external peripheral with power and reset sequences.
+type Device struct {
+	sync.Mutex
+
+	// Name is the device name.
+	Name string
+	// On is the power-on, and reset release, sequence.
+	On Sequence
+	// Off is the power-off sequence.
+	Off Sequence
+
+	powered bool
+}
+
+// PowerOn executes the device power-on sequence, it has no effect if the
+// device is already powered on.
+func (d *Device) PowerOn() (err error) {
+	d.Lock()
+	defer d.Unlock()
+
+	if d.powered {
+		return
+	}
+
+	if len(d.On) == 0 {
+		return errors.New("missing power-on sequence")
+	}
+
+	if err = d.On.Run(); err != nil {
+		return fmt.Errorf("%s power-on, %v", d.Name, err)
+	}
+
+	d.powered = true
+
+	return
+}
+
+// PowerOff executes the device power-off sequence, it has no effect if the
+// device is already powered off.
+func (d *Device) PowerOff() (err error) {
+	d.Lock()
+	defer d.Unlock()
+
+	if !d.powered {
+		return
+	}
+
+	if err = d.Off.Run(); err != nil {
+		return fmt.Errorf("%s power-off, %v", d.Name, err)
+	}
+
+	d.powered = false
+
+	return
+}
+
+// Cycle executes the device power-off sequence, if powered on, followed by
+// its power-on sequence.
+func (d *Device) Cycle() (err error) {
+	if err = d.PowerOff(); err != nil {
+		return
+	}
+
+	return d.PowerOn()
+}
+
+// Powered returns whether the device power-on sequence has been executed.
+func (d *Device) Powered() bool {
+	d.Lock()
+	defer d.Unlock()
+
+	return d.powered
+}
@@ -0,0 +1,167 @@
+// Deterministic record and replay support
+// https://github.com/karlo195/tamago
+//
+// Copyright (c) The TamaGo Authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+package replay
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"sync"
+)
+
+// Player represents a [Tracer] replaying recorded inputs.
+//
+// Traced register reads and queue indexes return recorded values, in
+// recording order, while recorded interrupts must be injected by the test
+// harness (see [Player.Dispatch]) at the point the replayed execution
+// reaches them. On divergence the argument values are returned and the
+// divergence is reported by [Player.Err].
+type Player struct {
+	sync.Mutex
+
+	events []Event
+	pos    int
+	err    error
+}
+
+// NewPlayer returns a player for the argument events.
+func NewPlayer(events []Event) *Player {
+	return &Player{
+		events: events,
+	}
+}
+
+// Load returns a player for the events read, as JSON lines, from the
+// argument reader (see [Recorder.WriteTo]).
+func Load(r io.Reader) (p *Player, err error) {
+	var events []Event
+
+	dec := json.NewDecoder(r)
+
+	for {
+		var ev Event
+
+		if err = dec.Decode(&ev); err == io.EOF {
+			break
+		} else if err != nil {
+			return
+		}
+
+		events = append(events, ev)
+	}
+
+	if len(events) == 0 {
+		return nil, errors.New("empty replay log")
+	}
+
+	return NewPlayer(events), nil
+}
+
+// next consumes the next event if it matches the argument one, reporting a
+// divergence otherwise.
+func (p *Player) next(got Event) (ev Event, ok bool) {
+	p.Lock()
+	defer p.Unlock()
+
+	if p.err != nil {
+		return
+	}
+
+	if p.pos < len(p.events) {
+		ev = p.events[p.pos]
+	}
+
+	if !ev.match(got) {
+		got.Seq = uint64(p.pos + 1)
+		p.err = &Divergence{Expected: ev, Got: got}
+		return
+	}
+
+	p.pos++
+
+	return ev, true
+}
+
+// Interrupt verifies that the servicing of an interrupt vector matches the
+// recorded sequence.
+func (p *Player) Interrupt(source string, vector int) {
+	p.next(Event{Kind: Interrupt, Source: source, ID: uint64(vector)})
+}
+
+// Read returns the recorded value of a register read.
+func (p *Player) Read(source string, addr uint64, val uint64) uint64 {
+	if ev, ok := p.next(Event{Kind: Read, Source: source, ID: addr, Value: val}); ok {
+		return ev.Value
+	}
+
+	return val
+}
+
+// Queue returns the recorded value of a virtual queue index read.
+func (p *Player) Queue(source string, addr uint64, index uint16) uint16 {
+	if ev, ok := p.next(Event{Kind: Queue, Source: source, ID: addr, Value: uint64(index)}); ok {
+		return uint16(ev.Value)
+	}
+
+	return index
+}
+
+// Peek returns the next recorded event, without consuming it.
+func (p *Player) Peek() (ev Event, ok bool) {
+	p.Lock()
+	defer p.Unlock()
+
+	if p.err != nil || p.pos >= len(p.events) {
+		return
+	}
+
+	return p.events[p.pos], true
+}
+
+// Dispatch invokes the argument function for each recorded interrupt
+// pending at the current replay position, the function is expected to service
+// the interrupt with the code under test, which must invoke
+// [Player.Interrupt] for it to be consumed.
+//
+// The number of dispatched interrupts is returned, dispatching stops on
+// divergence or when the function does not consume the interrupt.
+func (p *Player) Dispatch(fn func(source string, vector int)) (n int) {
+	for {
+		ev, ok := p.Peek()
+
+		if !ok || ev.Kind != Interrupt {
+			return
+		}
+
+		fn(ev.Source, int(ev.ID))
+
+		if next, ok := p.Peek(); ok && next.Seq == ev.Seq {
+			// not consumed
+			return
+		}
+
+		n++
+	}
+}
+
+// Done returns whether all recorded events have been replayed.
+func (p *Player) Done() bool {
+	p.Lock()
+	defer p.Unlock()
+
+	return p.err == nil && p.pos == len(p.events)
+}
+
+// Err returns the first replay divergence, if any.
+func (p *Player) Err() error {
+	p.Lock()
+	defer p.Unlock()
+
+	return p.err
+}
@@ -0,0 +1,131 @@
+// Deterministic record and replay support
+// https://github.com/karlo195/tamago
+//
+// Copyright (c) The TamaGo Authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+package replay
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+)
+
+// DefaultMaxEvents is the default maximum number of recorded events.
+const DefaultMaxEvents = 1 << 16
+
+// Recorder represents a [Tracer] recording all traced inputs.
+//
+// Recording stops once the maximum number of events is reached, as replay
+// requires the complete sequence since the beginning of a run.
+type Recorder struct {
+	sync.Mutex
+
+	// MaxEvents is the maximum number of recorded events (default
+	// DefaultMaxEvents).
+	MaxEvents int
+
+	events    []Event
+	seq       uint64
+	truncated bool
+}
+
+func (r *Recorder) record(kind Kind, source string, id uint64, val uint64) {
+	r.Lock()
+	defer r.Unlock()
+
+	max := r.MaxEvents
+
+	if max <= 0 {
+		max = DefaultMaxEvents
+	}
+
+	if len(r.events) >= max {
+		r.truncated = true
+		return
+	}
+
+	r.seq++
+
+	r.events = append(r.events, Event{
+		Seq:    r.seq,
+		Kind:   kind,
+		Source: source,
+		ID:     id,
+		Value:  val,
+	})
+}
+
+// Interrupt records the servicing of an interrupt vector.
+func (r *Recorder) Interrupt(source string, vector int) {
+	r.record(Interrupt, source, uint64(vector), 0)
+}
+
+// Read records a register read, returning the argument value.
+func (r *Recorder) Read(source string, addr uint64, val uint64) uint64 {
+	r.record(Read, source, addr, val)
+	return val
+}
+
+// Queue records a virtual queue index read, returning the argument value.
+func (r *Recorder) Queue(source string, addr uint64, index uint16) uint16 {
+	r.record(Queue, source, addr, uint64(index))
+	return index
+}
+
+// Events returns a copy of the recorded events.
+func (r *Recorder) Events() []Event {
+	r.Lock()
+	defer r.Unlock()
+
+	return append([]Event(nil), r.events...)
+}
+
+// Truncated returns whether events have been discarded as the maximum number
+// of events was reached.
+func (r *Recorder) Truncated() bool {
+	r.Lock()
+	defer r.Unlock()
+
+	return r.truncated
+}
+
+// Reset discards all recorded events.
+func (r *Recorder) Reset() {
+	r.Lock()
+	defer r.Unlock()
+
+	r.events = nil
+	r.seq = 0
+	r.truncated = false
+}
+
+// WriteTo writes the recorded events, as JSON lines, to the argument writer
+// (e.g. console or network connection) for later replay (see [Load]).
+func (r *Recorder) WriteTo(w io.Writer) (n int64, err error) {
+	cw := &countWriter{w: w}
+	enc := json.NewEncoder(cw)
+
+	for _, ev := range r.Events() {
+		if err = enc.Encode(ev); err != nil {
+			break
+		}
+	}
+
+	return cw.n, err
+}
+
+type countWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countWriter) Write(p []byte) (n int, err error) {
+	n, err = c.w.Write(p)
+	c.n += int64(n)
+
+	return
+}
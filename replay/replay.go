@@ -0,0 +1,127 @@
+// Deterministic record and replay support
+// https://github.com/karlo195/tamago
+//
+// Copyright (c) The TamaGo Authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+// Package replay implements record and replay of the non-deterministic inputs
+// of interrupt driven drivers: interrupt order, register reads and virtual
+// queue indexes.
+//
+// Drivers supporting it (e.g. virtio.MMIO, virtio.VirtualQueue and
+// amd64.CPU.ServiceInterrupts) pass hardware values through a [Tracer]. A
+// [Recorder] logs them during a run on target, the resulting log can then be
+// loaded in a [Player] which, used as Tracer in host tests, substitutes
+// recorded values to hardware ones and reports any divergence from the
+// recorded execution, making race conditions reproducible.
+//
+// Unlike most packages in this repository, this package has no hardware
+// dependencies and it can be used on any GOOS.
+package replay
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Event kinds
+const (
+	Interrupt Kind = iota + 1
+	Read
+	Queue
+)
+
+// Kind represents an event kind.
+type Kind uint8
+
+var kinds = map[Kind]string{
+	Interrupt: "irq",
+	Read:      "read",
+	Queue:     "queue",
+}
+
+// String returns the event kind name.
+func (k Kind) String() string {
+	if s, ok := kinds[k]; ok {
+		return s
+	}
+
+	return fmt.Sprintf("kind(%d)", k)
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (k Kind) MarshalText() ([]byte, error) {
+	return []byte(k.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (k *Kind) UnmarshalText(text []byte) error {
+	for kind, s := range kinds {
+		if s == string(text) {
+			*k = kind
+			return nil
+		}
+	}
+
+	return errors.New("invalid event kind")
+}
+
+// Event represents a recorded non-deterministic input.
+type Event struct {
+	// Seq is the event sequence number.
+	Seq uint64 `json:"seq"`
+	// Kind is the event kind.
+	Kind Kind `json:"kind"`
+	// Source identifies the recording driver (e.g. "virtio-mmio").
+	Source string `json:"src"`
+	// ID identifies the input within its source: the interrupt vector,
+	// the register address or the virtual queue address.
+	ID uint64 `json:"id"`
+	// Value is the register value or queue index.
+	Value uint64 `json:"val,omitempty"`
+}
+
+// String returns the event description.
+func (e Event) String() string {
+	return fmt.Sprintf("#%d %s %s id:%#x val:%#x", e.Seq, e.Kind, e.Source, e.ID, e.Value)
+}
+
+// match returns whether two events refer to the same input, regardless of
+// their values.
+func (e Event) match(o Event) bool {
+	return e.Kind == o.Kind && e.Source == o.Source && e.ID == o.ID
+}
+
+// Tracer represents the hook through which drivers pass non-deterministic
+// inputs.
+type Tracer interface {
+	// Interrupt traces the servicing of an interrupt vector.
+	Interrupt(source string, vector int)
+	// Read traces a register read, returning the value to be used by the
+	// driver.
+	Read(source string, addr uint64, val uint64) uint64
+	// Queue traces a virtual queue index read, returning the value to be
+	// used by the driver.
+	Queue(source string, addr uint64, index uint16) uint16
+}
+
+// Divergence represents a replay divergence, occurring when the replayed
+// execution requests an input different from the recorded one.
+type Divergence struct {
+	// Expected is the recorded event, with a zero Kind when the log is
+	// exhausted.
+	Expected Event
+	// Got is the replayed event.
+	Got Event
+}
+
+// Error implements the error interface.
+func (d *Divergence) Error() string {
+	if d.Expected.Kind == 0 {
+		return fmt.Sprintf("replay divergence, log exhausted, got %s", d.Got)
+	}
+
+	return fmt.Sprintf("replay divergence, expected %s, got %s", d.Expected, d.Got)
+}
@@ -0,0 +1,369 @@
+// eMMC Replay Protected Memory Block (RPMB) support
+// https://github.com/karlo195/tamago
+//
+// Copyright (c) The TamaGo Authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+// Package rpmb implements authenticated access to eMMC Replay Protected
+// Memory Block (RPMB) partitions, adopting the following reference
+// specifications:
+//   - JESD84-B51 - Embedded Multi-Media Card (e•MMC) Electrical Standard (5.1)
+//
+// RPMB partitions provide tamper-resistant storage, for counters and secrets,
+// as writes are authenticated with an HMAC-SHA256 key, programmed once on
+// the card, and protected against replay by a card write counter.
+//
+// The key should be derived from a hardware unique key (see [DeriveKey]) so
+// that it never needs to be stored:
+//
+//	p := &rpmb.RPMB{
+//		Device: imx6ul.USDHC1,
+//	}
+//
+//	p.Key, _ = rpmb.DeriveKey(func(div []byte) ([]byte, error) {
+//		key := make([]byte, 32)
+//		return key, imx6ul.CAAM.DeriveKey(div, key)
+//	}, []byte("rpmb"))
+//
+//	// program the key once, this operation is irreversible
+//	if err := p.ProgramKey(); err != nil && !errors.Is(err, rpmb.ErrKeyProgrammed) {
+//		return err
+//	}
+//
+// This package is only meant to be used with `GOOS=tamago` as supported by the
+// TamaGo framework for bare metal Go, see https://github.com/karlo195/tamago.
+package rpmb
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// RPMB data frame fields (p104, Table 18 — Data Frame Files for RPMB,
+// JESD84-B51)
+const (
+	FrameSize = 512
+	DataSize  = 256
+	KeySize   = sha256.Size
+
+	frameMAC     = 196
+	frameData    = 228
+	frameNonce   = 484
+	frameCounter = 500
+	frameAddress = 504
+	frameBlocks  = 506
+	frameResult  = 508
+	frameRequest = 510
+)
+
+// RPMB request message types (p105, Table 19 — RPMB Request/Response Message
+// Types, JESD84-B51)
+const (
+	REQ_PROGRAM_KEY  = 0x0001
+	REQ_READ_COUNTER = 0x0002
+	REQ_WRITE_DATA   = 0x0003
+	REQ_READ_DATA    = 0x0004
+	REQ_READ_RESULT  = 0x0005
+
+	RESP = 0x0100
+)
+
+// RPMB operation results (p106, Table 20 — RPMB Operation Results,
+// JESD84-B51)
+const (
+	RES_OK              = 0x0000
+	RES_FAILURE         = 0x0001
+	RES_AUTH_FAILURE    = 0x0002
+	RES_COUNTER_FAILURE = 0x0003
+	RES_ADDRESS_FAILURE = 0x0004
+	RES_WRITE_FAILURE   = 0x0005
+	RES_READ_FAILURE    = 0x0006
+	RES_NO_KEY          = 0x0007
+
+	RES_MASK            = 0x007f
+	RES_COUNTER_EXPIRED = 0x0080
+)
+
+// ErrKeyProgrammed is returned by [RPMB.ProgramKey] when the card
+// authentication key has already been programmed.
+var ErrKeyProgrammed = errors.New("RPMB key already programmed")
+
+// Device represents an eMMC card RPMB partition, matching the NXP uSDHC
+// driver (see soc/nxp/usdhc) API.
+type Device interface {
+	// WriteRPMB transfers a single data frame to the card, with an
+	// optional reliable write request.
+	WriteRPMB(buf []byte, rel bool) (err error)
+	// ReadRPMB transfers a single data frame from the card.
+	ReadRPMB(buf []byte) (err error)
+}
+
+// RPMB represents an eMMC card Replay Protected Memory Block partition.
+type RPMB struct {
+	sync.Mutex
+
+	// Device is the eMMC card RPMB partition.
+	Device Device
+	// Key is the 32-byte authentication key.
+	Key []byte
+}
+
+type frame [FrameSize]byte
+
+func (f *frame) mac(key []byte) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write(f[frameData:])
+	return h.Sum(nil)
+}
+
+func (f *frame) sign(key []byte) {
+	copy(f[frameMAC:frameData], f.mac(key))
+}
+
+func (f *frame) result() uint16 {
+	return binary.BigEndian.Uint16(f[frameResult:])
+}
+
+func (f *frame) verify(key []byte, req uint16, nonce []byte) (err error) {
+	if res := binary.BigEndian.Uint16(f[frameRequest:]); res != RESP|req {
+		return fmt.Errorf("unexpected RPMB response %#x", res)
+	}
+
+	if res := f.result(); res&RES_MASK != RES_OK {
+		return fmt.Errorf("RPMB operation failed (%#x)", res)
+	}
+
+	if nonce != nil && !bytes.Equal(f[frameNonce:frameCounter], nonce) {
+		return errors.New("RPMB nonce mismatch")
+	}
+
+	if key != nil && !hmac.Equal(f[frameMAC:frameData], f.mac(key)) {
+		return errors.New("RPMB MAC mismatch")
+	}
+
+	return
+}
+
+func (p *RPMB) check() error {
+	if p.Device == nil || len(p.Key) != KeySize {
+		return errors.New("invalid RPMB instance")
+	}
+
+	return nil
+}
+
+// request issues a read request, with a random nonce, and returns its
+// verified response.
+func (p *RPMB) request(req uint16, addr uint16) (f *frame, err error) {
+	f = &frame{}
+	nonce := make([]byte, frameCounter-frameNonce)
+
+	if _, err = rand.Read(nonce); err != nil {
+		return
+	}
+
+	copy(f[frameNonce:], nonce)
+	binary.BigEndian.PutUint16(f[frameAddress:], addr)
+	binary.BigEndian.PutUint16(f[frameRequest:], req)
+
+	if err = p.Device.WriteRPMB(f[:], false); err != nil {
+		return
+	}
+
+	if err = p.Device.ReadRPMB(f[:]); err != nil {
+		return
+	}
+
+	return f, f.verify(p.Key, req, nonce)
+}
+
+// readResult issues a result read request and returns its response.
+func (p *RPMB) readResult() (f *frame, err error) {
+	f = &frame{}
+	binary.BigEndian.PutUint16(f[frameRequest:], REQ_READ_RESULT)
+
+	if err = p.Device.WriteRPMB(f[:], false); err != nil {
+		return
+	}
+
+	err = p.Device.ReadRPMB(f[:])
+
+	return
+}
+
+// ProgramKey programs the authentication key on the card
+// (p105, 6.6.22.4.1 Programming of the Authentication Key, JESD84-B51).
+//
+// This is a one-time operation, the key cannot be changed once programmed
+// and [ErrKeyProgrammed] is returned on further attempts.
+func (p *RPMB) ProgramKey() (err error) {
+	p.Lock()
+	defer p.Unlock()
+
+	if err = p.check(); err != nil {
+		return
+	}
+
+	f := &frame{}
+	copy(f[frameMAC:frameData], p.Key)
+	binary.BigEndian.PutUint16(f[frameRequest:], REQ_PROGRAM_KEY)
+
+	if err = p.Device.WriteRPMB(f[:], true); err != nil {
+		return
+	}
+
+	if f, err = p.readResult(); err != nil {
+		return
+	}
+
+	if f.result()&RES_MASK == RES_WRITE_FAILURE {
+		return ErrKeyProgrammed
+	}
+
+	return f.verify(nil, REQ_PROGRAM_KEY, nil)
+}
+
+func (p *RPMB) counter() (n uint32, err error) {
+	f, err := p.request(REQ_READ_COUNTER, 0)
+
+	if err != nil {
+		return
+	}
+
+	return binary.BigEndian.Uint32(f[frameCounter:]), nil
+}
+
+// Counter returns the card write counter, which is incremented on each
+// authenticated write
+// (p106, 6.6.22.4.2 Reading of the Counter Value, JESD84-B51).
+func (p *RPMB) Counter() (n uint32, err error) {
+	p.Lock()
+	defer p.Unlock()
+
+	if err = p.check(); err != nil {
+		return
+	}
+
+	return p.counter()
+}
+
+// Read performs authenticated reads of consecutive half sectors (256 bytes),
+// starting at the argument address, to fill the argument buffer
+// (p108, 6.6.22.4.4 Authenticated Data Read, JESD84-B51).
+func (p *RPMB) Read(addr uint16, buf []byte) (err error) {
+	p.Lock()
+	defer p.Unlock()
+
+	if err = p.check(); err != nil {
+		return
+	}
+
+	if len(buf)%DataSize != 0 {
+		return errors.New("invalid buffer size")
+	}
+
+	for off := 0; off < len(buf); off += DataSize {
+		f, err := p.request(REQ_READ_DATA, addr)
+
+		if err != nil {
+			return err
+		}
+
+		copy(buf[off:], f[frameData:frameNonce])
+		addr++
+	}
+
+	return
+}
+
+// Write performs authenticated writes of the argument buffer to consecutive
+// half sectors (256 bytes), starting at the argument address
+// (p106, 6.6.22.4.3 Authenticated Data Write, JESD84-B51).
+func (p *RPMB) Write(addr uint16, buf []byte) (err error) {
+	p.Lock()
+	defer p.Unlock()
+
+	if err = p.check(); err != nil {
+		return
+	}
+
+	if len(buf)%DataSize != 0 {
+		return errors.New("invalid buffer size")
+	}
+
+	for off := 0; off < len(buf); off += DataSize {
+		n, err := p.counter()
+
+		if err != nil {
+			return err
+		}
+
+		if n == ^uint32(0) {
+			return errors.New("RPMB write counter expired")
+		}
+
+		f := &frame{}
+		copy(f[frameData:frameNonce], buf[off:])
+		binary.BigEndian.PutUint32(f[frameCounter:], n)
+		binary.BigEndian.PutUint16(f[frameAddress:], addr)
+		binary.BigEndian.PutUint16(f[frameBlocks:], 1)
+		binary.BigEndian.PutUint16(f[frameRequest:], REQ_WRITE_DATA)
+		f.sign(p.Key)
+
+		if err = p.Device.WriteRPMB(f[:], true); err != nil {
+			return err
+		}
+
+		if f, err = p.readResult(); err != nil {
+			return err
+		}
+
+		if err = f.verify(p.Key, REQ_WRITE_DATA, nil); err != nil {
+			return err
+		}
+
+		if binary.BigEndian.Uint32(f[frameCounter:]) != n+1 {
+			return errors.New("RPMB write counter mismatch")
+		}
+
+		addr++
+	}
+
+	return
+}
+
+// DeriveKey returns an RPMB authentication key derived, for the argument
+// diversifier, from a hardware unique key derivation function (e.g.
+// caam.CAAM.DeriveKey or dcp.DCP.DeriveKey).
+//
+// The derived key material is used as HMAC-SHA256 key, over a fixed label,
+// to obtain a key of the required size regardless of the underlying
+// derivation function output size.
+func DeriveKey(derive func(diversifier []byte) (key []byte, err error), diversifier []byte) (key []byte, err error) {
+	if derive == nil {
+		return nil, errors.New("invalid key derivation function")
+	}
+
+	k, err := derive(diversifier)
+
+	if err != nil {
+		return
+	}
+
+	if len(k) == 0 {
+		return nil, errors.New("invalid derived key")
+	}
+
+	h := hmac.New(sha256.New, k)
+	h.Write([]byte("RPMB authentication key"))
+
+	return h.Sum(nil), nil
+}
@@ -0,0 +1,308 @@
+// ESP-Hosted SDIO Wi-Fi transport driver
+// https://github.com/karlo195/tamago
+//
+// Copyright (c) The TamaGo Authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+// Package esphosted implements an SDIO function driver (see package sdio) for
+// Espressif ESP32 co-processors running ESP-Hosted firmware, exposing the
+// Wi-Fi station interface as generic network interface controller (see
+// nic.NIC), adopting the following reference specifications:
+//   - ESP-Hosted - https://github.com/espressif/esp-hosted
+//
+// Only the data path is implemented, Wi-Fi configuration (e.g. access point
+// association) is performed by the application through control messages
+// exchanged with [ESPHosted.ReadControl] and [ESPHosted.WriteControl] on the
+// ESP-Hosted serial interface (RPC protocol buffers).
+//
+// This package is only meant to be used with `GOOS=tamago` as supported by the
+// TamaGo framework for bare metal Go, see https://github.com/karlo195/tamago.
+package esphosted
+
+import (
+	"encoding/binary"
+	"errors"
+	"sync"
+
+	"github.com/karlo195/tamago/sdio"
+)
+
+// SDIO identification
+const (
+	VENDOR_ID   = 0x6666
+	DEVICE_ID_1 = 0x2222
+	DEVICE_ID_2 = 0x3333
+)
+
+// ESP32 SDIO slave host registers, accessed through function 1 with their
+// address masked to the lower 10 bits.
+const (
+	SLCHOST_BASE = 0x3ff55000
+
+	TOKEN_RDATA    = SLCHOST_BASE + 0x44
+	INT_RAW        = SLCHOST_BASE + 0x50
+	INT_ST         = SLCHOST_BASE + 0x58
+	PACKET_LEN     = SLCHOST_BASE + 0x60
+	SCRATCH_REG_7  = SLCHOST_BASE + 0x8c
+	INT_CLR        = SLCHOST_BASE + 0xd4
+	HOST_TO_SLAVE  = SCRATCH_REG_7
+	ADDRESS_MASK   = 0x3ff
+	CMD53_END_ADDR = 0x1f800
+
+	// host to slave interrupts
+	OPEN_DATA_PATH  = 0
+	CLOSE_DATA_PATH = 1
+)
+
+// Interface types
+const (
+	STA_IF    = 0
+	AP_IF     = 1
+	SERIAL_IF = 2
+	HCI_IF    = 3
+	PRIV_IF   = 4
+)
+
+// HeaderSize is the ESP-Hosted payload header size.
+const HeaderSize = 12
+
+const (
+	blockSize   = 512
+	bufferSize  = 1536
+	lenMask     = 0xfffff
+	rxByteMax   = 0x100000
+	txBufferMax = 0x1000
+
+	maxPending = 16
+)
+
+// ESPHosted represents an ESP-Hosted Wi-Fi co-processor instance.
+type ESPHosted struct {
+	sync.Mutex
+
+	function *sdio.Function
+
+	rxBytes   uint32
+	txBuffers uint32
+	seq       uint16
+
+	// received frames not yet returned
+	pending [][]byte
+	// received control messages
+	control [][]byte
+}
+
+// Match returns whether the argument function is an ESP-Hosted SDIO function.
+func (hw *ESPHosted) Match(f *sdio.Function) bool {
+	return f.Number == 1 && f.Card.Vendor == VENDOR_ID &&
+		(f.Card.Device == DEVICE_ID_1 || f.Card.Device == DEVICE_ID_2)
+}
+
+// Probe initializes the ESP-Hosted SDIO function and opens the data path.
+func (hw *ESPHosted) Probe(f *sdio.Function) (err error) {
+	hw.Lock()
+	defer hw.Unlock()
+
+	if err = f.Enable(0); err != nil {
+		return
+	}
+
+	if err = f.SetBlockSize(blockSize); err != nil {
+		return
+	}
+
+	hw.function = f
+	hw.rxBytes = 0
+	hw.txBuffers = 0
+
+	return hw.writeReg(HOST_TO_SLAVE, 1<<OPEN_DATA_PATH)
+}
+
+func (hw *ESPHosted) readReg(addr uint32) (val uint32, err error) {
+	buf := make([]byte, 4)
+
+	if err = hw.function.Read(addr&ADDRESS_MASK, true, buf); err != nil {
+		return
+	}
+
+	return binary.LittleEndian.Uint32(buf), nil
+}
+
+func (hw *ESPHosted) writeReg(addr uint32, val uint32) (err error) {
+	return hw.function.Write(addr&ADDRESS_MASK, true, binary.LittleEndian.AppendUint32(nil, val))
+}
+
+func checksum(buf []byte) (sum uint16) {
+	for _, b := range buf {
+		sum += uint16(b)
+	}
+
+	return
+}
+
+// receive reads all pending data from the slave and queues the received
+// packets.
+func (hw *ESPHosted) receive() (err error) {
+	val, err := hw.readReg(PACKET_LEN)
+
+	if err != nil {
+		return
+	}
+
+	size := ((val & lenMask) - hw.rxBytes) % rxByteMax
+
+	if size == 0 {
+		return
+	}
+
+	// the slave expects reads ending at CMD53_END_ADDR, rounded to full
+	// blocks
+	n := (size + blockSize - 1) / blockSize * blockSize
+	buf := make([]byte, n)
+
+	if err = hw.function.Read(CMD53_END_ADDR-n, true, buf); err != nil {
+		return
+	}
+
+	hw.rxBytes = (hw.rxBytes + size) % rxByteMax
+
+	hw.parse(buf[:size])
+
+	return
+}
+
+// parse splits received data into packets, each aligned to a slave buffer.
+func (hw *ESPHosted) parse(buf []byte) {
+	for len(buf) >= HeaderSize {
+		ifType := buf[0] & 0xf
+		length := int(binary.LittleEndian.Uint16(buf[2:]))
+		offset := int(binary.LittleEndian.Uint16(buf[4:]))
+
+		if length == 0 || offset < HeaderSize || offset+length > len(buf) {
+			return
+		}
+
+		pkt := append([]byte{}, buf[offset:offset+length]...)
+
+		switch ifType {
+		case STA_IF:
+			if len(hw.pending) < maxPending {
+				hw.pending = append(hw.pending, pkt)
+			}
+		case SERIAL_IF:
+			if len(hw.control) < maxPending {
+				hw.control = append(hw.control, pkt)
+			}
+		}
+
+		next := (offset + length + bufferSize - 1) / bufferSize * bufferSize
+
+		if next >= len(buf) {
+			return
+		}
+
+		buf = buf[next:]
+	}
+}
+
+// send transmits a single packet on the argument interface.
+func (hw *ESPHosted) send(ifType uint8, payload []byte) (err error) {
+	if hw.function == nil {
+		return errors.New("device not initialized")
+	}
+
+	if len(payload) == 0 || HeaderSize+len(payload) > bufferSize {
+		return errors.New("invalid packet size")
+	}
+
+	buf := make([]byte, HeaderSize+len(payload))
+	buf[0] = ifType
+	binary.LittleEndian.PutUint16(buf[2:], uint16(len(payload)))
+	binary.LittleEndian.PutUint16(buf[4:], HeaderSize)
+	binary.LittleEndian.PutUint16(buf[8:], hw.seq)
+	copy(buf[HeaderSize:], payload)
+	binary.LittleEndian.PutUint16(buf[6:], checksum(buf))
+
+	val, err := hw.readReg(TOKEN_RDATA)
+
+	if err != nil {
+		return
+	}
+
+	available := ((val>>16)&(txBufferMax-1) - hw.txBuffers) % txBufferMax
+
+	if available == 0 {
+		return errors.New("no buffers available")
+	}
+
+	if err = hw.function.Write(CMD53_END_ADDR-uint32(len(buf)), true, buf); err != nil {
+		return
+	}
+
+	hw.txBuffers = (hw.txBuffers + 1) % txBufferMax
+	hw.seq++
+
+	return
+}
+
+// Rx receives a single Ethernet frame from the Wi-Fi station interface, nil
+// is returned when no frame is available.
+func (hw *ESPHosted) Rx() (buf []byte) {
+	hw.Lock()
+	defer hw.Unlock()
+
+	if hw.function == nil {
+		return
+	}
+
+	if len(hw.pending) == 0 {
+		hw.receive()
+	}
+
+	if len(hw.pending) > 0 {
+		buf = hw.pending[0]
+		hw.pending = hw.pending[1:]
+	}
+
+	return
+}
+
+// Tx transmits a single Ethernet frame on the Wi-Fi station interface.
+func (hw *ESPHosted) Tx(buf []byte) {
+	hw.Lock()
+	defer hw.Unlock()
+
+	hw.send(STA_IF, buf)
+}
+
+// ReadControl returns a single control message received on the serial
+// interface, nil is returned when no message is available.
+func (hw *ESPHosted) ReadControl() (buf []byte) {
+	hw.Lock()
+	defer hw.Unlock()
+
+	if hw.function == nil {
+		return
+	}
+
+	if len(hw.control) == 0 {
+		hw.receive()
+	}
+
+	if len(hw.control) > 0 {
+		buf = hw.control[0]
+		hw.control = hw.control[1:]
+	}
+
+	return
+}
+
+// WriteControl transmits a single control message on the serial interface.
+func (hw *ESPHosted) WriteControl(buf []byte) (err error) {
+	hw.Lock()
+	defer hw.Unlock()
+
+	return hw.send(SERIAL_IF, buf)
+}
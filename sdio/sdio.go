@@ -0,0 +1,463 @@
+// SDIO function driver framework
+// https://github.com/karlo195/tamago
+//
+// Copyright (c) The TamaGo Authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+// Package sdio implements a framework for Secure Digital Input Output (SDIO)
+// card function drivers, adopting the following reference specifications:
+//   - SDIO Simplified Specification Version 3.00
+//
+// Cards are accessed through any host controller supporting SDIO register
+// access commands (e.g. usdhc.USDHC), their functions are enumerated and
+// bound to matching drivers:
+//
+//	if err := imx6ul.USDHC2.Detect(); err != nil {
+//		return err
+//	}
+//
+//	card := &sdio.Card{Host: imx6ul.USDHC2}
+//
+//	if err := card.Init(); err != nil {
+//		return err
+//	}
+//
+//	wifi := &esphosted.ESPHosted{}
+//
+//	if err := card.Bind(wifi); err != nil {
+//		return err
+//	}
+//
+// This package is only meant to be used with `GOOS=tamago` as supported by the
+// TamaGo framework for bare metal Go, see https://github.com/karlo195/tamago.
+package sdio
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Card Common Control Registers (CCCR)
+const (
+	CCCR_REVISION   = 0x00
+	CCCR_IO_ENABLE  = 0x02
+	CCCR_IO_READY   = 0x03
+	CCCR_INT_ENABLE = 0x04
+	CCCR_INT_MASTER = 0
+	CCCR_INT_PEND   = 0x05
+	CCCR_IO_ABORT   = 0x06
+	CCCR_CIS        = 0x09
+)
+
+// Function Basic Registers (FBR)
+const (
+	FBR_SIZE       = 0x100
+	FBR_INTERFACE  = 0x00
+	FBR_CIS        = 0x09
+	FBR_BLOCK_SIZE = 0x10
+)
+
+// Card Information Structure (CIS) tuples
+const (
+	CISTPL_NULL   = 0x00
+	CISTPL_MANFID = 0x20
+	CISTPL_FUNCE  = 0x22
+	CISTPL_END    = 0xff
+)
+
+// MaxFunctions is the maximum number of I/O functions on a card.
+const MaxFunctions = 7
+
+// DefaultEnableTimeout is the default function enable timeout.
+const DefaultEnableTimeout = 1 * time.Second
+
+// maxTuples limits CIS parsing on malformed tuple chains.
+const maxTuples = 64
+
+// Host represents an SDIO host controller with a detected and selected
+// card, matching the NXP uSDHC driver (see soc/nxp/usdhc) API.
+type Host interface {
+	// ReadDirect reads a single function register (CMD52).
+	ReadDirect(fn int, addr uint32) (val uint8, err error)
+	// WriteDirect writes a single function register (CMD52).
+	WriteDirect(fn int, addr uint32, val uint8) (err error)
+	// ReadExtended reads from a function (CMD53), in block mode when
+	// the block size is not zero and in byte mode otherwise.
+	ReadExtended(fn int, addr uint32, incr bool, blockSize int, buf []byte) (err error)
+	// WriteExtended writes to a function (CMD53), in block mode when the
+	// block size is not zero and in byte mode otherwise.
+	WriteExtended(fn int, addr uint32, incr bool, blockSize int, buf []byte) (err error)
+}
+
+// Driver represents an SDIO function driver.
+type Driver interface {
+	// Match returns whether the driver supports the argument function.
+	Match(f *Function) bool
+	// Probe initializes the driver for the argument function.
+	Probe(f *Function) error
+}
+
+// Card represents an SDIO card.
+type Card struct {
+	sync.Mutex
+
+	// Host is the host controller to which the card is connected.
+	Host Host
+
+	// Revision is the CCCR/SDIO revision register value.
+	Revision uint8
+	// Vendor is the card manufacturer code (CISTPL_MANFID).
+	Vendor uint16
+	// Device is the card manufacturer information (CISTPL_MANFID).
+	Device uint16
+	// Functions is the list of enumerated I/O functions.
+	Functions []*Function
+}
+
+// Function represents an SDIO card I/O function.
+type Function struct {
+	// Card is the parent card.
+	Card *Card
+	// Number is the function number (1-7).
+	Number int
+	// Interface is the standard SDIO function interface code.
+	Interface uint8
+	// Vendor is the function manufacturer code (CISTPL_MANFID).
+	Vendor uint16
+	// Device is the function manufacturer information (CISTPL_MANFID).
+	Device uint16
+	// BlockSize is the function block size, see [Function.SetBlockSize].
+	BlockSize int
+	// Driver is the bound function driver.
+	Driver Driver
+}
+
+func (c *Card) read24(fn int, addr uint32) (val uint32, err error) {
+	for i := uint32(0); i < 3; i++ {
+		b, err := c.Host.ReadDirect(fn, addr+i)
+
+		if err != nil {
+			return 0, err
+		}
+
+		val |= uint32(b) << (8 * i)
+	}
+
+	return
+}
+
+// parseCIS walks the Card Information Structure tuple chain at the argument
+// address and returns the manufacturer identification.
+func (c *Card) parseCIS(ptr uint32) (vendor uint16, device uint16, err error) {
+	for i := 0; i < maxTuples; i++ {
+		code, err := c.Host.ReadDirect(0, ptr)
+
+		if err != nil {
+			return 0, 0, err
+		}
+
+		switch code {
+		case CISTPL_END:
+			return vendor, device, nil
+		case CISTPL_NULL:
+			ptr++
+			continue
+		}
+
+		link, err := c.Host.ReadDirect(0, ptr+1)
+
+		if err != nil {
+			return 0, 0, err
+		}
+
+		if code == CISTPL_MANFID && link >= 4 {
+			buf := make([]byte, 4)
+
+			for j := range buf {
+				if buf[j], err = c.Host.ReadDirect(0, ptr+2+uint32(j)); err != nil {
+					return 0, 0, err
+				}
+			}
+
+			vendor = binary.LittleEndian.Uint16(buf[0:])
+			device = binary.LittleEndian.Uint16(buf[2:])
+		}
+
+		if link == 0xff {
+			break
+		}
+
+		ptr += 2 + uint32(link)
+	}
+
+	return
+}
+
+// Init reads the card common registers and enumerates its I/O functions.
+func (c *Card) Init() (err error) {
+	c.Lock()
+	defer c.Unlock()
+
+	if c.Host == nil {
+		return errors.New("invalid SDIO card instance")
+	}
+
+	if c.Revision, err = c.Host.ReadDirect(0, CCCR_REVISION); err != nil {
+		return
+	}
+
+	cis, err := c.read24(0, CCCR_CIS)
+
+	if err != nil {
+		return
+	}
+
+	if c.Vendor, c.Device, err = c.parseCIS(cis); err != nil {
+		return
+	}
+
+	c.Functions = nil
+
+	for n := 1; n <= MaxFunctions; n++ {
+		fbr := uint32(n * FBR_SIZE)
+
+		if cis, err = c.read24(0, fbr+FBR_CIS); err != nil {
+			return
+		}
+
+		// unimplemented functions have no CIS
+		if cis == 0 {
+			continue
+		}
+
+		f := &Function{
+			Card:   c,
+			Number: n,
+		}
+
+		if f.Interface, err = c.Host.ReadDirect(0, fbr+FBR_INTERFACE); err != nil {
+			return
+		}
+
+		f.Interface &= 0xf
+
+		if f.Vendor, f.Device, err = c.parseCIS(cis); err != nil {
+			return
+		}
+
+		c.Functions = append(c.Functions, f)
+	}
+
+	return
+}
+
+// Bind probes, for each enumerated function, the first matching driver
+// among the argument ones.
+func (c *Card) Bind(drivers ...Driver) (err error) {
+	c.Lock()
+	functions := c.Functions
+	c.Unlock()
+
+	for _, f := range functions {
+		if f.Driver != nil {
+			continue
+		}
+
+		for _, d := range drivers {
+			if !d.Match(f) {
+				continue
+			}
+
+			if err = d.Probe(f); err != nil {
+				return fmt.Errorf("function %d, %v", f.Number, err)
+			}
+
+			f.Driver = d
+			break
+		}
+	}
+
+	return
+}
+
+func (f *Function) setBit(addr uint32, on bool) (err error) {
+	val, err := f.Card.Host.ReadDirect(0, addr)
+
+	if err != nil {
+		return
+	}
+
+	if on {
+		val |= 1 << f.Number
+	} else {
+		val &^= 1 << f.Number
+	}
+
+	return f.Card.Host.WriteDirect(0, addr, val)
+}
+
+// Enable enables the function and waits, up to the argument timeout, for it
+// to be ready (DefaultEnableTimeout is used when zero).
+func (f *Function) Enable(timeout time.Duration) (err error) {
+	f.Card.Lock()
+	defer f.Card.Unlock()
+
+	if timeout == 0 {
+		timeout = DefaultEnableTimeout
+	}
+
+	if err = f.setBit(CCCR_IO_ENABLE, true); err != nil {
+		return
+	}
+
+	start := time.Now()
+
+	for {
+		ready, err := f.Card.Host.ReadDirect(0, CCCR_IO_READY)
+
+		if err != nil {
+			return err
+		}
+
+		if ready&(1<<f.Number) != 0 {
+			return nil
+		}
+
+		if time.Since(start) > timeout {
+			return errors.New("function enable timeout")
+		}
+
+		time.Sleep(1 * time.Millisecond)
+	}
+}
+
+// Disable disables the function.
+func (f *Function) Disable() (err error) {
+	f.Card.Lock()
+	defer f.Card.Unlock()
+
+	return f.setBit(CCCR_IO_ENABLE, false)
+}
+
+// SetBlockSize configures the function block size for block mode transfers.
+func (f *Function) SetBlockSize(size int) (err error) {
+	if size <= 0 || size > 2048 {
+		return errors.New("invalid block size")
+	}
+
+	f.Card.Lock()
+	defer f.Card.Unlock()
+
+	fbr := uint32(f.Number * FBR_SIZE)
+
+	if err = f.Card.Host.WriteDirect(0, fbr+FBR_BLOCK_SIZE, uint8(size)); err != nil {
+		return
+	}
+
+	if err = f.Card.Host.WriteDirect(0, fbr+FBR_BLOCK_SIZE+1, uint8(size>>8)); err != nil {
+		return
+	}
+
+	f.BlockSize = size
+
+	return
+}
+
+// EnableInterrupt enables, or disables, the function interrupt, the
+// interrupt master enable is set whenever a function interrupt is enabled.
+func (f *Function) EnableInterrupt(on bool) (err error) {
+	f.Card.Lock()
+	defer f.Card.Unlock()
+
+	val, err := f.Card.Host.ReadDirect(0, CCCR_INT_ENABLE)
+
+	if err != nil {
+		return
+	}
+
+	if on {
+		val |= 1<<f.Number | 1<<CCCR_INT_MASTER
+	} else {
+		val &^= 1 << f.Number
+	}
+
+	if val&^(1<<CCCR_INT_MASTER) == 0 {
+		val = 0
+	}
+
+	return f.Card.Host.WriteDirect(0, CCCR_INT_ENABLE, val)
+}
+
+// InterruptPending returns whether the function interrupt is pending.
+func (f *Function) InterruptPending() bool {
+	val, err := f.Card.Host.ReadDirect(0, CCCR_INT_PEND)
+	return err == nil && val&(1<<f.Number) != 0
+}
+
+// ReadRegister reads a single function register.
+func (f *Function) ReadRegister(addr uint32) (uint8, error) {
+	return f.Card.Host.ReadDirect(f.Number, addr)
+}
+
+// WriteRegister writes a single function register.
+func (f *Function) WriteRegister(addr uint32, val uint8) error {
+	return f.Card.Host.WriteDirect(f.Number, addr, val)
+}
+
+// transfer splits transfers in block mode transfers, for all full blocks, and
+// byte mode transfers for the remainder.
+func (f *Function) transfer(write bool, addr uint32, incr bool, buf []byte) (err error) {
+	size := f.BlockSize
+
+	if size == 0 {
+		return errors.New("block size not configured")
+	}
+
+	fn := f.Card.Host.ReadExtended
+
+	if write {
+		fn = f.Card.Host.WriteExtended
+	}
+
+	for len(buf) > 0 {
+		var n int
+		var blockSize int
+
+		if len(buf) >= size {
+			// block mode, up to 511 blocks
+			n = min(len(buf)/size, 511) * size
+			blockSize = size
+		} else {
+			// byte mode
+			n = min(len(buf), 512)
+		}
+
+		if err = fn(f.Number, addr, incr, blockSize, buf[:n]); err != nil {
+			return
+		}
+
+		if incr {
+			addr += uint32(n)
+		}
+
+		buf = buf[n:]
+	}
+
+	return
+}
+
+// Read reads from consecutive (incr) or fixed (e.g. FIFO) function
+// addresses.
+func (f *Function) Read(addr uint32, incr bool, buf []byte) error {
+	return f.transfer(false, addr, incr, buf)
+}
+
+// Write writes to consecutive (incr) or fixed (e.g. FIFO) function addresses.
+func (f *Function) Write(addr uint32, incr bool, buf []byte) error {
+	return f.transfer(true, addr, incr, buf)
+}
@@ -0,0 +1,217 @@
+// Serial port I/O adapter
+// https://github.com/karlo195/tamago
+//
+// Copyright (c) The TamaGo Authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+// Package serial implements an io.ReadWriter adapter for character oriented
+// UART drivers, with configurable blocking semantics and deadlines, to
+// interface serial ports with standard library or third-party packages (e.g.
+// bufio, terminals, framed protocols):
+//
+//	port := &serial.Port{
+//		UART: microvm.UART0,
+//	}
+//
+//	port.SetReadDeadline(time.Now().Add(5 * time.Second))
+//	line, err := bufio.NewReader(port).ReadString('\n')
+//
+// This package is only meant to be used with `GOOS=tamago` as supported by the
+// TamaGo framework for bare metal Go, see https://github.com/karlo195/tamago.
+package serial
+
+import (
+	"errors"
+	"os"
+	"sync"
+	"time"
+)
+
+// DefaultPollInterval is the default receive polling interval.
+const DefaultPollInterval = 1 * time.Millisecond
+
+// ErrNoData is returned by non-blocking reads when no character is available.
+var ErrNoData = errors.New("no data available")
+
+// UART represents a character oriented serial port driver, matching the API of
+// the UART drivers in this module (e.g. soc/intel/uart, soc/nxp/uart,
+// arm/pl011).
+type UART interface {
+	// Tx transmits a single character.
+	Tx(c byte)
+	// Rx receives a single character, if available.
+	Rx() (c byte, valid bool)
+}
+
+// Notifier represents a UART driver able to signal received characters (e.g.
+// soc/intel/uart in interrupt mode), sparing receive polling.
+type Notifier interface {
+	// Ready returns a channel signaled on character reception, or nil if
+	// unavailable.
+	Ready() <-chan struct{}
+}
+
+// Port represents a serial port adapter implementing io.ReadWriter.
+type Port struct {
+	// UART is the serial port driver.
+	UART UART
+
+	// NonBlocking disables blocking reads, returning ErrNoData when no
+	// character is available.
+	NonBlocking bool
+
+	// PollInterval is the receive polling interval used when the driver
+	// does not implement Notifier (default DefaultPollInterval).
+	PollInterval time.Duration
+
+	mu            sync.Mutex
+	readDeadline  time.Time
+	writeDeadline time.Time
+}
+
+// SetDeadline sets the read and write deadlines, a zero value disables them.
+func (p *Port) SetDeadline(t time.Time) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.readDeadline = t
+	p.writeDeadline = t
+
+	return nil
+}
+
+// SetReadDeadline sets the deadline for blocking reads, a zero value disables
+// it.
+func (p *Port) SetReadDeadline(t time.Time) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.readDeadline = t
+
+	return nil
+}
+
+// SetWriteDeadline sets the deadline for writes, a zero value disables it.
+//
+// The deadline is checked between characters, as transmission of a single
+// character cannot be interrupted (e.g. while waiting for hardware flow
+// control).
+func (p *Port) SetWriteDeadline(t time.Time) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.writeDeadline = t
+
+	return nil
+}
+
+func (p *Port) deadlines() (r time.Time, w time.Time) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.readDeadline, p.writeDeadline
+}
+
+// receive fills the argument buffer with available characters.
+func (p *Port) receive(buf []byte) (n int) {
+	var valid bool
+
+	for n = 0; n < len(buf); n++ {
+		if buf[n], valid = p.UART.Rx(); !valid {
+			break
+		}
+	}
+
+	return
+}
+
+// Read reads available characters into the argument buffer.
+//
+// Unless NonBlocking is set, the function blocks until at least one character
+// is received or the read deadline expires, in which case
+// os.ErrDeadlineExceeded is returned.
+func (p *Port) Read(buf []byte) (n int, err error) {
+	if p.UART == nil {
+		return 0, errors.New("invalid serial port")
+	}
+
+	if len(buf) == 0 {
+		return
+	}
+
+	if n = p.receive(buf); n > 0 {
+		return
+	}
+
+	if p.NonBlocking {
+		return 0, ErrNoData
+	}
+
+	deadline, _ := p.deadlines()
+
+	var ready <-chan struct{}
+
+	if notifier, ok := p.UART.(Notifier); ok {
+		ready = notifier.Ready()
+	}
+
+	interval := p.PollInterval
+
+	if interval <= 0 {
+		interval = DefaultPollInterval
+	}
+
+	for {
+		if !deadline.IsZero() && !time.Now().Before(deadline) {
+			return 0, os.ErrDeadlineExceeded
+		}
+
+		wait := interval
+
+		if ready != nil {
+			// the deadline still needs to be checked on expiration
+			wait = time.Until(deadline)
+
+			if deadline.IsZero() || wait > time.Second {
+				wait = time.Second
+			}
+		} else if !deadline.IsZero() {
+			wait = min(wait, time.Until(deadline))
+		}
+
+		timer := time.NewTimer(wait)
+
+		select {
+		case <-ready:
+		case <-timer.C:
+		}
+
+		timer.Stop()
+
+		if n = p.receive(buf); n > 0 {
+			return
+		}
+	}
+}
+
+// Write transmits the argument buffer, returning os.ErrDeadlineExceeded if the
+// write deadline expires before completion.
+func (p *Port) Write(buf []byte) (n int, err error) {
+	if p.UART == nil {
+		return 0, errors.New("invalid serial port")
+	}
+
+	_, deadline := p.deadlines()
+
+	for n = 0; n < len(buf); n++ {
+		if !deadline.IsZero() && !time.Now().Before(deadline) {
+			return n, os.ErrDeadlineExceeded
+		}
+
+		p.UART.Tx(buf[n])
+	}
+
+	return
+}
@@ -0,0 +1,93 @@
+// BCM2835 SoC GPIO input capture support
+// https://github.com/karlo195/tamago
+//
+// Copyright (c) the bcm2835 package authors
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+package bcm2835
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/karlo195/tamago/capture"
+)
+
+// GPIOCapture represents an input capture channel on a GPIO line, it
+// implements [capture.Channel] using the GPIO event detect logic timestamped
+// with the system timer.
+//
+// Events are detected in hardware but timestamped when polled, the capture
+// resolution therefore depends on how frequently [GPIOCapture.Capture] is
+// invoked. Edges occurring between two polls are coalesced into one.
+type GPIOCapture struct {
+	sync.Mutex
+
+	// GPIO line number
+	Line int
+	// Edge selects the captured signal transitions.
+	Edge capture.Edge
+
+	gpio *GPIO
+}
+
+// Init configures the GPIO line as input and enables edge detection.
+func (hw *GPIOCapture) Init() (err error) {
+	hw.Lock()
+	defer hw.Unlock()
+
+	if hw.Edge&capture.Both == 0 {
+		return errors.New("invalid edge")
+	}
+
+	if hw.gpio, err = NewGPIO(hw.Line); err != nil {
+		return
+	}
+
+	hw.gpio.In()
+	hw.gpio.EnableEdgeDetect(hw.Edge&capture.Rising != 0, hw.Edge&capture.Falling != 0)
+
+	return
+}
+
+// Disable disables edge detection on the GPIO line.
+func (hw *GPIOCapture) Disable() {
+	hw.Lock()
+	defer hw.Unlock()
+
+	if hw.gpio != nil {
+		hw.gpio.EnableEdgeDetect(false, false)
+	}
+}
+
+// Capture returns a sample if an edge has been detected since the previous
+// invocation, the transition direction is derived from the current signal
+// level when capturing both edges.
+func (hw *GPIOCapture) Capture() (s capture.Sample, ok bool) {
+	hw.Lock()
+	defer hw.Unlock()
+
+	if hw.gpio == nil || !hw.gpio.EventDetected() {
+		return
+	}
+
+	s.Ticks = uint64(read_systimer())
+
+	switch hw.Edge {
+	case capture.Rising:
+		s.Rising = true
+	case capture.Falling:
+		s.Rising = false
+	default:
+		s.Rising = hw.gpio.Value()
+	}
+
+	return s, true
+}
+
+// Frequency returns the capture timestamp frequency in Hz.
+func (hw *GPIOCapture) Frequency() uint32 {
+	return SysTimerFreq
+}
@@ -0,0 +1,119 @@
+// BCM2835 SoC FrameBuffer support
+// https://github.com/karlo195/tamago
+//
+// Copyright (c) the bcm2835 package authors
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+package framebuffer
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/karlo195/tamago/display"
+	"github.com/karlo195/tamago/dma"
+	"github.com/karlo195/tamago/soc/bcm2835"
+)
+
+// VideoCore pixel orders
+const (
+	PIXEL_ORDER_BGR = 0
+	PIXEL_ORDER_RGB = 1
+)
+
+// Display allocates a VideoCore framebuffer with the argument resolution and
+// pixel format (display.RGB565LE or display.XRGB8888), returning it as memory
+// mapped display framebuffer.
+func Display(width uint32, height uint32, format display.Format) (fb *display.Framebuffer, err error) {
+	var depth uint32
+
+	switch format {
+	case display.RGB565LE:
+		depth = 16
+	case display.XRGB8888:
+		depth = 32
+	default:
+		return nil, errors.New("unsupported pixel format")
+	}
+
+	size := make([]byte, bcm2835.VC_FB_SET_PHYSICAL_SIZE_LEN)
+	binary.LittleEndian.PutUint32(size[0:], width)
+	binary.LittleEndian.PutUint32(size[4:], height)
+
+	bpp := make([]byte, bcm2835.VC_FB_SET_DEPTH_LEN)
+	binary.LittleEndian.PutUint32(bpp, depth)
+
+	order := make([]byte, bcm2835.VC_FB_SET_PIXEL_ORDER_LEN)
+	binary.LittleEndian.PutUint32(order, PIXEL_ORDER_BGR)
+
+	alloc := make([]byte, bcm2835.VC_FB_ALLOC_BUFFER_LEN)
+	binary.LittleEndian.PutUint32(alloc, 16)
+
+	msg := &bcm2835.MailboxMessage{
+		Tags: []bcm2835.MailboxTag{
+			{ID: bcm2835.VC_FB_SET_PHYSICAL_SIZE, Buffer: size},
+			{ID: bcm2835.VC_FB_SET_VIRTUAL_SIZE, Buffer: append([]byte{}, size...)},
+			{ID: bcm2835.VC_FB_SET_DEPTH, Buffer: bpp},
+			{ID: bcm2835.VC_FB_SET_PIXEL_ORDER, Buffer: order},
+			{ID: bcm2835.VC_FB_ALLOC_BUFFER, Buffer: alloc},
+			{ID: bcm2835.VC_FB_GET_PITCH, Buffer: make([]byte, bcm2835.VC_FB_GET_PITCH_LEN)},
+		},
+	}
+
+	bcm2835.Mailbox.Call(bcm2835.VC_CH_PROPERTYTAGS_A_TO_VC, msg)
+
+	if msg.Error() {
+		return nil, errors.New("failed to allocate framebuffer")
+	}
+
+	tag := msg.Tag(bcm2835.VC_FB_SET_DEPTH)
+
+	if tag == nil || len(tag.Buffer) < 4 || binary.LittleEndian.Uint32(tag.Buffer) != depth {
+		return nil, fmt.Errorf("failed to set %d bits per pixel", depth)
+	}
+
+	tag = msg.Tag(bcm2835.VC_FB_ALLOC_BUFFER)
+
+	if tag == nil || len(tag.Buffer) < 8 {
+		return nil, errors.New("failed to allocate framebuffer")
+	}
+
+	// convert the VideoCore bus address to an ARM physical one
+	addr := binary.LittleEndian.Uint32(tag.Buffer[0:]) &^ bcm2835.DRAM_FLAG_NOCACHE
+	n := binary.LittleEndian.Uint32(tag.Buffer[4:])
+
+	tag = msg.Tag(bcm2835.VC_FB_GET_PITCH)
+
+	if tag == nil || len(tag.Buffer) < 4 {
+		return nil, errors.New("failed to get framebuffer pitch")
+	}
+
+	pitch := binary.LittleEndian.Uint32(tag.Buffer)
+
+	if addr == 0 || n < pitch*height {
+		return nil, errors.New("invalid framebuffer allocation")
+	}
+
+	r, err := dma.NewRegion(uint(addr), int(n), false)
+
+	if err != nil {
+		return
+	}
+
+	_, pix := r.Reserve(int(n), 0)
+
+	fb = &display.Framebuffer{
+		Width:  int(width),
+		Height: int(height),
+		Format: format,
+		Stride: int(pitch),
+		Pix:    pix,
+	}
+
+	err = fb.Init()
+
+	return
+}
@@ -25,6 +25,9 @@ const (
 	GPSET0    = GPIO_BASE + 0x1c
 	GPCLR0    = GPIO_BASE + 0x28
 	GPLEV0    = GPIO_BASE + 0x34
+	GPEDS0    = GPIO_BASE + 0x40
+	GPREN0    = GPIO_BASE + 0x4c
+	GPFEN0    = GPIO_BASE + 0x58
 	GPPUD     = GPIO_BASE + 0x94
 	GPPUDCLK0 = GPIO_BASE + 0x98
 )
@@ -120,6 +123,37 @@ func (gpio *GPIO) Value() (high bool) {
 	return (reg.Read(register)>>shift)&0x1 != 0
 }
 
+// EnableEdgeDetect configures rising and/or falling edge detection on the
+// line, events are reported by [GPIO.EventDetected].
+func (gpio *GPIO) EnableEdgeDetect(rising bool, falling bool) {
+	// The detect enable registers are shared between GPIO pins.
+	gpmu.Lock()
+	defer gpmu.Unlock()
+
+	shift := gpio.num % 32
+
+	reg.SetTo(PeripheralAddress(GPREN0+4*uint32(gpio.num/32)), shift, rising)
+	reg.SetTo(PeripheralAddress(GPFEN0+4*uint32(gpio.num/32)), shift, falling)
+
+	// clear stale events
+	reg.Write(PeripheralAddress(GPEDS0+4*uint32(gpio.num/32)), 1<<shift)
+}
+
+// EventDetected returns and clears the line event detect status
+// (p96, GPIO Event Detect Status Registers, BCM2835 ARM Peripherals).
+func (gpio *GPIO) EventDetected() bool {
+	register := PeripheralAddress(GPEDS0 + 4*uint32(gpio.num/32))
+	shift := uint32(gpio.num % 32)
+
+	if (reg.Read(register)>>shift)&0x1 == 0 {
+		return false
+	}
+
+	reg.Write(register, 1<<shift)
+
+	return true
+}
+
 // PullUpDown controls the pull-up or pull-down state of the line.
 //
 // The pull-up / pull-down state persists across power-down state
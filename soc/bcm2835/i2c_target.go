@@ -0,0 +1,191 @@
+// BCM2835 SoC I2C target (BSC slave) driver
+// https://github.com/karlo195/tamago
+//
+// Copyright (c) the bcm2835 package authors
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+package bcm2835
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/karlo195/tamago/internal/reg"
+)
+
+// BSC slave registers (p160, 11.2 Registers, BCM2835 ARM Peripherals)
+const (
+	BSC_SLAVE_BASE = 0x214000
+
+	BSC_SLAVE_DR = BSC_SLAVE_BASE + 0x00
+	DR_DATA      = 0
+
+	BSC_SLAVE_RSR = BSC_SLAVE_BASE + 0x04
+	BSC_SLAVE_SLV = BSC_SLAVE_BASE + 0x08
+
+	BSC_SLAVE_CR = BSC_SLAVE_BASE + 0x0c
+	CR_EN        = 0
+	CR_I2C       = 2
+	CR_BRK       = 7
+	CR_TXE       = 8
+	CR_RXE       = 9
+
+	BSC_SLAVE_FR = BSC_SLAVE_BASE + 0x10
+	FR_RXFE      = 1
+	FR_TXFF      = 2
+	FR_RXBUSY    = 5
+)
+
+// BSC slave GPIO lines (ALT3)
+const (
+	BSC_SLAVE_SDA = 18
+	BSC_SLAVE_SCL = 19
+)
+
+// I2CRegisterFile represents the register file of an I2C target, accessed by
+// the bus controller with register address auto-increment: the first byte
+// written after addressing sets the register pointer, following writes and
+// reads access consecutive registers.
+type I2CRegisterFile interface {
+	// Read returns the value of the register at the argument address.
+	Read(addr uint8) uint8
+	// Write sets the value of the register at the argument address.
+	Write(addr uint8, val uint8)
+}
+
+// I2CTarget represents the BSC slave controller instance, operated in I2C
+// target mode.
+//
+// The controller does not support clock stretching nor report transaction
+// boundaries, therefore register reads are served from a transmit FIFO filled
+// in advance (with registers following the current pointer) and the end of a
+// write transaction is detected when the receiver is found idle by
+// [I2CTarget.Handle], which must therefore be polled more frequently than
+// consecutive bus transactions. Combined register pointer write and read
+// transactions (i.e. with repeated start) are not supported, the
+// pointer must be set in a separate write transaction.
+type I2CTarget struct {
+	sync.Mutex
+
+	// Address is the 7-bit target address.
+	Address uint8
+	// RegisterFile serves bus controller accesses.
+	RegisterFile I2CRegisterFile
+
+	// register pointer
+	ptr uint8
+	// next register address queued for transmission
+	next uint8
+	// write transaction in progress
+	active bool
+
+	dr  uint32
+	fr  uint32
+	cr  uint32
+	rsr uint32
+}
+
+// Init initializes the BSC slave controller in I2C target mode.
+func (hw *I2CTarget) Init() (err error) {
+	hw.Lock()
+	defer hw.Unlock()
+
+	if hw.Address > 0x7f {
+		return errors.New("invalid target address")
+	}
+
+	if hw.RegisterFile == nil {
+		return errors.New("invalid register file")
+	}
+
+	for _, num := range []int{BSC_SLAVE_SDA, BSC_SLAVE_SCL} {
+		gpio, err := NewGPIO(num)
+
+		if err != nil {
+			return err
+		}
+
+		if err = gpio.SelectFunction(GPIO_FN3); err != nil {
+			return err
+		}
+	}
+
+	hw.dr = PeripheralAddress(BSC_SLAVE_DR)
+	hw.fr = PeripheralAddress(BSC_SLAVE_FR)
+	hw.cr = PeripheralAddress(BSC_SLAVE_CR)
+	hw.rsr = PeripheralAddress(BSC_SLAVE_RSR)
+
+	hw.ptr = 0
+	hw.next = 0
+	hw.active = false
+
+	reg.Write(PeripheralAddress(BSC_SLAVE_SLV), uint32(hw.Address))
+	reg.Write(hw.rsr, 0)
+	reg.Write(hw.cr, 1<<CR_EN|1<<CR_I2C|1<<CR_TXE|1<<CR_RXE)
+
+	hw.flush()
+	hw.fill()
+
+	return
+}
+
+// Disable disables the BSC slave controller.
+func (hw *I2CTarget) Disable() {
+	hw.Lock()
+	defer hw.Unlock()
+
+	reg.Write(hw.cr, 1<<CR_BRK)
+	reg.Write(hw.cr, 0)
+}
+
+// flush clears the transmit and receive FIFOs
+func (hw *I2CTarget) flush() {
+	reg.Set(hw.cr, CR_BRK)
+	reg.Clear(hw.cr, CR_BRK)
+
+	hw.next = hw.ptr
+}
+
+// fill queues registers, following the pointer, for transmission
+func (hw *I2CTarget) fill() {
+	for reg.Get(hw.fr, FR_TXFF, 1) == 0 {
+		reg.Write(hw.dr, uint32(hw.RegisterFile.Read(hw.next)))
+		hw.next++
+	}
+}
+
+// Handle processes received bytes and refills the transmit FIFO, it returns
+// whether any byte was received.
+func (hw *I2CTarget) Handle() (handled bool) {
+	hw.Lock()
+	defer hw.Unlock()
+
+	for reg.Get(hw.fr, FR_RXFE, 1) == 0 {
+		val := uint8(reg.Get(hw.dr, DR_DATA, 0xff))
+		handled = true
+
+		if !hw.active {
+			hw.active = true
+			hw.ptr = val
+			continue
+		}
+
+		hw.RegisterFile.Write(hw.ptr, val)
+		hw.ptr++
+	}
+
+	if hw.active && reg.Get(hw.fr, FR_RXBUSY, 1) == 0 && reg.Get(hw.fr, FR_RXFE, 1) == 1 {
+		// end of write transaction, discard stale transmit data
+		hw.active = false
+		hw.flush()
+	}
+
+	// clear overrun/underrun errors
+	reg.Write(hw.rsr, 0)
+
+	hw.fill()
+
+	return
+}
@@ -0,0 +1,274 @@
+// Advanced Configuration and Power Interface (ACPI) support
+// https://github.com/karlo195/tamago
+//
+// Copyright (c) The TamaGo Authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+// Package acpi implements a parser for Advanced Configuration and Power
+// Interface (ACPI) system description tables adopting the following reference
+// specifications:
+//   - Advanced Configuration and Power Interface (ACPI) Specification - Release 6.5
+//
+// This package is only meant to be used with `GOOS=tamago GOARCH=amd64` as
+// supported by the TamaGo framework for bare metal Go, see
+// https://github.com/karlo195/tamago.
+package acpi
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+
+	"github.com/karlo195/tamago/internal/mem"
+)
+
+// Root System Description Pointer search areas
+// (5.2.5.1 Finding the RSDP on IA-PC Systems).
+const (
+	EBDA_POINTER = 0x40e
+	EBDA_SIZE    = 1024
+
+	SEARCH_START = 0xe0000
+	SEARCH_END   = 0xfffff
+	SEARCH_ALIGN = 16
+)
+
+// System description table signatures
+const (
+	FADT_SIGNATURE = "FACP"
+	DSDT_SIGNATURE = "DSDT"
+	SSDT_SIGNATURE = "SSDT"
+)
+
+const (
+	rsdpSignature = "RSD PTR "
+	rsdpLength    = 20
+	xsdpLength    = 36
+
+	headerLength = 36
+)
+
+// Header represents the System Description Table Header
+// (5.2.6 System Description Table Header).
+type Header struct {
+	Signature       [4]byte
+	Length          uint32
+	Revision        uint8
+	Checksum        uint8
+	OEMID           [6]byte
+	OEMTableID      [8]byte
+	OEMRevision     uint32
+	CreatorID       uint32
+	CreatorRevision uint32
+}
+
+// Table represents an ACPI system description table.
+type Table struct {
+	Header
+
+	// Address is the table physical address.
+	Address uint
+	// Data is the table contents, following its header.
+	Data []byte
+}
+
+// ACPI represents the set of system description tables referenced by the
+// Root System Description Pointer (RSDP).
+type ACPI struct {
+	// Revision is the RSDP revision.
+	Revision int
+	// OEMID is the OEM identification string.
+	OEMID string
+
+	// Tables is the list of parsed system description tables.
+	Tables []*Table
+}
+
+func scan(start uint, size int) (addr uint, found bool) {
+	area, err := mem.Read(start, size)
+
+	if err != nil {
+		return
+	}
+
+	for off := 0; off+rsdpLength <= len(area); off += SEARCH_ALIGN {
+		if bytes.HasPrefix(area[off:], []byte(rsdpSignature)) && mem.Checksum(area[off:off+rsdpLength]) {
+			return start + uint(off), true
+		}
+	}
+
+	return
+}
+
+// Find locates the Root System Description Pointer (RSDP) through a scan of
+// the Extended BIOS Data Area (EBDA) and of the BIOS read-only memory area.
+//
+// When the RSDP address is provided by firmware or boot loader handoff (e.g.
+// PVH start information or EFI configuration table), it can be passed to
+// Parse() directly.
+func Find() (addr uint, err error) {
+	if ptr, err := mem.Read(EBDA_POINTER, 2); err == nil {
+		if ebda := uint(binary.LittleEndian.Uint16(ptr)) << 4; ebda != 0 {
+			if addr, found := scan(ebda, EBDA_SIZE); found {
+				return addr, nil
+			}
+		}
+	}
+
+	if addr, found := scan(SEARCH_START, SEARCH_END-SEARCH_START+1); found {
+		return addr, nil
+	}
+
+	return 0, errors.New("RSDP not found")
+}
+
+func parseTable(addr uint) (t *Table, err error) {
+	hdr, err := mem.Read(addr, headerLength)
+
+	if err != nil {
+		return
+	}
+
+	t = &Table{
+		Address: addr,
+	}
+
+	if _, err = binary.Decode(hdr, binary.LittleEndian, &t.Header); err != nil {
+		return
+	}
+
+	if t.Length < headerLength {
+		return nil, errors.New("invalid table length")
+	}
+
+	buf, err := mem.Read(addr, int(t.Length))
+
+	if err != nil {
+		return
+	}
+
+	if !mem.Checksum(buf) {
+		return nil, errors.New("invalid table checksum")
+	}
+
+	t.Data = buf[headerLength:]
+
+	return
+}
+
+// Load parses the system description tables referenced by the Root System
+// Description Pointer (RSDP) at the argument address (e.g. amd64.RSDP()), which
+// is located with Find() when zero.
+func Load(rsdp uint) (a *ACPI, err error) {
+	if rsdp == 0 {
+		if rsdp, err = Find(); err != nil {
+			return
+		}
+	}
+
+	return Parse(rsdp)
+}
+
+// Parse parses the system description tables referenced by the Root System
+// Description Pointer (RSDP) at the argument address (see Find()), the
+// Extended System Description Table (XSDT) is preferred over the Root System
+// Description Table (RSDT) when available.
+func Parse(rsdp uint) (a *ACPI, err error) {
+	buf, err := mem.Read(rsdp, xsdpLength)
+
+	if err != nil {
+		return
+	}
+
+	if !bytes.HasPrefix(buf, []byte(rsdpSignature)) || !mem.Checksum(buf[:rsdpLength]) {
+		return nil, errors.New("invalid RSDP")
+	}
+
+	a = &ACPI{
+		Revision: int(buf[15]),
+		OEMID:    string(bytes.TrimRight(buf[9:15], " \x00")),
+	}
+
+	// 5.2.5.3 Root System Description Pointer (RSDP) Structure
+	sdt := uint(binary.LittleEndian.Uint32(buf[16:]))
+	entrySize := 4
+
+	if a.Revision >= 2 && mem.Checksum(buf[:xsdpLength]) {
+		if xsdt := uint(binary.LittleEndian.Uint64(buf[24:])); xsdt != 0 {
+			sdt = xsdt
+			entrySize = 8
+		}
+	}
+
+	root, err := parseTable(sdt)
+
+	if err != nil {
+		return
+	}
+
+	for off := 0; off+entrySize <= len(root.Data); off += entrySize {
+		var addr uint
+
+		if entrySize == 8 {
+			addr = uint(binary.LittleEndian.Uint64(root.Data[off:]))
+		} else {
+			addr = uint(binary.LittleEndian.Uint32(root.Data[off:]))
+		}
+
+		t, err := parseTable(addr)
+
+		if err != nil {
+			continue
+		}
+
+		a.Tables = append(a.Tables, t)
+	}
+
+	// the DSDT is referenced by the FADT rather than the RSDT/XSDT
+	if dsdt, err := a.parseDSDT(); err == nil {
+		a.Tables = append(a.Tables, dsdt)
+	}
+
+	return
+}
+
+// parseDSDT parses the Differentiated System Description Table referenced by
+// the Fixed ACPI Description Table (5.2.9 Fixed ACPI Description Table
+// (FADT)), the X_DSDT field is preferred over the DSDT one when available.
+func (a *ACPI) parseDSDT() (t *Table, err error) {
+	fadt, err := a.Table(FADT_SIGNATURE)
+
+	if err != nil {
+		return
+	}
+
+	var addr uint
+
+	if len(fadt.Data) >= 112 {
+		addr = uint(binary.LittleEndian.Uint64(fadt.Data[104:]))
+	}
+
+	if addr == 0 && len(fadt.Data) >= 8 {
+		addr = uint(binary.LittleEndian.Uint32(fadt.Data[4:]))
+	}
+
+	if addr == 0 {
+		return nil, errors.New("missing DSDT")
+	}
+
+	return parseTable(addr)
+}
+
+// Table returns the first system description table matching the argument
+// signature (e.g. "APIC").
+func (a *ACPI) Table(signature string) (t *Table, err error) {
+	for _, t := range a.Tables {
+		if string(t.Signature[:]) == signature {
+			return t, nil
+		}
+	}
+
+	return nil, errors.New("table not found")
+}
@@ -0,0 +1,195 @@
+// Advanced Configuration and Power Interface (ACPI) support
+// https://github.com/karlo195/tamago
+//
+// Copyright (c) The TamaGo Authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+package acpi
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+// DMA Remapping Reporting (DMAR) table signature
+const DMAR_SIGNATURE = "DMAR"
+
+// Remapping structure types
+// (Intel® Virtualization Technology for Directed I/O
+// Architecture Specification - 8.2 DMA Remapping Reporting Structure).
+const (
+	DMAR_DRHD = 0
+	DMAR_RMRR = 1
+)
+
+// DMA Remapping Hardware Unit Definition flags
+const (
+	DRHD_INCLUDE_PCI_ALL = 0
+)
+
+// Device scope types
+// (8.3.1 Device Scope Structure).
+const (
+	SCOPE_PCI_ENDPOINT = 1
+	SCOPE_PCI_BRIDGE   = 2
+	SCOPE_IOAPIC       = 3
+	SCOPE_HPET         = 4
+)
+
+// DeviceScope represents a DMAR Device Scope structure.
+type DeviceScope struct {
+	// Type is the device scope type.
+	Type uint8
+	// EnumerationID is the I/O APIC or HPET identifier.
+	EnumerationID uint8
+	// Bus is the start bus number.
+	Bus uint8
+	// Path is the hierarchical list of (device, function) pairs from the
+	// start bus to the device.
+	Path [][2]uint8
+}
+
+// Device returns the device and function numbers of the scope final path
+// entry.
+func (s *DeviceScope) Device() (dev uint8, fn uint8) {
+	if len(s.Path) == 0 {
+		return
+	}
+
+	p := s.Path[len(s.Path)-1]
+
+	return p[0], p[1]
+}
+
+// DRHD represents a DMA Remapping Hardware Unit Definition structure.
+type DRHD struct {
+	// Flags is the DRHD flags field.
+	Flags uint8
+	// Segment is the PCI segment number.
+	Segment uint16
+	// Address is the remapping hardware register set base address.
+	Address uint64
+	// Scopes is the list of devices within the unit scope.
+	Scopes []DeviceScope
+}
+
+// IncludeAll returns whether the unit covers all PCI devices, within its
+// segment, not covered by other units.
+func (d *DRHD) IncludeAll() bool {
+	return d.Flags&(1<<DRHD_INCLUDE_PCI_ALL) != 0
+}
+
+// RMRR represents a Reserved Memory Region Reporting structure, describing
+// memory which devices within its scope might access at any time (e.g. USB
+// legacy emulation).
+type RMRR struct {
+	// Segment is the PCI segment number.
+	Segment uint16
+	// Base is the region base address.
+	Base uint64
+	// Limit is the region limit (last byte) address.
+	Limit uint64
+	// Scopes is the list of devices using the region.
+	Scopes []DeviceScope
+}
+
+// DMAR represents the DMA Remapping Reporting table.
+type DMAR struct {
+	// HostAddressWidth is the maximum DMA physical address width.
+	HostAddressWidth int
+	// Flags is the DMAR flags field.
+	Flags uint8
+
+	// Units is the list of remapping hardware units.
+	Units []DRHD
+	// Reserved is the list of reserved memory regions.
+	Reserved []RMRR
+}
+
+func parseScopes(buf []byte) (scopes []DeviceScope, err error) {
+	for len(buf) >= 6 {
+		n := int(buf[1])
+
+		if n < 6 || n > len(buf) || n%2 != 0 {
+			return nil, errors.New("invalid DMAR device scope")
+		}
+
+		s := DeviceScope{
+			Type:          buf[0],
+			EnumerationID: buf[4],
+			Bus:           buf[5],
+		}
+
+		for p := buf[6:n]; len(p) >= 2; p = p[2:] {
+			s.Path = append(s.Path, [2]uint8{p[0], p[1]})
+		}
+
+		scopes = append(scopes, s)
+		buf = buf[n:]
+	}
+
+	return
+}
+
+// DMAR returns the parsed DMA Remapping Reporting table.
+func (a *ACPI) DMAR() (d *DMAR, err error) {
+	t, err := a.Table(DMAR_SIGNATURE)
+
+	if err != nil {
+		return
+	}
+
+	buf := t.Data
+
+	if len(buf) < 12 {
+		return nil, errors.New("invalid DMAR length")
+	}
+
+	d = &DMAR{
+		HostAddressWidth: int(buf[0]) + 1,
+		Flags:            buf[1],
+	}
+
+	for buf = buf[12:]; len(buf) >= 4; {
+		typ := binary.LittleEndian.Uint16(buf[0:])
+		n := int(binary.LittleEndian.Uint16(buf[2:]))
+
+		if n < 4 || n > len(buf) {
+			return nil, errors.New("invalid DMAR entry")
+		}
+
+		e := buf[:n]
+		buf = buf[n:]
+
+		switch {
+		case typ == DMAR_DRHD && n >= 16:
+			u := DRHD{
+				Flags:   e[4],
+				Segment: binary.LittleEndian.Uint16(e[6:]),
+				Address: binary.LittleEndian.Uint64(e[8:]),
+			}
+
+			if u.Scopes, err = parseScopes(e[16:]); err != nil {
+				return nil, err
+			}
+
+			d.Units = append(d.Units, u)
+		case typ == DMAR_RMRR && n >= 24:
+			r := RMRR{
+				Segment: binary.LittleEndian.Uint16(e[6:]),
+				Base:    binary.LittleEndian.Uint64(e[8:]),
+				Limit:   binary.LittleEndian.Uint64(e[16:]),
+			}
+
+			if r.Scopes, err = parseScopes(e[24:]); err != nil {
+				return nil, err
+			}
+
+			d.Reserved = append(d.Reserved, r)
+		}
+	}
+
+	return
+}
@@ -0,0 +1,223 @@
+// Advanced Configuration and Power Interface (ACPI) support
+// https://github.com/karlo195/tamago
+//
+// Copyright (c) The TamaGo Authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+package acpi
+
+import (
+	"encoding/binary"
+	"errors"
+
+	"github.com/karlo195/tamago/soc/intel/ioapic"
+)
+
+// Multiple APIC Description Table (MADT) signature
+const MADT_SIGNATURE = "APIC"
+
+// Interrupt Controller Structure types
+// (5.2.12 Multiple APIC Description Table (MADT)).
+const (
+	MADT_LAPIC          = 0
+	MADT_IOAPIC         = 1
+	MADT_OVERRIDE       = 2
+	MADT_LAPIC_NMI      = 4
+	MADT_LAPIC_OVERRIDE = 5
+	MADT_X2APIC         = 9
+)
+
+// Processor Local APIC flags
+const (
+	LAPIC_ENABLED        = 0
+	LAPIC_ONLINE_CAPABLE = 1
+)
+
+// MPS INTI flags
+// (5.2.12.5 Interrupt Source Override Structure).
+const (
+	INTI_POLARITY      = 0
+	INTI_POLARITY_HIGH = 0b01
+	INTI_POLARITY_LOW  = 0b11
+	INTI_TRIGGER       = 2
+	INTI_TRIGGER_EDGE  = 0b01
+	INTI_TRIGGER_LEVEL = 0b11
+)
+
+// LAPIC represents a Processor Local APIC (or x2APIC) structure.
+type LAPIC struct {
+	// ProcessorUID is the ACPI processor UID.
+	ProcessorUID uint32
+	// ID is the processor local APIC ID.
+	ID uint32
+	// Flags is the local APIC flags field.
+	Flags uint32
+}
+
+// Enabled returns whether the processor is usable.
+func (l *LAPIC) Enabled() bool {
+	return l.Flags&(1<<LAPIC_ENABLED) != 0
+}
+
+// IOAPIC represents an I/O APIC structure.
+type IOAPIC struct {
+	// ID is the I/O APIC ID.
+	ID uint8
+	// Address is the I/O APIC physical address.
+	Address uint32
+	// GSIBase is the first Global System Interrupt handled by the I/O
+	// APIC.
+	GSIBase uint32
+}
+
+// InterruptOverride represents an Interrupt Source Override structure.
+type InterruptOverride struct {
+	// Bus is the overridden bus (0 for ISA).
+	Bus uint8
+	// Source is the bus-relative interrupt source (IRQ).
+	Source uint8
+	// GSI is the Global System Interrupt the source maps to.
+	GSI uint32
+	// Flags is the MPS INTI flags field.
+	Flags uint16
+}
+
+// MADT represents the Multiple APIC Description Table.
+type MADT struct {
+	// LAPICAddress is the local APIC physical address.
+	LAPICAddress uint64
+	// Flags is the multiple APIC flags field.
+	Flags uint32
+
+	// LAPICs is the list of processor local APICs.
+	LAPICs []LAPIC
+	// IOAPICs is the list of I/O APICs.
+	IOAPICs []IOAPIC
+	// Overrides is the list of interrupt source overrides.
+	Overrides []InterruptOverride
+}
+
+// MADT returns the parsed Multiple APIC Description Table.
+func (a *ACPI) MADT() (m *MADT, err error) {
+	t, err := a.Table(MADT_SIGNATURE)
+
+	if err != nil {
+		return
+	}
+
+	buf := t.Data
+
+	if len(buf) < 8 {
+		return nil, errors.New("invalid MADT length")
+	}
+
+	m = &MADT{
+		LAPICAddress: uint64(binary.LittleEndian.Uint32(buf[0:])),
+		Flags:        binary.LittleEndian.Uint32(buf[4:]),
+	}
+
+	for buf = buf[8:]; len(buf) >= 2; {
+		typ := buf[0]
+		n := int(buf[1])
+
+		if n < 2 || n > len(buf) {
+			return nil, errors.New("invalid MADT entry")
+		}
+
+		e := buf[:n]
+		buf = buf[n:]
+
+		switch {
+		case typ == MADT_LAPIC && n >= 8:
+			m.LAPICs = append(m.LAPICs, LAPIC{
+				ProcessorUID: uint32(e[2]),
+				ID:           uint32(e[3]),
+				Flags:        binary.LittleEndian.Uint32(e[4:]),
+			})
+		case typ == MADT_X2APIC && n >= 16:
+			m.LAPICs = append(m.LAPICs, LAPIC{
+				ID:           binary.LittleEndian.Uint32(e[4:]),
+				Flags:        binary.LittleEndian.Uint32(e[8:]),
+				ProcessorUID: binary.LittleEndian.Uint32(e[12:]),
+			})
+		case typ == MADT_IOAPIC && n >= 12:
+			m.IOAPICs = append(m.IOAPICs, IOAPIC{
+				ID:      e[2],
+				Address: binary.LittleEndian.Uint32(e[4:]),
+				GSIBase: binary.LittleEndian.Uint32(e[8:]),
+			})
+		case typ == MADT_OVERRIDE && n >= 10:
+			m.Overrides = append(m.Overrides, InterruptOverride{
+				Bus:    e[2],
+				Source: e[3],
+				GSI:    binary.LittleEndian.Uint32(e[4:]),
+				Flags:  binary.LittleEndian.Uint16(e[8:]),
+			})
+		case typ == MADT_LAPIC_OVERRIDE && n >= 12:
+			m.LAPICAddress = binary.LittleEndian.Uint64(e[4:])
+		}
+	}
+
+	return
+}
+
+// NumCPU returns the number of enabled processors.
+func (m *MADT) NumCPU() (n int) {
+	for _, l := range m.LAPICs {
+		if l.Enabled() {
+			n++
+		}
+	}
+
+	return
+}
+
+// GSI returns the Global System Interrupt, and MPS INTI flags, matching the
+// argument ISA interrupt source (IRQ), taking interrupt source overrides into
+// account.
+func (m *MADT) GSI(irq int) (gsi int, flags uint16) {
+	for _, o := range m.Overrides {
+		if o.Bus == 0 && int(o.Source) == irq {
+			return int(o.GSI), o.Flags
+		}
+	}
+
+	return irq, 0
+}
+
+// ConfigureIOAPICs updates the argument I/O APIC instances, in order, with the
+// address and GSI base of the I/O APICs described by the table, no instance is
+// updated when fewer are described.
+func (m *MADT) ConfigureIOAPICs(ios ...*ioapic.IOAPIC) (err error) {
+	if len(m.IOAPICs) < len(ios) {
+		return errors.New("missing I/O APIC entries")
+	}
+
+	for i, io := range ios {
+		io.Base = m.IOAPICs[i].Address
+		io.GSIBase = int(m.IOAPICs[i].GSIBase)
+	}
+
+	return
+}
+
+// ConfigureIOAPICs updates the argument I/O APIC instances with the layout
+// described by the MADT of the system description tables referenced by the
+// argument RSDP address (see Load()), no instance is updated on error.
+func ConfigureIOAPICs(rsdp uint, ios ...*ioapic.IOAPIC) (err error) {
+	a, err := Load(rsdp)
+
+	if err != nil {
+		return
+	}
+
+	m, err := a.MADT()
+
+	if err != nil {
+		return
+	}
+
+	return m.ConfigureIOAPICs(ios...)
+}
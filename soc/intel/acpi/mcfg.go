@@ -0,0 +1,63 @@
+// Advanced Configuration and Power Interface (ACPI) support
+// https://github.com/karlo195/tamago
+//
+// Copyright (c) The TamaGo Authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+package acpi
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+// PCI Express memory mapped configuration space base address description
+// table signature (PCI Firmware Specification Revision 3.2 - 4.1.2 MCFG
+// Table Description).
+const MCFG_SIGNATURE = "MCFG"
+
+// MCFG allocation structure length
+const mcfgEntryLength = 16
+
+// ECAM represents an MCFG configuration space base address allocation
+// structure, describing a PCI Express Enhanced Configuration Access Mechanism
+// region.
+type ECAM struct {
+	// Base is the region base address.
+	Base uint64
+	// Segment is the PCI segment group number.
+	Segment uint16
+	// StartBus is the first decoded bus number.
+	StartBus uint8
+	// EndBus is the last decoded bus number.
+	EndBus uint8
+}
+
+// MCFG returns the configuration space base address allocations from the
+// PCI Express memory mapped configuration space base address description
+// table.
+func (a *ACPI) MCFG() (regions []ECAM, err error) {
+	t, err := a.Table(MCFG_SIGNATURE)
+
+	if err != nil {
+		return
+	}
+
+	if len(t.Data) < 8 {
+		return nil, errors.New("invalid MCFG length")
+	}
+
+	// skip reserved field
+	for buf := t.Data[8:]; len(buf) >= mcfgEntryLength; buf = buf[mcfgEntryLength:] {
+		regions = append(regions, ECAM{
+			Base:     binary.LittleEndian.Uint64(buf[0:]),
+			Segment:  binary.LittleEndian.Uint16(buf[8:]),
+			StartBus: buf[10],
+			EndBus:   buf[11],
+		})
+	}
+
+	return
+}
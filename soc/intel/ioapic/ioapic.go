@@ -17,6 +17,8 @@
 package ioapic
 
 import (
+	"errors"
+
 	"github.com/karlo195/tamago/bits"
 	"github.com/karlo195/tamago/internal/reg"
 )
@@ -36,14 +38,54 @@ const (
 
 	IOAPICVER   = 0x01
 	VER_ENTRIES = 16
+	VER_VERSION = 0
+
+	// EOI register (version >= 0x20)
+	IOEOI = 0x40
 
-	IOAPICREDTBLn  = 0x10
-	REDTBL_DEST    = 56
-	REDTBL_MASK    = 16
-	REDTBL_DESTMOD = 11
-	REDTBL_INTVEC  = 0
+	IOAPICREDTBLn    = 0x10
+	REDTBL_DEST      = 56
+	REDTBL_MASK      = 16
+	REDTBL_TRIGGER   = 15
+	REDTBL_REMOTEIRR = 14
+	REDTBL_POLARITY  = 13
+	REDTBL_DELIVS    = 12
+	REDTBL_DESTMOD   = 11
+	REDTBL_DELMOD    = 8
+	REDTBL_INTVEC    = 0
+)
+
+// Delivery modes (p12, 3.2.4 IOREDTBL[23:0] - I/O Redirection Table
+// Registers, 82093AA)
+const (
+	DELMOD_FIXED           = 0b000
+	DELMOD_LOWEST_PRIORITY = 0b001
+	DELMOD_SMI             = 0b010
+	DELMOD_NMI             = 0b100
+	DELMOD_INIT            = 0b101
+	DELMOD_EXTINT          = 0b111
 )
 
+// Redirection represents an I/O APIC redirection table entry.
+type Redirection struct {
+	// Vector is the interrupt vector.
+	Vector int
+	// DeliveryMode is the interrupt delivery mode (e.g. DELMOD_FIXED).
+	DeliveryMode int
+	// Logical selects logical destination mode, the destination is
+	// otherwise interpreted as an APIC ID (physical mode).
+	Logical bool
+	// ActiveLow selects low input pin polarity.
+	ActiveLow bool
+	// LevelTriggered selects level sensitive trigger mode.
+	LevelTriggered bool
+	// Masked disables interrupt delivery.
+	Masked bool
+	// Destination is the destination APIC ID (physical mode) or set of
+	// processors (logical mode).
+	Destination uint8
+}
+
 // IOAPIC represents an I/O APIC instance.
 type IOAPIC struct {
 	// Controller index
@@ -60,6 +102,16 @@ func (io *IOAPIC) Init() {
 	reg.SetN(io.Base+IOWIN, 24, 0xf, uint32(io.Index))
 }
 
+func (io *IOAPIC) read(index uint32) uint32 {
+	reg.Write(io.Base+IOREGSEL, index)
+	return reg.Read(io.Base + IOWIN)
+}
+
+func (io *IOAPIC) write(index uint32, val uint32) {
+	reg.Write(io.Base+IOREGSEL, index)
+	reg.Write(io.Base+IOWIN, val)
+}
+
 // ID returns the IOAPIC identification.
 func (io *IOAPIC) ID() uint32 {
 	reg.Write(io.Base+IOREGSEL, IOAPICID)
@@ -72,6 +124,14 @@ func (io *IOAPIC) Version() uint32 {
 	return reg.Read(io.Base + IOWIN)
 }
 
+// DirectedEOI returns whether the IOAPIC implements the EOI register, used to
+// signal the end of level-triggered interrupts when LAPIC EOI broadcast is
+// suppressed (see lapic.LAPIC.SuppressEOIBroadcast).
+func (io *IOAPIC) DirectedEOI() bool {
+	reg.Write(io.Base+IOREGSEL, IOAPICVER)
+	return reg.Get(io.Base+IOWIN, VER_VERSION, 0xff) >= 0x20
+}
+
 // Entries returns the size of the IOAPIC redirection table.
 func (io *IOAPIC) Entries() int {
 	reg.Write(io.Base+IOREGSEL, IOAPICVER)
@@ -79,33 +139,173 @@ func (io *IOAPIC) Entries() int {
 	return int(maxIndex) + 1
 }
 
+// entry returns the redirection table register index for the argument
+// Global System Interrupt.
+func (io *IOAPIC) entry(gsi int) (index uint32, err error) {
+	n := gsi - io.GSIBase
+
+	if n < 0 || n > io.Entries()-1 {
+		return 0, errors.New("invalid GSI")
+	}
+
+	return IOAPICREDTBLn + uint32(n*2), nil
+}
+
+// Redirection returns the redirection table entry for the argument Global
+// System Interrupt.
+func (io *IOAPIC) Redirection(gsi int) (r Redirection, err error) {
+	index, err := io.entry(gsi)
+
+	if err != nil {
+		return
+	}
+
+	lo := io.read(index)
+	hi := io.read(index + 1)
+
+	r.Vector = int(bits.Get(&lo, REDTBL_INTVEC, 0xff))
+	r.DeliveryMode = int(bits.Get(&lo, REDTBL_DELMOD, 0b111))
+	r.Logical = bits.IsSet(&lo, REDTBL_DESTMOD)
+	r.ActiveLow = bits.IsSet(&lo, REDTBL_POLARITY)
+	r.LevelTriggered = bits.IsSet(&lo, REDTBL_TRIGGER)
+	r.Masked = bits.IsSet(&lo, REDTBL_MASK)
+	r.Destination = uint8(bits.Get(&hi, REDTBL_DEST-32, 0xff))
+
+	return
+}
+
+// SetRedirection configures the redirection table entry for the argument
+// Global System Interrupt.
+//
+// PCI interrupts are typically active low and level triggered, while ISA
+// ones are active high and edge triggered unless overridden by ACPI MADT
+// Interrupt Source Override entries.
+func (io *IOAPIC) SetRedirection(gsi int, r Redirection) (err error) {
+	var lo uint32
+	var hi uint32
+
+	if r.DeliveryMode == DELMOD_FIXED || r.DeliveryMode == DELMOD_LOWEST_PRIORITY {
+		if r.Vector < MinVector || r.Vector > MaxVector {
+			return errors.New("invalid vector")
+		}
+	}
+
+	if r.DeliveryMode < 0 || r.DeliveryMode > 0b111 {
+		return errors.New("invalid delivery mode")
+	}
+
+	index, err := io.entry(gsi)
+
+	if err != nil {
+		return
+	}
+
+	bits.SetN(&lo, REDTBL_INTVEC, 0xff, uint32(r.Vector))
+	bits.SetN(&lo, REDTBL_DELMOD, 0b111, uint32(r.DeliveryMode))
+	bits.SetTo(&lo, REDTBL_DESTMOD, r.Logical)
+	bits.SetTo(&lo, REDTBL_POLARITY, r.ActiveLow)
+	bits.SetTo(&lo, REDTBL_TRIGGER, r.LevelTriggered)
+	bits.SetTo(&lo, REDTBL_MASK, r.Masked)
+
+	bits.SetN(&hi, REDTBL_DEST-32, 0xff, uint32(r.Destination))
+
+	// mask the entry while it is being updated
+	io.write(index, 1<<REDTBL_MASK)
+	io.write(index+1, hi)
+	io.write(index, lo)
+
+	return
+}
+
+// MaskInterrupt masks, or unmasks, the redirection table entry for the
+// argument Global System Interrupt.
+func (io *IOAPIC) MaskInterrupt(gsi int, mask bool) (err error) {
+	index, err := io.entry(gsi)
+
+	if err != nil {
+		return
+	}
+
+	lo := io.read(index)
+	bits.SetTo(&lo, REDTBL_MASK, mask)
+	io.write(index, lo)
+
+	return
+}
+
 // EnableInterrupt activates an IOAPIC redirection table entry at the
-// corresponding index for the desired interrupt vector.
+// corresponding index for the desired interrupt vector, with fixed delivery
+// to the BSP, active high polarity and edge trigger mode (see
+// [IOAPIC.SetRedirection] for full configuration).
 func (io *IOAPIC) EnableInterrupt(index int, id int) {
-	var val uint32
+	io.SetRedirection(index, Redirection{
+		Vector:       id,
+		DeliveryMode: DELMOD_FIXED,
+	})
+}
+
+// RemoteIRR returns whether a level-triggered interrupt, for the argument
+// Global System Interrupt, has been accepted by a LAPIC and is awaiting its
+// end of interrupt.
+func (io *IOAPIC) RemoteIRR(gsi int) (pending bool, err error) {
+	index, err := io.entry(gsi)
 
-	if id < MinVector || id > MaxVector {
+	if err != nil {
 		return
 	}
 
-	index -= io.GSIBase
+	lo := io.read(index)
 
-	if index > io.Entries()-1 {
+	return bits.IsSet(&lo, REDTBL_REMOTEIRR), nil
+}
+
+// EOI signals the end of a level-triggered interrupt, for the argument Global
+// System Interrupt, clearing its Remote IRR flag to allow further delivery.
+//
+// The EOI register is used when available (see [IOAPIC.DirectedEOI]), on
+// earlier versions the Remote IRR flag is cleared by temporarily switching
+// the entry to edge trigger mode. It is a no-op for edge-triggered entries.
+func (io *IOAPIC) EOI(gsi int) (err error) {
+	index, err := io.entry(gsi)
+
+	if err != nil {
 		return
 	}
 
-	// set destination field for physical mode
-	bits.Clear(&val, REDTBL_DESTMOD)
+	lo := io.read(index)
 
-	// set destination to BSP
-	reg.Write(io.Base+IOREGSEL, IOAPICREDTBLn+uint32(index*2)+1)
-	reg.Write(io.Base+IOWIN, 0 << (REDTBL_DEST-32))
+	if !bits.IsSet(&lo, REDTBL_TRIGGER) {
+		return
+	}
 
-	// set interrupt vector
-	bits.Clear(&val, REDTBL_MASK)
-	bits.SetN(&val, REDTBL_INTVEC, 0xff, uint32(id))
+	if io.DirectedEOI() {
+		reg.Write(io.Base+IOEOI, bits.Get(&lo, REDTBL_INTVEC, 0xff))
+		return
+	}
 
-	// set redirection table entry
-	reg.Write(io.Base+IOREGSEL, IOAPICREDTBLn+uint32(index*2))
-	reg.Write(io.Base+IOWIN, val)
+	edge := lo
+	bits.Set(&edge, REDTBL_MASK)
+	bits.Clear(&edge, REDTBL_TRIGGER)
+
+	io.write(index, edge)
+	io.write(index, lo)
+
+	return
+}
+
+// LevelHandler returns an interrupt handler, wrapping the argument function,
+// for level-triggered interrupts on the argument Global System Interrupt.
+//
+// The entry is masked while the function executes, to prevent interrupt
+// storms from shared lines (e.g. PCI INTx) which remain asserted until all
+// devices are serviced, then acknowledged (see [IOAPIC.EOI]) and unmasked.
+func (io *IOAPIC) LevelHandler(gsi int, fn func()) func() {
+	return func() {
+		io.MaskInterrupt(gsi, true)
+		defer io.MaskInterrupt(gsi, false)
+
+		fn()
+
+		io.EOI(gsi)
+	}
 }
@@ -0,0 +1,124 @@
+// Intel Advanced Programmable Interrupt Controller (APIC) driver
+// https://github.com/karlo195/tamago
+//
+// Copyright (c) The TamaGo Authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+package ioapic
+
+import (
+	"errors"
+	"sort"
+	"sync"
+)
+
+// Registry represents the set of I/O APICs of a system, each serving the
+// range of Global System Interrupts starting at its GSI base (see the ACPI
+// MADT I/O APIC structures, acpi.MADT.IOAPICs):
+//
+//	madt, _ := a.MADT()
+//
+//	for i, e := range madt.IOAPICs {
+//		io := &ioapic.IOAPIC{
+//			Index:   i,
+//			Base:    e.Address,
+//			GSIBase: int(e.GSIBase),
+//		}
+//
+//		io.Init()
+//		registry.Add(io)
+//	}
+type Registry struct {
+	sync.Mutex
+
+	entries []registryEntry
+}
+
+type registryEntry struct {
+	io *IOAPIC
+	// number of redirection table entries
+	n int
+}
+
+// Add registers an I/O APIC, its GSI range must not overlap with already
+// registered ones.
+func (r *Registry) Add(io *IOAPIC) (err error) {
+	r.Lock()
+	defer r.Unlock()
+
+	if io == nil || io.Base == 0 || io.GSIBase < 0 {
+		return errors.New("invalid I/O APIC instance")
+	}
+
+	n := io.Entries()
+
+	for _, e := range r.entries {
+		if io.GSIBase < e.io.GSIBase+e.n && e.io.GSIBase < io.GSIBase+n {
+			return errors.New("overlapping GSI range")
+		}
+	}
+
+	r.entries = append(r.entries, registryEntry{io: io, n: n})
+
+	sort.Slice(r.entries, func(i, j int) bool {
+		return r.entries[i].io.GSIBase < r.entries[j].io.GSIBase
+	})
+
+	return
+}
+
+// Controllers returns the registered I/O APICs sorted by GSI base.
+func (r *Registry) Controllers() (ios []*IOAPIC) {
+	r.Lock()
+	defer r.Unlock()
+
+	for _, e := range r.entries {
+		ios = append(ios, e.io)
+	}
+
+	return
+}
+
+// Lookup returns the I/O APIC serving the argument Global System Interrupt.
+func (r *Registry) Lookup(gsi int) (io *IOAPIC, err error) {
+	r.Lock()
+	defer r.Unlock()
+
+	for _, e := range r.entries {
+		if gsi >= e.io.GSIBase && gsi < e.io.GSIBase+e.n {
+			return e.io, nil
+		}
+	}
+
+	return nil, errors.New("GSI not found")
+}
+
+// Redirect configures the redirection table entry for the argument Global
+// System Interrupt on its serving I/O APIC (see [IOAPIC.SetRedirection]).
+func (r *Registry) Redirect(gsi int, re Redirection) (err error) {
+	io, err := r.Lookup(gsi)
+
+	if err != nil {
+		return
+	}
+
+	return io.SetRedirection(gsi, re)
+}
+
+// RouteGSI routes the argument Global System Interrupt to the argument vector
+// on the processor identified by the argument LAPIC ID, with fixed delivery,
+// active high polarity and edge trigger mode (see [Registry.Redirect] for full
+// configuration).
+func (r *Registry) RouteGSI(gsi int, vector int, cpu int) (err error) {
+	if cpu < 0 || cpu > 0xff {
+		return errors.New("invalid APIC ID")
+	}
+
+	return r.Redirect(gsi, Redirection{
+		Vector:       vector,
+		DeliveryMode: DELMOD_FIXED,
+		Destination:  uint8(cpu),
+	})
+}
@@ -0,0 +1,365 @@
+// Intel interrupt routing manager
+// https://github.com/karlo195/tamago
+//
+// Copyright (c) The TamaGo Authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+// Package irq implements an interrupt routing manager which presents a single
+// API to request, enable, disable and route interrupts regardless of their
+// delivery mechanism: I/O APIC redirection entries, PCI MSI capabilities,
+// PCI MSI-X table entries or LAPIC local vectors.
+//
+// Each request allocates an interrupt vector, registers its handler and
+// programs the interrupt source for delivery to the bootstrap processor:
+//
+//	m := &irq.Manager{
+//		CPU:     microvm.AMD64,
+//		IOAPICs: microvm.IOAPICs,
+//	}
+//
+//	i, err := m.Request(&irq.GSI{GSI: 4}, uart.Handle)
+//
+//	if err != nil {
+//		return err
+//	}
+//
+//	i.Enable()
+//
+//	// dispatch to registered handlers
+//	microvm.AMD64.ServiceInterrupts(nil)
+//
+// This package is only meant to be used with `GOOS=tamago GOARCH=amd64` as
+// supported by the TamaGo framework for bare metal Go, see
+// https://github.com/karlo195/tamago.
+package irq
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/karlo195/tamago/amd64"
+	"github.com/karlo195/tamago/amd64/lapic"
+	"github.com/karlo195/tamago/soc/intel/ioapic"
+	"github.com/karlo195/tamago/soc/intel/pci"
+)
+
+// Source represents an interrupt source, see [GSI], [MSI], [MSIX] and [Local].
+type Source interface {
+	// route programs, masked, delivery of the argument vector to the
+	// argument LAPIC ID.
+	route(m *Manager, vector int, apicid int) error
+	// mask masks, or unmasks, the interrupt source.
+	mask(m *Manager, mask bool) error
+	// wrap returns the handler for the argument function.
+	wrap(m *Manager, fn func()) func()
+}
+
+// GSI represents an interrupt source delivered through an I/O APIC
+// redirection entry.
+type GSI struct {
+	// GSI is the Global System Interrupt (see acpi.MADT.GSI for ISA
+	// interrupts).
+	GSI int
+	// ActiveLow selects low input pin polarity.
+	ActiveLow bool
+	// LevelTriggered selects level sensitive trigger mode, the entry is
+	// masked while its handler executes (see ioapic.IOAPIC.LevelHandler).
+	LevelTriggered bool
+}
+
+func (s *GSI) route(m *Manager, vector int, apicid int) error {
+	if m.IOAPICs == nil {
+		return errors.New("invalid I/O APIC registry")
+	}
+
+	return m.IOAPICs.Redirect(s.GSI, ioapic.Redirection{
+		Vector:         vector,
+		DeliveryMode:   ioapic.DELMOD_FIXED,
+		ActiveLow:      s.ActiveLow,
+		LevelTriggered: s.LevelTriggered,
+		Masked:         true,
+		Destination:    uint8(apicid),
+	})
+}
+
+func (s *GSI) mask(m *Manager, mask bool) (err error) {
+	io, err := m.IOAPICs.Lookup(s.GSI)
+
+	if err != nil {
+		return
+	}
+
+	return io.MaskInterrupt(s.GSI, mask)
+}
+
+func (s *GSI) wrap(m *Manager, fn func()) func() {
+	if !s.LevelTriggered {
+		return fn
+	}
+
+	io, err := m.IOAPICs.Lookup(s.GSI)
+
+	if err != nil {
+		return fn
+	}
+
+	return io.LevelHandler(s.GSI, fn)
+}
+
+// MSIX represents an interrupt source delivered through a PCI MSI-X table
+// entry.
+type MSIX struct {
+	// Capability is the device MSI-X capability.
+	Capability *pci.CapabilityMSIX
+	// Entry is the MSI-X table entry index.
+	Entry int
+}
+
+func (s *MSIX) route(m *Manager, vector int, apicid int) (err error) {
+	if s.Capability == nil {
+		return errors.New("invalid MSI-X capability")
+	}
+
+	addr, data := lapic.MSIMessage(apicid, vector, lapic.ICR_DLV_IRQ)
+
+	if err = s.Capability.EnableInterrupt(s.Entry, addr, data); err != nil {
+		return
+	}
+
+	return s.Capability.MaskInterrupt(s.Entry, true)
+}
+
+func (s *MSIX) mask(_ *Manager, mask bool) error {
+	return s.Capability.MaskInterrupt(s.Entry, mask)
+}
+
+func (s *MSIX) wrap(_ *Manager, fn func()) func() {
+	return fn
+}
+
+// MSI represents an interrupt source delivered through a PCI MSI capability,
+// configured for a single vector.
+type MSI struct {
+	// Capability is the device MSI capability.
+	Capability *pci.CapabilityMSI
+
+	addr uint64
+	data uint32
+}
+
+func (s *MSI) route(_ *Manager, vector int, apicid int) (err error) {
+	if s.Capability == nil {
+		return errors.New("invalid MSI capability")
+	}
+
+	s.addr, s.data = lapic.MSIMessage(apicid, vector, lapic.ICR_DLV_IRQ)
+
+	if err = s.Capability.EnableInterrupt(s.addr, s.data, 1); err != nil {
+		return
+	}
+
+	return s.mask(nil, true)
+}
+
+// mask uses per-vector masking, when supported, or otherwise the MSI enable
+// bit.
+func (s *MSI) mask(_ *Manager, mask bool) error {
+	switch {
+	case s.Capability.PerVectorMasking():
+		return s.Capability.MaskInterrupt(0, mask)
+	case mask:
+		return s.Capability.DisableInterrupt()
+	default:
+		return s.Capability.EnableInterrupt(s.addr, s.data, 1)
+	}
+}
+
+func (s *MSI) wrap(_ *Manager, fn func()) func() {
+	return fn
+}
+
+// Local represents an interrupt source which is programmed by its driver with
+// the allocated vector (e.g. LAPIC local vector table entries, IPIs or
+// devices with proprietary MSI configuration), only handler registration is
+// performed by the manager.
+type Local struct{}
+
+func (s *Local) route(_ *Manager, _ int, _ int) error {
+	return nil
+}
+
+func (s *Local) mask(_ *Manager, _ bool) error {
+	return nil
+}
+
+func (s *Local) wrap(_ *Manager, fn func()) func() {
+	return fn
+}
+
+// Manager represents an interrupt routing manager instance.
+type Manager struct {
+	sync.Mutex
+
+	// CPU is the processor instance servicing interrupts.
+	CPU *amd64.CPU
+	// IOAPICs is the I/O APIC registry, required for [GSI] sources.
+	IOAPICs *ioapic.Registry
+}
+
+// IRQ represents an interrupt requested to a [Manager].
+type IRQ struct {
+	sync.Mutex
+
+	// Vector is the allocated interrupt vector.
+	Vector int
+
+	m       *Manager
+	src     Source
+	apicid  int
+	enabled bool
+}
+
+// Request allocates an interrupt vector for the argument source, registers the
+// argument function as its handler and routes it, masked, to the processor
+// servicing interrupts. The interrupt must be enabled with [IRQ.Enable].
+//
+// Handlers are invoked by [amd64.CPU.ServiceInterrupts] (with a nil
+// argument), which signals the end of interrupt to the LAPIC.
+func (m *Manager) Request(src Source, fn func()) (irq *IRQ, err error) {
+	m.Lock()
+	defer m.Unlock()
+
+	if m.CPU == nil {
+		return nil, errors.New("invalid manager instance")
+	}
+
+	if src == nil || fn == nil {
+		return nil, errors.New("invalid interrupt source or handler")
+	}
+
+	vector, err := m.CPU.AllocateInterrupt(src.wrap(m, fn))
+
+	if err != nil {
+		return
+	}
+
+	irq = &IRQ{
+		Vector: vector,
+		m:      m,
+		src:    src,
+		apicid: int(m.CPU.ID()),
+	}
+
+	if err = src.route(m, vector, irq.apicid); err != nil {
+		m.CPU.FreeInterrupt(vector)
+		return nil, err
+	}
+
+	return
+}
+
+// Free masks the interrupt source and releases its vector.
+func (irq *IRQ) Free() {
+	irq.Lock()
+	defer irq.Unlock()
+
+	if irq.m == nil {
+		return
+	}
+
+	irq.src.mask(irq.m, true)
+	irq.m.CPU.FreeInterrupt(irq.Vector)
+
+	irq.m = nil
+	irq.enabled = false
+}
+
+// Enable unmasks the interrupt source.
+func (irq *IRQ) Enable() (err error) {
+	irq.Lock()
+	defer irq.Unlock()
+
+	if irq.m == nil {
+		return errors.New("interrupt has been freed")
+	}
+
+	if err = irq.src.mask(irq.m, false); err != nil {
+		return
+	}
+
+	irq.enabled = true
+
+	return
+}
+
+// Disable masks the interrupt source.
+func (irq *IRQ) Disable() (err error) {
+	irq.Lock()
+	defer irq.Unlock()
+
+	if irq.m == nil {
+		return errors.New("interrupt has been freed")
+	}
+
+	if err = irq.src.mask(irq.m, true); err != nil {
+		return
+	}
+
+	irq.enabled = false
+
+	return
+}
+
+// Enabled returns whether the interrupt source is unmasked.
+func (irq *IRQ) Enabled() bool {
+	irq.Lock()
+	defer irq.Unlock()
+
+	return irq.enabled
+}
+
+// SetAffinity routes the interrupt to the processor identified by the
+// argument LAPIC ID, preserving its enabled state.
+//
+// Handlers registered with the manager are only invoked on the processor
+// executing [amd64.CPU.ServiceInterrupts], therefore interrupts routed to
+// other processors must be serviced by them.
+func (irq *IRQ) SetAffinity(apicid int) (err error) {
+	irq.Lock()
+	defer irq.Unlock()
+
+	if irq.m == nil {
+		return errors.New("interrupt has been freed")
+	}
+
+	if apicid < 0 || apicid > 0xff {
+		return errors.New("invalid APIC ID")
+	}
+
+	if _, ok := irq.src.(*Local); ok {
+		return errors.New("unsupported on local interrupts")
+	}
+
+	if err = irq.src.route(irq.m, irq.Vector, apicid); err != nil {
+		return
+	}
+
+	irq.apicid = apicid
+
+	if irq.enabled {
+		err = irq.src.mask(irq.m, false)
+	}
+
+	return
+}
+
+// Affinity returns the LAPIC ID of the processor to which the interrupt is
+// routed.
+func (irq *IRQ) Affinity() int {
+	irq.Lock()
+	defer irq.Unlock()
+
+	return irq.apicid
+}
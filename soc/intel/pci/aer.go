@@ -0,0 +1,158 @@
+// Intel Peripheral Component Interconnect (PCI) driver
+// https://github.com/karlo195/tamago
+//
+// Copyright (c) The TamaGo Authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+package pci
+
+import (
+	"errors"
+)
+
+// Advanced Error Reporting registers
+// (PCI Express Base Specification Revision 4.0 - 7.8.4 Advanced Error
+// Reporting Extended Capability).
+const (
+	AER_UNCORRECTABLE_STATUS   = 0x04
+	AER_UNCORRECTABLE_MASK     = 0x08
+	AER_UNCORRECTABLE_SEVERITY = 0x0c
+	AER_CORRECTABLE_STATUS     = 0x10
+	AER_CORRECTABLE_MASK       = 0x14
+	AER_CONTROL                = 0x18
+	AER_HEADER_LOG             = 0x1c
+
+	// Uncorrectable Error Status register bits
+	AER_UE_DATA_LINK          = 4
+	AER_UE_SURPRISE_DOWN      = 5
+	AER_UE_POISONED_TLP       = 12
+	AER_UE_FLOW_CONTROL       = 13
+	AER_UE_COMPLETION_TIMEOUT = 14
+	AER_UE_COMPLETER_ABORT    = 15
+	AER_UE_UNEXPECTED_COMP    = 16
+	AER_UE_RECEIVER_OVERFLOW  = 17
+	AER_UE_MALFORMED_TLP      = 18
+	AER_UE_ECRC               = 19
+	AER_UE_UNSUPPORTED_REQ    = 20
+	AER_UE_ACS_VIOLATION      = 21
+
+	// Correctable Error Status register bits
+	AER_CE_RECEIVER_ERROR  = 0
+	AER_CE_BAD_TLP         = 6
+	AER_CE_BAD_DLLP        = 7
+	AER_CE_REPLAY_ROLLOVER = 8
+	AER_CE_REPLAY_TIMEOUT  = 12
+	AER_CE_ADVISORY        = 13
+	AER_CE_INTERNAL        = 14
+	AER_CE_HEADER_LOG_OVF  = 15
+
+	// Advanced Error Capabilities and Control register fields
+	AER_FIRST_ERROR_POINTER = 0
+)
+
+// CapabilityAER represents an Advanced Error Reporting Extended Capability
+// Structure.
+type CapabilityAER struct {
+	ExtendedCapabilityHeader
+
+	device *Device
+	off    uint32
+}
+
+// Unmarshal decodes a PCI Express Advanced Error Reporting Extended
+// Capability from the argument device configuration space at function 0 and
+// the given register offset.
+func (aer *CapabilityAER) Unmarshal(d *Device, off uint32) (err error) {
+	val, err := d.ReadExtended(0, off)
+
+	if err != nil {
+		return
+	}
+
+	aer.unmarshal(val)
+	aer.device = d
+	aer.off = off
+
+	return
+}
+
+// Offset returns the Extended Capability configuration space offset.
+func (aer *CapabilityAER) Offset() uint32 {
+	return aer.off
+}
+
+func (aer *CapabilityAER) read(off uint32) (uint32, error) {
+	if aer.device == nil {
+		return 0, errors.New("invalid capabilty instance")
+	}
+
+	return aer.device.ReadExtended(0, aer.off+off)
+}
+
+func (aer *CapabilityAER) write(off uint32, val uint32) error {
+	if aer.device == nil {
+		return errors.New("invalid capabilty instance")
+	}
+
+	return aer.device.WriteExtended(0, aer.off+off, val)
+}
+
+// Status returns the Uncorrectable and Correctable Error Status registers.
+func (aer *CapabilityAER) Status() (uncorrectable uint32, correctable uint32, err error) {
+	if uncorrectable, err = aer.read(AER_UNCORRECTABLE_STATUS); err != nil {
+		return
+	}
+
+	correctable, err = aer.read(AER_CORRECTABLE_STATUS)
+
+	return
+}
+
+// ClearStatus clears the argument Uncorrectable and Correctable Error Status
+// register bits.
+func (aer *CapabilityAER) ClearStatus(uncorrectable uint32, correctable uint32) (err error) {
+	// status bits are cleared by writing one
+	if err = aer.write(AER_UNCORRECTABLE_STATUS, uncorrectable); err != nil {
+		return
+	}
+
+	return aer.write(AER_CORRECTABLE_STATUS, correctable)
+}
+
+// Mask sets the Uncorrectable and Correctable Error Mask registers, masked
+// errors are neither logged nor signaled.
+func (aer *CapabilityAER) Mask(uncorrectable uint32, correctable uint32) (err error) {
+	if err = aer.write(AER_UNCORRECTABLE_MASK, uncorrectable); err != nil {
+		return
+	}
+
+	return aer.write(AER_CORRECTABLE_MASK, correctable)
+}
+
+// Severity returns the Uncorrectable Error Severity register, set bits
+// identify errors reported as fatal.
+func (aer *CapabilityAER) Severity() (uint32, error) {
+	return aer.read(AER_UNCORRECTABLE_SEVERITY)
+}
+
+// FirstError returns the bit position, in the Uncorrectable Error Status
+// register, of the first reported error along with its logged TLP header.
+func (aer *CapabilityAER) FirstError() (pos int, header [4]uint32, err error) {
+	ctrl, err := aer.read(AER_CONTROL)
+
+	if err != nil {
+		return
+	}
+
+	pos = int(ctrl>>AER_FIRST_ERROR_POINTER) & 0x1f
+
+	for i := range header {
+		if header[i], err = aer.read(AER_HEADER_LOG + uint32(i)*4); err != nil {
+			return
+		}
+	}
+
+	return
+}
@@ -0,0 +1,259 @@
+// Intel Peripheral Component Interconnect (PCI) driver
+// https://github.com/karlo195/tamago
+//
+// Copyright (c) The TamaGo Authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+package pci
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+
+	"github.com/karlo195/tamago/bits"
+)
+
+// Command register bits
+// (PCI Local Bus Specification, revision 3.0 - 6.2.2 Device Control).
+const (
+	CMD_IO_SPACE   = 0
+	CMD_MEM_SPACE  = 1
+	CMD_BUS_MASTER = 2
+)
+
+// Base Address register bits
+// (PCI Local Bus Specification, revision 3.0 - 6.2.5.1 Address Maps).
+const (
+	BAR_IO       = 0
+	BAR_TYPE     = 1
+	BAR_PREFETCH = 3
+
+	BAR_TYPE_32 = 0b00
+	BAR_TYPE_64 = 0b10
+)
+
+// Window represents a physical address range.
+type Window struct {
+	// Base is the range start address.
+	Base uint64
+	// Size is the range size in bytes.
+	Size uint64
+}
+
+// End returns the address following the range.
+func (w Window) End() uint64 {
+	return w.Base + w.Size
+}
+
+func (w Window) overlaps(base uint64, size uint64) bool {
+	return base < w.End() && w.Base < base+size
+}
+
+// Allocator assigns Base Address registers (BARs) left unassigned by
+// firmware, which is common on minimal VMMs or bare metal bring-up without
+// a BIOS, within the address windows decoded by the host bridge.
+//
+// Windows are either obtained from the host bridge ACPI _CRS resource
+// template or from fixed board memory maps. Reserved ranges (e.g. I/O APIC,
+// LAPIC and RAM) are never allocated, even if they fall within a window.
+type Allocator struct {
+	sync.Mutex
+
+	// Memory is the list of host bridge memory windows, only windows below
+	// 4GB are used for 32-bit BARs.
+	Memory []Window
+	// IO is the list of host bridge I/O port windows.
+	IO []Window
+	// Reserved is the list of memory ranges, within host bridge windows,
+	// which must not be allocated.
+	Reserved []Window
+
+	mem []Window
+	io  []Window
+}
+
+// allocate returns the lowest address, aligned to its size, within the
+// argument windows and limit, which does not overlap with used ranges.
+func allocate(windows []Window, used []Window, size uint64, limit uint64) (addr uint64, err error) {
+	for _, w := range windows {
+		addr = (w.Base + size - 1) &^ (size - 1)
+
+		for addr+size <= w.End() && addr+size <= limit {
+			conflict := false
+
+			for _, u := range used {
+				if u.overlaps(addr, size) {
+					addr = (u.End() + size - 1) &^ (size - 1)
+					conflict = true
+					break
+				}
+			}
+
+			if !conflict {
+				return
+			}
+		}
+	}
+
+	return 0, errors.New("no space available")
+}
+
+// ioSize returns the size of the I/O space decoded by a device Base Address
+// register (BAR).
+func ioSize(d *Device, off uint32) uint64 {
+	bar := d.Read(0, off)
+
+	d.Write(0, off, 0xffffffff)
+	val := d.Read(0, off) & 0xfffffffc
+	d.Write(0, off, bar)
+
+	if val == 0 {
+		return 0
+	}
+
+	// upper 16 bits might be hardwired to zero
+	return uint64(^(val | 0xffff0000) + 1)
+}
+
+// Assign assigns all unassigned Base Address registers (BARs) of the argument
+// device and enables its decoding of the corresponding address spaces.
+// Registers already assigned by firmware are preserved and their ranges are
+// excluded from further allocations.
+func (a *Allocator) Assign(d *Device) (err error) {
+	a.Lock()
+	defer a.Unlock()
+
+	cmd := d.Read(0, Command) & 0xffff
+
+	// disable decoding while sizing and assigning
+	d.Write(0, Command, cmd&^(1<<CMD_IO_SPACE|1<<CMD_MEM_SPACE))
+
+	defer func() {
+		d.Write(0, Command, cmd)
+	}()
+
+	bars := 6
+
+	// bridge headers (Type 1) only have two BARs
+	if d.HeaderType() == HEADER_TYPE_BRIDGE {
+		bars = 2
+	}
+
+	for n := 0; n < bars; n++ {
+		off := Bar0 + uint32(n)*4
+		bar := d.Read(0, off)
+
+		if bits.IsSet(&bar, BAR_IO) {
+			size := ioSize(d, off)
+
+			if size == 0 {
+				continue
+			}
+
+			if addr := uint64(bar &^ 0b11); addr != 0 {
+				a.io = append(a.io, Window{Base: addr, Size: size})
+				cmd |= 1 << CMD_IO_SPACE
+				continue
+			}
+
+			addr, err := allocate(a.IO, a.io, size, 1<<16)
+
+			if err != nil {
+				return fmt.Errorf("BAR%d I/O allocation, %v", n, err)
+			}
+
+			d.Write(0, off, uint32(addr))
+			a.io = append(a.io, Window{Base: addr, Size: size})
+			cmd |= 1 << CMD_IO_SPACE
+
+			continue
+		}
+
+		is64 := bits.Get(&bar, BAR_TYPE, 0b11) == BAR_TYPE_64
+		size := d.BaseAddressSize(n)
+
+		if size == 0 {
+			if is64 {
+				n++
+			}
+
+			continue
+		}
+
+		limit := uint64(1 << 32)
+		addr := uint64(bar &^ 0xf)
+
+		if is64 {
+			limit = math.MaxUint64
+			addr |= uint64(d.Read(0, off+4)) << 32
+		}
+
+		if addr == 0 {
+			used := append(append([]Window{}, a.Reserved...), a.mem...)
+
+			if addr, err = allocate(a.Memory, used, size, limit); err != nil {
+				return fmt.Errorf("BAR%d memory allocation, %v", n, err)
+			}
+
+			d.Write(0, off, uint32(addr))
+
+			if is64 {
+				d.Write(0, off+4, uint32(addr>>32))
+			}
+		}
+
+		a.mem = append(a.mem, Window{Base: addr, Size: size})
+		cmd |= 1 << CMD_MEM_SPACE
+
+		if is64 {
+			n++
+		}
+	}
+
+	return
+}
+
+// AssignAll assigns all unassigned Base Address registers (BARs) of devices
+// found on the argument bus.
+func (a *Allocator) AssignAll(bus int) (err error) {
+	for _, d := range Devices(bus) {
+		if err = a.Assign(d); err != nil {
+			return fmt.Errorf("%02x:%02x.%x, %v", d.Bus, d.Slot, d.Function, err)
+		}
+	}
+
+	return
+}
+
+// AssignExpansionROM assigns the device Expansion ROM Base Address register,
+// if unassigned, without enabling its decoding (see Device.ReadExpansionROM).
+func (a *Allocator) AssignExpansionROM(d *Device) (err error) {
+	a.Lock()
+	defer a.Unlock()
+
+	size := uint64(d.ExpansionROMSize())
+
+	if size == 0 {
+		return errors.New("no Expansion ROM")
+	}
+
+	addr := uint64(d.ExpansionROMAddress())
+
+	if addr == 0 {
+		used := append(append([]Window{}, a.Reserved...), a.mem...)
+
+		if addr, err = allocate(a.Memory, used, size, 1<<32); err != nil {
+			return fmt.Errorf("Expansion ROM allocation, %v", err)
+		}
+
+		d.Write(0, d.ExpansionROMOffset(), uint32(addr))
+	}
+
+	a.mem = append(a.mem, Window{Base: addr, Size: size})
+
+	return
+}
@@ -0,0 +1,273 @@
+// Intel Peripheral Component Interconnect (PCI) driver
+// https://github.com/karlo195/tamago
+//
+// Copyright (c) The TamaGo Authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+package pci
+
+import (
+	"errors"
+)
+
+// Header Type register values
+// (PCI Local Bus Specification, revision 3.0 - 6.2.1 Device Identification).
+const (
+	HEADER_TYPE_DEVICE = 0x00
+	HEADER_TYPE_BRIDGE = 0x01
+	HEADER_TYPE_MF     = 7
+)
+
+// Header Type 0x1 offsets
+// (PCI-to-PCI Bridge Architecture Specification, revision 1.2 - 3.2
+// PCI-to-PCI Bridge Configuration Space Header Format).
+const (
+	BusNumbers         = 0x18
+	IOWindow           = 0x1c
+	MemoryWindow       = 0x20
+	PrefetchWindow     = 0x24
+	PrefetchBaseUpper  = 0x28
+	PrefetchLimitUpper = 0x2c
+	IOWindowUpper      = 0x30
+)
+
+// Bridge window granularities
+const (
+	bridgeIOAlign  = 1 << 12
+	bridgeMemAlign = 1 << 20
+)
+
+// HeaderType returns the device configuration space header layout.
+func (d *Device) HeaderType() uint8 {
+	return uint8(d.Read(0, 0x0c)>>16) &^ (1 << HEADER_TYPE_MF)
+}
+
+// Bridge returns the device PCI-to-PCI bridge instance, nil is returned if
+// the device configuration space does not have a bridge header (Type 1).
+func (d *Device) Bridge() *BridgeDevice {
+	if d.HeaderType() != HEADER_TYPE_BRIDGE {
+		return nil
+	}
+
+	return &BridgeDevice{Device: d}
+}
+
+// BridgeDevice represents a PCI-to-PCI bridge, such as a PCI Express Root Port.
+type BridgeDevice struct {
+	*Device
+}
+
+// Buses returns the bridge primary, secondary and subordinate bus numbers.
+func (b *BridgeDevice) Buses() (primary uint8, secondary uint8, subordinate uint8) {
+	val := b.Read(0, BusNumbers)
+	return uint8(val), uint8(val >> 8), uint8(val >> 16)
+}
+
+// SetBuses sets the bridge primary, secondary and subordinate bus numbers,
+// configuration transactions for buses within the secondary and subordinate
+// range are forwarded by the bridge.
+func (b *BridgeDevice) SetBuses(primary uint8, secondary uint8, subordinate uint8) {
+	val := b.Read(0, BusNumbers)
+
+	// preserve Secondary Latency Timer
+	val &= 0xff000000
+	val |= uint32(subordinate)<<16 | uint32(secondary)<<8 | uint32(primary)
+
+	b.Write(0, BusNumbers, val)
+}
+
+// window decodes a bridge base and limit register pair, where the register
+// fields hold the upper bits of addresses aligned to the argument
+// granularity.
+func window(base uint64, limit uint64, align uint64) Window {
+	if limit < base {
+		return Window{}
+	}
+
+	return Window{
+		Base: base,
+		Size: limit + align - base,
+	}
+}
+
+// IOWindow returns the I/O address range forwarded by the bridge, a zero
+// size is returned if the window is disabled.
+func (b *BridgeDevice) IOWindow() Window {
+	val := b.Read(0, IOWindow)
+
+	base := uint64(val&0xf0) << 8
+	limit := uint64(val & 0xf000)
+
+	// 32-bit I/O addressing
+	if val&0xf == 1 {
+		upper := b.Read(0, IOWindowUpper)
+		base |= uint64(upper&0xffff) << 16
+		limit |= uint64(upper>>16) << 16
+	}
+
+	return window(base, limit, bridgeIOAlign)
+}
+
+// SetIOWindow sets the I/O address range forwarded by the bridge, a zero
+// size disables the window.
+func (b *BridgeDevice) SetIOWindow(w Window) error {
+	base, limit, err := checkWindow(w, bridgeIOAlign, 1<<32)
+
+	if err != nil {
+		return err
+	}
+
+	b.Write(0, IOWindowUpper, uint32(limit>>16)<<16|uint32(base>>16)&0xffff)
+	// Secondary Status bits are cleared by writing one, leave them untouched
+	b.Write(0, IOWindow, uint32(limit>>8)&0xf000|uint32(base>>8)&0xf0)
+
+	return nil
+}
+
+// MemoryWindow returns the non-prefetchable memory address range forwarded
+// by the bridge, a zero size is returned if the window is disabled.
+func (b *BridgeDevice) MemoryWindow() Window {
+	val := b.Read(0, MemoryWindow)
+
+	base := uint64(val&0xfff0) << 16
+	limit := uint64(val & 0xfff00000)
+
+	return window(base, limit, bridgeMemAlign)
+}
+
+// SetMemoryWindow sets the non-prefetchable memory address range forwarded
+// by the bridge, a zero size disables the window.
+func (b *BridgeDevice) SetMemoryWindow(w Window) error {
+	base, limit, err := checkWindow(w, bridgeMemAlign, 1<<32)
+
+	if err != nil {
+		return err
+	}
+
+	b.Write(0, MemoryWindow, uint32(limit)&0xfff00000|uint32(base>>16)&0xfff0)
+
+	return nil
+}
+
+// PrefetchableWindow returns the prefetchable memory address range forwarded
+// by the bridge, a zero size is returned if the window is disabled.
+func (b *BridgeDevice) PrefetchableWindow() Window {
+	val := b.Read(0, PrefetchWindow)
+
+	base := uint64(val&0xfff0) << 16
+	limit := uint64(val & 0xfff00000)
+
+	// 64-bit addressing
+	if val&0xf == 1 {
+		base |= uint64(b.Read(0, PrefetchBaseUpper)) << 32
+		limit |= uint64(b.Read(0, PrefetchLimitUpper)) << 32
+	}
+
+	return window(base, limit, bridgeMemAlign)
+}
+
+// SetPrefetchableWindow sets the prefetchable memory address range forwarded
+// by the bridge, a zero size disables the window.
+func (b *BridgeDevice) SetPrefetchableWindow(w Window) error {
+	limit := uint64(1 << 32)
+
+	// 64-bit addressing
+	if b.Read(0, PrefetchWindow)&0xf == 1 {
+		limit = 0
+	}
+
+	base, end, err := checkWindow(w, bridgeMemAlign, limit)
+
+	if err != nil {
+		return err
+	}
+
+	if limit == 0 {
+		b.Write(0, PrefetchBaseUpper, uint32(base>>32))
+		b.Write(0, PrefetchLimitUpper, uint32(end>>32))
+	}
+
+	b.Write(0, PrefetchWindow, uint32(end)&0xfff00000|uint32(base>>16)&0xfff0)
+
+	return nil
+}
+
+// checkWindow validates a bridge window against its granularity and address
+// limit (0 for none), returning the corresponding base and limit register
+// values. A disabled window is encoded with a limit lower than its base.
+func checkWindow(w Window, align uint64, top uint64) (base uint64, limit uint64, err error) {
+	if w.Size == 0 {
+		return align, 0, nil
+	}
+
+	if w.Base%align != 0 || w.Size%align != 0 {
+		return 0, 0, errors.New("invalid window alignment")
+	}
+
+	if top != 0 && w.End() > top {
+		return 0, 0, errors.New("invalid window range")
+	}
+
+	return w.Base, w.End() - align, nil
+}
+
+// Scan returns all PCI devices found on bus 0 and, recursively, on the
+// secondary buses of PCI-to-PCI bridges (e.g. PCI Express Root Ports on
+// q35 machines).
+//
+// Bridges left unconfigured by firmware are assigned bus numbers in depth
+// first order, following the highest bus number found so far.
+//
+// Each found device is bound to the first matching registered driver (see
+// [Register]), whose probe function is invoked with the device. Probe
+// failures do not interrupt the scan and are returned jointly.
+func Scan() (devices []*Device, err error) {
+	var errs []error
+
+	next := uint32(0)
+	scan(0, &next, &devices)
+
+	for _, d := range devices {
+		if err := bind(d); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return devices, errors.Join(errs...)
+}
+
+func scan(bus uint32, next *uint32, devices *[]*Device) {
+	for _, d := range Devices(int(bus)) {
+		*devices = append(*devices, d)
+
+		b := d.Bridge()
+
+		if b == nil {
+			continue
+		}
+
+		_, secondary, subordinate := b.Buses()
+
+		// bridge configured by firmware
+		if uint32(secondary) > bus {
+			*next = max(*next, uint32(secondary), uint32(subordinate))
+			scan(uint32(secondary), next, devices)
+			continue
+		}
+
+		if *next >= maxBuses-1 {
+			continue
+		}
+
+		// configure bridge, forwarding all buses until the walk
+		// behind it is complete
+		*next++
+		secondary = uint8(*next)
+
+		b.SetBuses(uint8(bus), secondary, 0xff)
+		scan(uint32(secondary), next, devices)
+		b.SetBuses(uint8(bus), secondary, uint8(*next))
+	}
+}
@@ -41,6 +41,25 @@ const (
 	FPB            = 0x15
 )
 
+// PCI Express Capabilities register fields
+// (PCI Express Base Specification Revision 4.0 - 7.5.3.2 PCI Express
+// Capabilities Register).
+const (
+	PCIE_CAP_VERSION   = 0
+	PCIE_CAP_PORT_TYPE = 4
+)
+
+// maxCapabilities limits Capabilities List walking on malformed chains.
+const maxCapabilities = 48
+
+// Capability represents a decoded PCI Capabilities List entry.
+type Capability interface {
+	// Header returns the Capability common fields.
+	Header() *CapabilityHeader
+	// Offset returns the Capability configuration space offset.
+	Offset() uint32
+}
+
 // CapabilityHeader represents the common fields of PCI Capabilities entries.
 type CapabilityHeader struct {
 	Vendor uint8
@@ -56,24 +75,195 @@ func (hdr *CapabilityHeader) Unmarshal(d *Device, off uint32) (err error) {
 	return
 }
 
-// Capabilities is an iterator over the entries of the device Capabilities
-// List.
-func (d *Device) Capabilities() func(func(off uint32, hdr *CapabilityHeader) bool) {
-	return func(yield func(uint32, *CapabilityHeader) bool) {
-		off := d.Read(0, CapabilitiesOffset)
+// Header returns the Capability common fields.
+func (hdr *CapabilityHeader) Header() *CapabilityHeader {
+	return hdr
+}
 
-		for off != 0 {
-			hdr := &CapabilityHeader{}
+// CapabilityGeneric represents a PCI Capability without a specific decoder.
+type CapabilityGeneric struct {
+	CapabilityHeader
 
-			if err := hdr.Unmarshal(d, off); err != nil {
-				return
-			}
+	off uint32
+}
+
+// Unmarshal decodes a PCI Capability common fields from the argument device
+// configuration space at function 0 and the given register offset.
+func (c *CapabilityGeneric) Unmarshal(d *Device, off uint32) (err error) {
+	c.off = off
+	return c.CapabilityHeader.Unmarshal(d, off)
+}
+
+// Offset returns the Capability configuration space offset.
+func (c *CapabilityGeneric) Offset() uint32 {
+	return c.off
+}
+
+// CapabilityVendor represents a Vendor Specific Capability.
+type CapabilityVendor struct {
+	CapabilityHeader
+
+	Length uint8
+	// Data holds the entire Capability, including its header, as its
+	// format is vendor specific.
+	Data []byte
+
+	off uint32
+}
+
+// Unmarshal decodes a PCI Vendor Specific Capability from the argument device
+// configuration space at function 0 and the given register offset.
+func (c *CapabilityVendor) Unmarshal(d *Device, off uint32) (err error) {
+	val := d.Read(0, off)
+	c.Vendor = uint8(val)
+	c.Next = uint8(val >> 8)
+	c.Length = uint8(val >> 16)
+	c.off = off
+
+	c.Data = make([]byte, (int(c.Length)+3)&^3)
+
+	for i := 0; i < len(c.Data); i += 4 {
+		binary.LittleEndian.PutUint32(c.Data[i:], d.Read(0, off+uint32(i)))
+	}
+
+	c.Data = c.Data[:c.Length]
+
+	return
+}
+
+// Offset returns the Capability configuration space offset.
+func (c *CapabilityVendor) Offset() uint32 {
+	return c.off
+}
+
+// CapabilityPCIe represents a PCI Express Capability Structure.
+type CapabilityPCIe struct {
+	CapabilityHeader
+
+	Capabilities       uint16
+	DeviceCapabilities uint32
+	DeviceControl      uint16
+	DeviceStatus       uint16
+	LinkCapabilities   uint32
+	LinkControl        uint16
+	LinkStatus         uint16
+
+	off uint32
+}
+
+// Unmarshal decodes a PCI Express Capability from the argument device
+// configuration space at function 0 and the given register offset.
+func (c *CapabilityPCIe) Unmarshal(d *Device, off uint32) (err error) {
+	val := d.Read(0, off)
+	c.Vendor = uint8(val)
+	c.Next = uint8(val >> 8)
+	c.Capabilities = uint16(val >> 16)
+
+	c.DeviceCapabilities = d.Read(0, off+0x04)
+
+	val = d.Read(0, off+0x08)
+	c.DeviceControl = uint16(val)
+	c.DeviceStatus = uint16(val >> 16)
+
+	c.LinkCapabilities = d.Read(0, off+0x0c)
+
+	val = d.Read(0, off+0x10)
+	c.LinkControl = uint16(val)
+	c.LinkStatus = uint16(val >> 16)
+
+	c.off = off
+
+	return
+}
+
+// Offset returns the Capability configuration space offset.
+func (c *CapabilityPCIe) Offset() uint32 {
+	return c.off
+}
+
+// Version returns the PCI Express Capability structure version.
+func (c *CapabilityPCIe) Version() int {
+	return int(c.Capabilities>>PCIE_CAP_VERSION) & 0xf
+}
+
+// PortType returns the PCI Express Device/Port Type.
+func (c *CapabilityPCIe) PortType() int {
+	return int(c.Capabilities>>PCIE_CAP_PORT_TYPE) & 0xf
+}
 
-			if !yield(off, hdr) {
-				return
+// Capabilities walks the device Capabilities List and returns its entries,
+// decoded according to their Capability ID.
+//
+// MSI, MSI-X, Power Management, PCI Express and Vendor Specific capabilities
+// are returned as their specific type (e.g. *CapabilityMSIX), any other one as
+// *CapabilityGeneric. Vendor Specific capabilities of VirtIO devices are
+// returned as *CapabilityVirtIO.
+func (d *Device) Capabilities() (caps []Capability) {
+	off := d.Read(0, CapabilitiesOffset) & 0xfc
+
+	for i := 0; off != 0 && i < maxCapabilities; i++ {
+		var c Capability
+		var err error
+
+		hdr := &CapabilityHeader{}
+
+		if err = hdr.Unmarshal(d, off); err != nil {
+			return
+		}
+
+		switch hdr.Vendor {
+		case MSI:
+			msi := &CapabilityMSI{}
+			err = msi.Unmarshal(d, off)
+			c = msi
+		case MSIX:
+			msix := &CapabilityMSIX{}
+			err = msix.Unmarshal(d, off)
+			c = msix
+		case Power:
+			pm := &CapabilityPM{}
+			err = pm.Unmarshal(d, off)
+			c = pm
+		case PCIe:
+			pcie := &CapabilityPCIe{}
+			err = pcie.Unmarshal(d, off)
+			c = pcie
+		case VendorSpecific:
+			if d.Vendor == VirtIOVendorID {
+				virtio := &CapabilityVirtIO{}
+				err = virtio.Unmarshal(d, off)
+				c = virtio
+				break
 			}
 
-			off = uint32(hdr.Next)
+			vendor := &CapabilityVendor{}
+			err = vendor.Unmarshal(d, off)
+			c = vendor
+		default:
+			generic := &CapabilityGeneric{}
+			err = generic.Unmarshal(d, off)
+			c = generic
+		}
+
+		if err != nil {
+			return
+		}
+
+		caps = append(caps, c)
+		off = uint32(hdr.Next) & 0xfc
+	}
+
+	return
+}
+
+// Capability returns the first device Capabilities List entry matching the
+// argument Capability ID, nil is returned if not found.
+func (d *Device) Capability(id uint8) Capability {
+	for _, c := range d.Capabilities() {
+		if c.Header().Vendor == id {
+			return c
 		}
 	}
+
+	return nil
 }
@@ -0,0 +1,143 @@
+// Intel Peripheral Component Interconnect (PCI) driver
+// https://github.com/karlo195/tamago
+//
+// Copyright (c) The TamaGo Authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+package pci
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// AnyID matches any Vendor or Device ID in a driver [Match] entry.
+const AnyID = 0xffff
+
+// Match represents a driver match table entry.
+type Match struct {
+	// Vendor is the matching Vendor ID, or AnyID.
+	Vendor uint16
+	// Device is the matching Device ID, or AnyID.
+	Device uint16
+	// Class is the matching class code (base class, sub-class and
+	// programming interface), compared under ClassMask.
+	Class uint32
+	// ClassMask selects the class code bits compared against Class, a zero
+	// value ignores the class code.
+	ClassMask uint32
+}
+
+// Matches returns whether the argument device matches the entry.
+func (m *Match) Matches(d *Device) bool {
+	if m.Vendor != AnyID && m.Vendor != d.Vendor {
+		return false
+	}
+
+	if m.Device != AnyID && m.Device != d.Device {
+		return false
+	}
+
+	return d.Class&m.ClassMask == m.Class&m.ClassMask
+}
+
+// Driver represents a PCI device driver.
+type Driver struct {
+	// Name is the driver name.
+	Name string
+	// Match is the list of supported devices.
+	Match []Match
+	// Probe initializes the driver for the argument matching device.
+	Probe func(d *Device) error
+	// Remove is an optional function invoked when a bound device is
+	// found removed (see [Monitor]).
+	Remove func(d *Device)
+}
+
+var (
+	mu      sync.Mutex
+	drivers []*Driver
+	bound   = make(map[uint32]*Device)
+)
+
+// Register adds a driver to the set probed by [Scan], drivers registered
+// earlier take precedence when matching the same device.
+func Register(drv *Driver) error {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if drv == nil || drv.Name == "" || drv.Probe == nil {
+		return errors.New("invalid driver")
+	}
+
+	for _, d := range drivers {
+		if d.Name == drv.Name {
+			return fmt.Errorf("duplicate driver %s", drv.Name)
+		}
+	}
+
+	drivers = append(drivers, drv)
+
+	return nil
+}
+
+// bind probes the first registered driver matching the argument device, a
+// device is bound only once across scans.
+func bind(d *Device) (err error) {
+	id := d.routingID()
+
+	mu.Lock()
+	prev, ok := bound[id]
+	candidates := drivers
+	mu.Unlock()
+
+	if ok && prev.Vendor == d.Vendor && prev.Device == d.Device {
+		d.Driver = prev.Driver
+		return
+	}
+
+	for _, drv := range candidates {
+		for _, m := range drv.Match {
+			if !m.Matches(d) {
+				continue
+			}
+
+			if err = drv.Probe(d); err != nil {
+				return fmt.Errorf("%02x:%02x.%x %s, %v", d.Bus, d.Slot, d.Function, drv.Name, err)
+			}
+
+			d.Driver = drv
+
+			mu.Lock()
+			bound[id] = d
+			mu.Unlock()
+
+			return
+		}
+	}
+
+	return
+}
+
+// unbind releases the driver bound to the argument device.
+func unbind(d *Device) {
+	id := d.routingID()
+
+	mu.Lock()
+
+	// the slot might have been already bound to a replacement device
+	if b, ok := bound[id]; ok && b.Vendor == d.Vendor && b.Device == d.Device {
+		delete(bound, id)
+	}
+
+	mu.Unlock()
+
+	if d.Driver != nil && d.Driver.Remove != nil {
+		d.Driver.Remove(d)
+	}
+
+	d.Driver = nil
+}
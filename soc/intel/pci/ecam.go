@@ -0,0 +1,113 @@
+// Intel Peripheral Component Interconnect (PCI) driver
+// https://github.com/karlo195/tamago
+//
+// Copyright (c) The TamaGo Authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+package pci
+
+import (
+	"errors"
+
+	"github.com/karlo195/tamago/internal/reg"
+)
+
+// ExtendedConfigSpace is the offset of the PCI Express extended configuration
+// space.
+const ExtendedConfigSpace = 0x100
+
+// ConfigSpaceSize is the size of the PCI Express configuration space of each
+// function.
+const ConfigSpaceSize = 0x1000
+
+// ECAM represents a PCI Express Enhanced Configuration Access Mechanism
+// region, the only mechanism providing access to the extended configuration
+// space (PCI Express Base Specification Revision 4.0 - 7.2.2 PCI Express
+// Enhanced Configuration Access Mechanism (ECAM)).
+//
+// Regions are typically described by the ACPI MCFG table (see acpi.MCFG).
+type ECAM struct {
+	// Base is the region base address, corresponding to bus 0 regardless
+	// of StartBus as in MCFG allocation structures.
+	Base uint64
+	// StartBus is the first decoded bus number.
+	StartBus uint32
+	// EndBus is the last decoded bus number.
+	EndBus uint32
+}
+
+var ecam []ECAM
+
+// SetECAM configures the ECAM regions used to access the device extended
+// configuration space, it must be invoked before any such access.
+func SetECAM(regions ...ECAM) {
+	ecam = regions
+}
+
+func (d *Device) ecamAddress(fn uint32, off uint32) (addr uint32, err error) {
+	if fn += d.Function; fn > 7 || off >= ConfigSpaceSize {
+		return 0, errors.New("invalid function or register offset")
+	}
+
+	for _, e := range ecam {
+		if d.Bus < e.StartBus || d.Bus > e.EndBus {
+			continue
+		}
+
+		a := e.Base + uint64(d.Bus<<20|d.Slot<<15|fn<<12|off&0xffc)
+
+		if a > 0xffffffff {
+			return 0, errors.New("unsupported ECAM address")
+		}
+
+		return uint32(a), nil
+	}
+
+	return 0, errors.New("no ECAM region for device")
+}
+
+// ReadExtended reads the device configuration space, including the PCI
+// Express extended configuration space, for a given function and register
+// offset.
+//
+// Accesses below the extended configuration space are performed through the
+// legacy configuration mechanism when no ECAM region is configured.
+func (d *Device) ReadExtended(fn uint32, off uint32) (val uint32, err error) {
+	addr, err := d.ecamAddress(fn, off)
+
+	if err != nil {
+		if off < ExtendedConfigSpace && fn <= 7 {
+			return d.Read(fn, off), nil
+		}
+
+		return
+	}
+
+	return reg.Read(addr) >> ((off & 2) * 8), nil
+}
+
+// WriteExtended writes the device configuration space, including the PCI
+// Express extended configuration space, for a given function and register
+// offset, the offset must be 32-bit aligned.
+func (d *Device) WriteExtended(fn uint32, off uint32, val uint32) (err error) {
+	if off&3 != 0 {
+		return errors.New("invalid register offset")
+	}
+
+	addr, err := d.ecamAddress(fn, off)
+
+	if err != nil {
+		if off < ExtendedConfigSpace && fn <= 7 {
+			d.Write(fn, off, val)
+			return nil
+		}
+
+		return
+	}
+
+	reg.Write(addr, val)
+
+	return
+}
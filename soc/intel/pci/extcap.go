@@ -0,0 +1,147 @@
+// Intel Peripheral Component Interconnect (PCI) driver
+// https://github.com/karlo195/tamago
+//
+// Copyright (c) The TamaGo Authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+package pci
+
+// Extended Capability IDs
+//
+// (PCI Code and ID Assignment Specification Revision 1.11
+// 24 Jan 2019 - 3. Extended Capability IDs).
+const (
+	ExtNull           = 0x0000
+	ExtAER            = 0x0001
+	ExtVC             = 0x0002
+	ExtSerialNumber   = 0x0003
+	ExtPowerBudget    = 0x0004
+	ExtACS            = 0x000d
+	ExtARI            = 0x000e
+	ExtATS            = 0x000f
+	ExtSRIOV          = 0x0010
+	ExtVendorSpecific = 0x000b
+	ExtLTR            = 0x0018
+	ExtL1PMSubstates  = 0x001e
+	ExtPTM            = 0x001f
+)
+
+// maxExtendedCapabilities limits Extended Capabilities List walking on
+// malformed chains.
+const maxExtendedCapabilities = (ConfigSpaceSize - ExtendedConfigSpace) / 4
+
+// ExtendedCapability represents a decoded PCI Express Extended Capabilities
+// List entry.
+type ExtendedCapability interface {
+	// Header returns the Extended Capability common fields.
+	Header() *ExtendedCapabilityHeader
+	// Offset returns the Extended Capability configuration space offset.
+	Offset() uint32
+}
+
+// ExtendedCapabilityHeader represents the common fields of PCI Express
+// Extended Capabilities entries (PCI Express Base Specification Revision 4.0
+// - 7.6.3 PCI Express Extended Capability Header).
+type ExtendedCapabilityHeader struct {
+	ID      uint16
+	Version uint8
+	Next    uint16
+}
+
+// Header returns the Extended Capability common fields.
+func (hdr *ExtendedCapabilityHeader) Header() *ExtendedCapabilityHeader {
+	return hdr
+}
+
+func (hdr *ExtendedCapabilityHeader) unmarshal(val uint32) {
+	hdr.ID = uint16(val)
+	hdr.Version = uint8(val>>16) & 0xf
+	hdr.Next = uint16(val >> 20)
+}
+
+// ExtendedCapabilityGeneric represents a PCI Express Extended Capability
+// without a specific decoder.
+type ExtendedCapabilityGeneric struct {
+	ExtendedCapabilityHeader
+
+	off uint32
+}
+
+// Offset returns the Extended Capability configuration space offset.
+func (c *ExtendedCapabilityGeneric) Offset() uint32 {
+	return c.off
+}
+
+// ExtendedCapabilities walks the device Extended Capabilities List, which
+// requires ECAM access (see SetECAM), and returns its entries decoded
+// according to their Extended Capability ID.
+//
+// AER and SR-IOV capabilities are returned as their specific type (e.g.
+// *CapabilityAER), any other one as *ExtendedCapabilityGeneric.
+func (d *Device) ExtendedCapabilities() (caps []ExtendedCapability, err error) {
+	off := uint32(ExtendedConfigSpace)
+
+	for i := 0; i < maxExtendedCapabilities; i++ {
+		var c ExtendedCapability
+
+		val, err := d.ReadExtended(0, off)
+
+		if err != nil {
+			return nil, err
+		}
+
+		// absent capabilities, or function, are indicated by an all
+		// zeroes or all ones header
+		if val == 0 || val == 0xffffffff {
+			break
+		}
+
+		hdr := &ExtendedCapabilityHeader{}
+		hdr.unmarshal(val)
+
+		switch hdr.ID {
+		case ExtAER:
+			aer := &CapabilityAER{}
+			err = aer.Unmarshal(d, off)
+			c = aer
+		case ExtSRIOV:
+			sriov := &CapabilitySRIOV{}
+			err = sriov.Unmarshal(d, off)
+			c = sriov
+		default:
+			c = &ExtendedCapabilityGeneric{
+				ExtendedCapabilityHeader: *hdr,
+				off:                      off,
+			}
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		caps = append(caps, c)
+
+		if off = uint32(hdr.Next) &^ 3; off < ExtendedConfigSpace {
+			break
+		}
+	}
+
+	return
+}
+
+// ExtendedCapability returns the first device Extended Capabilities List
+// entry matching the argument Extended Capability ID, nil is returned if not
+// found.
+func (d *Device) ExtendedCapability(id uint16) ExtendedCapability {
+	caps, _ := d.ExtendedCapabilities()
+
+	for _, c := range caps {
+		if c.Header().ID == id {
+			return c
+		}
+	}
+
+	return nil
+}
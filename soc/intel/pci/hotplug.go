@@ -0,0 +1,182 @@
+// Intel Peripheral Component Interconnect (PCI) driver
+// https://github.com/karlo195/tamago
+//
+// Copyright (c) The TamaGo Authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+package pci
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/karlo195/tamago/internal/reg"
+)
+
+// Hotplug event types
+const (
+	Added = iota
+	Removed
+)
+
+// Event represents a device hotplug event.
+type Event struct {
+	// Type is the event type (Added or Removed).
+	Type int
+	// Device is the added or removed device.
+	Device *Device
+}
+
+// Cloud Hypervisor PCI hotplug controller registers
+const (
+	PCIU = 0x00
+	PCID = 0x04
+	B0EJ = 0x08
+	PSEG = 0x0c
+)
+
+// HotplugController represents the Cloud Hypervisor PCI hotplug controller,
+// exposed to ACPI as the `\_SB_.PHPR` device whose `_EJ0` method writes the
+// ejected slots to the B0EJ register.
+type HotplugController struct {
+	// Base is the controller register block address (see `PHPR` _CRS).
+	Base uint32
+	// Segment is the PCI segment group number.
+	Segment uint32
+}
+
+// Status returns, and clears, the bitmaps of the bus 0 slots where devices
+// have been hotplugged (up) or are pending removal (down).
+func (hw *HotplugController) Status() (up uint32, down uint32) {
+	reg.Write(hw.Base+PSEG, hw.Segment)
+
+	up = reg.Read(hw.Base + PCIU)
+	down = reg.Read(hw.Base + PCID)
+
+	return
+}
+
+// Eject acknowledges the removal of the devices on the argument bus 0 slots
+// bitmap, allowing the VMM to complete it (see `_EJ0`).
+func (hw *HotplugController) Eject(slots uint32) {
+	reg.Write(hw.Base+PSEG, hw.Segment)
+	reg.Write(hw.Base+B0EJ, slots)
+}
+
+// Monitor tracks devices across bus re-enumerations, to detect devices
+// hotplugged by the VMM (e.g. Cloud Hypervisor `add-device` and
+// `remove-device` API calls) into a running guest.
+//
+// Re-enumeration must be triggered by the application, either periodically or
+// on the VMM hotplug notification (e.g. ACPI Generic Event Device interrupt).
+//
+// Without a Controller, devices replaced by one with identical Vendor and
+// Device IDs between re-enumerations cannot be detected.
+type Monitor struct {
+	sync.Mutex
+
+	// Handler is an optional function invoked for each event.
+	Handler func(e Event)
+
+	// Controller is the optional VMM hotplug controller, used to detect
+	// replaced devices and to eject removed ones.
+	Controller *HotplugController
+
+	devices map[uint32]*Device
+}
+
+// Rescan re-enumerates all buses (see [Scan]) and returns the events for
+// devices that appeared or vanished since the previous invocation, the first
+// invocation reports all devices as added.
+//
+// Removed devices are released from their bound driver (see [Driver.Remove])
+// before added ones are bound to matching registered drivers. Devices pending
+// removal on the Controller are reported as removed and then ejected.
+func (m *Monitor) Rescan() (events []Event, err error) {
+	var up, down uint32
+	var devices []*Device
+	var errs []error
+
+	m.Lock()
+	defer m.Unlock()
+
+	if m.Controller != nil {
+		up, down = m.Controller.Status()
+	}
+
+	next := uint32(0)
+	scan(0, &next, &devices)
+
+	found := make(map[uint32]*Device, len(devices))
+
+	for _, d := range devices {
+		id := d.routingID()
+
+		// devices pending ejection are already gone for the VMM
+		if d.Bus == 0 && down&(1<<d.Slot) != 0 {
+			continue
+		}
+
+		found[id] = d
+
+		// a different, or hotplugged, device on the same slot is a
+		// replacement
+		if prev, ok := m.devices[id]; ok {
+			replaced := d.Bus == 0 && up&(1<<d.Slot) != 0
+
+			if prev.Vendor == d.Vendor && prev.Device == d.Device && !replaced {
+				continue
+			}
+
+			unbind(prev)
+			events = append(events, Event{Type: Removed, Device: prev})
+		}
+
+		events = append(events, Event{Type: Added, Device: d})
+	}
+
+	for id, d := range m.devices {
+		if _, ok := found[id]; !ok {
+			unbind(d)
+			events = append(events, Event{Type: Removed, Device: d})
+		}
+	}
+
+	for _, d := range devices {
+		if _, ok := found[d.routingID()]; !ok {
+			continue
+		}
+
+		if err := bind(d); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if down != 0 {
+		m.Controller.Eject(down)
+	}
+
+	m.devices = found
+
+	if m.Handler != nil {
+		for _, e := range events {
+			m.Handler(e)
+		}
+	}
+
+	return events, errors.Join(errs...)
+}
+
+// Devices returns the devices found on the last re-enumeration.
+func (m *Monitor) Devices() (devices []*Device) {
+	m.Lock()
+	defer m.Unlock()
+
+	for _, d := range m.devices {
+		devices = append(devices, d)
+	}
+
+	return
+}
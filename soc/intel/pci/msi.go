@@ -0,0 +1,190 @@
+// Intel Peripheral Component Interconnect (PCI) driver
+// https://github.com/karlo195/tamago
+//
+// Copyright (c) The TamaGo Authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+package pci
+
+import (
+	"errors"
+
+	"github.com/karlo195/tamago/bits"
+)
+
+// MSI Message Control register bits
+// (PCI Local Bus Specification, revision 3.0 - 6.8.1.3 Message Control for MSI).
+const (
+	MSI_CTRL_PVM    = 8
+	MSI_CTRL_64     = 7
+	MSI_CTRL_MME    = 4
+	MSI_CTRL_MMC    = 1
+	MSI_CTRL_ENABLE = 0
+)
+
+// CapabilityMSI represents an MSI Capability Structure.
+type CapabilityMSI struct {
+	CapabilityHeader
+
+	MessageControl uint16
+
+	device *Device
+	off    uint32
+}
+
+// Unmarshal decodes a PCI MSI Capability from the argument device
+// configuration space at function 0 and the given register offset.
+func (msi *CapabilityMSI) Unmarshal(d *Device, off uint32) (err error) {
+	val := d.Read(0, off)
+	msi.Vendor = uint8(val & 0xff)
+	msi.Next = uint8(val >> 8)
+	msi.MessageControl = uint16(val >> 16)
+
+	msi.device = d
+	msi.off = off
+
+	return
+}
+
+// Offset returns the Capability configuration space offset.
+func (msi *CapabilityMSI) Offset() uint32 {
+	return msi.off
+}
+
+func (msi *CapabilityMSI) control() uint32 {
+	return uint32(msi.MessageControl)
+}
+
+func (msi *CapabilityMSI) writeControl(ctrl uint32) {
+	msi.device.Write(0, msi.off, ctrl<<16)
+	msi.MessageControl = uint16(ctrl)
+}
+
+// Is64Bit returns whether the device supports 64-bit message addresses.
+func (msi *CapabilityMSI) Is64Bit() bool {
+	ctrl := msi.control()
+	return bits.IsSet(&ctrl, MSI_CTRL_64)
+}
+
+// PerVectorMasking returns whether the device supports per-vector masking.
+func (msi *CapabilityMSI) PerVectorMasking() bool {
+	ctrl := msi.control()
+	return bits.IsSet(&ctrl, MSI_CTRL_PVM)
+}
+
+// Vectors returns the number of vectors requested by the device (Multiple
+// Message Capable field).
+func (msi *CapabilityMSI) Vectors() int {
+	ctrl := msi.control()
+	return 1 << bits.Get(&ctrl, MSI_CTRL_MMC, 0b111)
+}
+
+// dataOffset returns the Message Data register offset.
+func (msi *CapabilityMSI) dataOffset() uint32 {
+	if msi.Is64Bit() {
+		return msi.off + 0x0c
+	}
+
+	return msi.off + 0x08
+}
+
+// EnableInterrupt configures the MSI message address and data, for the
+// argument number of vectors, and enables MSI.
+//
+// When more than one vector is enabled the device modifies the low order bits
+// of the message data to signal each vector, therefore the data must be aligned
+// to the number of vectors, which must be a power of 2.
+func (msi *CapabilityMSI) EnableInterrupt(addr uint64, data uint32, vectors int) (err error) {
+	if msi.device == nil {
+		return errors.New("invalid capabilty instance")
+	}
+
+	if vectors < 1 || vectors > msi.Vectors() || vectors&(vectors-1) != 0 {
+		return errors.New("invalid number of vectors")
+	}
+
+	if data&uint32(vectors-1) != 0 || data > 0xffff {
+		return errors.New("invalid message data")
+	}
+
+	if addr>>32 != 0 && !msi.Is64Bit() {
+		return errors.New("invalid message address")
+	}
+
+	ctrl := msi.control()
+
+	// disable while updating
+	bits.Clear(&ctrl, MSI_CTRL_ENABLE)
+	msi.writeControl(ctrl)
+
+	msi.device.Write(0, msi.off+4, uint32(addr))
+
+	if msi.Is64Bit() {
+		msi.device.Write(0, msi.off+8, uint32(addr>>32))
+	}
+
+	msi.device.Write(0, msi.dataOffset(), data)
+
+	mme := uint32(0)
+
+	for 1<<mme < vectors {
+		mme++
+	}
+
+	bits.SetN(&ctrl, MSI_CTRL_MME, 0b111, mme)
+	bits.Set(&ctrl, MSI_CTRL_ENABLE)
+	msi.writeControl(ctrl)
+
+	return
+}
+
+// DisableInterrupt disables MSI.
+func (msi *CapabilityMSI) DisableInterrupt() (err error) {
+	if msi.device == nil {
+		return errors.New("invalid capabilty instance")
+	}
+
+	ctrl := msi.control()
+	bits.Clear(&ctrl, MSI_CTRL_ENABLE)
+	msi.writeControl(ctrl)
+
+	return
+}
+
+// MaskInterrupt masks, or unmasks, an MSI vector through the Mask Bits
+// register, only available on devices supporting per-vector masking.
+func (msi *CapabilityMSI) MaskInterrupt(n int, mask bool) (err error) {
+	if msi.device == nil {
+		return errors.New("invalid capabilty instance")
+	}
+
+	if !msi.PerVectorMasking() {
+		return errors.New("per-vector masking not supported")
+	}
+
+	if n < 0 || n >= msi.Vectors() {
+		return errors.New("invalid vector")
+	}
+
+	off := msi.dataOffset() + 4
+	val := msi.device.Read(0, off)
+
+	bits.SetTo(&val, n, mask)
+	msi.device.Write(0, off, val)
+
+	return
+}
+
+// Pending returns whether an MSI vector has a pending message, only available
+// on devices supporting per-vector masking.
+func (msi *CapabilityMSI) Pending(n int) bool {
+	if msi.device == nil || !msi.PerVectorMasking() || n < 0 || n >= msi.Vectors() {
+		return false
+	}
+
+	val := msi.device.Read(0, msi.dataOffset()+8)
+
+	return bits.IsSet(&val, n)
+}
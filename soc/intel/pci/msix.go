@@ -15,7 +15,10 @@ import (
 	"github.com/karlo195/tamago/dma"
 )
 
-const msixEnable = 31
+const (
+	msixEnable     = 31
+	msixVectorMask = 0
+)
 
 // CapabilityMSIX represents an MSI-X Capability Structure.
 type CapabilityMSIX struct {
@@ -46,16 +49,20 @@ func (msix *CapabilityMSIX) Unmarshal(d *Device, off uint32) (err error) {
 	return
 }
 
+// Offset returns the Capability configuration space offset.
+func (msix *CapabilityMSIX) Offset() uint32 {
+	return msix.off
+}
+
 // TableSize returns the number of entries in the MSI-X table.
 func (msix *CapabilityMSIX) TableSize() int {
 	return int(msix.MessageControl&0x7ff) + 1
 }
 
-// EnableInterrupt configures an MSI-X interrupt entry and enables the MSI-X
-// table.
-func (msix *CapabilityMSIX) EnableInterrupt(n int, addr uint64, data uint32) (err error) {
-	if n > msix.TableSize() || msix.device == nil {
-		return errors.New("invalid capabilty instance")
+// entry maps the indexed MSI-X table entry.
+func (msix *CapabilityMSIX) entry(n int) (ptr uint, entry []byte, err error) {
+	if n < 0 || n >= msix.TableSize() || msix.device == nil {
+		return 0, nil, errors.New("invalid capabilty instance")
 	}
 
 	bir := int(msix.TableOffset & 0b11)
@@ -68,10 +75,22 @@ func (msix *CapabilityMSIX) EnableInterrupt(n int, addr uint64, data uint32) (er
 	r, err := dma.NewRegion(uint(table+off), size, false)
 
 	if err != nil {
-		return err
+		return
 	}
 
-	ptr, entry := r.Reserve(size, 0)
+	ptr, entry = r.Reserve(size, 0)
+
+	return
+}
+
+// EnableInterrupt configures an MSI-X interrupt entry and enables the MSI-X
+// table.
+func (msix *CapabilityMSIX) EnableInterrupt(n int, addr uint64, data uint32) (err error) {
+	ptr, entry, err := msix.entry(n)
+
+	if err != nil {
+		return
+	}
 	defer dma.Release(ptr)
 
 	binary.LittleEndian.PutUint64(entry[0:], addr)
@@ -82,3 +101,26 @@ func (msix *CapabilityMSIX) EnableInterrupt(n int, addr uint64, data uint32) (er
 
 	return
 }
+
+// MaskInterrupt masks, or unmasks, an MSI-X interrupt entry through its
+// vector control field.
+func (msix *CapabilityMSIX) MaskInterrupt(n int, mask bool) (err error) {
+	ptr, entry, err := msix.entry(n)
+
+	if err != nil {
+		return
+	}
+	defer dma.Release(ptr)
+
+	ctrl := binary.LittleEndian.Uint32(entry[12:])
+
+	if mask {
+		ctrl |= 1 << msixVectorMask
+	} else {
+		ctrl &^= 1 << msixVectorMask
+	}
+
+	binary.LittleEndian.PutUint32(entry[12:], ctrl)
+
+	return
+}
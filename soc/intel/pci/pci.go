@@ -27,8 +27,9 @@ const (
 )
 
 const (
-	maxBuses   = 256
-	maxDevices = 32
+	maxBuses     = 256
+	maxDevices   = 32
+	maxFunctions = 8
 )
 
 // Header Type 0x0 offsets
@@ -48,24 +49,39 @@ type Device struct {
 	Vendor uint16
 	// Device ID
 	Device uint16
+	// Class code (base class, sub-class and programming interface)
+	Class uint32
 
 	// PCI Slot
 	Slot uint32
+	// Function number, non-zero only for additional functions of
+	// multi-function devices
+	Function uint32
+
+	// Driver is the bound device driver, see [Register].
+	Driver *Driver
 }
 
 func (d *Device) address(fn uint32, off uint32) uint32 {
-	return 1<<31 | d.Bus<<16 | d.Slot<<11 | fn<<8 | off&0xfc
+	return 1<<31 | d.Bus<<16 | d.Slot<<11 | (d.Function+fn)<<8 | off&0xfc
+}
+
+// routingID returns the device bus, device and function numbers
+// (PCI Express Base Specification Revision 4.0 - 2.2.4.2 ID Based Routing).
+func (d *Device) routingID() uint32 {
+	return d.Bus<<8 | d.Slot<<3 | d.Function
 }
 
-// Read reads the device configuration space for a given function and
-// register offset.
+// Read reads the device configuration space for a given function, relative to
+// the device Function, and register offset.
 func (d *Device) Read(fn uint32, off uint32) uint32 {
 	reg.Out32(CONFIG_ADDRESS, d.address(fn, off))
 	return reg.In32(CONFIG_DATA) >> ((off & 2) * 8)
 }
 
-// Write writes the device configuration space for a given function and
-// register offset, the offset must be 32-bit aligned.
+// Write writes the device configuration space for a given function, relative
+// to the device Function, and register offset, the offset must be 32-bit
+// aligned.
 func (d *Device) Write(fn uint32, off uint32, val uint32) {
 	if (off&2)*8 != 0 {
 		return
@@ -95,6 +111,46 @@ func (d *Device) BaseAddress(n int) uint {
 	return 0
 }
 
+// BaseAddressSize returns the size of the memory space decoded by a device
+// Base Address register (BAR), I/O space BARs are not supported
+// (PCI Local Bus Specification, revision 3.0 - 6.2.5.1 Address Maps).
+func (d *Device) BaseAddressSize(n int) uint64 {
+	if n > 5 {
+		return 0
+	}
+
+	off := Bar0 + uint32(n)*4
+	bar := d.Read(0, off)
+
+	if bits.IsSet(&bar, 0) {
+		return 0
+	}
+
+	// disable memory decoding while sizing
+	cmd := d.Read(0, Command) & 0xffff
+	d.Write(0, Command, cmd&^(1<<1))
+	defer d.Write(0, Command, cmd)
+
+	d.Write(0, off, 0xffffffff)
+	lo := d.Read(0, off) & 0xfffffff0
+	d.Write(0, off, bar)
+
+	if bits.Get(&bar, 1, 0b11) != 2 {
+		if lo == 0 {
+			return 0
+		}
+
+		return uint64(^lo + 1)
+	}
+
+	val := d.Read(0, off+4)
+	d.Write(0, off+4, 0xffffffff)
+	hi := d.Read(0, off+4)
+	d.Write(0, off+4, val)
+
+	return ^(uint64(hi)<<32 | uint64(lo)) + 1
+}
+
 func (d *Device) probe() bool {
 	if d.Bus > maxBuses {
 		return false
@@ -107,20 +163,29 @@ func (d *Device) probe() bool {
 	}
 
 	d.Device = uint16(val >> 16)
+	d.Class = d.Read(0, RevisionID) >> 8
 
 	return true
 }
 
-// Probe probes a PCI device.
+// multiFunction returns whether the device implements more than one function.
+func (d *Device) multiFunction() bool {
+	return (d.Read(0, 0x0c)>>16)&(1<<HEADER_TYPE_MF) != 0
+}
+
+// Probe returns the first PCI device, found on the argument bus, matching the
+// argument vendor and device IDs.
+//
+// All buses are walked as in [Scan], including the ones behind PCI-to-PCI
+// bridges, without binding drivers.
 func Probe(bus int, vendor uint16, device uint16) *Device {
-	d := &Device{
-		Bus: uint32(bus),
-	}
+	var devices []*Device
 
-	for slot := uint32(0); slot < maxDevices; slot++ {
-		d.Slot = slot
+	next := uint32(0)
+	scan(0, &next, &devices)
 
-		if d.probe() && d.Vendor == vendor && d.Device == device {
+	for _, d := range devices {
+		if d.Bus == uint32(bus) && d.Vendor == vendor && d.Device == device {
 			return d
 		}
 	}
@@ -128,7 +193,8 @@ func Probe(bus int, vendor uint16, device uint16) *Device {
 	return nil
 }
 
-// Devices returns all found PCI devices on a given bus.
+// Devices returns all found PCI devices on a given bus, the additional
+// functions of multi-function devices are returned as separate devices.
 func Devices(bus int) (devices []*Device) {
 	for slot := uint32(0); slot < maxDevices; slot++ {
 		d := &Device{
@@ -136,8 +202,26 @@ func Devices(bus int) (devices []*Device) {
 			Slot: slot,
 		}
 
-		if d.probe() {
-			devices = append(devices, d)
+		if !d.probe() {
+			continue
+		}
+
+		devices = append(devices, d)
+
+		if !d.multiFunction() {
+			continue
+		}
+
+		for fn := uint32(1); fn < maxFunctions; fn++ {
+			f := &Device{
+				Bus:      uint32(bus),
+				Slot:     slot,
+				Function: fn,
+			}
+
+			if f.probe() {
+				devices = append(devices, f)
+			}
 		}
 	}
 
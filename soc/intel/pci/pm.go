@@ -0,0 +1,302 @@
+// Intel Peripheral Component Interconnect (PCI) driver
+// https://github.com/karlo195/tamago
+//
+// Copyright (c) The TamaGo Authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+package pci
+
+import (
+	"errors"
+	"time"
+
+	"github.com/karlo195/tamago/bits"
+	"github.com/karlo195/tamago/dma"
+)
+
+// Power Management registers
+// (PCI Bus Power Management Interface Specification Revision 1.2 - 3.2 Power
+// Management Register Block Definition).
+const (
+	PMC_VERSION = 0
+	PMC_D1      = 9
+	PMC_D2      = 10
+	PMC_PME     = 11
+
+	PMCSR_STATE         = 0
+	PMCSR_NO_SOFT_RESET = 3
+	PMCSR_PME_EN        = 8
+	PMCSR_PME_STATUS    = 15
+)
+
+// Device power states
+const (
+	D0 = iota
+	D1
+	D2
+	D3hot
+)
+
+// Power state transition recovery times
+// (PCI Bus Power Management Interface Specification Revision 1.2 - 5.6.1
+// State Transition Recovery Time Requirements).
+const (
+	D2Delay    = 200 * time.Microsecond
+	D3hotDelay = 10 * time.Millisecond
+)
+
+// configRegister represents a saved configuration space register.
+type configRegister struct {
+	off uint32
+	val uint32
+	// writable bits to restore, excluding read-only or write-one-to-clear
+	// ones.
+	mask uint32
+}
+
+// CapabilityPM represents a Power Management Capability Structure
+// (PCI Bus Power Management Interface Specification Revision 1.2 - 3.2 Power
+// Management Register Block Definition).
+//
+// The configuration context saved on transitions to D3hot, and restored on
+// return to D0, is held in the instance which must therefore be re-used
+// across transitions.
+type CapabilityPM struct {
+	CapabilityHeader
+
+	PMC   uint16
+	PMCSR uint16
+
+	device *Device
+	off    uint32
+
+	// saved configuration context
+	context []configRegister
+	msix    *CapabilityMSIX
+	control uint32
+	table   [][]byte
+}
+
+// Unmarshal decodes a PCI Power Management Capability from the argument
+// device configuration space at function 0 and the given register offset.
+func (pm *CapabilityPM) Unmarshal(d *Device, off uint32) (err error) {
+	val := d.Read(0, off)
+	pm.Vendor = uint8(val & 0xff)
+	pm.Next = uint8(val >> 8)
+	pm.PMC = uint16(val >> 16)
+	pm.PMCSR = uint16(d.Read(0, off+4))
+
+	pm.device = d
+	pm.off = off
+
+	return
+}
+
+// Offset returns the Capability configuration space offset.
+func (pm *CapabilityPM) Offset() uint32 {
+	return pm.off
+}
+
+// Supported returns whether the argument power state is supported by the
+// device, D0 and D3hot are always supported.
+func (pm *CapabilityPM) Supported(state int) bool {
+	pmc := uint32(pm.PMC)
+
+	switch state {
+	case D0, D3hot:
+		return true
+	case D1:
+		return bits.IsSet(&pmc, PMC_D1)
+	case D2:
+		return bits.IsSet(&pmc, PMC_D2)
+	}
+
+	return false
+}
+
+// PMESupport returns the power states from which the device can generate
+// PME (Power Management Event) messages, as a bitmask where bit n
+// represents Dn (D3cold being bit 4).
+func (pm *CapabilityPM) PMESupport() uint8 {
+	pmc := uint32(pm.PMC)
+	return uint8(bits.Get(&pmc, PMC_PME, 0b11111))
+}
+
+// NoSoftReset returns whether the device preserves its configuration context
+// on D3hot to D0 transitions.
+func (pm *CapabilityPM) NoSoftReset() bool {
+	pmcsr := uint32(pm.PMCSR)
+	return bits.IsSet(&pmcsr, PMCSR_NO_SOFT_RESET)
+}
+
+// State returns the current device power state.
+func (pm *CapabilityPM) State() int {
+	if pm.device == nil {
+		return D0
+	}
+
+	pm.PMCSR = uint16(pm.device.Read(0, pm.off+4))
+	pmcsr := uint32(pm.PMCSR)
+
+	return int(bits.Get(&pmcsr, PMCSR_STATE, 0b11))
+}
+
+func recoveryTime(state int) time.Duration {
+	switch state {
+	case D2:
+		return D2Delay
+	case D3hot:
+		return D3hotDelay
+	}
+
+	return 0
+}
+
+// SetState transitions the device to the argument power state, waiting for
+// the required recovery time.
+//
+// On transitions to D3hot the device configuration context (header, MSI,
+// MSI-X and PCI Express control registers, MSI-X table) is saved, unless the
+// device preserves it, to be restored on return to D0.
+func (pm *CapabilityPM) SetState(state int) (err error) {
+	if pm.device == nil {
+		return errors.New("invalid capabilty instance")
+	}
+
+	if !pm.Supported(state) {
+		return errors.New("unsupported power state")
+	}
+
+	current := pm.State()
+
+	if state == current {
+		return
+	}
+
+	// D3hot can only transition to D0
+	if current == D3hot && state != D0 {
+		return errors.New("invalid power state transition")
+	}
+
+	if state == D3hot && !pm.NoSoftReset() {
+		pm.save()
+	}
+
+	pmcsr := uint32(pm.PMCSR)
+
+	// preserve PME status, which is cleared by writing one
+	bits.Clear(&pmcsr, PMCSR_PME_STATUS)
+	bits.SetN(&pmcsr, PMCSR_STATE, 0b11, uint32(state))
+
+	pm.device.Write(0, pm.off+4, pmcsr)
+	time.Sleep(max(recoveryTime(current), recoveryTime(state)))
+
+	if pm.State() != state {
+		return errors.New("power state transition failed")
+	}
+
+	if current == D3hot {
+		pm.restore()
+	}
+
+	return
+}
+
+// save saves the device configuration context which can be lost on D3hot to
+// D0 transitions, ordered so that resources are restored before being
+// enabled.
+func (pm *CapabilityPM) save() {
+	d := pm.device
+
+	pm.context = nil
+	pm.msix = nil
+	pm.table = nil
+
+	add := func(off uint32, mask uint32) {
+		pm.context = append(pm.context, configRegister{off, d.Read(0, off), mask})
+	}
+
+	// Cache Line Size, Latency Timer
+	add(0x0c, 0x0000ffff)
+
+	for n := uint32(0); n < 6; n++ {
+		add(Bar0+n*4, 0xffffffff)
+	}
+
+	// Interrupt Line
+	add(0x3c, 0x000000ff)
+	add(Command, 0x0000ffff)
+
+	for _, c := range d.Capabilities() {
+		switch c := c.(type) {
+		case *CapabilityMSI:
+			// Message Address and Data, Mask Bits, before Message
+			// Control
+			end := c.dataOffset() + 4
+
+			if c.PerVectorMasking() {
+				end += 4
+			}
+
+			for off := c.off + 4; off < end; off += 4 {
+				add(off, 0xffffffff)
+			}
+
+			add(c.off, 0xffff0000)
+		case *CapabilityMSIX:
+			// the table resides in memory space and it is restored
+			// separately, before Message Control
+			pm.msix = c
+			pm.control = d.Read(0, c.off) & 0xffff0000
+			pm.table = make([][]byte, c.TableSize())
+
+			for n := range pm.table {
+				ptr, entry, err := c.entry(n)
+
+				if err != nil {
+					break
+				}
+
+				pm.table[n] = append([]byte{}, entry...)
+				dma.Release(ptr)
+			}
+		case *CapabilityPCIe:
+			// Device Control, Link Control
+			add(c.off+0x08, 0x0000ffff)
+			add(c.off+0x10, 0x0000ffff)
+		}
+	}
+}
+
+// restore restores the device configuration context saved on transition to
+// D3hot.
+func (pm *CapabilityPM) restore() {
+	for _, r := range pm.context {
+		pm.device.Write(0, r.off, r.val&r.mask)
+	}
+
+	if pm.msix != nil {
+		for n, buf := range pm.table {
+			if buf == nil {
+				continue
+			}
+
+			ptr, entry, err := pm.msix.entry(n)
+
+			if err != nil {
+				continue
+			}
+
+			copy(entry, buf)
+			dma.Release(ptr)
+		}
+
+		pm.device.Write(0, pm.msix.off, pm.control)
+	}
+
+	pm.context = nil
+	pm.msix = nil
+	pm.table = nil
+}
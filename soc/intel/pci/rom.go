@@ -0,0 +1,184 @@
+// Intel Peripheral Component Interconnect (PCI) driver
+// https://github.com/karlo195/tamago
+//
+// Copyright (c) The TamaGo Authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+package pci
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/karlo195/tamago/dma"
+)
+
+// Expansion ROM Base Address register
+// (PCI Local Bus Specification, revision 3.0 - 6.2.5.2 Expansion ROM Base
+// Address Register).
+const (
+	ExpansionROM       = 0x30
+	ExpansionROMBridge = 0x38
+
+	ROM_ENABLE       = 0
+	ROM_ADDRESS_MASK = 0xfffff800
+)
+
+// Expansion ROM image format
+// (PCI Local Bus Specification, revision 3.0 - 6.3.1 PCI Expansion ROM
+// Contents).
+const (
+	romSignature  = 0xaa55
+	romPCIR       = 0x18
+	romBlockSize  = 512
+	pcirSignature = "PCIR"
+	pcirLength    = 0x10
+	pcirIndicator = 0x15
+	pcirLastImage = 7
+)
+
+// ExpansionROMOffset returns the configuration space offset of the device
+// Expansion ROM Base Address register, which depends on its header type.
+func (d *Device) ExpansionROMOffset() uint32 {
+	if d.HeaderType() == HEADER_TYPE_BRIDGE {
+		return ExpansionROMBridge
+	}
+
+	return ExpansionROM
+}
+
+// ExpansionROMAddress returns the address assigned to the device Expansion
+// ROM, zero is returned when unassigned.
+func (d *Device) ExpansionROMAddress() uint32 {
+	return d.Read(0, d.ExpansionROMOffset()) & ROM_ADDRESS_MASK
+}
+
+// ExpansionROMSize returns the size of the address space decoded by the
+// device Expansion ROM Base Address register, zero is returned when the
+// device does not implement an Expansion ROM.
+func (d *Device) ExpansionROMSize() uint32 {
+	off := d.ExpansionROMOffset()
+	rom := d.Read(0, off)
+
+	// disable memory decoding while sizing
+	cmd := d.Read(0, Command) & 0xffff
+	d.Write(0, Command, cmd&^(1<<CMD_MEM_SPACE))
+	defer d.Write(0, Command, cmd)
+
+	d.Write(0, off, ROM_ADDRESS_MASK)
+	val := d.Read(0, off) & ROM_ADDRESS_MASK
+	d.Write(0, off, rom)
+
+	if val == 0 {
+		return 0
+	}
+
+	return ^val + 1
+}
+
+// EnableExpansionROM controls the device Expansion ROM address decoding,
+// which requires an assigned address (see Allocator.AssignExpansionROM) and
+// memory space decoding to be enabled.
+func (d *Device) EnableExpansionROM(on bool) (err error) {
+	off := d.ExpansionROMOffset()
+	rom := d.Read(0, off)
+
+	if on && rom&ROM_ADDRESS_MASK == 0 {
+		return errors.New("unassigned Expansion ROM address")
+	}
+
+	if on {
+		rom |= 1 << ROM_ENABLE
+	} else {
+		rom &^= 1 << ROM_ENABLE
+	}
+
+	d.Write(0, off, rom)
+
+	return
+}
+
+// ReadExpansionROM returns the device Expansion ROM contents, trimmed to the
+// end of its last image, for analysis or measurement (e.g. hashing of
+// passthrough device option ROMs).
+//
+// The Expansion ROM must have an assigned address (see
+// Allocator.AssignExpansionROM), its decoding is enabled only for the
+// duration of the read as devices are allowed to share decoders between the
+// Expansion ROM and other BARs.
+func (d *Device) ReadExpansionROM() (buf []byte, err error) {
+	addr := d.ExpansionROMAddress()
+	size := d.ExpansionROMSize()
+
+	if size == 0 {
+		return nil, errors.New("no Expansion ROM")
+	}
+
+	if addr == 0 {
+		return nil, errors.New("unassigned Expansion ROM address")
+	}
+
+	cmd := d.Read(0, Command) & 0xffff
+	d.Write(0, Command, cmd|1<<CMD_MEM_SPACE)
+	defer d.Write(0, Command, cmd)
+
+	if err = d.EnableExpansionROM(true); err != nil {
+		return
+	}
+
+	defer d.EnableExpansionROM(false)
+
+	r, err := dma.NewRegion(uint(addr), int(size), false)
+
+	if err != nil {
+		return
+	}
+
+	_, rom := r.Reserve(int(size), 0)
+
+	n, err := romLength(rom)
+
+	if err != nil {
+		return
+	}
+
+	buf = make([]byte, n)
+	copy(buf, rom)
+
+	return
+}
+
+// romLength returns the length of the Expansion ROM images, up to the one
+// flagged as last.
+func romLength(rom []byte) (n int, err error) {
+	for n+romPCIR+2 <= len(rom) {
+		img := rom[n:]
+
+		if binary.LittleEndian.Uint16(img) != romSignature {
+			return 0, fmt.Errorf("invalid image signature at %#x", n)
+		}
+
+		pcir := int(binary.LittleEndian.Uint16(img[romPCIR:]))
+
+		if pcir+pcirIndicator >= len(img) || string(img[pcir:pcir+4]) != pcirSignature {
+			return 0, fmt.Errorf("invalid PCI data structure at %#x", n)
+		}
+
+		length := int(binary.LittleEndian.Uint16(img[pcir+pcirLength:])) * romBlockSize
+
+		if length == 0 || n+length > len(rom) {
+			return 0, fmt.Errorf("invalid image length at %#x", n)
+		}
+
+		n += length
+
+		if img[pcir+pcirIndicator]&(1<<pcirLastImage) != 0 {
+			return
+		}
+	}
+
+	return 0, errors.New("missing last image")
+}
@@ -0,0 +1,266 @@
+// Intel Peripheral Component Interconnect (PCI) driver
+// https://github.com/karlo195/tamago
+//
+// Copyright (c) The TamaGo Authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+package pci
+
+import (
+	"errors"
+	"time"
+)
+
+// Single Root I/O Virtualization registers
+// (PCI Express Base Specification Revision 4.0 - 9.3.3 SR-IOV Extended
+// Capability).
+const (
+	SRIOV_CAPABILITIES = 0x04
+	SRIOV_CONTROL      = 0x08
+	SRIOV_INITIAL_VFS  = 0x0c
+	SRIOV_NUM_VFS      = 0x10
+	SRIOV_VF_OFFSET    = 0x14
+	SRIOV_VF_DEVICE_ID = 0x18
+	SRIOV_VF_BAR0      = 0x24
+
+	// SR-IOV Control register bits
+	SRIOV_CTRL_VF_ENABLE = 0
+	SRIOV_CTRL_VF_MSE    = 3
+	SRIOV_CTRL_ARI       = 4
+)
+
+// VFEnableDelay is the time to wait, after enabling Virtual Functions, before
+// issuing configuration requests to them (PCI Express Base Specification
+// Revision 4.0 - 9.3.3.3.1 VF Enable).
+const VFEnableDelay = 100 * time.Millisecond
+
+// VirtualFunction represents an SR-IOV Virtual Function.
+type VirtualFunction struct {
+	// Device is the Virtual Function device, the Vendor ID is inherited
+	// from its Physical Function.
+	Device *Device
+	// Function is the Virtual Function function number.
+	Function uint32
+	// Index is the Virtual Function number (starting from 1).
+	Index int
+}
+
+// Read reads the Virtual Function configuration space.
+func (vf *VirtualFunction) Read(off uint32) (uint32, error) {
+	return vf.Device.ReadExtended(vf.Function, off)
+}
+
+// Write writes the Virtual Function configuration space.
+func (vf *VirtualFunction) Write(off uint32, val uint32) error {
+	return vf.Device.WriteExtended(vf.Function, off, val)
+}
+
+// CapabilitySRIOV represents a Single Root I/O Virtualization Extended
+// Capability Structure.
+type CapabilitySRIOV struct {
+	ExtendedCapabilityHeader
+
+	InitialVFs uint16
+	TotalVFs   uint16
+	VFDeviceID uint16
+
+	device *Device
+	off    uint32
+}
+
+// Unmarshal decodes a PCI Express SR-IOV Extended Capability from the
+// argument device configuration space at function 0 and the given register
+// offset.
+func (sriov *CapabilitySRIOV) Unmarshal(d *Device, off uint32) (err error) {
+	val, err := d.ReadExtended(0, off)
+
+	if err != nil {
+		return
+	}
+
+	sriov.unmarshal(val)
+
+	if val, err = d.ReadExtended(0, off+SRIOV_INITIAL_VFS); err != nil {
+		return
+	}
+
+	sriov.InitialVFs = uint16(val)
+	sriov.TotalVFs = uint16(val >> 16)
+
+	if val, err = d.ReadExtended(0, off+SRIOV_VF_DEVICE_ID); err != nil {
+		return
+	}
+
+	sriov.VFDeviceID = uint16(val >> 16)
+
+	sriov.device = d
+	sriov.off = off
+
+	return
+}
+
+// Offset returns the Extended Capability configuration space offset.
+func (sriov *CapabilitySRIOV) Offset() uint32 {
+	return sriov.off
+}
+
+// VFBaseAddress returns the base address of the argument Virtual Function
+// BAR, the region of each Virtual Function follows the previous one with the
+// VF BAR aperture size.
+func (sriov *CapabilitySRIOV) VFBaseAddress(n int) (addr uint64, err error) {
+	if sriov.device == nil || n < 0 || n > 5 {
+		return 0, errors.New("invalid capabilty instance or BAR")
+	}
+
+	off := sriov.off + SRIOV_VF_BAR0 + uint32(n)*4
+	bar, err := sriov.device.ReadExtended(0, off)
+
+	if err != nil || bar&1 != 0 {
+		return
+	}
+
+	addr = uint64(bar &^ 0xf)
+
+	// 64-bit BAR
+	if (bar>>1)&0b11 == 2 && n < 5 {
+		hi, err := sriov.device.ReadExtended(0, off+4)
+
+		if err != nil {
+			return 0, err
+		}
+
+		addr |= uint64(hi) << 32
+	}
+
+	return
+}
+
+// Enabled returns the number of enabled Virtual Functions.
+func (sriov *CapabilitySRIOV) Enabled() (n int, err error) {
+	if sriov.device == nil {
+		return 0, errors.New("invalid capabilty instance")
+	}
+
+	ctrl, err := sriov.device.ReadExtended(0, sriov.off+SRIOV_CONTROL)
+
+	if err != nil || ctrl&(1<<SRIOV_CTRL_VF_ENABLE) == 0 {
+		return
+	}
+
+	val, err := sriov.device.ReadExtended(0, sriov.off+SRIOV_NUM_VFS)
+
+	return int(uint16(val)), err
+}
+
+// Enable enables the argument number of Virtual Functions, with memory space
+// decoding, and returns them.
+//
+// The VF BARs must have been previously assigned (e.g. by firmware).
+func (sriov *CapabilitySRIOV) Enable(n int) (vfs []*VirtualFunction, err error) {
+	if sriov.device == nil {
+		return nil, errors.New("invalid capabilty instance")
+	}
+
+	if n < 1 || n > int(sriov.TotalVFs) {
+		return nil, errors.New("invalid number of Virtual Functions")
+	}
+
+	if err = sriov.Disable(); err != nil {
+		return
+	}
+
+	// VF Offset and Stride depend on NumVFs, which must be set before
+	// enabling
+	if err = sriov.device.WriteExtended(0, sriov.off+SRIOV_NUM_VFS, uint32(n)); err != nil {
+		return
+	}
+
+	ctrl, err := sriov.device.ReadExtended(0, sriov.off+SRIOV_CONTROL)
+
+	if err != nil {
+		return
+	}
+
+	ctrl &= 0xffff
+	ctrl |= 1<<SRIOV_CTRL_VF_ENABLE | 1<<SRIOV_CTRL_VF_MSE
+
+	if err = sriov.device.WriteExtended(0, sriov.off+SRIOV_CONTROL, ctrl); err != nil {
+		return
+	}
+
+	time.Sleep(VFEnableDelay)
+
+	return sriov.VirtualFunctions()
+}
+
+// Disable disables all Virtual Functions.
+func (sriov *CapabilitySRIOV) Disable() (err error) {
+	if sriov.device == nil {
+		return errors.New("invalid capabilty instance")
+	}
+
+	ctrl, err := sriov.device.ReadExtended(0, sriov.off+SRIOV_CONTROL)
+
+	if err != nil {
+		return
+	}
+
+	if ctrl&(1<<SRIOV_CTRL_VF_ENABLE) == 0 {
+		return
+	}
+
+	ctrl &= 0xffff
+	ctrl &^= 1<<SRIOV_CTRL_VF_ENABLE | 1<<SRIOV_CTRL_VF_MSE
+
+	if err = sriov.device.WriteExtended(0, sriov.off+SRIOV_CONTROL, ctrl); err != nil {
+		return
+	}
+
+	// allow VFs to complete outstanding transactions
+	time.Sleep(VFEnableDelay)
+
+	return
+}
+
+// VirtualFunctions returns the enabled Virtual Functions, located through
+// their Routing ID offset from the Physical Function one
+// (PCI Express Base Specification Revision 4.0 - 9.3.3.9 First VF Offset and
+// 9.3.3.10 VF Stride).
+func (sriov *CapabilitySRIOV) VirtualFunctions() (vfs []*VirtualFunction, err error) {
+	n, err := sriov.Enabled()
+
+	if err != nil || n == 0 {
+		return
+	}
+
+	val, err := sriov.device.ReadExtended(0, sriov.off+SRIOV_VF_OFFSET)
+
+	if err != nil {
+		return
+	}
+
+	offset := val & 0xffff
+	stride := val >> 16
+
+	// Physical Function Routing ID
+	pf := sriov.device.routingID()
+
+	for i := 1; i <= n; i++ {
+		rid := pf + offset + stride*uint32(i-1)
+
+		vfs = append(vfs, &VirtualFunction{
+			Device: &Device{
+				Bus:    rid >> 8,
+				Slot:   (rid >> 3) & 0x1f,
+				Vendor: sriov.device.Vendor,
+				Device: sriov.VFDeviceID,
+			},
+			Function: rid & 0b111,
+			Index:    i,
+		})
+	}
+
+	return
+}
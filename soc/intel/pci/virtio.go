@@ -0,0 +1,143 @@
+// Intel Peripheral Component Interconnect (PCI) driver
+// https://github.com/karlo195/tamago
+//
+// Copyright (c) The TamaGo Authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+package pci
+
+import (
+	"errors"
+
+	"github.com/karlo195/tamago/dma"
+)
+
+// VirtIOVendorID is the PCI Vendor ID of VirtIO devices.
+const VirtIOVendorID = 0x1af4
+
+// VirtIO PCI capability configuration types
+// (Virtual I/O Device (VIRTIO) Version 1.2 - 4.1.4 Virtio Structure PCI
+// Capabilities).
+const (
+	VIRTIO_PCI_CAP_COMMON_CFG        = 1
+	VIRTIO_PCI_CAP_NOTIFY_CFG        = 2
+	VIRTIO_PCI_CAP_ISR_CFG           = 3
+	VIRTIO_PCI_CAP_DEVICE_CFG        = 4
+	VIRTIO_PCI_CAP_PCI_CFG           = 5
+	VIRTIO_PCI_CAP_SHARED_MEMORY_CFG = 8
+	VIRTIO_PCI_CAP_VENDOR_CFG        = 9
+)
+
+// struct virtio_pci_cap layout
+const (
+	virtioCapLength = 16
+	virtioCapType   = 3
+	virtioCapBar    = 4
+	virtioCapID     = 5
+	virtioCapOffset = 8
+	virtioCapSize   = 12
+)
+
+// CapabilityVirtIO represents a VirtIO Structure PCI Capability, a Vendor
+// Specific Capability locating a VirtIO configuration structure within a
+// device BAR.
+type CapabilityVirtIO struct {
+	CapabilityHeader
+
+	// Length is the capability length.
+	Length uint8
+	// Type is the configuration structure type (cfg_type).
+	Type uint8
+	// Bar is the index of the BAR holding the configuration structure.
+	Bar uint8
+	// ID identifies multiple capabilities of the same type (e.g. shared
+	// memory regions).
+	ID uint8
+	// BarOffset is the configuration structure offset within its BAR,
+	// including the upper bits of shared memory capabilities
+	// (struct virtio_pci_cap64).
+	BarOffset uint64
+	// Size is the configuration structure length, including the upper
+	// bits of shared memory capabilities (struct virtio_pci_cap64).
+	Size uint64
+	// NotifyOffMultiplier is the queue notification address multiplier,
+	// only valid for VIRTIO_PCI_CAP_NOTIFY_CFG capabilities.
+	NotifyOffMultiplier uint32
+
+	device *Device
+	off    uint32
+}
+
+// Unmarshal decodes a VirtIO Structure PCI Capability from the argument device
+// configuration space at function 0 and the given register offset.
+func (c *CapabilityVirtIO) Unmarshal(d *Device, off uint32) (err error) {
+	val := d.Read(0, off)
+	c.Vendor = uint8(val)
+	c.Next = uint8(val >> 8)
+	c.Length = uint8(val >> 16)
+	c.Type = uint8(val >> (virtioCapType * 8))
+
+	if c.Length < virtioCapLength {
+		return errors.New("invalid VirtIO capability length")
+	}
+
+	val = d.Read(0, off+virtioCapBar)
+	c.Bar = uint8(val)
+	c.ID = uint8(val >> ((virtioCapID - virtioCapBar) * 8))
+
+	c.BarOffset = uint64(d.Read(0, off+virtioCapOffset))
+	c.Size = uint64(d.Read(0, off+virtioCapSize))
+
+	switch c.Type {
+	case VIRTIO_PCI_CAP_NOTIFY_CFG:
+		c.NotifyOffMultiplier = d.Read(0, off+virtioCapLength)
+	case VIRTIO_PCI_CAP_SHARED_MEMORY_CFG:
+		c.BarOffset |= uint64(d.Read(0, off+virtioCapLength)) << 32
+		c.Size |= uint64(d.Read(0, off+virtioCapLength+4)) << 32
+	}
+
+	c.device = d
+	c.off = off
+
+	return
+}
+
+// Offset returns the Capability configuration space offset.
+func (c *CapabilityVirtIO) Offset() uint32 {
+	return c.off
+}
+
+// Address returns the configuration structure physical address.
+func (c *CapabilityVirtIO) Address() uint64 {
+	if c.device == nil || c.Bar > 5 {
+		return 0
+	}
+
+	bar := uint64(c.device.BaseAddress(int(c.Bar))) &^ 0xf
+
+	if bar == 0 {
+		return 0
+	}
+
+	return bar + c.BarOffset
+}
+
+// Map returns the configuration structure physical address and its memory
+// mapped contents.
+func (c *CapabilityVirtIO) Map() (addr uint64, buf []byte, err error) {
+	if addr = c.Address(); addr == 0 || c.Size == 0 {
+		return 0, nil, errors.New("invalid VirtIO capability region")
+	}
+
+	r, err := dma.NewRegion(uint(addr), int(c.Size), false)
+
+	if err != nil {
+		return 0, nil, errors.New("invalid VirtIO capability region")
+	}
+
+	_, buf = r.Reserve(int(c.Size), 0)
+
+	return
+}
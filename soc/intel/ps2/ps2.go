@@ -0,0 +1,490 @@
+// i8042 PS/2 keyboard driver
+// https://github.com/karlo195/tamago
+//
+// Copyright (c) The TamaGo Authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+// Package ps2 implements a driver for PS/2 keyboards attached to Intel 8042
+// compatible keyboard controllers, with scan code set 2 decoding, adopting
+// the following reference specifications:
+//   - IBM PC AT Technical Reference - March 1984
+//   - IBM Personal System/2 Hardware Interface Technical Reference - 1988
+//
+// Keyboard input is interrupt driven, the controller first port interrupt
+// (ISA IRQ1) is routed through an I/O APIC and serviced with
+// [CPU.ServiceInterrupts], key events are then available through a channel or
+// as terminal input through an io.Reader interface.
+//
+// This package is only meant to be used with `GOOS=tamago GOARCH=amd64` as
+// supported by the TamaGo framework for bare metal Go, see
+// https://github.com/karlo195/tamago.
+//
+// [CPU.ServiceInterrupts]: https://pkg.go.dev/github.com/karlo195/tamago/amd64#CPU.ServiceInterrupts
+package ps2
+
+import (
+	"errors"
+	"time"
+	"unicode/utf8"
+
+	"github.com/karlo195/tamago/amd64"
+	"github.com/karlo195/tamago/internal/reg"
+	"github.com/karlo195/tamago/soc/intel/ioapic"
+)
+
+// i8042 registers
+const (
+	DATA = 0x60
+
+	STATUS     = 0x64
+	STATUS_OBF = 0
+	STATUS_IBF = 1
+
+	COMMAND = 0x64
+)
+
+// i8042 commands
+const (
+	CMD_READ_CONFIG   = 0x20
+	CMD_WRITE_CONFIG  = 0x60
+	CMD_DISABLE_PORT2 = 0xa7
+	CMD_SELF_TEST     = 0xaa
+	CMD_TEST_PORT1    = 0xab
+	CMD_DISABLE_PORT1 = 0xad
+	CMD_ENABLE_PORT1  = 0xae
+
+	// controller configuration byte
+	CONFIG_IRQ1        = 0
+	CONFIG_IRQ2        = 1
+	CONFIG_TRANSLATION = 6
+)
+
+// Keyboard commands and responses
+const (
+	KBD_SET_LEDS     = 0xed
+	KBD_SCANCODE_SET = 0xf0
+	KBD_ENABLE_SCAN  = 0xf4
+	KBD_DISABLE_SCAN = 0xf5
+	KBD_RESET        = 0xff
+
+	KBD_ACK          = 0xfa
+	KBD_RESEND       = 0xfe
+	KBD_ECHO         = 0xee
+	KBD_SELF_TEST_OK = 0xaa
+
+	// controller self-test response
+	SELF_TEST_OK = 0x55
+)
+
+// Keyboard LEDs
+const (
+	LED_SCROLL_LOCK = 0
+	LED_NUM_LOCK    = 1
+	LED_CAPS_LOCK   = 2
+)
+
+// DefaultBufferSize is the default number of buffered key events.
+const DefaultBufferSize = 64
+
+// IRQ is the keyboard ISA interrupt line.
+const IRQ = 1
+
+// Modifier flags
+const (
+	ModShift = 1 << iota
+	ModCtrl
+	ModAlt
+	ModCapsLock
+	ModNumLock
+)
+
+const timeout = 100 * time.Millisecond
+
+// Event represents a key event.
+type Event struct {
+	// Code is the scan code set 2 make code, extended keys are prefixed
+	// with 0xe0 (e.g. [KeyUp]).
+	Code uint16
+	// Pressed is true on key press (make) and false on release (break).
+	Pressed bool
+	// Modifiers is the modifier keys and locks state.
+	Modifiers int
+	// Rune is the character translated with a US layout, 0 for keys not
+	// producing characters.
+	Rune rune
+}
+
+// Keyboard represents a PS/2 keyboard instance.
+type Keyboard struct {
+	// BufferSize is the number of buffered key events, further events
+	// are discarded when the buffer is full (default 64).
+	BufferSize int
+
+	events chan Event
+	// Read() pending bytes
+	pending []byte
+
+	// decoder state, accessed only by Handle()
+	extended bool
+	release  bool
+	skip     int
+	mods     int
+}
+
+func waitStatus(pos int, val bool) bool {
+	start := time.Now()
+
+	for time.Since(start) < timeout {
+		if (reg.In8(STATUS)>>pos)&1 == 1 == val {
+			return true
+		}
+	}
+
+	return false
+}
+
+func command(cmd uint8) (err error) {
+	if !waitStatus(STATUS_IBF, false) {
+		return errors.New("controller timeout")
+	}
+
+	reg.Out8(COMMAND, cmd)
+
+	return
+}
+
+func write(val uint8) (err error) {
+	if !waitStatus(STATUS_IBF, false) {
+		return errors.New("controller timeout")
+	}
+
+	reg.Out8(DATA, val)
+
+	return
+}
+
+func read() (val uint8, err error) {
+	if !waitStatus(STATUS_OBF, true) {
+		return 0, errors.New("controller timeout")
+	}
+
+	return reg.In8(DATA), nil
+}
+
+func flush() {
+	for i := 0; i < 16 && (reg.In8(STATUS)>>STATUS_OBF)&1 == 1; i++ {
+		reg.In8(DATA)
+	}
+}
+
+// send transmits a keyboard command, or argument, and waits for its
+// acknowledgment.
+func send(val uint8) (err error) {
+	for retry := 0; retry < 3; retry++ {
+		if err = write(val); err != nil {
+			return
+		}
+
+		res, err := read()
+
+		if err != nil {
+			return err
+		}
+
+		switch res {
+		case KBD_ACK:
+			return nil
+		case KBD_RESEND:
+			continue
+		default:
+			return errors.New("unexpected keyboard response")
+		}
+	}
+
+	return errors.New("keyboard command failed")
+}
+
+// Init initializes the keyboard controller and the keyboard attached to its
+// first port, selecting scan code set 2 with controller translation
+// disabled.
+func (kbd *Keyboard) Init() (err error) {
+	if kbd.BufferSize <= 0 {
+		kbd.BufferSize = DefaultBufferSize
+	}
+
+	kbd.events = make(chan Event, kbd.BufferSize)
+
+	// disable devices during initialization
+	if err = command(CMD_DISABLE_PORT1); err != nil {
+		return
+	}
+
+	command(CMD_DISABLE_PORT2)
+	flush()
+
+	if err = command(CMD_READ_CONFIG); err != nil {
+		return
+	}
+
+	config, err := read()
+
+	if err != nil {
+		return
+	}
+
+	config &^= 1<<CONFIG_IRQ1 | 1<<CONFIG_IRQ2 | 1<<CONFIG_TRANSLATION
+
+	if err = command(CMD_SELF_TEST); err != nil {
+		return
+	}
+
+	if res, err := read(); err != nil || res != SELF_TEST_OK {
+		return errors.New("controller self-test failed")
+	}
+
+	if err = command(CMD_TEST_PORT1); err != nil {
+		return
+	}
+
+	if res, err := read(); err != nil || res != 0 {
+		return errors.New("keyboard port test failed")
+	}
+
+	// the self-test might reset the configuration
+	if err = command(CMD_WRITE_CONFIG); err != nil {
+		return
+	}
+
+	if err = write(config); err != nil {
+		return
+	}
+
+	if err = command(CMD_ENABLE_PORT1); err != nil {
+		return
+	}
+
+	if err = send(KBD_RESET); err != nil {
+		return
+	}
+
+	if res, err := read(); err != nil || res != KBD_SELF_TEST_OK {
+		return errors.New("keyboard self-test failed")
+	}
+
+	if err = send(KBD_SCANCODE_SET); err != nil {
+		return
+	}
+
+	if err = send(2); err != nil {
+		return
+	}
+
+	if err = send(KBD_ENABLE_SCAN); err != nil {
+		return
+	}
+
+	flush()
+
+	// enable first port interrupt
+	if err = command(CMD_WRITE_CONFIG); err != nil {
+		return
+	}
+
+	return write(config | 1<<CONFIG_IRQ1)
+}
+
+// EnableInterrupt routes the keyboard interrupt, through the argument I/O
+// APIC, to a vector allocated on the argument CPU instance, the argument
+// Global System Interrupt should be [IRQ] unless overridden by firmware (see
+// acpi.MADT.GSI).
+//
+// Interrupts are handled by [Keyboard.Handle] once serviced with
+// [CPU.ServiceInterrupts] (with a nil argument).
+//
+// [CPU.ServiceInterrupts]: https://pkg.go.dev/github.com/karlo195/tamago/amd64#CPU.ServiceInterrupts
+func (kbd *Keyboard) EnableInterrupt(cpu *amd64.CPU, io *ioapic.IOAPIC, gsi int) (vector int, err error) {
+	if kbd.events == nil {
+		return 0, errors.New("keyboard not initialized")
+	}
+
+	if vector, err = cpu.AllocateInterrupt(kbd.Handle); err != nil {
+		return
+	}
+
+	io.EnableInterrupt(gsi, vector)
+
+	return
+}
+
+// Handle reads, and decodes, all scan codes available in the controller
+// output buffer, it is meant to be invoked on keyboard interrupts.
+func (kbd *Keyboard) Handle() {
+	for i := 0; i < 16 && (reg.In8(STATUS)>>STATUS_OBF)&1 == 1; i++ {
+		kbd.decode(reg.In8(DATA))
+	}
+}
+
+func (kbd *Keyboard) decode(b uint8) {
+	switch {
+	case kbd.skip > 0:
+		// Pause key sequence
+		kbd.skip--
+		return
+	case b == prefixPause:
+		kbd.skip = 7
+		return
+	case b == prefixExtended:
+		kbd.extended = true
+		return
+	case b == prefixBreak:
+		kbd.release = true
+		return
+	case b == KBD_ACK || b == KBD_RESEND || b == KBD_ECHO || b == 0x00 || b == 0xff:
+		return
+	}
+
+	code := uint16(b)
+
+	if kbd.extended {
+		code |= Extended
+	}
+
+	pressed := !kbd.release
+	kbd.extended = false
+	kbd.release = false
+
+	switch code {
+	case KeyLeftShift, KeyRightShift:
+		kbd.modifier(ModShift, pressed)
+	case KeyLeftCtrl, KeyRightCtrl:
+		kbd.modifier(ModCtrl, pressed)
+	case KeyLeftAlt, KeyRightAlt:
+		kbd.modifier(ModAlt, pressed)
+	case KeyCapsLock:
+		kbd.toggle(ModCapsLock, pressed)
+	case KeyNumLock:
+		kbd.toggle(ModNumLock, pressed)
+	case Extended | KeyLeftShift, Extended | KeyRightShift:
+		// fake shifts within Print Screen sequences
+		return
+	}
+
+	ev := Event{
+		Code:      code,
+		Pressed:   pressed,
+		Modifiers: kbd.mods,
+		Rune:      kbd.translate(code),
+	}
+
+	select {
+	case kbd.events <- ev:
+	default:
+	}
+}
+
+func (kbd *Keyboard) modifier(mod int, pressed bool) {
+	if pressed {
+		kbd.mods |= mod
+	} else {
+		kbd.mods &^= mod
+	}
+}
+
+func (kbd *Keyboard) toggle(mod int, pressed bool) {
+	if !pressed {
+		return
+	}
+
+	kbd.mods ^= mod
+
+	var leds uint8
+
+	if kbd.mods&ModCapsLock != 0 {
+		leds |= 1 << LED_CAPS_LOCK
+	}
+
+	if kbd.mods&ModNumLock != 0 {
+		leds |= 1 << LED_NUM_LOCK
+	}
+
+	// acknowledgments are discarded by decode()
+	write(KBD_SET_LEDS)
+	write(leds)
+}
+
+func (kbd *Keyboard) translate(code uint16) (r rune) {
+	if c, ok := keypad[code]; ok {
+		if kbd.mods&ModNumLock != 0 {
+			return c
+		}
+
+		return
+	}
+
+	c, ok := keymap[code]
+
+	if !ok {
+		return
+	}
+
+	shift := kbd.mods&ModShift != 0
+
+	if c[0] >= 'a' && c[0] <= 'z' && kbd.mods&ModCapsLock != 0 {
+		shift = !shift
+	}
+
+	if r = c[0]; shift {
+		r = c[1]
+	}
+
+	if kbd.mods&ModCtrl != 0 && r >= '@' && r <= '~' {
+		// control characters
+		r &= 0x1f
+	}
+
+	return
+}
+
+// Events returns the key events channel.
+func (kbd *Keyboard) Events() <-chan Event {
+	return kbd.events
+}
+
+// Read implements io.Reader, returning key presses as terminal input: UTF-8
+// characters, control characters and ANSI escape sequences for navigation
+// keys. The function blocks until at least one byte is available.
+func (kbd *Keyboard) Read(buf []byte) (n int, err error) {
+	if kbd.events == nil {
+		return 0, errors.New("keyboard not initialized")
+	}
+
+	for len(kbd.pending) == 0 {
+		ev := <-kbd.events
+
+		if !ev.Pressed {
+			continue
+		}
+
+		if ev.Rune != 0 {
+			kbd.pending = utf8.AppendRune(kbd.pending, ev.Rune)
+			continue
+		}
+
+		code := ev.Code
+
+		// without Num Lock, keypad digits act as navigation keys
+		// which share their extended counterpart make codes
+		if _, ok := keypad[code]; ok {
+			code |= Extended
+		}
+
+		kbd.pending = append(kbd.pending, sequences[code]...)
+	}
+
+	n = copy(buf, kbd.pending)
+	kbd.pending = kbd.pending[n:]
+
+	return
+}
@@ -0,0 +1,118 @@
+// i8042 PS/2 keyboard driver
+// https://github.com/karlo195/tamago
+//
+// Copyright (c) The TamaGo Authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+package ps2
+
+// Scan code set 2 prefixes
+const (
+	prefixExtended = 0xe0
+	prefixPause    = 0xe1
+	prefixBreak    = 0xf0
+
+	// extended key codes are reported with this prefix
+	Extended = prefixExtended << 8
+)
+
+// Scan code set 2 make codes of special keys
+const (
+	KeyEscape    = 0x76
+	KeyBackspace = 0x66
+	KeyTab       = 0x0d
+	KeyEnter     = 0x5a
+	KeyCapsLock  = 0x58
+	KeyNumLock   = 0x77
+	KeyScroll    = 0x7e
+
+	KeyLeftShift  = 0x12
+	KeyRightShift = 0x59
+	KeyLeftCtrl   = 0x14
+	KeyLeftAlt    = 0x11
+
+	KeyF1  = 0x05
+	KeyF2  = 0x06
+	KeyF3  = 0x04
+	KeyF4  = 0x0c
+	KeyF5  = 0x03
+	KeyF6  = 0x0b
+	KeyF7  = 0x83
+	KeyF8  = 0x0a
+	KeyF9  = 0x01
+	KeyF10 = 0x09
+	KeyF11 = 0x78
+	KeyF12 = 0x07
+
+	KeyRightCtrl = Extended | 0x14
+	KeyRightAlt  = Extended | 0x11
+	KeyLeftGUI   = Extended | 0x1f
+	KeyRightGUI  = Extended | 0x27
+	KeyApps      = Extended | 0x2f
+	KeyInsert    = Extended | 0x70
+	KeyDelete    = Extended | 0x71
+	KeyHome      = Extended | 0x6c
+	KeyEnd       = Extended | 0x69
+	KeyPageUp    = Extended | 0x7d
+	KeyPageDown  = Extended | 0x7a
+	KeyUp        = Extended | 0x75
+	KeyDown      = Extended | 0x72
+	KeyLeft      = Extended | 0x6b
+	KeyRight     = Extended | 0x74
+
+	KeyKeypadEnter = Extended | 0x5a
+	KeyKeypadSlash = Extended | 0x4a
+)
+
+// US layout character translation, unshifted and shifted, indexed by make
+// code.
+var keymap = map[uint16][2]rune{
+	0x1c: {'a', 'A'}, 0x32: {'b', 'B'}, 0x21: {'c', 'C'}, 0x23: {'d', 'D'},
+	0x24: {'e', 'E'}, 0x2b: {'f', 'F'}, 0x34: {'g', 'G'}, 0x33: {'h', 'H'},
+	0x43: {'i', 'I'}, 0x3b: {'j', 'J'}, 0x42: {'k', 'K'}, 0x4b: {'l', 'L'},
+	0x3a: {'m', 'M'}, 0x31: {'n', 'N'}, 0x44: {'o', 'O'}, 0x4d: {'p', 'P'},
+	0x15: {'q', 'Q'}, 0x2d: {'r', 'R'}, 0x1b: {'s', 'S'}, 0x2c: {'t', 'T'},
+	0x3c: {'u', 'U'}, 0x2a: {'v', 'V'}, 0x1d: {'w', 'W'}, 0x22: {'x', 'X'},
+	0x35: {'y', 'Y'}, 0x1a: {'z', 'Z'},
+
+	0x45: {'0', ')'}, 0x16: {'1', '!'}, 0x1e: {'2', '@'}, 0x26: {'3', '#'},
+	0x25: {'4', '$'}, 0x2e: {'5', '%'}, 0x36: {'6', '^'}, 0x3d: {'7', '&'},
+	0x3e: {'8', '*'}, 0x46: {'9', '('},
+
+	0x0e: {'`', '~'}, 0x4e: {'-', '_'}, 0x55: {'=', '+'}, 0x5d: {'\\', '|'},
+	0x54: {'[', '{'}, 0x5b: {']', '}'}, 0x4c: {';', ':'}, 0x52: {'\'', '"'},
+	0x41: {',', '<'}, 0x49: {'.', '>'}, 0x4a: {'/', '?'}, 0x29: {' ', ' '},
+
+	KeyEscape:    {0x1b, 0x1b},
+	KeyBackspace: {0x7f, 0x7f},
+	KeyTab:       {'\t', '\t'},
+	KeyEnter:     {'\r', '\r'},
+
+	// keypad
+	0x7c: {'*', '*'}, 0x7b: {'-', '-'}, 0x79: {'+', '+'},
+	KeyKeypadSlash: {'/', '/'},
+	KeyKeypadEnter: {'\r', '\r'},
+}
+
+// keypad digits, translated only with Num Lock enabled
+var keypad = map[uint16]rune{
+	0x70: '0', 0x69: '1', 0x72: '2', 0x7a: '3', 0x6b: '4',
+	0x73: '5', 0x74: '6', 0x6c: '7', 0x75: '8', 0x7d: '9',
+	0x71: '.',
+}
+
+// ANSI escape sequences for navigation keys
+var sequences = map[uint16]string{
+	KeyUp:       "\x1b[A",
+	KeyDown:     "\x1b[B",
+	KeyRight:    "\x1b[C",
+	KeyLeft:     "\x1b[D",
+	KeyHome:     "\x1b[H",
+	KeyEnd:      "\x1b[F",
+	KeyInsert:   "\x1b[2~",
+	KeyDelete:   "\x1b[3~",
+	KeyPageUp:   "\x1b[5~",
+	KeyPageDown: "\x1b[6~",
+}
@@ -9,6 +9,7 @@
 // Package rtc implements a driver for Real Time Clock devices adopting the
 // following reference specifications:
 //   - IBM PC AT Technical Reference - March 1984
+//   - MC146818A Real-Time Clock Plus RAM (RTC) - Motorola Semiconductor Technical Data
 //
 // This package is only meant to be used with `GOOS=tamago GOARCH=amd64` as
 // supported by the TamaGo framework for bare metal Go, see
@@ -19,7 +20,9 @@ import (
 	"errors"
 	"time"
 
+	"github.com/karlo195/tamago/amd64"
 	"github.com/karlo195/tamago/internal/reg"
+	"github.com/karlo195/tamago/soc/intel/ioapic"
 )
 
 // CMOS registers
@@ -30,22 +33,56 @@ const (
 
 // RTC registers
 const (
-	SECONDS = 0x00
-	MINUTES = 0x02
-	HOURS   = 0x04
-	DOW     = 0x07
-	MONTH   = 0x08
-	YEAR    = 0x09
-	CENTURY = 0x32
+	SECONDS       = 0x00
+	SECONDS_ALARM = 0x01
+	MINUTES       = 0x02
+	MINUTES_ALARM = 0x03
+	HOURS         = 0x04
+	HOURS_ALARM   = 0x05
+	DOW           = 0x06
+	DAY           = 0x07
+	MONTH         = 0x08
+	YEAR          = 0x09
+	CENTURY       = 0x32
 
 	STATUSA     = 0x0a
 	STATUSA_UIP = 7
+
+	STATUSB     = 0x0b
+	STATUSB_24H = 1
+	STATUSB_DM  = 2
+	STATUSB_AIE = 5
+
+	STATUSC    = 0x0c
+	STATUSC_AF = 5
+
+	// 12-hour mode PM flag
+	HOURS_PM = 7
 )
 
+// IRQ is the ISA interrupt line of the RTC.
+const IRQ = 8
+
+const defaultCentury = 20
+
+// UpdateTimeout is the maximum time waited for an update cycle to complete,
+// which lasts at most 1984µs after the update-in-progress flag is set.
+const UpdateTimeout = 10 * time.Millisecond
+
 // RTC represents a Real Time Clock instance.
 type RTC struct {
 	// Time zone
 	Location *time.Location
+
+	// Century is the CMOS century register index (see the ACPI FADT
+	// CENTURY field), CENTURY is used when unset, the 21st century is
+	// assumed when the register is not implemented.
+	Century int
+}
+
+// date represents the raw contents of the date and time registers.
+type date struct {
+	ss, mm, hh, dd, MM, yy, cc int
 }
 
 func (rtc *RTC) read(addr int) int {
@@ -53,35 +90,229 @@ func (rtc *RTC) read(addr int) int {
 	return int(reg.In8(CMOS_RTC_IN))
 }
 
+func (rtc *RTC) write(addr int, val int) {
+	reg.Out8(CMOS_RTC_OUT, uint8(addr))
+	reg.Out8(CMOS_RTC_IN, uint8(val))
+}
+
 func bcdToBin(val int) int {
 	return (val & 0x0f) + ((val / 16) * 10)
 }
 
+func binToBcd(val int) int {
+	return (val/10)*16 + val%10
+}
+
+func (rtc *RTC) updating() bool {
+	return (rtc.read(STATUSA)>>STATUSA_UIP)&1 == 1
+}
+
+func (rtc *RTC) readDate() (d date) {
+	century := rtc.Century
+
+	if century == 0 {
+		century = CENTURY
+	}
+
+	d.ss = rtc.read(SECONDS)
+	d.mm = rtc.read(MINUTES)
+	d.hh = rtc.read(HOURS)
+	d.dd = rtc.read(DAY)
+	d.MM = rtc.read(MONTH)
+	d.yy = rtc.read(YEAR)
+	d.cc = rtc.read(century)
+
+	return
+}
+
 // Now() returns the real-time clock.
+//
+// Registers are read outside update cycles, until two consecutive reads
+// match, to prevent inconsistent values across a rollover. Both BCD and
+// binary data modes, as well as 12 and 24 hour modes, are supported.
 func (rtc *RTC) Now() (t time.Time, err error) {
+	var d, last date
+
+	if rtc.Location == nil {
+		if rtc.Location, err = time.LoadLocation(""); err != nil {
+			return
+		}
+	}
+
+	start := time.Now()
+
+	for {
+		if time.Since(start) > UpdateTimeout {
+			err = errors.New("update in progress")
+			return
+		}
+
+		if rtc.updating() {
+			continue
+		}
+
+		d = rtc.readDate()
+
+		if d == last && !rtc.updating() {
+			break
+		}
+
+		last = d
+	}
+
+	status := rtc.read(STATUSB)
+	pm := (d.hh>>HOURS_PM)&1 == 1
+	d.hh &^= 1 << HOURS_PM
+
+	if (status>>STATUSB_DM)&1 == 0 {
+		d.ss = bcdToBin(d.ss)
+		d.mm = bcdToBin(d.mm)
+		d.hh = bcdToBin(d.hh)
+		d.dd = bcdToBin(d.dd)
+		d.MM = bcdToBin(d.MM)
+		d.yy = bcdToBin(d.yy)
+		d.cc = bcdToBin(d.cc)
+	}
+
+	if (status>>STATUSB_24H)&1 == 0 {
+		// 12-hour mode: 12 AM is midnight, 12 PM is noon
+		d.hh %= 12
+
+		if pm {
+			d.hh += 12
+		}
+	}
+
+	if d.cc == 0 {
+		d.cc = defaultCentury
+	}
+
+	if d.MM < 1 || d.MM > 12 || d.dd < 1 || d.dd > 31 || d.hh > 23 || d.mm > 59 || d.ss > 59 {
+		return t, errors.New("invalid date")
+	}
+
+	return time.Date(d.cc*100+d.yy, time.Month(d.MM), d.dd, d.hh, d.mm, d.ss, 0, rtc.Location), nil
+}
+
+// Sync sets the system time of the argument CPU instance, and therefore the
+// runtime wall clock returned by time.Now(), to the real-time clock.
+//
+// The system time is also used as monotonic clock, therefore this function
+// should be invoked early, before timers are created.
+func (rtc *RTC) Sync(cpu *amd64.CPU) (err error) {
+	t, err := rtc.Now()
+
+	if err != nil {
+		return
+	}
+
+	cpu.SetTime(t.UnixNano())
+
+	return
+}
+
+// SetAlarm programs the RTC alarm to fire at the argument time, which must
+// be within the next 24 hours as the alarm only matches hours, minutes and
+// seconds.
+//
+// The alarm state can be polled with [RTC.AlarmFired] or serviced through
+// [RTC.EnableInterrupt], the latter also wakes a halted processor.
+func (rtc *RTC) SetAlarm(t time.Time) (err error) {
 	if rtc.Location == nil {
 		if rtc.Location, err = time.LoadLocation(""); err != nil {
 			return
 		}
 	}
 
-	if a := rtc.read(STATUSA); (a>>STATUSA_UIP)&1 == 1 {
-		err = errors.New("update in progress")
+	now, err := rtc.Now()
+
+	if err != nil {
 		return
 	}
 
-	// We assume that the RTC remains in its initialized state with Data
-	// Mode set to BCD and 24-hour mode.
+	if d := t.Sub(now); d <= 0 || d > 24*time.Hour {
+		return errors.New("invalid alarm time")
+	}
+
+	t = t.In(rtc.Location)
+	status := rtc.read(STATUSB)
+
+	ss := t.Second()
+	mm := t.Minute()
+	hh := t.Hour()
+	pm := false
+
+	if (status>>STATUSB_24H)&1 == 0 {
+		// 12-hour mode: 12 AM is midnight, 12 PM is noon
+		pm = hh >= 12
+
+		if hh %= 12; hh == 0 {
+			hh = 12
+		}
+	}
 
-	ss := bcdToBin(rtc.read(SECONDS))
-	mm := bcdToBin(rtc.read(MINUTES))
-	dd := bcdToBin(rtc.read(DOW))
-	MM := bcdToBin(rtc.read(MONTH))
-	yy := bcdToBin(rtc.read(YEAR))
-	cc := bcdToBin(rtc.read(CENTURY))
+	if (status>>STATUSB_DM)&1 == 0 {
+		ss = binToBcd(ss)
+		mm = binToBcd(mm)
+		hh = binToBcd(hh)
+	}
+
+	if pm {
+		hh |= 1 << HOURS_PM
+	}
+
+	// disable the alarm while it is being updated
+	rtc.write(STATUSB, status&^(1<<STATUSB_AIE))
+
+	rtc.write(SECONDS_ALARM, ss)
+	rtc.write(MINUTES_ALARM, mm)
+	rtc.write(HOURS_ALARM, hh)
+
+	// clear any pending flag
+	rtc.read(STATUSC)
+
+	rtc.write(STATUSB, status|1<<STATUSB_AIE)
+
+	return
+}
+
+// ClearAlarm disables the RTC alarm.
+func (rtc *RTC) ClearAlarm() {
+	rtc.write(STATUSB, rtc.read(STATUSB)&^(1<<STATUSB_AIE))
+	rtc.read(STATUSC)
+}
+
+// AlarmFired returns whether the RTC alarm has fired, reading the RTC
+// interrupt flags clears them and therefore acknowledges the interrupt.
+func (rtc *RTC) AlarmFired() bool {
+	return (rtc.read(STATUSC)>>STATUSC_AF)&1 == 1
+}
+
+// EnableInterrupt configures delivery of RTC interrupts, through the argument
+// I/O APIC, to the argument function which is invoked when the alarm fires.
+// The Global System Interrupt should be [IRQ] unless overridden by firmware
+// (see acpi.MADT.GSI).
+//
+// Interrupts are handled once serviced with [CPU.ServiceInterrupts] (with a
+// nil argument).
+//
+// [CPU.ServiceInterrupts]: https://pkg.go.dev/github.com/karlo195/tamago/amd64#CPU.ServiceInterrupts
+func (rtc *RTC) EnableInterrupt(cpu *amd64.CPU, io *ioapic.IOAPIC, gsi int, fn func()) (vector int, err error) {
+	if fn == nil {
+		return 0, errors.New("invalid handler")
+	}
+
+	handler := func() {
+		if rtc.AlarmFired() {
+			fn()
+		}
+	}
+
+	if vector, err = cpu.AllocateInterrupt(handler); err != nil {
+		return
+	}
 
-	hh := rtc.read(HOURS)
-	hh = ((hh & 0x0f) + (((hh & 0x70) / 16) * 10)) | (hh & 0x80)
+	io.EnableInterrupt(gsi, vector)
 
-	return time.Date(cc*100+yy, time.Month(MM), dd, hh, mm, ss, 0, rtc.Location), nil
+	return
 }
@@ -0,0 +1,272 @@
+// System Management BIOS (SMBIOS) support
+// https://github.com/karlo195/tamago
+//
+// Copyright (c) The TamaGo Authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+// Package smbios implements a parser for System Management BIOS (SMBIOS)
+// tables adopting the following reference specifications:
+//   - DSP0134 - System Management BIOS (SMBIOS) Reference Specification - Version 3.7.0
+//
+// This package is only meant to be used with `GOOS=tamago GOARCH=amd64` as
+// supported by the TamaGo framework for bare metal Go, see
+// https://github.com/karlo195/tamago.
+package smbios
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/karlo195/tamago/internal/mem"
+)
+
+// Legacy entry point search area
+// (5.2.1 SMBIOS 2.1 (32-bit) Entry Point).
+const (
+	SEARCH_START = 0xf0000
+	SEARCH_END   = 0xfffff
+	SEARCH_ALIGN = 16
+)
+
+// Entry point anchor strings
+const (
+	anchor32 = "_SM_"
+	anchor64 = "_SM3_"
+)
+
+// Structure types
+// (7 Structure definitions).
+const (
+	TypeBIOS      = 0
+	TypeSystem    = 1
+	TypeBaseboard = 2
+	TypeChassis   = 3
+	TypeProcessor = 4
+	TypeEnd       = 127
+)
+
+// header length of all structures
+const headerLength = 4
+
+// Structure represents an SMBIOS structure.
+type Structure struct {
+	// Type is the structure type.
+	Type uint8
+	// Handle is the structure handle.
+	Handle uint16
+	// Formatted is the formatted area, including the structure header.
+	Formatted []byte
+	// Strings is the unformed string-set area.
+	Strings []string
+}
+
+// String returns the string referenced by a formatted area byte offset,
+// an empty string is returned for invalid or null references.
+func (s *Structure) String(off int) string {
+	if off >= len(s.Formatted) {
+		return ""
+	}
+
+	n := int(s.Formatted[off])
+
+	if n == 0 || n > len(s.Strings) {
+		return ""
+	}
+
+	return s.Strings[n-1]
+}
+
+// Table represents the SMBIOS structure table.
+type Table struct {
+	// Major is the specification major version.
+	Major int
+	// Minor is the specification minor version.
+	Minor int
+
+	// Structures is the list of parsed structures.
+	Structures []*Structure
+}
+
+// SystemInfo represents the System Information (Type 1) structure
+// (7.2 System Information (Type 1)).
+type SystemInfo struct {
+	Manufacturer string
+	ProductName  string
+	Version      string
+	SerialNumber string
+	UUID         string
+	SKUNumber    string
+	Family       string
+}
+
+// Find locates the SMBIOS entry point through a scan of the legacy BIOS
+// memory area, on EFI systems the entry point address should be obtained
+// from the EFI configuration table instead.
+func Find() (addr uint, err error) {
+	area, err := mem.Read(SEARCH_START, SEARCH_END-SEARCH_START+1)
+
+	if err != nil {
+		return
+	}
+
+	for off := 0; off < len(area); off += SEARCH_ALIGN {
+		if bytes.HasPrefix(area[off:], []byte(anchor64)) || bytes.HasPrefix(area[off:], []byte(anchor32)) {
+			return SEARCH_START + uint(off), nil
+		}
+	}
+
+	return 0, errors.New("SMBIOS entry point not found")
+}
+
+// Parse parses the SMBIOS structure table referenced by the entry point at
+// the argument address (see Find()).
+func Parse(addr uint) (t *Table, err error) {
+	var tableAddr uint
+	var tableSize int
+
+	ep, err := mem.Read(addr, 0x20)
+
+	if err != nil {
+		return
+	}
+
+	t = &Table{}
+
+	switch {
+	case bytes.HasPrefix(ep, []byte(anchor64)):
+		// 5.2.2 SMBIOS 3.0 (64-bit) Entry Point
+		if n := int(ep[0x06]); n > len(ep) || !mem.Checksum(ep[:n]) {
+			return nil, errors.New("invalid entry point checksum")
+		}
+
+		t.Major = int(ep[0x07])
+		t.Minor = int(ep[0x08])
+		tableSize = int(binary.LittleEndian.Uint32(ep[0x0c:]))
+		tableAddr = uint(binary.LittleEndian.Uint64(ep[0x10:]))
+	case bytes.HasPrefix(ep, []byte(anchor32)):
+		// 5.2.1 SMBIOS 2.1 (32-bit) Entry Point
+		if n := int(ep[0x05]); n > len(ep) || !mem.Checksum(ep[:n]) {
+			return nil, errors.New("invalid entry point checksum")
+		}
+
+		t.Major = int(ep[0x06])
+		t.Minor = int(ep[0x07])
+		tableSize = int(binary.LittleEndian.Uint16(ep[0x16:]))
+		tableAddr = uint(binary.LittleEndian.Uint32(ep[0x18:]))
+	default:
+		return nil, errors.New("invalid entry point")
+	}
+
+	if tableAddr == 0 || tableSize == 0 {
+		return nil, errors.New("invalid structure table")
+	}
+
+	buf, err := mem.Read(tableAddr, tableSize)
+
+	if err != nil {
+		return
+	}
+
+	t.Structures, err = parseStructures(buf)
+
+	return
+}
+
+func parseStructures(buf []byte) (structures []*Structure, err error) {
+	for len(buf) >= headerLength {
+		n := int(buf[1])
+
+		if n < headerLength || n > len(buf) {
+			return nil, errors.New("invalid structure length")
+		}
+
+		s := &Structure{
+			Type:      buf[0],
+			Handle:    binary.LittleEndian.Uint16(buf[2:]),
+			Formatted: buf[:n],
+		}
+
+		// the string-set is terminated by a double null
+		end := bytes.Index(buf[n:], []byte{0, 0})
+
+		if end < 0 {
+			return nil, errors.New("invalid structure strings")
+		}
+
+		for _, str := range bytes.Split(buf[n:n+end], []byte{0}) {
+			if len(str) > 0 {
+				s.Strings = append(s.Strings, string(str))
+			}
+		}
+
+		structures = append(structures, s)
+		buf = buf[n+end+2:]
+
+		if s.Type == TypeEnd {
+			break
+		}
+	}
+
+	return
+}
+
+// Lookup returns all structures matching the argument type.
+func (t *Table) Lookup(typ uint8) (structures []*Structure) {
+	for _, s := range t.Structures {
+		if s.Type == typ {
+			structures = append(structures, s)
+		}
+	}
+
+	return
+}
+
+// System returns the System Information (Type 1) structure contents.
+func (t *Table) System() (info *SystemInfo, err error) {
+	systems := t.Lookup(TypeSystem)
+
+	if len(systems) == 0 {
+		return nil, errors.New("system information not found")
+	}
+
+	s := systems[0]
+
+	info = &SystemInfo{
+		Manufacturer: s.String(0x04),
+		ProductName:  s.String(0x05),
+		Version:      s.String(0x06),
+		SerialNumber: s.String(0x07),
+		SKUNumber:    s.String(0x19),
+		Family:       s.String(0x1a),
+	}
+
+	if len(s.Formatted) >= 0x18 {
+		info.UUID = t.uuid(s.Formatted[0x08:0x18])
+	}
+
+	return
+}
+
+// uuid formats a System Information UUID (7.2.1 System — UUID).
+func (t *Table) uuid(b []byte) string {
+	if bytes.Equal(b, make([]byte, 16)) || bytes.Equal(b, bytes.Repeat([]byte{0xff}, 16)) {
+		// not present or not set
+		return ""
+	}
+
+	u := make([]byte, 16)
+	copy(u, b)
+
+	// the first three fields are little-endian since version 2.6
+	if t.Major > 2 || t.Major == 2 && t.Minor >= 6 {
+		u[0], u[1], u[2], u[3] = u[3], u[2], u[1], u[0]
+		u[4], u[5] = u[5], u[4]
+		u[6], u[7] = u[7], u[6]
+	}
+
+	return fmt.Sprintf("%x-%x-%x-%x-%x", u[0:4], u[4:6], u[6:8], u[8:10], u[10:16])
+}
@@ -0,0 +1,148 @@
+// 16550 Universal Asynchronous Receiver/Transmitter (UART) driver
+// https://github.com/karlo195/tamago
+//
+// Copyright (c) The TamaGo Authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+package uart
+
+import (
+	"errors"
+	"runtime"
+	"time"
+
+	"github.com/karlo195/tamago/internal/reg"
+)
+
+// FIFO_SIZE is the transmit and receive FIFO depth.
+const FIFO_SIZE = 16
+
+// DefaultCTSTimeout is the default maximum time transmission is held while the
+// remote end deasserts CTS.
+const DefaultCTSTimeout = 1 * time.Second
+
+// SetFIFO enables, resetting their contents, or disables the transmit and
+// receive FIFOs (PC16550D - 8.6.3 FIFO Control Register), the receive FIFO
+// interrupt trigger level is set to the argument value (TRIGGER_1, TRIGGER_4,
+// TRIGGER_8 or TRIGGER_14).
+func (hw *UART) SetFIFO(on bool, trigger int) (err error) {
+	if trigger < TRIGGER_1 || trigger > TRIGGER_14 {
+		return errors.New("invalid trigger level")
+	}
+
+	hw.Lock()
+	defer hw.Unlock()
+
+	if !on {
+		reg.Out8(hw.Base+FCR, 0)
+		hw.FIFO = false
+		hw.fifo = false
+		return
+	}
+
+	reg.Out8(hw.Base+FCR, uint8(trigger<<FCR_TRIGGER|1<<FCR_TX_RST|1<<FCR_RX_RST|1<<FCR_ENABLE))
+
+	hw.FIFO = true
+	hw.Trigger = trigger
+	hw.fifo = true
+
+	return
+}
+
+// SetFlow enables or disables RTS/CTS hardware flow control.
+//
+// When enabled, transmission of each character is held while the remote end
+// deasserts CTS, up to CTSTimeout, and, in interrupt mode (see [UART.EnableInterrupt]), RTS is deasserted while the
+// receive buffer is more than three quarters full.
+//
+// RTS is asserted when flow control is disabled.
+func (hw *UART) SetFlow(on bool) {
+	hw.Lock()
+	defer hw.Unlock()
+
+	hw.Flow = on
+	hw.throttled = false
+
+	mcr := reg.In8(hw.Base + MCR)
+	reg.Out8(hw.Base+MCR, mcr|1<<MCR_DTR|1<<MCR_RTS)
+}
+
+// waitCTS waits, until CTSTimeout expires, for the remote end to assert CTS.
+func (hw *UART) waitCTS() bool {
+	if reg.In8(hw.Base+MSR)&(1<<MSR_CTS) != 0 {
+		return true
+	}
+
+	timeout := hw.CTSTimeout
+
+	if timeout == 0 {
+		timeout = DefaultCTSTimeout
+	}
+
+	start := time.Now()
+
+	for reg.In8(hw.Base+MSR)&(1<<MSR_CTS) == 0 {
+		// wait for remote end to accept characters
+		if time.Since(start) >= timeout {
+			return false
+		}
+	}
+
+	return true
+}
+
+// throttle updates RTS according to the argument receive buffer occupancy.
+func (hw *UART) throttle(n int) {
+	hw.Lock()
+	defer hw.Unlock()
+
+	if hw.rx == nil {
+		return
+	}
+
+	size := len(hw.rx.buf)
+
+	switch {
+	case !hw.throttled && n >= size*3/4:
+		hw.throttled = true
+	case hw.throttled && n <= size/4:
+		hw.throttled = false
+	default:
+		return
+	}
+
+	mcr := reg.In8(hw.Base + MCR)
+
+	if hw.throttled {
+		mcr &^= 1 << MCR_RTS
+	} else {
+		mcr |= 1 << MCR_RTS
+	}
+
+	reg.Out8(hw.Base+MCR, mcr)
+}
+
+// Empty returns whether the transmitter is idle, with both its holding
+// register (or FIFO) and shift register empty.
+func (hw *UART) Empty() bool {
+	return reg.In8(hw.Base+LSR)&(1<<LSR_TEMT) != 0
+}
+
+// Drain waits, until a timeout expires, for all pending characters to be
+// transmitted (e.g. before a reset, power off or line reconfiguration). This
+// function cannot be used before runtime initialization.
+func (hw *UART) Drain(timeout time.Duration) (err error) {
+	start := time.Now()
+
+	for !hw.Empty() {
+		runtime.Gosched()
+
+		if time.Since(start) >= timeout {
+			return errors.New("timeout draining transmitter")
+		}
+	}
+
+	return
+}
@@ -0,0 +1,155 @@
+// 16550 Universal Asynchronous Receiver/Transmitter (UART) driver
+// https://github.com/karlo195/tamago
+//
+// Copyright (c) The TamaGo Authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+package uart
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/karlo195/tamago/internal/reg"
+)
+
+// DEFAULT_CLOCK is the baud rate generator input clock of PC compatible
+// serial ports.
+const DEFAULT_CLOCK = 1843200
+
+// Divisor latch registers, accessible with LCR_DLAB set.
+const (
+	DLL = 0x00
+	DLM = 0x01
+)
+
+// Parity modes
+const (
+	PARITY_NONE = iota
+	PARITY_ODD
+	PARITY_EVEN
+	PARITY_MARK
+	PARITY_SPACE
+)
+
+// SetLine configures the serial port baud rate, through the divisor latch
+// derived from Clock, and the character format. Zero word length, parity and
+// stop bits select 8, PARITY_NONE and 1 respectively.
+//
+// The baud rate must be within 2% of a rate achievable with the baud rate
+// generator clock (PC16550D - 8.5 Programmable Baud Generator).
+//
+// The divisor latch shares its registers with the transmitter, the serial
+// port must not be used concurrently.
+func (hw *UART) SetLine(speed int, wordLength int, parity int, stopBits int) (err error) {
+	if hw.Clock == 0 {
+		hw.Clock = DEFAULT_CLOCK
+	}
+
+	if wordLength == 0 {
+		wordLength = 8
+	}
+
+	if stopBits == 0 {
+		stopBits = 1
+	}
+
+	if speed <= 0 || speed > hw.Clock/16 {
+		return errors.New("invalid speed")
+	}
+
+	if wordLength < 5 || wordLength > 8 {
+		return errors.New("invalid word length")
+	}
+
+	if stopBits != 1 && stopBits != 2 {
+		return errors.New("invalid stop bits")
+	}
+
+	// round to the nearest divisor
+	div := (hw.Clock + 8*speed) / (16 * speed)
+
+	if div == 0 || div > 0xffff {
+		return errors.New("invalid speed")
+	}
+
+	if actual := hw.Clock / (16 * div); actual*50 < speed*49 || actual*50 > speed*51 {
+		return fmt.Errorf("speed %d not achievable (%d)", speed, actual)
+	}
+
+	lcr := uint8(wordLength - 5)
+
+	if stopBits == 2 {
+		lcr |= 1 << LCR_STB
+	}
+
+	switch parity {
+	case PARITY_NONE:
+	case PARITY_ODD:
+		lcr |= 1 << LCR_PEN
+	case PARITY_EVEN:
+		lcr |= 1<<LCR_PEN | 1<<LCR_EPS
+	case PARITY_MARK:
+		lcr |= 1<<LCR_PEN | 1<<LCR_STICK
+	case PARITY_SPACE:
+		lcr |= 1<<LCR_PEN | 1<<LCR_EPS | 1<<LCR_STICK
+	default:
+		return errors.New("invalid parity")
+	}
+
+	hw.Lock()
+	defer hw.Unlock()
+
+	reg.Out8(hw.Base+LCR, 1<<LCR_DLAB)
+	reg.Out8(hw.Base+DLL, uint8(div))
+	reg.Out8(hw.Base+DLM, uint8(div>>8))
+	reg.Out8(hw.Base+LCR, lcr)
+
+	hw.Speed = speed
+	hw.WordLength = wordLength
+	hw.Parity = parity
+	hw.StopBits = stopBits
+
+	return
+}
+
+// Line returns the current serial port baud rate, word length, parity mode
+// and number of stop bits, as configured in hardware.
+func (hw *UART) Line() (speed int, wordLength int, parity int, stopBits int) {
+	if hw.Clock == 0 {
+		hw.Clock = DEFAULT_CLOCK
+	}
+
+	hw.Lock()
+	defer hw.Unlock()
+
+	lcr := reg.In8(hw.Base + LCR)
+
+	reg.Out8(hw.Base+LCR, lcr|1<<LCR_DLAB)
+	div := int(reg.In8(hw.Base+DLL)) | int(reg.In8(hw.Base+DLM))<<8
+	reg.Out8(hw.Base+LCR, lcr)
+
+	if div != 0 {
+		speed = hw.Clock / (16 * div)
+	}
+
+	wordLength = int(lcr&0b11) + 5
+	stopBits = 1 + int(lcr>>LCR_STB)&1
+
+	switch {
+	case lcr&(1<<LCR_PEN) == 0:
+		parity = PARITY_NONE
+	case lcr&(1<<LCR_STICK) != 0 && lcr&(1<<LCR_EPS) != 0:
+		parity = PARITY_SPACE
+	case lcr&(1<<LCR_STICK) != 0:
+		parity = PARITY_MARK
+	case lcr&(1<<LCR_EPS) != 0:
+		parity = PARITY_EVEN
+	default:
+		parity = PARITY_ODD
+	}
+
+	return
+}
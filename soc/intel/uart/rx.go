@@ -0,0 +1,259 @@
+// 16550 Universal Asynchronous Receiver/Transmitter (UART) driver
+// https://github.com/karlo195/tamago
+//
+// Copyright (c) The TamaGo Authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+package uart
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/karlo195/tamago/amd64"
+	"github.com/karlo195/tamago/internal/reg"
+	"github.com/karlo195/tamago/soc/intel/ioapic"
+)
+
+// Receive FIFO interrupt trigger levels
+const (
+	TRIGGER_1  = 0b00
+	TRIGGER_4  = 0b01
+	TRIGGER_8  = 0b10
+	TRIGGER_14 = 0b11
+)
+
+// ISA interrupt lines of standard serial ports
+const (
+	IRQ_COM1 = 4
+	IRQ_COM2 = 3
+)
+
+// DefaultBufferSize is the default receive ring buffer size.
+const DefaultBufferSize = 1024
+
+// ring represents a receive ring buffer, characters received when full are
+// discarded.
+type ring struct {
+	sync.Mutex
+
+	buf   []byte
+	start int
+	n     int
+
+	// number of discarded characters
+	dropped uint64
+}
+
+func (r *ring) push(c byte) bool {
+	r.Lock()
+	defer r.Unlock()
+
+	if r.n == len(r.buf) {
+		r.dropped++
+		return false
+	}
+
+	r.buf[(r.start+r.n)%len(r.buf)] = c
+	r.n++
+
+	return true
+}
+
+func (r *ring) pop() (c byte, valid bool) {
+	r.Lock()
+	defer r.Unlock()
+
+	if r.n == 0 {
+		return
+	}
+
+	c = r.buf[r.start]
+	r.start = (r.start + 1) % len(r.buf)
+	r.n--
+
+	return c, true
+}
+
+func (r *ring) len() int {
+	r.Lock()
+	defer r.Unlock()
+
+	return r.n
+}
+
+func (hw *UART) ring() *ring {
+	hw.Lock()
+	defer hw.Unlock()
+
+	return hw.rx
+}
+
+func (hw *UART) interrupts() bool {
+	hw.Lock()
+	defer hw.Unlock()
+
+	return hw.irq
+}
+
+// wait blocks until the receive buffer is not empty or interrupt mode is
+// disabled.
+func (hw *UART) wait() {
+	for hw.ring().len() == 0 && hw.interrupts() {
+		<-hw.ready
+	}
+}
+
+// EnableInterrupt enables interrupt driven reception, with the receive FIFO
+// trigger level set by Trigger, routing the serial port interrupt through the
+// argument I/O APIC to a vector allocated on the argument CPU instance. The
+// argument Global System Interrupt should be [IRQ_COM1] or [IRQ_COM2] unless
+// overridden by firmware (see acpi.MADT.GSI).
+//
+// Interrupts are handled by [UART.Handle] once serviced with
+// [CPU.ServiceInterrupts] (with a nil argument), received characters are then
+// available through [UART.Read], which becomes blocking, and signaled on the
+// [UART.Ready] channel.
+//
+// [CPU.ServiceInterrupts]: https://pkg.go.dev/github.com/karlo195/tamago/amd64#CPU.ServiceInterrupts
+func (hw *UART) EnableInterrupt(cpu *amd64.CPU, io *ioapic.IOAPIC, gsi int) (vector int, err error) {
+	if hw.Base == 0 {
+		return 0, errors.New("invalid UART controller instance")
+	}
+
+	if hw.Trigger < TRIGGER_1 || hw.Trigger > TRIGGER_14 {
+		return 0, errors.New("invalid trigger level")
+	}
+
+	if hw.BufferSize <= 0 {
+		hw.BufferSize = DefaultBufferSize
+	}
+
+	hw.Lock()
+
+	if hw.rx == nil {
+		hw.rx = &ring{buf: make([]byte, hw.BufferSize)}
+		hw.ready = make(chan struct{}, 1)
+		hw.notify = make(chan struct{}, 1)
+	}
+
+	hw.Unlock()
+
+	if vector, err = cpu.AllocateInterrupt(hw.Handle); err != nil {
+		return
+	}
+
+	hw.Lock()
+	reg.Out8(hw.Base+FCR, uint8(hw.Trigger<<FCR_TRIGGER|1<<FCR_RX_RST|1<<FCR_ENABLE))
+	hw.FIFO = true
+	hw.fifo = true
+	hw.irq = true
+	hw.Unlock()
+
+	// OUT2 gates the interrupt line on PC compatible serial ports
+	mcr := reg.In8(hw.Base + MCR)
+	reg.Out8(hw.Base+MCR, mcr|1<<MCR_OUT2)
+
+	reg.Out8(hw.Base+IER, 1<<IER_ERBFI|1<<IER_ELSI)
+
+	io.EnableInterrupt(gsi, vector)
+
+	return
+}
+
+// DisableInterrupt disables interrupt driven reception, characters left in
+// the receive buffer remain available to [UART.Read], which then reverts to
+// non-blocking polled reception.
+func (hw *UART) DisableInterrupt() {
+	hw.Lock()
+	hw.irq = false
+	hw.Unlock()
+
+	reg.Out8(hw.Base+IER, 0)
+
+	// wake up blocked readers
+	select {
+	case hw.ready <- struct{}{}:
+	default:
+	}
+}
+
+// Handle services all pending serial port interrupts, moving received
+// characters to the receive buffer, it is meant to be invoked on serial port
+// interrupts.
+func (hw *UART) Handle() {
+	rx := hw.ring()
+
+	if rx == nil {
+		return
+	}
+
+	received := false
+
+	for i := 0; i < 16; i++ {
+		iir := reg.In8(hw.Base + IIR)
+
+		if iir&(1<<IIR_NO_INT) != 0 {
+			break
+		}
+
+		switch (iir >> IIR_ID) & IIR_ID_MASK {
+		case IIR_ID_RLS:
+			// reading clears the line status interrupt
+			reg.In8(hw.Base + LSR)
+		case IIR_ID_RDA, IIR_ID_CTI:
+			for reg.In8(hw.Base+LSR)&(1<<LSR_DR) != 0 {
+				rx.push(reg.In8(hw.Base + RBR))
+				received = true
+			}
+		case IIR_ID_MSR:
+			reg.In8(hw.Base + MSR)
+		case IIR_ID_THRE:
+			// cleared by IIR read
+		}
+	}
+
+	if !received {
+		return
+	}
+
+	if hw.Flow {
+		hw.throttle(rx.len())
+	}
+
+	select {
+	case hw.ready <- struct{}{}:
+	default:
+	}
+
+	select {
+	case hw.notify <- struct{}{}:
+	default:
+	}
+}
+
+// Ready returns a channel signaled when characters are received in interrupt
+// mode (see [UART.EnableInterrupt]), nil is returned otherwise.
+func (hw *UART) Ready() <-chan struct{} {
+	hw.Lock()
+	defer hw.Unlock()
+
+	return hw.notify
+}
+
+// Dropped returns the number of received characters discarded due to a full
+// receive buffer.
+func (hw *UART) Dropped() uint64 {
+	rx := hw.ring()
+
+	if rx == nil {
+		return 0
+	}
+
+	rx.Lock()
+	defer rx.Unlock()
+
+	return rx.dropped
+}
@@ -10,12 +10,19 @@
 // following reference specifications:
 //   - PC16550D - Universal Asynchronous Receiver/Transmitter with FIFOs - June 1995
 //
+// Reception is polled by default, interrupt driven reception into a ring
+// buffer can be enabled with [UART.EnableInterrupt].
+//
 // This package is only meant to be used with `GOOS=tamago GOARCH=amd64` as
 // supported by the TamaGo framework for bare metal Go, see
 // https://github.com/karlo195/tamago.
 package uart
 
 import (
+	"errors"
+	"sync"
+	"time"
+
 	"github.com/karlo195/tamago/internal/reg"
 )
 
@@ -25,41 +32,161 @@ const (
 
 	RBR = 0x00
 	THR = 0x00
-	IER = 0x01
-	FCR = 0x02
-	MCR = 0x04
+
+	IER       = 0x01
+	IER_ERBFI = 0
+	IER_ETBEI = 1
+	IER_ELSI  = 2
+	IER_EDSSI = 3
+
+	IIR         = 0x02
+	IIR_NO_INT  = 0
+	IIR_ID      = 1
+	IIR_ID_MSR  = 0b000
+	IIR_ID_THRE = 0b001
+	IIR_ID_RDA  = 0b010
+	IIR_ID_RLS  = 0b011
+	IIR_ID_CTI  = 0b110
+	IIR_ID_MASK = 0b111
+
+	FCR         = 0x02
+	FCR_ENABLE  = 0
+	FCR_RX_RST  = 1
+	FCR_TX_RST  = 2
+	FCR_TRIGGER = 6
+
+	LCR       = 0x03
+	LCR_WLS   = 0
+	LCR_STB   = 2
+	LCR_PEN   = 3
+	LCR_EPS   = 4
+	LCR_STICK = 5
+	LCR_BREAK = 6
+	LCR_DLAB  = 7
+
+	MCR      = 0x04
+	MCR_DTR  = 0
+	MCR_RTS  = 1
+	MCR_OUT2 = 3
 
 	LSR      = 0x05
 	LSR_DR   = 0
 	LSR_THRE = 5
+	LSR_TEMT = 6
+
+	MSR      = 0x06
+	MSR_DCTS = 0
+	MSR_CTS  = 4
 )
 
 // UART represents a serial port instance.
 type UART struct {
+	sync.Mutex
+
 	// Controller index
 	Index int
 	// Base register
 	Base uint16
+
+	// Clock is the baud rate generator input clock frequency in Hz
+	// (default DEFAULT_CLOCK).
+	Clock int
+	// Speed is the line baud rate, the line parameters are left to their
+	// current configuration when zero (see [UART.SetLine]).
+	Speed int
+	// WordLength is the number of data bits, 5 to 8 (default 8).
+	WordLength int
+	// Parity is the parity mode (default PARITY_NONE).
+	Parity int
+	// StopBits is the number of stop bits, 1 or 2 (default 1).
+	StopBits int
+
+	// Flow enables RTS/CTS hardware flow control (see [UART.SetFlow]).
+	Flow bool
+	// CTSTimeout is the maximum time transmission is held while the remote
+	// end deasserts CTS, with Flow set (default DefaultCTSTimeout).
+	CTSTimeout time.Duration
+	// FIFO enables the transmit and receive FIFOs, with the receive
+	// trigger level set by Trigger (see [UART.SetFIFO]).
+	FIFO bool
+
+	// BufferSize is the receive ring buffer size, used in interrupt mode
+	// (default DefaultBufferSize).
+	BufferSize int
+	// Trigger is the receive FIFO interrupt trigger level, used with FIFO
+	// or in interrupt mode (TRIGGER_1, TRIGGER_4, TRIGGER_8 or TRIGGER_14).
+	Trigger int
+
+	// receive ring buffer, set in interrupt mode
+	rx *ring
+	// interrupt driven reception enabled
+	irq bool
+	// receive notifications
+	ready  chan struct{}
+	notify chan struct{}
+
+	// FIFOs enabled
+	fifo bool
+	// RTS deasserted due to a full receive buffer
+	throttled bool
 }
 
-// Init initializes and enables the UART.
+// Init initializes and enables the UART, configuring the line parameters
+// when Speed is set, the FIFOs when FIFO is set and flow control when Flow is
+// set.
 func (hw *UART) Init() {
 	if hw.Base == 0 {
 		panic("invalid UART controller instance")
 	}
+
+	if hw.Speed != 0 {
+		if err := hw.SetLine(hw.Speed, hw.WordLength, hw.Parity, hw.StopBits); err != nil {
+			panic("invalid UART line parameters")
+		}
+	}
+
+	if hw.FIFO {
+		if err := hw.SetFIFO(true, hw.Trigger); err != nil {
+			panic("invalid UART FIFO parameters")
+		}
+	}
+
+	if hw.Flow {
+		hw.SetFlow(true)
+	}
 }
 
-// Tx transmits a single character to the serial port.
+// Tx transmits a single character to the serial port, with hardware flow
+// control enabled the function blocks until the remote end asserts CTS, the
+// character is discarded if CTSTimeout expires.
 func (hw *UART) Tx(c byte) {
 	for reg.In8(hw.Base+LSR)&(1<<LSR_THRE) == 0 {
 		// wait for TX FIFO to have room for a character
 	}
 
+	if hw.Flow && !hw.waitCTS() {
+		return
+	}
+
 	reg.Out8(hw.Base+THR, uint8(c))
 }
 
-// Rx receives a single character from the serial port.
+// Rx receives a single character from the serial port, or from its receive
+// buffer in interrupt mode (see [UART.EnableInterrupt]).
 func (hw *UART) Rx() (c byte, valid bool) {
+	if rx := hw.ring(); rx != nil {
+		c, valid = rx.pop()
+
+		if hw.Flow {
+			hw.throttle(rx.len())
+		}
+
+		// buffered characters are drained before polling resumes
+		if valid || hw.interrupts() {
+			return
+		}
+	}
+
 	if reg.In8(hw.Base+LSR)&(1<<LSR_DR) == 0 {
 		return
 	}
@@ -68,18 +195,46 @@ func (hw *UART) Rx() (c byte, valid bool) {
 }
 
 // Write data from buffer to serial port.
-func (hw *UART) Write(buf []byte) (n int, _ error) {
-	for n = 0; n < len(buf); n++ {
-		hw.Tx(buf[n])
+//
+// With FIFOs enabled (see [UART.SetFIFO]) characters are transmitted in
+// bursts filling the transmit FIFO. With hardware flow control enabled CTS is
+// checked before each character, an error is returned if CTSTimeout expires.
+func (hw *UART) Write(buf []byte) (n int, err error) {
+	burst := 1
+
+	if hw.fifo {
+		burst = FIFO_SIZE
+	}
+
+	for n < len(buf) {
+		for reg.In8(hw.Base+LSR)&(1<<LSR_THRE) == 0 {
+			// wait for TX FIFO to be empty
+		}
+
+		for i := 0; i < burst && n < len(buf); i++ {
+			if hw.Flow && !hw.waitCTS() {
+				return n, errors.New("CTS timeout")
+			}
+
+			reg.Out8(hw.Base+THR, buf[n])
+			n++
+		}
 	}
 
 	return
 }
 
 // Read available data to buffer from serial port.
+//
+// In interrupt mode (see [UART.EnableInterrupt]) the function blocks until at
+// least one character is available.
 func (hw *UART) Read(buf []byte) (n int, _ error) {
 	var valid bool
 
+	if hw.interrupts() && len(buf) > 0 {
+		hw.wait()
+	}
+
 	for n = 0; n < len(buf); n++ {
 		buf[n], valid = hw.Rx()
 
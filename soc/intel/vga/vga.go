@@ -0,0 +1,268 @@
+// VGA text mode driver
+// https://github.com/karlo195/tamago
+//
+// Copyright (c) The TamaGo Authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+// Package vga implements a driver for the legacy VGA text mode buffer, for
+// console output on displays (e.g. QEMU graphical window), adopting the
+// following reference specifications:
+//   - IBM VGA Technical Reference - 1987
+//
+// The driver implements the console.Sink interface, to be added to a board
+// console output manager alongside its UART:
+//
+//	display := &vga.VGA{}
+//	display.Init()
+//	microvm.Console.Add(display)
+//
+// This package is only meant to be used with `GOOS=tamago GOARCH=amd64` as
+// supported by the TamaGo framework for bare metal Go, see
+// https://github.com/karlo195/tamago.
+package vga
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/karlo195/tamago/dma"
+	"github.com/karlo195/tamago/internal/reg"
+)
+
+// Text mode defaults
+const (
+	TEXT_BUFFER = 0xb8000
+
+	DefaultWidth  = 80
+	DefaultHeight = 25
+)
+
+// CRT controller registers
+const (
+	CRTC_ADDR = 0x3d4
+	CRTC_DATA = 0x3d5
+
+	CURSOR_START         = 0x0a
+	CURSOR_DISABLE       = 5
+	CURSOR_END           = 0x0b
+	CURSOR_LOCATION_HIGH = 0x0e
+	CURSOR_LOCATION_LOW  = 0x0f
+)
+
+// Colors
+const (
+	Black = iota
+	Blue
+	Green
+	Cyan
+	Red
+	Magenta
+	Brown
+	LightGray
+	DarkGray
+	LightBlue
+	LightGreen
+	LightCyan
+	LightRed
+	LightMagenta
+	Yellow
+	White
+)
+
+const tabWidth = 8
+
+// VGA represents a VGA text mode buffer instance.
+type VGA struct {
+	sync.Mutex
+
+	// Base is the text buffer address (default TEXT_BUFFER).
+	Base uint
+	// Width is the number of columns (default DefaultWidth).
+	Width int
+	// Height is the number of rows (default DefaultHeight).
+	Height int
+
+	// text buffer, character and attribute byte pairs
+	buf []byte
+	// cursor position
+	x, y int
+	// character attribute
+	attr uint8
+}
+
+// Init initializes the text buffer, clearing the screen.
+func (hw *VGA) Init() (err error) {
+	if hw.Base == 0 {
+		hw.Base = TEXT_BUFFER
+	}
+
+	if hw.Width == 0 {
+		hw.Width = DefaultWidth
+	}
+
+	if hw.Height == 0 {
+		hw.Height = DefaultHeight
+	}
+
+	if hw.Width < 1 || hw.Height < 1 {
+		return errors.New("invalid VGA instance")
+	}
+
+	size := hw.Width * hw.Height * 2
+	r, err := dma.NewRegion(hw.Base, size, false)
+
+	if err != nil {
+		return
+	}
+
+	_, hw.buf = r.Reserve(size, 0)
+	hw.SetColor(LightGray, Black)
+
+	// enable underline cursor
+	crtc(CURSOR_START, 14)
+	crtc(CURSOR_END, 15)
+
+	hw.Clear()
+
+	return
+}
+
+func crtc(index uint8, val uint8) {
+	reg.Out8(CRTC_ADDR, index)
+	reg.Out8(CRTC_DATA, val)
+}
+
+// SetColor sets the foreground and background colors of subsequent output.
+func (hw *VGA) SetColor(fg int, bg int) {
+	hw.Lock()
+	defer hw.Unlock()
+
+	hw.attr = uint8(bg&0x0f)<<4 | uint8(fg&0x0f)
+}
+
+// Clear clears the screen and moves the cursor to the top left corner.
+func (hw *VGA) Clear() {
+	hw.Lock()
+	defer hw.Unlock()
+
+	hw.clearRows(0, hw.Height)
+	hw.x = 0
+	hw.y = 0
+	hw.updateCursor()
+}
+
+// SetCursor moves the cursor to the argument column and row.
+func (hw *VGA) SetCursor(x int, y int) {
+	hw.Lock()
+	defer hw.Unlock()
+
+	hw.x = min(max(x, 0), hw.Width-1)
+	hw.y = min(max(y, 0), hw.Height-1)
+	hw.updateCursor()
+}
+
+// Cursor returns the cursor column and row.
+func (hw *VGA) Cursor() (x int, y int) {
+	hw.Lock()
+	defer hw.Unlock()
+
+	return hw.x, hw.y
+}
+
+func (hw *VGA) clearRows(start int, end int) {
+	for i := start * hw.Width * 2; i < end*hw.Width*2; i += 2 {
+		hw.buf[i] = ' '
+		hw.buf[i+1] = hw.attr
+	}
+}
+
+func (hw *VGA) updateCursor() {
+	pos := uint16(hw.y*hw.Width + hw.x)
+
+	crtc(CURSOR_LOCATION_HIGH, uint8(pos>>8))
+	crtc(CURSOR_LOCATION_LOW, uint8(pos))
+}
+
+func (hw *VGA) scroll() {
+	row := hw.Width * 2
+
+	copy(hw.buf, hw.buf[row:])
+	hw.clearRows(hw.Height-1, hw.Height)
+}
+
+func (hw *VGA) newline() {
+	hw.x = 0
+
+	if hw.y++; hw.y == hw.Height {
+		hw.scroll()
+		hw.y = hw.Height - 1
+	}
+}
+
+func (hw *VGA) put(c byte) {
+	switch c {
+	case '\n':
+		hw.newline()
+	case '\r':
+		hw.x = 0
+	case '\b':
+		if hw.x > 0 {
+			hw.x--
+		}
+	case '\t':
+		for {
+			hw.put(' ')
+
+			if hw.x%tabWidth == 0 {
+				break
+			}
+		}
+	default:
+		off := (hw.y*hw.Width + hw.x) * 2
+
+		hw.buf[off] = c
+		hw.buf[off+1] = hw.attr
+
+		if hw.x++; hw.x == hw.Width {
+			hw.newline()
+		}
+	}
+}
+
+// Tx displays a single character at the cursor position, it implements the
+// console.Sink interface.
+//
+// Characters are interpreted in code page 437, line feed, carriage return,
+// backspace and tab control characters are supported.
+//
+// As console sinks must not block, the character is discarded when the
+// buffer is being concurrently updated.
+func (hw *VGA) Tx(c byte) {
+	if hw.buf == nil || !hw.TryLock() {
+		return
+	}
+	defer hw.Unlock()
+
+	hw.put(c)
+	hw.updateCursor()
+}
+
+// Write displays the argument buffer, it implements the io.Writer interface.
+func (hw *VGA) Write(buf []byte) (n int, _ error) {
+	if hw.buf == nil {
+		return
+	}
+
+	hw.Lock()
+	defer hw.Unlock()
+
+	for _, c := range buf {
+		hw.put(c)
+	}
+
+	hw.updateCursor()
+
+	return len(buf), nil
+}
@@ -0,0 +1,561 @@
+// Intel Virtualization Technology for Directed I/O (VT-d) driver
+// https://github.com/karlo195/tamago
+//
+// Copyright (c) The TamaGo Authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+// Package vtd implements a driver for Intel Virtualization Technology for
+// Directed I/O (VT-d) DMA remapping hardware units adopting the following
+// reference specifications:
+//   - Intel® Virtualization Technology for Directed I/O - Architecture Specification - Revision 4.1
+//
+// Each PCI device is attached to a translation domain (see [Domain]),
+// implementing [dma.Mapper], which restricts its DMA to explicitly mapped
+// memory. Remapping hardware units can be discovered through the ACPI DMAR
+// table (see [acpi.ACPI.DMAR]).
+//
+// This package is only meant to be used with `GOOS=tamago GOARCH=amd64` as
+// supported by the TamaGo framework for bare metal Go, see
+// https://github.com/karlo195/tamago.
+package vtd
+
+import (
+	"encoding/binary"
+	"errors"
+	"sync"
+	"time"
+	"unsafe"
+
+	"github.com/karlo195/tamago/bits"
+	"github.com/karlo195/tamago/dma"
+	"github.com/karlo195/tamago/internal/reg"
+)
+
+// VT-d registers
+// (11.4 Register Descriptions).
+const (
+	VER_REG = 0x00
+
+	CAP_REG   = 0x08
+	CAP_NFR   = 40
+	CAP_FRO   = 24
+	CAP_MGAW  = 16
+	CAP_SAGAW = 8
+	CAP_RWBF  = 4
+	CAP_ND    = 0
+
+	ECAP_REG = 0x10
+	ECAP_IRO = 8
+	ECAP_C   = 0
+
+	GCMD_REG  = 0x18
+	GSTS_REG  = 0x1c
+	GCMD_TE   = 31
+	GCMD_SRTP = 30
+	GCMD_WBF  = 27
+
+	RTADDR_REG = 0x20
+
+	CCMD_REG  = 0x28
+	CCMD_ICC  = 63
+	CCMD_CIRG = 61
+
+	FSTS_REG = 0x34
+	FSTS_PPF = 1
+	FSTS_PFO = 0
+
+	// IOTLB register, at ECAP_REG.IRO offset
+	IOTLB_REG  = 0x08
+	IOTLB_IVT  = 63
+	IOTLB_IIRG = 60
+	IOTLB_DR   = 49
+	IOTLB_DW   = 48
+)
+
+// Invalidation granularities
+const (
+	invalidateGlobal = 0b01
+)
+
+// Second-level paging entry fields
+// (9.8 Second-Level Paging Entries).
+const (
+	PTE_R = 0
+	PTE_W = 1
+)
+
+// Context entry fields
+// (9.3 Context Entry).
+const (
+	CTX_P       = 0
+	CTX_AW      = 0
+	CTX_DID     = 8
+	AW_39BIT    = 1
+	AW_48BIT    = 2
+	levels39Bit = 3
+	levels48Bit = 4
+)
+
+const (
+	pageSize         = 4096
+	pageTableEntries = 512
+	entrySize        = 16
+	timeout          = 100 * time.Millisecond
+
+	// GSTS_REG bits which must not be written back to GCMD_REG
+	gstsOneShotMask = 0x96ffffff
+
+	addressMask = 0x000ffffffffff000
+)
+
+// Fault represents a recorded DMA remapping fault.
+type Fault struct {
+	// Source is the faulting device source identifier (bus, device and
+	// function).
+	Source uint16
+	// Reason is the fault reason code.
+	Reason uint8
+	// Address is the faulting page address.
+	Address uint64
+	// Read indicates a faulting read request, rather than a write.
+	Read bool
+}
+
+// VTD represents a DMA remapping hardware unit instance.
+type VTD struct {
+	sync.Mutex
+
+	// Base is the register set base address (see [acpi.DRHD]).
+	Base uint64
+
+	// Region is the memory region holding root, context and page tables,
+	// it must be dedicated to this purpose and never reachable by devices.
+	// When nil, tables are allocated within Go runtime memory.
+	Region *dma.Region
+
+	cap   uint64
+	ecap  uint64
+	iotlb uint64
+
+	// second-level paging levels
+	levels int
+	aw     uint64
+
+	// root and context tables
+	root     []byte
+	contexts map[uint8][]byte
+	// allocated domain identifiers
+	domains uint16
+
+	// translation table pages, never mapped within domains
+	tablesLock sync.Mutex
+	tables     map[uint][]byte
+}
+
+// Domain represents a DMA remapping translation domain, with its own
+// second-level page tables, shared by all devices attached to it.
+type Domain struct {
+	sync.Mutex
+
+	// ID is the domain identifier.
+	ID uint16
+
+	hw *VTD
+
+	root uint
+	// page tables, indexed by physical address
+	tables map[uint][]byte
+}
+
+func (hw *VTD) read32(off uint64) uint32 {
+	return reg.Read(uint32(hw.Base + off))
+}
+
+func (hw *VTD) write32(off uint64, val uint32) {
+	reg.Write(uint32(hw.Base+off), val)
+}
+
+func (hw *VTD) read64(off uint64) uint64 {
+	return reg.Read64(hw.Base + off)
+}
+
+func (hw *VTD) write64(off uint64, val uint64) {
+	reg.Write64(hw.Base+off, val)
+}
+
+func (hw *VTD) wait(off uint64, pos int, val bool) (err error) {
+	start := time.Now()
+
+	for reg.IsSet64(hw.Base+off, pos) != val {
+		if time.Since(start) > timeout {
+			return errors.New("timeout")
+		}
+	}
+
+	return
+}
+
+// command issues a global command, waiting for its status to match the
+// argument value (11.4.4 Global Command Register).
+func (hw *VTD) command(pos int, val bool) (err error) {
+	sts := hw.read32(GSTS_REG) & gstsOneShotMask
+
+	if val {
+		sts |= 1 << pos
+	} else {
+		sts &^= 1 << pos
+	}
+
+	hw.write32(GCMD_REG, sts)
+
+	start := time.Now()
+
+	for (hw.read32(GSTS_REG)>>pos)&1 == 1 != val {
+		if time.Since(start) > timeout {
+			return errors.New("command timeout")
+		}
+	}
+
+	return
+}
+
+// alloc allocates a zeroed translation table page, outside of the global DMA
+// region as devices must not be able to alter their own translations.
+func (hw *VTD) alloc() (addr uint, buf []byte) {
+	hw.tablesLock.Lock()
+	defer hw.tablesLock.Unlock()
+
+	if hw.Region != nil {
+		addr, buf = hw.Region.Reserve(pageSize, pageSize)
+
+		for i := range buf {
+			buf[i] = 0
+		}
+	} else {
+		// Go runtime memory is identity mapped, references are kept
+		// to prevent page reuse.
+		mem := make([]byte, 2*pageSize)
+		start := uint(uintptr(unsafe.Pointer(&mem[0])))
+		off := int((pageSize - start%pageSize) % pageSize)
+
+		addr = start + uint(off)
+		buf = mem[off : off+pageSize]
+	}
+
+	if hw.tables == nil {
+		hw.tables = make(map[uint][]byte)
+	}
+
+	hw.tables[addr] = buf
+
+	return
+}
+
+// isTable returns whether the argument page holds translation tables.
+func (hw *VTD) isTable(addr uint) bool {
+	hw.tablesLock.Lock()
+	defer hw.tablesLock.Unlock()
+
+	_, ok := hw.tables[addr]
+
+	return ok
+}
+
+// Init initializes the DMA remapping hardware unit, DMA remapping is not
+// enabled until [VTD.Enable] is invoked.
+func (hw *VTD) Init() (err error) {
+	hw.Lock()
+	defer hw.Unlock()
+
+	if hw.Base == 0 {
+		return errors.New("invalid VT-d instance")
+	}
+
+	hw.cap = hw.read64(CAP_REG)
+	hw.ecap = hw.read64(ECAP_REG)
+	hw.iotlb = uint64(bits.Get64(&hw.ecap, ECAP_IRO, 0x3ff))*16 + IOTLB_REG
+
+	if !bits.IsSet64(&hw.ecap, ECAP_C) {
+		return errors.New("non-coherent page walks are not supported")
+	}
+
+	sagaw := bits.Get64(&hw.cap, CAP_SAGAW, 0x1f)
+
+	switch {
+	case sagaw&(1<<AW_48BIT) != 0:
+		hw.levels = levels48Bit
+		hw.aw = AW_48BIT
+	case sagaw&(1<<AW_39BIT) != 0:
+		hw.levels = levels39Bit
+		hw.aw = AW_39BIT
+	default:
+		return errors.New("unsupported address width")
+	}
+
+	addr, root := hw.alloc()
+
+	hw.root = root
+	hw.contexts = make(map[uint8][]byte)
+	// domain 0 is reserved on caching mode implementations
+	hw.domains = 1
+
+	hw.write64(RTADDR_REG, uint64(addr))
+
+	if err = hw.command(GCMD_SRTP, true); err != nil {
+		return
+	}
+
+	return hw.invalidate()
+}
+
+// flushWriteBuffer flushes the unit internal write buffer, when required
+// (6.8 Write Buffer Flushing).
+func (hw *VTD) flushWriteBuffer() (err error) {
+	if !bits.IsSet64(&hw.cap, CAP_RWBF) {
+		return
+	}
+
+	return hw.command(GCMD_WBF, true)
+}
+
+// invalidate performs global context-cache and IOTLB invalidation
+// (6.5.1 Register-based Invalidation Interface).
+func (hw *VTD) invalidate() (err error) {
+	if err = hw.flushWriteBuffer(); err != nil {
+		return
+	}
+
+	hw.write64(CCMD_REG, 1<<CCMD_ICC|invalidateGlobal<<CCMD_CIRG)
+
+	if err = hw.wait(CCMD_REG, CCMD_ICC, false); err != nil {
+		return
+	}
+
+	hw.write64(hw.iotlb, 1<<IOTLB_IVT|invalidateGlobal<<IOTLB_IIRG|1<<IOTLB_DR|1<<IOTLB_DW)
+
+	return hw.wait(hw.iotlb, IOTLB_IVT, false)
+}
+
+// Enable enables DMA remapping, from this point onwards DMA requests of
+// devices not attached to a domain, or outside mapped memory, are blocked
+// (see [VTD.Faults]).
+func (hw *VTD) Enable() (err error) {
+	hw.Lock()
+	defer hw.Unlock()
+
+	return hw.command(GCMD_TE, true)
+}
+
+// Disable disables DMA remapping.
+func (hw *VTD) Disable() (err error) {
+	hw.Lock()
+	defer hw.Unlock()
+
+	return hw.command(GCMD_TE, false)
+}
+
+// NewDomain allocates a translation domain, initially without any mapping.
+func (hw *VTD) NewDomain() (d *Domain, err error) {
+	hw.Lock()
+	defer hw.Unlock()
+
+	nd := 1 << (4 + 2*bits.Get64(&hw.cap, CAP_ND, 0b111))
+
+	if int(hw.domains) >= nd {
+		return nil, errors.New("no domain identifiers available")
+	}
+
+	addr, buf := hw.alloc()
+
+	d = &Domain{
+		ID:     hw.domains,
+		hw:     hw,
+		root:   addr,
+		tables: map[uint][]byte{addr: buf},
+	}
+
+	hw.domains++
+
+	return
+}
+
+// Attach attaches a PCI device, identified by its bus, device and function
+// numbers, to a translation domain.
+func (hw *VTD) Attach(d *Domain, bus uint8, dev uint8, fn uint8) (err error) {
+	hw.Lock()
+	defer hw.Unlock()
+
+	if d == nil || d.hw != hw || dev >= 32 || fn >= 8 {
+		return errors.New("invalid attachment")
+	}
+
+	ctx, ok := hw.contexts[bus]
+
+	if !ok {
+		var addr uint
+
+		addr, ctx = hw.alloc()
+		hw.contexts[bus] = ctx
+
+		// 9.1 Root Entry
+		binary.LittleEndian.PutUint64(hw.root[int(bus)*entrySize:], uint64(addr)|1)
+	}
+
+	e := ctx[(int(dev)<<3|int(fn))*entrySize:]
+
+	// translation type 00b: untranslated requests through second-level
+	// page tables
+	binary.LittleEndian.PutUint64(e[8:], hw.aw<<CTX_AW|uint64(d.ID)<<CTX_DID)
+	binary.LittleEndian.PutUint64(e[0:], uint64(d.root)|1<<CTX_P)
+
+	return hw.invalidate()
+}
+
+// Detach detaches a PCI device, blocking all its DMA requests.
+func (hw *VTD) Detach(bus uint8, dev uint8, fn uint8) (err error) {
+	hw.Lock()
+	defer hw.Unlock()
+
+	ctx, ok := hw.contexts[bus]
+
+	if !ok || dev >= 32 || fn >= 8 {
+		return
+	}
+
+	e := ctx[(int(dev)<<3|int(fn))*entrySize:]
+
+	binary.LittleEndian.PutUint64(e[0:], 0)
+	binary.LittleEndian.PutUint64(e[8:], 0)
+
+	return hw.invalidate()
+}
+
+// Faults returns, and clears, the recorded DMA remapping faults
+// (7.2.1 Primary Fault Logging).
+func (hw *VTD) Faults() (faults []Fault) {
+	hw.Lock()
+	defer hw.Unlock()
+
+	fro := bits.Get64(&hw.cap, CAP_FRO, 0x3ff) * 16
+	nfr := bits.Get64(&hw.cap, CAP_NFR, 0xff) + 1
+
+	for i := uint64(0); i < nfr; i++ {
+		off := fro + i*16
+		hi := hw.read64(off + 8)
+
+		// F (fault) bit
+		if hi>>63 == 0 {
+			continue
+		}
+
+		faults = append(faults, Fault{
+			Source:  uint16(hi),
+			Reason:  uint8(hi >> 32),
+			Address: hw.read64(off) & addressMask,
+			Read:    (hi>>62)&1 == 1,
+		})
+
+		// clear F bit (write 1 to clear)
+		hw.write64(off+8, 1<<63)
+	}
+
+	hw.write32(FSTS_REG, 1<<FSTS_PFO)
+
+	return
+}
+
+// entry returns the leaf page table entry for the argument I/O virtual
+// address, allocating intermediate tables as required.
+func (d *Domain) entry(iova uint64, create bool) (e []byte) {
+	table := d.tables[d.root]
+
+	for l := d.hw.levels - 1; ; l-- {
+		i := (iova >> (12 + 9*l)) & (pageTableEntries - 1)
+		e = table[i*8 : i*8+8]
+
+		if l == 0 {
+			return
+		}
+
+		pte := binary.LittleEndian.Uint64(e)
+
+		if pte&(1<<PTE_R|1<<PTE_W) == 0 {
+			if !create {
+				return nil
+			}
+
+			addr, buf := d.hw.alloc()
+			d.tables[addr] = buf
+
+			pte = uint64(addr) | 1<<PTE_R | 1<<PTE_W
+			binary.LittleEndian.PutUint64(e, pte)
+		}
+
+		table = d.tables[uint(pte&addressMask)]
+	}
+}
+
+func checkRange(addr uint, size int) error {
+	if addr%pageSize != 0 || size <= 0 || size%pageSize != 0 {
+		return errors.New("invalid page range")
+	}
+
+	return nil
+}
+
+// Map allows DMA of devices attached to the domain to the argument physical
+// memory range, which must be page aligned, at the same I/O virtual address.
+// Ranges holding translation tables cannot be mapped.
+func (d *Domain) Map(addr uint, size int, write bool) (err error) {
+	if err = checkRange(addr, size); err != nil {
+		return
+	}
+
+	for pa := addr; pa < addr+uint(size); pa += pageSize {
+		if d.hw.isTable(pa) {
+			return errors.New("range overlaps translation tables")
+		}
+	}
+
+	d.Lock()
+	defer d.Unlock()
+
+	attr := uint64(1 << PTE_R)
+
+	if write {
+		attr |= 1 << PTE_W
+	}
+
+	for pa := uint64(addr); pa < uint64(addr)+uint64(size); pa += pageSize {
+		binary.LittleEndian.PutUint64(d.entry(pa, true), pa|attr)
+	}
+
+	d.hw.Lock()
+	defer d.hw.Unlock()
+
+	return d.hw.invalidate()
+}
+
+// Unmap revokes DMA of devices attached to the domain to the argument
+// physical memory range, which must be page aligned.
+func (d *Domain) Unmap(addr uint, size int) (err error) {
+	if err = checkRange(addr, size); err != nil {
+		return
+	}
+
+	d.Lock()
+	defer d.Unlock()
+
+	for pa := uint64(addr); pa < uint64(addr)+uint64(size); pa += pageSize {
+		if e := d.entry(pa, false); e != nil {
+			binary.LittleEndian.PutUint64(e, 0)
+		}
+	}
+
+	d.hw.Lock()
+	defer d.hw.Unlock()
+
+	return d.hw.invalidate()
+}
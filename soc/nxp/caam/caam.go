@@ -21,6 +21,7 @@ import (
 
 	"github.com/karlo195/tamago/dma"
 	"github.com/karlo195/tamago/internal/reg"
+	"github.com/karlo195/tamago/soc/nxp/ccm"
 )
 
 // CAAM registers
@@ -49,10 +50,8 @@ type CAAM struct {
 
 	// Base register
 	Base uint32
-	// Clock gate register
-	CCGR uint32
 	// Clock gate
-	CG int
+	Gate *ccm.Gate
 
 	// DeriveKeyMemory represents the DMA memory region where the CAAM blob
 	// key encryption key (BKEK), derived from the hardware unique key, is
@@ -88,7 +87,7 @@ func (hw *CAAM) Init() {
 	hw.Lock()
 	defer hw.Unlock()
 
-	if hw.Base == 0 || hw.CCGR == 0 {
+	if hw.Base == 0 || hw.Gate == nil {
 		panic("invalid CAAM instance")
 	}
 
@@ -98,7 +97,7 @@ func (hw *CAAM) Init() {
 	hw.rtent15 = hw.Base + CAAM_RTENT15
 
 	// enable clock
-	reg.SetN(hw.CCGR, hw.CG, 0b11, 0b11)
+	hw.Gate.Enable()
 
 	// enter program mode
 	reg.Set(hw.rtmctl, RTMCTL_PRGM)
@@ -115,7 +115,7 @@ func (hw *CAAM) EnableRTIC(blocks []MemoryBlock) (err error) {
 	hw.Lock()
 	defer hw.Unlock()
 
-	if hw.Base == 0 || hw.CCGR == 0 {
+	if hw.Base == 0 || hw.Gate == nil {
 		return errors.New("invalid CAAM instance")
 	}
 
@@ -0,0 +1,92 @@
+// NXP Cryptographic Acceleration and Assurance Module (CAAM) driver
+// https://github.com/karlo195/tamago
+//
+// Copyright (c) The TamaGo Authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+package caam
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"encoding/asn1"
+	"errors"
+	"io"
+	"math/big"
+	"sync"
+)
+
+// Signer represents an ECDSA private key whose signing operations are
+// performed by the CAAM, it implements crypto.Signer.
+type Signer struct {
+	sync.Mutex
+
+	hw   *CAAM
+	priv *ecdsa.PrivateKey
+	pdb  *SignPDB
+}
+
+// NewSigner returns a crypto.Signer for the argument ECDSA private key, the
+// key is initialized once in a cached sign protocol data block (see
+// SignPDB), which is released with [Signer.Free].
+func (hw *CAAM) NewSigner(priv *ecdsa.PrivateKey) (s *Signer, err error) {
+	if priv == nil {
+		return nil, errors.New("invalid private key")
+	}
+
+	s = &Signer{
+		hw:   hw,
+		priv: priv,
+		pdb:  &SignPDB{},
+	}
+
+	if err = s.pdb.Init(priv); err != nil {
+		return nil, err
+	}
+
+	return
+}
+
+// Public returns the public key corresponding to the private key.
+func (s *Signer) Public() crypto.PublicKey {
+	return &s.priv.PublicKey
+}
+
+// Sign signs the argument digest, the signature is returned in ASN.1 DER
+// format. The rand and opts arguments are ignored, as random generation is
+// performed internally by the CAAM.
+func (s *Signer) Sign(_ io.Reader, digest []byte, _ crypto.SignerOpts) (sig []byte, err error) {
+	s.Lock()
+	defer s.Unlock()
+
+	if s.pdb == nil {
+		return nil, errors.New("signer has been freed")
+	}
+
+	if len(digest) < s.pdb.n {
+		return nil, errors.New("invalid digest size")
+	}
+
+	r, ss, err := s.hw.Sign(nil, digest, s.pdb)
+
+	if err != nil {
+		return
+	}
+
+	return asn1.Marshal(struct {
+		R, S *big.Int
+	}{r, ss})
+}
+
+// Free frees the memory allocated by the signer.
+func (s *Signer) Free() {
+	s.Lock()
+	defer s.Unlock()
+
+	if s.pdb != nil {
+		s.pdb.Free()
+		s.pdb = nil
+	}
+}
@@ -0,0 +1,75 @@
+// NXP i.MX Clock Controller Module (CCM) support
+// https://github.com/karlo195/tamago
+//
+// Copyright (c) The TamaGo Authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+// Package ccm implements support for the NXP Clock Controller Module (CCM)
+// peripheral clock gates, shared by i.MX peripheral drivers, adopting the
+// following reference specifications:
+//   - IMX6ULLRM - i.MX 6ULL Applications Processor Reference Manual - Rev 1 2017/11
+//
+// This package is only meant to be used with `GOOS=tamago GOARCH=arm` as
+// supported by the TamaGo framework for bare metal Go, see
+// https://github.com/karlo195/tamago.
+package ccm
+
+import (
+	"github.com/karlo195/tamago/internal/reg"
+)
+
+// Clock gating modes
+// (18.6.24 CCM Clock Gating Register 0 (CCM_CCGR0), IMX6ULLRM).
+const (
+	// Clock is off during all modes
+	CG_OFF = 0b00
+	// Clock is on in run mode, but off in WAIT and STOP modes
+	CG_RUN = 0b01
+	// Clock is on during all modes, except STOP mode
+	CG_ON = 0b11
+)
+
+// Gate represents a peripheral clock gate.
+type Gate struct {
+	// CCGR is the clock gating register.
+	CCGR uint32
+	// CG is the clock gate position within CCGR.
+	CG int
+	// Count is the number of adjacent clock gates controlled together
+	// (e.g. bus and serial clocks), 0 is equivalent to 1.
+	Count int
+}
+
+func (g *Gate) count() int {
+	return max(g.Count, 1)
+}
+
+// Mode returns the clock gating mode of the first gate (see CG_* constants).
+func (g *Gate) Mode() uint32 {
+	return reg.Get(g.CCGR, g.CG, 0b11)
+}
+
+// SetMode sets the clock gating mode (see CG_* constants), care must be taken
+// as clock gates can be shared across peripheral instances.
+func (g *Gate) SetMode(mode uint32) {
+	for i := 0; i < g.count(); i++ {
+		reg.SetN(g.CCGR, g.CG+i*2, 0b11, mode)
+	}
+}
+
+// Enable opens the clock gate in all modes except STOP.
+func (g *Gate) Enable() {
+	g.SetMode(CG_ON)
+}
+
+// Disable closes the clock gate in all modes.
+func (g *Gate) Disable() {
+	g.SetMode(CG_OFF)
+}
+
+// Enabled returns whether the clock gate is open in run mode.
+func (g *Gate) Enabled() bool {
+	return g.Mode() != CG_OFF
+}
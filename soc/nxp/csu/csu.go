@@ -20,6 +20,7 @@ import (
 	"errors"
 
 	"github.com/karlo195/tamago/internal/reg"
+	"github.com/karlo195/tamago/soc/nxp/ccm"
 )
 
 // CSU registers
@@ -32,10 +33,8 @@ const (
 type CSU struct {
 	// Base register
 	Base uint32
-	// Clock gate register
-	CCGR uint32
 	// Clock gate
-	CG int
+	Gate *ccm.Gate
 
 	// control registers
 	csl0 uint32
@@ -44,7 +43,7 @@ type CSU struct {
 
 // Init initializes the Central Security Unit (CSU).
 func (hw *CSU) Init() {
-	if hw.Base == 0 || hw.CCGR == 0 {
+	if hw.Base == 0 || hw.Gate == nil {
 		panic("invalid CSU instance")
 	}
 
@@ -52,7 +51,7 @@ func (hw *CSU) Init() {
 	hw.sa = hw.Base + CSU_SA
 
 	// enable clock
-	reg.SetN(hw.CCGR, hw.CG, 0b11, 0b11)
+	hw.Gate.Enable()
 }
 
 // GetAccess returns the security access (SA) for one of the 16 masters IDs.
@@ -25,6 +25,7 @@ import (
 	"github.com/karlo195/tamago/bits"
 	"github.com/karlo195/tamago/dma"
 	"github.com/karlo195/tamago/internal/reg"
+	"github.com/karlo195/tamago/soc/nxp/ccm"
 )
 
 // DCP registers
@@ -117,10 +118,8 @@ type DCP struct {
 
 	// Base register
 	Base uint32
-	// Clock gate register
-	CCGR uint32
 	// Clock gate
-	CG int
+	Gate *ccm.Gate
 	// Interrupt ID
 	IRQ int
 
@@ -163,7 +162,7 @@ func (hw *DCP) Init() {
 	hw.Lock()
 	defer hw.Unlock()
 
-	if hw.Base == 0 || hw.CCGR == 0 {
+	if hw.Base == 0 || hw.Gate == nil {
 		panic("invalid DCP instance")
 	}
 
@@ -179,7 +178,7 @@ func (hw *DCP) Init() {
 	hw.ch0stat_clr = hw.Base + DCP_CH0STAT_CLR
 
 	// enable clock
-	reg.SetN(hw.CCGR, hw.CG, 0b11, 0b11)
+	hw.Gate.Enable()
 
 	// soft reset DCP
 	reg.Set(hw.ctrl, CTRL_SFTRST)
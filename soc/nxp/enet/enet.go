@@ -18,11 +18,15 @@ package enet
 import (
 	"crypto/rand"
 	"encoding/binary"
+	"errors"
 	"net"
 	"runtime"
 	"sync"
+	"time"
 
+	"github.com/karlo195/tamago/bits"
 	"github.com/karlo195/tamago/internal/reg"
+	"github.com/karlo195/tamago/soc/nxp/ccm"
 )
 
 // ENET registers
@@ -71,6 +75,13 @@ const (
 
 	ENETx_PALR = 0x00e4
 	ENETx_PAUR = 0x00e8
+
+	ENETx_TXIC = 0x00f0
+	ENETx_RXIC = 0x0100
+	IC_EN      = 31
+	IC_CS      = 30
+	IC_FT      = 20
+	IC_TT      = 0
 	ENETx_RDSR = 0x0180
 	ENETx_TDSR = 0x0184
 	ENETx_MRBR = 0x0188
@@ -122,10 +133,8 @@ type ENET struct {
 	Index int
 	// Base register
 	Base uint32
-	// Clock gate register
-	CCGR uint32
 	// Clock gate
-	CG int
+	Gate *ccm.Gate
 	// Clock retrieval function
 	Clock func() uint32
 	// Interrupt ID
@@ -161,6 +170,8 @@ type ENET struct {
 	tcr  uint32
 	palr uint32
 	paur uint32
+	txic uint32
+	rxic uint32
 	rdsr uint32
 	tdsr uint32
 	mrbr uint32
@@ -171,6 +182,9 @@ type ENET struct {
 	rx bufferDescriptorRing
 	// transmit data buffers
 	tx bufferDescriptorRing
+
+	// busy-poll wakeup signal
+	poll chan struct{}
 }
 
 // Init initializes and enables the Ethernet MAC controller for 100 Mbps full
@@ -208,12 +222,16 @@ func (hw *ENET) Init() {
 	hw.tcr = hw.Base + ENETx_TCR
 	hw.palr = hw.Base + ENETx_PALR
 	hw.paur = hw.Base + ENETx_PAUR
+	hw.txic = hw.Base + ENETx_TXIC
+	hw.rxic = hw.Base + ENETx_RXIC
 	hw.rdsr = hw.Base + ENETx_RDSR
 	hw.tdsr = hw.Base + ENETx_TDSR
 	hw.mrbr = hw.Base + ENETx_MRBR
 	hw.ftrl = hw.Base + ENETx_FTRL
 	hw.racc = hw.Base + ENETx_RACC
 
+	hw.poll = make(chan struct{}, 1)
+
 	hw.setup()
 
 	hw.Unlock()
@@ -221,7 +239,7 @@ func (hw *ENET) Init() {
 
 func (hw *ENET) setup() {
 	// enable clock
-	reg.SetN(hw.CCGR, hw.CG, 0b11, 0b11)
+	hw.Gate.Enable()
 	hw.EnablePLL(hw.Index)
 
 	// soft reset
@@ -316,7 +334,142 @@ func (hw *ENET) EnableInterrupt(event int) {
 	reg.Set(hw.eimr, event)
 }
 
+// DisableInterrupt disables interrupt generation for a specific event.
+func (hw *ENET) DisableInterrupt(event int) {
+	reg.Clear(hw.eimr, event)
+}
+
 // ClearInterrupt clears the interrupt corresponding to a specific event.
 func (hw *ENET) ClearInterrupt(event int) {
 	reg.Set(hw.eir, event)
 }
+
+// Poll handles up to budget received packets (see [ENET.Rx]) through
+// [ENET.RxHandler] (when set), the number of handled packets is returned.
+func (hw *ENET) Poll(budget int) (n int) {
+	for n < budget {
+		buf := hw.Rx()
+
+		if buf == nil {
+			break
+		}
+
+		if hw.RxHandler != nil {
+			hw.RxHandler(buf)
+		}
+
+		n += 1
+	}
+
+	return
+}
+
+// HandleRxInterrupt masks and clears the frame reception interrupt (see
+// [IRQ_RXF]) to resume [ENET.BusyPoll], it is meant to be invoked by the
+// application interrupt service routine.
+func (hw *ENET) HandleRxInterrupt() {
+	hw.DisableInterrupt(IRQ_RXF)
+	hw.ClearInterrupt(IRQ_RXF)
+
+	select {
+	case hw.poll <- struct{}{}:
+	default:
+	}
+}
+
+// BusyPoll handles received packets in polling mode (NAPI-style), avoiding
+// interrupt storms at high packet rates, it should never return.
+//
+// Frame reception interrupts (see [IRQ_RXF]) are masked while polling, at
+// each iteration up to budget packets are handled through [ENET.Poll]. Once
+// the receive ring is drained interrupts are unmasked and polling is
+// suspended until resumed by [ENET.HandleRxInterrupt].
+//
+// The calling goroutine is locked to its current OS thread, on multiprocessor
+// systems this pins polling to the processor running it.
+func (hw *ENET) BusyPoll(budget int) {
+	if hw.poll == nil {
+		panic("invalid ENET controller instance")
+	}
+
+	if budget <= 0 {
+		budget = hw.RingSize
+	}
+
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	for {
+		hw.DisableInterrupt(IRQ_RXF)
+
+		for hw.Poll(budget) > 0 {
+			runtime.Gosched()
+		}
+
+		hw.ClearInterrupt(IRQ_RXF)
+		hw.EnableInterrupt(IRQ_RXF)
+
+		// catch packets received before unmasking
+		if hw.Poll(budget) > 0 {
+			continue
+		}
+
+		<-hw.poll
+	}
+}
+
+// SetRxCoalescing configures receive interrupt coalescing, frame interrupts
+// (see [IRQ_RXF]) are generated once the argument frame count is reached or
+// the argument timeout elapses since the first received frame.
+//
+// Coalescing trades latency for a reduced interrupt rate, both arguments set
+// to zero disable it.
+func (hw *ENET) SetRxCoalescing(frames int, timeout time.Duration) error {
+	return hw.setCoalescing(hw.rxic, frames, timeout)
+}
+
+// SetTxCoalescing configures transmit interrupt coalescing, frame interrupts
+// (see [IRQ_TXF]) are generated once the argument frame count is reached or
+// the argument timeout elapses since the first transmitted frame.
+//
+// Coalescing trades latency for a reduced interrupt rate, both arguments set
+// to zero disable it.
+func (hw *ENET) SetTxCoalescing(frames int, timeout time.Duration) error {
+	return hw.setCoalescing(hw.txic, frames, timeout)
+}
+
+func (hw *ENET) setCoalescing(ic uint32, frames int, timeout time.Duration) error {
+	var val uint32
+
+	if ic == 0 {
+		return errors.New("invalid ENET controller instance")
+	}
+
+	// coalescing must be disabled before changing its configuration
+	reg.Clear(ic, IC_EN)
+
+	if frames == 0 && timeout == 0 {
+		return nil
+	}
+
+	if frames <= 0 || frames > 0xff {
+		return errors.New("invalid frame count threshold")
+	}
+
+	// the timer threshold is expressed in units of 64 ENET system clock
+	// cycles
+	cycles := uint64(timeout) * uint64(hw.Clock()) / uint64(time.Second) / 64
+
+	if cycles == 0 || cycles > 0xffff {
+		return errors.New("invalid timer threshold")
+	}
+
+	bits.SetN(&val, IC_FT, 0xff, uint32(frames))
+	bits.SetN(&val, IC_TT, 0xffff, uint32(cycles))
+	bits.Set(&val, IC_CS)
+
+	reg.Write(ic, val)
+	reg.Set(ic, IC_EN)
+
+	return nil
+}
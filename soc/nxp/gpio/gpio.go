@@ -18,6 +18,7 @@ import (
 	"fmt"
 
 	"github.com/karlo195/tamago/internal/reg"
+	"github.com/karlo195/tamago/soc/nxp/ccm"
 )
 
 // GPIO registers
@@ -32,10 +33,8 @@ type GPIO struct {
 	Index int
 	// Base register
 	Base uint32
-	// Clock gate register
-	CCGR uint32
 	// Clock gate
-	CG int
+	Gate *ccm.Gate
 
 	clk bool
 }
@@ -49,7 +48,7 @@ type Pin struct {
 
 // Init initializes a GPIO.
 func (hw *GPIO) Init(num int) (gpio *Pin, err error) {
-	if hw.Base == 0 || hw.CCGR == 0 {
+	if hw.Base == 0 || hw.Gate == nil {
 		return nil, errors.New("invalid GPIO controller instance")
 	}
 
@@ -65,7 +64,7 @@ func (hw *GPIO) Init(num int) (gpio *Pin, err error) {
 
 	if !hw.clk {
 		// enable clock
-		reg.SetN(hw.CCGR, hw.CG, 0b11, 0b11)
+		hw.Gate.Enable()
 		hw.clk = true
 	}
 
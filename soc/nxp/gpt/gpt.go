@@ -0,0 +1,278 @@
+// NXP General Purpose Timer (GPT) driver
+// https://github.com/karlo195/tamago
+//
+// Copyright (c) The TamaGo Authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+// Package gpt implements a driver for the NXP General Purpose Timer (GPT),
+// supporting its input capture channels, adopting the following reference
+// specifications:
+//   - IMX6ULLRM - i.MX 6ULL Applications Processor Reference Manual - Rev 1 2017/11
+//
+// This package is only meant to be used with `GOOS=tamago GOARCH=arm` as
+// supported by the TamaGo framework for bare metal Go, see
+// https://github.com/karlo195/tamago.
+package gpt
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/karlo195/tamago/capture"
+	"github.com/karlo195/tamago/internal/reg"
+	"github.com/karlo195/tamago/soc/nxp/ccm"
+)
+
+// GPT registers
+// (p1129, 30.6 GPT Memory Map/Register Definition, IMX6ULLRM)
+const (
+	GPT_CR     = 0x00
+	CR_IM2     = 18
+	CR_IM1     = 16
+	CR_SWR     = 15
+	CR_EN_24M  = 10
+	CR_FRR     = 9
+	CR_CLKSRC  = 6
+	CR_WAITEN  = 3
+	CR_DBGEN   = 2
+	CR_ENMOD   = 1
+	CR_EN      = 0
+	CLKSRC_24M = 0b101
+
+	GPT_PR          = 0x04
+	PR_PRESCALER24M = 12
+	PR_PRESCALER    = 0
+
+	GPT_SR = 0x08
+	SR_ROV = 5
+	SR_IF2 = 4
+	SR_IF1 = 3
+
+	GPT_IR   = 0x0c
+	GPT_ICR1 = 0x1c
+	GPT_ICR2 = 0x20
+	GPT_CNT  = 0x24
+)
+
+// Input capture channels
+const (
+	CAPTURE1 = 1
+	CAPTURE2 = 2
+)
+
+// OSC24M is the 24 MHz crystal oscillator frequency, used as GPT clock
+// source.
+const OSC24M = 24000000
+
+// GPT represents a General Purpose Timer instance.
+type GPT struct {
+	sync.Mutex
+
+	// Controller index
+	Index int
+	// Base register
+	Base uint32
+	// Clock gate, covering the bus and serial clocks (see ccm.Gate.Count)
+	Gate *ccm.Gate
+	// Prescaler sets the 24 MHz clock divider (1-16), 1 is used when
+	// unset.
+	Prescaler int
+
+	// control registers
+	cr  uint32
+	pr  uint32
+	sr  uint32
+	cnt uint32
+
+	// extended counter state
+	high uint64
+	last uint32
+}
+
+// Channel represents a GPT input capture channel, it implements
+// [capture.Channel].
+type Channel struct {
+	gpt  *GPT
+	n    int
+	icr  uint32
+	edge capture.Edge
+	// last captured level, used when capturing both edges
+	level bool
+}
+
+// Init initializes the General Purpose Timer as a free running counter
+// clocked by the 24 MHz crystal oscillator
+// (p1121, 30.5.1 Selecting the Clock Source, IMX6ULLRM).
+func (hw *GPT) Init() (err error) {
+	hw.Lock()
+	defer hw.Unlock()
+
+	if hw.Base == 0 || hw.Gate == nil {
+		return errors.New("invalid GPT controller instance")
+	}
+
+	if hw.Prescaler == 0 {
+		hw.Prescaler = 1
+	}
+
+	if hw.Prescaler < 1 || hw.Prescaler > 16 {
+		return errors.New("invalid prescaler")
+	}
+
+	hw.cr = hw.Base + GPT_CR
+	hw.pr = hw.Base + GPT_PR
+	hw.sr = hw.Base + GPT_SR
+	hw.cnt = hw.Base + GPT_CNT
+
+	// enable bus and serial clocks
+	hw.Gate.Enable()
+
+	// disable and reset
+	reg.Write(hw.cr, 0)
+	reg.Write(hw.Base+GPT_IR, 0)
+	reg.Set(hw.cr, CR_SWR)
+	reg.Wait(hw.cr, CR_SWR, 1, 0)
+
+	// select the 24 MHz crystal oscillator
+	reg.Write(hw.cr, CLKSRC_24M<<CR_CLKSRC|1<<CR_EN_24M|1<<CR_FRR|1<<CR_ENMOD|1<<CR_WAITEN|1<<CR_DBGEN)
+	reg.Write(hw.pr, uint32(hw.Prescaler-1)<<PR_PRESCALER24M)
+
+	// clear status
+	reg.Write(hw.sr, 0x3f)
+
+	reg.Set(hw.cr, CR_EN)
+
+	hw.high = 0
+	hw.last = 0
+
+	return
+}
+
+// Frequency returns the counter frequency in Hz.
+func (hw *GPT) Frequency() uint32 {
+	return OSC24M / uint32(hw.Prescaler)
+}
+
+// extend returns the argument 32-bit counter value extended to 64 bits, it
+// must be invoked at least once per counter rollover period (about 179
+// seconds without prescaling).
+func (hw *GPT) extend(val uint32) uint64 {
+	if val < hw.last {
+		hw.high += 1 << 32
+	}
+
+	hw.last = val
+
+	return hw.high | uint64(val)
+}
+
+// Counter returns the counter value, extended to 64 bits.
+func (hw *GPT) Counter() uint64 {
+	hw.Lock()
+	defer hw.Unlock()
+
+	return hw.extend(reg.Read(hw.cnt))
+}
+
+// EnableCapture enables the indexed input capture channel (CAPTURE1 or
+// CAPTURE2) on the argument edges, the corresponding GPT_CAPTUREx pad must be
+// configured separately (see iomuxc package).
+//
+// Captured edges must be collected with [Channel.Capture] at least once per
+// counter rollover period, as the controller only holds the latest one.
+func (hw *GPT) EnableCapture(n int, edge capture.Edge) (ch *Channel, err error) {
+	hw.Lock()
+	defer hw.Unlock()
+
+	if hw.cr == 0 {
+		return nil, errors.New("controller not initialized")
+	}
+
+	if edge&capture.Both == 0 {
+		return nil, errors.New("invalid edge")
+	}
+
+	var pos int
+	var icr uint32
+
+	switch n {
+	case CAPTURE1:
+		pos = CR_IM1
+		icr = hw.Base + GPT_ICR1
+	case CAPTURE2:
+		pos = CR_IM2
+		icr = hw.Base + GPT_ICR2
+	default:
+		return nil, errors.New("invalid capture channel")
+	}
+
+	ch = &Channel{
+		gpt:  hw,
+		n:    n,
+		icr:  icr,
+		edge: edge,
+	}
+
+	// input capture operating mode encoding matches capture.Edge
+	reg.SetN(hw.cr, pos, 0b11, uint32(edge))
+	reg.Write(hw.sr, 1<<(SR_IF1+n-1))
+
+	return
+}
+
+// DisableCapture disables the indexed input capture channel.
+func (hw *GPT) DisableCapture(n int) {
+	hw.Lock()
+	defer hw.Unlock()
+
+	switch n {
+	case CAPTURE1:
+		reg.SetN(hw.cr, CR_IM1, 0b11, 0)
+	case CAPTURE2:
+		reg.SetN(hw.cr, CR_IM2, 0b11, 0)
+	}
+}
+
+// Capture returns the latest captured edge, if any.
+//
+// When capturing both edges the transition direction cannot be read from the
+// controller, edges are therefore assumed to alternate starting with a rising
+// one.
+func (ch *Channel) Capture() (s capture.Sample, ok bool) {
+	hw := ch.gpt
+
+	hw.Lock()
+	defer hw.Unlock()
+
+	flag := SR_IF1 + ch.n - 1
+
+	if reg.Get(hw.sr, flag, 1) == 0 {
+		return
+	}
+
+	val := reg.Read(ch.icr)
+	reg.Write(hw.sr, 1<<flag)
+
+	// extend the capture value against the current counter
+	now := hw.extend(reg.Read(hw.cnt))
+	s.Ticks = now - uint64(uint32(now)-val)
+
+	switch ch.edge {
+	case capture.Rising:
+		s.Rising = true
+	case capture.Falling:
+		s.Rising = false
+	default:
+		ch.level = !ch.level
+		s.Rising = ch.level
+	}
+
+	return s, true
+}
+
+// Frequency returns the capture timestamp frequency in Hz.
+func (ch *Channel) Frequency() uint32 {
+	return ch.gpt.Frequency()
+}
@@ -22,6 +22,7 @@ import (
 	"time"
 
 	"github.com/karlo195/tamago/internal/reg"
+	"github.com/karlo195/tamago/soc/nxp/ccm"
 )
 
 // I2C registers
@@ -65,10 +66,8 @@ type I2C struct {
 	Index int
 	// Base register
 	Base uint32
-	// Clock gate register
-	CCGR uint32
 	// Clock gate
-	CG int
+	Gate *ccm.Gate
 	// Timeout for I2C operations
 	Timeout time.Duration
 	// Div sets the frequency divider to control the I2C clock rate
@@ -81,15 +80,18 @@ type I2C struct {
 	i2cr uint32
 	i2sr uint32
 	i2dr uint32
+
+	// target mode state
+	target *target
 }
 
-// Init initializes the I2C controller instance. At this time only master mode
-// is supported by this driver.
+// Init initializes the I2C controller instance in controller (master) mode,
+// target (slave) mode can be enabled afterwards with [I2C.EnableTarget].
 func (hw *I2C) Init() {
 	hw.Lock()
 	defer hw.Unlock()
 
-	if hw.Base == 0 || hw.CCGR == 0 {
+	if hw.Base == 0 || hw.Gate == nil {
 		panic("invalid I2C controller instance")
 	}
 
@@ -110,7 +112,7 @@ func (hw *I2C) Init() {
 	// p1452, 31.5.1 Initialization sequence, IMX6ULLRM
 
 	// enable clock
-	reg.SetN(hw.CCGR, hw.CG, 0b11, 0b11)
+	hw.Gate.Enable()
 
 	// Set SCL frequency
 	reg.Write16(hw.ifdr, hw.Div)
@@ -0,0 +1,145 @@
+// NXP I2C driver
+// https://github.com/karlo195/tamago
+//
+// Copyright (c) The TamaGo Authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+package i2c
+
+import (
+	"errors"
+
+	"github.com/karlo195/tamago/internal/reg"
+)
+
+// I2C target mode register bits
+// (p1466, 31.7.3 I2C Control Register (I2Cx_I2CR), IMX6ULLRM)
+// (p1467, 31.7.4 I2C Status Register (I2Cx_I2SR), IMX6ULLRM)
+const (
+	I2CR_IIEN = 6
+
+	I2SR_IAAS = 6
+	I2SR_SRW  = 2
+)
+
+// RegisterFile represents the register file of an I2C target, accessed by the
+// bus controller with register address auto-increment: the first byte
+// written after addressing sets the register pointer, following writes and
+// reads access consecutive registers.
+type RegisterFile interface {
+	// Read returns the value of the register at the argument address.
+	Read(addr uint8) uint8
+	// Write sets the value of the register at the argument address.
+	Write(addr uint8, val uint8)
+}
+
+type target struct {
+	rf RegisterFile
+
+	// register pointer
+	ptr uint8
+	// register pointer set in current write transaction
+	pointer bool
+}
+
+// EnableTarget enables target (slave) mode, on the argument 7-bit address,
+// with the argument register file serving bus controller accesses
+// (p1455, 31.5.6 Slave mode, IMX6ULLRM).
+//
+// Target events must be processed with [I2C.HandleTarget], either in polling
+// or from the I2C interrupt handler as the interrupt is enabled. Controller
+// mode transfers (i.e. [I2C.Read] and [I2C.Write]) must not be performed
+// while target mode is enabled.
+func (hw *I2C) EnableTarget(addr uint8, rf RegisterFile) (err error) {
+	if addr > 0x7f {
+		return errors.New("invalid target address")
+	}
+
+	if rf == nil {
+		return errors.New("invalid register file")
+	}
+
+	hw.Lock()
+	defer hw.Unlock()
+
+	hw.target = &target{rf: rf}
+
+	reg.Write16(hw.iadr, uint16(addr)<<1)
+
+	reg.Clear16(hw.i2cr, I2CR_MSTA)
+	reg.Clear16(hw.i2cr, I2CR_MTX)
+	reg.Clear16(hw.i2cr, I2CR_TXAK)
+	reg.Clear16(hw.i2sr, I2SR_IIF)
+	reg.Set16(hw.i2cr, I2CR_IIEN)
+
+	return
+}
+
+// DisableTarget disables target (slave) mode.
+func (hw *I2C) DisableTarget() {
+	hw.Lock()
+	defer hw.Unlock()
+
+	reg.Clear16(hw.i2cr, I2CR_IIEN)
+	reg.Clear16(hw.i2cr, I2CR_MTX)
+	reg.Clear16(hw.i2sr, I2SR_IIF)
+
+	hw.target = nil
+}
+
+// HandleTarget processes a pending target mode event (addressing or byte
+// transfer completion), if any, it returns whether an event was handled.
+func (hw *I2C) HandleTarget() (handled bool) {
+	hw.Lock()
+	defer hw.Unlock()
+
+	t := hw.target
+
+	if t == nil || reg.Get16(hw.i2sr, I2SR_IIF, 1) == 0 {
+		return false
+	}
+
+	reg.Clear16(hw.i2sr, I2SR_IIF)
+
+	switch {
+	case reg.Get16(hw.i2sr, I2SR_IAAS, 1) == 1:
+		// addressed as target
+		if reg.Get16(hw.i2sr, I2SR_SRW, 1) == 1 {
+			// controller read, transmit first register
+			reg.Set16(hw.i2cr, I2CR_MTX)
+			reg.Write16(hw.i2dr, uint16(t.rf.Read(t.ptr)))
+			t.ptr++
+		} else {
+			// controller write, dummy read to release the bus
+			reg.Clear16(hw.i2cr, I2CR_MTX)
+			reg.Read16(hw.i2dr)
+			t.pointer = false
+		}
+	case reg.Get16(hw.i2cr, I2CR_MTX, 1) == 1:
+		if reg.Get16(hw.i2sr, I2SR_RXAK, 1) == 1 {
+			// end of controller read, switch to receive
+			// and dummy read to release the bus
+			reg.Clear16(hw.i2cr, I2CR_MTX)
+			reg.Read16(hw.i2dr)
+			break
+		}
+
+		reg.Write16(hw.i2dr, uint16(t.rf.Read(t.ptr)))
+		t.ptr++
+	default:
+		val := uint8(reg.Read16(hw.i2dr) & 0xff)
+
+		if !t.pointer {
+			t.ptr = val
+			t.pointer = true
+			break
+		}
+
+		t.rf.Write(t.ptr, val)
+		t.ptr++
+	}
+
+	return true
+}
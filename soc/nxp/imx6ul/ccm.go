@@ -0,0 +1,386 @@
+// NXP i.MX6UL clock control
+// https://github.com/karlo195/tamago
+//
+// Copyright (c) The TamaGo Authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+package imx6ul
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/karlo195/tamago/internal/reg"
+	"github.com/karlo195/tamago/soc/nxp/ccm"
+)
+
+// Clock tree registers
+// (18.6 CCM Memory Map/Register Definition, IMX6ULLRM)
+// (18.7 CCM Analog Memory Map/Register Definition, IMX6ULLRM).
+const (
+	CBCDR_PERIPH_CLK2_PODF = 27
+	CBCDR_PERIPH_CLK_SEL   = 25
+	CBCDR_AHB_PODF         = 10
+
+	CCM_CDHIPR           = 0x020c4048
+	CDHIPR_AHB_PODF_BUSY = 1
+
+	CCM_CBCMR                = 0x020c4018
+	CBCMR_PRE_PERIPH_CLK_SEL = 18
+	CBCMR_PERIPH_CLK2_SEL    = 12
+
+	CCM_ANALOG_PLL_SYS = CCM_ANALOG_PLL_ARM + 0x30
+
+	CCM_ANALOG_PLL_AUDIO       = CCM_ANALOG_PLL_ARM + 0x70
+	CCM_ANALOG_PLL_AUDIO_NUM   = CCM_ANALOG_PLL_ARM + 0x80
+	CCM_ANALOG_PLL_AUDIO_DENOM = CCM_ANALOG_PLL_ARM + 0x90
+
+	CCM_ANALOG_PLL_VIDEO       = CCM_ANALOG_PLL_ARM + 0xa0
+	CCM_ANALOG_PLL_VIDEO_NUM   = CCM_ANALOG_PLL_ARM + 0xb0
+	CCM_ANALOG_PLL_VIDEO_DENOM = CCM_ANALOG_PLL_ARM + 0xc0
+
+	PLL_POST_DIV_SELECT = 19
+)
+
+// ENET PLL reference frequency
+const PLL6_FREQ = 500000000
+
+// PLL instances
+// (18.5.1.3 PLLs, IMX6ULLRM).
+const (
+	PLL_ARM   = 1
+	PLL_SYS   = 2
+	PLL_USB1  = 3
+	PLL_AUDIO = 4
+	PLL_VIDEO = 5
+	PLL_ENET  = 6
+	PLL_USB2  = 7
+)
+
+// PLL lock timeout
+const pllLockTimeout = 10 * time.Millisecond
+
+// ClockGate represents a named peripheral clock gate.
+type ClockGate struct {
+	*ccm.Gate
+
+	// Name is the peripheral instance name.
+	Name string
+}
+
+// ClockGates returns the clock gates of all available SoC peripheral
+// instances, sorted by name.
+func ClockGates() (gates []*ClockGate) {
+	add := func(name string, gate *ccm.Gate) {
+		if gate != nil {
+			gates = append(gates, &ClockGate{Name: name, Gate: gate})
+		}
+	}
+
+	add("CSU", CSU.Gate)
+
+	if DCP != nil {
+		add("DCP", DCP.Gate)
+	}
+
+	if ENET1 != nil {
+		add("ENET1", ENET1.Gate)
+	}
+
+	if ENET2 != nil {
+		add("ENET2", ENET2.Gate)
+	}
+
+	add("GPIO1", GPIO1.Gate)
+	add("GPIO2", GPIO2.Gate)
+	add("GPIO3", GPIO3.Gate)
+	add("GPIO4", GPIO4.Gate)
+	add("GPIO5", GPIO5.Gate)
+	add("GPT1", GPT1.Gate)
+	add("GPT2", GPT2.Gate)
+	add("I2C1", I2C1.Gate)
+	add("I2C2", I2C2.Gate)
+	add("OCOTP", OCOTP.Gate)
+	add("SNVS", SNVS.Gate)
+	add("UART1", UART1.Gate)
+	add("UART2", UART2.Gate)
+	add("USB1", USB1.Gate)
+	add("USB2", USB2.Gate)
+	add("USDHC1", USDHC1.Gate)
+	add("USDHC2", USDHC2.Gate)
+	add("WDOG1", WDOG1.Gate)
+	add("WDOG2", WDOG2.Gate)
+	add("WDOG3", WDOG3.Gate)
+
+	sort.Slice(gates, func(i, j int) bool {
+		return gates[i].Name < gates[j].Name
+	})
+
+	return
+}
+
+// LookupClockGate returns the clock gate for the named SoC peripheral
+// instance (e.g. "UART1").
+func LookupClockGate(name string) (*ClockGate, error) {
+	for _, g := range ClockGates() {
+		if g.Name == name {
+			return g, nil
+		}
+	}
+
+	return nil, fmt.Errorf("clock gate %s not found", name)
+}
+
+func pllRegister(pll int) (addr uint32, err error) {
+	switch pll {
+	case PLL_ARM:
+		addr = CCM_ANALOG_PLL_ARM
+	case PLL_SYS:
+		addr = CCM_ANALOG_PLL_SYS
+	case PLL_USB1:
+		addr = CCM_ANALOG_PLL_USB1
+	case PLL_AUDIO:
+		addr = CCM_ANALOG_PLL_AUDIO
+	case PLL_VIDEO:
+		addr = CCM_ANALOG_PLL_VIDEO
+	case PLL_ENET:
+		addr = CCM_ANALOG_PLL_ENET
+	case PLL_USB2:
+		addr = CCM_ANALOG_PLL_USB2
+	default:
+		err = errors.New("invalid PLL index")
+	}
+
+	return
+}
+
+// GetPLLClock returns the output frequency of a PLL (see PLL_* constants),
+// zero is returned for powered down PLLs. The 500 MHz reference frequency is
+// returned for PLL_ENET, see EnableENETPLL for its output dividers
+// (18.7 CCM Analog Memory Map/Register Definition, IMX6ULLRM).
+func GetPLLClock(pll int) (hz uint32) {
+	addr, err := pllRegister(pll)
+
+	if err != nil {
+		return
+	}
+
+	r := reg.Read(addr)
+
+	if r&(1<<PLL_BYPASS) != 0 {
+		return OSC_FREQ
+	}
+
+	switch pll {
+	case PLL_USB1, PLL_USB2:
+		// POWER bit is active high
+		if r&(1<<PLL_POWER) == 0 {
+			return
+		}
+	default:
+		// POWERDOWN bit is active high
+		if r&(1<<PLL_POWER) != 0 {
+			return
+		}
+	}
+
+	switch pll {
+	case PLL_ARM:
+		return uint32(OSC_FREQ * ARMPLLDiv())
+	case PLL_SYS, PLL_USB1, PLL_USB2:
+		if r&1 == 1 {
+			return OSC_FREQ * 22
+		}
+
+		return OSC_FREQ * 20
+	case PLL_AUDIO, PLL_VIDEO:
+		// CCM_ANALOG_PLL_{AUDIO,VIDEO}_{NUM,DENOM}
+		num := reg.Read(addr + 0x10)
+		denom := reg.Read(addr + 0x20)
+
+		freq := float64(OSC_FREQ) * float64(r&0x7f)
+
+		if denom != 0 {
+			freq += float64(OSC_FREQ) * float64(num&0x3fffffff) / float64(denom&0x3fffffff)
+		}
+
+		switch (r >> PLL_POST_DIV_SELECT) & 0b11 {
+		case 0b00:
+			freq /= 4
+		case 0b01:
+			freq /= 2
+		}
+
+		return uint32(freq)
+	case PLL_ENET:
+		return PLL6_FREQ
+	}
+
+	return
+}
+
+// SetPLL configures the loop divider of a PLL (see PLL_* constants), which
+// must be 20 or 22 for PLL_SYS, PLL_USB1 and PLL_USB2 and between 27 and 54 for
+// PLL_AUDIO and PLL_VIDEO, which also take the fractional loop divider
+// numerator and denominator (18.7 CCM Analog Memory Map/Register Definition,
+// IMX6ULLRM).
+//
+// The PLL_ARM and PLL_ENET PLLs are configured with SetARMFreq and
+// EnableENETPLL, care must be taken as clock roots derived from the PLL change
+// frequency accordingly.
+func SetPLL(pll int, div uint32, num uint32, denom uint32) (err error) {
+	addr, err := pllRegister(pll)
+
+	if err != nil {
+		return
+	}
+
+	switch pll {
+	case PLL_SYS, PLL_USB1, PLL_USB2:
+		if div != 20 && div != 22 {
+			return errors.New("invalid PLL divider")
+		}
+
+		reg.SetN(addr, PLL_DIV_SELECT, 1, (div-20)/2)
+	case PLL_AUDIO, PLL_VIDEO:
+		if div < 27 || div > 54 || denom == 0 || denom > 0x3fffffff || num >= denom {
+			return errors.New("invalid PLL divider")
+		}
+
+		if pll == PLL_AUDIO {
+			reg.Write(CCM_ANALOG_PLL_AUDIO_NUM, num)
+			reg.Write(CCM_ANALOG_PLL_AUDIO_DENOM, denom)
+		} else {
+			reg.Write(CCM_ANALOG_PLL_VIDEO_NUM, num)
+			reg.Write(CCM_ANALOG_PLL_VIDEO_DENOM, denom)
+		}
+
+		reg.SetN(addr, PLL_DIV_SELECT, 0x7f, div)
+	default:
+		return errors.New("unsupported PLL")
+	}
+
+	if GetPLLClock(pll) == 0 || reg.IsSet(addr, PLL_BYPASS) {
+		return
+	}
+
+	if !reg.WaitFor(pllLockTimeout, addr, PLL_LOCK, 1, 1) {
+		return errors.New("PLL lock timeout")
+	}
+
+	return
+}
+
+// SetAHBDiv sets the AHB_CLK_ROOT divider (1-8), IPG_CLK_ROOT is derived from
+// it (p629, Figure 18-2. Clock Tree - Part 1, IMX6ULLRM).
+func SetAHBDiv(div uint32) (err error) {
+	if div < 1 || div > 8 {
+		return errors.New("invalid AHB divider")
+	}
+
+	reg.SetN(CCM_CBCDR, CBCDR_AHB_PODF, 0b111, div-1)
+	reg.Wait(CCM_CDHIPR, CDHIPR_AHB_PODF_BUSY, 1, 0)
+
+	return
+}
+
+// SetIPGDiv sets the IPG_CLK_ROOT divider (1-4)
+// (p629, Figure 18-2. Clock Tree - Part 1, IMX6ULLRM).
+func SetIPGDiv(div uint32) (err error) {
+	if div < 1 || div > 4 {
+		return errors.New("invalid IPG divider")
+	}
+
+	reg.SetN(CCM_CBCDR, CBCDR_IPG_PODF, 0b11, div-1)
+
+	return
+}
+
+// SetPERCLKDiv sets the PERCLK_CLK_ROOT divider (1-64)
+// (p629, Figure 18-2. Clock Tree - Part 1, IMX6ULLRM).
+func SetPERCLKDiv(div uint32) (err error) {
+	if div < 1 || div > 64 {
+		return errors.New("invalid PERCLK divider")
+	}
+
+	reg.SetN(CCM_CSCMR1, CSCMR1_PERCLK_PODF, 0x3f, div-1)
+
+	return
+}
+
+// GetAHBClock returns the AHB_CLK_ROOT frequency
+// (p629, Figure 18-2. Clock Tree - Part 1, IMX6ULLRM).
+func GetAHBClock() uint32 {
+	var freq uint32
+
+	if reg.Get(CCM_CBCDR, CBCDR_PERIPH_CLK_SEL, 1) == 1 {
+		switch reg.Get(CCM_CBCMR, CBCMR_PERIPH_CLK2_SEL, 0b11) {
+		case 0b00:
+			freq = GetPLLClock(PLL_USB1)
+		default:
+			freq = OSC_FREQ
+		}
+
+		freq /= reg.Get(CCM_CBCDR, CBCDR_PERIPH_CLK2_PODF, 0b111) + 1
+	} else {
+		switch reg.Get(CCM_CBCMR, CBCMR_PRE_PERIPH_CLK_SEL, 0b11) {
+		case 0b00:
+			freq = GetPLLClock(PLL_SYS)
+		case 0b01:
+			_, freq = GetPFD(2, 2)
+		case 0b10:
+			_, freq = GetPFD(2, 0)
+		case 0b11:
+			freq = ARMFreq()
+		}
+	}
+
+	return freq / (reg.Get(CCM_CBCDR, CBCDR_AHB_PODF, 0b111) + 1)
+}
+
+// ClockInfo represents a clock tree node frequency.
+type ClockInfo struct {
+	// Name is the clock name.
+	Name string
+	// Hz is the clock frequency.
+	Hz uint32
+}
+
+// String returns the clock name and frequency in MHz.
+func (c ClockInfo) String() string {
+	return fmt.Sprintf("%-16s %4d.%03d MHz", c.Name, c.Hz/1000000, c.Hz/1000%1000)
+}
+
+// ClockTree returns the frequency of all PLLs, PFDs and root clocks supported
+// by this package.
+func ClockTree() (clocks []ClockInfo) {
+	for pll := PLL_ARM; pll <= PLL_USB2; pll++ {
+		clocks = append(clocks, ClockInfo{fmt.Sprintf("PLL%d", pll), GetPLLClock(pll)})
+	}
+
+	for _, pll := range []int{2, 3} {
+		for pfd := 0; pfd < 4; pfd++ {
+			_, hz := GetPFD(pll, pfd)
+			clocks = append(clocks, ClockInfo{fmt.Sprintf("PLL%d_PFD%d", pll, pfd), hz})
+		}
+	}
+
+	_, _, usdhc1 := GetUSDHCClock(1)
+	_, _, usdhc2 := GetUSDHCClock(2)
+
+	clocks = append(clocks,
+		ClockInfo{"ARM_CLK_ROOT", ARMFreq()},
+		ClockInfo{"AHB_CLK_ROOT", GetAHBClock()},
+		ClockInfo{"IPG_CLK_ROOT", GetPeripheralClock()},
+		ClockInfo{"PERCLK_CLK_ROOT", GetHighFrequencyClock()},
+		ClockInfo{"UART_CLK_ROOT", GetUARTClock()},
+		ClockInfo{"USDHC1_CLK_ROOT", usdhc1},
+		ClockInfo{"USDHC2_CLK_ROOT", usdhc2},
+	)
+
+	return
+}
@@ -256,9 +256,9 @@ func SetARMFreq(mhz uint32) (err error) {
 // GetPeripheralClock returns the IPG_CLK_ROOT frequency,
 // (p629, Figure 18-2. Clock Tree - Part 1, IMX6ULLRM).
 func GetPeripheralClock() uint32 {
-	// IPG_CLK_ROOT derived from AHB_CLK_ROOT which is 132 MHz
+	// IPG_CLK_ROOT derived from AHB_CLK_ROOT
 	ipg_podf := reg.Get(CCM_CBCDR, CBCDR_IPG_PODF, 0b11)
-	return AHB_FREQ / (ipg_podf + 1)
+	return GetAHBClock() / (ipg_podf + 1)
 }
 
 // GetHighFrequencyClock returns the PERCLK_CLK_ROOT frequency,
@@ -27,19 +27,21 @@ package imx6ul
 
 import (
 	"encoding/binary"
-
-	"github.com/karlo195/tamago/internal/reg"
+	"errors"
+	"net"
 
 	"github.com/karlo195/tamago/arm"
 	"github.com/karlo195/tamago/arm/gic"
 	"github.com/karlo195/tamago/arm/tzc380"
-
+	"github.com/karlo195/tamago/internal/reg"
 	"github.com/karlo195/tamago/soc/nxp/bee"
 	"github.com/karlo195/tamago/soc/nxp/caam"
+	"github.com/karlo195/tamago/soc/nxp/ccm"
 	"github.com/karlo195/tamago/soc/nxp/csu"
 	"github.com/karlo195/tamago/soc/nxp/dcp"
 	"github.com/karlo195/tamago/soc/nxp/enet"
 	"github.com/karlo195/tamago/soc/nxp/gpio"
+	"github.com/karlo195/tamago/soc/nxp/gpt"
 	"github.com/karlo195/tamago/soc/nxp/i2c"
 	"github.com/karlo195/tamago/soc/nxp/ocotp"
 	"github.com/karlo195/tamago/soc/nxp/rngb"
@@ -56,6 +58,11 @@ const (
 	// The first 32 interrupts are private to the CPUs' interface.
 	BASE_IRQ = 32
 
+	// Power Management Unit brownout (1P1, 2P5, 3P0 regulators)
+	PMU_BO_IRQ = BASE_IRQ + 49
+	// Power Management Unit brownout (ARM core, SoC regulators)
+	PMU_BO_CORE_IRQ = BASE_IRQ + 54
+
 	// Data Co-Processor (ULL/ULZ only)
 	DCP_IRQ = BASE_IRQ + 47
 
@@ -63,6 +70,9 @@ const (
 	ENET1_IRQ = BASE_IRQ + 118
 	ENET2_IRQ = BASE_IRQ + 120
 
+	// Secure Non-Volatile Storage (including SRTC alarm)
+	SNVS_IRQ = BASE_IRQ + 19
+
 	// USB 2.0 controller
 	USB1_IRQ = BASE_IRQ + 43
 	USB2_IRQ = BASE_IRQ + 42
@@ -97,6 +107,10 @@ const (
 	GPIO4_BASE = 0x020a8000
 	GPIO5_BASE = 0x020ac000
 
+	// General Purpose Timer
+	GPT1_BASE = 0x02098000
+	GPT2_BASE = 0x020e8000
+
 	// Ethernet MAC (UL/ULL only)
 	ENET1_BASE = 0x02188000
 	ENET2_BASE = 0x020b4000
@@ -169,8 +183,7 @@ var (
 	// Central Security Unit
 	CSU = &csu.CSU{
 		Base: CSU_BASE,
-		CCGR: CCM_CCGR1,
-		CG:   CCGRx_CG14,
+		Gate: &ccm.Gate{CCGR: CCM_CCGR1, CG: CCGRx_CG14},
 	}
 
 	// Data Co-Processor (ULL/ULZ only)
@@ -185,40 +198,49 @@ var (
 	GPIO1 = &gpio.GPIO{
 		Index: 1,
 		Base:  GPIO1_BASE,
-		CCGR:  CCM_CCGR1,
-		CG:    CCGRx_CG13,
+		Gate:  &ccm.Gate{CCGR: CCM_CCGR1, CG: CCGRx_CG13},
 	}
 
 	// GPIO controller 2
 	GPIO2 = &gpio.GPIO{
 		Index: 2,
 		Base:  GPIO2_BASE,
-		CCGR:  CCM_CCGR0,
-		CG:    CCGRx_CG15,
+		Gate:  &ccm.Gate{CCGR: CCM_CCGR0, CG: CCGRx_CG15},
 	}
 
 	// GPIO controller 3
 	GPIO3 = &gpio.GPIO{
 		Index: 3,
 		Base:  GPIO3_BASE,
-		CCGR:  CCM_CCGR2,
-		CG:    CCGRx_CG13,
+		Gate:  &ccm.Gate{CCGR: CCM_CCGR2, CG: CCGRx_CG13},
 	}
 
 	// GPIO controller 4
 	GPIO4 = &gpio.GPIO{
 		Index: 4,
 		Base:  GPIO4_BASE,
-		CCGR:  CCM_CCGR3,
-		CG:    CCGRx_CG6,
+		Gate:  &ccm.Gate{CCGR: CCM_CCGR3, CG: CCGRx_CG6},
 	}
 
 	// GPIO controller 5
 	GPIO5 = &gpio.GPIO{
 		Index: 5,
 		Base:  GPIO5_BASE,
-		CCGR:  CCM_CCGR1,
-		CG:    CCGRx_CG15,
+		Gate:  &ccm.Gate{CCGR: CCM_CCGR1, CG: CCGRx_CG15},
+	}
+
+	// General Purpose Timer 1
+	GPT1 = &gpt.GPT{
+		Index: 1,
+		Base:  GPT1_BASE,
+		Gate:  &ccm.Gate{CCGR: CCM_CCGR1, CG: CCGRx_CG10, Count: 2},
+	}
+
+	// General Purpose Timer 2
+	GPT2 = &gpt.GPT{
+		Index: 2,
+		Base:  GPT2_BASE,
+		Gate:  &ccm.Gate{CCGR: CCM_CCGR0, CG: CCGRx_CG12, Count: 2},
 	}
 
 	// Ethernet MAC 1 (UL/ULL only)
@@ -229,24 +251,21 @@ var (
 	I2C1 = &i2c.I2C{
 		Index: 1,
 		Base:  I2C1_BASE,
-		CCGR:  CCM_CCGR2,
-		CG:    CCGRx_CG3,
+		Gate:  &ccm.Gate{CCGR: CCM_CCGR2, CG: CCGRx_CG3},
 	}
 
 	// I2C controller 2
 	I2C2 = &i2c.I2C{
 		Index: 2,
 		Base:  I2C2_BASE,
-		CCGR:  CCM_CCGR2,
-		CG:    CCGRx_CG5,
+		Gate:  &ccm.Gate{CCGR: CCM_CCGR2, CG: CCGRx_CG5},
 	}
 
 	// On-Chip OTP Controller
 	OCOTP = &ocotp.OCOTP{
 		Base:     OCOTP_BASE,
 		BankBase: OCOTP_BANK_BASE,
-		CCGR:     CCM_CCGR2,
-		CG:       CCGRx_CG6,
+		Gate:     &ccm.Gate{CCGR: CCM_CCGR2, CG: CCGRx_CG6},
 	}
 
 	// True Random Number Generator (ULL/ULZ only)
@@ -255,8 +274,7 @@ var (
 	// Secure Non-Volatile Storage
 	SNVS = &snvs.SNVS{
 		Base: SNVS_HP_BASE,
-		CCGR: CCM_CCGR5,
-		CG:   CCGRx_CG9,
+		Gate: &ccm.Gate{CCGR: CCM_CCGR5, CG: CCGRx_CG9},
 	}
 
 	// Temperature Monitor
@@ -276,8 +294,7 @@ var (
 	UART1 = &uart.UART{
 		Index: 1,
 		Base:  UART1_BASE,
-		CCGR:  CCM_CCGR5,
-		CG:    CCGRx_CG12,
+		Gate:  &ccm.Gate{CCGR: CCM_CCGR5, CG: CCGRx_CG12},
 		Clock: GetUARTClock,
 	}
 
@@ -285,8 +302,7 @@ var (
 	UART2 = &uart.UART{
 		Index: 2,
 		Base:  UART2_BASE,
-		CCGR:  CCM_CCGR0,
-		CG:    CCGRx_CG14,
+		Gate:  &ccm.Gate{CCGR: CCM_CCGR0, CG: CCGRx_CG14},
 		Clock: GetUARTClock,
 	}
 
@@ -294,8 +310,7 @@ var (
 	USB1 = &usb.USB{
 		Index:     1,
 		Base:      USB1_BASE,
-		CCGR:      CCM_CCGR6,
-		CG:        CCGRx_CG0,
+		Gate:      &ccm.Gate{CCGR: CCM_CCGR6, CG: CCGRx_CG0},
 		Analog:    USB_ANALOG1_BASE,
 		PHY:       USBPHY1_BASE,
 		IRQ:       USB1_IRQ,
@@ -306,8 +321,7 @@ var (
 	USB2 = &usb.USB{
 		Index:     2,
 		Base:      USB2_BASE,
-		CCGR:      CCM_CCGR6,
-		CG:        CCGRx_CG0,
+		Gate:      &ccm.Gate{CCGR: CCM_CCGR6, CG: CCGRx_CG0},
 		Analog:    USB_ANALOG2_BASE,
 		PHY:       USBPHY2_BASE,
 		IRQ:       USB2_IRQ,
@@ -318,8 +332,7 @@ var (
 	USDHC1 = &usdhc.USDHC{
 		Index:    1,
 		Base:     USDHC1_BASE,
-		CCGR:     CCM_CCGR6,
-		CG:       CCGRx_CG1,
+		Gate:     &ccm.Gate{CCGR: CCM_CCGR6, CG: CCGRx_CG1},
 		SetClock: SetUSDHCClock,
 	}
 
@@ -327,8 +340,7 @@ var (
 	USDHC2 = &usdhc.USDHC{
 		Index:    2,
 		Base:     USDHC2_BASE,
-		CCGR:     CCM_CCGR6,
-		CG:       CCGRx_CG2,
+		Gate:     &ccm.Gate{CCGR: CCM_CCGR6, CG: CCGRx_CG2},
 		SetClock: SetUSDHCClock,
 	}
 
@@ -336,8 +348,7 @@ var (
 	WDOG1 = &wdog.WDOG{
 		Index: 1,
 		Base:  WDOG1_BASE,
-		CCGR:  CCM_CCGR3,
-		CG:    CCGRx_CG8,
+		Gate:  &ccm.Gate{CCGR: CCM_CCGR3, CG: CCGRx_CG8},
 		IRQ:   WDOG1_IRQ,
 	}
 
@@ -345,8 +356,7 @@ var (
 	WDOG2 = &wdog.WDOG{
 		Index: 2,
 		Base:  WDOG2_BASE,
-		CCGR:  CCM_CCGR5,
-		CG:    CCGRx_CG5,
+		Gate:  &ccm.Gate{CCGR: CCM_CCGR5, CG: CCGRx_CG5},
 		IRQ:   WDOG2_IRQ,
 	}
 
@@ -357,8 +367,7 @@ var (
 	WDOG3 = &wdog.WDOG{
 		Index: 3,
 		Base:  WDOG3_BASE,
-		CCGR:  CCM_CCGR6,
-		CG:    CCGRx_CG10,
+		Gate:  &ccm.Gate{CCGR: CCM_CCGR6, CG: CCGRx_CG10},
 		IRQ:   WDOG3_IRQ,
 	}
 )
@@ -386,6 +395,39 @@ func UniqueID() (uid [8]byte) {
 	return
 }
 
+// MAC returns the Ethernet MAC address, programmed in the OCOTP MAC_ADDR
+// fuses, for the argument ENET controller index (1 or 2).
+func MAC(index int) (mac net.HardwareAddr, err error) {
+	var mac0, mac1, mac2 uint32
+
+	if mac0, err = OCOTP.Read(4, 2); err != nil {
+		return
+	}
+
+	if mac1, err = OCOTP.Read(4, 3); err != nil {
+		return
+	}
+
+	if mac2, err = OCOTP.Read(4, 4); err != nil {
+		return
+	}
+
+	mac = make([]byte, 6)
+
+	switch index {
+	case 1:
+		binary.BigEndian.PutUint16(mac[0:2], uint16(mac1))
+		binary.BigEndian.PutUint32(mac[2:6], mac0)
+	case 2:
+		binary.BigEndian.PutUint32(mac[0:4], mac2)
+		binary.BigEndian.PutUint16(mac[4:6], uint16(mac1>>16))
+	default:
+		return nil, errors.New("invalid ENET index")
+	}
+
+	return
+}
+
 // Model returns the SoC model name.
 func Model() (model string) {
 	switch Family {
@@ -17,6 +17,7 @@ import (
 	"github.com/karlo195/tamago/dma"
 	"github.com/karlo195/tamago/internal/reg"
 	"github.com/karlo195/tamago/soc/nxp/bee"
+	"github.com/karlo195/tamago/soc/nxp/ccm"
 	"github.com/karlo195/tamago/soc/nxp/dcp"
 	"github.com/karlo195/tamago/soc/nxp/enet"
 	"github.com/karlo195/tamago/soc/nxp/usb"
@@ -103,8 +104,7 @@ func init() {
 		// Data Co-Processor
 		DCP = &dcp.DCP{
 			Base:            DCP_BASE,
-			CCGR:            CCM_CCGR0,
-			CG:              CCGRx_CG5,
+			Gate:            &ccm.Gate{CCGR: CCM_CCGR0, CG: CCGRx_CG5},
 			IRQ:             DCP_IRQ,
 			DeriveKeyMemory: dma.Default(),
 		}
@@ -118,8 +118,7 @@ func init() {
 		ENET1 = &enet.ENET{
 			Index:     1,
 			Base:      ENET1_BASE,
-			CCGR:      CCM_CCGR0,
-			CG:        CCGRx_CG6,
+			Gate:      &ccm.Gate{CCGR: CCM_CCGR0, CG: CCGRx_CG6},
 			Clock:     GetPeripheralClock,
 			IRQ:       ENET1_IRQ,
 			EnablePLL: EnableENETPLL,
@@ -129,8 +128,7 @@ func init() {
 		ENET2 = &enet.ENET{
 			Index:     2,
 			Base:      ENET2_BASE,
-			CCGR:      CCM_CCGR0,
-			CG:        CCGRx_CG6,
+			Gate:      &ccm.Gate{CCGR: CCM_CCGR0, CG: CCGRx_CG6},
 			Clock:     GetPeripheralClock,
 			IRQ:       ENET2_IRQ,
 			EnablePLL: EnableENETPLL,
@@ -0,0 +1,166 @@
+// NXP i.MX6UL power management unit (PMU) support
+// https://github.com/karlo195/tamago
+//
+// Copyright (c) The TamaGo Authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+package imx6ul
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/karlo195/tamago/internal/reg"
+)
+
+// Power Management Unit registers
+// (39.6 PMU Memory Map/Register Definition, IMX6ULLRM).
+const (
+	PMU_REG_1P1 = 0x020c8110
+	PMU_REG_3P0 = 0x020c8120
+	PMU_REG_2P5 = 0x020c8130
+
+	REG_BO_STATUS = 16
+	REG_BO_OFFSET = 4
+	REG_ENABLE_BO = 1
+
+	PMU_MISC2 = 0x020c8170
+
+	MISC2_REG2_ENABLE_BO = 21
+	MISC2_REG2_BO_STATUS = 19
+	MISC2_REG2_BO_OFFSET = 16
+	MISC2_REG0_ENABLE_BO = 5
+	MISC2_REG0_BO_STATUS = 3
+	MISC2_REG0_BO_OFFSET = 0
+)
+
+// Regulators with brownout detection
+const (
+	// 1.1V LDO (VDD_HIGH_IN to NVCC_PLL)
+	LDO_1P1 = iota
+	// 2.5V LDO (VDD_HIGH_IN to NVCC_2P5)
+	LDO_2P5
+	// 3.0V LDO (USB_OTG_VBUS to VDD_USB_CAP)
+	LDO_3P0
+	// ARM core digital regulator
+	LDO_ARM
+	// SoC digital regulator
+	LDO_SOC
+)
+
+// Brownout offset bounds, in mV below the regulator target output
+const (
+	BrownoutOffsetStep = 25
+	BrownoutOffsetMax  = 175
+)
+
+var brownout struct {
+	sync.Mutex
+	handler func(regulator int)
+}
+
+// brownoutRegister returns the control register and field positions for the
+// argument regulator brownout detector.
+func brownoutRegister(regulator int) (addr uint32, enable int, status int, offset int, err error) {
+	switch regulator {
+	case LDO_1P1:
+		return PMU_REG_1P1, REG_ENABLE_BO, REG_BO_STATUS, REG_BO_OFFSET, nil
+	case LDO_2P5:
+		return PMU_REG_2P5, REG_ENABLE_BO, REG_BO_STATUS, REG_BO_OFFSET, nil
+	case LDO_3P0:
+		return PMU_REG_3P0, REG_ENABLE_BO, REG_BO_STATUS, REG_BO_OFFSET, nil
+	case LDO_ARM:
+		return PMU_MISC2, MISC2_REG0_ENABLE_BO, MISC2_REG0_BO_STATUS, MISC2_REG0_BO_OFFSET, nil
+	case LDO_SOC:
+		return PMU_MISC2, MISC2_REG2_ENABLE_BO, MISC2_REG2_BO_STATUS, MISC2_REG2_BO_OFFSET, nil
+	}
+
+	return 0, 0, 0, 0, errors.New("invalid regulator")
+}
+
+// EnableBrownout enables the brownout detector of the argument regulator
+// (see LDO_* constants), the detection threshold is set at the argument offset
+// (in mV, with a 25mV granularity) below the regulator target output.
+//
+// Brownout events are signaled on interrupts PMU_BO_IRQ (LDO_1P1, LDO_2P5,
+// LDO_3P0) and PMU_BO_CORE_IRQ (LDO_ARM, LDO_SOC), which must be enabled on the
+// GIC by the application and serviced with HandleBrownout().
+func EnableBrownout(regulator int, mV int) (err error) {
+	addr, enable, _, offset, err := brownoutRegister(regulator)
+
+	if err != nil {
+		return
+	}
+
+	if mV < 0 || mV > BrownoutOffsetMax {
+		return errors.New("invalid brownout offset")
+	}
+
+	reg.SetN(addr, offset, 0b111, uint32(mV/BrownoutOffsetStep))
+	reg.Set(addr, enable)
+
+	return
+}
+
+// DisableBrownout disables the brownout detector of the argument regulator
+// (see LDO_* constants).
+func DisableBrownout(regulator int) {
+	if addr, enable, _, _, err := brownoutRegister(regulator); err == nil {
+		reg.Clear(addr, enable)
+	}
+}
+
+// Brownout returns whether the argument regulator (see LDO_* constants)
+// output is currently below its brownout detection threshold.
+func Brownout(regulator int) bool {
+	addr, enable, status, _, err := brownoutRegister(regulator)
+
+	if err != nil || !reg.IsSet(addr, enable) {
+		return false
+	}
+
+	return reg.IsSet(addr, status)
+}
+
+// SetBrownoutHandler sets the function invoked by HandleBrownout() for each
+// regulator signaling a brownout event, to allow flushing of application state
+// to persistent storage before power is lost.
+//
+// The handler is executed within the interrupt servicing routine, therefore it
+// should complete its operation in the shortest possible time.
+func SetBrownoutHandler(fn func(regulator int)) {
+	brownout.Lock()
+	defer brownout.Unlock()
+
+	brownout.handler = fn
+}
+
+// HandleBrownout services a brownout interrupt (PMU_BO_IRQ, PMU_BO_CORE_IRQ)
+// by invoking the handler set with SetBrownoutHandler() for all regulators
+// signaling a brownout event, the number of which is returned.
+//
+// As brownout status is level sensitive, detection is disabled on signaling
+// regulators to prevent further interrupts, EnableBrownout() must be called
+// again to re-arm detection once power is restored.
+func HandleBrownout() (n int) {
+	brownout.Lock()
+	fn := brownout.handler
+	brownout.Unlock()
+
+	for regulator := LDO_1P1; regulator <= LDO_SOC; regulator++ {
+		if !Brownout(regulator) {
+			continue
+		}
+
+		DisableBrownout(regulator)
+		n += 1
+
+		if fn != nil {
+			fn(regulator)
+		}
+	}
+
+	return
+}
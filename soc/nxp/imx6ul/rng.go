@@ -16,6 +16,7 @@ import (
 	"github.com/karlo195/tamago/dma"
 	"github.com/karlo195/tamago/internal/rng"
 	"github.com/karlo195/tamago/soc/nxp/caam"
+	"github.com/karlo195/tamago/soc/nxp/ccm"
 	"github.com/karlo195/tamago/soc/nxp/rngb"
 )
 
@@ -40,8 +41,7 @@ func initRNG() {
 		// Cryptographic Acceleration and Assurance Module
 		CAAM = &caam.CAAM{
 			Base:            CAAM_BASE,
-			CCGR:            CCM_CCGR0,
-			CG:              CCGRx_CG5,
+			Gate:            &ccm.Gate{CCGR: CCM_CCGR0, CG: CCGRx_CG5},
 			DeriveKeyMemory: dma.Default(),
 		}
 		CAAM.Init()
@@ -14,6 +14,7 @@
 package iomuxc
 
 import (
+	"github.com/karlo195/tamago/bits"
 	"github.com/karlo195/tamago/internal/reg"
 )
 
@@ -96,3 +97,90 @@ func (pad *Pad) Select(input uint32) {
 
 	reg.Write(pad.Daisy, input)
 }
+
+// PadControl represents the pad control register (SW_PAD_CTL) settings.
+type PadControl struct {
+	// Hysteresis enables the Schmitt trigger input.
+	Hysteresis bool
+	// PullKeeper enables the pull/keeper function.
+	PullKeeper bool
+	// Pull selects pull (true) or keeper (false) function.
+	Pull bool
+	// PullSelect is the pull up/down configuration (SW_PAD_CTL_PUS_*).
+	PullSelect uint32
+	// OpenDrain enables open drain output.
+	OpenDrain bool
+	// Speed is the pad speed (SW_PAD_CTL_SPEED_*).
+	Speed uint32
+	// DriveStrength is the pad drive strength (SW_PAD_CTL_DSE_*).
+	DriveStrength uint32
+	// FastSlewRate enables the fast slew rate.
+	FastSlewRate bool
+}
+
+// Value returns the pad control register value.
+func (c PadControl) Value() (ctl uint32) {
+	bits.SetTo(&ctl, SW_PAD_CTL_HYS, c.Hysteresis)
+	bits.SetN(&ctl, SW_PAD_CTL_PUS, 0b11, c.PullSelect)
+	bits.SetTo(&ctl, SW_PAD_CTL_PUE, c.Pull)
+	bits.SetTo(&ctl, SW_PAD_CTL_PKE, c.PullKeeper)
+	bits.SetTo(&ctl, SW_PAD_CTL_ODE, c.OpenDrain)
+	bits.SetN(&ctl, SW_PAD_CTL_SPEED, 0b11, c.Speed)
+	bits.SetN(&ctl, SW_PAD_CTL_DSE, 0b111, c.DriveStrength)
+	bits.SetTo(&ctl, SW_PAD_CTL_SRE, c.FastSlewRate)
+
+	return
+}
+
+// PadConfig represents a declarative pad configuration, allowing board
+// packages to describe pin multiplexing in a single table (see Configure).
+type PadConfig struct {
+	// Mux register (e.g. IOMUXC_SW_MUX_CTL_PAD_*)
+	Mux uint32
+	// Pad register (e.g. IOMUXC_SW_PAD_CTL_PAD_*), a zero value leaves
+	// pad control settings unchanged.
+	Pad uint32
+	// Daisy register (e.g. IOMUXC_*_SELECT_INPUT), a zero value
+	// indicates no daisy chain selection.
+	Daisy uint32
+
+	// Mode is the iomux mode (ALT0-ALT8).
+	Mode uint32
+	// SoftwareInput forces the input path of the pad (SION).
+	SoftwareInput bool
+	// Ctl is the pad control register value (see PadControl.Value()).
+	Ctl uint32
+	// Input is the daisy chain input selection.
+	Input uint32
+}
+
+// Configure applies the pad configuration, returning the configured pad
+// instance.
+func (c *PadConfig) Configure() (p *Pad) {
+	p = &Pad{
+		Mux:   c.Mux,
+		Pad:   c.Pad,
+		Daisy: c.Daisy,
+	}
+
+	p.Mode(c.Mode)
+	p.SoftwareInput(c.SoftwareInput)
+
+	if p.Pad != 0 {
+		p.Ctl(c.Ctl)
+	}
+
+	p.Select(c.Input)
+
+	return
+}
+
+// Configure applies all pad configurations of the argument table, in order,
+// returning the configured pad instances.
+func Configure(table []PadConfig) (pads []*Pad) {
+	for i := range table {
+		pads = append(pads, table[i].Configure())
+	}
+
+	return
+}
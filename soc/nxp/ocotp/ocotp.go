@@ -29,6 +29,7 @@ import (
 	"time"
 
 	"github.com/karlo195/tamago/internal/reg"
+	"github.com/karlo195/tamago/soc/nxp/ccm"
 )
 
 // OCOTP registers
@@ -64,10 +65,8 @@ type OCOTP struct {
 	BankBase uint32
 	// Banks size
 	Banks int
-	// Clock gate register
-	CCGR uint32
 	// Clock gate
-	CG int
+	Gate *ccm.Gate
 	// Timeout for OCOTP controller operations
 	Timeout time.Duration
 
@@ -82,7 +81,7 @@ func (hw *OCOTP) Init() {
 	hw.Lock()
 	defer hw.Unlock()
 
-	if hw.Base == 0 || hw.BankBase == 0 || hw.CCGR == 0 {
+	if hw.Base == 0 || hw.BankBase == 0 || hw.Gate == nil {
 		panic("invalid OCOTP instance")
 	}
 
@@ -95,7 +94,7 @@ func (hw *OCOTP) Init() {
 	hw.data = hw.Base + OCOTP_DATA
 
 	// enable clock
-	reg.SetN(hw.CCGR, hw.CG, 0b11, 0b11)
+	hw.Gate.Enable()
 }
 
 // Read returns the value in the argument bank and word location.
@@ -0,0 +1,95 @@
+// NXP Secure Non-Volatile Storage (SNVS) support
+// https://github.com/karlo195/tamago
+//
+// Copyright (c) The TamaGo Authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+package snvs
+
+import (
+	"errors"
+
+	"github.com/karlo195/tamago/internal/reg"
+)
+
+// SNVS Secure Monotonic Counter registers
+const (
+	LPSR_MCR = 2
+
+	SNVS_LPSMCMR   = 0x5c
+	LPSMCMR_MC_MSB = 0
+
+	SNVS_LPSMCLR = 0x60
+)
+
+// enableMonotonicCounter sets the monotonic counter as valid, which fails
+// when a security violation occurred or the counter rolled over.
+func (hw *SNVS) enableMonotonicCounter() (err error) {
+	lpcr := hw.Base + SNVS_LPCR
+
+	if reg.IsSet(lpcr, LPCR_MC_ENV) {
+		return
+	}
+
+	if reg.IsSet(hw.Base+SNVS_LPSR, LPSR_MCR) {
+		return errors.New("monotonic counter rolled over")
+	}
+
+	reg.Set(lpcr, LPCR_MC_ENV)
+
+	if err = hw.waitLPCR(LPCR_MC_ENV, 1); err != nil {
+		return errors.New("monotonic counter not available")
+	}
+
+	return
+}
+
+// MonotonicCounter returns the 48-bit Secure Monotonic Counter value.
+//
+// The counter is part of the SNVS low power domain and retains its value,
+// while a coin cell or always-on supply is present, across system power
+// cycles. It can only be incremented (see [SNVS.IncrementMonotonicCounter]).
+func (hw *SNVS) MonotonicCounter() (cnt uint64, err error) {
+	hw.Lock()
+	defer hw.Unlock()
+
+	if err = hw.enableMonotonicCounter(); err != nil {
+		return
+	}
+
+	var last uint64
+
+	for i := 0; i < 3; i++ {
+		msb := uint64(reg.Get(hw.Base+SNVS_LPSMCMR, LPSMCMR_MC_MSB, 0xffff))
+		lsb := uint64(reg.Read(hw.Base + SNVS_LPSMCLR))
+
+		if cnt = msb<<32 | lsb; cnt == last {
+			break
+		}
+
+		last = cnt
+	}
+
+	return
+}
+
+// IncrementMonotonicCounter increments the Secure Monotonic Counter by one.
+//
+// The counter is invalidated on security violations (see [SNVS.SetPolicy]),
+// after which it can no longer be incremented until the next SNVS low power
+// domain reset.
+func (hw *SNVS) IncrementMonotonicCounter() (err error) {
+	hw.Lock()
+	defer hw.Unlock()
+
+	if err = hw.enableMonotonicCounter(); err != nil {
+		return
+	}
+
+	// any write increments the counter
+	reg.Write(hw.Base+SNVS_LPSMCLR, 0)
+
+	return
+}
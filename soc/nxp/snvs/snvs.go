@@ -19,6 +19,7 @@ package snvs
 import (
 	"github.com/karlo195/tamago/bits"
 	"github.com/karlo195/tamago/internal/reg"
+	"github.com/karlo195/tamago/soc/nxp/ccm"
 
 	"sync"
 	"time"
@@ -98,10 +99,8 @@ type SNVS struct {
 
 	// Base register
 	Base uint32
-	// Clock gate register
-	CCGR uint32
 	// Clock gate
-	CG int
+	Gate *ccm.Gate
 	// auxiliary logic base register
 	DryIce uint32
 
@@ -173,12 +172,12 @@ func (hw *SNVS) initDryIce(calibrationData uint32) {
 // individually for each part and is required for correct initialization of the
 // DryIce auxiliary logic (when available).
 func (hw *SNVS) Init(calibrationData uint32) {
-	if hw.Base == 0 || hw.CCGR == 0 {
+	if hw.Base == 0 || hw.Gate == nil {
 		panic("invalid SNVS instance")
 	}
 
 	// enable clock
-	reg.SetN(hw.CCGR, hw.CG, 0b11, 0b11)
+	hw.Gate.Enable()
 
 	hw.hpcomr = hw.Base + SNVS_HPCOMR
 	hw.hpsvcr = hw.Base + SNVS_HPSVCR
@@ -0,0 +1,179 @@
+// NXP Secure Non-Volatile Storage (SNVS) support
+// https://github.com/karlo195/tamago
+//
+// Copyright (c) The TamaGo Authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+package snvs
+
+import (
+	"errors"
+	"time"
+
+	"github.com/karlo195/tamago/internal/reg"
+)
+
+// SNVS Secure Real Time Counter registers
+const (
+	SNVS_LPCR     = 0x38
+	LPCR_LPWUI_EN = 3
+	LPCR_MC_ENV   = 2
+	LPCR_LPTA_EN  = 1
+	LPCR_SRTC_ENV = 0
+
+	LPSR_LPTA = 0
+
+	SNVS_LPSRTCMR = 0x50
+	SNVS_LPSRTCLR = 0x54
+	SNVS_LPTAR    = 0x58
+)
+
+// SRTC counter frequency, the 47-bit counter holds seconds in its upper 32
+// bits
+const (
+	srtcFreq  = 32768
+	srtcShift = 15
+)
+
+// srtcTimeout is the maximum time waited for SRTC control changes to take
+// effect, which are synchronized to the 32 kHz clock.
+const srtcTimeout = 10 * time.Millisecond
+
+func (hw *SNVS) waitLPCR(pos int, val uint32) (err error) {
+	lpcr := hw.Base + SNVS_LPCR
+
+	if !reg.WaitFor(srtcTimeout, lpcr, pos, 1, val) {
+		return errors.New("SRTC control timeout")
+	}
+
+	return
+}
+
+// srtc returns the 47-bit Secure Real Time Counter value, read until two
+// consecutive reads match as the counter is not latched.
+func (hw *SNVS) srtc() (cnt uint64) {
+	var last uint64
+
+	for i := 0; i < 3; i++ {
+		msb := uint64(reg.Read(hw.Base+SNVS_LPSRTCMR) & 0x7fff)
+		lsb := uint64(reg.Read(hw.Base + SNVS_LPSRTCLR))
+
+		if cnt = msb<<32 | lsb; cnt == last {
+			break
+		}
+
+		last = cnt
+	}
+
+	return
+}
+
+// Now returns the Secure Real Time Counter (SRTC) as time elapsed since the
+// Unix epoch, see [SNVS.SetTime].
+//
+// The SRTC is part of the SNVS low power domain and retains its value, while
+// a coin cell or always-on supply is present, across system power cycles.
+func (hw *SNVS) Now() time.Time {
+	hw.Lock()
+	defer hw.Unlock()
+
+	cnt := hw.srtc()
+	sec := int64(cnt >> srtcShift)
+	nsec := int64(cnt&(srtcFreq-1)) * int64(time.Second) / srtcFreq
+
+	return time.Unix(sec, nsec)
+}
+
+// SetTime sets, and enables, the Secure Real Time Counter (SRTC).
+func (hw *SNVS) SetTime(t time.Time) (err error) {
+	hw.Lock()
+	defer hw.Unlock()
+
+	if t.Unix() < 0 || t.Unix() > 1<<32-1 {
+		return errors.New("invalid time")
+	}
+
+	cnt := uint64(t.Unix())<<srtcShift | uint64(t.Nanosecond())*srtcFreq/uint64(time.Second)
+	lpcr := hw.Base + SNVS_LPCR
+
+	reg.Clear(lpcr, LPCR_SRTC_ENV)
+
+	if err = hw.waitLPCR(LPCR_SRTC_ENV, 0); err != nil {
+		return
+	}
+
+	reg.Write(hw.Base+SNVS_LPSRTCMR, uint32(cnt>>32)&0x7fff)
+	reg.Write(hw.Base+SNVS_LPSRTCLR, uint32(cnt))
+
+	reg.Set(lpcr, LPCR_SRTC_ENV)
+
+	return hw.waitLPCR(LPCR_SRTC_ENV, 1)
+}
+
+// SetAlarm programs the SRTC time alarm to fire at the argument time (with
+// one second resolution), the SRTC must be enabled (see [SNVS.SetTime]).
+//
+// When wakeup is true the alarm also asserts the SNVS wake-up interrupt,
+// allowing to resume the system from low power modes. The alarm state can be
+// polled with [SNVS.AlarmFired].
+func (hw *SNVS) SetAlarm(t time.Time, wakeup bool) (err error) {
+	hw.Lock()
+	defer hw.Unlock()
+
+	lpcr := hw.Base + SNVS_LPCR
+
+	if !reg.IsSet(lpcr, LPCR_SRTC_ENV) {
+		return errors.New("SRTC not enabled")
+	}
+
+	if sec := t.Unix(); sec <= int64(hw.srtc()>>srtcShift) || sec > 1<<32-1 {
+		return errors.New("invalid alarm time")
+	}
+
+	// disable the alarm while it is being updated
+	reg.Clear(lpcr, LPCR_LPTA_EN)
+
+	if err = hw.waitLPCR(LPCR_LPTA_EN, 0); err != nil {
+		return
+	}
+
+	reg.Write(hw.Base+SNVS_LPTAR, uint32(t.Unix()))
+
+	// clear any pending flag
+	reg.Write(hw.Base+SNVS_LPSR, 1<<LPSR_LPTA)
+
+	reg.SetTo(lpcr, LPCR_LPWUI_EN, wakeup)
+	reg.Set(lpcr, LPCR_LPTA_EN)
+
+	return hw.waitLPCR(LPCR_LPTA_EN, 1)
+}
+
+// ClearAlarm disables the SRTC time alarm.
+func (hw *SNVS) ClearAlarm() {
+	hw.Lock()
+	defer hw.Unlock()
+
+	lpcr := hw.Base + SNVS_LPCR
+
+	reg.Clear(lpcr, LPCR_LPTA_EN)
+	reg.Clear(lpcr, LPCR_LPWUI_EN)
+	reg.Write(hw.Base+SNVS_LPSR, 1<<LPSR_LPTA)
+}
+
+// AlarmFired returns, and clears, the SRTC time alarm status.
+func (hw *SNVS) AlarmFired() bool {
+	hw.Lock()
+	defer hw.Unlock()
+
+	lpsr := hw.Base + SNVS_LPSR
+
+	if !reg.IsSet(lpsr, LPSR_LPTA) {
+		return false
+	}
+
+	reg.Write(lpsr, 1<<LPSR_LPTA)
+
+	return true
+}
@@ -18,6 +18,7 @@ package uart
 import (
 	"github.com/karlo195/tamago/bits"
 	"github.com/karlo195/tamago/internal/reg"
+	"github.com/karlo195/tamago/soc/nxp/ccm"
 )
 
 // UART registers
@@ -117,10 +118,8 @@ type UART struct {
 	Index int
 	// Base register
 	Base uint32
-	// Clock gate register
-	CCGR uint32
 	// Clock gate
-	CG int
+	Gate *ccm.Gate
 	// Clock retrieval function
 	Clock func() uint32
 	// port speed
@@ -149,7 +148,7 @@ type UART struct {
 // Init initializes and enables the UART for RS-232 mode,
 // p3605, 55.13.1 Programming the UART in RS-232 mode, IMX6ULLRM.
 func (hw *UART) Init() {
-	if hw.Base == 0 || hw.CCGR == 0 || hw.Clock == nil {
+	if hw.Base == 0 || hw.Gate == nil || hw.Clock == nil {
 		panic("invalid UART controller instance")
 	}
 
@@ -172,7 +171,7 @@ func (hw *UART) Init() {
 	hw.uts = hw.Base + UARTx_UTS
 
 	// enable clock
-	reg.SetN(hw.CCGR, hw.CG, 0b11, 0b11)
+	hw.Gate.Enable()
 
 	hw.setup()
 }
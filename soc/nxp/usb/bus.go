@@ -21,6 +21,7 @@ import (
 	"sync"
 
 	"github.com/karlo195/tamago/internal/reg"
+	"github.com/karlo195/tamago/soc/nxp/ccm"
 )
 
 // USB registers
@@ -133,10 +134,8 @@ type USB struct {
 	Index int
 	// Base register
 	Base uint32
-	// Clock gate register
-	CCGR uint32
 	// Clock gate
-	CG int
+	Gate *ccm.Gate
 	// Analog base register
 	Analog uint32
 	// PHY base register
@@ -186,7 +185,7 @@ func (hw *USB) Init() {
 	hw.Lock()
 	defer hw.Unlock()
 
-	if hw.Base == 0 || hw.CCGR == 0 || hw.Analog == 0 || hw.PHY == 0 || hw.EnablePLL == nil {
+	if hw.Base == 0 || hw.Gate == nil || hw.Analog == 0 || hw.PHY == 0 || hw.EnablePLL == nil {
 		panic("invalid USB controller instance")
 	}
 
@@ -209,7 +208,7 @@ func (hw *USB) Init() {
 	hw.epctrl = hw.Base + USB_UOGx_ENDPTCTRL
 
 	// enable clock
-	reg.SetN(hw.CCGR, hw.CG, 0b11, 0b11)
+	hw.Gate.Enable()
 	hw.EnablePLL(hw.Index)
 
 	// soft reset USB PHY
@@ -71,6 +71,8 @@ var cmds = map[uint32]cmdParams{
 	//  SD: CMD3 - SEND_RELATIVE_ADDR - get relative card address (RCA)
 	// MMC: CMD3 -  SET_RELATIVE_ADDR - set relative card address (RCA
 	3: {READ, RSP_48, true, true},
+	// SDIO: CMD5 - IO_SEND_OP_COND - read I/O operating conditions
+	5: {READ, RSP_48, false, false},
 	// CMD6 - SWITCH - switch mode of operation
 	6: {READ, RSP_48_CHECK_BUSY, true, true},
 	// CMD7 - SELECT/DESELECT CARD - enter transfer state
@@ -98,6 +100,10 @@ var cmds = map[uint32]cmdParams{
 	25: {WRITE, RSP_48, true, true},
 	// SD: ACMD41 - SD_SEND_OP_COND - read capacity information
 	41: {READ, RSP_48, false, false},
+	// SDIO: CMD52 - IO_RW_DIRECT - single register access
+	52: {READ, RSP_48, true, true},
+	// SDIO: CMD53 - IO_RW_EXTENDED - multiple register access
+	53: {READ, RSP_48, true, true},
 	// SD: CMD55 - APP_CMD - next command is application specific
 	55: {READ, RSP_48, true, true},
 }
@@ -111,6 +117,12 @@ func (hw *USDHC) cmd(index uint32, arg uint32, blocks uint32, timeout time.Durat
 		return fmt.Errorf("CMD%d unsupported", index)
 	}
 
+	return hw.cmdParams(index, params, arg, blocks, timeout)
+}
+
+// cmdParams sends an SD / MMC command with explicit parameters, required for
+// commands supporting both transfer directions (e.g. SDIO CMD53).
+func (hw *USDHC) cmdParams(index uint32, params cmdParams, arg uint32, blocks uint32, timeout time.Duration) (err error) {
 	if timeout == 0 {
 		timeout = DEFAULT_CMD_TIMEOUT
 	}
@@ -134,7 +146,7 @@ func (hw *USDHC) cmd(index uint32, arg uint32, blocks uint32, timeout time.Durat
 	// clear interrupts status
 	reg.Write(hw.int_status, 0xffffffff)
 
-	if params.dtd == WRITE && reg.Get(hw.pres_state, PRES_STATE_WPSPL, 1) == 0 {
+	if params.dtd == WRITE && !hw.card.SDIO && reg.Get(hw.pres_state, PRES_STATE_WPSPL, 1) == 0 {
 		// The uSDHC merely reports on WP, it doesn't really act on it
 		// despite IMX6ULLRM suggesting otherwise (e.g. p4017).
 		return fmt.Errorf("card is write protected")
@@ -185,8 +197,9 @@ func (hw *USDHC) cmd(index uint32, arg uint32, blocks uint32, timeout time.Durat
 		bits.Set(&xfr, CMD_XFR_TYP_DPSEL)
 		// enable DMA
 		bits.Set(&mix, MIX_CTRL_DMAEN)
-		// enable automatic CMD12 to stop transactions
-		bits.Set(&mix, MIX_CTRL_AC12EN)
+		// enable automatic CMD12 to stop transactions, not supported
+		// by SDIO
+		bits.SetTo(&mix, MIX_CTRL_AC12EN, !hw.card.SDIO)
 		// multiple blocks
 		bits.SetTo(&mix, MIX_CTRL_MSBSEL, blocks > 1)
 		// block count
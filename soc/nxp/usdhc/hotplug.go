@@ -0,0 +1,193 @@
+// NXP Ultra Secured Digital Host Controller (uSDHC) driver
+// https://github.com/karlo195/tamago
+//
+// IP: https://www.mobiveil.com/esdhc/
+//
+// Copyright (c) The TamaGo Authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+package usdhc
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/karlo195/tamago/internal/reg"
+)
+
+// ErrCardRemoved is returned by card transfers issued after card removal and
+// before a successful [USDHC.Detect].
+var ErrCardRemoved = errors.New("card removed")
+
+// DefaultDebounce is the default card detection debounce time.
+const DefaultDebounce = 100 * time.Millisecond
+
+// Present returns whether a card is inserted, either through the board
+// specific CardDetect function or the controller card detection state
+// (Present State register, uSDHCx_PRES_STATE, IMX6ULLRM).
+func (hw *USDHC) Present() bool {
+	if hw.CardDetect != nil {
+		return hw.CardDetect()
+	}
+
+	if hw.pres_state == 0 {
+		return false
+	}
+
+	return reg.Get(hw.pres_state, PRES_STATE_CINST, 1) == 1
+}
+
+// Invalidate discards detected card information, all further card transfers
+// fail with [ErrCardRemoved] until the card is detected again with
+// [USDHC.Detect].
+//
+// Outstanding transfers are completed (or timed out) before invalidation.
+func (hw *USDHC) Invalidate() {
+	hw.Lock()
+	defer hw.Unlock()
+
+	hw.card = CardInfo{}
+	hw.removed = true
+}
+
+// Hotplug represents a card insertion and removal monitor.
+type Hotplug struct {
+	sync.Mutex
+
+	// Controller is the monitored uSDHC controller.
+	Controller *USDHC
+
+	// Insert is invoked after card insertion and successful detection
+	// (e.g. to remount file systems).
+	Insert func(info CardInfo)
+	// Remove is invoked after card removal and controller invalidation
+	// (e.g. to discard block device users).
+	Remove func()
+	// Error is invoked on card detection errors after insertion.
+	Error func(err error)
+
+	// Debounce is the time a card detection state change must remain
+	// stable before being reported (default: DefaultDebounce).
+	Debounce time.Duration
+
+	present bool
+	exit    chan struct{}
+	notify  chan struct{}
+}
+
+// Init initializes the monitor with the current card detection state, without
+// invoking any callback.
+func (h *Hotplug) Init() {
+	h.Lock()
+	defer h.Unlock()
+
+	if h.Controller == nil {
+		panic("invalid uSDHC hotplug instance")
+	}
+
+	if h.Debounce == 0 {
+		h.Debounce = DefaultDebounce
+	}
+
+	h.present = h.Controller.Present()
+	h.notify = make(chan struct{}, 1)
+}
+
+// Poll checks the card detection state and, on change, performs card
+// detection or invalidation and invokes the relevant callback. It can be
+// invoked periodically (see [Hotplug.Start]), as it blocks for debouncing it
+// must not be invoked in interrupt context (see [Hotplug.Notify]).
+func (h *Hotplug) Poll() {
+	h.Lock()
+	defer h.Unlock()
+
+	hw := h.Controller
+	present := hw.Present()
+
+	if present == h.present {
+		return
+	}
+
+	// debounce
+	time.Sleep(h.Debounce)
+
+	if hw.Present() != present {
+		return
+	}
+
+	h.present = present
+
+	if !present {
+		hw.Invalidate()
+
+		if h.Remove != nil {
+			h.Remove()
+		}
+
+		return
+	}
+
+	if err := hw.Detect(); err != nil {
+		if h.Error != nil {
+			h.Error(err)
+		}
+
+		return
+	}
+
+	if h.Insert != nil {
+		h.Insert(hw.Info())
+	}
+}
+
+// Notify requests a card detection state check to the goroutine started by
+// [Hotplug.Start], without blocking, it can be invoked in interrupt context
+// (e.g. upon card detect GPIO interrupts).
+func (h *Hotplug) Notify() {
+	select {
+	case h.notify <- struct{}{}:
+	default:
+	}
+}
+
+// Start polls the card detection state, at the argument interval or upon
+// [Hotplug.Notify], in a separate goroutine until [Hotplug.Stop] is invoked.
+func (h *Hotplug) Start(interval time.Duration) {
+	h.Lock()
+
+	if h.exit != nil {
+		h.Unlock()
+		return
+	}
+
+	exit := make(chan struct{})
+	h.exit = exit
+	h.Unlock()
+
+	go func() {
+		for {
+			select {
+			case <-exit:
+				return
+			case <-h.notify:
+				h.Poll()
+			case <-time.After(interval):
+				h.Poll()
+			}
+		}
+	}()
+}
+
+// Stop stops card detection state polling.
+func (h *Hotplug) Stop() {
+	h.Lock()
+	defer h.Unlock()
+
+	if h.exit != nil {
+		close(h.exit)
+		h.exit = nil
+	}
+}
@@ -56,6 +56,7 @@ const (
 	EXT_CSD_HS_TIMING        = 185
 	EXT_CSD_BUS_WIDTH        = 183
 	EXT_CSD_PARTITION_CONFIG = 179
+	EXT_CSD_RPMB_SIZE_MULT   = 168
 
 	// p224, PARTITION_CONFIG, JESD84-B51
 	PARTITION_ACCESS_NONE = 0x0
@@ -184,6 +185,9 @@ func (hw *USDHC) detectCapabilitiesMMC(c_size_mult uint32, c_size uint32, read_b
 		hw.card.Blocks = int((c_size + 1) * (2 << (c_size_mult + 2)))
 	}
 
+	// p226, 7.4.78 RPMB_SIZE_MULT [168], JESD84-B51
+	hw.card.RPMBSize = int(extCSD[EXT_CSD_RPMB_SIZE_MULT]) * 128 * 1024
+
 	// p220, Table 137 — Device types, JESD84-B51
 	deviceType := extCSD[EXT_CSD_DEVICE_TYPE]
 
@@ -0,0 +1,279 @@
+// NXP Ultra Secured Digital Host Controller (uSDHC) driver
+// https://github.com/karlo195/tamago
+//
+// IP: https://www.mobiveil.com/esdhc/
+//
+// Copyright (c) The TamaGo Authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+package usdhc
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/karlo195/tamago/bits"
+)
+
+// SDIO constants (SDIO Simplified Specification Version 3.00)
+const (
+	// IO_SEND_OP_COND Response (R4)
+	SDIO_OCR_READY     = 31
+	SDIO_OCR_FUNCTIONS = 28
+	SDIO_OCR_MEMORY    = 27
+	SDIO_OCR_VDD_MASK  = 0xff8000
+
+	// IO_RW_DIRECT Command (CMD52) and IO_RW_EXTENDED Command (CMD53)
+	SDIO_ARG_RW     = 31
+	SDIO_ARG_FN     = 28
+	SDIO_ARG_RAW    = 27
+	SDIO_ARG_BLOCK  = 27
+	SDIO_ARG_INCR   = 26
+	SDIO_ARG_ADDR   = 9
+	SDIO_ARG_DATA   = 0
+	SDIO_ARG_COUNT  = 0
+	SDIO_MAX_ADDR   = 0x1ffff
+	SDIO_MAX_BYTES  = 512
+	SDIO_MAX_BLOCKS = 511
+
+	// IO_RW_DIRECT Response (R5): COM_CRC_ERROR, ILLEGAL_COMMAND, ERROR,
+	// FUNCTION_NUMBER, OUT_OF_RANGE
+	SDIO_R5_FLAGS  = 8
+	SDIO_R5_ERRORS = 0xcb
+
+	// Card Common Control Registers (CCCR)
+	CCCR_BUS_IF      = 0x07
+	CCCR_BUS_WIDTH_4 = 0b10
+	CCCR_CAPABILITY  = 0x08
+	CCCR_CAP_4BLS    = 7
+	CCCR_CAP_LSC     = 6
+	CCCR_HIGH_SPEED  = 0x13
+	CCCR_HS_EHS      = 1
+	CCCR_HS_SHS      = 0
+
+	// Default Speed, 4-bit, throughput
+	SDIO_DS_MBPS = 12
+)
+
+// Card Initialization, SDIO Simplified Specification Version 3.00
+func (hw *USDHC) voltageValidationSDIO() bool {
+	// CMD5 - IO_SEND_OP_COND - read I/O operating conditions
+	if err := hw.cmd(5, 0, 0, 0); err != nil {
+		return false
+	}
+
+	rsp := hw.rsp(0)
+
+	if bits.Get(&rsp, SDIO_OCR_FUNCTIONS, 0b111) == 0 {
+		return false
+	}
+
+	arg := rsp & SDIO_OCR_VDD_MASK
+	start := time.Now()
+
+	for time.Since(start) <= SD_DETECT_TIMEOUT {
+		// CMD5 - IO_SEND_OP_COND - set I/O operating conditions
+		if err := hw.cmd(5, arg, 0, 0); err != nil {
+			break
+		}
+
+		rsp = hw.rsp(0)
+
+		if bits.Get(&rsp, SDIO_OCR_READY, 1) == 0 {
+			continue
+		}
+
+		hw.card.SDIO = true
+
+		break
+	}
+
+	return hw.card.SDIO
+}
+
+// initSDIO initializes an I/O only SDIO card, the memory portion of combo
+// cards is not initialized.
+func (hw *USDHC) initSDIO() (err error) {
+	// CMD3 - SEND_RELATIVE_ADDR - get relative card address (RCA)
+	if err = hw.cmd(3, 0, 0, 0); err != nil {
+		return
+	}
+
+	// set relative card address
+	hw.rca = hw.rsp(0) & (0xffff << RCA_ADDR)
+
+	// CMD7 - SELECT/DESELECT CARD - enter command state
+	if err = hw.cmd(7, hw.rca, 0, 0); err != nil {
+		return
+	}
+
+	hw.setFreq(-1, -1)
+	hw.setFreq(DVS_OP, SDCLKFS_OP)
+
+	hw.card.Rate = SDIO_DS_MBPS
+
+	caps, err := hw.readDirect(0, CCCR_CAPABILITY)
+
+	if err != nil {
+		return
+	}
+
+	switch hw.width {
+	case 1:
+	case 4:
+		if caps&(1<<CCCR_CAP_LSC) != 0 && caps&(1<<CCCR_CAP_4BLS) == 0 {
+			return errors.New("card does not support 4-bit bus width")
+		}
+
+		if err = hw.writeDirect(0, CCCR_BUS_IF, CCCR_BUS_WIDTH_4); err != nil {
+			return
+		}
+	default:
+		return errors.New("unsupported SDIO bus width")
+	}
+
+	hs, err := hw.readDirect(0, CCCR_HIGH_SPEED)
+
+	if err != nil || hs&(1<<CCCR_HS_SHS) == 0 {
+		return
+	}
+
+	if err = hw.writeDirect(0, CCCR_HIGH_SPEED, hs|1<<CCCR_HS_EHS); err != nil {
+		return
+	}
+
+	hw.setFreq(-1, -1)
+	hw.SetClock(hw.Index, ROOTCLK_HS_SDR, 0)
+	hw.setFreq(DVS_HS, SDCLKFS_HS_SDR)
+
+	hw.card.Rate = HS_MBPS
+	hw.card.HS = true
+
+	return
+}
+
+func (hw *USDHC) rw52(fn int, addr uint32, write bool, val uint8) (res uint8, err error) {
+	var arg uint32
+
+	if fn < 0 || fn > 7 || addr > SDIO_MAX_ADDR {
+		return 0, errors.New("invalid SDIO function or address")
+	}
+
+	bits.SetTo(&arg, SDIO_ARG_RW, write)
+	bits.SetN(&arg, SDIO_ARG_FN, 0b111, uint32(fn))
+	bits.SetTo(&arg, SDIO_ARG_RAW, write)
+	bits.SetN(&arg, SDIO_ARG_ADDR, SDIO_MAX_ADDR, addr)
+	bits.SetN(&arg, SDIO_ARG_DATA, 0xff, uint32(val))
+
+	// CMD52 - IO_RW_DIRECT - single register access
+	if err = hw.cmd(52, arg, 0, 0); err != nil {
+		return
+	}
+
+	rsp := hw.rsp(0)
+
+	if flags := bits.Get(&rsp, SDIO_R5_FLAGS, 0xff); flags&SDIO_R5_ERRORS != 0 {
+		return 0, fmt.Errorf("CMD52 error flags %#x", flags)
+	}
+
+	return uint8(rsp), nil
+}
+
+func (hw *USDHC) readDirect(fn int, addr uint32) (val uint8, err error) {
+	return hw.rw52(fn, addr, false, 0)
+}
+
+func (hw *USDHC) writeDirect(fn int, addr uint32, val uint8) (err error) {
+	_, err = hw.rw52(fn, addr, true, val)
+	return
+}
+
+// ReadDirect reads a single SDIO function register (CMD52).
+func (hw *USDHC) ReadDirect(fn int, addr uint32) (val uint8, err error) {
+	hw.Lock()
+	defer hw.Unlock()
+
+	if !hw.card.SDIO {
+		return 0, fmt.Errorf("no SDIO card detected on uSDHC%d", hw.Index)
+	}
+
+	return hw.readDirect(fn, addr)
+}
+
+// WriteDirect writes a single SDIO function register (CMD52).
+func (hw *USDHC) WriteDirect(fn int, addr uint32, val uint8) (err error) {
+	hw.Lock()
+	defer hw.Unlock()
+
+	if !hw.card.SDIO {
+		return fmt.Errorf("no SDIO card detected on uSDHC%d", hw.Index)
+	}
+
+	return hw.writeDirect(fn, addr, val)
+}
+
+// rw53 performs an SDIO extended transfer, in block mode when the block size
+// is not zero and in byte mode otherwise.
+func (hw *USDHC) rw53(dtd uint32, fn int, addr uint32, incr bool, blockSize int, buf []byte) (err error) {
+	var arg uint32
+	var count int
+
+	hw.Lock()
+	defer hw.Unlock()
+
+	if !hw.card.SDIO {
+		return fmt.Errorf("no SDIO card detected on uSDHC%d", hw.Index)
+	}
+
+	if fn < 0 || fn > 7 || addr > SDIO_MAX_ADDR {
+		return errors.New("invalid SDIO function or address")
+	}
+
+	size := len(buf)
+
+	switch {
+	case blockSize > 0:
+		if size%blockSize != 0 || size/blockSize > SDIO_MAX_BLOCKS {
+			return errors.New("invalid transfer size")
+		}
+
+		count = size / blockSize
+		bits.Set(&arg, SDIO_ARG_BLOCK)
+	default:
+		if size == 0 || size > SDIO_MAX_BYTES {
+			return errors.New("invalid transfer size")
+		}
+
+		// a zero count indicates 512 bytes
+		count = size % SDIO_MAX_BYTES
+		blockSize = size
+	}
+
+	bits.SetTo(&arg, SDIO_ARG_RW, dtd == WRITE)
+	bits.SetN(&arg, SDIO_ARG_FN, 0b111, uint32(fn))
+	bits.SetTo(&arg, SDIO_ARG_INCR, incr)
+	bits.SetN(&arg, SDIO_ARG_ADDR, SDIO_MAX_ADDR, addr)
+	bits.SetN(&arg, SDIO_ARG_COUNT, 0x1ff, uint32(count))
+
+	// CMD53 - IO_RW_EXTENDED - multiple register access
+	return hw.transfer(53, dtd, uint64(arg), uint32(size/blockSize), uint32(blockSize), buf)
+}
+
+// ReadExtended reads from an SDIO function (CMD53), from incrementing or
+// fixed (e.g. FIFO) addresses, in block mode when the argument block size
+// is not zero (which must match the function configured block size) and in
+// byte mode otherwise.
+func (hw *USDHC) ReadExtended(fn int, addr uint32, incr bool, blockSize int, buf []byte) (err error) {
+	return hw.rw53(READ, fn, addr, incr, blockSize, buf)
+}
+
+// WriteExtended writes to an SDIO function (CMD53), to incrementing or fixed
+// (e.g. FIFO) addresses, in block mode when the argument block size is not
+// zero (which must match the function configured block size) and in byte
+// mode otherwise.
+func (hw *USDHC) WriteExtended(fn int, addr uint32, incr bool, blockSize int, buf []byte) (err error) {
+	return hw.rw53(WRITE, fn, addr, incr, blockSize, buf)
+}
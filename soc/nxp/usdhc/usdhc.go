@@ -62,6 +62,7 @@ import (
 	"github.com/karlo195/tamago/bits"
 	"github.com/karlo195/tamago/dma"
 	"github.com/karlo195/tamago/internal/reg"
+	"github.com/karlo195/tamago/soc/nxp/ccm"
 )
 
 // USDHC registers (p4012, 58.8 uSDHC Memory Map/Register Definition, IMX6ULLRM).
@@ -88,6 +89,7 @@ const (
 	USDHCx_PRES_STATE = 0x24
 	PRES_STATE_DLSL   = 24
 	PRES_STATE_WPSPL  = 19
+	PRES_STATE_CINST  = 16
 	PRES_STATE_BREN   = 11
 	PRES_STATE_SDSTB  = 3
 	PRES_STATE_CDIHB  = 1
@@ -209,6 +211,8 @@ type CardInfo struct {
 	MMC bool
 	// SD card
 	SD bool
+	// SDIO card
+	SDIO bool
 	// High Capacity
 	HC bool
 	// High Speed
@@ -222,6 +226,8 @@ type CardInfo struct {
 	BlockSize int
 	// Capacity
 	Blocks int
+	// Replay Protected Memory Block partition size (eMMC only)
+	RPMBSize int
 
 	// device identification number
 	CID [16]byte
@@ -235,10 +241,8 @@ type USDHC struct {
 	Index int
 	// Base register
 	Base uint32
-	// Clock gate register
-	CCGR uint32
 	// Clock gate
-	CG int
+	Gate *ccm.Gate
 	// Clock setup function
 	SetClock func(index int, podf uint32, clksel uint32) error
 
@@ -249,6 +253,11 @@ type USDHC struct {
 	// low voltage indication (MMC) is successful.
 	LowVoltage func(enable bool) bool
 
+	// CardDetect is the board specific function which returns card
+	// presence (e.g. from a card detect GPIO), when not defined the
+	// controller card detection state is used (see [USDHC.Present]).
+	CardDetect func() bool
+
 	// bus width
 	width int
 	// Relative Card Address
@@ -276,6 +285,8 @@ type USDHC struct {
 
 	// detected card properties
 	card CardInfo
+	// card removal since detection
+	removed bool
 
 	// eMMC Replay Protected Memory Block (RPMB) operation
 	rpmb bool
@@ -365,7 +376,7 @@ func (hw *USDHC) Init(width int) {
 	hw.Lock()
 	defer hw.Unlock()
 
-	if hw.Index == 0 || hw.Base == 0 || hw.SetClock == nil || hw.CCGR == 0 {
+	if hw.Index == 0 || hw.Base == 0 || hw.SetClock == nil || hw.Gate == nil {
 		panic("invalid uSDHC controller instance")
 	}
 
@@ -398,7 +409,7 @@ func (hw *USDHC) Init(width int) {
 	hw.writeTimeout = 500 * time.Millisecond
 
 	// enable clock
-	reg.SetN(hw.CCGR, hw.CG, 0b11, 0b11)
+	hw.Gate.Enable()
 }
 
 // Detect initializes an SD/MMC card. The highest speed supported by the
@@ -414,12 +425,13 @@ func (hw *USDHC) Detect() (err error) {
 	}
 
 	// check if a card has already been detected and not removed since
-	if reg.Get(hw.int_status, INT_STATUS_CRM, 1) == 0 && (hw.card.MMC || hw.card.SD) {
+	if reg.Get(hw.int_status, INT_STATUS_CRM, 1) == 0 && (hw.card.MMC || hw.card.SD || hw.card.SDIO) {
 		return
 	}
 
 	// clear card information
 	hw.card = CardInfo{}
+	hw.removed = false
 
 	// soft reset uSDHC
 	reg.Set(hw.sys_ctrl, SYS_CTRL_RSTA)
@@ -472,7 +484,9 @@ func (hw *USDHC) Detect() (err error) {
 		return
 	}
 
-	if hw.voltageValidationSD() {
+	if hw.voltageValidationSDIO() {
+		err = hw.initSDIO()
+	} else if hw.voltageValidationSD() {
 		err = hw.initSD()
 	} else if hw.voltageValidationMMC() {
 		err = hw.initMMC()
@@ -484,7 +498,7 @@ func (hw *USDHC) Detect() (err error) {
 		return
 	}
 
-	if !hw.card.DDR {
+	if !hw.card.DDR && !hw.card.SDIO {
 		// CMD16 - SET_BLOCKLEN - define the block length,
 		// only legal In single data rate mode.
 		err = hw.cmd(16, uint32(hw.card.BlockSize), 0, 0)
@@ -504,6 +518,10 @@ func (hw *USDHC) transfer(index uint32, dtd uint32, arg uint64, blocks uint32, b
 		return errors.New("controller is not initialized")
 	}
 
+	if hw.removed {
+		return ErrCardRemoved
+	}
+
 	if blocks == 0 || blockSize == 0 {
 		return
 	}
@@ -512,8 +530,9 @@ func (hw *USDHC) transfer(index uint32, dtd uint32, arg uint64, blocks uint32, b
 		return errors.New("transfer size cannot exceed 65535 blocks")
 	}
 
-	// State polling cannot be issued while tuning (CMD19 and CMD21).
-	if !(index == 19 || index == 21) {
+	// State polling cannot be issued while tuning (CMD19 and CMD21) and
+	// is not supported by SDIO cards.
+	if !(index == 19 || index == 21 || hw.card.SDIO) {
 		if err = hw.waitState(CURRENT_STATE_TRAN, 1*time.Millisecond); err != nil {
 			return
 		}
@@ -567,7 +586,15 @@ func (hw *USDHC) transfer(index uint32, dtd uint32, arg uint64, blocks uint32, b
 		reg.SetN(hw.wtmk_lvl, WTMK_LVL_RD_WML, 0xff, blockSize/4)
 	}
 
-	err = hw.cmd(index, uint32(arg), blocks, timeout)
+	params, ok := cmds[index]
+
+	if !ok {
+		return fmt.Errorf("CMD%d unsupported", index)
+	}
+
+	params.dtd = dtd
+
+	err = hw.cmdParams(index, params, uint32(arg), blocks, timeout)
 	adma_err := reg.Read(hw.adma_err_status)
 
 	if err != nil {
@@ -586,6 +613,10 @@ func (hw *USDHC) transfer(index uint32, dtd uint32, arg uint64, blocks uint32, b
 }
 
 func (hw *USDHC) transferBlocks(index uint32, dtd uint32, lba int, buf []byte) (err error) {
+	if hw.removed {
+		return ErrCardRemoved
+	}
+
 	blockSize := hw.card.BlockSize
 	offset := uint64(lba) * uint64(blockSize)
 	size := len(buf)
@@ -620,6 +651,10 @@ func (hw *USDHC) ReadBlocks(lba int, buf []byte) (err error) {
 
 // Read transfers data from the card.
 func (hw *USDHC) Read(offset int64, size int64) (buf []byte, err error) {
+	if hw.removed {
+		return nil, ErrCardRemoved
+	}
+
 	blockSize := int64(hw.card.BlockSize)
 
 	if size == 0 || blockSize == 0 {
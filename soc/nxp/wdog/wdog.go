@@ -19,6 +19,7 @@ import (
 	"sync"
 
 	"github.com/karlo195/tamago/internal/reg"
+	"github.com/karlo195/tamago/soc/nxp/ccm"
 )
 
 // WDOG registers
@@ -61,10 +62,8 @@ type WDOG struct {
 	Index int
 	// Base register
 	Base uint32
-	// Clock gate register
-	CCGR uint32
 	// Clock gate
-	CG int
+	Gate *ccm.Gate
 	// Interrupt ID
 	IRQ int
 
@@ -83,7 +82,7 @@ func (hw *WDOG) Init() {
 	hw.Lock()
 	defer hw.Unlock()
 
-	if hw.Base == 0 || hw.CCGR == 0 {
+	if hw.Base == 0 || hw.Gate == nil {
 		panic("invalid WDOG module instance")
 	}
 
@@ -94,7 +93,7 @@ func (hw *WDOG) Init() {
 	hw.wmcr = hw.Base + WDOGx_WMCR
 
 	// enable clock
-	reg.SetN(hw.CCGR, hw.CG, 0b11, 0b11)
+	hw.Gate.Enable()
 
 	// p4085, 59.5.3 Power-down counter event, IMX6ULLRM
 	reg.Clear16(hw.wmcr, WMCR_PDE)
@@ -133,7 +132,7 @@ func (hw *WDOG) Service(timeout int) {
 	// In case we are a TrustZone Watchdog the Normal World OS might
 	// disable our clock, which keeps the timeout but prevents servicing,
 	// therefore we re-enable the clock.
-	reg.SetN(hw.CCGR, hw.CG, 0b11, 0b11)
+	hw.Gate.Enable()
 
 	// update timeout
 	reg.SetN16(hw.wcr, WCR_WT, 0xff, uint16(timeout/500-1))
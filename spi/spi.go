@@ -0,0 +1,23 @@
+// Serial Peripheral Interface (SPI) support
+// https://github.com/karlo195/tamago
+//
+// Copyright (c) The TamaGo Authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+// Package spi defines a generic Serial Peripheral Interface (SPI) bus
+// interface, between SoC controller drivers and the drivers of devices
+// attached to them (e.g. packages w5500, enc28j60, tft and nvstore).
+//
+// This package is only meant to be used with `GOOS=tamago` as supported by the
+// TamaGo framework for bare metal Go, see https://github.com/karlo195/tamago.
+package spi
+
+// Bus represents an SPI controller with an asserted chip select for the
+// duration of each transfer.
+type Bus interface {
+	// Transfer performs a full-duplex transfer, rx can be nil or must be
+	// the same length of tx.
+	Transfer(tx []byte, rx []byte) (err error)
+}
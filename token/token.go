@@ -0,0 +1,326 @@
+// Remote key operation service
+// https://github.com/karlo195/tamago
+//
+// Copyright (c) The TamaGo Authors. All Rights Reserved.
+//
+// Use of this source code is governed by the license
+// that can be found in the LICENSE file.
+
+// Package token implements a minimal remote key operation service, allowing
+// a host to use keys held by the device (e.g. backed by CAAM or DCP hardware
+// unique keys) for signing, decryption and attestation without their
+// disclosure, so that the device acts as a hardware security module.
+//
+// The service is transport agnostic and served over any reliable byte stream
+// (e.g. a vsock connection or USB bulk endpoints):
+//
+//	svc := &token.Service{
+//		Keys: []*token.Key{
+//			{ID: "fw-signing", Signer: signer},
+//		},
+//		Attestation: signer,
+//	}
+//
+//	svc.Serve(conn)
+//
+// Messages are length prefixed, a request is formed by its 32-bit big-endian
+// length, an operation code and an operation specific payload, a response by
+// its 32-bit big-endian length, a status code and an operation specific
+// payload:
+//
+//	OP_LIST:    request:  -
+//	            response: keys (1 byte id length, id, 1 byte capabilities)
+//	OP_PUBLIC:  request:  1 byte id length, id
+//	            response: PKIX, ASN.1 DER, public key
+//	OP_SIGN:    request:  1 byte id length, id, 1 byte crypto.Hash, digest
+//	            response: signature
+//	OP_DECRYPT: request:  1 byte id length, id, ciphertext
+//	            response: plaintext
+//	OP_ATTEST:  request:  nonce
+//	            response: 2 bytes claims length, claims, signature
+//
+// Attestation signatures are computed, with the [Service.Attestation] key,
+// over the SHA-256 digest of the nonce followed by the claims.
+//
+// This package is only meant to be used with `GOOS=tamago` as supported by the
+// TamaGo framework for bare metal Go, see https://github.com/karlo195/tamago.
+package token
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/binary"
+	"errors"
+	"io"
+	"sync"
+)
+
+// Operation codes
+const (
+	OP_LIST    = 0x01
+	OP_PUBLIC  = 0x02
+	OP_SIGN    = 0x03
+	OP_DECRYPT = 0x04
+	OP_ATTEST  = 0x05
+)
+
+// Status codes
+const (
+	STATUS_OK          = 0x00
+	STATUS_INVALID     = 0x01
+	STATUS_UNKNOWN_KEY = 0x02
+	STATUS_UNSUPPORTED = 0x03
+	STATUS_FAILED      = 0x04
+)
+
+// Key capabilities
+const (
+	CAP_SIGN    = 1 << 0
+	CAP_DECRYPT = 1 << 1
+)
+
+// MaxMessageSize is the maximum request size.
+const MaxMessageSize = 64 * 1024
+
+// MaxNonceSize is the maximum attestation nonce size.
+const MaxNonceSize = 64
+
+// Decrypter represents a decryption key, either asymmetric or symmetric
+// (e.g. a DCP AES key slot with the IV prepended to the ciphertext).
+type Decrypter interface {
+	Decrypt(ciphertext []byte) (plaintext []byte, err error)
+}
+
+// DecrypterFunc is an adapter to allow the use of ordinary functions as
+// [Decrypter].
+type DecrypterFunc func(ciphertext []byte) (plaintext []byte, err error)
+
+// Decrypt calls f(ciphertext).
+func (f DecrypterFunc) Decrypt(ciphertext []byte) ([]byte, error) {
+	return f(ciphertext)
+}
+
+// Key represents a key exposed by the service, at least one of Signer or
+// Decrypter must be set.
+type Key struct {
+	// ID is the key identifier (up to 255 bytes).
+	ID string
+	// Signer performs signing operations.
+	Signer crypto.Signer
+	// Decrypter performs decryption operations.
+	Decrypter Decrypter
+}
+
+func (k *Key) capabilities() (caps uint8) {
+	if k.Signer != nil {
+		caps |= CAP_SIGN
+	}
+
+	if k.Decrypter != nil {
+		caps |= CAP_DECRYPT
+	}
+
+	return
+}
+
+// Service represents a remote key operation service instance.
+type Service struct {
+	sync.Mutex
+
+	// Keys is the list of exposed keys.
+	Keys []*Key
+
+	// Attestation is the key used to sign attestation evidence, when nil
+	// attestation requests are not supported.
+	Attestation crypto.Signer
+	// Claims returns the attested device state (e.g. firmware
+	// measurements), up to 65535 bytes.
+	Claims func() []byte
+}
+
+func (s *Service) lookup(id []byte) *Key {
+	for _, k := range s.Keys {
+		if k.ID == string(id) {
+			return k
+		}
+	}
+
+	return nil
+}
+
+// parseID splits a request payload in key identifier and data.
+func parseID(payload []byte) (id []byte, data []byte, ok bool) {
+	if len(payload) < 1 || len(payload) < 1+int(payload[0]) {
+		return
+	}
+
+	n := int(payload[0])
+
+	return payload[1 : 1+n], payload[1+n:], true
+}
+
+// Handle processes a single request, without its length prefix, and returns
+// the corresponding status and response payload.
+func (s *Service) Handle(req []byte) (status uint8, res []byte) {
+	s.Lock()
+	defer s.Unlock()
+
+	if len(req) < 1 {
+		return STATUS_INVALID, nil
+	}
+
+	op := req[0]
+	payload := req[1:]
+
+	if op == OP_LIST {
+		buf := new(bytes.Buffer)
+
+		for _, k := range s.Keys {
+			if len(k.ID) > 0xff {
+				continue
+			}
+
+			buf.WriteByte(uint8(len(k.ID)))
+			buf.WriteString(k.ID)
+			buf.WriteByte(k.capabilities())
+		}
+
+		return STATUS_OK, buf.Bytes()
+	}
+
+	if op == OP_ATTEST {
+		return s.attest(payload)
+	}
+
+	id, data, ok := parseID(payload)
+
+	if !ok {
+		return STATUS_INVALID, nil
+	}
+
+	k := s.lookup(id)
+
+	if k == nil {
+		return STATUS_UNKNOWN_KEY, nil
+	}
+
+	var err error
+
+	switch op {
+	case OP_PUBLIC:
+		if k.Signer == nil {
+			return STATUS_UNSUPPORTED, nil
+		}
+
+		res, err = x509.MarshalPKIXPublicKey(k.Signer.Public())
+	case OP_SIGN:
+		if k.Signer == nil {
+			return STATUS_UNSUPPORTED, nil
+		}
+
+		if len(data) < 1 {
+			return STATUS_INVALID, nil
+		}
+
+		h := crypto.Hash(data[0])
+
+		if !h.Available() || len(data)-1 != h.Size() {
+			return STATUS_INVALID, nil
+		}
+
+		res, err = k.Signer.Sign(rand.Reader, data[1:], h)
+	case OP_DECRYPT:
+		if k.Decrypter == nil {
+			return STATUS_UNSUPPORTED, nil
+		}
+
+		res, err = k.Decrypter.Decrypt(data)
+	default:
+		return STATUS_UNSUPPORTED, nil
+	}
+
+	if err != nil {
+		return STATUS_FAILED, nil
+	}
+
+	return STATUS_OK, res
+}
+
+func (s *Service) attest(nonce []byte) (status uint8, res []byte) {
+	if s.Attestation == nil {
+		return STATUS_UNSUPPORTED, nil
+	}
+
+	if len(nonce) == 0 || len(nonce) > MaxNonceSize {
+		return STATUS_INVALID, nil
+	}
+
+	var claims []byte
+
+	if s.Claims != nil {
+		claims = s.Claims()
+	}
+
+	if len(claims) > 0xffff {
+		return STATUS_FAILED, nil
+	}
+
+	h := sha256.New()
+	h.Write(nonce)
+	h.Write(claims)
+
+	sig, err := s.Attestation.Sign(rand.Reader, h.Sum(nil), crypto.SHA256)
+
+	if err != nil {
+		return STATUS_FAILED, nil
+	}
+
+	res = binary.BigEndian.AppendUint16(nil, uint16(len(claims)))
+	res = append(res, claims...)
+	res = append(res, sig...)
+
+	return STATUS_OK, res
+}
+
+// Serve processes requests read from the argument stream, and writes their
+// responses, until the stream is closed or a transport error occurs.
+func (s *Service) Serve(rw io.ReadWriter) (err error) {
+	hdr := make([]byte, 4)
+
+	for {
+		if _, err = io.ReadFull(rw, hdr); err != nil {
+			break
+		}
+
+		n := binary.BigEndian.Uint32(hdr)
+
+		if n > MaxMessageSize {
+			return errors.New("invalid message size")
+		}
+
+		req := make([]byte, n)
+
+		if _, err = io.ReadFull(rw, req); err != nil {
+			break
+		}
+
+		status, payload := s.Handle(req)
+
+		res := binary.BigEndian.AppendUint32(nil, uint32(1+len(payload)))
+		res = append(res, status)
+		res = append(res, payload...)
+
+		if _, err = rw.Write(res); err != nil {
+			return
+		}
+	}
+
+	if err == io.EOF {
+		err = nil
+	}
+
+	return
+}